@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/therealutkarshpriyadarshi/balance/pkg/admin"
 	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
 	"github.com/therealutkarshpriyadarshi/balance/pkg/proxy"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/systemd"
 )
 
 var (
@@ -19,9 +32,29 @@ var (
 	BuildTime = "unknown"
 )
 
+const (
+	// defaultPidfile is where balance reload looks for the running proxy by default
+	defaultPidfile = "/run/balance.pid"
+
+	// healthcheckTimeout bounds the health check made by -healthcheck
+	healthcheckTimeout = 3 * time.Second
+)
+
 func main() {
+	// balance reload signals a running proxy to reload its configuration
+	if len(os.Args) > 1 && os.Args[1] == "reload" {
+		if err := reload(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "balance reload: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Command-line flags
-	configPath := flag.String("config", "config.yaml", "Path to configuration file")
+	configPath := flag.String("config", envOr("BALANCE_CONFIG", "config.yaml"), "Path to configuration file (- reads it from stdin)")
+	pidfile := flag.String("pidfile", "", "Write the process ID to this file, for balance reload")
+	container := flag.Bool("container", envBool("BALANCE_CONTAINER"), "Use defaults suited to containers: JSON logs on stdout and a shutdown within a 30s grace period")
+	healthcheck := flag.Bool("healthcheck", false, "Check the health of the running proxy and exit, e.g. as a Docker HEALTHCHECK")
 	showVersion := flag.Bool("version", false, "Show version information")
 	flag.Parse()
 
@@ -33,8 +66,19 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Check a running proxy and exit
+	if *healthcheck {
+		if err := runHealthcheck(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("healthy")
+		return
+	}
+
 	// Load configuration
-	cfg, err := config.Load(*configPath)
+	config.SetContainerMode(*container)
+	cfg, err := loadConfig(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
@@ -44,18 +88,39 @@ func main() {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
 
+	// Send application logs to the configured output, in the configured format
+	logger, logOutput, err := newLogger(cfg.Logging)
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	defer logOutput.Close()
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logging.InfoLevel))
+
+	// Apply metric label policy before any metrics are recorded
+	if err := metrics.SetClientLabelMode(cfg.Metrics.ClientLabel); err != nil {
+		log.Fatalf("Invalid metrics configuration: %v", err)
+	}
+	metrics.RegisterRuntimeCollectors()
+
 	log.Printf("Starting Balance proxy (version: %s)", Version)
-	log.Printf("Loaded configuration from: %s", *configPath)
+	if *configPath == "-" {
+		log.Println("Loaded configuration from stdin")
+	} else {
+		log.Printf("Loaded configuration from: %s", *configPath)
+	}
 
 	// Create proxy server based on configuration
 	var server *proxy.Server
 	switch cfg.Mode {
 	case "tcp":
-		server, err = proxy.NewTCPServer(cfg)
+		server, err = proxy.NewTCPServer(cfg, logger)
 	case "http":
-		server, err = proxy.NewHTTPServer(cfg)
+		server, err = proxy.NewHTTPServer(cfg, logger)
+	case "auto":
+		server, err = proxy.NewAutoServer(cfg, logger)
 	default:
-		log.Fatalf("Unsupported mode: %s (supported: tcp, http)", cfg.Mode)
+		log.Fatalf("Unsupported mode: %s (supported: tcp, http, auto)", cfg.Mode)
 	}
 
 	if err != nil {
@@ -69,21 +134,322 @@ func main() {
 
 	log.Printf("Proxy listening on %s (mode: %s)", cfg.Listen, cfg.Mode)
 
-	// Wait for shutdown signal
-	waitForShutdown(server)
+	// Publish statistics for expvar-based tooling
+	server.PublishExpvar()
+
+	// Start the admin API if enabled
+	var adminServer *admin.Server
+	if cfg.Admin != nil && cfg.Admin.Enabled {
+		admin.Version = Version
+		admin.GitCommit = GitCommit
+		admin.BuildTime = BuildTime
+
+		auth, tlsConfig, err := adminSecurity(cfg.Admin)
+		if err != nil {
+			log.Fatalf("Invalid admin configuration: %v", err)
+		}
+
+		adminServer = admin.NewServer(admin.Config{
+			Listen: cfg.Admin.Listen,
+			HealthFunc: func() bool {
+				return server.Pool().HealthySize() > 0
+			},
+			DrainingFunc:        server.Draining,
+			StatsFunc:           server.AdminStats,
+			Pool:                server.Pool(),
+			StateMachineFunc:    server.StateMachine,
+			AuditLog:            server.AuditLog(),
+			Capture:             server.Capture(),
+			CircuitBreakersFunc: server.CircuitBreakers,
+			RateLimitResetFunc:  server.ResetRateLimit,
+			Blocklist:           server.Blocklist(),
+			Certificates:        server.Certificates(),
+			TLSReloadFunc:       server.ReloadCertificates,
+			ConfigFunc:          server.Config,
+			ConfigPath:          *configPath,
+			ConfigApplyFunc:     server.ApplyConfig,
+			Auth:                auth,
+			TLSConfig:           tlsConfig,
+		})
+		if err := adminServer.Start(); err != nil {
+			log.Fatalf("Failed to start admin server: %v", err)
+		}
+
+		log.Printf("Admin API listening on %s", cfg.Admin.Listen)
+	}
+
+	if *pidfile != "" {
+		if err := writePidfile(*pidfile); err != nil {
+			log.Fatalf("Failed to write pidfile: %v", err)
+		}
+		defer removePidfile(*pidfile)
+	}
+
+	// Tell systemd the listeners are bound, and keep its watchdog fed until exit
+	notifySystemd(systemd.Ready)
+	watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+	defer stopWatchdog()
+	if interval, err := systemd.WatchdogInterval(); err != nil {
+		log.Printf("Systemd watchdog disabled: %v", err)
+	} else if interval > 0 {
+		log.Printf("Systemd watchdog enabled (keepalive every %s)", interval)
+		go systemd.RunWatchdog(watchdogCtx, interval, func() bool {
+			// Size takes the pool lock, so keepalives stop if the pool deadlocks
+			server.Pool().Size()
+			return true
+		})
+	}
+
+	// Wait for shutdown signal, reloading the configuration on SIGHUP
+	waitForShutdown(server, adminServer, *configPath)
+}
+
+// adminSecurity creates the authentication and TLS configuration of the admin API
+func adminSecurity(cfg *config.AdminConfig) (*admin.Auth, *tls.Config, error) {
+	var auth *admin.Auth
+	if cfg.Auth != nil {
+		var err error
+		if auth, err = admin.NewAuth(cfg.Auth); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cfg.TLS == nil {
+		return auth, nil, nil
+	}
+	tlsConfig, err := admin.NewTLSConfig(cfg.TLS, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	return auth, tlsConfig, nil
+}
+
+// newLogger creates the application logger from the logging configuration
+// Without one, info and above is logged as text to stderr.
+func newLogger(cfg *config.LoggingConfig) (*logging.Logger, io.Closer, error) {
+	if cfg == nil {
+		cfg = &config.LoggingConfig{}
+	}
+	level, err := logging.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	format, err := logging.ParseFormat(cfg.Format)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := cfg.Output
+	if output == "" {
+		output = "stderr"
+	}
+	w, err := logging.OpenOutput(output)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log output: %w", err)
+	}
+
+	return logging.NewLogger(logging.Config{
+		Level:     level,
+		Format:    format,
+		Output:    w,
+		AddCaller: cfg.AddCaller,
+	}), w, nil
 }
 
 // waitForShutdown waits for interrupt signal and gracefully shuts down the server
-func waitForShutdown(server *proxy.Server) {
+// SIGHUP reloads the configuration in the meantime.
+func waitForShutdown(server *proxy.Server, adminServer *admin.Server, configPath string) {
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
-	<-sigChan
+	for sig := <-sigChan; sig == syscall.SIGHUP; sig = <-sigChan {
+		reloadConfig(server, configPath)
+	}
 	log.Println("Shutdown signal received, gracefully shutting down...")
+	// The watchdog keeps running while connections drain
+	notifySystemd(systemd.Stopping)
 
 	if err := server.Shutdown(); err != nil {
 		log.Printf("Error during shutdown: %v", err)
 	}
 
+	if adminServer != nil {
+		if err := adminServer.Shutdown(); err != nil {
+			log.Printf("Error shutting down admin server: %v", err)
+		}
+	}
+
 	log.Println("Server stopped")
 }
+
+// reloadConfig loads the configuration file again and applies it to the running proxy
+// Like POST /config/apply, only backend changes are applied; if anything else changed,
+// the running configuration is left as it was.
+func reloadConfig(server *proxy.Server, configPath string) {
+	log.Printf("Reload signal received, reloading configuration from %s", configPath)
+	notifySystemd(systemd.Reloading)
+	defer notifySystemd(systemd.Ready)
+
+	if configPath == "-" {
+		log.Println("Failed to reload configuration: it was read from stdin")
+		return
+	}
+	cfg, err := config.Load(configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil {
+		err = server.ApplyConfig(cfg)
+	}
+	if err != nil {
+		log.Printf("Failed to reload configuration: %v", err)
+		return
+	}
+	log.Println("Configuration reloaded")
+}
+
+// loadConfig loads the configuration file, or reads the configuration from stdin if
+// path is "-"
+func loadConfig(path string) (*config.Config, error) {
+	if path != "-" {
+		return config.Load(path)
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config from stdin: %w", err)
+	}
+	return config.Parse(data, path)
+}
+
+// runHealthcheck checks the proxy running with the configuration at configPath: through
+// /health on the admin API if it is enabled, or by connecting to the proxy otherwise
+// A configuration read from stdin cannot be read again, so the admin API must be given
+// by BALANCE_ADMIN_LISTEN.
+func runHealthcheck(configPath string) error {
+	var cfg *config.Config
+	if configPath == "-" {
+		listen := os.Getenv("BALANCE_ADMIN_LISTEN")
+		if listen == "" {
+			return fmt.Errorf("a configuration read from stdin cannot be read again: set BALANCE_ADMIN_LISTEN to the admin API address")
+		}
+		cfg = &config.Config{Admin: &config.AdminConfig{Enabled: true, Listen: listen}}
+	} else {
+		var err error
+		if cfg, err = config.Load(configPath); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Admin == nil || !cfg.Admin.Enabled {
+		conn, err := net.DialTimeout("tcp", localAddress(cfg.Listen), healthcheckTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	client := &http.Client{Timeout: healthcheckTimeout}
+	scheme := "http"
+	if cfg.Admin.TLS != nil {
+		scheme = "https"
+		// The proxy's own listener is checked, whatever name its certificate has
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	resp, err := client.Get(scheme + "://" + localAddress(cfg.Admin.Listen) + "/health")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+// localAddress turns a listen address such as ":9090" into an address to connect to
+// on this host
+func localAddress(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// envOr returns the value of an environment variable, or def if it is not set
+func envOr(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// envBool reports whether an environment variable is set to true, e.g. "1" or "true"
+func envBool(name string) bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(name))
+	return enabled
+}
+
+// notifySystemd sends a state to systemd, if it started the proxy
+func notifySystemd(state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		log.Printf("Failed to notify systemd: %v", err)
+	}
+}
+
+// reload implements balance reload: it sends SIGHUP to the proxy whose process ID is
+// in the pidfile
+func reload(args []string) error {
+	flags := flag.NewFlagSet("reload", flag.ExitOnError)
+	pidfile := flags.String("pidfile", defaultPidfile, "Pidfile of the running proxy")
+	flags.Parse(args)
+
+	pid, err := readPidfile(*pidfile)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Kill(pid, syscall.SIGHUP); err != nil {
+		return fmt.Errorf("failed to signal process %d: %w", pid, err)
+	}
+	fmt.Printf("Sent reload signal to balance (pid %d)\n", pid)
+	return nil
+}
+
+// writePidfile writes the process ID to path, refusing to replace the pidfile of
+// another running proxy
+func writePidfile(path string) error {
+	if pid, err := readPidfile(path); err == nil && pid != os.Getpid() {
+		// EPERM means the process exists but belongs to another user
+		if err := syscall.Kill(pid, 0); err == nil || errors.Is(err, syscall.EPERM) {
+			return fmt.Errorf("%s belongs to running process %d", path, pid)
+		}
+	}
+
+	// Write and rename, so readers never see a partly written file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// removePidfile removes the pidfile on exit, unless another process has replaced it
+func removePidfile(path string) {
+	if pid, err := readPidfile(path); err == nil && pid == os.Getpid() {
+		os.Remove(path)
+	}
+}
+
+// readPidfile returns the process ID in a pidfile
+func readPidfile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read pidfile: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pidfile %s", path)
+	}
+	return pid, nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/lb"
 )
 
 var (
@@ -52,8 +53,8 @@ func main() {
 	errors := []string{}
 
 	// Check mode
-	if cfg.Mode != "tcp" && cfg.Mode != "http" {
-		errors = append(errors, fmt.Sprintf("invalid mode '%s' (must be 'tcp' or 'http')", cfg.Mode))
+	if cfg.Mode != "tcp" && cfg.Mode != "http" && cfg.Mode != "auto" {
+		errors = append(errors, fmt.Sprintf("invalid mode '%s' (must be 'tcp', 'http' or 'auto')", cfg.Mode))
 	}
 
 	// Check backends
@@ -62,15 +63,7 @@ func main() {
 	}
 
 	// Check load balancer algorithm
-	validAlgorithms := map[string]bool{
-		"round-robin":       true,
-		"least-connections": true,
-		"weighted-round-robin": true,
-		"weighted-least-connections": true,
-		"consistent-hash":   true,
-		"bounded-load":      true,
-	}
-	if cfg.LoadBalancer.Algorithm != "" && !validAlgorithms[cfg.LoadBalancer.Algorithm] {
+	if cfg.LoadBalancer.Algorithm != "" && !lb.IsRegistered(cfg.LoadBalancer.Algorithm) {
 		errors = append(errors, fmt.Sprintf("invalid load balancer algorithm '%s'", cfg.LoadBalancer.Algorithm))
 	}
 
@@ -1,14 +1,29 @@
 package admin
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/security"
+	balancetls "github.com/therealutkarshpriyadarshi/balance/pkg/tls"
 )
 
 // Server represents the admin HTTP server for health checks and metrics
@@ -18,12 +33,90 @@ type Server struct {
 	mu         sync.RWMutex
 	startTime  time.Time
 	healthFunc func() bool
+	drainFunc  func() bool
+	statsFunc  func() map[string]interface{}
+	stateFunc  func(name string) *backend.StateMachine
+	pool       *backend.Pool
+	audit      *logging.AuditLogger
+	capture    *logging.RequestCapture
+	breakers   func() map[string]*resilience.CircuitBreaker
+	rateReset  func(key string) bool
+	blocklist  *security.IPBlocklist
+	certs      *balancetls.CertificateManager
+	tlsReload  func() error
+	auth       *Auth
+
+	configFunc  func() *config.Config
+	configPath  string
+	configApply func(*config.Config) error
+
+	// ctx is canceled on shutdown to stop background drains
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	drainMu sync.Mutex
+	drains  map[string]*drainOperation
 }
 
 // Config contains configuration for the admin server
 type Config struct {
 	Listen     string
 	HealthFunc func() bool
+
+	// DrainingFunc reports whether the proxy is shutting down; /ready fails while it returns true
+	DrainingFunc func() bool
+
+	// StatsFunc returns the aggregated proxy statistics served on /stats
+	StatsFunc func() map[string]interface{}
+
+	// Pool is the backend pool managed by the /backends endpoints
+	Pool *backend.Pool
+
+	// StateMachineFunc returns a backend's health state machine (nil if health checking is disabled)
+	StateMachineFunc func(name string) *backend.StateMachine
+
+	// AuditLog records changes made through the API (nil if audit logging is disabled)
+	AuditLog *logging.AuditLogger
+
+	// Capture is the debug capture of proxied requests managed by /debug/capture
+	// (nil if the proxy does not capture requests)
+	Capture *logging.RequestCapture
+
+	// CircuitBreakersFunc returns the circuit breakers by backend name managed by
+	// /circuit-breakers (nil if circuit breaking is disabled)
+	CircuitBreakersFunc func() map[string]*resilience.CircuitBreaker
+
+	// RateLimitResetFunc clears the rate limit state of a key for DELETE /rate-limits/{key},
+	// returning false if rate limiting is disabled
+	RateLimitResetFunc func(key string) bool
+
+	// Blocklist is the IP blocklist managed by /blocklist (nil if security is disabled)
+	Blocklist *security.IPBlocklist
+
+	// Certificates are the certificates of the TLS listener listed by /tls/certificates
+	// (nil if TLS termination is disabled)
+	Certificates *balancetls.CertificateManager
+
+	// TLSReloadFunc reads the TLS certificate files again for POST /tls/reload
+	TLSReloadFunc func() error
+
+	// ConfigFunc returns the running configuration, which /config/validate and
+	// /config/apply diff submitted configurations against
+	ConfigFunc func() *config.Config
+
+	// ConfigPath is the file the configuration was loaded from; relative paths in
+	// submitted configurations resolve against its directory
+	ConfigPath string
+
+	// ConfigApplyFunc applies a validated configuration to the running proxy
+	ConfigApplyFunc func(*config.Config) error
+
+	// Auth requires clients to authenticate with a token or client certificate whose
+	// role allows the request (nil serves every client)
+	Auth *Auth
+
+	// TLSConfig serves the API over HTTPS (nil serves plain HTTP)
+	TLSConfig *tls.Config
 }
 
 // NewServer creates a new admin server
@@ -32,7 +125,25 @@ func NewServer(cfg Config) *Server {
 		addr:       cfg.Listen,
 		startTime:  time.Now(),
 		healthFunc: cfg.HealthFunc,
+		drainFunc:  cfg.DrainingFunc,
+		statsFunc:  cfg.StatsFunc,
+		stateFunc:  cfg.StateMachineFunc,
+		pool:       cfg.Pool,
+		audit:      cfg.AuditLog,
+		capture:    cfg.Capture,
+		breakers:   cfg.CircuitBreakersFunc,
+		rateReset:  cfg.RateLimitResetFunc,
+		blocklist:  cfg.Blocklist,
+		certs:      cfg.Certificates,
+		tlsReload:  cfg.TLSReloadFunc,
+		auth:       cfg.Auth,
+		drains:     make(map[string]*drainOperation),
+
+		configFunc:  cfg.ConfigFunc,
+		configPath:  cfg.ConfigPath,
+		configApply: cfg.ConfigApplyFunc,
 	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.handleHealth)
@@ -41,11 +152,35 @@ func NewServer(cfg Config) *Server {
 	mux.HandleFunc("/readyz", s.handleReady) // Kubernetes-style readiness check
 	mux.HandleFunc("/status", s.handleStatus)
 	mux.HandleFunc("/version", s.handleVersion)
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/stats/stream", s.handleStatsStream)
+	mux.HandleFunc("/backends", s.handleBackends)
+	mux.HandleFunc("/backends/{name}/weight", s.handleBackendWeight)
+	mux.HandleFunc("/backends/{name}/state", s.handleBackendState)
+	mux.HandleFunc("/backends/{name}/drain", s.handleBackendDrain)
+	mux.HandleFunc("/backends/{name}/undrain", s.handleBackendUndrain)
+	mux.HandleFunc("/circuit-breakers", s.handleCircuitBreakers)
+	mux.HandleFunc("/circuit-breakers/{backend}/reset", s.handleCircuitBreakerReset)
+	mux.HandleFunc("/rate-limits/{key...}", s.handleRateLimitReset)
+	mux.HandleFunc("/blocklist", s.handleBlocklist)
+	mux.HandleFunc("/blocklist/{target...}", s.handleBlocklistEntry)
+	mux.HandleFunc("/tls/certificates", s.handleTLSCertificates)
+	mux.HandleFunc("/tls/reload", s.handleTLSReload)
+	mux.HandleFunc("/config/validate", s.handleConfigValidate)
+	mux.HandleFunc("/config/apply", s.handleConfigApply)
+	mux.HandleFunc("/debug/capture", s.handleCapture)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/metrics", metrics.MetricsHandler())
+
+	var handler http.Handler = mux
+	if s.auth != nil {
+		handler = s.authorize(mux)
+	}
 
 	s.server = &http.Server{
 		Addr:         cfg.Listen,
-		Handler:      mux,
+		Handler:      handler,
+		TLSConfig:    cfg.TLSConfig,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -55,9 +190,22 @@ func NewServer(cfg Config) *Server {
 }
 
 // Start starts the admin server
+// The listener is bound before Start returns, so a busy address is reported as an error.
 func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.server.TLSConfig != nil {
+			// The certificates are in the TLS configuration
+			err = s.server.ServeTLS(listener, "", "")
+		} else {
+			err = s.server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			fmt.Printf("Admin server error: %v\n", err)
 		}
 	}()
@@ -66,6 +214,7 @@ func (s *Server) Start() error {
 
 // Shutdown gracefully shuts down the admin server
 func (s *Server) Shutdown() error {
+	s.cancel()
 	return s.server.Close()
 }
 
@@ -135,12 +284,26 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleReady handles the /ready endpoint (for readiness probes)
+// The proxy is ready while it is healthy and not draining
 func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
-	// For now, readiness is the same as health
-	// In the future, this could check if backends are available
+	if s.draining() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(HealthResponse{
+			Status:  "draining",
+			Message: "shutting down",
+		})
+		return
+	}
+
 	s.handleHealth(w, r)
 }
 
+// draining reports whether the proxy is shutting down
+func (s *Server) draining() bool {
+	return s.drainFunc != nil && s.drainFunc()
+}
+
 // handleStatus handles the /status endpoint
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	s.mu.RLock()
@@ -152,8 +315,13 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	uptime := time.Since(s.startTime)
 	uptimeSeconds := int64(uptime.Seconds())
 
+	state := "running"
+	if s.draining() {
+		state = "draining"
+	}
+
 	status := StatusResponse{
-		Status:        "running",
+		Status:        state,
 		Uptime:        uptime.String(),
 		UptimeSeconds: uptimeSeconds,
 		Version:       Version,
@@ -186,3 +354,643 @@ func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(version)
 }
+
+// handleStats handles the /stats endpoint
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.stats())
+}
+
+// stats returns a snapshot of the statistics served on /stats
+func (s *Server) stats() map[string]interface{} {
+	stats := make(map[string]interface{})
+	if s.statsFunc != nil {
+		for k, v := range s.statsFunc() {
+			stats[k] = v
+		}
+	}
+	stats["uptime_seconds"] = int64(time.Since(s.startTime).Seconds())
+	stats["timestamp"] = time.Now()
+	return stats
+}
+
+// BackendRequest is the body accepted by POST /backends
+type BackendRequest struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Weight  *int   `json:"weight"`
+}
+
+// BackendResponse describes a backend in the pool
+type BackendResponse struct {
+	Backend string `json:"backend"`
+	Address string `json:"address"`
+	Weight  int    `json:"weight"`
+}
+
+// handleBackends handles the /backends endpoint
+// POST adds a backend to the pool; invalid addresses are rejected with 400 and
+// names or addresses already in the pool with 409
+func (s *Server) handleBackends(w http.ResponseWriter, r *http.Request) {
+	if s.pool == nil {
+		http.Error(w, "Backend pool not available", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BackendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" || req.Address == "" {
+		http.Error(w, "Invalid request body: expected {\"name\": <string>, \"address\": \"host:port\", \"weight\": <int>}", http.StatusBadRequest)
+		return
+	}
+	weight := 1
+	if req.Weight != nil {
+		weight = *req.Weight
+	}
+	if weight < 0 {
+		http.Error(w, "Weight must be non-negative", http.StatusBadRequest)
+		return
+	}
+
+	b := backend.NewBackend(req.Name, req.Address, weight)
+	if err := s.pool.Add(b); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, backend.ErrDuplicateBackend) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	log.Printf("Backend %s (%s) added via admin API", b.Name(), b.Address())
+	s.auditChange(r, map[string]string{
+		"backend": b.Name(),
+		"address": b.Address(),
+		"weight":  strconv.Itoa(b.Weight()),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(BackendResponse{
+		Backend: b.Name(),
+		Address: b.Address(),
+		Weight:  b.Weight(),
+	})
+}
+
+// WeightRequest is the body accepted by PUT /backends/{name}/weight
+type WeightRequest struct {
+	Weight *int `json:"weight"`
+}
+
+// WeightResponse reports a backend's weight
+type WeightResponse struct {
+	Backend string `json:"backend"`
+	Weight  int    `json:"weight"`
+}
+
+// handleBackendWeight handles the /backends/{name}/weight endpoint
+// GET returns the current weight and PUT changes it without rebuilding the pool
+func (s *Server) handleBackendWeight(w http.ResponseWriter, r *http.Request) {
+	if s.pool == nil {
+		http.Error(w, "Backend pool not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	name := r.PathValue("name")
+	b := s.pool.Get(name)
+	if b == nil {
+		http.Error(w, fmt.Sprintf("Backend not found: %s", name), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req WeightRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Weight == nil {
+			http.Error(w, "Invalid request body: expected {\"weight\": <int>}", http.StatusBadRequest)
+			return
+		}
+		if *req.Weight < 0 {
+			http.Error(w, "Weight must be non-negative", http.StatusBadRequest)
+			return
+		}
+
+		old := b.Weight()
+		b.SetWeight(*req.Weight)
+		log.Printf("Backend %s weight changed from %d to %d", name, old, *req.Weight)
+		s.auditChange(r, map[string]string{
+			"backend":    name,
+			"old_weight": strconv.Itoa(old),
+			"weight":     strconv.Itoa(*req.Weight),
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(WeightResponse{
+		Backend: name,
+		Weight:  b.Weight(),
+	})
+}
+
+// StateRequest is the body accepted by POST /backends/{name}/state
+type StateRequest struct {
+	// State is "healthy", "unhealthy" or "draining"
+	State string `json:"state"`
+}
+
+// StateResponse reports a backend's health state
+type StateResponse struct {
+	Backend string `json:"backend"`
+	State   string `json:"state"`
+	Healthy bool   `json:"healthy"`
+}
+
+// handleBackendState handles the /backends/{name}/state endpoint
+// GET returns the current state and POST overrides it, e.g. to pull a backend out of rotation
+func (s *Server) handleBackendState(w http.ResponseWriter, r *http.Request) {
+	if s.pool == nil {
+		http.Error(w, "Backend pool not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	name := r.PathValue("name")
+	b := s.pool.Get(name)
+	if b == nil {
+		http.Error(w, fmt.Sprintf("Backend not found: %s", name), http.StatusNotFound)
+		return
+	}
+
+	sm := s.stateMachine(name)
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req StateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: expected {\"state\": \"healthy|unhealthy|draining\"}", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case req.State == "healthy" && sm != nil:
+			sm.ForceHealthy()
+		case req.State == "unhealthy" && sm != nil:
+			sm.ForceUnhealthy()
+		case req.State == "draining" && sm != nil:
+			sm.StartDraining()
+		case req.State == "healthy":
+			b.MarkHealthy()
+		case req.State == "unhealthy":
+			b.MarkUnhealthy()
+		case req.State == "draining":
+			http.Error(w, "Draining requires health checking to be enabled", http.StatusConflict)
+			return
+		default:
+			http.Error(w, fmt.Sprintf("Invalid state: %q (must be healthy, unhealthy or draining)", req.State), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Backend %s state set to %s via admin API", name, req.State)
+		s.auditChange(r, map[string]string{
+			"backend": name,
+			"state":   req.State,
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newStateResponse(name, b, sm))
+}
+
+// stateMachine returns a backend's health state machine (nil if health checking is disabled)
+func (s *Server) stateMachine(name string) *backend.StateMachine {
+	if s.stateFunc == nil {
+		return nil
+	}
+	return s.stateFunc(name)
+}
+
+// newStateResponse describes the health state of a backend
+func newStateResponse(name string, b *backend.Backend, sm *backend.StateMachine) StateResponse {
+	resp := StateResponse{Backend: name, Healthy: b.IsHealthy()}
+	switch {
+	case sm != nil:
+		resp.State = sm.GetState().String()
+	case b.IsDraining():
+		resp.State = backend.StateDraining.String()
+	case resp.Healthy:
+		resp.State = backend.StateHealthy.String()
+	default:
+		resp.State = backend.StateUnhealthy.String()
+	}
+	return resp
+}
+
+// CircuitBreakerResponse describes a backend's circuit breaker
+type CircuitBreakerResponse struct {
+	Backend             string    `json:"backend"`
+	State               string    `json:"state"`
+	TotalRequests       uint64    `json:"total_requests"`
+	TotalSuccesses      uint64    `json:"total_successes"`
+	TotalFailures       uint64    `json:"total_failures"`
+	TotalRejected       uint64    `json:"total_rejected"`
+	ConsecutiveFailures uint32    `json:"consecutive_failures"`
+	StateChangedAt      time.Time `json:"state_changed_at"`
+}
+
+// newCircuitBreakerResponse describes the circuit breaker of a backend
+func newCircuitBreakerResponse(name string, cb *resilience.CircuitBreaker) CircuitBreakerResponse {
+	m := cb.GetMetrics()
+	return CircuitBreakerResponse{
+		Backend:             name,
+		State:               m.State.String(),
+		TotalRequests:       m.TotalRequests,
+		TotalSuccesses:      m.TotalSuccesses,
+		TotalFailures:       m.TotalFailures,
+		TotalRejected:       m.TotalRejected,
+		ConsecutiveFailures: m.ConsecutiveFailures,
+		StateChangedAt:      m.StateChangedAt,
+	}
+}
+
+// circuitBreakers returns the circuit breakers by backend name (nil if circuit breaking is disabled)
+func (s *Server) circuitBreakers() map[string]*resilience.CircuitBreaker {
+	if s.breakers == nil {
+		return nil
+	}
+	return s.breakers()
+}
+
+// handleCircuitBreakers handles the /circuit-breakers endpoint
+// GET returns the state and counters of every backend's circuit breaker, sorted by backend
+func (s *Server) handleCircuitBreakers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	breakers := s.circuitBreakers()
+	if breakers == nil {
+		http.Error(w, "Circuit breakers not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	resp := make([]CircuitBreakerResponse, 0, len(breakers))
+	for name, cb := range breakers {
+		resp = append(resp, newCircuitBreakerResponse(name, cb))
+	}
+	sort.Slice(resp, func(i, j int) bool {
+		return resp[i].Backend < resp[j].Backend
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleCircuitBreakerReset handles the /circuit-breakers/{backend}/reset endpoint
+// POST closes a backend's circuit breaker and clears its failure count, so traffic
+// returns to a recovered backend without waiting for the open timeout
+func (s *Server) handleCircuitBreakerReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	breakers := s.circuitBreakers()
+	if breakers == nil {
+		http.Error(w, "Circuit breakers not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	name := r.PathValue("backend")
+	cb, ok := breakers[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Backend not found: %s", name), http.StatusNotFound)
+		return
+	}
+
+	previous := cb.GetState()
+	cb.Reset()
+	log.Printf("Circuit breaker for backend %s reset from %s via admin API", name, previous)
+	s.auditChange(r, map[string]string{
+		"backend":        name,
+		"previous_state": previous.String(),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newCircuitBreakerResponse(name, cb))
+}
+
+// handleRateLimitReset handles the /rate-limits/{key} endpoint
+// DELETE clears the rate limit state of a client IP, tenant or API key, so a client that
+// was rate limited by mistake is let through again at once
+func (s *Server) handleRateLimitReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := r.PathValue("key")
+	if s.rateReset == nil || !s.rateReset(key) {
+		http.Error(w, "Rate limiting not available", http.StatusServiceUnavailable)
+		return
+	}
+	log.Printf("Rate limit state of %s reset via admin API", key)
+	s.auditChange(r, map[string]string{"key": key})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BlockRequest is the body accepted by POST /blocklist
+type BlockRequest struct {
+	// Target is the IP address or CIDR range to block
+	Target string `json:"target"`
+
+	// Duration is how long to block an IP address for, e.g. "1h" (permanent if empty);
+	// CIDR ranges are always blocked permanently
+	Duration string `json:"duration,omitempty"`
+}
+
+// handleBlocklist handles the /blocklist endpoint
+// GET lists the blocked IP addresses and CIDR ranges with their expiry and POST blocks
+// an IP address temporarily or permanently, or a CIDR range permanently
+func (s *Server) handleBlocklist(w http.ResponseWriter, r *http.Request) {
+	if s.blocklist == nil {
+		http.Error(w, "Blocklist not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(s.blocklist.List())
+	case http.MethodPost:
+		var req BlockRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Target == "" {
+			http.Error(w, "Invalid request body: expected {\"target\": \"<ip or cidr>\", \"duration\": \"<duration>\"}", http.StatusBadRequest)
+			return
+		}
+
+		var entry security.BlockEntry
+		if strings.Contains(req.Target, "/") {
+			if req.Duration != "" {
+				http.Error(w, "CIDR ranges can only be blocked permanently", http.StatusBadRequest)
+				return
+			}
+			if err := s.blocklist.BlockCIDR(req.Target); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			_, ipNet, _ := net.ParseCIDR(req.Target)
+			entry = security.BlockEntry{Target: ipNet.String(), Permanent: true}
+		} else {
+			ip := net.ParseIP(req.Target)
+			if ip == nil {
+				http.Error(w, fmt.Sprintf("Invalid IP address: %s", req.Target), http.StatusBadRequest)
+				return
+			}
+			entry.Target = ip.String()
+			if req.Duration == "" {
+				s.blocklist.BlockPermanent(entry.Target)
+				entry.Permanent = true
+			} else {
+				duration, err := time.ParseDuration(req.Duration)
+				if err != nil || duration <= 0 {
+					http.Error(w, fmt.Sprintf("Invalid duration: %q (must be a positive duration)", req.Duration), http.StatusBadRequest)
+					return
+				}
+				s.blocklist.Block(entry.Target, duration)
+				expiresAt := time.Now().Add(duration)
+				entry.ExpiresAt = &expiresAt
+			}
+		}
+		log.Printf("Blocked %s via admin API (duration: %s)", entry.Target, durationOrPermanent(req.Duration))
+		s.auditChange(r, map[string]string{
+			"target":   entry.Target,
+			"duration": durationOrPermanent(req.Duration),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(entry)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBlocklistEntry handles the /blocklist/{target} endpoint
+// DELETE removes the block of an IP address or CIDR range
+func (s *Server) handleBlocklistEntry(w http.ResponseWriter, r *http.Request) {
+	if s.blocklist == nil {
+		http.Error(w, "Blocklist not available", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target := r.PathValue("target")
+	var removed bool
+	if strings.Contains(target, "/") {
+		var err error
+		if removed, err = s.blocklist.UnblockCIDR(target); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		if ip := net.ParseIP(target); ip != nil {
+			target = ip.String()
+		}
+		removed = s.blocklist.Unblock(target)
+	}
+	if !removed {
+		http.Error(w, fmt.Sprintf("Not blocked: %s", target), http.StatusNotFound)
+		return
+	}
+	log.Printf("Unblocked %s via admin API", target)
+	s.auditChange(r, map[string]string{"target": target})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// durationOrPermanent describes the duration of a block
+func durationOrPermanent(duration string) string {
+	if duration == "" {
+		return "permanent"
+	}
+	return duration
+}
+
+// CertificateResponse describes a certificate served by the TLS listener
+type CertificateResponse struct {
+	Name              string    `json:"name"`
+	Domains           []string  `json:"domains"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serial_number"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	ExpiresInSeconds  int64     `json:"expires_in_seconds"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+}
+
+// listCertificates describes the certificates of the TLS listener, sorted by name
+func (s *Server) listCertificates() []CertificateResponse {
+	now := time.Now()
+	certs := s.certs.ListCertificates()
+	resp := make([]CertificateResponse, 0, len(certs))
+	for _, cert := range certs {
+		resp = append(resp, CertificateResponse{
+			Name:              cert.Name(),
+			Domains:           cert.Domains,
+			Issuer:            cert.Cert.Issuer.String(),
+			SerialNumber:      cert.Cert.SerialNumber.String(),
+			NotBefore:         cert.NotBefore,
+			NotAfter:          cert.NotAfter,
+			ExpiresInSeconds:  int64(cert.NotAfter.Sub(now).Seconds()),
+			FingerprintSHA256: cert.Fingerprint(),
+		})
+	}
+	sort.Slice(resp, func(i, j int) bool {
+		return resp[i].Name < resp[j].Name
+	})
+	return resp
+}
+
+// handleTLSCertificates handles the /tls/certificates endpoint
+// GET lists the certificates served by the TLS listener with their domains, expiry and
+// fingerprint
+func (s *Server) handleTLSCertificates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.certs == nil {
+		http.Error(w, "TLS termination not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.listCertificates())
+}
+
+// handleTLSReload handles the /tls/reload endpoint
+// POST reads the certificate files again so certificates rotated out of band are served
+// without a restart, and returns the certificates now served
+func (s *Server) handleTLSReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.certs == nil || s.tlsReload == nil {
+		http.Error(w, "TLS termination not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := s.tlsReload(); err != nil {
+		log.Printf("TLS certificate reload via admin API failed: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to reload certificates: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("TLS certificates reloaded via admin API")
+	s.auditChange(r, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.listCertificates())
+}
+
+// CaptureRequest is the body accepted by POST /debug/capture
+type CaptureRequest struct {
+	// Count is how many matching requests to capture
+	Count int `json:"count"`
+
+	// Filter selects the requests to capture (any request if empty)
+	Filter logging.CaptureFilter `json:"filter"`
+}
+
+// handleCapture handles the /debug/capture endpoint
+// POST captures the next requests matching a filter, GET returns the requests captured
+// so far and DELETE stops the capture and discards them
+func (s *Server) handleCapture(w http.ResponseWriter, r *http.Request) {
+	if s.capture == nil {
+		http.Error(w, "Request capture not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req CaptureRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: expected {\"count\": <int>, \"filter\": {...}}", http.StatusBadRequest)
+			return
+		}
+		if err := s.capture.Start(req.Filter, req.Count); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter, _ := json.Marshal(req.Filter)
+		log.Printf("Debug capture of %d requests matching %s started via admin API", req.Count, filter)
+		s.auditChange(r, map[string]string{
+			"count":  strconv.Itoa(req.Count),
+			"filter": string(filter),
+		})
+	case http.MethodDelete:
+		s.capture.Stop()
+		log.Printf("Debug capture stopped via admin API")
+		s.auditChange(r, nil)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.capture.Status())
+}
+
+// auditChange records a change made through the API in the audit log
+func (s *Server) auditChange(r *http.Request, details map[string]string) {
+	if s.audit == nil {
+		return
+	}
+	actor, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		actor = r.RemoteAddr
+	}
+	if name := clientName(r); name != "" {
+		if details == nil {
+			details = make(map[string]string, 1)
+		}
+		details["client"] = name
+	}
+	s.audit.Log(logging.AuditEvent{
+		Type:    logging.AuditAdmin,
+		Actor:   actor,
+		Action:  r.Method + " " + r.URL.Path,
+		Outcome: "applied",
+		Details: details,
+	})
+}
@@ -1,11 +1,21 @@
 package admin
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/health"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/security"
+	balancetls "github.com/therealutkarshpriyadarshi/balance/pkg/tls"
 )
 
 func TestHealthEndpoint(t *testing.T) {
@@ -88,6 +98,32 @@ func TestReadyEndpoint(t *testing.T) {
 	}
 }
 
+func TestReadyEndpointDraining(t *testing.T) {
+	srv := NewServer(Config{
+		Listen:       ":0",
+		HealthFunc:   func() bool { return true },
+		DrainingFunc: func() bool { return true },
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+
+	srv.handleReady(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	var resp HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Status != "draining" {
+		t.Errorf("expected status draining, got %s", resp.Status)
+	}
+}
+
 func TestStatusEndpoint(t *testing.T) {
 	srv := NewServer(Config{
 		Listen: ":0",
@@ -164,6 +200,561 @@ func TestVersionEndpoint(t *testing.T) {
 	}
 }
 
+func TestStatsEndpoint(t *testing.T) {
+	srv := NewServer(Config{
+		Listen: ":0",
+		StatsFunc: func() map[string]interface{} {
+			return map[string]interface{}{
+				"proxy": map[string]interface{}{"total_requests": 42},
+				"pool":  map[string]interface{}{"healthy_backends": 2},
+			}
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	rec := httptest.NewRecorder()
+
+	srv.handleStats(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var resp map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	for _, key := range []string{"proxy", "pool", "uptime_seconds", "timestamp"} {
+		if _, ok := resp[key]; !ok {
+			t.Errorf("expected key %q in stats response", key)
+		}
+	}
+
+	proxy, ok := resp["proxy"].(map[string]interface{})
+	if !ok || proxy["total_requests"] != float64(42) {
+		t.Errorf("expected proxy.total_requests 42, got %v", resp["proxy"])
+	}
+
+	// Non-GET requests are rejected
+	req = httptest.NewRequest(http.MethodPost, "/stats", nil)
+	rec = httptest.NewRecorder()
+
+	srv.handleStats(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestBackendWeightEndpoint(t *testing.T) {
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("backend-1", "127.0.0.1:9001", 1))
+
+	srv := NewServer(Config{
+		Listen: ":0",
+		Pool:   pool,
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedWeight int
+	}{
+		{"get weight", http.MethodGet, "/backends/backend-1/weight", "", http.StatusOK, 1},
+		{"set weight", http.MethodPut, "/backends/backend-1/weight", `{"weight": 5}`, http.StatusOK, 5},
+		{"drain with zero weight", http.MethodPut, "/backends/backend-1/weight", `{"weight": 0}`, http.StatusOK, 0},
+		{"negative weight", http.MethodPut, "/backends/backend-1/weight", `{"weight": -1}`, http.StatusBadRequest, 0},
+		{"missing weight", http.MethodPut, "/backends/backend-1/weight", `{}`, http.StatusBadRequest, 0},
+		{"unknown backend", http.MethodPut, "/backends/missing/weight", `{"weight": 2}`, http.StatusNotFound, 0},
+		{"wrong method", http.MethodDelete, "/backends/backend-1/weight", "", http.StatusMethodNotAllowed, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			srv.server.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var resp WeightResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Weight != tt.expectedWeight {
+				t.Errorf("expected weight %d, got %d", tt.expectedWeight, resp.Weight)
+			}
+		})
+	}
+
+	if w := pool.Get("backend-1").Weight(); w != 0 {
+		t.Errorf("expected backend weight 0, got %d", w)
+	}
+}
+
+func TestBackendWeightEndpointAdaptive(t *testing.T) {
+	pool := backend.NewPool()
+	fast := backend.NewBackend("backend-1", "127.0.0.1:9001", 1)
+	slow := backend.NewBackend("backend-2", "127.0.0.1:9002", 1)
+	pool.Add(fast)
+	pool.Add(slow)
+
+	checker := health.NewChecker(pool, health.CheckerConfig{})
+	checker.RecordRequest(fast, true, 10*time.Millisecond)
+	checker.RecordRequest(slow, true, 40*time.Millisecond)
+
+	aw := health.NewAdaptiveWeights(checker, pool, health.AdaptiveWeightsConfig{Interval: 10 * time.Millisecond})
+	aw.Start()
+	defer aw.Stop()
+
+	for deadline := time.Now().Add(time.Second); slow.WeightFactor() == 1 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if slow.WeightFactor() == 1 {
+		t.Fatal("expected the slow backend to be scaled down")
+	}
+
+	srv := NewServer(Config{
+		Listen: ":0",
+		Pool:   pool,
+	})
+
+	getWeight := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/backends/backend-2/weight", nil)
+		rec := httptest.NewRecorder()
+		srv.server.Handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp WeightResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.Weight
+	}
+
+	// The endpoint reports the configured weight, not the scaled one
+	if w := getWeight(); w != 1 {
+		t.Errorf("expected weight 1, got %d", w)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/backends/backend-2/weight", strings.NewReader(`{"weight": 5}`))
+	rec := httptest.NewRecorder()
+	srv.server.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	// Later adjustments keep the weight set through the endpoint
+	time.Sleep(50 * time.Millisecond)
+	if w := getWeight(); w != 5 {
+		t.Errorf("expected weight 5 to be kept, got %d", w)
+	}
+	if w, f := slow.EffectiveWeight(), slow.WeightFactor(); w != 5*f {
+		t.Errorf("expected effective weight %v, got %v", 5*f, w)
+	}
+}
+
+func TestBackendsEndpoint(t *testing.T) {
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("backend-1", "127.0.0.1:9001", 1))
+
+	srv := NewServer(Config{
+		Listen: ":0",
+		Pool:   pool,
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+	}{
+		{"add backend", http.MethodPost, `{"name": "backend-2", "address": "127.0.0.1:9002", "weight": 3}`, http.StatusCreated},
+		{"duplicate name", http.MethodPost, `{"name": "backend-1", "address": "127.0.0.1:9003"}`, http.StatusConflict},
+		{"duplicate address", http.MethodPost, `{"name": "backend-3", "address": "127.0.0.1:9001"}`, http.StatusConflict},
+		{"missing port", http.MethodPost, `{"name": "backend-3", "address": "127.0.0.1"}`, http.StatusBadRequest},
+		{"invalid port", http.MethodPost, `{"name": "backend-3", "address": "127.0.0.1:70000"}`, http.StatusBadRequest},
+		{"missing name", http.MethodPost, `{"address": "127.0.0.1:9003"}`, http.StatusBadRequest},
+		{"negative weight", http.MethodPost, `{"name": "backend-3", "address": "127.0.0.1:9003", "weight": -1}`, http.StatusBadRequest},
+		{"wrong method", http.MethodDelete, "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/backends", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			srv.server.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	if pool.Size() != 2 {
+		t.Fatalf("expected 2 backends, got %d", pool.Size())
+	}
+	if b := pool.Get("backend-2"); b == nil || b.Weight() != 3 {
+		t.Errorf("expected backend-2 with weight 3, got %v", b)
+	}
+}
+
+func TestBackendStateEndpoint(t *testing.T) {
+	pool := backend.NewPool()
+	b1 := backend.NewBackend("backend-1", "127.0.0.1:9001", 1)
+	b2 := backend.NewBackend("backend-2", "127.0.0.1:9002", 1)
+	pool.Add(b1)
+	pool.Add(b2)
+
+	// Only backend-1 is health checked
+	sm := backend.NewStateMachine(b1, 2, 3)
+	srv := NewServer(Config{
+		Listen: ":0",
+		Pool:   pool,
+		StateMachineFunc: func(name string) *backend.StateMachine {
+			if name == "backend-1" {
+				return sm
+			}
+			return nil
+		},
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedState  string
+	}{
+		{"get state", http.MethodGet, "/backends/backend-1/state", "", http.StatusOK, "healthy"},
+		{"force unhealthy", http.MethodPost, "/backends/backend-1/state", `{"state": "unhealthy"}`, http.StatusOK, "unhealthy"},
+		{"force healthy", http.MethodPost, "/backends/backend-1/state", `{"state": "healthy"}`, http.StatusOK, "healthy"},
+		{"start draining", http.MethodPost, "/backends/backend-1/state", `{"state": "draining"}`, http.StatusOK, "draining"},
+		{"invalid state", http.MethodPost, "/backends/backend-1/state", `{"state": "sleepy"}`, http.StatusBadRequest, ""},
+		{"unchecked backend unhealthy", http.MethodPost, "/backends/backend-2/state", `{"state": "unhealthy"}`, http.StatusOK, "unhealthy"},
+		{"unchecked backend draining", http.MethodPost, "/backends/backend-2/state", `{"state": "draining"}`, http.StatusConflict, ""},
+		{"unknown backend", http.MethodPost, "/backends/missing/state", `{"state": "healthy"}`, http.StatusNotFound, ""},
+		{"wrong method", http.MethodDelete, "/backends/backend-1/state", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			srv.server.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var resp StateResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.State != tt.expectedState {
+				t.Errorf("expected state %s, got %s", tt.expectedState, resp.State)
+			}
+		})
+	}
+
+	if b1.IsHealthy() || b2.IsHealthy() {
+		t.Error("expected both backends to be out of rotation")
+	}
+}
+
+func TestAuditLogMutations(t *testing.T) {
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("backend-1", "127.0.0.1:9001", 1))
+
+	var out bytes.Buffer
+	srv := NewServer(Config{
+		Listen:   ":0",
+		Pool:     pool,
+		AuditLog: logging.NewAuditLogger(&out),
+	})
+
+	// Reads and rejected changes are not audited
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/backends/backend-1/weight", nil),
+		httptest.NewRequest(http.MethodPut, "/backends/backend-1/weight", strings.NewReader(`{"weight": -1}`)),
+		httptest.NewRequest(http.MethodPut, "/backends/backend-1/weight", strings.NewReader(`{"weight": 3}`)),
+	} {
+		req.RemoteAddr = "198.51.100.7:40000"
+		srv.server.Handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 audit event, got %d: %q", len(lines), out.String())
+	}
+	var event logging.AuditEvent
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatalf("failed to decode audit event: %v", err)
+	}
+	if event.Type != logging.AuditAdmin || event.Actor != "198.51.100.7" || event.Action != "PUT /backends/backend-1/weight" {
+		t.Errorf("unexpected audit event: %+v", event)
+	}
+	if event.Details["old_weight"] != "1" || event.Details["weight"] != "3" || event.Time.IsZero() {
+		t.Errorf("unexpected audit event details: %+v", event)
+	}
+}
+
+func TestCaptureEndpoint(t *testing.T) {
+	if rec := serveAdmin(NewServer(Config{Listen: ":0"}), http.MethodGet, "/debug/capture", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a capture, got %d", rec.Code)
+	}
+
+	capture := logging.NewRequestCapture()
+	srv := NewServer(Config{Listen: ":0", Capture: capture})
+
+	if rec := serveAdmin(srv, http.MethodPost, "/debug/capture", `{"count": 0}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a zero count, got %d", rec.Code)
+	}
+	if rec := serveAdmin(srv, http.MethodPost, "/debug/capture", `{"count": 5, "filter": {"path_prefix": "/api"}}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 starting a capture, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The proxy records a matching request
+	cr := capture.Begin(httptest.NewRequest(http.MethodGet, "/api/users", nil), "192.0.2.1")
+	if cr == nil {
+		t.Fatal("expected the request to be captured")
+	}
+	cr.Finish(http.StatusOK, http.Header{}, time.Millisecond)
+
+	rec := serveAdmin(srv, http.MethodGet, "/debug/capture", "")
+	var status logging.CaptureStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !status.Active || status.Remaining != 4 || len(status.Requests) != 1 || status.Requests[0].URL != "/api/users" {
+		t.Errorf("unexpected capture status: %+v", status)
+	}
+
+	if rec := serveAdmin(srv, http.MethodDelete, "/debug/capture", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 stopping the capture, got %d", rec.Code)
+	}
+	if status := capture.Status(); status.Active || len(status.Requests) != 0 {
+		t.Errorf("expected a stopped, empty capture, got %+v", status)
+	}
+}
+
+func TestCircuitBreakersEndpoint(t *testing.T) {
+	if rec := serveAdmin(NewServer(Config{Listen: ":0"}), http.MethodGet, "/circuit-breakers", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without circuit breakers, got %d", rec.Code)
+	}
+
+	web1 := resilience.NewCircuitBreaker(resilience.CircuitBreakerConfig{Name: "web-1", MaxFailures: 2, Timeout: time.Hour})
+	web2 := resilience.NewCircuitBreaker(resilience.CircuitBreakerConfig{Name: "web-2", MaxFailures: 2, Timeout: time.Hour})
+	for i := 0; i < 2; i++ {
+		web1.Execute(func() error { return errors.New("connection refused") })
+	}
+	srv := NewServer(Config{
+		Listen: ":0",
+		CircuitBreakersFunc: func() map[string]*resilience.CircuitBreaker {
+			return map[string]*resilience.CircuitBreaker{"web-2": web2, "web-1": web1}
+		},
+	})
+
+	rec := serveAdmin(srv, http.MethodGet, "/circuit-breakers", "")
+	var breakers []CircuitBreakerResponse
+	if err := json.NewDecoder(rec.Body).Decode(&breakers); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(breakers) != 2 || breakers[0].Backend != "web-1" || breakers[1].Backend != "web-2" {
+		t.Fatalf("expected web-1 and web-2 in order, got %+v", breakers)
+	}
+	if breakers[0].State != "open" || breakers[0].TotalFailures != 2 || breakers[0].ConsecutiveFailures != 2 {
+		t.Errorf("expected web-1 to be open after 2 failures, got %+v", breakers[0])
+	}
+	if breakers[1].State != "closed" {
+		t.Errorf("expected web-2 to be closed, got %s", breakers[1].State)
+	}
+
+	if rec := serveAdmin(srv, http.MethodPost, "/circuit-breakers/web-9/reset", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown backend, got %d", rec.Code)
+	}
+	if rec := serveAdmin(srv, http.MethodGet, "/circuit-breakers/web-1/reset", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rec.Code)
+	}
+
+	rec = serveAdmin(srv, http.MethodPost, "/circuit-breakers/web-1/reset", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 resetting the breaker, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var reset CircuitBreakerResponse
+	if err := json.NewDecoder(rec.Body).Decode(&reset); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if reset.State != "closed" || reset.ConsecutiveFailures != 0 {
+		t.Errorf("expected a closed breaker with no failures, got %+v", reset)
+	}
+	if web1.GetState() != resilience.StateClosed {
+		t.Errorf("expected web-1 to accept requests again, got %s", web1.GetState())
+	}
+}
+
+func TestRateLimitResetEndpoint(t *testing.T) {
+	if rec := serveAdmin(NewServer(Config{Listen: ":0"}), http.MethodDelete, "/rate-limits/tenant-a", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without rate limiting, got %d", rec.Code)
+	}
+
+	var reset []string
+	srv := NewServer(Config{
+		Listen: ":0",
+		RateLimitResetFunc: func(key string) bool {
+			reset = append(reset, key)
+			return true
+		},
+	})
+
+	if rec := serveAdmin(srv, http.MethodGet, "/rate-limits/tenant-a", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rec.Code)
+	}
+	if rec := serveAdmin(srv, http.MethodDelete, "/rate-limits/tenant-a", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 resetting a key, got %d", rec.Code)
+	}
+	// Keys may contain slashes and escaped characters
+	if rec := serveAdmin(srv, http.MethodDelete, "/rate-limits/team%20a/key", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 resetting a key, got %d", rec.Code)
+	}
+	if len(reset) != 2 || reset[0] != "tenant-a" || reset[1] != "team a/key" {
+		t.Errorf("expected tenant-a and team a/key to be reset, got %q", reset)
+	}
+}
+
+func TestBlocklistEndpoints(t *testing.T) {
+	if rec := serveAdmin(NewServer(Config{Listen: ":0"}), http.MethodGet, "/blocklist", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a blocklist, got %d", rec.Code)
+	}
+
+	bl := security.NewIPBlocklist()
+	srv := NewServer(Config{Listen: ":0", Blocklist: bl})
+
+	for _, body := range []string{
+		`{"target": "not-an-ip"}`,
+		`{"target": "203.0.113.7", "duration": "forever"}`,
+		`{"target": "198.51.100.0/24", "duration": "1h"}`,
+		`{"target": "198.51.100.0/33"}`,
+	} {
+		if rec := serveAdmin(srv, http.MethodPost, "/blocklist", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, rec.Code)
+		}
+	}
+
+	for _, body := range []string{
+		`{"target": "203.0.113.7", "duration": "1h"}`,
+		`{"target": "203.0.113.8"}`,
+		`{"target": "198.51.100.0/24"}`,
+	} {
+		if rec := serveAdmin(srv, http.MethodPost, "/blocklist", body); rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201 for %s, got %d: %s", body, rec.Code, rec.Body.String())
+		}
+	}
+	if !bl.IsBlocked("203.0.113.7") || !bl.IsBlocked("203.0.113.8") || !bl.IsBlocked("198.51.100.9") {
+		t.Fatal("expected the blocks to apply")
+	}
+
+	rec := serveAdmin(srv, http.MethodGet, "/blocklist", "")
+	var entries []security.BlockEntry
+	if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Target != "198.51.100.0/24" || entries[1].ExpiresAt == nil || !entries[2].Permanent {
+		t.Errorf("unexpected blocklist: %+v", entries)
+	}
+
+	if rec := serveAdmin(srv, http.MethodDelete, "/blocklist/203.0.113.9", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 unblocking an IP that is not blocked, got %d", rec.Code)
+	}
+	if rec := serveAdmin(srv, http.MethodDelete, "/blocklist/203.0.113.7", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 unblocking an IP, got %d", rec.Code)
+	}
+	if rec := serveAdmin(srv, http.MethodDelete, "/blocklist/198.51.100.0/24", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 unblocking a CIDR range, got %d", rec.Code)
+	}
+	if bl.IsBlocked("203.0.113.7") || bl.IsBlocked("198.51.100.9") || len(bl.List()) != 1 {
+		t.Errorf("expected only 203.0.113.8 to stay blocked, got %+v", bl.List())
+	}
+}
+
+func TestTLSEndpoints(t *testing.T) {
+	if rec := serveAdmin(NewServer(Config{Listen: ":0"}), http.MethodGet, "/tls/certificates", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without TLS termination, got %d", rec.Code)
+	}
+
+	certs := balancetls.NewCertificateManager(nil)
+	first, _ := balancetls.GenerateSelfSignedCertificate([]string{"www.example.com", "example.com"})
+	certs.AddCertificate(first)
+	reloaded := 0
+	srv := NewServer(Config{
+		Listen:       ":0",
+		Certificates: certs,
+		TLSReloadFunc: func() error {
+			reloaded++
+			if reloaded > 1 {
+				return errors.New("TLS certificate /etc/balance/cert.pem: no such file")
+			}
+			rotated, _ := balancetls.GenerateSelfSignedCertificate([]string{"www.example.com", "example.com"})
+			return certs.ReplaceCertificate(first, rotated)
+		},
+	})
+
+	rec := serveAdmin(srv, http.MethodGet, "/tls/certificates", "")
+	var listed []CertificateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Name != "www.example.com" || len(listed[0].Domains) != 2 {
+		t.Fatalf("unexpected certificates: %+v", listed)
+	}
+	if listed[0].FingerprintSHA256 != first.Fingerprint() || listed[0].ExpiresInSeconds <= 0 || !listed[0].NotAfter.Equal(first.NotAfter) {
+		t.Errorf("unexpected certificate details: %+v", listed[0])
+	}
+
+	if rec := serveAdmin(srv, http.MethodGet, "/tls/reload", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rec.Code)
+	}
+	rec = serveAdmin(srv, http.MethodPost, "/tls/reload", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 reloading, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(listed) != 1 || listed[0].FingerprintSHA256 == first.Fingerprint() {
+		t.Errorf("expected the rotated certificate, got %+v", listed)
+	}
+
+	rec = serveAdmin(srv, http.MethodPost, "/tls/reload", "")
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "no such file") {
+		t.Errorf("expected 500 with the reload error, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// serveAdmin sends a request to the admin server's handler
+func serveAdmin(srv *Server, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	srv.server.Handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
 func TestServerStartStop(t *testing.T) {
 	srv := NewServer(Config{
 		Listen: "127.0.0.1:0", // Use random port
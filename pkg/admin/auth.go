@@ -0,0 +1,234 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+// Role is what an authenticated admin API client may do
+type Role string
+
+const (
+	// RoleReadOnly clients may only use endpoints that change nothing, e.g. dashboards
+	// reading stats and metrics
+	RoleReadOnly Role = "read-only"
+
+	// RoleOperator clients may also change the proxy, e.g. drain backends
+	RoleOperator Role = "operator"
+)
+
+// publicPaths are served without authentication so health probes keep working
+var publicPaths = map[string]bool{
+	"/health":  true,
+	"/healthz": true,
+	"/ready":   true,
+	"/readyz":  true,
+}
+
+// dryRunPaths change nothing although they are not GET requests, so read-only clients
+// may use them
+var dryRunPaths = map[string]bool{
+	"/config/validate": true,
+}
+
+// Auth authenticates admin API clients by bearer token or client certificate
+type Auth struct {
+	tokens  []authToken
+	clients map[string]Role
+}
+
+// clientKey is the context key of an authenticated client
+type clientKey struct{}
+
+// authClient is the name and role of an authenticated client
+type authClient struct {
+	name string
+	role Role
+}
+
+// withClient returns a context carrying the name and role of the authenticated client
+func withClient(ctx context.Context, name string, role Role) context.Context {
+	return context.WithValue(ctx, clientKey{}, authClient{name: name, role: role})
+}
+
+// clientName returns the name of the client that made a request: the token name or
+// certificate common name it authenticated with ("" if it did not authenticate)
+func clientName(r *http.Request) string {
+	if client, ok := r.Context().Value(clientKey{}).(authClient); ok {
+		return client.name
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	return ""
+}
+
+// authToken is a bearer token accepted by the admin API
+type authToken struct {
+	name  string
+	token []byte
+	role  Role
+}
+
+// NewAuth creates the authentication of the admin API, reading token files
+func NewAuth(cfg *config.AdminAuthConfig) (*Auth, error) {
+	a := &Auth{clients: make(map[string]Role, len(cfg.Clients))}
+	for _, t := range cfg.Tokens {
+		token := t.Token
+		if t.TokenFile != "" {
+			data, err := os.ReadFile(t.TokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read admin token %s: %w", t.Name, err)
+			}
+			token = strings.TrimSpace(string(data))
+		}
+		if token == "" {
+			return nil, fmt.Errorf("admin token %s is empty", t.Name)
+		}
+		a.tokens = append(a.tokens, authToken{name: t.Name, token: []byte(token), role: Role(t.Role)})
+	}
+	for _, c := range cfg.Clients {
+		a.clients[c.CommonName] = Role(c.Role)
+	}
+	return a, nil
+}
+
+// NewTLSConfig creates the TLS configuration of the admin API
+// With a client CA, client certificates are verified; they are required unless auth
+// is configured, in which case clients may authenticate with a token instead.
+func NewTLSConfig(cfg *config.AdminTLSConfig, auth *Auth) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in admin client CA file %s", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if auth == nil {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// authenticate returns the name and role of the client making a request, reporting
+// false if it presented no valid credentials
+func (a *Auth) authenticate(r *http.Request) (string, Role, bool) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if role, ok := a.clients[name]; ok {
+			return name, role, true
+		}
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", "", false
+	}
+	// Every token is compared so the time taken does not reveal which one matched
+	var match *authToken
+	for i := range a.tokens {
+		if subtle.ConstantTimeCompare(a.tokens[i].token, []byte(token)) == 1 {
+			match = &a.tokens[i]
+		}
+	}
+	if match == nil {
+		return "", "", false
+	}
+	return match.name, match.role, true
+}
+
+// allows reports whether a role may make a request
+func (role Role) allows(r *http.Request) bool {
+	return role == RoleOperator || !changes(r)
+}
+
+// changes reports whether a request may change the proxy: any request other than a
+// GET or HEAD, unless its endpoint is a dry run
+func changes(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return false
+	}
+	return !dryRunPaths[r.URL.Path]
+}
+
+// authorize wraps the admin API's handler, rejecting requests from clients that are
+// not authenticated (401) or whose role does not allow them (403)
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name, role, ok := s.auth.authenticate(r)
+		if !ok {
+			s.auditAuthFailure(r, "", "missing or invalid credentials")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="balance"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !role.allows(r) {
+			s.auditAuthFailure(r, name, fmt.Sprintf("role %s does not allow changes", role))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withClient(r.Context(), name, role)))
+	})
+}
+
+// clientRole returns the role of the client that made a request; without
+// authentication every client is an operator
+func clientRole(r *http.Request) Role {
+	if client, ok := r.Context().Value(clientKey{}).(authClient); ok {
+		return client.role
+	}
+	return RoleOperator
+}
+
+// auditAuthFailure records a rejected admin API request
+func (s *Server) auditAuthFailure(r *http.Request, name, reason string) {
+	if s.audit == nil {
+		return
+	}
+	actor, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		actor = r.RemoteAddr
+	}
+	var details map[string]string
+	if name != "" {
+		details = map[string]string{"client": name}
+	}
+	s.audit.Log(logging.AuditEvent{
+		Type:    logging.AuditAuthFailure,
+		Actor:   actor,
+		Action:  r.Method + " " + r.URL.Path,
+		Outcome: "rejected",
+		Reason:  reason,
+		Details: details,
+	})
+}
@@ -0,0 +1,161 @@
+package admin
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	balancetls "github.com/therealutkarshpriyadarshi/balance/pkg/tls"
+)
+
+// serveAuthenticated serves a request with a bearer token
+func serveAuthenticated(srv *Server, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(`{"weight": 3}`))
+	req.RemoteAddr = "198.51.100.7:40000"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	srv.server.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthTokenRoles(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("operator-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := NewAuth(&config.AdminAuthConfig{
+		Tokens: []config.AdminToken{
+			{Name: "grafana", Token: "dashboard-secret", Role: "read-only"},
+			{Name: "deploy", TokenFile: tokenFile, Role: "operator"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %v", err)
+	}
+
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("web-1", "10.0.0.1:8080", 1))
+	var out bytes.Buffer
+	srv := NewServer(Config{Listen: ":0", Pool: pool, Auth: auth, AuditLog: logging.NewAuditLogger(&out)})
+
+	// Probes need no credentials
+	if rec := serveAuthenticated(srv, http.MethodGet, "/healthz", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for an unauthenticated probe, got %d", rec.Code)
+	}
+
+	rec := serveAuthenticated(srv, http.MethodGet, "/backends/web-1/weight", "")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected 401 with a challenge without a token, got %d", rec.Code)
+	}
+	if rec := serveAuthenticated(srv, http.MethodGet, "/metrics", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unknown token, got %d", rec.Code)
+	}
+
+	if rec := serveAuthenticated(srv, http.MethodGet, "/backends/web-1/weight", "dashboard-secret"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 reading with a read-only token, got %d", rec.Code)
+	}
+	if rec := serveAuthenticated(srv, http.MethodPut, "/backends/web-1/weight", "dashboard-secret"); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 changing with a read-only token, got %d", rec.Code)
+	}
+	if pool.Get("web-1").Weight() != 1 {
+		t.Fatal("expected the weight to be unchanged")
+	}
+
+	if rec := serveAuthenticated(srv, http.MethodPut, "/backends/web-1/weight", "operator-secret"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 changing with an operator token, got %d", rec.Code)
+	}
+	if pool.Get("web-1").Weight() != 3 {
+		t.Error("expected the weight to be changed")
+	}
+
+	var events []logging.AuditEvent
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event logging.AuditEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("failed to decode audit event: %v", err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 audit events, got %d: %q", len(events), out.String())
+	}
+	if events[2].Type != logging.AuditAuthFailure || events[2].Details["client"] != "grafana" {
+		t.Errorf("expected the forbidden change to be audited, got %+v", events[2])
+	}
+	if events[3].Type != logging.AuditAdmin || events[3].Details["client"] != "deploy" {
+		t.Errorf("expected the change to be audited with the client name, got %+v", events[3])
+	}
+}
+
+func TestAuthClientCertificate(t *testing.T) {
+	auth, err := NewAuth(&config.AdminAuthConfig{
+		Clients: []config.AdminClient{{CommonName: "ops", Role: "operator"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %v", err)
+	}
+	srv := NewServer(Config{Listen: ":0", Auth: auth})
+
+	serve := func(commonName string) int {
+		req := httptest.NewRequest(http.MethodPost, "/tls/reload", nil)
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+		rec := httptest.NewRecorder()
+		srv.server.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// The TLS endpoints are not available, but the request gets through
+	if code := serve("ops"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected an operator certificate to be allowed, got %d", code)
+	}
+	if code := serve("someone-else"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unknown certificate, got %d", code)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	cert, err := balancetls.GenerateSelfSignedCertificate([]string{"admin.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := balancetls.SaveCertificateToPEM(cert, certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfig, err := NewTLSConfig(&config.AdminTLSConfig{CertFile: certFile, KeyFile: keyFile}, nil)
+	if err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+	if len(tlsConfig.Certificates) != 1 || tlsConfig.ClientAuth != tls.NoClientCert {
+		t.Errorf("expected HTTPS without client certificates, got %v", tlsConfig.ClientAuth)
+	}
+
+	// Client certificates are required unless clients can use tokens instead
+	mtls := &config.AdminTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}
+	if tlsConfig, err = NewTLSConfig(mtls, nil); err != nil || tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("expected client certificates to be required, got %v (%v)", tlsConfig, err)
+	}
+	if tlsConfig, err = NewTLSConfig(mtls, &Auth{}); err != nil || tlsConfig.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("expected client certificates to be optional with auth, got %v (%v)", tlsConfig, err)
+	}
+
+	mtls.ClientCAFile = keyFile
+	if _, err := NewTLSConfig(mtls, nil); err == nil {
+		t.Error("expected an error for a client CA file without certificates")
+	}
+}
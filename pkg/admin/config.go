@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+// maxConfigSize is the largest configuration accepted by /config/validate and /config/apply
+const maxConfigSize = 10 << 20
+
+// ConfigDiffResponse reports how a submitted configuration differs from the running one
+type ConfigDiffResponse struct {
+	// Changes are the settings that differ, sorted by path
+	Changes []config.Change `json:"changes"`
+
+	// RestartRequired are the paths of changes that cannot be applied at runtime
+	RestartRequired []string `json:"restart_required"`
+
+	// Applied is true once the configuration is running
+	Applied bool `json:"applied"`
+}
+
+// handleConfigValidate handles the /config/validate endpoint
+// POST validates a full configuration (YAML or JSON) and returns its diff against the
+// running configuration without applying it. Read-only clients are not shown changes
+// to credentials.
+func (s *Server) handleConfigValidate(w http.ResponseWriter, r *http.Request) {
+	s.handleConfig(w, r, false)
+}
+
+// handleConfigApply handles the /config/apply endpoint
+// POST validates a full configuration and applies it if every change can be applied at
+// runtime; otherwise nothing is applied and the diff is returned with 409
+func (s *Server) handleConfigApply(w http.ResponseWriter, r *http.Request) {
+	s.handleConfig(w, r, true)
+}
+
+// handleConfig validates a submitted configuration, diffs it against the running one
+// and optionally applies it
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request, apply bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.configFunc == nil || (apply && s.configApply == nil) {
+		http.Error(w, "Configuration management not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read configuration: %v", err), http.StatusBadRequest)
+		return
+	}
+	cfg, err := config.Parse(data, s.configPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid configuration: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	changes, err := config.Diff(s.configFunc(), cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if clientRole(r) != RoleOperator {
+		// Read-only clients could otherwise test guesses of credentials against the
+		// running configuration
+		changes = slices.DeleteFunc(changes, config.Change.Redacted)
+	}
+	resp := ConfigDiffResponse{Changes: changes, RestartRequired: []string{}}
+	if resp.Changes == nil {
+		resp.Changes = []config.Change{}
+	}
+	for _, change := range changes {
+		if !change.Reloadable() {
+			resp.RestartRequired = append(resp.RestartRequired, change.Path)
+		}
+	}
+
+	status := http.StatusOK
+	switch {
+	case !apply:
+	case len(resp.RestartRequired) > 0:
+		status = http.StatusConflict
+	default:
+		if err := s.configApply(cfg); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to apply configuration: %v", err), http.StatusConflict)
+			return
+		}
+		resp.Applied = true
+		log.Printf("Configuration with %d changes applied via admin API", len(changes))
+		s.auditChange(r, map[string]string{"changes": strconv.Itoa(len(changes))})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
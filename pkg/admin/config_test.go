@@ -0,0 +1,191 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+const testConfig = `
+mode: http
+listen: ":8080"
+backends:
+  - name: web-1
+    address: "10.0.0.1:8080"
+    weight: 1
+  - name: web-2
+    address: "10.0.0.2:8080"
+    weight: 1
+`
+
+// parseTestConfig parses and validates a configuration for the tests
+func parseTestConfig(t *testing.T, data string) *config.Config {
+	t.Helper()
+	cfg, err := config.Parse([]byte(data), "config.yaml")
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	return cfg
+}
+
+func TestConfigValidateEndpoint(t *testing.T) {
+	if rec := serveAdmin(NewServer(Config{Listen: ":0"}), http.MethodPost, "/config/validate", testConfig); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a running config, got %d", rec.Code)
+	}
+
+	running := parseTestConfig(t, testConfig)
+	srv := NewServer(Config{Listen: ":0", ConfigFunc: func() *config.Config { return running }})
+
+	if rec := serveAdmin(srv, http.MethodPost, "/config/validate", "mode: [http"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid YAML, got %d", rec.Code)
+	}
+	if rec := serveAdmin(srv, http.MethodPost, "/config/validate", "mode: udp\nbackends: []\n"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Invalid configuration") {
+		t.Errorf("expected 400 for an invalid config, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	submitted := strings.Replace(testConfig, `":8080"`, `":9000"`, 1)
+	submitted = strings.Replace(submitted, "weight: 1\n  - name: web-2", "weight: 5\n  - name: web-2", 1)
+	rec := serveAdmin(srv, http.MethodPost, "/config/validate", submitted)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var diff ConfigDiffResponse
+	if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(diff.Changes) != 2 || diff.Changes[0].Path != "backends[web-1].weight" || diff.Changes[1].Path != "listen" {
+		t.Fatalf("unexpected changes: %+v", diff.Changes)
+	}
+	if diff.Changes[0].Old != float64(1) || diff.Changes[0].New != float64(5) {
+		t.Errorf("expected the weight to change from 1 to 5, got %+v", diff.Changes[0])
+	}
+	if len(diff.RestartRequired) != 1 || diff.RestartRequired[0] != "listen" || diff.Applied {
+		t.Errorf("expected listen to require a restart, got %+v", diff)
+	}
+}
+
+func TestConfigValidateReadOnly(t *testing.T) {
+	auth, err := NewAuth(&config.AdminAuthConfig{
+		Tokens: []config.AdminToken{{Name: "ci", Token: "ci-secret", Role: "read-only"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %v", err)
+	}
+	running := parseTestConfig(t, testConfig)
+	srv := NewServer(Config{
+		Listen:          ":0",
+		Auth:            auth,
+		ConfigFunc:      func() *config.Config { return running },
+		ConfigApplyFunc: func(cfg *config.Config) error { return nil },
+	})
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{"/config/validate", http.StatusOK},
+		{"/config/apply", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(testConfig))
+		req.Header.Set("Authorization", "Bearer ci-secret")
+		rec := httptest.NewRecorder()
+		srv.server.Handler.ServeHTTP(rec, req)
+		if rec.Code != tt.expectedStatus {
+			t.Errorf("%s: expected %d with a read-only token, got %d", tt.path, tt.expectedStatus, rec.Code)
+		}
+	}
+}
+
+func TestConfigValidateHidesCredentials(t *testing.T) {
+	withToken := func(token string) string {
+		return testConfig + "admin:\n  auth:\n    tokens:\n      - name: ops\n        token: " + token + "\n        role: operator\n"
+	}
+	auth, err := NewAuth(&config.AdminAuthConfig{
+		Tokens: []config.AdminToken{
+			{Name: "dashboard", Token: "dashboard-secret", Role: "read-only"},
+			{Name: "ops", Token: "ops-secret", Role: "operator"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %v", err)
+	}
+	running := parseTestConfig(t, withToken("ops-secret"))
+	srv := NewServer(Config{
+		Listen:     ":0",
+		Auth:       auth,
+		ConfigFunc: func() *config.Config { return running },
+	})
+
+	validate := func(bearer, submitted string) ConfigDiffResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/config/validate", strings.NewReader(submitted))
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		srv.server.Handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var diff ConfigDiffResponse
+		if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return diff
+	}
+
+	// A read-only client cannot tell a wrong guess of a token from the right one
+	for _, guess := range []string{"wrong", "ops-secret"} {
+		if diff := validate("dashboard-secret", withToken(guess)); len(diff.Changes) != 0 || len(diff.RestartRequired) != 0 {
+			t.Errorf("expected no changes for a read-only client guessing %q, got %+v", guess, diff)
+		}
+	}
+
+	// Operators see that the token changed, without its value
+	diff := validate("ops-secret", withToken("wrong"))
+	if len(diff.Changes) != 1 || diff.Changes[0].Path != "admin.auth.tokens[ops].token" || diff.Changes[0].New != "[redacted]" {
+		t.Errorf("expected a redacted token change for an operator, got %+v", diff.Changes)
+	}
+}
+
+func TestConfigApplyEndpoint(t *testing.T) {
+	running := parseTestConfig(t, testConfig)
+	var applied *config.Config
+	srv := NewServer(Config{
+		Listen:     ":0",
+		ConfigFunc: func() *config.Config { return running },
+		ConfigApplyFunc: func(cfg *config.Config) error {
+			applied = cfg
+			return nil
+		},
+	})
+
+	// Nothing is applied if any change needs a restart
+	rec := serveAdmin(srv, http.MethodPost, "/config/apply", strings.Replace(testConfig, "mode: http", "mode: tcp", 1))
+	if rec.Code != http.StatusConflict || applied != nil {
+		t.Fatalf("expected 409 without applying, got %d", rec.Code)
+	}
+
+	// A backend is added and one is removed
+	submitted := strings.Replace(testConfig, "web-2", "web-3", 1)
+	rec = serveAdmin(srv, http.MethodPost, "/config/apply", submitted)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 applying, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var diff ConfigDiffResponse
+	if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !diff.Applied || len(diff.Changes) != 2 || diff.Changes[0].New != nil || diff.Changes[1].Old != nil {
+		t.Errorf("expected web-2 to be removed and web-3 added, got %+v", diff)
+	}
+	if applied == nil || len(applied.Backends) != 2 || applied.Backends[1].Name != "web-3" {
+		t.Errorf("expected the submitted config to be applied, got %+v", applied)
+	}
+}
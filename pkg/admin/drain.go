@@ -0,0 +1,245 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+// drainPollInterval is how often a drain waiting to remove its backend checks the
+// backend's active connections
+const drainPollInterval = 100 * time.Millisecond
+
+// DrainRequest is the body accepted by POST /backends/{name}/drain
+type DrainRequest struct {
+	// Remove removes the backend from the pool once its active connections finish
+	Remove bool `json:"remove"`
+
+	// Timeout is how long to wait for active connections before removing the backend
+	// anyway, e.g. "30s" (wait indefinitely if empty); only used with Remove
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// DrainResponse reports the progress of draining a backend
+// State is "draining" while connections remain, "drained" once they have finished
+// and "removed" once the backend has been removed from the pool.
+type DrainResponse struct {
+	Backend           string    `json:"backend"`
+	State             string    `json:"state"`
+	ActiveConnections int64     `json:"active_connections"`
+	Remove            bool      `json:"remove"`
+	StartedAt         time.Time `json:"started_at"`
+}
+
+// drainOperation is a backend being drained through the API
+type drainOperation struct {
+	backend *backend.Backend
+	remove  bool
+	timeout time.Duration
+	started time.Time
+
+	// cancel is closed when the backend is undrained or drained again
+	cancel chan struct{}
+
+	// removed is set once the backend has been removed from the pool, under drainMu
+	removed bool
+}
+
+// progress reports how far the drain has got
+func (op *drainOperation) progress(name string, removed bool) DrainResponse {
+	resp := DrainResponse{
+		Backend:           name,
+		State:             "draining",
+		ActiveConnections: op.backend.ActiveConnections(),
+		Remove:            op.remove,
+		StartedAt:         op.started,
+	}
+	switch {
+	case removed:
+		resp.State = "removed"
+	case resp.ActiveConnections <= 0:
+		resp.State = "drained"
+	}
+	return resp
+}
+
+// handleBackendDrain handles the /backends/{name}/drain endpoint
+// POST takes a backend out of rotation while its active connections finish, optionally
+// removing it from the pool once they have; GET reports the drain's progress
+func (s *Server) handleBackendDrain(w http.ResponseWriter, r *http.Request) {
+	if s.pool == nil {
+		http.Error(w, "Backend pool not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	name := r.PathValue("name")
+	switch r.Method {
+	case http.MethodGet:
+		s.drainMu.Lock()
+		op := s.drains[name]
+		removed := op != nil && op.removed
+		s.drainMu.Unlock()
+		if op == nil {
+			http.Error(w, fmt.Sprintf("Backend not draining: %s", name), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(op.progress(name, removed))
+	case http.MethodPost:
+		b := s.pool.Get(name)
+		if b == nil {
+			http.Error(w, fmt.Sprintf("Backend not found: %s", name), http.StatusNotFound)
+			return
+		}
+
+		var req DrainRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid request body: expected {\"remove\": <bool>, \"timeout\": \"<duration>\"}", http.StatusBadRequest)
+			return
+		}
+		var timeout time.Duration
+		if req.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(req.Timeout); err != nil || timeout <= 0 {
+				http.Error(w, fmt.Sprintf("Invalid timeout: %q (must be a positive duration)", req.Timeout), http.StatusBadRequest)
+				return
+			}
+		}
+
+		op := &drainOperation{
+			backend: b,
+			remove:  req.Remove,
+			timeout: timeout,
+			started: time.Now(),
+			cancel:  make(chan struct{}),
+		}
+		s.startDrain(name, b, op)
+		log.Printf("Backend %s draining via admin API (%d active connections, remove: %t)", name, b.ActiveConnections(), req.Remove)
+		s.auditChange(r, map[string]string{
+			"backend": name,
+			"remove":  strconv.FormatBool(req.Remove),
+			"timeout": req.Timeout,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(op.progress(name, false))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBackendUndrain handles the /backends/{name}/undrain endpoint
+// POST returns a draining backend to rotation and cancels its pending removal
+func (s *Server) handleBackendUndrain(w http.ResponseWriter, r *http.Request) {
+	if s.pool == nil {
+		http.Error(w, "Backend pool not available", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	b := s.pool.Get(name)
+	if b == nil {
+		http.Error(w, fmt.Sprintf("Backend not found: %s", name), http.StatusNotFound)
+		return
+	}
+	if !b.IsDraining() {
+		http.Error(w, fmt.Sprintf("Backend not draining: %s", name), http.StatusConflict)
+		return
+	}
+
+	s.drainMu.Lock()
+	if op := s.drains[name]; op != nil {
+		close(op.cancel)
+		delete(s.drains, name)
+	}
+	s.drainMu.Unlock()
+
+	sm := s.stateMachine(name)
+	if sm != nil {
+		sm.ForceHealthy()
+	} else {
+		b.SetDraining(false)
+	}
+	log.Printf("Backend %s undrained via admin API", name)
+	s.auditChange(r, map[string]string{"backend": name})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newStateResponse(name, b, sm))
+}
+
+// startDrain takes a backend out of rotation and records the drain, replacing any
+// earlier drain of the same backend
+func (s *Server) startDrain(name string, b *backend.Backend, op *drainOperation) {
+	s.drainMu.Lock()
+	if previous := s.drains[name]; previous != nil && !previous.removed {
+		close(previous.cancel)
+	}
+	s.drains[name] = op
+	s.drainMu.Unlock()
+
+	// The state machine keeps the backend draining across health check results
+	if sm := s.stateMachine(name); sm != nil {
+		sm.StartDraining()
+	} else {
+		b.SetDraining(true)
+	}
+
+	if op.remove {
+		go s.removeWhenDrained(name, op)
+	}
+}
+
+// removeWhenDrained removes a draining backend from the pool once its active
+// connections have finished or the drain times out
+func (s *Server) removeWhenDrained(name string, op *drainOperation) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	var timeout <-chan time.Time
+	if op.timeout > 0 {
+		timer := time.NewTimer(op.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+wait:
+	for op.backend.ActiveConnections() > 0 {
+		select {
+		case <-op.cancel:
+			return
+		case <-s.ctx.Done():
+			return
+		case <-timeout:
+			log.Printf("Drain of backend %s timed out with %d active connections", name, op.backend.ActiveConnections())
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	if s.drains[name] != op {
+		return
+	}
+	// The backend may have been replaced since the drain started
+	if s.pool.Get(name) == op.backend {
+		s.pool.Remove(name)
+		log.Printf("Backend %s removed after draining", name)
+	}
+	op.removed = true
+}
@@ -0,0 +1,137 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+func TestBackendDrainWithRemoval(t *testing.T) {
+	pool := backend.NewPool()
+	b := backend.NewBackend("web-1", "10.0.0.1:8080", 1)
+	pool.Add(b)
+	pool.Add(backend.NewBackend("web-2", "10.0.0.2:8080", 1))
+	srv := NewServer(Config{Listen: ":0", Pool: pool})
+	defer srv.Shutdown()
+
+	if rec := serveAdmin(srv, http.MethodPost, "/backends/web-9/drain", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown backend, got %d", rec.Code)
+	}
+	if rec := serveAdmin(srv, http.MethodPost, "/backends/web-1/drain", `{"remove": true, "timeout": "soon"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid timeout, got %d", rec.Code)
+	}
+
+	// An in-flight request holds the backend
+	b.IncrementConnections()
+	rec := serveAdmin(srv, http.MethodPost, "/backends/web-1/drain", `{"remove": true}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 starting the drain, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var progress DrainResponse
+	if err := json.NewDecoder(rec.Body).Decode(&progress); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if progress.State != "draining" || progress.ActiveConnections != 1 || !progress.Remove {
+		t.Errorf("unexpected drain progress: %+v", progress)
+	}
+	if !b.IsDraining() || b.IsAvailable() {
+		t.Error("expected the backend to be out of rotation")
+	}
+
+	time.Sleep(3 * drainPollInterval)
+	if pool.Get("web-1") == nil {
+		t.Fatal("expected the backend to stay in the pool while connections remain")
+	}
+
+	b.DecrementConnections()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rec = serveAdmin(srv, http.MethodGet, "/backends/web-1/drain", "")
+		if err := json.NewDecoder(rec.Body).Decode(&progress); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if progress.State == "removed" {
+			break
+		}
+	}
+	if progress.State != "removed" || progress.ActiveConnections != 0 {
+		t.Errorf("expected the drained backend to be removed, got %+v", progress)
+	}
+	if pool.Get("web-1") != nil || pool.Size() != 1 {
+		t.Error("expected web-1 to be removed from the pool")
+	}
+}
+
+func TestBackendDrainTimeout(t *testing.T) {
+	pool := backend.NewPool()
+	b := backend.NewBackend("web-1", "10.0.0.1:8080", 1)
+	pool.Add(b)
+	srv := NewServer(Config{Listen: ":0", Pool: pool})
+	defer srv.Shutdown()
+
+	b.IncrementConnections()
+	if rec := serveAdmin(srv, http.MethodPost, "/backends/web-1/drain", `{"remove": true, "timeout": "150ms"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 starting the drain, got %d", rec.Code)
+	}
+
+	for deadline := time.Now().Add(2 * time.Second); pool.Get("web-1") != nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pool.Get("web-1") != nil {
+		t.Error("expected the backend to be removed once the drain timed out")
+	}
+}
+
+func TestBackendUndrain(t *testing.T) {
+	pool := backend.NewPool()
+	b := backend.NewBackend("web-1", "10.0.0.1:8080", 1)
+	pool.Add(b)
+	sm := backend.NewStateMachine(b, 2, 3)
+	srv := NewServer(Config{
+		Listen: ":0",
+		Pool:   pool,
+		StateMachineFunc: func(name string) *backend.StateMachine {
+			if name == "web-1" {
+				return sm
+			}
+			return nil
+		},
+	})
+	defer srv.Shutdown()
+
+	if rec := serveAdmin(srv, http.MethodPost, "/backends/web-1/undrain", ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 undraining a backend in rotation, got %d", rec.Code)
+	}
+
+	b.IncrementConnections()
+	if rec := serveAdmin(srv, http.MethodPost, "/backends/web-1/drain", `{"remove": true}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 starting the drain, got %d", rec.Code)
+	}
+	if sm.GetState() != backend.StateDraining {
+		t.Fatalf("expected the state machine to be draining, got %s", sm.GetState())
+	}
+
+	rec := serveAdmin(srv, http.MethodPost, "/backends/web-1/undrain", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 undraining, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var state StateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if state.State != "healthy" || !b.IsAvailable() {
+		t.Errorf("expected the backend back in rotation, got %+v", state)
+	}
+
+	// The pending removal is canceled
+	b.DecrementConnections()
+	time.Sleep(3 * drainPollInterval)
+	if pool.Get("web-1") == nil {
+		t.Error("expected the undrained backend to stay in the pool")
+	}
+	if rec := serveAdmin(srv, http.MethodGet, "/backends/web-1/drain", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the progress of an undrained backend, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// statsStreamInterval is how often /stats/stream sends a snapshot by default
+	statsStreamInterval = time.Second
+
+	// minStatsStreamInterval is the shortest interval a client may ask for
+	minStatsStreamInterval = 100 * time.Millisecond
+
+	// statsStreamWriteTimeout bounds each write, so that a stalled client is dropped
+	// while the stream itself outlives the server's write timeout
+	statsStreamWriteTimeout = 10 * time.Second
+)
+
+// handleStatsStream handles the /stats/stream endpoint
+// GET streams the statistics served on /stats as server-sent events, one "stats" event
+// per interval (default 1s, e.g. ?interval=5s), until the client disconnects
+func (s *Server) handleStatsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	interval := statsStreamInterval
+	if value := r.URL.Query().Get("interval"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < minStatsStreamInterval {
+			http.Error(w, fmt.Sprintf("Invalid interval: %s (must be a duration of at least %s)", value, minStatsStreamInterval), http.StatusBadRequest)
+			return
+		}
+		interval = d
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keep reverse proxies such as nginx from buffering the events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		data, err := json.Marshal(s.stats())
+		if err != nil {
+			return
+		}
+		rc.SetWriteDeadline(time.Now().Add(statsStreamWriteTimeout))
+		if _, err := fmt.Fprintf(w, "event: stats\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
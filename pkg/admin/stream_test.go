@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsStream(t *testing.T) {
+	calls := 0
+	srv := NewServer(Config{
+		Listen: ":0",
+		StatsFunc: func() map[string]interface{} {
+			calls++
+			return map[string]interface{}{"total_connections": calls}
+		},
+	})
+	defer srv.Shutdown()
+
+	if rec := serveAdmin(srv, http.MethodPost, "/stats/stream", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+	if rec := serveAdmin(srv, http.MethodGet, "/stats/stream?interval=1ms", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a too short interval, got %d", rec.Code)
+	}
+
+	// The stream outlives the server's write timeout
+	ts := httptest.NewUnstartedServer(srv.server.Handler)
+	ts.Config.WriteTimeout = 200 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/stats/stream?interval=100ms")
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var snapshots []map[string]interface{}
+	for len(snapshots) < 5 && scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line == "event: stats" {
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			t.Fatalf("unexpected line: %q", line)
+		}
+		var stats map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &stats); err != nil {
+			t.Fatalf("failed to decode snapshot: %v", err)
+		}
+		snapshots = append(snapshots, stats)
+	}
+	if len(snapshots) != 5 {
+		t.Fatalf("expected 5 snapshots, got %d (%v)", len(snapshots), scanner.Err())
+	}
+	for i, stats := range snapshots {
+		if stats["total_connections"] != float64(i+1) || stats["timestamp"] == nil {
+			t.Errorf("unexpected snapshot %d: %v", i, stats)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package backend
 
 import (
+	"math"
 	"sync"
 	"sync/atomic"
 )
@@ -9,14 +10,40 @@ import (
 type Backend struct {
 	name    string
 	address string
-	weight  int
+
+	// Weight can be changed at runtime, so it is stored atomically
+	weight atomic.Int64
+
+	// weightFactor scales the weight load balancers use, as math.Float64bits (0 = unscaled)
+	weightFactor atomic.Uint64
+
+	// Priority tier (lower values are preferred)
+	priority int
+
+	// Backup backends only receive traffic when no primary backend is healthy
+	backup bool
+
+	// Zone (availability zone or locality) the backend runs in
+	zone string
+
+	// Labels are metadata used to select backends for routes
+	labels map[string]string
 
 	// Connection tracking
 	activeConnections atomic.Int64
 
+	// maxConnections caps the active connections (0 = unlimited)
+	maxConnections atomic.Int64
+
 	// Health status
 	healthy atomic.Bool
 
+	// Draining backends finish their connections but receive no new ones
+	draining atomic.Bool
+
+	// pools the backend belongs to, notified when its health status changes
+	pools map[*Pool]struct{}
+
 	mu sync.RWMutex
 }
 
@@ -25,8 +52,8 @@ func NewBackend(name, address string, weight int) *Backend {
 	b := &Backend{
 		name:    name,
 		address: address,
-		weight:  weight,
 	}
+	b.weight.Store(int64(weight))
 	b.healthy.Store(true) // Start as healthy
 	return b
 }
@@ -43,7 +70,89 @@ func (b *Backend) Address() string {
 
 // Weight returns the backend weight
 func (b *Backend) Weight() int {
-	return b.weight
+	return int(b.weight.Load())
+}
+
+// SetWeight changes the backend weight
+// Load balancers read the weight on every selection, so the change applies immediately
+func (b *Backend) SetWeight(weight int) {
+	b.weight.Store(int64(weight))
+}
+
+// WeightFactor returns the factor the weight is scaled by for load balancing (1 if unscaled)
+func (b *Backend) WeightFactor() float64 {
+	bits := b.weightFactor.Load()
+	if bits == 0 {
+		return 1
+	}
+	return math.Float64frombits(bits)
+}
+
+// SetWeightFactor scales the weight load balancers use without changing Weight, which
+// stays the weight set by the configuration or the operator
+// Adaptive weighting sets it from each backend's latency and error rate; the factor must
+// be positive, and 1 removes the scaling.
+func (b *Backend) SetWeightFactor(factor float64) {
+	b.weightFactor.Store(math.Float64bits(factor))
+}
+
+// EffectiveWeight returns the weight scaled by the weight factor
+func (b *Backend) EffectiveWeight() float64 {
+	return float64(b.Weight()) * b.WeightFactor()
+}
+
+// Priority returns the backend's priority tier (lower values are preferred)
+func (b *Backend) Priority() int {
+	return b.priority
+}
+
+// SetPriority sets the backend's priority tier
+// It must be called before the backend is added to a pool
+func (b *Backend) SetPriority(priority int) {
+	b.priority = priority
+}
+
+// IsBackup returns true if the backend is a backup
+func (b *Backend) IsBackup() bool {
+	return b.backup
+}
+
+// SetBackup marks the backend as a backup
+// It must be called before the backend is added to a pool
+func (b *Backend) SetBackup(backup bool) {
+	b.backup = backup
+}
+
+// Zone returns the backend's zone (empty if unknown)
+func (b *Backend) Zone() string {
+	return b.zone
+}
+
+// SetZone sets the backend's zone
+// It must be called before the backend is added to a pool
+func (b *Backend) SetZone(zone string) {
+	b.zone = zone
+}
+
+// Labels returns the backend's labels (nil if none); the map must not be modified
+func (b *Backend) Labels() map[string]string {
+	return b.labels
+}
+
+// SetLabels sets the backend's labels
+// It must be called before the backend is added to a pool
+func (b *Backend) SetLabels(labels map[string]string) {
+	b.labels = labels
+}
+
+// MatchLabels reports whether the backend has every label in the selector
+func (b *Backend) MatchLabels(selector map[string]string) bool {
+	for k, v := range selector {
+		if label, ok := b.labels[k]; !ok || label != v {
+			return false
+		}
+	}
+	return true
 }
 
 // IsHealthy returns true if the backend is healthy
@@ -53,12 +162,65 @@ func (b *Backend) IsHealthy() bool {
 
 // MarkHealthy marks the backend as healthy
 func (b *Backend) MarkHealthy() {
-	b.healthy.Store(true)
+	if !b.healthy.Swap(true) {
+		b.notifyHealthChange(true)
+	}
 }
 
 // MarkUnhealthy marks the backend as unhealthy
 func (b *Backend) MarkUnhealthy() {
-	b.healthy.Store(false)
+	if b.healthy.Swap(false) {
+		b.notifyHealthChange(false)
+	}
+}
+
+// IsDraining returns true if the backend is draining
+func (b *Backend) IsDraining() bool {
+	return b.draining.Load()
+}
+
+// SetDraining starts or stops draining the backend
+// A draining backend is skipped by every load balancing algorithm regardless of its
+// health, while its existing connections are left to finish.
+func (b *Backend) SetDraining(draining bool) {
+	b.draining.Store(draining)
+}
+
+// IsAvailable returns true if the backend can receive new connections: it is healthy
+// and not draining
+func (b *Backend) IsAvailable() bool {
+	return b.IsHealthy() && !b.IsDraining()
+}
+
+// notifyHealthChange publishes a health change to the pools the backend belongs to
+func (b *Backend) notifyHealthChange(healthy bool) {
+	b.mu.RLock()
+	pools := make([]*Pool, 0, len(b.pools))
+	for p := range b.pools {
+		pools = append(pools, p)
+	}
+	b.mu.RUnlock()
+
+	for _, p := range pools {
+		p.publish(PoolEvent{Type: BackendHealthChanged, Backend: b, Healthy: healthy})
+	}
+}
+
+// attach records that the backend belongs to a pool
+func (b *Backend) attach(p *Pool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pools == nil {
+		b.pools = make(map[*Pool]struct{})
+	}
+	b.pools[p] = struct{}{}
+}
+
+// detach records that the backend left a pool
+func (b *Backend) detach(p *Pool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.pools, p)
 }
 
 // ActiveConnections returns the number of active connections
@@ -71,6 +233,36 @@ func (b *Backend) IncrementConnections() {
 	b.activeConnections.Add(1)
 }
 
+// TryIncrementConnections increments the active connection count unless the backend
+// is at its connection limit, reporting whether it did
+func (b *Backend) TryIncrementConnections() bool {
+	for {
+		active := b.activeConnections.Load()
+		if limit := b.maxConnections.Load(); limit > 0 && active >= limit {
+			return false
+		}
+		if b.activeConnections.CompareAndSwap(active, active+1) {
+			return true
+		}
+	}
+}
+
+// MaxConnections returns the connection limit (0 = unlimited)
+func (b *Backend) MaxConnections() int {
+	return int(b.maxConnections.Load())
+}
+
+// SetMaxConnections sets the connection limit (0 = unlimited)
+func (b *Backend) SetMaxConnections(limit int) {
+	b.maxConnections.Store(int64(limit))
+}
+
+// AtCapacity reports whether the backend has reached its connection limit
+func (b *Backend) AtCapacity() bool {
+	limit := b.maxConnections.Load()
+	return limit > 0 && b.activeConnections.Load() >= limit
+}
+
 // DecrementConnections decrements the active connection count
 func (b *Backend) DecrementConnections() {
 	b.activeConnections.Add(-1)
@@ -1,13 +1,80 @@
 package backend
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
 	"sync"
 )
 
+var (
+	// ErrDuplicateBackend is returned when adding a backend whose name or address is already in the pool
+	ErrDuplicateBackend = errors.New("duplicate backend")
+
+	// ErrInvalidAddress is returned when adding a backend whose address is not a valid host:port
+	ErrInvalidAddress = errors.New("invalid backend address")
+)
+
+// PoolEventType identifies a change to a pool
+type PoolEventType int
+
+const (
+	// BackendAdded is published when a backend joins the pool
+	BackendAdded PoolEventType = iota
+
+	// BackendRemoved is published when a backend leaves the pool
+	BackendRemoved
+
+	// BackendHealthChanged is published when a backend in the pool becomes healthy or unhealthy
+	BackendHealthChanged
+)
+
+// String returns the event type name
+func (t PoolEventType) String() string {
+	switch t {
+	case BackendAdded:
+		return "added"
+	case BackendRemoved:
+		return "removed"
+	case BackendHealthChanged:
+		return "health-changed"
+	default:
+		return "unknown"
+	}
+}
+
+// PoolEvent describes a change to a pool
+type PoolEvent struct {
+	Type    PoolEventType
+	Backend *Backend
+
+	// Healthy is the new health status of a BackendHealthChanged event
+	Healthy bool
+}
+
+// PoolListener is called with every change to a pool it subscribed to
+type PoolListener func(event PoolEvent)
+
 // Pool manages a collection of backends
 type Pool struct {
 	backends []*Backend
 	mu       sync.RWMutex
+
+	// Primary backends grouped by priority, highest priority first, and the backups,
+	// regrouped whenever the backends change so Healthy does not sort on every call
+	tiers   [][]*Backend
+	backups []*Backend
+
+	// failoverThreshold is the healthy fraction below which a priority tier fails over to the next
+	failoverThreshold float64
+
+	// Subscribed listeners by subscription ID
+	listeners    map[int]PoolListener
+	nextListener int
 }
 
 // NewPool creates a new backend pool
@@ -18,26 +85,201 @@ func NewPool() *Pool {
 }
 
 // Add adds a backend to the pool
-func (p *Pool) Add(backend *Backend) {
+// It fails if the address is not a valid host:port or another backend in the pool
+// already has the same name or address.
+func (p *Pool) Add(backend *Backend) error {
+	if err := ValidateAddress(backend.Address()); err != nil {
+		return fmt.Errorf("backend %s: %w", backend.Name(), err)
+	}
+
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	for _, b := range p.backends {
+		if b.Name() == backend.Name() {
+			p.mu.Unlock()
+			return fmt.Errorf("%w: name %s is already in the pool", ErrDuplicateBackend, backend.Name())
+		}
+		if b.Address() == backend.Address() {
+			p.mu.Unlock()
+			return fmt.Errorf("%w: address %s is already used by backend %s", ErrDuplicateBackend, backend.Address(), b.Name())
+		}
+	}
 	p.backends = append(p.backends, backend)
+	p.regroup()
+	backend.attach(p)
+	p.mu.Unlock()
+
+	p.publish(PoolEvent{Type: BackendAdded, Backend: backend, Healthy: backend.IsHealthy()})
+	return nil
+}
+
+// ValidateAddress checks that address is a host:port with a host and a port between 1 and 65535
+func ValidateAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidAddress, address, err)
+	}
+	if host == "" {
+		return fmt.Errorf("%w %q: missing host", ErrInvalidAddress, address)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%w %q: port must be a number between 1 and 65535", ErrInvalidAddress, address)
+	}
+	return nil
 }
 
 // Remove removes a backend from the pool
 func (p *Pool) Remove(name string) bool {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	for i, b := range p.backends {
 		if b.Name() == name {
 			p.backends = append(p.backends[:i], p.backends[i+1:]...)
+			p.regroup()
+			b.detach(p)
+			p.mu.Unlock()
+
+			p.publish(PoolEvent{Type: BackendRemoved, Backend: b, Healthy: b.IsHealthy()})
 			return true
 		}
 	}
+	p.mu.Unlock()
 	return false
 }
 
+// ReplaceAll atomically replaces the pool's backends, so readers never see the pool
+// partly updated
+// A current backend survives if the replacement has the same name, address, priority,
+// backup flag, zone and labels: the current instance is kept, so its connection count
+// and health carry over, and it takes the replacement's weight. Otherwise the
+// replacement is added as a new backend. Backends that Add would reject, because their
+// address is invalid or an earlier backend has the same name or address, are left out
+// and reported in the error; the rest are still applied. It returns the backends that
+// were added and removed, and publishes their events with removals first.
+func (p *Pool) ReplaceAll(backends []*Backend) (added, removed []*Backend, err error) {
+	return p.Update(func([]*Backend) []*Backend { return backends })
+}
+
+// Update replaces the pool's backends with those returned by update, which is called
+// with the current backends under the pool lock
+// Use it for read-modify-write changes so concurrent Add and Remove calls are not
+// lost. update must not call other methods of the pool. The replacement is applied as
+// by ReplaceAll.
+func (p *Pool) Update(update func(current []*Backend) []*Backend) (added, removed []*Backend, err error) {
+	p.mu.Lock()
+
+	backends := update(slices.Clone(p.backends))
+
+	current := make(map[string]*Backend, len(p.backends))
+	for _, b := range p.backends {
+		current[b.Name()] = b
+	}
+
+	var errs []error
+	next := make([]*Backend, 0, len(backends))
+	names := make(map[string]bool, len(backends))
+	addresses := make(map[string]string, len(backends))
+	for _, b := range backends {
+		if err := ValidateAddress(b.Address()); err != nil {
+			errs = append(errs, fmt.Errorf("backend %s: %w", b.Name(), err))
+			continue
+		}
+		if names[b.Name()] {
+			errs = append(errs, fmt.Errorf("%w: name %s is already in the pool", ErrDuplicateBackend, b.Name()))
+			continue
+		}
+		if other, ok := addresses[b.Address()]; ok {
+			errs = append(errs, fmt.Errorf("%w: address %s is already used by backend %s", ErrDuplicateBackend, b.Address(), other))
+			continue
+		}
+		names[b.Name()] = true
+		addresses[b.Address()] = b.Name()
+
+		if existing, ok := current[b.Name()]; ok && sameBackend(existing, b) {
+			if existing != b {
+				existing.SetWeight(b.Weight())
+			}
+			next = append(next, existing)
+			delete(current, b.Name())
+			continue
+		}
+		next = append(next, b)
+		added = append(added, b)
+	}
+
+	for _, b := range p.backends {
+		if _, ok := current[b.Name()]; ok {
+			removed = append(removed, b)
+			b.detach(p)
+		}
+	}
+	for _, b := range added {
+		b.attach(p)
+	}
+
+	p.backends = next
+	p.regroup()
+	p.mu.Unlock()
+
+	// A replaced backend is removed before its replacement is added, so listeners
+	// tracking backends by name end up with the replacement
+	for _, b := range removed {
+		p.publish(PoolEvent{Type: BackendRemoved, Backend: b, Healthy: b.IsHealthy()})
+	}
+	for _, b := range added {
+		p.publish(PoolEvent{Type: BackendAdded, Backend: b, Healthy: b.IsHealthy()})
+	}
+	return added, removed, errors.Join(errs...)
+}
+
+// sameBackend reports whether b can stand in for existing, differing at most in weight
+func sameBackend(existing, b *Backend) bool {
+	return existing.Address() == b.Address() &&
+		existing.Priority() == b.Priority() &&
+		existing.IsBackup() == b.IsBackup() &&
+		existing.Zone() == b.Zone() &&
+		maps.Equal(existing.Labels(), b.Labels())
+}
+
+// Subscribe registers a listener for backends being added to or removed from the pool
+// and for changes in their health, and returns a function that unsubscribes it
+// Listeners are called synchronously after the change, outside the pool lock, so they
+// may use the pool but must not block.
+func (p *Pool) Subscribe(listener PoolListener) (unsubscribe func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.listeners == nil {
+		p.listeners = make(map[int]PoolListener)
+	}
+	id := p.nextListener
+	p.nextListener++
+	p.listeners[id] = listener
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.listeners, id)
+	}
+}
+
+// publish calls every subscribed listener with an event
+func (p *Pool) publish(event PoolEvent) {
+	p.mu.RLock()
+	if len(p.listeners) == 0 {
+		p.mu.RUnlock()
+		return
+	}
+	ids := slices.Sorted(maps.Keys(p.listeners))
+	listeners := make([]PoolListener, len(ids))
+	for i, id := range ids {
+		listeners[i] = p.listeners[id]
+	}
+	p.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
 // Get returns a backend by name
 func (p *Pool) Get(name string) *Backend {
 	p.mu.RLock()
@@ -67,18 +309,84 @@ func (p *Pool) All() []*Backend {
 	return result
 }
 
-// Healthy returns all healthy backends
+// SetFailoverThreshold sets the healthy fraction (0.0-1.0) a priority tier needs to keep receiving traffic
+// With 0, traffic only fails over once a tier has no healthy backends
+func (p *Pool) SetFailoverThreshold(threshold float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failoverThreshold = threshold
+}
+
+// Healthy returns the healthy backends of the highest-priority tier that has healthy capacity
+// A tier whose healthy fraction is below the failover threshold is skipped in favour of the
+// next tier; if no tier meets the threshold, the first tier with any healthy backend is used.
+// Backup backends are only returned when no primary backend is healthy. Draining backends
+// count as unhealthy, so no algorithm selects them.
 func (p *Pool) Healthy() []*Backend {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	result := make([]*Backend, 0, len(p.backends))
+	var fallback []*Backend
+	for _, tier := range p.tiers {
+		healthy := make([]*Backend, 0, len(tier))
+		for _, b := range tier {
+			if b.IsAvailable() {
+				healthy = append(healthy, b)
+			}
+		}
+
+		if len(healthy) == 0 {
+			continue
+		}
+		if float64(len(healthy))/float64(len(tier)) >= p.failoverThreshold {
+			return healthy
+		}
+		if fallback == nil {
+			fallback = healthy
+		}
+	}
+
+	if fallback != nil {
+		return fallback
+	}
+
+	// All primary backends are down: use the backups
+	backups := make([]*Backend, 0)
+	for _, b := range p.backups {
+		if b.IsAvailable() {
+			backups = append(backups, b)
+		}
+	}
+	return backups
+}
+
+// regroup groups primary (non-backup) backends by priority, highest priority (lowest value)
+// first, and collects the backups
+// Callers must hold the write lock. Priority and the backup flag cannot change once a
+// backend is in the pool, so the groups only change with the backends.
+func (p *Pool) regroup() {
+	byPriority := make(map[int][]*Backend)
+	backups := make([]*Backend, 0)
 	for _, b := range p.backends {
-		if b.IsHealthy() {
-			result = append(result, b)
+		if b.IsBackup() {
+			backups = append(backups, b)
+			continue
 		}
+		byPriority[b.Priority()] = append(byPriority[b.Priority()], b)
 	}
-	return result
+
+	priorities := make([]int, 0, len(byPriority))
+	for priority := range byPriority {
+		priorities = append(priorities, priority)
+	}
+	sort.Ints(priorities)
+
+	tiers := make([][]*Backend, 0, len(priorities))
+	for _, priority := range priorities {
+		tiers = append(tiers, byPriority[priority])
+	}
+	p.tiers = tiers
+	p.backups = backups
 }
 
 // Size returns the total number of backends
@@ -88,16 +396,42 @@ func (p *Pool) Size() int {
 	return len(p.backends)
 }
 
-// HealthySize returns the number of healthy backends
+// HealthySize returns the number of healthy backends that are not draining
 func (p *Pool) HealthySize() int {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	count := 0
 	for _, b := range p.backends {
-		if b.IsHealthy() {
+		if b.IsAvailable() {
 			count++
 		}
 	}
 	return count
 }
+
+// Stats returns pool statistics
+func (p *Pool) Stats() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	healthy := 0
+	draining := 0
+	activeConnections := int64(0)
+	for _, b := range p.backends {
+		if b.IsAvailable() {
+			healthy++
+		}
+		if b.IsDraining() {
+			draining++
+		}
+		activeConnections += b.ActiveConnections()
+	}
+
+	return map[string]interface{}{
+		"total_backends":     len(p.backends),
+		"healthy_backends":   healthy,
+		"draining_backends":  draining,
+		"active_connections": activeConnections,
+	}
+}
@@ -0,0 +1,349 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func newTieredPool(threshold float64) (*Pool, []*Backend, []*Backend) {
+	pool := NewPool()
+	pool.SetFailoverThreshold(threshold)
+
+	var primary, secondary []*Backend
+	for i, name := range []string{"p1", "p2", "p3", "p4"} {
+		b := NewBackend(name, fmt.Sprintf("localhost:%d", 9001+i), 1)
+		pool.Add(b)
+		primary = append(primary, b)
+	}
+	for i, name := range []string{"s1", "s2"} {
+		b := NewBackend(name, fmt.Sprintf("localhost:%d", 9101+i), 1)
+		b.SetPriority(1)
+		pool.Add(b)
+		secondary = append(secondary, b)
+	}
+
+	return pool, primary, secondary
+}
+
+func names(backends []*Backend) map[string]bool {
+	result := make(map[string]bool)
+	for _, b := range backends {
+		result[b.Name()] = true
+	}
+	return result
+}
+
+func TestPool_PriorityTiers(t *testing.T) {
+	pool, primary, _ := newTieredPool(0)
+
+	// Only the highest-priority tier receives traffic
+	healthy := names(pool.Healthy())
+	if len(healthy) != 4 || !healthy["p1"] || healthy["s1"] {
+		t.Errorf("Expected only primary backends, got %v", healthy)
+	}
+
+	// A partially healthy tier keeps traffic with a zero threshold
+	primary[0].MarkUnhealthy()
+	primary[1].MarkUnhealthy()
+	primary[2].MarkUnhealthy()
+
+	healthy = names(pool.Healthy())
+	if len(healthy) != 1 || !healthy["p4"] {
+		t.Errorf("Expected only p4, got %v", healthy)
+	}
+
+	// Traffic fails over once the tier has no healthy backends
+	primary[3].MarkUnhealthy()
+
+	healthy = names(pool.Healthy())
+	if len(healthy) != 2 || !healthy["s1"] || !healthy["s2"] {
+		t.Errorf("Expected secondary backends, got %v", healthy)
+	}
+
+	// And fails back when the tier recovers
+	primary[0].MarkHealthy()
+
+	healthy = names(pool.Healthy())
+	if len(healthy) != 1 || !healthy["p1"] {
+		t.Errorf("Expected p1 after recovery, got %v", healthy)
+	}
+}
+
+func TestPool_TiersFollowChanges(t *testing.T) {
+	pool, _, _ := newTieredPool(0)
+
+	// Removing the whole primary tier moves traffic to the next one
+	for _, name := range []string{"p1", "p2", "p3", "p4"} {
+		pool.Remove(name)
+	}
+	healthy := names(pool.Healthy())
+	if len(healthy) != 2 || !healthy["s1"] || !healthy["s2"] {
+		t.Errorf("Expected secondary backends, got %v", healthy)
+	}
+
+	// A higher-priority backend added later takes the traffic
+	top := NewBackend("top", "localhost:9201", 1)
+	top.SetPriority(-1)
+	pool.Add(top)
+	healthy = names(pool.Healthy())
+	if len(healthy) != 1 || !healthy["top"] {
+		t.Errorf("Expected top, got %v", healthy)
+	}
+
+	// Replacing the backends regroups them
+	backup := NewBackend("backup", "localhost:9301", 1)
+	backup.SetBackup(true)
+	pool.ReplaceAll([]*Backend{backup})
+	healthy = names(pool.Healthy())
+	if len(healthy) != 1 || !healthy["backup"] {
+		t.Errorf("Expected backup, got %v", healthy)
+	}
+}
+
+func BenchmarkPool_Healthy(b *testing.B) {
+	pool, _, _ := newTieredPool(0)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pool.Healthy()
+	}
+}
+
+func TestPool_FailoverThreshold(t *testing.T) {
+	pool, primary, secondary := newTieredPool(0.75)
+
+	// 3 of 4 healthy meets the threshold
+	primary[0].MarkUnhealthy()
+
+	healthy := names(pool.Healthy())
+	if len(healthy) != 3 || healthy["p1"] {
+		t.Errorf("Expected p2, p3 and p4, got %v", healthy)
+	}
+
+	// 2 of 4 healthy is below the threshold
+	primary[1].MarkUnhealthy()
+
+	healthy = names(pool.Healthy())
+	if len(healthy) != 2 || !healthy["s1"] || !healthy["s2"] {
+		t.Errorf("Expected secondary backends, got %v", healthy)
+	}
+
+	// If no tier meets the threshold, the first tier with healthy backends is used
+	secondary[0].MarkUnhealthy()
+
+	healthy = names(pool.Healthy())
+	if len(healthy) != 2 || !healthy["p3"] || !healthy["p4"] {
+		t.Errorf("Expected p3 and p4 as fallback, got %v", healthy)
+	}
+
+	// Nothing healthy at all
+	primary[2].MarkUnhealthy()
+	primary[3].MarkUnhealthy()
+	secondary[1].MarkUnhealthy()
+
+	if healthy := pool.Healthy(); len(healthy) != 0 {
+		t.Errorf("Expected no healthy backends, got %d", len(healthy))
+	}
+}
+
+func TestPool_BackupBackends(t *testing.T) {
+	pool := NewPool()
+
+	primary1 := NewBackend("primary-1", "localhost:9001", 1)
+	primary2 := NewBackend("primary-2", "localhost:9002", 1)
+	primary2.SetPriority(1)
+	backup := NewBackend("backup", "localhost:9003", 1)
+	backup.SetBackup(true)
+
+	pool.Add(primary1)
+	pool.Add(primary2)
+	pool.Add(backup)
+
+	// Backups are excluded while any primary is healthy, whatever its tier
+	primary1.MarkUnhealthy()
+
+	healthy := names(pool.Healthy())
+	if len(healthy) != 1 || !healthy["primary-2"] {
+		t.Errorf("Expected primary-2, got %v", healthy)
+	}
+
+	// Backups take over once every primary is down
+	primary2.MarkUnhealthy()
+
+	healthy = names(pool.Healthy())
+	if len(healthy) != 1 || !healthy["backup"] {
+		t.Errorf("Expected backup, got %v", healthy)
+	}
+
+	// Unhealthy backups are not used either
+	backup.MarkUnhealthy()
+
+	if healthy := pool.Healthy(); len(healthy) != 0 {
+		t.Errorf("Expected no healthy backends, got %d", len(healthy))
+	}
+}
+
+func TestPool_ReplaceAll(t *testing.T) {
+	pool := NewPool()
+	kept := NewBackend("kept", "localhost:9001", 1)
+	moved := NewBackend("moved", "localhost:9002", 1)
+	gone := NewBackend("gone", "localhost:9003", 1)
+	pool.Add(kept)
+	pool.Add(moved)
+	pool.Add(gone)
+	kept.IncrementConnections()
+	kept.MarkUnhealthy()
+
+	added, removed, err := pool.ReplaceAll([]*Backend{
+		NewBackend("kept", "localhost:9001", 5),
+		NewBackend("moved", "localhost:9102", 1),
+		NewBackend("new", "localhost:9004", 1),
+		NewBackend("new", "localhost:9005", 1),
+		NewBackend("copy", "localhost:9004", 1),
+		NewBackend("broken", "localhost", 1),
+	})
+
+	// Backends Add would reject are left out and reported
+	if !errors.Is(err, ErrDuplicateBackend) || !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Expected duplicate and invalid address errors, got %v", err)
+	}
+
+	// The surviving backend keeps its instance, connections and health, with the new weight
+	if pool.Get("kept") != kept || kept.ActiveConnections() != 1 || kept.IsHealthy() || kept.Weight() != 5 {
+		t.Error("Expected kept to survive with its connection count and health, and weight 5")
+	}
+	if b := pool.Get("moved"); b == moved || b.Address() != "localhost:9102" {
+		t.Error("Expected moved to be replaced by the backend at its new address")
+	}
+	if pool.Get("gone") != nil {
+		t.Error("Expected gone to be removed")
+	}
+	if pool.Get("copy") != nil || pool.Get("broken") != nil {
+		t.Error("Expected the backends with a duplicate or invalid address to be left out")
+	}
+	if b := pool.Get("new"); b == nil || b.Address() != "localhost:9004" || pool.Size() != 3 {
+		t.Errorf("Expected the first backend named new and 3 backends, got %d", pool.Size())
+	}
+
+	if got := names(added); len(got) != 2 || !got["moved"] || !got["new"] {
+		t.Errorf("Expected moved and new to be added, got %v", got)
+	}
+	if got := names(removed); len(got) != 2 || !got["moved"] || !got["gone"] {
+		t.Errorf("Expected moved and gone to be removed, got %v", got)
+	}
+}
+
+func TestPool_UpdateConcurrentAdd(t *testing.T) {
+	pool := NewPool()
+
+	// Each update keeps what is in the pool and adds one backend, so no Add made while
+	// updates run is lost
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			pool.Update(func(current []*Backend) []*Backend {
+				return append(current, NewBackend(fmt.Sprintf("updated-%d", i), fmt.Sprintf("localhost:%d", 10000+i), 1))
+			})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		pool.Add(NewBackend(fmt.Sprintf("added-%d", i), fmt.Sprintf("localhost:%d", 20000+i), 1))
+	}
+	<-done
+
+	if size := pool.Size(); size != 200 {
+		t.Errorf("Expected 200 backends, got %d", size)
+	}
+}
+
+func TestPool_Subscribe(t *testing.T) {
+	pool := NewPool()
+	var events []string
+	unsubscribe := pool.Subscribe(func(event PoolEvent) {
+		events = append(events, fmt.Sprintf("%s %s %t", event.Type, event.Backend.Name(), event.Healthy))
+	})
+
+	a := NewBackend("a", "localhost:9001", 1)
+	pool.Add(a)
+	pool.Add(NewBackend("a", "localhost:9002", 1)) // duplicate, no event
+	a.MarkUnhealthy()
+	a.MarkUnhealthy() // no change, no event
+	pool.ReplaceAll([]*Backend{NewBackend("a", "localhost:9003", 1)})
+	a.MarkHealthy() // no longer in the pool, no event
+	pool.Remove("a")
+
+	unsubscribe()
+	pool.Add(NewBackend("b", "localhost:9004", 1))
+
+	expected := []string{
+		"added a true",
+		"health-changed a false",
+		"removed a false",
+		"added a true",
+		"removed a true",
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+}
+
+func TestPool_AddRejectsDuplicatesAndInvalidAddresses(t *testing.T) {
+	pool := NewPool()
+	if err := pool.Add(NewBackend("a", "localhost:9001", 1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		backend *Backend
+		err     error
+	}{
+		{"duplicate name", NewBackend("a", "localhost:9002", 1), ErrDuplicateBackend},
+		{"duplicate address", NewBackend("b", "localhost:9001", 1), ErrDuplicateBackend},
+		{"missing port", NewBackend("b", "localhost", 1), ErrInvalidAddress},
+		{"missing host", NewBackend("b", ":9002", 1), ErrInvalidAddress},
+		{"port out of range", NewBackend("b", "localhost:65536", 1), ErrInvalidAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pool.Add(tt.backend); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	if pool.Size() != 1 {
+		t.Errorf("Expected rejected backends to stay out of the pool, got %d backends", pool.Size())
+	}
+}
+
+func TestPool_ReplaceAllConcurrentReaders(t *testing.T) {
+	pool := NewPool()
+	sets := [][]*Backend{
+		{NewBackend("a", "localhost:9001", 1), NewBackend("b", "localhost:9002", 1)},
+		{NewBackend("c", "localhost:9003", 1), NewBackend("d", "localhost:9004", 1)},
+	}
+	pool.ReplaceAll(sets[0])
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			pool.ReplaceAll(sets[i%2])
+		}
+	}()
+
+	// Readers always see a complete set, never an empty or mixed pool
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if got := len(pool.Healthy()); got != 2 {
+			t.Fatalf("Expected 2 backends, got %d", got)
+		}
+	}
+}
@@ -65,8 +65,14 @@ type HealthMetrics struct {
 
 	// Response time tracking (for passive health checks)
 	totalResponseTime atomic.Int64 // in nanoseconds
+
+	// Exponentially weighted moving average of response time
+	ewmaResponseTime atomic.Int64 // in nanoseconds
 }
 
+// ewmaAlpha is the weight given to each new response time sample
+const ewmaAlpha = 0.3
+
 // StateMachine manages backend health state transitions
 type StateMachine struct {
 	backend *Backend
@@ -119,8 +125,8 @@ func (sm *StateMachine) RecordSuccess() {
 	sm.metrics.totalSuccesses.Add(1)
 	sm.metrics.lastCheckTime.Store(time.Now())
 
-	// Check if we should transition to healthy
-	if sm.metrics.consecutiveSuccesses.Load() >= int64(sm.healthyThreshold) {
+	// Check if we should transition to healthy (draining is only left via ForceHealthy)
+	if sm.metrics.consecutiveSuccesses.Load() >= int64(sm.healthyThreshold) && !sm.IsDraining() {
 		sm.transitionTo(StateHealthy)
 	}
 }
@@ -132,8 +138,8 @@ func (sm *StateMachine) RecordFailure() {
 	sm.metrics.totalFailures.Add(1)
 	sm.metrics.lastCheckTime.Store(time.Now())
 
-	// Check if we should transition to unhealthy
-	if sm.metrics.consecutiveFailures.Load() >= int64(sm.unhealthyThreshold) {
+	// Check if we should transition to unhealthy (draining is only left via ForceHealthy)
+	if sm.metrics.consecutiveFailures.Load() >= int64(sm.unhealthyThreshold) && !sm.IsDraining() {
 		sm.transitionTo(StateUnhealthy)
 	}
 }
@@ -144,11 +150,26 @@ func (sm *StateMachine) RecordRequest(success bool, responseTime time.Duration)
 
 	if success {
 		sm.metrics.totalResponseTime.Add(int64(responseTime))
+		sm.updateEWMA(responseTime)
 	} else {
 		sm.metrics.failedRequests.Add(1)
 	}
 }
 
+// updateEWMA folds a response time sample into the moving average
+func (sm *StateMachine) updateEWMA(responseTime time.Duration) {
+	for {
+		old := sm.metrics.ewmaResponseTime.Load()
+		next := int64(responseTime)
+		if old != 0 {
+			next = int64(ewmaAlpha*float64(responseTime) + (1-ewmaAlpha)*float64(old))
+		}
+		if sm.metrics.ewmaResponseTime.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
 // StartDraining transitions the backend to draining state
 func (sm *StateMachine) StartDraining() {
 	sm.transitionTo(StateDraining)
@@ -178,7 +199,9 @@ func (sm *StateMachine) transitionTo(newState State) {
 	sm.state.Store(newState)
 	sm.metrics.lastStateChange.Store(time.Now())
 
-	// Update backend's health status for backward compatibility
+	// Load balancers skip draining backends; the health status is also updated for
+	// backward compatibility
+	sm.backend.SetDraining(newState == StateDraining)
 	if newState == StateHealthy {
 		sm.backend.MarkHealthy()
 	} else {
@@ -248,6 +271,11 @@ func (sm *StateMachine) GetAverageResponseTime() time.Duration {
 	return time.Duration(totalTime / total)
 }
 
+// GetEWMAResponseTime returns the moving average response time, weighted towards recent requests
+func (sm *StateMachine) GetEWMAResponseTime() time.Duration {
+	return time.Duration(sm.metrics.ewmaResponseTime.Load())
+}
+
 // GetLastCheckTime returns the last health check time
 func (sm *StateMachine) GetLastCheckTime() time.Time {
 	val := sm.metrics.lastCheckTime.Load()
@@ -275,6 +303,7 @@ func (sm *StateMachine) Reset() {
 	sm.metrics.totalRequests.Store(0)
 	sm.metrics.failedRequests.Store(0)
 	sm.metrics.totalResponseTime.Store(0)
+	sm.metrics.ewmaResponseTime.Store(0)
 	now := time.Now()
 	sm.metrics.lastCheckTime.Store(now)
 	sm.metrics.lastStateChange.Store(now)
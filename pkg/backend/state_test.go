@@ -137,6 +137,18 @@ func TestStateMachine_Draining(t *testing.T) {
 	if sm.GetState() != StateDraining {
 		t.Errorf("Expected state to be StateDraining, got %s", sm.GetState())
 	}
+
+	// Health checks do not end draining
+	sm.RecordSuccess()
+	sm.RecordSuccess()
+	if !sm.IsDraining() {
+		t.Errorf("Expected backend to stay draining after successful checks, got %s", sm.GetState())
+	}
+
+	sm.ForceHealthy()
+	if !sm.IsHealthy() {
+		t.Error("Expected ForceHealthy to end draining")
+	}
 }
 
 func TestStateMachine_ForceStates(t *testing.T) {
@@ -180,6 +192,28 @@ func TestStateMachine_RequestTracking(t *testing.T) {
 	}
 }
 
+func TestStateMachine_EWMAResponseTime(t *testing.T) {
+	backend := NewBackend("test", "localhost:8080", 1)
+	sm := NewStateMachine(backend, 2, 3)
+
+	if sm.GetEWMAResponseTime() != 0 {
+		t.Errorf("Expected no EWMA before requests, got %s", sm.GetEWMAResponseTime())
+	}
+
+	// The first sample seeds the average
+	sm.RecordRequest(true, 100*time.Millisecond)
+	if sm.GetEWMAResponseTime() != 100*time.Millisecond {
+		t.Errorf("Expected EWMA 100ms, got %s", sm.GetEWMAResponseTime())
+	}
+
+	// Failed requests do not affect latency
+	sm.RecordRequest(false, 5*time.Second)
+	sm.RecordRequest(true, 200*time.Millisecond)
+	if sm.GetEWMAResponseTime() != 130*time.Millisecond {
+		t.Errorf("Expected EWMA 130ms, got %s", sm.GetEWMAResponseTime())
+	}
+}
+
 func TestStateMachine_Metrics(t *testing.T) {
 	backend := NewBackend("test", "localhost:8080", 1)
 	sm := NewStateMachine(backend, 2, 3)
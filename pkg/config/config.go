@@ -2,23 +2,86 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/therealutkarshpriyadarshi/balance/pkg/lb"
 	"gopkg.in/yaml.v3"
 )
 
+// ja3HashPattern matches a JA3 fingerprint hash (the MD5 of the fingerprint string)
+var ja3HashPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// validSyslogFacilities are the syslog facility names accepted in log output URLs
+var validSyslogFacilities = map[string]bool{
+	"kern": true, "user": true, "mail": true, "daemon": true, "auth": true, "syslog": true,
+	"lpr": true, "news": true, "uucp": true, "cron": true, "authpriv": true, "ftp": true,
+	"local0": true, "local1": true, "local2": true, "local3": true,
+	"local4": true, "local5": true, "local6": true, "local7": true,
+}
+
+// statusClassPattern matches an HTTP status class such as "2xx"
+var statusClassPattern = regexp.MustCompile(`^[1-5]xx$`)
+
 // Config represents the main configuration structure
 type Config struct {
-	// Mode can be "tcp" or "http"
+	// Mode can be "tcp", "http" or "auto" (detect TLS, HTTP or raw TCP per connection)
 	Mode string `yaml:"mode"`
 
 	// Listen address (e.g., ":8080" or "0.0.0.0:8080")
 	Listen string `yaml:"listen"`
 
+	// SniffTimeout is how long auto mode waits for a client's first bytes before
+	// proxying the connection as raw TCP (for protocols where the server speaks first)
+	SniffTimeout time.Duration `yaml:"sniff_timeout,omitempty"`
+
+	// MaxConnections limits concurrent client connections on the listener (0 = unlimited)
+	MaxConnections int `yaml:"max_connections,omitempty"`
+
+	// ConnectionQueueTimeout is how long a connection over the limit waits for a free slot
+	// before being rejected (0 = reject immediately)
+	ConnectionQueueTimeout time.Duration `yaml:"connection_queue_timeout,omitempty"`
+
+	// ConnectionQueueSize is how many connections over the limit may wait for a slot at
+	// once; further connections are rejected (default: max_connections)
+	ConnectionQueueSize int `yaml:"connection_queue_size,omitempty"`
+
+	// MaxConnectionRate limits new client connections accepted per second on the
+	// listener across all client IPs (0 = unlimited)
+	MaxConnectionRate float64 `yaml:"max_connection_rate,omitempty"`
+
+	// ConnectionRateBurst is how many connections can be accepted at once above
+	// max_connection_rate (default: max_connection_rate rounded up)
+	ConnectionRateBurst int `yaml:"connection_rate_burst,omitempty"`
+
+	// ConnectionWorkers handles TCP connections on a bounded pool of goroutines instead
+	// of a new goroutine each (optional, tcp and auto modes)
+	ConnectionWorkers *ConnectionWorkersConfig `yaml:"connection_workers,omitempty"`
+
+	// RequestQueue holds HTTP requests while every backend is at its max_connections,
+	// instead of failing them immediately (optional)
+	RequestQueue *RequestQueueConfig `yaml:"request_queue,omitempty"`
+
+	// Zone the proxy runs in; enables zone-aware routing to backends in the same zone
+	Zone string `yaml:"zone,omitempty"`
+
 	// Backends configuration
 	Backends []Backend `yaml:"backends"`
 
+	// BackendsFile is a YAML file of additional backends, reloaded when it changes (optional)
+	// A relative path is relative to the directory of the configuration file.
+	BackendsFile string `yaml:"backends_file,omitempty"`
+
+	// Discovery adds backends found by service discovery (optional)
+	Discovery *DiscoveryConfig `yaml:"discovery,omitempty"`
+
 	// LoadBalancer configuration
 	LoadBalancer LoadBalancerConfig `yaml:"load_balancer"`
 
@@ -40,6 +103,9 @@ type Config struct {
 	// Metrics configuration
 	Metrics MetricsConfig `yaml:"metrics"`
 
+	// Admin API configuration (optional)
+	Admin *AdminConfig `yaml:"admin,omitempty"`
+
 	// Security configuration
 	Security *SecurityConfig `yaml:"security,omitempty"`
 
@@ -54,6 +120,15 @@ type Config struct {
 
 	// Logging configuration (Phase 6)
 	Logging *LoggingConfig `yaml:"logging,omitempty"`
+
+	// Bandwidth throttling configuration (optional)
+	Bandwidth *BandwidthConfig `yaml:"bandwidth,omitempty"`
+
+	// Buffer sizing configuration (optional)
+	Buffers *BufferConfig `yaml:"buffers,omitempty"`
+
+	// Shutdown configuration (optional)
+	Shutdown *ShutdownConfig `yaml:"shutdown,omitempty"`
 }
 
 // Backend represents a backend server configuration
@@ -69,15 +144,93 @@ type Backend struct {
 
 	// MaxConnections limits concurrent connections to this backend (0 = unlimited)
 	MaxConnections int `yaml:"max_connections"`
+
+	// MaxBandwidth limits traffic to this backend in bytes/sec (overrides bandwidth.per_backend)
+	MaxBandwidth int64 `yaml:"max_bandwidth,omitempty"`
+
+	// Priority tier (default: 0); lower tiers only receive traffic when higher tiers fail over
+	Priority int `yaml:"priority,omitempty"`
+
+	// Backup backends only receive traffic when all primary backends are unhealthy
+	Backup bool `yaml:"backup,omitempty"`
+
+	// Zone the backend runs in, used for zone-aware routing
+	Zone string `yaml:"zone,omitempty"`
+
+	// Labels are arbitrary metadata used by route selectors (e.g., {"tier": "premium"})
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// CircuitBreaker overrides the global circuit breaker thresholds for this backend
+	CircuitBreaker *BackendCircuitBreakerConfig `yaml:"circuit_breaker,omitempty"`
+}
+
+// BackendCircuitBreakerConfig represents per-backend circuit breaker thresholds
+// Unset values use resilience.circuit_breaker
+type BackendCircuitBreakerConfig struct {
+	// MaxFailures before opening the circuit
+	MaxFailures int `yaml:"max_failures,omitempty"`
+
+	// Timeout before attempting recovery (half-open state)
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// MaxConcurrentRequests in half-open state
+	MaxConcurrentRequests int `yaml:"max_concurrent_requests,omitempty"`
 }
 
 // LoadBalancerConfig represents load balancer settings
 type LoadBalancerConfig struct {
-	// Algorithm: "round-robin", "least-connections", "consistent-hash", "weighted-round-robin"
+	// Algorithm: "round-robin", "least-connections", "consistent-hash", "weighted-round-robin", "peak-ewma",
+	// or the name of a custom algorithm registered with lb.Register
 	Algorithm string `yaml:"algorithm"`
 
-	// HashKey for consistent hashing (e.g., "source-ip", "header:X-User-ID")
+	// Params are settings passed to custom algorithms
+	Params map[string]string `yaml:"params,omitempty"`
+
+	// HashKey for consistent hashing (e.g., "source-ip", "header:X-User-ID", "query:session_id")
 	HashKey string `yaml:"hash_key,omitempty"`
+
+	// VirtualNodes is the number of ring positions per unit of backend weight for
+	// consistent hashing (default: 150); more nodes give smoother distribution at higher memory cost
+	VirtualNodes int `yaml:"virtual_nodes,omitempty"`
+
+	// LoadFactor caps each backend at this multiple of the average load for
+	// bounded-consistent-hash (default: 1.25); lower values reduce skew, higher values improve stickiness
+	LoadFactor float64 `yaml:"load_factor,omitempty"`
+
+	// FailoverThreshold is the healthy fraction (0.0-1.0) a priority tier needs before
+	// traffic fails over to the next tier (default: 0, fail over only when no backend is healthy)
+	FailoverThreshold float64 `yaml:"failover_threshold,omitempty"`
+
+	// ZoneSpillover is the percentage (0-100) of requests sent to other zones when zone-aware
+	// routing is enabled by setting the top-level zone (default: 0)
+	ZoneSpillover int `yaml:"zone_spillover,omitempty"`
+
+	// AdaptiveWeights adjusts backend weights based on observed latency and errors
+	AdaptiveWeights *AdaptiveWeightsConfig `yaml:"adaptive_weights,omitempty"`
+
+	// Subset limits this instance to a deterministic subset of the backends
+	Subset *SubsetConfig `yaml:"subset,omitempty"`
+}
+
+// SubsetConfig represents deterministic backend subsetting settings
+type SubsetConfig struct {
+	// Size is the number of backends each instance uses (0 = all backends)
+	Size int `yaml:"size"`
+
+	// InstanceID identifies this instance when picking its subset (default: hostname)
+	InstanceID string `yaml:"instance_id,omitempty"`
+}
+
+// AdaptiveWeightsConfig represents latency-adaptive weight settings
+type AdaptiveWeightsConfig struct {
+	// Enabled enables periodic weight adjustment
+	Enabled bool `yaml:"enabled"`
+
+	// Interval between adjustments (default: 10s)
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// MinWeightFactor is the lowest fraction of its configured weight a backend can drop to (default: 0.1)
+	MinWeightFactor float64 `yaml:"min_weight_factor,omitempty"`
 }
 
 // TLSConfig represents TLS/SSL configuration
@@ -94,6 +247,10 @@ type TLSConfig struct {
 	// KeyFile path to private key file (deprecated - use Certificates instead)
 	KeyFile string `yaml:"key_file,omitempty"`
 
+	// AutoSelfSigned serves a generated self-signed certificate when no certificate
+	// files are configured (for development and testing only)
+	AutoSelfSigned bool `yaml:"auto_self_signed,omitempty"`
+
 	// MinVersion minimum TLS version (e.g., "1.0", "1.1", "1.2", "1.3")
 	MinVersion string `yaml:"min_version,omitempty"`
 
@@ -116,6 +273,12 @@ type TLSConfig struct {
 	// ClientCAFile path to client CA certificate file for client authentication
 	ClientCAFile string `yaml:"client_ca_file,omitempty"`
 
+	// CRLFile path to a PEM or DER certificate revocation list checked against client certificates
+	CRLFile string `yaml:"crl_file,omitempty"`
+
+	// CRLRefreshInterval is how often crl_file is reloaded (0 = never)
+	CRLRefreshInterval time.Duration `yaml:"crl_refresh_interval,omitempty"`
+
 	// ALPN protocols (e.g., ["h2", "http/1.1"])
 	ALPNProtocols []string `yaml:"alpn_protocols,omitempty"`
 
@@ -124,6 +287,27 @@ type TLSConfig struct {
 
 	// SNI configuration
 	SNI *SNIConfig `yaml:"sni,omitempty"`
+
+	// ALPN configuration
+	ALPN *ALPNConfig `yaml:"alpn,omitempty"`
+
+	// SPIFFE workload identity configuration
+	SPIFFE *SPIFFEConfig `yaml:"spiffe,omitempty"`
+
+	// RedirectHTTP runs a plaintext HTTP listener that redirects to HTTPS
+	RedirectHTTP *HTTPRedirectConfig `yaml:"redirect_http,omitempty"`
+}
+
+// HTTPRedirectConfig represents the plaintext HTTP listener redirecting to HTTPS
+type HTTPRedirectConfig struct {
+	// Enabled starts the redirect listener
+	Enabled bool `yaml:"enabled"`
+
+	// Listen address of the redirect listener (default: ":80")
+	Listen string `yaml:"listen,omitempty"`
+
+	// HTTPSPort is the port redirects point to (default: the port of the main listener)
+	HTTPSPort int `yaml:"https_port,omitempty"`
 }
 
 // CertificateConfig represents a single certificate configuration
@@ -139,6 +323,60 @@ type CertificateConfig struct {
 
 	// Default indicates this is the default certificate
 	Default bool `yaml:"default,omitempty"`
+
+	// ClientAuth overrides tls.client_auth for connections served this certificate
+	ClientAuth string `yaml:"client_auth,omitempty"`
+
+	// ClientCAFile overrides tls.client_ca_file for connections served this certificate
+	ClientCAFile string `yaml:"client_ca_file,omitempty"`
+
+	// Vault issues the certificate from a HashiCorp Vault PKI role instead of files
+	Vault *VaultCertificateConfig `yaml:"vault,omitempty"`
+
+	// KubernetesSecret loads the certificate from a kubernetes.io/tls Secret instead of files
+	KubernetesSecret *KubernetesSecretConfig `yaml:"kubernetes_secret,omitempty"`
+
+	// RefreshInterval is how often a vault or kubernetes_secret certificate is fetched again
+	RefreshInterval time.Duration `yaml:"refresh_interval,omitempty"`
+}
+
+// VaultCertificateConfig represents a certificate issued by a Vault PKI secrets engine
+type VaultCertificateConfig struct {
+	// Address of the Vault server (default: $VAULT_ADDR)
+	Address string `yaml:"address,omitempty"`
+
+	// Token authenticates to Vault (default: $VAULT_TOKEN)
+	Token string `yaml:"token,omitempty"`
+
+	// TokenFile is read for the token on every request, for tokens rotated by an agent
+	TokenFile string `yaml:"token_file,omitempty"`
+
+	// Mount is the path the PKI secrets engine is mounted at
+	Mount string `yaml:"mount,omitempty"`
+
+	// Role is the PKI role to issue the certificate from
+	Role string `yaml:"role"`
+
+	// CommonName of the issued certificate
+	CommonName string `yaml:"common_name"`
+
+	// AltNames are additional DNS names of the issued certificate
+	AltNames []string `yaml:"alt_names,omitempty"`
+
+	// TTL of the issued certificate (default: the role's TTL)
+	TTL time.Duration `yaml:"ttl,omitempty"`
+
+	// CAFile path to the CA certificate that signs the Vault server's certificate
+	CAFile string `yaml:"ca_file,omitempty"`
+}
+
+// KubernetesSecretConfig represents a reference to a kubernetes.io/tls Secret
+type KubernetesSecretConfig struct {
+	// Namespace of the Secret (default: the pod's namespace)
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Name of the Secret
+	Name string `yaml:"name"`
 }
 
 // BackendTLSConfig represents TLS configuration for backend connections
@@ -165,6 +403,25 @@ type SNIConfig struct {
 	Routes map[string][]string `yaml:"routes,omitempty"`
 }
 
+// SPIFFEConfig represents SPIFFE Workload API configuration
+type SPIFFEConfig struct {
+	// Enabled fetches X.509 SVIDs from the Workload API to serve as the listener's
+	// certificate and to present to backends when tls.backend is enabled
+	Enabled bool `yaml:"enabled"`
+
+	// SocketPath of the Workload API (default: $SPIFFE_ENDPOINT_SOCKET)
+	SocketPath string `yaml:"socket_path,omitempty"`
+
+	// BackendIDs are the SPIFFE IDs backends may present (empty = any ID trusted by the bundle)
+	BackendIDs []string `yaml:"backend_ids,omitempty"`
+}
+
+// ALPNConfig represents ALPN protocol routing configuration
+type ALPNConfig struct {
+	// Routes maps negotiated ALPN protocols (e.g. "h2") to backend names
+	Routes map[string][]string `yaml:"routes,omitempty"`
+}
+
 // SecurityConfig represents security configuration
 type SecurityConfig struct {
 	// RateLimit configuration
@@ -175,6 +432,59 @@ type SecurityConfig struct {
 
 	// IPBlocklist configuration
 	IPBlocklist *IPBlocklistConfig `yaml:"ip_blocklist,omitempty"`
+
+	// BlockedJA3 lists JA3 fingerprint hashes whose TLS handshakes are rejected
+	BlockedJA3 []string `yaml:"blocked_ja3,omitempty"`
+
+	// URLFilter configuration (HTTP mode)
+	URLFilter *URLFilterConfig `yaml:"url_filter,omitempty"`
+
+	// AutoBan temporarily blocks clients that keep triggering suspicious events (optional)
+	AutoBan *AutoBanConfig `yaml:"auto_ban,omitempty"`
+
+	// TrustedProxies holds the IPs and CIDR ranges of proxies in front of Balance whose
+	// X-Forwarded-For and X-Real-IP headers identify the client (HTTP mode). Requests
+	// from other peers are identified by their connection's address (default: none).
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty"`
+}
+
+// AutoBanConfig represents reactive banning, in the manner of fail2ban
+// A client causing threshold counted events within find_time is blocked for ban_time.
+// Every further ban doubles the duration up to max_ban_time, and a client's ban count
+// drops by one for every max_ban_time it goes without a ban.
+type AutoBanConfig struct {
+	// Enabled enables reactive banning
+	Enabled bool `yaml:"enabled"`
+
+	// Events counted towards a ban: "unauthorized" (backend responded 401), "waf" (rejected
+	// by the URL filter), "tls_handshake_failure" and "rate_limited" (default: all)
+	Events []string `yaml:"events,omitempty"`
+
+	// Threshold is the number of events within find_time that gets a client banned (default: 10)
+	Threshold int `yaml:"threshold,omitempty"`
+
+	// FindTime is how long an event counts towards a ban (default: 10m)
+	FindTime time.Duration `yaml:"find_time,omitempty"`
+
+	// BanTime is the duration of a client's first ban (default: 10m)
+	BanTime time.Duration `yaml:"ban_time,omitempty"`
+
+	// MaxBanTime caps the duration of repeated bans (default: 24h)
+	MaxBanTime time.Duration `yaml:"max_ban_time,omitempty"`
+
+	// Allowlist holds IPs and CIDR ranges that are never banned, e.g. health checkers
+	Allowlist []string `yaml:"allowlist,omitempty"`
+}
+
+// URLFilterConfig represents request URL filtering, applied before routing
+type URLFilterConfig struct {
+	// Enabled rejects paths with traversal sequences (including encoded ones such as
+	// %2e%2e) or null bytes with 400 Bad Request
+	Enabled bool `yaml:"enabled"`
+
+	// DenyPatterns are regular expressions matched against the decoded, normalized
+	// path and query; matching requests are rejected with 403 Forbidden
+	DenyPatterns []string `yaml:"deny_patterns,omitempty"`
 }
 
 // RateLimitConfig represents rate limiting configuration
@@ -185,6 +495,36 @@ type RateLimitConfig struct {
 	// Type: "token-bucket" or "sliding-window"
 	Type string `yaml:"type"`
 
+	// Key identifies whom HTTP requests are rate limited for: "client-ip" (default),
+	// "header:<name>", "cookie:<name>", "jwt:<claim>" or "api-key:<header>"
+	// Requests without the key fall back to their client IP: the connection's address,
+	// or the forwarded one for requests from trusted_proxies.
+	Key string `yaml:"key,omitempty"`
+
+	// RequestsPerSecond for token bucket rate limiting
+	RequestsPerSecond float64 `yaml:"requests_per_second,omitempty"`
+
+	// BurstSize for token bucket (max tokens)
+	BurstSize int64 `yaml:"burst_size,omitempty"`
+
+	// WindowSize for sliding window rate limiting (e.g., "1m", "1h")
+	WindowSize string `yaml:"window_size,omitempty"`
+
+	// MaxRequests for sliding window rate limiting
+	MaxRequests int64 `yaml:"max_requests,omitempty"`
+
+	// TierKey selects the tier of an HTTP request: "header:<name>" or "jwt:<claim>"
+	// Requests whose value names no tier get the limits above.
+	TierKey string `yaml:"tier_key,omitempty"`
+
+	// Tiers are named rate limits (e.g. free, pro, internal) replacing the limits above
+	// for requests of that tier
+	Tiers map[string]*RateLimitTierConfig `yaml:"tiers,omitempty"`
+}
+
+// RateLimitTierConfig represents the rate limit of a tier; its settings follow the
+// rate limit's type
+type RateLimitTierConfig struct {
 	// RequestsPerSecond for token bucket rate limiting
 	RequestsPerSecond float64 `yaml:"requests_per_second,omitempty"`
 
@@ -206,6 +546,11 @@ type ConnectionProtectionConfig struct {
 	// MaxConnectionRate limits new connections per second per IP
 	MaxConnectionRate float64 `yaml:"max_connection_rate"`
 
+	// MaxConcurrentRequestsPerIP limits in-flight HTTP requests per client IP, counting
+	// each HTTP/2 stream (0 = unlimited). The IP is the connection's address unless it
+	// is one of the trusted_proxies.
+	MaxConcurrentRequestsPerIP int `yaml:"max_concurrent_requests_per_ip,omitempty"`
+
 	// ReadTimeout for reading request headers (Slowloris protection)
 	ReadTimeout string `yaml:"read_timeout"`
 
@@ -233,6 +578,9 @@ type HealthCheckConfig struct {
 	// Interval between health checks
 	Interval time.Duration `yaml:"interval"`
 
+	// UnhealthyInterval between health checks of unhealthy backends (default: interval)
+	UnhealthyInterval time.Duration `yaml:"unhealthy_interval,omitempty"`
+
 	// Timeout for health check requests
 	Timeout time.Duration `yaml:"timeout"`
 
@@ -248,10 +596,31 @@ type HealthCheckConfig struct {
 	// Path for HTTP health checks (e.g., "/health")
 	Path string `yaml:"path,omitempty"`
 
+	// TLS options for HTTPS health checks
+	TLS *HealthCheckTLSConfig `yaml:"tls,omitempty"`
+
 	// PassiveChecks enables passive health checking
 	PassiveChecks *PassiveHealthCheckConfig `yaml:"passive_checks,omitempty"`
 }
 
+// HealthCheckTLSConfig represents TLS settings for HTTPS health checks
+type HealthCheckTLSConfig struct {
+	// CAFile path to CA bundle used to verify backend certificates (default: system roots)
+	CAFile string `yaml:"ca_file,omitempty"`
+
+	// ServerName overrides the SNI and verified hostname (default: backend host)
+	ServerName string `yaml:"server_name,omitempty"`
+
+	// ClientCertFile path to client certificate file for mTLS
+	ClientCertFile string `yaml:"client_cert_file,omitempty"`
+
+	// ClientKeyFile path to client private key file for mTLS
+	ClientKeyFile string `yaml:"client_key_file,omitempty"`
+
+	// InsecureSkipVerify disables backend certificate verification (for testing only)
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
 // PassiveHealthCheckConfig represents passive health check settings
 type PassiveHealthCheckConfig struct {
 	// Enabled enables passive health checking
@@ -274,6 +643,93 @@ type ResilienceConfig struct {
 
 	// Retry configuration
 	Retry *RetryConfig `yaml:"retry,omitempty"`
+
+	// RetryBudget caps retries across all requests (applies whenever retries are enabled)
+	RetryBudget *RetryBudgetConfig `yaml:"retry_budget,omitempty"`
+
+	// Hedging configuration (HTTP mode only)
+	Hedging *HedgingConfig `yaml:"hedging,omitempty"`
+
+	// LoadShedding rejects requests while the proxy itself is saturated (HTTP mode only)
+	LoadShedding *LoadSheddingConfig `yaml:"load_shedding,omitempty"`
+}
+
+// validate checks the retry_on conditions and per-try timeout
+func (r *RetryConfig) validate() error {
+	if r.PerTryTimeout < 0 {
+		return fmt.Errorf("per_try_timeout must be non-negative")
+	}
+	for _, cond := range r.RetryOn {
+		switch cond {
+		case "connect-failure", "refused", "reset", "5xx", "idempotent-only":
+			continue
+		}
+		if code, err := strconv.Atoi(cond); err != nil || code < 400 || code > 599 {
+			return fmt.Errorf("invalid retry_on condition: %s", cond)
+		}
+	}
+	return nil
+}
+
+// RetryBudgetConfig represents the global retry budget
+type RetryBudgetConfig struct {
+	// Percent is the maximum retries as a percentage of requests
+	Percent float64 `yaml:"percent,omitempty"`
+
+	// MinRetriesPerSecond is always allowed regardless of the percentage
+	MinRetriesPerSecond int `yaml:"min_retries_per_second,omitempty"`
+
+	// Window over which requests and retries are counted
+	Window time.Duration `yaml:"window,omitempty"`
+}
+
+// setDefaults fills unset retry budget settings
+func (b *RetryBudgetConfig) setDefaults() {
+	if b.Percent == 0 {
+		b.Percent = 20
+	}
+	if b.MinRetriesPerSecond == 0 {
+		b.MinRetriesPerSecond = 10
+	}
+	if b.Window == 0 {
+		b.Window = 10 * time.Second
+	}
+}
+
+// HedgingConfig represents request hedging configuration
+type HedgingConfig struct {
+	// Enabled enables hedged requests
+	Enabled bool `yaml:"enabled"`
+
+	// Delay before a hedged request is sent (0 = p95 of recent response times)
+	Delay time.Duration `yaml:"delay,omitempty"`
+
+	// MinDelay is the lower bound for the p95-derived delay
+	MinDelay time.Duration `yaml:"min_delay,omitempty"`
+
+	// BudgetPercent caps hedged requests as a percentage of hedge-eligible requests
+	BudgetPercent float64 `yaml:"budget_percent,omitempty"`
+}
+
+// LoadSheddingConfig represents load shedding configuration
+type LoadSheddingConfig struct {
+	// Enabled enables load shedding
+	Enabled bool `yaml:"enabled"`
+
+	// Interval is how often the proxy's load is measured (default: 250ms)
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// MaxLag is the control loop scheduling delay above which the proxy is saturated (default: 50ms)
+	MaxLag time.Duration `yaml:"max_lag,omitempty"`
+
+	// MaxCPU is the CPU utilization (0-1) above which the proxy is saturated (default: 0.9)
+	MaxCPU float64 `yaml:"max_cpu,omitempty"`
+
+	// Step is how much the shed fraction changes per interval (default: 0.05)
+	Step float64 `yaml:"step,omitempty"`
+
+	// MaxFraction caps the fraction of requests shed (default: 0.9)
+	MaxFraction float64 `yaml:"max_fraction,omitempty"`
 }
 
 // CircuitBreakerConfig represents circuit breaker settings
@@ -310,6 +766,59 @@ type RetryConfig struct {
 
 	// Jitter adds randomness to backoff (0.0-1.0)
 	Jitter float64 `yaml:"jitter,omitempty"`
+
+	// RetryOn lists the failures that are retried: connect-failure, refused, reset,
+	// 5xx, individual status codes (e.g. "503") and idempotent-only (empty = any connection error)
+	RetryOn []string `yaml:"retry_on,omitempty"`
+
+	// RetryNonIdempotent allows retrying non-idempotent methods after the request reached a backend
+	RetryNonIdempotent bool `yaml:"retry_non_idempotent,omitempty"`
+
+	// PerTryTimeout bounds each attempt until response headers arrive (0 = no per-attempt limit)
+	PerTryTimeout time.Duration `yaml:"per_try_timeout,omitempty"`
+}
+
+// setDefaults fills unset retry settings from parent (if any), then from the built-in defaults
+func (r *RetryConfig) setDefaults(parent *RetryConfig) {
+	if parent != nil {
+		if r.MaxAttempts == 0 {
+			r.MaxAttempts = parent.MaxAttempts
+		}
+		if r.InitialDelay == 0 {
+			r.InitialDelay = parent.InitialDelay
+		}
+		if r.MaxDelay == 0 {
+			r.MaxDelay = parent.MaxDelay
+		}
+		if r.Multiplier == 0 {
+			r.Multiplier = parent.Multiplier
+		}
+		if r.Jitter == 0 {
+			r.Jitter = parent.Jitter
+		}
+		if r.RetryOn == nil {
+			r.RetryOn = parent.RetryOn
+		}
+		if r.PerTryTimeout == 0 {
+			r.PerTryTimeout = parent.PerTryTimeout
+		}
+	}
+
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = 3
+	}
+	if r.InitialDelay == 0 {
+		r.InitialDelay = 100 * time.Millisecond
+	}
+	if r.MaxDelay == 0 {
+		r.MaxDelay = 10 * time.Second
+	}
+	if r.Multiplier == 0 {
+		r.Multiplier = 2.0
+	}
+	if r.Jitter == 0 {
+		r.Jitter = 0.1
+	}
 }
 
 // TimeoutConfig represents timeout settings
@@ -325,6 +834,35 @@ type TimeoutConfig struct {
 
 	// Idle timeout for idle connections
 	Idle time.Duration `yaml:"idle"`
+
+	// TCPIdle closes TCP streams with no traffic in either direction for this long
+	// (0 = never, so long-lived streams such as database or SSH sessions stay open)
+	TCPIdle time.Duration `yaml:"tcp_idle,omitempty"`
+
+	// Request is the total time allowed for an HTTP request, including retries (0 = no limit)
+	Request time.Duration `yaml:"request,omitempty"`
+
+	// Propagate sends the remaining request time to backends in X-Request-Timeout and grpc-timeout headers
+	Propagate bool `yaml:"propagate,omitempty"`
+
+	// KeepAlive configures TCP keepalive on client and backend connections (optional)
+	KeepAlive *KeepAliveConfig `yaml:"keepalive,omitempty"`
+}
+
+// KeepAliveConfig represents TCP keepalive configuration
+// Zero durations and counts use the operating system defaults
+type KeepAliveConfig struct {
+	// Enabled enables TCP keepalive probes
+	Enabled bool `yaml:"enabled"`
+
+	// Idle is how long a connection must be idle before the first probe is sent
+	Idle time.Duration `yaml:"idle,omitempty"`
+
+	// Interval between keepalive probes
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// Count is the number of unacknowledged probes before the connection is dropped
+	Count int `yaml:"count,omitempty"`
 }
 
 // MetricsConfig represents metrics configuration
@@ -337,83 +875,378 @@ type MetricsConfig struct {
 
 	// Path for metrics endpoint (default: "/metrics")
 	Path string `yaml:"path"`
-}
 
-// HTTPConfig represents HTTP-specific configuration
-type HTTPConfig struct {
-	// Routes for HTTP routing (optional, if empty uses default backend pool)
-	Routes []Route `yaml:"routes,omitempty"`
+	// ClientLabel controls per-client metric labels: ip, prefix (/24), hash, or drop (default: "ip")
+	ClientLabel string `yaml:"client_label,omitempty"`
 
-	// EnableWebSocket enables WebSocket proxying
-	EnableWebSocket bool `yaml:"enable_websocket"`
+	// UpstreamTiming records the DNS, connect, TLS handshake, time-to-first-byte and
+	// total times of requests to backends as histograms
+	UpstreamTiming bool `yaml:"upstream_timing,omitempty"`
+}
 
-	// EnableHTTP2 enables HTTP/2 support
-	EnableHTTP2 bool `yaml:"enable_http2"`
+// BandwidthConfig represents bandwidth throttling configuration for TCP and WebSocket proxying
+// All limits are in bytes/sec; 0 disables the limit
+type BandwidthConfig struct {
+	// Global limits total proxied traffic
+	Global int64 `yaml:"global,omitempty"`
 
-	// MaxIdleConnsPerHost limits idle connections per backend
-	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`
+	// PerBackend limits traffic to each backend
+	PerBackend int64 `yaml:"per_backend,omitempty"`
 
-	// IdleConnTimeout is the idle connection timeout
-	IdleConnTimeout time.Duration `yaml:"idle_conn_timeout"`
-}
+	// PerClientIP limits traffic from each client IP
+	PerClientIP int64 `yaml:"per_client_ip,omitempty"`
 
-// Route represents an HTTP routing rule
-type Route struct {
-	// Name of the route
-	Name string `yaml:"name"`
+	// Direction is the traffic the limits apply to: "both", "upload" (client to backend)
+	// or "download" (backend to client) (default: "both")
+	Direction string `yaml:"direction,omitempty"`
+}
 
-	// Host pattern for host-based routing (e.g., "api.example.com")
-	Host string `yaml:"host,omitempty"`
+// BufferConfig represents buffer sizing configuration
+type BufferConfig struct {
+	// CopyBufferSize is the size of pooled buffers used when copying TCP and WebSocket streams (default: 32KB)
+	CopyBufferSize int `yaml:"copy_buffer_size,omitempty"`
 
-	// PathPrefix for path-based routing (e.g., "/api/")
-	PathPrefix string `yaml:"path_prefix,omitempty"`
+	// ListenerReadBuffer is the socket receive buffer for accepted client connections in bytes (0 = OS default)
+	ListenerReadBuffer int `yaml:"listener_read_buffer,omitempty"`
 
-	// Headers for header-based routing (e.g., {"X-API-Key": "secret"})
-	Headers map[string]string `yaml:"headers,omitempty"`
+	// ListenerWriteBuffer is the socket send buffer for accepted client connections in bytes (0 = OS default)
+	ListenerWriteBuffer int `yaml:"listener_write_buffer,omitempty"`
 
-	// Backends for this route (backend names)
-	Backends []string `yaml:"backends"`
+	// TransportReadBufferSize is the HTTP transport read buffer size (default: 4KB)
+	TransportReadBufferSize int `yaml:"transport_read_buffer_size,omitempty"`
 
-	// Priority for route matching (higher = higher priority)
-	Priority int `yaml:"priority"`
+	// TransportWriteBufferSize is the HTTP transport write buffer size (default: 4KB)
+	TransportWriteBufferSize int `yaml:"transport_write_buffer_size,omitempty"`
 }
 
-// ConnectionPoolConfig represents connection pooling configuration (Phase 6)
-type ConnectionPoolConfig struct {
-	// Enabled enables connection pooling
-	Enabled bool `yaml:"enabled"`
+// RequestQueueConfig represents request queueing settings
+type RequestQueueConfig struct {
+	// MaxDepth is how many requests may wait at once; more are rejected (default: 100)
+	MaxDepth int `yaml:"max_depth,omitempty"`
 
-	// MaxSize maximum number of connections per backend
-	MaxSize int `yaml:"max_size"`
+	// Timeout is how long a request waits for a backend before being rejected (default: 5s)
+	Timeout time.Duration `yaml:"timeout,omitempty"`
 
-	// MaxIdleTime maximum time a connection can be idle
-	MaxIdleTime time.Duration `yaml:"max_idle_time"`
+	// RetryAfter is sent in the Retry-After header of rejected requests (default: 1s)
+	RetryAfter time.Duration `yaml:"retry_after,omitempty"`
 }
 
-// TransformConfig represents request/response transformation configuration (Phase 6)
-type TransformConfig struct {
-	// RequestHeaders to add/set/remove
-	RequestHeaders []HeaderTransform `yaml:"request_headers,omitempty"`
+// ConnectionWorkersConfig represents the goroutine pool handling TCP connections
+// Each worker serves one connection at a time for the connection's lifetime.
+type ConnectionWorkersConfig struct {
+	// MaxWorkers is how many connections are served at once (default: 10000)
+	MaxWorkers int `yaml:"max_workers,omitempty"`
 
-	// ResponseHeaders to add/set/remove
-	ResponseHeaders []HeaderTransform `yaml:"response_headers,omitempty"`
+	// QueueSize is how many accepted connections may wait for a free worker (default: 0)
+	QueueSize int `yaml:"queue_size,omitempty"`
 
-	// StripPrefix removes prefix from request path
-	StripPrefix string `yaml:"strip_prefix,omitempty"`
+	// Overflow is what happens when every worker is busy and the queue is full: "reject"
+	// closes new connections, "block" stops accepting until a worker is free, leaving
+	// clients in the listen backlog (default: "reject")
+	Overflow string `yaml:"overflow,omitempty"`
 
-	// AddPrefix adds prefix to request path
-	AddPrefix string `yaml:"add_prefix,omitempty"`
+	// IdleTimeout is how long an idle worker is kept for the next connection (default: 10s)
+	IdleTimeout time.Duration `yaml:"idle_timeout,omitempty"`
 }
 
-// HeaderTransform represents a header transformation
-type HeaderTransform struct {
-	// Action: "add", "set", or "remove"
-	Action string `yaml:"action"`
+// ShutdownConfig represents graceful shutdown settings
+type ShutdownConfig struct {
+	// DrainTimeout is how long active connections may finish before being force-closed (default: 30s)
+	DrainTimeout time.Duration `yaml:"drain_timeout,omitempty"`
 
-	// Name of the header
-	Name string `yaml:"name"`
+	// AnnouncePeriod keeps serving with /ready failing for this long before listeners close (0 = none)
+	AnnouncePeriod time.Duration `yaml:"announce_period,omitempty"`
 
-	// Value of the header (not used for "remove")
+	// CloseConnections sends Connection: close on HTTP responses during the announce period
+	CloseConnections bool `yaml:"close_connections,omitempty"`
+}
+
+// DiscoveryConfig represents service discovery settings
+// Discovered backends are added to and removed from the pool alongside the static backends.
+type DiscoveryConfig struct {
+	// SRV discovers backends from DNS SRV records (optional)
+	SRV *SRVDiscoveryConfig `yaml:"srv,omitempty"`
+
+	// Etcd discovers backends registered under a key prefix in etcd (optional)
+	Etcd *EtcdDiscoveryConfig `yaml:"etcd,omitempty"`
+
+	// Eureka discovers backends from application instances in a Eureka registry (optional)
+	Eureka *EurekaDiscoveryConfig `yaml:"eureka,omitempty"`
+
+	// Prune removes discovered backends that stay unhealthy from the pool (optional)
+	Prune *DiscoveryPruneConfig `yaml:"prune,omitempty"`
+}
+
+// DiscoveryPruneConfig represents the policy for removing failing discovered backends
+type DiscoveryPruneConfig struct {
+	// After is how long a backend must fail health checks before it is removed (default: 5m)
+	After time.Duration `yaml:"after,omitempty"`
+
+	// Quarantine keeps a removed backend out even if still announced (0 = until re-announced)
+	Quarantine time.Duration `yaml:"quarantine,omitempty"`
+}
+
+// SRVDiscoveryConfig represents DNS SRV record discovery settings
+type SRVDiscoveryConfig struct {
+	// Name is the SRV record to query (e.g., "_http._tcp.api.service.consul")
+	Name string `yaml:"name"`
+
+	// Server is the DNS server address (default: the first nameserver in /etc/resolv.conf)
+	Server string `yaml:"server,omitempty"`
+
+	// MinRefreshInterval is the shortest time between queries, even with lower TTLs (default: 5s)
+	MinRefreshInterval time.Duration `yaml:"min_refresh_interval,omitempty"`
+
+	// MaxRefreshInterval is the longest time between queries, even with higher TTLs (default: 5m)
+	MaxRefreshInterval time.Duration `yaml:"max_refresh_interval,omitempty"`
+}
+
+// EtcdDiscoveryConfig represents etcd discovery settings
+type EtcdDiscoveryConfig struct {
+	// Endpoints are the etcd client URLs (e.g., "http://127.0.0.1:2379")
+	Endpoints []string `yaml:"endpoints"`
+
+	// Prefix is the key prefix backends are registered under (default: "/balance/backends/")
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// Username and Password enable etcd authentication
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+
+	// CAFile verifies HTTPS endpoints in addition to the system roots
+	CAFile string `yaml:"ca_file,omitempty"`
+
+	// CertFile and KeyFile are the client certificate for etcd client auth
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+}
+
+// EurekaDiscoveryConfig represents Eureka discovery settings
+type EurekaDiscoveryConfig struct {
+	// ServiceURLs are the Eureka server URLs (e.g., "http://eureka:8761/eureka")
+	ServiceURLs []string `yaml:"service_urls"`
+
+	// Applications are the application names whose instances become backends
+	Applications []string `yaml:"applications"`
+
+	// RefreshInterval is how often the registry is polled (default: 30s)
+	RefreshInterval time.Duration `yaml:"refresh_interval,omitempty"`
+
+	// CAFile verifies HTTPS service URLs in addition to the system roots
+	CAFile string `yaml:"ca_file,omitempty"`
+}
+
+// AdminConfig represents admin API configuration
+type AdminConfig struct {
+	// Enabled enables the admin HTTP API
+	Enabled bool `yaml:"enabled"`
+
+	// Listen address for the admin API (e.g., ":9090")
+	Listen string `yaml:"listen"`
+
+	// TLS serves the admin API over HTTPS, optionally verifying client certificates
+	TLS *AdminTLSConfig `yaml:"tls,omitempty"`
+
+	// Auth requires clients to authenticate and limits what they can do by role (optional)
+	Auth *AdminAuthConfig `yaml:"auth,omitempty"`
+}
+
+// AdminTLSConfig represents HTTPS configuration for the admin API
+type AdminTLSConfig struct {
+	// CertFile is the server certificate
+	CertFile string `yaml:"cert_file"`
+
+	// KeyFile is the server certificate's private key
+	KeyFile string `yaml:"key_file"`
+
+	// ClientCAFile verifies client certificates (mTLS); without auth every client
+	// must present a valid certificate and gets the operator role
+	ClientCAFile string `yaml:"client_ca_file,omitempty"`
+}
+
+// AdminAuthConfig represents authentication and role-based access for the admin API
+// Health and readiness probes are always served without authentication.
+type AdminAuthConfig struct {
+	// Tokens are static bearer tokens, sent as "Authorization: Bearer <token>"
+	Tokens []AdminToken `yaml:"tokens,omitempty"`
+
+	// Clients grant roles to client certificates by subject common name
+	// (requires admin.tls.client_ca_file)
+	Clients []AdminClient `yaml:"clients,omitempty"`
+}
+
+// AdminToken represents a bearer token accepted by the admin API
+type AdminToken struct {
+	// Name identifies the client in the audit log
+	Name string `yaml:"name"`
+
+	// Token is the secret itself
+	Token string `yaml:"token,omitempty"`
+
+	// TokenFile is read for the token at startup instead, e.g. a mounted secret
+	TokenFile string `yaml:"token_file,omitempty"`
+
+	// Role is "read-only" (GET requests only) or "operator" (default: "read-only")
+	Role string `yaml:"role,omitempty"`
+}
+
+// AdminClient represents a client certificate accepted by the admin API
+type AdminClient struct {
+	// CommonName is the subject common name of the certificate
+	CommonName string `yaml:"common_name"`
+
+	// Role is "read-only" (GET requests only) or "operator" (default: "read-only")
+	Role string `yaml:"role,omitempty"`
+}
+
+// HTTPConfig represents HTTP-specific configuration
+type HTTPConfig struct {
+	// Routes for HTTP routing (optional, if empty uses default backend pool)
+	Routes []Route `yaml:"routes,omitempty"`
+
+	// NoRouteMatch is what happens when no route matches: "pool" (use all backends), "404" or "503"
+	NoRouteMatch string `yaml:"no_route_match,omitempty"`
+
+	// EnableWebSocket enables WebSocket proxying
+	EnableWebSocket bool `yaml:"enable_websocket"`
+
+	// EnableHTTP2 enables HTTP/2 support
+	EnableHTTP2 bool `yaml:"enable_http2"`
+
+	// MaxIdleConnsPerHost limits idle connections per backend
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`
+
+	// IdleConnTimeout is the idle connection timeout
+	IdleConnTimeout time.Duration `yaml:"idle_conn_timeout"`
+
+	// ACMEChallengeBackend is the backend that receives ACME HTTP-01 challenge requests
+	// (/.well-known/acme-challenge/), bypassing routes (optional)
+	ACMEChallengeBackend string `yaml:"acme_challenge_backend,omitempty"`
+
+	// PriorityHeader is a request header carrying the priority class ("low", "normal" or
+	// "high"), overriding the route's priority_class (e.g., "X-Priority")
+	PriorityHeader string `yaml:"priority_header,omitempty"`
+}
+
+// Route represents an HTTP routing rule
+type Route struct {
+	// Name of the route
+	Name string `yaml:"name"`
+
+	// Host pattern for host-based routing (e.g., "api.example.com")
+	Host string `yaml:"host,omitempty"`
+
+	// PathPrefix for path-based routing (e.g., "/api/")
+	PathPrefix string `yaml:"path_prefix,omitempty"`
+
+	// Headers for header-based routing (e.g., {"X-API-Key": "secret"})
+	// Values can also be "*" (present), "~regex", or any of these prefixed with "!" to negate
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// QueryParams for query-parameter routing (e.g., {"version": "2"}); "*" only requires presence
+	QueryParams map[string]string `yaml:"query_params,omitempty"`
+
+	// Backends for this route (backend names)
+	Backends []string `yaml:"backends"`
+
+	// Selector adds the backends whose labels match (e.g., "tier=premium, region=eu")
+	Selector string `yaml:"selector,omitempty"`
+
+	// Overflow backends (backend names) receive the route's requests only while all of
+	// its backends are at max_connections or have an open circuit breaker
+	Overflow []string `yaml:"overflow,omitempty"`
+
+	// Priority for route matching (higher = higher priority)
+	Priority int `yaml:"priority"`
+
+	// Default marks the route used when no other route matches
+	Default bool `yaml:"default,omitempty"`
+
+	// Retry overrides resilience.retry for this route (e.g., enabled: false for unsafe endpoints)
+	Retry *RetryConfig `yaml:"retry,omitempty"`
+
+	// Timeout overrides timeouts.request for this route
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// HostRewrite replaces the Host header sent to backends (e.g., "api.internal")
+	HostRewrite string `yaml:"host_rewrite,omitempty"`
+
+	// PreserveHost keeps the client's Host header (default: true); false sends the backend address
+	PreserveHost *bool `yaml:"preserve_host,omitempty"`
+
+	// StripPrefix removes a prefix from the request path sent to backends (e.g., "/api")
+	StripPrefix string `yaml:"strip_prefix,omitempty"`
+
+	// AddPrefix adds a prefix to the request path sent to backends (applied after StripPrefix)
+	AddPrefix string `yaml:"add_prefix,omitempty"`
+
+	// PriorityClass is "low", "normal" (default) or "high", which decides how the route's
+	// requests fare under load shedding, request queueing and rate limiting
+	PriorityClass string `yaml:"priority_class,omitempty"`
+
+	// TraceSampleRate overrides tracing.sample_rate for this route (e.g., 1.0 for checkout,
+	// 0.001 for health checks)
+	TraceSampleRate *float64 `yaml:"trace_sample_rate,omitempty"`
+}
+
+// ParseLabelSelector parses a comma-separated list of key=value label requirements
+// All requirements must match for a backend to be selected
+func ParseLabelSelector(selector string) (map[string]string, error) {
+	requirements := make(map[string]string)
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(term, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid selector term %q (expected key=value)", term)
+		}
+		requirements[key] = value
+	}
+	if len(requirements) == 0 {
+		return nil, fmt.Errorf("selector %q has no requirements", selector)
+	}
+	return requirements, nil
+}
+
+// ConnectionPoolConfig represents connection pooling configuration (Phase 6)
+type ConnectionPoolConfig struct {
+	// Enabled enables connection pooling
+	Enabled bool `yaml:"enabled"`
+
+	// MaxSize maximum number of connections per backend
+	MaxSize int `yaml:"max_size"`
+
+	// MaxIdleTime maximum time a connection can be idle
+	MaxIdleTime time.Duration `yaml:"max_idle_time"`
+}
+
+// TransformConfig represents request/response transformation configuration (Phase 6)
+type TransformConfig struct {
+	// RequestHeaders to add/set/remove
+	RequestHeaders []HeaderTransform `yaml:"request_headers,omitempty"`
+
+	// ResponseHeaders to add/set/remove
+	ResponseHeaders []HeaderTransform `yaml:"response_headers,omitempty"`
+
+	// Deprecated: StripPrefix has moved to http.routes; setting it here is a validation error
+	StripPrefix string `yaml:"strip_prefix,omitempty"`
+
+	// Deprecated: AddPrefix has moved to http.routes; setting it here is a validation error
+	AddPrefix string `yaml:"add_prefix,omitempty"`
+}
+
+// HeaderTransform represents a header transformation
+type HeaderTransform struct {
+	// Action: "add", "set", or "remove"
+	Action string `yaml:"action"`
+
+	// Name of the header
+	Name string `yaml:"name"`
+
+	// Value of the header (not used for "remove")
 	Value string `yaml:"value,omitempty"`
 }
 
@@ -425,11 +1258,21 @@ type TracingConfig struct {
 	// ServiceName for tracing
 	ServiceName string `yaml:"service_name"`
 
-	// Endpoint for trace collector (e.g., Jaeger)
+	// Exporter selects where spans are sent: "jaeger" (Jaeger collector over HTTP),
+	// "jaeger-agent" (Jaeger agent over UDP) or "zipkin" (Zipkin over HTTP) (default: "jaeger")
+	Exporter string `yaml:"exporter,omitempty"`
+
+	// Endpoint for trace collector: the collector URL for jaeger and zipkin
+	// (e.g., "http://zipkin:9411/api/v2/spans") or the agent's host:port for jaeger-agent
 	Endpoint string `yaml:"endpoint"`
 
 	// SampleRate (0.0-1.0) for sampling traces
 	SampleRate float64 `yaml:"sample_rate"`
+
+	// SpanAttributes adds Balance-specific context to request spans: "route", "backend",
+	// "client_ip" (the client's /24 or /48 network instead of its full address),
+	// "header:<name>" for a request header and "baggage:<key>" for a W3C baggage member
+	SpanAttributes []string `yaml:"span_attributes,omitempty"`
 }
 
 // LoggingConfig represents logging configuration (Phase 6)
@@ -443,8 +1286,49 @@ type LoggingConfig struct {
 	// AddCaller adds caller info to logs
 	AddCaller bool `yaml:"add_caller"`
 
+	// Output is where application logs go: "stdout", "stderr", a file path or a
+	// remote collector such as "syslog+tls://logs.internal" or "tcp-json://logs.internal:5170"
+	// (default: "stderr")
+	Output string `yaml:"output,omitempty"`
+
 	// AccessLog enables HTTP access logging
 	AccessLog bool `yaml:"access_log"`
+
+	// AccessLogOutput is where access logs go, in the same form as Output (default: "stdout")
+	AccessLogOutput string `yaml:"access_log_output,omitempty"`
+
+	// AccessLogSampling logs only a fraction of requests (optional; all are logged without it)
+	AccessLogSampling *AccessLogSamplingConfig `yaml:"access_log_sampling,omitempty"`
+
+	// UpstreamTiming adds the DNS, connect, TLS handshake, time-to-first-byte and
+	// total times of the request to the backend to access log entries
+	UpstreamTiming bool `yaml:"upstream_timing,omitempty"`
+
+	// AuditLog writes security events to a sink of their own (optional)
+	AuditLog *AuditLogConfig `yaml:"audit_log,omitempty"`
+}
+
+// AccessLogSamplingConfig configures which requests are access logged
+// Logging 1% of successful requests while keeping every error and slow request
+// keeps access logs affordable at high request rates.
+type AccessLogSamplingConfig struct {
+	// Rates maps status classes ("1xx" to "5xx") to the fraction (0-1) of their
+	// requests logged; classes not listed are always logged
+	Rates map[string]float64 `yaml:"rates,omitempty"`
+
+	// SlowThreshold always logs requests taking at least this long (0 = disabled)
+	SlowThreshold time.Duration `yaml:"slow_threshold,omitempty"`
+}
+
+// AuditLogConfig configures the security event audit log
+// Blocked and rate-limited clients, failed client authentication, URL filter hits and
+// admin API changes are written as JSON lines.
+type AuditLogConfig struct {
+	// Enabled turns on the audit log
+	Enabled bool `yaml:"enabled"`
+
+	// Output is where audit events go, in the same form as the logging output (default: "stderr")
+	Output string `yaml:"output"`
 }
 
 // Load loads configuration from a YAML file
@@ -454,17 +1338,57 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	return Parse(data, path)
+}
+
+// Parse parses a configuration as if it was loaded from path, so relative paths in it
+// resolve the same way, applies environment overrides and sets defaults
+func Parse(data []byte, path string) (*Config, error) {
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	if cfg.BackendsFile != "" && !filepath.IsAbs(cfg.BackendsFile) {
+		cfg.BackendsFile = filepath.Join(filepath.Dir(path), cfg.BackendsFile)
+	}
+
+	// Environment variables take precedence over the file
+	cfg.applyEnv()
+	if containerMode.Load() {
+		cfg.setContainerDefaults()
+	}
+
 	// Set defaults
 	cfg.setDefaults()
 
 	return &cfg, nil
 }
 
+// RetriesEnabled reports whether retries are enabled globally or for any route
+func (c *Config) RetriesEnabled() bool {
+	if c.Resilience != nil && c.Resilience.Retry != nil && c.Resilience.Retry.Enabled {
+		return true
+	}
+	if c.HTTP != nil {
+		for _, route := range c.HTTP.Routes {
+			if route.Retry != nil && route.Retry.Enabled {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DiscoveryEnabled reports whether backends are added at runtime, by a backends file
+// or a service discovery provider
+func (c *Config) DiscoveryEnabled() bool {
+	if c.BackendsFile != "" {
+		return true
+	}
+	return c.Discovery != nil && (c.Discovery.SRV != nil || c.Discovery.Etcd != nil || c.Discovery.Eureka != nil)
+}
+
 // setDefaults sets default values for optional configuration
 func (c *Config) setDefaults() {
 	// Default mode
@@ -482,6 +1406,24 @@ func (c *Config) setDefaults() {
 		c.LoadBalancer.Algorithm = "round-robin"
 	}
 
+	// Default consistent hashing settings
+	if c.LoadBalancer.VirtualNodes == 0 {
+		c.LoadBalancer.VirtualNodes = lb.DefaultVirtualNodes
+	}
+	if c.LoadBalancer.LoadFactor == 0 {
+		c.LoadBalancer.LoadFactor = lb.DefaultLoadFactor
+	}
+
+	// Default adaptive weight settings
+	if aw := c.LoadBalancer.AdaptiveWeights; aw != nil {
+		if aw.Interval == 0 {
+			aw.Interval = 10 * time.Second
+		}
+		if aw.MinWeightFactor == 0 {
+			aw.MinWeightFactor = 0.1
+		}
+	}
+
 	// Default backend weights
 	for i := range c.Backends {
 		if c.Backends[i].Weight == 0 {
@@ -551,20 +1493,59 @@ func (c *Config) setDefaults() {
 
 		// Retry defaults
 		if c.Resilience.Retry != nil && c.Resilience.Retry.Enabled {
-			if c.Resilience.Retry.MaxAttempts == 0 {
-				c.Resilience.Retry.MaxAttempts = 3
+			c.Resilience.Retry.setDefaults(nil)
+		}
+
+		// Hedging defaults
+		if c.Resilience.Hedging != nil && c.Resilience.Hedging.Enabled {
+			if c.Resilience.Hedging.MinDelay == 0 {
+				c.Resilience.Hedging.MinDelay = 10 * time.Millisecond
+			}
+			if c.Resilience.Hedging.BudgetPercent == 0 {
+				c.Resilience.Hedging.BudgetPercent = 10
+			}
+		}
+
+		// Load shedding defaults
+		if ls := c.Resilience.LoadShedding; ls != nil && ls.Enabled {
+			if ls.Interval == 0 {
+				ls.Interval = 250 * time.Millisecond
 			}
-			if c.Resilience.Retry.InitialDelay == 0 {
-				c.Resilience.Retry.InitialDelay = 100 * time.Millisecond
+			if ls.MaxLag == 0 {
+				ls.MaxLag = 50 * time.Millisecond
 			}
-			if c.Resilience.Retry.MaxDelay == 0 {
-				c.Resilience.Retry.MaxDelay = 10 * time.Second
+			if ls.MaxCPU == 0 {
+				ls.MaxCPU = 0.9
 			}
-			if c.Resilience.Retry.Multiplier == 0 {
-				c.Resilience.Retry.Multiplier = 2.0
+			if ls.Step == 0 {
+				ls.Step = 0.05
 			}
-			if c.Resilience.Retry.Jitter == 0 {
-				c.Resilience.Retry.Jitter = 0.1
+			if ls.MaxFraction == 0 {
+				ls.MaxFraction = 0.9
+			}
+		}
+	}
+
+	// Retry budget defaults
+	if c.RetriesEnabled() {
+		if c.Resilience == nil {
+			c.Resilience = &ResilienceConfig{}
+		}
+		if c.Resilience.RetryBudget == nil {
+			c.Resilience.RetryBudget = &RetryBudgetConfig{}
+		}
+		c.Resilience.RetryBudget.setDefaults()
+	}
+
+	// Per-route retry overrides inherit unset values from the global retry policy
+	if c.HTTP != nil {
+		var global *RetryConfig
+		if c.Resilience != nil {
+			global = c.Resilience.Retry
+		}
+		for i := range c.HTTP.Routes {
+			if retry := c.HTTP.Routes[i].Retry; retry != nil && retry.Enabled {
+				retry.setDefaults(global)
 			}
 		}
 	}
@@ -573,9 +1554,98 @@ func (c *Config) setDefaults() {
 	if c.Metrics.Enabled && c.Metrics.Path == "" {
 		c.Metrics.Path = "/metrics"
 	}
+	if c.Metrics.ClientLabel == "" {
+		c.Metrics.ClientLabel = "ip"
+	}
+
+	// Default discovery settings
+	if c.Discovery != nil && c.Discovery.SRV != nil {
+		srv := c.Discovery.SRV
+		if srv.MinRefreshInterval == 0 {
+			srv.MinRefreshInterval = 5 * time.Second
+		}
+		if srv.MaxRefreshInterval == 0 {
+			srv.MaxRefreshInterval = 5 * time.Minute
+		}
+	}
+	if c.Discovery != nil && c.Discovery.Etcd != nil && c.Discovery.Etcd.Prefix == "" {
+		c.Discovery.Etcd.Prefix = "/balance/backends/"
+	}
+	if c.Discovery != nil && c.Discovery.Eureka != nil && c.Discovery.Eureka.RefreshInterval == 0 {
+		c.Discovery.Eureka.RefreshInterval = 30 * time.Second
+	}
+	if c.Discovery != nil && c.Discovery.Prune != nil && c.Discovery.Prune.After == 0 {
+		c.Discovery.Prune.After = 5 * time.Minute
+	}
+
+	// Default connection worker settings
+	if w := c.ConnectionWorkers; w != nil {
+		if w.MaxWorkers == 0 {
+			w.MaxWorkers = 10000
+		}
+		if w.Overflow == "" {
+			w.Overflow = "reject"
+		}
+		if w.IdleTimeout == 0 {
+			w.IdleTimeout = 10 * time.Second
+		}
+	}
+
+	// Default request queue settings
+	if q := c.RequestQueue; q != nil {
+		if q.MaxDepth == 0 {
+			q.MaxDepth = 100
+		}
+		if q.Timeout == 0 {
+			q.Timeout = 5 * time.Second
+		}
+		if q.RetryAfter == 0 {
+			q.RetryAfter = time.Second
+		}
+	}
+
+	// Default shutdown settings
+	if c.Shutdown != nil && c.Shutdown.DrainTimeout == 0 {
+		c.Shutdown.DrainTimeout = 30 * time.Second
+	}
+
+	// Default buffer settings
+	if c.Buffers != nil {
+		if c.Buffers.CopyBufferSize == 0 {
+			c.Buffers.CopyBufferSize = 32 * 1024
+		}
+		if c.Buffers.TransportReadBufferSize == 0 {
+			c.Buffers.TransportReadBufferSize = 4096
+		}
+		if c.Buffers.TransportWriteBufferSize == 0 {
+			c.Buffers.TransportWriteBufferSize = 4096
+		}
+	}
+
+	// Default admin settings
+	if c.Admin != nil && c.Admin.Enabled && c.Admin.Listen == "" {
+		c.Admin.Listen = ":9090"
+	}
+	if c.Admin != nil && c.Admin.Auth != nil {
+		for i := range c.Admin.Auth.Tokens {
+			if c.Admin.Auth.Tokens[i].Role == "" {
+				c.Admin.Auth.Tokens[i].Role = "read-only"
+			}
+		}
+		for i := range c.Admin.Auth.Clients {
+			if c.Admin.Auth.Clients[i].Role == "" {
+				c.Admin.Auth.Clients[i].Role = "read-only"
+			}
+		}
+	}
+
+	// Default protocol detection timeout
+	if c.Mode == "auto" && c.SniffTimeout == 0 {
+		c.SniffTimeout = time.Second
+	}
 
 	// Default HTTP settings
-	if c.Mode == "http" && c.HTTP == nil {
+	if (c.Mode == "http" || c.Mode == "auto") && c.HTTP == nil {
 		c.HTTP = &HTTPConfig{
 			EnableWebSocket:     true,
 			EnableHTTP2:         true,
@@ -610,51 +1680,298 @@ func (c *Config) setDefaults() {
 		if c.Tracing.SampleRate == 0 {
 			c.Tracing.SampleRate = 1.0
 		}
-	}
-
-	// Phase 6: Logging defaults
-	if c.Logging != nil {
-		if c.Logging.Level == "" {
-			c.Logging.Level = "info"
+		if c.Tracing.Exporter == "" {
+			c.Tracing.Exporter = "jaeger"
 		}
-		if c.Logging.Format == "" {
-			c.Logging.Format = "text"
+		if c.Tracing.Endpoint == "" {
+			switch c.Tracing.Exporter {
+			case "jaeger-agent":
+				c.Tracing.Endpoint = "localhost:6831"
+			case "zipkin":
+				c.Tracing.Endpoint = "http://localhost:9411/api/v2/spans"
+			}
 		}
 	}
-}
 
-// Validate validates the configuration
-func (c *Config) Validate() error {
-	// Validate mode
-	if c.Mode != "tcp" && c.Mode != "http" {
-		return fmt.Errorf("invalid mode: %s (must be 'tcp' or 'http')", c.Mode)
+	// HTTPS redirect defaults
+	if c.TLS != nil && c.TLS.RedirectHTTP != nil && c.TLS.RedirectHTTP.Listen == "" {
+		c.TLS.RedirectHTTP.Listen = ":80"
 	}
 
-	// Validate backends
-	if len(c.Backends) == 0 {
+	// TLS certificate source defaults
+	if c.TLS != nil {
+		for i := range c.TLS.Certificates {
+			certCfg := &c.TLS.Certificates[i]
+			if certCfg.Vault == nil && certCfg.KubernetesSecret == nil {
+				continue
+			}
+			if certCfg.Vault != nil && certCfg.Vault.Mount == "" {
+				certCfg.Vault.Mount = "pki"
+			}
+			if certCfg.RefreshInterval == 0 {
+				certCfg.RefreshInterval = time.Hour
+			}
+		}
+	}
+
+	// Auto-ban defaults
+	if c.Security != nil && c.Security.AutoBan != nil && c.Security.AutoBan.Enabled {
+		ab := c.Security.AutoBan
+		if len(ab.Events) == 0 {
+			ab.Events = []string{"unauthorized", "waf", "tls_handshake_failure", "rate_limited"}
+		}
+		if ab.Threshold == 0 {
+			ab.Threshold = 10
+		}
+		if ab.FindTime == 0 {
+			ab.FindTime = 10 * time.Minute
+		}
+		if ab.BanTime == 0 {
+			ab.BanTime = 10 * time.Minute
+		}
+		if ab.MaxBanTime == 0 {
+			ab.MaxBanTime = 24 * time.Hour
+		}
+	}
+
+	// Phase 6: Logging defaults
+	if c.Logging != nil {
+		if c.Logging.Level == "" {
+			c.Logging.Level = "info"
+		}
+		if c.Logging.Format == "" {
+			c.Logging.Format = "text"
+		}
+		if c.Logging.Output == "" {
+			c.Logging.Output = "stderr"
+		}
+		if c.Logging.AccessLogOutput == "" {
+			c.Logging.AccessLogOutput = "stdout"
+		}
+		if c.Logging.AuditLog != nil && c.Logging.AuditLog.Output == "" {
+			c.Logging.AuditLog.Output = "stderr"
+		}
+	}
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	// Validate mode
+	if c.Mode != "tcp" && c.Mode != "http" && c.Mode != "auto" {
+		return fmt.Errorf("invalid mode: %s (must be 'tcp', 'http' or 'auto')", c.Mode)
+	}
+	if c.SniffTimeout < 0 {
+		return fmt.Errorf("sniff_timeout must be non-negative")
+	}
+
+	// Validate bandwidth limits
+	if c.Bandwidth != nil && (c.Bandwidth.Global < 0 || c.Bandwidth.PerBackend < 0 || c.Bandwidth.PerClientIP < 0) {
+		return fmt.Errorf("bandwidth limits must be non-negative")
+	}
+	if c.Bandwidth != nil {
+		switch c.Bandwidth.Direction {
+		case "", "both", "upload", "download":
+		default:
+			return fmt.Errorf("invalid bandwidth direction: %s (must be 'both', 'upload', or 'download')", c.Bandwidth.Direction)
+		}
+	}
+
+	// Validate buffer sizes
+	if c.Buffers != nil && c.Buffers.CopyBufferSize != 0 &&
+		(c.Buffers.CopyBufferSize < 4*1024 || c.Buffers.CopyBufferSize > 1024*1024) {
+		return fmt.Errorf("buffers copy_buffer_size must be between 4KB and 1MB")
+	}
+	if b := c.Buffers; b != nil && (b.ListenerReadBuffer < 0 || b.ListenerWriteBuffer < 0 ||
+		b.TransportReadBufferSize < 0 || b.TransportWriteBufferSize < 0) {
+		return fmt.Errorf("buffer sizes must be non-negative")
+	}
+
+	// Validate keepalive settings
+	if ka := c.Timeouts.KeepAlive; ka != nil && (ka.Idle < 0 || ka.Interval < 0 || ka.Count < 0) {
+		return fmt.Errorf("keepalive idle, interval and count must be non-negative")
+	}
+
+	if c.Timeouts.Request < 0 {
+		return fmt.Errorf("timeouts request must be non-negative")
+	}
+
+	// Validate connection limits
+	if c.MaxConnections < 0 {
+		return fmt.Errorf("max_connections must be non-negative")
+	}
+	if c.Timeouts.TCPIdle < 0 {
+		return fmt.Errorf("tcp_idle timeout must be non-negative")
+	}
+	if c.ConnectionQueueTimeout < 0 || c.ConnectionQueueSize < 0 {
+		return fmt.Errorf("connection_queue_timeout and connection_queue_size must be non-negative")
+	}
+	if c.MaxConnectionRate < 0 || c.ConnectionRateBurst < 0 {
+		return fmt.Errorf("max_connection_rate and connection_rate_burst must be non-negative")
+	}
+	if q := c.RequestQueue; q != nil && (q.MaxDepth < 0 || q.Timeout < 0 || q.RetryAfter < 0) {
+		return fmt.Errorf("request_queue max_depth, timeout and retry_after must be non-negative")
+	}
+	if w := c.ConnectionWorkers; w != nil {
+		if c.Mode == "http" {
+			return fmt.Errorf("connection_workers is only supported in tcp and auto modes")
+		}
+		if w.MaxWorkers < 0 || w.QueueSize < 0 || w.IdleTimeout < 0 {
+			return fmt.Errorf("connection_workers max_workers, queue_size and idle_timeout must be non-negative")
+		}
+		if w.Overflow != "reject" && w.Overflow != "block" {
+			return fmt.Errorf("invalid connection_workers overflow: %s (must be 'reject' or 'block')", w.Overflow)
+		}
+	}
+
+	// Validate shutdown settings
+	if c.Shutdown != nil && (c.Shutdown.DrainTimeout < 0 || c.Shutdown.AnnouncePeriod < 0) {
+		return fmt.Errorf("shutdown drain_timeout and announce_period must be non-negative")
+	}
+
+	if c.HealthCheck != nil && c.HealthCheck.UnhealthyInterval < 0 {
+		return fmt.Errorf("health_check unhealthy_interval must be non-negative")
+	}
+
+	// Validate health check TLS settings
+	if hc := c.HealthCheck; hc != nil && hc.TLS != nil {
+		if hc.Type != "https" {
+			return fmt.Errorf("health_check tls requires type https")
+		}
+		if (hc.TLS.ClientCertFile == "") != (hc.TLS.ClientKeyFile == "") {
+			return fmt.Errorf("health_check tls client_cert_file and client_key_file must be set together")
+		}
+	}
+
+	// Validate backends
+	if len(c.Backends) == 0 && !c.DiscoveryEnabled() {
 		return fmt.Errorf("at least one backend is required")
 	}
 
+	// Validate discovery
+	if c.Discovery != nil && c.Discovery.SRV != nil {
+		srv := c.Discovery.SRV
+		if srv.Name == "" {
+			return fmt.Errorf("discovery srv name is required")
+		}
+		if srv.MinRefreshInterval < 0 || srv.MaxRefreshInterval < srv.MinRefreshInterval {
+			return fmt.Errorf("discovery srv refresh intervals must be non-negative with min_refresh_interval <= max_refresh_interval")
+		}
+	}
+	if c.Discovery != nil && c.Discovery.Etcd != nil {
+		etcd := c.Discovery.Etcd
+		if len(etcd.Endpoints) == 0 {
+			return fmt.Errorf("discovery etcd requires at least one endpoint")
+		}
+		for _, endpoint := range etcd.Endpoints {
+			if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("discovery etcd endpoint %s must be an http or https URL", endpoint)
+			}
+		}
+		if (etcd.CertFile == "") != (etcd.KeyFile == "") {
+			return fmt.Errorf("discovery etcd cert_file and key_file must be set together")
+		}
+	}
+	if c.Discovery != nil && c.Discovery.Eureka != nil {
+		eureka := c.Discovery.Eureka
+		if len(eureka.ServiceURLs) == 0 {
+			return fmt.Errorf("discovery eureka requires at least one service_url")
+		}
+		for _, serviceURL := range eureka.ServiceURLs {
+			if u, err := url.Parse(serviceURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("discovery eureka service_url %s must be an http or https URL", serviceURL)
+			}
+		}
+		if len(eureka.Applications) == 0 {
+			return fmt.Errorf("discovery eureka requires at least one application")
+		}
+		if eureka.RefreshInterval < 0 {
+			return fmt.Errorf("discovery eureka refresh_interval must be non-negative")
+		}
+	}
+	if c.Discovery != nil && c.Discovery.Prune != nil {
+		if c.HealthCheck == nil || !c.HealthCheck.Enabled {
+			return fmt.Errorf("discovery prune requires health_check to be enabled")
+		}
+		if c.Discovery.Prune.After < 0 || c.Discovery.Prune.Quarantine < 0 {
+			return fmt.Errorf("discovery prune after and quarantine must be non-negative")
+		}
+	}
+
+	backendNames := make(map[string]bool, len(c.Backends))
+	backendAddresses := make(map[string]string, len(c.Backends))
 	for i, backend := range c.Backends {
 		if backend.Address == "" {
 			return fmt.Errorf("backend %d: address is required", i)
 		}
+		if err := validateBackendAddress(backend.Address); err != nil {
+			return fmt.Errorf("backend %d: %w", i, err)
+		}
+		if backendNames[backend.Name] {
+			return fmt.Errorf("backend %d: duplicate backend name %s", i, backend.Name)
+		}
+		backendNames[backend.Name] = true
+		if other, ok := backendAddresses[backend.Address]; ok {
+			return fmt.Errorf("backend %d: address %s is already used by backend %s", i, backend.Address, other)
+		}
+		backendAddresses[backend.Address] = backend.Name
 		if backend.Weight < 0 {
 			return fmt.Errorf("backend %d: weight must be non-negative", i)
 		}
+		if backend.Priority < 0 {
+			return fmt.Errorf("backend %d: priority must be non-negative", i)
+		}
+		if cb := backend.CircuitBreaker; cb != nil {
+			if cb.MaxFailures < 0 || cb.Timeout < 0 || cb.MaxConcurrentRequests < 0 {
+				return fmt.Errorf("backend %d: circuit_breaker values must be non-negative", i)
+			}
+		}
+		if backend.MaxBandwidth < 0 {
+			return fmt.Errorf("backend %d: max_bandwidth must be non-negative", i)
+		}
+		if backend.MaxConnections < 0 {
+			return fmt.Errorf("backend %d: max_connections must be non-negative", i)
+		}
+	}
+
+	// Validate load balancer algorithm (built-in or registered with lb.Register)
+	if !lb.IsRegistered(c.LoadBalancer.Algorithm) {
+		return fmt.Errorf("invalid load balancer algorithm: %s (must be one of %s)",
+			c.LoadBalancer.Algorithm, strings.Join(lb.Registered(), ", "))
 	}
 
-	// Validate load balancer algorithm
-	validAlgorithms := map[string]bool{
-		"round-robin":                true,
-		"least-connections":          true,
-		"consistent-hash":            true,
-		"bounded-consistent-hash":    true,
-		"weighted-round-robin":       true,
-		"weighted-least-connections": true,
+	if c.LoadBalancer.VirtualNodes < 0 {
+		return fmt.Errorf("load_balancer virtual_nodes must be non-negative")
+	}
+	if c.LoadBalancer.LoadFactor != 0 && c.LoadBalancer.LoadFactor < 1 {
+		return fmt.Errorf("load_balancer load_factor must be at least 1.0")
 	}
-	if !validAlgorithms[c.LoadBalancer.Algorithm] {
-		return fmt.Errorf("invalid load balancer algorithm: %s", c.LoadBalancer.Algorithm)
+
+	if c.LoadBalancer.ZoneSpillover < 0 || c.LoadBalancer.ZoneSpillover > 100 {
+		return fmt.Errorf("load_balancer zone_spillover must be between 0 and 100")
+	}
+
+	if c.LoadBalancer.FailoverThreshold < 0 || c.LoadBalancer.FailoverThreshold > 1 {
+		return fmt.Errorf("load_balancer failover_threshold must be between 0 and 1")
+	}
+
+	if c.LoadBalancer.Subset != nil && c.LoadBalancer.Subset.Size < 0 {
+		return fmt.Errorf("load_balancer subset size must be non-negative")
+	}
+
+	// Validate adaptive weights
+	if aw := c.LoadBalancer.AdaptiveWeights; aw != nil && aw.Enabled {
+		if c.LoadBalancer.Algorithm != "weighted-round-robin" && c.LoadBalancer.Algorithm != "weighted-least-connections" {
+			return fmt.Errorf("adaptive_weights requires a weighted load balancer algorithm")
+		}
+		if c.HealthCheck == nil || !c.HealthCheck.Enabled {
+			return fmt.Errorf("adaptive_weights requires health_check to be enabled")
+		}
+		if aw.Interval < 0 {
+			return fmt.Errorf("adaptive_weights interval must be non-negative")
+		}
+		if aw.MinWeightFactor < 0 || aw.MinWeightFactor > 1 {
+			return fmt.Errorf("adaptive_weights min_weight_factor must be between 0 and 1")
+		}
 	}
 
 	// Validate hash key for consistent hashing algorithms
@@ -664,20 +1981,208 @@ func (c *Config) Validate() error {
 		c.LoadBalancer.HashKey = "source-ip"
 	}
 
+	// Validate hash key format
+	if key := c.LoadBalancer.HashKey; key != "" && key != "source-ip" {
+		name := ""
+		switch {
+		case strings.HasPrefix(key, "header:"):
+			name = strings.TrimPrefix(key, "header:")
+		case strings.HasPrefix(key, "query:"):
+			name = strings.TrimPrefix(key, "query:")
+		default:
+			return fmt.Errorf("invalid hash_key: %s (expected source-ip, header:<name> or query:<name>)", key)
+		}
+		if name == "" {
+			return fmt.Errorf("invalid hash_key: %s (missing name)", key)
+		}
+		if c.Mode == "tcp" {
+			return fmt.Errorf("hash_key %s requires http mode", key)
+		}
+	}
+
+	if c.Transform != nil && (c.Transform.StripPrefix != "" || c.Transform.AddPrefix != "") {
+		return fmt.Errorf("transform strip_prefix and add_prefix have moved to http.routes")
+	}
+
+	// Validate retry conditions
+	if c.Resilience != nil && c.Resilience.Retry != nil {
+		if err := c.Resilience.Retry.validate(); err != nil {
+			return fmt.Errorf("resilience retry: %w", err)
+		}
+	}
+	if c.HTTP != nil {
+		switch c.HTTP.NoRouteMatch {
+		case "", "pool", "404", "503":
+		default:
+			return fmt.Errorf("invalid http no_route_match: %s (must be 'pool', '404' or '503')", c.HTTP.NoRouteMatch)
+		}
+
+		if name := c.HTTP.ACMEChallengeBackend; name != "" {
+			if !slices.ContainsFunc(c.Backends, func(b Backend) bool { return b.Name == name }) {
+				return fmt.Errorf("http acme_challenge_backend: unknown backend %s", name)
+			}
+		}
+
+		defaults := 0
+		for _, route := range c.HTTP.Routes {
+			if !route.Default {
+				continue
+			}
+			defaults++
+			if route.Host != "" || route.PathPrefix != "" || len(route.Headers) > 0 || len(route.QueryParams) > 0 {
+				return fmt.Errorf("route %s: default route cannot have match conditions", route.Name)
+			}
+		}
+		if defaults > 1 {
+			return fmt.Errorf("only one route can be marked default")
+		}
+
+		for _, route := range c.HTTP.Routes {
+			if route.Timeout < 0 {
+				return fmt.Errorf("route %s: timeout must be non-negative", route.Name)
+			}
+			switch route.PriorityClass {
+			case "", "low", "normal", "high":
+			default:
+				return fmt.Errorf("route %s: invalid priority_class: %s (must be low, normal or high)", route.Name, route.PriorityClass)
+			}
+			if rate := route.TraceSampleRate; rate != nil && (*rate < 0 || *rate > 1) {
+				return fmt.Errorf("route %s: trace_sample_rate must be between 0 and 1", route.Name)
+			}
+			for _, name := range route.Overflow {
+				if !slices.ContainsFunc(c.Backends, func(b Backend) bool { return b.Name == name }) {
+					return fmt.Errorf("route %s: unknown overflow backend %s", route.Name, name)
+				}
+				if slices.Contains(route.Backends, name) {
+					return fmt.Errorf("route %s: backend %s cannot be both a backend and an overflow backend", route.Name, name)
+				}
+			}
+			if (route.StripPrefix != "" && !strings.HasPrefix(route.StripPrefix, "/")) ||
+				(route.AddPrefix != "" && !strings.HasPrefix(route.AddPrefix, "/")) {
+				return fmt.Errorf("route %s: strip_prefix and add_prefix must start with /", route.Name)
+			}
+			if route.HostRewrite != "" && route.PreserveHost != nil && *route.PreserveHost {
+				return fmt.Errorf("route %s: host_rewrite and preserve_host: true are mutually exclusive", route.Name)
+			}
+			for name, value := range route.Headers {
+				if pattern, ok := strings.CutPrefix(strings.TrimPrefix(value, "!"), "~"); ok {
+					if _, err := regexp.Compile(pattern); err != nil {
+						return fmt.Errorf("route %s: invalid regex for header %s: %w", route.Name, name, err)
+					}
+				}
+			}
+			if route.Selector != "" {
+				if _, err := ParseLabelSelector(route.Selector); err != nil {
+					return fmt.Errorf("route %s: %w", route.Name, err)
+				}
+			}
+			if route.Retry == nil {
+				continue
+			}
+			if err := route.Retry.validate(); err != nil {
+				return fmt.Errorf("route %s retry: %w", route.Name, err)
+			}
+		}
+	}
+
+	// Validate retry budget
+	if c.Resilience != nil && c.Resilience.RetryBudget != nil {
+		budget := c.Resilience.RetryBudget
+		if budget.Percent < 0 || budget.Percent > 100 {
+			return fmt.Errorf("resilience retry_budget percent must be between 0 and 100")
+		}
+		if budget.MinRetriesPerSecond < 0 || budget.Window < 0 {
+			return fmt.Errorf("resilience retry_budget min_retries_per_second and window must be non-negative")
+		}
+	}
+
+	// Validate hedging
+	if c.Resilience != nil && c.Resilience.Hedging != nil && c.Resilience.Hedging.Enabled {
+		hedging := c.Resilience.Hedging
+		if c.Mode == "tcp" {
+			return fmt.Errorf("resilience hedging requires http mode")
+		}
+		if hedging.Delay < 0 || hedging.MinDelay < 0 {
+			return fmt.Errorf("resilience hedging delay and min_delay must be non-negative")
+		}
+		if hedging.BudgetPercent < 0 || hedging.BudgetPercent > 100 {
+			return fmt.Errorf("resilience hedging budget_percent must be between 0 and 100")
+		}
+	}
+
+	// Validate load shedding
+	if c.Resilience != nil && c.Resilience.LoadShedding != nil && c.Resilience.LoadShedding.Enabled {
+		ls := c.Resilience.LoadShedding
+		if c.Mode == "tcp" {
+			return fmt.Errorf("resilience load_shedding requires http mode")
+		}
+		if ls.Interval <= 0 || ls.MaxLag <= 0 {
+			return fmt.Errorf("resilience load_shedding interval and max_lag must be positive")
+		}
+		if ls.MaxCPU < 0 || ls.MaxCPU > 1 || ls.Step <= 0 || ls.Step > 1 || ls.MaxFraction <= 0 || ls.MaxFraction > 1 {
+			return fmt.Errorf("resilience load_shedding max_cpu, step and max_fraction must be between 0 and 1")
+		}
+	}
+
 	// Validate TLS configuration
 	if c.TLS != nil && c.TLS.Enabled {
 		// Check for either new-style certificates or old-style cert/key files
-		if len(c.TLS.Certificates) == 0 && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
-			return fmt.Errorf("TLS certificates or cert_file/key_file is required when TLS is enabled")
+		spiffe := c.TLS.SPIFFE != nil && c.TLS.SPIFFE.Enabled
+		if len(c.TLS.Certificates) == 0 && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") && !c.TLS.AutoSelfSigned && !spiffe {
+			return fmt.Errorf("TLS certificates, cert_file/key_file, auto_self_signed or spiffe is required when TLS is enabled")
+		}
+
+		validClientAuth := map[string]bool{
+			"none": true, "request": true, "require": true,
+			"verify": true, "require-and-verify": true,
 		}
 
 		// Validate certificate configurations
 		for i, certCfg := range c.TLS.Certificates {
-			if certCfg.CertFile == "" {
-				return fmt.Errorf("TLS certificate %d: cert_file is required", i)
+			sources := 0
+			for _, set := range []bool{certCfg.CertFile != "" || certCfg.KeyFile != "", certCfg.Vault != nil, certCfg.KubernetesSecret != nil} {
+				if set {
+					sources++
+				}
+			}
+			if sources != 1 {
+				return fmt.Errorf("TLS certificate %d: exactly one of cert_file/key_file, vault or kubernetes_secret is required", i)
 			}
-			if certCfg.KeyFile == "" {
-				return fmt.Errorf("TLS certificate %d: key_file is required", i)
+			switch {
+			case certCfg.Vault != nil:
+				vault := certCfg.Vault
+				if vault.Address == "" && os.Getenv("VAULT_ADDR") == "" {
+					return fmt.Errorf("TLS certificate %d: vault address is required when VAULT_ADDR is not set", i)
+				}
+				if vault.Token == "" && vault.TokenFile == "" && os.Getenv("VAULT_TOKEN") == "" {
+					return fmt.Errorf("TLS certificate %d: vault token or token_file is required when VAULT_TOKEN is not set", i)
+				}
+				if vault.Role == "" {
+					return fmt.Errorf("TLS certificate %d: vault role is required", i)
+				}
+				if vault.CommonName == "" {
+					return fmt.Errorf("TLS certificate %d: vault common_name is required", i)
+				}
+				if vault.TTL < 0 {
+					return fmt.Errorf("TLS certificate %d: vault ttl must be non-negative", i)
+				}
+			case certCfg.KubernetesSecret != nil:
+				if certCfg.KubernetesSecret.Name == "" {
+					return fmt.Errorf("TLS certificate %d: kubernetes_secret name is required", i)
+				}
+			default:
+				if certCfg.CertFile == "" {
+					return fmt.Errorf("TLS certificate %d: cert_file is required", i)
+				}
+				if certCfg.KeyFile == "" {
+					return fmt.Errorf("TLS certificate %d: key_file is required", i)
+				}
+			}
+			if certCfg.RefreshInterval < 0 {
+				return fmt.Errorf("TLS certificate %d: refresh_interval must be non-negative", i)
+			}
+			if certCfg.ClientAuth != "" && !validClientAuth[certCfg.ClientAuth] {
+				return fmt.Errorf("TLS certificate %d: invalid client_auth: %s", i, certCfg.ClientAuth)
 			}
 		}
 
@@ -698,24 +2203,392 @@ func (c *Config) Validate() error {
 
 		// Validate client auth
 		if c.TLS.ClientAuth != "" {
-			validClientAuth := map[string]bool{
-				"none": true, "request": true, "require": true,
-				"verify": true, "require-and-verify": true,
-			}
 			if !validClientAuth[c.TLS.ClientAuth] {
 				return fmt.Errorf("invalid TLS client_auth: %s", c.TLS.ClientAuth)
 			}
 		}
+
+		if c.TLS.CRLRefreshInterval < 0 {
+			return fmt.Errorf("TLS crl_refresh_interval must be non-negative")
+		}
+
+		// Validate SNI routes
+		if c.TLS.SNI != nil {
+			for hostname, backends := range c.TLS.SNI.Routes {
+				if hostname == "" {
+					return fmt.Errorf("TLS sni route hostname cannot be empty")
+				}
+				if len(backends) == 0 {
+					return fmt.Errorf("TLS sni route %s: at least one backend is required", hostname)
+				}
+				for _, name := range backends {
+					if !backendNames[name] {
+						return fmt.Errorf("TLS sni route %s: unknown backend %s", hostname, name)
+					}
+				}
+			}
+		}
+
+		// Validate ALPN routes
+		if c.TLS.ALPN != nil {
+			for protocol, backends := range c.TLS.ALPN.Routes {
+				if protocol == "" {
+					return fmt.Errorf("TLS alpn route protocol cannot be empty")
+				}
+				if len(c.TLS.ALPNProtocols) > 0 && !slices.Contains(c.TLS.ALPNProtocols, protocol) {
+					return fmt.Errorf("TLS alpn route %s: protocol is not listed in alpn_protocols", protocol)
+				}
+				if protocol == "h2" && c.HTTP != nil && c.Mode != "tcp" && !c.HTTP.EnableHTTP2 {
+					return fmt.Errorf("TLS alpn route h2 requires http.enable_http2")
+				}
+				if len(backends) == 0 {
+					return fmt.Errorf("TLS alpn route %s: at least one backend is required", protocol)
+				}
+				for _, name := range backends {
+					if !backendNames[name] {
+						return fmt.Errorf("TLS alpn route %s: unknown backend %s", protocol, name)
+					}
+				}
+			}
+		}
+	}
+
+	// Validate the HTTPS redirect listener
+	if c.TLS != nil && c.TLS.RedirectHTTP != nil && c.TLS.RedirectHTTP.Enabled {
+		if !c.TLS.Enabled {
+			return fmt.Errorf("TLS redirect_http requires TLS to be enabled")
+		}
+		if c.TLS.RedirectHTTP.HTTPSPort < 0 || c.TLS.RedirectHTTP.HTTPSPort > 65535 {
+			return fmt.Errorf("TLS redirect_http https_port must be between 1 and 65535")
+		}
+		if c.TLS.RedirectHTTP.Listen == c.Listen {
+			return fmt.Errorf("TLS redirect_http listen must differ from listen")
+		}
+	}
+
+	// Validate backend TLS and SPIFFE, which also apply without TLS termination
+	if c.TLS != nil {
+		if b := c.TLS.Backend; b != nil && b.Enabled && (b.ClientCertFile == "") != (b.ClientKeyFile == "") {
+			return fmt.Errorf("TLS backend client_cert_file and client_key_file must be set together")
+		}
+		if sp := c.TLS.SPIFFE; sp != nil && sp.Enabled {
+			if sp.SocketPath == "" && os.Getenv("SPIFFE_ENDPOINT_SOCKET") == "" {
+				return fmt.Errorf("TLS spiffe socket_path is required when SPIFFE_ENDPOINT_SOCKET is not set")
+			}
+			for _, id := range sp.BackendIDs {
+				if !strings.HasPrefix(id, "spiffe://") {
+					return fmt.Errorf("TLS spiffe backend_ids: invalid SPIFFE ID %s", id)
+				}
+			}
+		}
+	}
+
+	// Validate tracing configuration
+	if c.Tracing != nil && c.Tracing.Enabled {
+		switch c.Tracing.Exporter {
+		case "jaeger":
+		case "jaeger-agent":
+			if _, _, err := net.SplitHostPort(c.Tracing.Endpoint); err != nil {
+				return fmt.Errorf("invalid tracing endpoint %s: jaeger-agent requires host:port", c.Tracing.Endpoint)
+			}
+		case "zipkin":
+			if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid tracing endpoint %s: zipkin requires an http or https URL", c.Tracing.Endpoint)
+			}
+		default:
+			return fmt.Errorf("invalid tracing exporter: %s (must be jaeger, jaeger-agent or zipkin)", c.Tracing.Exporter)
+		}
+		for _, attr := range c.Tracing.SpanAttributes {
+			switch {
+			case attr == "route", attr == "backend", attr == "client_ip":
+			case strings.HasPrefix(attr, "header:") && len(attr) > len("header:"):
+			case strings.HasPrefix(attr, "baggage:") && len(attr) > len("baggage:"):
+			default:
+				return fmt.Errorf("invalid tracing span attribute: %s (must be route, backend, client_ip, header:<name> or baggage:<key>)", attr)
+			}
+		}
+	}
+
+	// Validate logging configuration
+	if c.Logging != nil {
+		validLevels := map[string]bool{"": true, "debug": true, "info": true, "warn": true, "error": true, "fatal": true}
+		if !validLevels[c.Logging.Level] {
+			return fmt.Errorf("invalid logging level: %s (must be 'debug', 'info', 'warn', 'error', or 'fatal')", c.Logging.Level)
+		}
+		if c.Logging.Format != "" && c.Logging.Format != "text" && c.Logging.Format != "json" {
+			return fmt.Errorf("invalid logging format: %s (must be 'text' or 'json')", c.Logging.Format)
+		}
+		if err := validateLogOutput(c.Logging.Output); err != nil {
+			return fmt.Errorf("invalid logging output: %w", err)
+		}
+		if err := validateLogOutput(c.Logging.AccessLogOutput); err != nil {
+			return fmt.Errorf("invalid logging access_log_output: %w", err)
+		}
+		if c.Logging.AuditLog != nil {
+			if err := validateLogOutput(c.Logging.AuditLog.Output); err != nil {
+				return fmt.Errorf("invalid logging audit_log output: %w", err)
+			}
+		}
+	}
+
+	// Validate access log sampling
+	if c.Logging != nil && c.Logging.UpstreamTiming && !c.Logging.AccessLog {
+		return fmt.Errorf("logging upstream_timing requires access_log")
+	}
+
+	if c.Logging != nil && c.Logging.AccessLogSampling != nil {
+		if !c.Logging.AccessLog {
+			return fmt.Errorf("logging access_log_sampling requires access_log")
+		}
+		for class, rate := range c.Logging.AccessLogSampling.Rates {
+			if !statusClassPattern.MatchString(class) {
+				return fmt.Errorf("invalid logging access_log_sampling status class: %s (must be 1xx, 2xx, 3xx, 4xx or 5xx)", class)
+			}
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("logging access_log_sampling rate of %s must be between 0 and 1", class)
+			}
+		}
+		if c.Logging.AccessLogSampling.SlowThreshold < 0 {
+			return fmt.Errorf("logging access_log_sampling slow_threshold must be non-negative")
+		}
+	}
+
+	// Validate metrics configuration
+	validClientLabels := map[string]bool{"ip": true, "prefix": true, "hash": true, "drop": true}
+	if c.Metrics.ClientLabel != "" && !validClientLabels[c.Metrics.ClientLabel] {
+		return fmt.Errorf("invalid metrics client_label: %s (must be 'ip', 'prefix', 'hash', or 'drop')", c.Metrics.ClientLabel)
+	}
+
+	// Validate admin API configuration
+	if c.Admin != nil && c.Admin.Enabled {
+		if err := c.Admin.validate(); err != nil {
+			return err
+		}
 	}
 
 	// Validate security configuration
 	if c.Security != nil {
+		if cp := c.Security.ConnectionProtection; cp != nil && cp.MaxConcurrentRequestsPerIP < 0 {
+			return fmt.Errorf("security connection_protection max_concurrent_requests_per_ip must be non-negative")
+		}
 		if c.Security.RateLimit != nil && c.Security.RateLimit.Enabled {
 			if c.Security.RateLimit.Type != "token-bucket" && c.Security.RateLimit.Type != "sliding-window" {
 				return fmt.Errorf("invalid rate limit type: %s (must be 'token-bucket' or 'sliding-window')", c.Security.RateLimit.Type)
 			}
+			if err := validateRateLimitKey(c.Security.RateLimit.Key, c.Mode); err != nil {
+				return err
+			}
+			if err := c.Security.RateLimit.validateTiers(c.Mode); err != nil {
+				return err
+			}
+		}
+		if uf := c.Security.URLFilter; uf != nil && uf.Enabled {
+			if c.Mode == "tcp" {
+				return fmt.Errorf("security url_filter requires http mode")
+			}
+			for _, pattern := range uf.DenyPatterns {
+				if _, err := regexp.Compile(pattern); err != nil {
+					return fmt.Errorf("invalid security url_filter deny pattern %q: %w", pattern, err)
+				}
+			}
+		}
+		for _, hash := range c.Security.BlockedJA3 {
+			if !ja3HashPattern.MatchString(hash) {
+				return fmt.Errorf("invalid security blocked_ja3 hash: %s (must be 32 lowercase hex characters)", hash)
+			}
+		}
+		if ab := c.Security.AutoBan; ab != nil && ab.Enabled {
+			if err := ab.validate(c.Mode); err != nil {
+				return err
+			}
 		}
+		for _, entry := range c.Security.TrustedProxies {
+			if net.ParseIP(entry) != nil {
+				continue
+			}
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return fmt.Errorf("invalid security trusted_proxies entry: %s (must be an IP or CIDR range)", entry)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validate checks the auto_ban events, durations and allowlist
+func (ab *AutoBanConfig) validate(mode string) error {
+	for _, event := range ab.Events {
+		switch event {
+		case "tls_handshake_failure", "rate_limited":
+		case "unauthorized", "waf":
+			if mode == "tcp" {
+				return fmt.Errorf("security auto_ban event %s requires http mode", event)
+			}
+		default:
+			return fmt.Errorf("invalid security auto_ban event: %s (must be unauthorized, waf, tls_handshake_failure or rate_limited)", event)
+		}
+	}
+	if ab.Threshold < 1 {
+		return fmt.Errorf("security auto_ban threshold must be positive")
+	}
+	if ab.FindTime <= 0 || ab.BanTime <= 0 || ab.MaxBanTime <= 0 {
+		return fmt.Errorf("security auto_ban find_time, ban_time and max_ban_time must be positive")
+	}
+	if ab.MaxBanTime < ab.BanTime {
+		return fmt.Errorf("security auto_ban max_ban_time must be at least ban_time")
+	}
+	for _, entry := range ab.Allowlist {
+		if net.ParseIP(entry) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil {
+			return fmt.Errorf("invalid security auto_ban allowlist entry: %s (must be an IP or CIDR range)", entry)
+		}
+	}
+	return nil
+}
+
+// validateRateLimitKey checks a rate_limit key of the form "client-ip" or "<source>:<name>"
+func validateRateLimitKey(key, mode string) error {
+	if key == "" || key == "client-ip" {
+		return nil
+	}
+
+	source, name, _ := strings.Cut(key, ":")
+	switch source {
+	case "header", "cookie", "jwt", "api-key":
+	default:
+		return fmt.Errorf("invalid rate_limit key: %s (expected client-ip, header:<name>, cookie:<name>, jwt:<claim> or api-key:<header>)", key)
+	}
+	if name == "" {
+		return fmt.Errorf("invalid rate_limit key: %s (missing name)", key)
+	}
+	if mode == "tcp" {
+		return fmt.Errorf("rate_limit key %s requires http mode", key)
+	}
+	return nil
+}
+
+// validateTiers checks the tier key and that every tier sets the limits of the rate limit's type
+func (rl *RateLimitConfig) validateTiers(mode string) error {
+	if len(rl.Tiers) == 0 {
+		if rl.TierKey != "" {
+			return fmt.Errorf("rate_limit tier_key requires tiers")
+		}
+		return nil
+	}
+
+	source, name, _ := strings.Cut(rl.TierKey, ":")
+	if (source != "header" && source != "jwt") || name == "" {
+		return fmt.Errorf("invalid rate_limit tier_key: %q (expected header:<name> or jwt:<claim>)", rl.TierKey)
+	}
+	if mode == "tcp" {
+		return fmt.Errorf("rate_limit tiers require http mode")
 	}
 
+	for tierName, tier := range rl.Tiers {
+		if tier == nil {
+			return fmt.Errorf("rate_limit tier %s: missing limits", tierName)
+		}
+		switch rl.Type {
+		case "token-bucket":
+			if tier.RequestsPerSecond <= 0 || tier.BurstSize <= 0 {
+				return fmt.Errorf("rate_limit tier %s: requests_per_second and burst_size must be positive", tierName)
+			}
+		case "sliding-window":
+			if window, err := time.ParseDuration(tier.WindowSize); err != nil || window <= 0 {
+				return fmt.Errorf("rate_limit tier %s: invalid window_size %q", tierName, tier.WindowSize)
+			}
+			if tier.MaxRequests <= 0 {
+				return fmt.Errorf("rate_limit tier %s: max_requests must be positive", tierName)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the admin API's TLS and authentication settings
+func (a *AdminConfig) validate() error {
+	if a.TLS != nil && (a.TLS.CertFile == "" || a.TLS.KeyFile == "") {
+		return fmt.Errorf("admin tls cert_file and key_file are required")
+	}
+	if a.Auth == nil {
+		return nil
+	}
+	if len(a.Auth.Tokens) == 0 && len(a.Auth.Clients) == 0 {
+		return fmt.Errorf("admin auth requires at least one token or client")
+	}
+
+	validRoles := map[string]bool{"read-only": true, "operator": true}
+	names := make(map[string]bool, len(a.Auth.Tokens))
+	for i, token := range a.Auth.Tokens {
+		if token.Name == "" {
+			return fmt.Errorf("admin auth token %d: name is required", i)
+		}
+		if names[token.Name] {
+			return fmt.Errorf("admin auth token %d: duplicate name %s", i, token.Name)
+		}
+		names[token.Name] = true
+		if (token.Token == "") == (token.TokenFile == "") {
+			return fmt.Errorf("admin auth token %s: exactly one of token or token_file is required", token.Name)
+		}
+		if !validRoles[token.Role] {
+			return fmt.Errorf("admin auth token %s: invalid role: %s (must be 'read-only' or 'operator')", token.Name, token.Role)
+		}
+	}
+	if len(a.Auth.Clients) > 0 && (a.TLS == nil || a.TLS.ClientCAFile == "") {
+		return fmt.Errorf("admin auth clients require admin tls client_ca_file")
+	}
+	for i, client := range a.Auth.Clients {
+		if client.CommonName == "" {
+			return fmt.Errorf("admin auth client %d: common_name is required", i)
+		}
+		if !validRoles[client.Role] {
+			return fmt.Errorf("admin auth client %s: invalid role: %s (must be 'read-only' or 'operator')", client.CommonName, client.Role)
+		}
+	}
+	return nil
+}
+
+// validateLogOutput checks a log output: stdout, stderr, a file path or a syslog or
+// TCP JSON collector URL
+func validateLogOutput(output string) error {
+	if !strings.Contains(output, "://") {
+		return nil
+	}
+	u, err := url.Parse(output)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "syslog", "syslog+udp", "syslog+tcp", "syslog+tls":
+		if facility := u.Query().Get("facility"); facility != "" && !validSyslogFacilities[facility] {
+			return fmt.Errorf("invalid syslog facility: %s", facility)
+		}
+	case "tcp-json", "tcp-json+tls":
+		if u.Port() == "" {
+			return fmt.Errorf("%s requires a port", output)
+		}
+	default:
+		return fmt.Errorf("unsupported scheme %s (must be syslog, syslog+udp, syslog+tcp, syslog+tls, tcp-json or tcp-json+tls)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%s requires a host", output)
+	}
+	return nil
+}
+
+// validateBackendAddress checks that a backend address is a host:port with a port between 1 and 65535
+func validateBackendAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %v", address, err)
+	}
+	if host == "" {
+		return fmt.Errorf("invalid address %s: missing host", address)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid address %s: port must be a number between 1 and 65535", address)
+	}
 	return nil
 }
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/lb"
+)
+
+// baseConfig is a minimal valid configuration the tests extend
+const baseConfig = `
+mode: http
+listen: ":8080"
+backends:
+  - name: backend-1
+    address: "127.0.0.1:9001"
+`
+
+func TestConsistentHashDefaults(t *testing.T) {
+	tests := []struct {
+		name         string
+		yaml         string
+		virtualNodes int
+		loadFactor   float64
+	}{
+		{"unset", "", lb.DefaultVirtualNodes, lb.DefaultLoadFactor},
+		{"set", "load_balancer:\n  virtual_nodes: 500\n  load_factor: 2\n", 500, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(baseConfig+tt.yaml), "balance.yaml")
+			if err != nil {
+				t.Fatalf("Failed to parse config: %v", err)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Expected a valid config, got %v", err)
+			}
+			if cfg.LoadBalancer.VirtualNodes != tt.virtualNodes {
+				t.Errorf("Expected %d virtual nodes, got %d", tt.virtualNodes, cfg.LoadBalancer.VirtualNodes)
+			}
+			if cfg.LoadBalancer.LoadFactor != tt.loadFactor {
+				t.Errorf("Expected load factor %v, got %v", tt.loadFactor, cfg.LoadBalancer.LoadFactor)
+			}
+		})
+	}
+}
+
+func TestConsistentHashValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"load factor of 1", "load_balancer:\n  load_factor: 1\n", ""},
+		{"load factor below 1", "load_balancer:\n  load_factor: 0.5\n", "load_factor must be at least 1.0"},
+		{"negative virtual nodes", "load_balancer:\n  virtual_nodes: -1\n", "virtual_nodes must be non-negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(baseConfig+tt.yaml), "balance.yaml")
+			if err != nil {
+				t.Fatalf("Failed to parse config: %v", err)
+			}
+			err = cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected a valid config, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	file := baseConfig + "logging:\n  level: info\nadmin:\n  enabled: true\n  listen: \":9090\"\n"
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		field    func(*Config) string
+		expected string
+	}{
+		{"listen from file", nil, func(c *Config) string { return c.Listen }, ":8080"},
+		{"listen", map[string]string{"BALANCE_LISTEN": ":9000"}, func(c *Config) string { return c.Listen }, ":9000"},
+		{"mode from file", nil, func(c *Config) string { return c.Mode }, "http"},
+		{"mode", map[string]string{"BALANCE_MODE": "tcp"}, func(c *Config) string { return c.Mode }, "tcp"},
+		{"log level from file", nil, func(c *Config) string { return c.Logging.Level }, "info"},
+		{"log level", map[string]string{"BALANCE_LOG_LEVEL": "debug"}, func(c *Config) string { return c.Logging.Level }, "debug"},
+		{"admin listen from file", nil, func(c *Config) string { return c.Admin.Listen }, ":9090"},
+		{"admin listen", map[string]string{"BALANCE_ADMIN_LISTEN": "127.0.0.1:9191"}, func(c *Config) string { return c.Admin.Listen }, "127.0.0.1:9191"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Variables set outside the test are cleared, as empty values are ignored
+			for _, name := range []string{"BALANCE_LISTEN", "BALANCE_MODE", "BALANCE_LOG_LEVEL", "BALANCE_ADMIN_LISTEN"} {
+				t.Setenv(name, "")
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := Parse([]byte(file), "balance.yaml")
+			if err != nil {
+				t.Fatalf("Failed to parse config: %v", err)
+			}
+			if got := tt.field(cfg); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEnvLogLevelWithoutLoggingSection(t *testing.T) {
+	t.Setenv("BALANCE_LOG_LEVEL", "warn")
+
+	cfg, err := Parse([]byte(baseConfig), "balance.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if cfg.Logging == nil || cfg.Logging.Level != "warn" {
+		t.Errorf("Expected log level warn, got %+v", cfg.Logging)
+	}
+}
+
+func TestContainerDefaults(t *testing.T) {
+	SetContainerMode(true)
+	defer SetContainerMode(false)
+
+	tests := []struct {
+		name           string
+		yaml           string
+		format         string
+		output         string
+		announcePeriod time.Duration
+		drainTimeout   time.Duration
+	}{
+		{"unset", "", "json", "stdout", 5 * time.Second, 20 * time.Second},
+		{
+			"set in the file",
+			"logging:\n  format: text\n  output: stderr\nshutdown:\n  announce_period: 1s\n  drain_timeout: 45s\n",
+			"text", "stderr", time.Second, 45 * time.Second,
+		},
+		{
+			"partly set",
+			"logging:\n  format: text\nshutdown:\n  drain_timeout: 10s\n",
+			"text", "stdout", 5 * time.Second, 10 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(baseConfig+tt.yaml), "balance.yaml")
+			if err != nil {
+				t.Fatalf("Failed to parse config: %v", err)
+			}
+			if cfg.Logging.Format != tt.format || cfg.Logging.Output != tt.output {
+				t.Errorf("Expected logging %s to %s, got %s to %s", tt.format, tt.output, cfg.Logging.Format, cfg.Logging.Output)
+			}
+			if cfg.Shutdown.AnnouncePeriod != tt.announcePeriod || cfg.Shutdown.DrainTimeout != tt.drainTimeout {
+				t.Errorf("Expected announce period %v and drain timeout %v, got %v and %v",
+					tt.announcePeriod, tt.drainTimeout, cfg.Shutdown.AnnouncePeriod, cfg.Shutdown.DrainTimeout)
+			}
+		})
+	}
+}
+
+func TestContainerDefaultsDisabled(t *testing.T) {
+	cfg, err := Parse([]byte(baseConfig), "balance.yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if cfg.Logging != nil && cfg.Logging.Output == "stdout" {
+		t.Errorf("Expected no container logging defaults, got %+v", cfg.Logging)
+	}
+	if cfg.Shutdown != nil && cfg.Shutdown.AnnouncePeriod != 0 {
+		t.Errorf("Expected no announce period, got %v", cfg.Shutdown.AnnouncePeriod)
+	}
+}
+
+func TestDiffRedactsCredentials(t *testing.T) {
+	withTokens := func(tokens ...AdminToken) *Config {
+		return &Config{
+			Mode:     "http",
+			Backends: []Backend{{Name: "web-1", Address: "10.0.0.1:8080", Weight: 1}},
+			Admin:    &AdminConfig{Auth: &AdminAuthConfig{Tokens: tokens}},
+		}
+	}
+	running := withTokens(AdminToken{Name: "ops", Token: "ops-secret", Role: "operator"})
+
+	tests := []struct {
+		name     string
+		new      *Config
+		path     string
+		redacted bool
+	}{
+		{"changed token", withTokens(AdminToken{Name: "ops", Token: "guess", Role: "operator"}), "admin.auth.tokens[ops].token", true},
+		{"added token", withTokens(running.Admin.Auth.Tokens[0], AdminToken{Name: "ci", Token: "ci-secret"}), "admin.auth.tokens[ci]", true},
+		{"changed role", withTokens(AdminToken{Name: "ops", Token: "ops-secret", Role: "read-only"}), "admin.auth.tokens[ops].role", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Diff(running, tt.new)
+			if err != nil {
+				t.Fatalf("Failed to diff: %v", err)
+			}
+			if len(changes) != 1 || changes[0].Path != tt.path {
+				t.Fatalf("Expected a change to %s, got %+v", tt.path, changes)
+			}
+			if changes[0].Redacted() != tt.redacted {
+				t.Errorf("Expected redacted %v, got %v", tt.redacted, changes[0].Redacted())
+			}
+			if strings.Contains(fmt.Sprint(changes[0].Old, changes[0].New), "secret") {
+				t.Errorf("Expected credentials to be hidden, got %+v", changes[0])
+			}
+		})
+	}
+}
+
+func TestSettingKey(t *testing.T) {
+	tests := map[string]string{
+		"listen":                 "listen",
+		"backends[web-1].weight": "weight",
+		"admin.auth.tokens[0]":   "tokens",
+		"backends[web-1]":        "backends",
+	}
+	for path, key := range tests {
+		if got := settingKey(path); got != key {
+			t.Errorf("Expected key %s for %s, got %s", key, path, got)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedKeys are substrings of the keys whose values are hidden in diffs, as they
+// carry credentials
+var redactedKeys = []string{"token", "password", "secret"}
+
+// Change is a setting that differs between two configurations
+// Old is nil for added settings and New is nil for removed ones.
+type Change struct {
+	// Path locates the setting, e.g. "backends[web-1].weight"; elements of lists of
+	// named items are identified by name and others by index
+	Path string `json:"path"`
+
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+
+	// redacted is set if values of credentials were hidden in the change
+	redacted bool
+}
+
+// Redacted reports whether values of credentials were hidden in the change
+// The change still tells that a credential differs, so clients that may not learn
+// credentials must not be shown it: comparing guesses against the running
+// configuration would reveal them.
+func (c Change) Redacted() bool {
+	return c.redacted
+}
+
+// Reloadable reports whether the change can be applied to a running proxy: only the
+// static backends can change, except for their circuit breaker and bandwidth overrides
+func (c Change) Reloadable() bool {
+	rest, ok := strings.CutPrefix(c.Path, "backends")
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "[")) {
+		return false
+	}
+	for _, key := range []string{"circuit_breaker", "max_bandwidth"} {
+		// Added or removed backends carry all of their settings
+		if strings.Contains(rest, "."+key) || hasBackendSetting(c.Old, key) || hasBackendSetting(c.New, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasBackendSetting reports whether a backend, or any backend in a list, has a setting
+func hasBackendSetting(value interface{}, key string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v[key] != nil
+	case []interface{}:
+		for _, item := range v {
+			if hasBackendSetting(item, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Diff returns the settings that differ between two configurations, sorted by path
+// Values of credentials such as tokens and passwords are redacted.
+func Diff(old, new *Config) ([]Change, error) {
+	oldTree, err := toTree(old)
+	if err != nil {
+		return nil, err
+	}
+	newTree, err := toTree(new)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	diffValues("", oldTree, newTree, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// toTree converts a configuration to the generic maps and lists of its YAML form
+func toTree(cfg *Config) (interface{}, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	var tree interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	return tree, nil
+}
+
+// diffValues appends the differences between two values at path
+func diffValues(path string, old, new interface{}, changes *[]Change) {
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			diffMaps(path, o, n, changes)
+			return
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			diffLists(path, o, n, changes)
+			return
+		}
+	}
+	*changes = append(*changes, redactChange(Change{Path: path, Old: old, New: new}))
+}
+
+// diffMaps appends the differences between two maps at path
+func diffMaps(path string, old, new map[string]interface{}, changes *[]Change) {
+	keys := make(map[string]bool, len(old)+len(new))
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range new {
+		keys[key] = true
+	}
+	for key := range keys {
+		child := key
+		if path != "" {
+			child = path + "." + key
+		}
+		diffValues(child, old[key], new[key], changes)
+	}
+}
+
+// diffLists appends the differences between two lists at path, matching elements by
+// name if every element has a unique one and by index otherwise
+func diffLists(path string, old, new []interface{}, changes *[]Change) {
+	oldNamed, oldOK := namedItems(old)
+	newNamed, newOK := namedItems(new)
+	if oldOK && newOK {
+		names := make(map[string]bool, len(oldNamed)+len(newNamed))
+		for name := range oldNamed {
+			names[name] = true
+		}
+		for name := range newNamed {
+			names[name] = true
+		}
+		for name := range names {
+			diffValues(fmt.Sprintf("%s[%s]", path, name), oldNamed[name], newNamed[name], changes)
+		}
+		return
+	}
+
+	for i := 0; i < len(old) || i < len(new); i++ {
+		var o, n interface{}
+		if i < len(old) {
+			o = old[i]
+		}
+		if i < len(new) {
+			n = new[i]
+		}
+		diffValues(fmt.Sprintf("%s[%d]", path, i), o, n, changes)
+	}
+}
+
+// namedItems indexes a list of maps by their name field, reporting false if an
+// element has no name or shares it with another
+func namedItems(list []interface{}) (map[string]interface{}, bool) {
+	named := make(map[string]interface{}, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok || name == "" || named[name] != nil {
+			return nil, false
+		}
+		named[name] = item
+	}
+	return named, true
+}
+
+// redactChange hides the values of credentials in a change
+func redactChange(c Change) Change {
+	if isCredential(settingKey(c.Path)) {
+		if c.Old != nil {
+			c.Old = "[redacted]"
+		}
+		if c.New != nil {
+			c.New = "[redacted]"
+		}
+		c.redacted = true
+		return c
+	}
+	var oldRedacted, newRedacted bool
+	c.Old, oldRedacted = redactTree(c.Old)
+	c.New, newRedacted = redactTree(c.New)
+	c.redacted = oldRedacted || newRedacted
+	return c
+}
+
+// settingKey returns the key of the setting at path, e.g. "tokens" for
+// "admin.auth.tokens[0]"
+func settingKey(path string) string {
+	for strings.HasSuffix(path, "]") {
+		i := strings.LastIndex(path, "[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+// isCredential reports whether a setting's key names a credential
+func isCredential(key string) bool {
+	key = strings.ToLower(key)
+	for _, redacted := range redactedKeys {
+		if strings.Contains(key, redacted) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactTree hides the values of credentials nested in an added or removed setting,
+// reporting whether it hid any
+func redactTree(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		hidden := false
+		for key, child := range v {
+			if isCredential(key) {
+				out[key] = "[redacted]"
+				hidden = true
+				continue
+			}
+			var childHidden bool
+			out[key], childHidden = redactTree(child)
+			hidden = hidden || childHidden
+		}
+		return out, hidden
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		hidden := false
+		for i, child := range v {
+			var childHidden bool
+			out[i], childHidden = redactTree(child)
+			hidden = hidden || childHidden
+		}
+		return out, hidden
+	}
+	return value, false
+}
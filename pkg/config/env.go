@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// containerMode makes Parse apply the container defaults (see SetContainerMode)
+var containerMode atomic.Bool
+
+// SetContainerMode makes Parse apply defaults suited to running in a container, for
+// settings the configuration leaves unset:
+//   - logs are written to stdout as JSON, for the container runtime to collect
+//   - shutdown keeps serving for 5s with /ready failing, in place of a preStop sleep,
+//     and then drains connections for up to 20s, finishing within Kubernetes' default
+//     30s termination grace period
+func SetContainerMode(enabled bool) {
+	containerMode.Store(enabled)
+}
+
+// applyEnv overrides settings with the BALANCE_LISTEN, BALANCE_MODE,
+// BALANCE_LOG_LEVEL and BALANCE_ADMIN_LISTEN environment variables
+func (c *Config) applyEnv() {
+	if listen := os.Getenv("BALANCE_LISTEN"); listen != "" {
+		c.Listen = listen
+	}
+	if mode := os.Getenv("BALANCE_MODE"); mode != "" {
+		c.Mode = mode
+	}
+	if level := os.Getenv("BALANCE_LOG_LEVEL"); level != "" {
+		if c.Logging == nil {
+			c.Logging = &LoggingConfig{}
+		}
+		c.Logging.Level = level
+	}
+	if listen := os.Getenv("BALANCE_ADMIN_LISTEN"); listen != "" {
+		if c.Admin == nil {
+			c.Admin = &AdminConfig{}
+		}
+		c.Admin.Listen = listen
+	}
+}
+
+// setContainerDefaults applies the defaults described by SetContainerMode
+func (c *Config) setContainerDefaults() {
+	if c.Logging == nil {
+		c.Logging = &LoggingConfig{}
+	}
+	if c.Logging.Format == "" {
+		c.Logging.Format = "json"
+	}
+	if c.Logging.Output == "" {
+		c.Logging.Output = "stdout"
+	}
+
+	if c.Shutdown == nil {
+		c.Shutdown = &ShutdownConfig{}
+	}
+	if c.Shutdown.AnnouncePeriod == 0 {
+		c.Shutdown.AnnouncePeriod = 5 * time.Second
+	}
+	if c.Shutdown.DrainTimeout == 0 {
+		c.Shutdown.DrainTimeout = 20 * time.Second
+	}
+}
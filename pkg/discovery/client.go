@@ -0,0 +1,57 @@
+package discovery
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// requestTimeout bounds registry requests other than long-lived watches
+const requestTimeout = 10 * time.Second
+
+// TLSConfig configures the TLS connection to a registry
+type TLSConfig struct {
+	// CAFile is trusted in addition to the system roots
+	CAFile string
+
+	// CertFile and KeyFile are the client certificate, if the registry requires one
+	CertFile string
+	KeyFile  string
+}
+
+// newHTTPClient returns the HTTP client a provider uses to reach its registry
+// It has no overall timeout, so callers bound requests with their context.
+func newHTTPClient(cfg TLSConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile == "" && cfg.CertFile == "" {
+		return &http.Client{Transport: transport}, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
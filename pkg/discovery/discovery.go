@@ -0,0 +1,352 @@
+package discovery
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+// maxRetryBackoff caps the delay before restarting a provider that failed
+const maxRetryBackoff = 30 * time.Second
+
+// Endpoint is a backend announced by a discovery provider
+type Endpoint struct {
+	// Name identifies the backend in the pool; it must be stable across updates
+	Name     string
+	Address  string
+	Weight   int
+	Priority int
+	Zone     string
+	Labels   map[string]string
+}
+
+// Provider discovers the endpoints of a service
+type Provider interface {
+	// Name identifies the provider in logs
+	Name() string
+
+	// Watch calls update with the complete set of endpoints whenever it changes,
+	// until ctx is done or the provider fails
+	Watch(ctx context.Context, update func([]Endpoint)) error
+}
+
+// ChangeFunc is called after a provider update added or removed backends
+type ChangeFunc func(added, removed []*backend.Backend)
+
+// PrunePolicy removes discovered backends that stay unhealthy from the pool
+// A pruned backend is quarantined: it is not re-added while its provider keeps
+// announcing it at the same address, until the quarantine expires. It is re-admitted
+// as soon as the provider re-announces it, after withdrawing it or with a new address.
+type PrunePolicy struct {
+	// After is how long a backend must be unhealthy before it is pruned
+	After time.Duration
+
+	// Quarantine is how long a pruned backend is kept out (0 = until re-announced)
+	Quarantine time.Duration
+
+	// UnhealthySince reports whether a backend is unhealthy and since when
+	UnhealthySince func(name string) (since time.Time, unhealthy bool)
+}
+
+// QuarantinedBackend is a pruned backend kept out of the pool
+type QuarantinedBackend struct {
+	Name     string    `json:"name"`
+	Address  string    `json:"address"`
+	Provider string    `json:"provider"`
+	Since    time.Time `json:"since"`
+}
+
+// quarantineEntry records a pruned backend and the provider that announced it
+type quarantineEntry struct {
+	QuarantinedBackend
+	provider Provider
+}
+
+// Manager keeps a backend pool in sync with the endpoints announced by providers
+// Each provider owns the backends it added; backends from the configuration or other
+// providers are never modified or removed.
+type Manager struct {
+	pool      *backend.Pool
+	providers []Provider
+	onChange  ChangeFunc
+
+	// owned maps each provider to the names of the backends it added
+	owned map[Provider]map[string]bool
+	mu    sync.Mutex
+
+	// Pruning (zero policy when disabled): last holds each provider's latest
+	// endpoints so quarantined backends can be re-admitted when it expires
+	prune      PrunePolicy
+	quarantine map[string]quarantineEntry
+	last       map[Provider][]Endpoint
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager creates a discovery manager for the pool
+func NewManager(pool *backend.Pool, providers ...Provider) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	owned := make(map[Provider]map[string]bool, len(providers))
+	for _, p := range providers {
+		owned[p] = make(map[string]bool)
+	}
+	return &Manager{
+		pool:       pool,
+		providers:  providers,
+		owned:      owned,
+		quarantine: make(map[string]quarantineEntry),
+		last:       make(map[Provider][]Endpoint),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// OnChange sets the function called after backends are added or removed
+// It must be set before Start.
+func (m *Manager) OnChange(fn ChangeFunc) {
+	m.onChange = fn
+}
+
+// SetPrunePolicy enables pruning of discovered backends that stay unhealthy
+// It must be set before Start.
+func (m *Manager) SetPrunePolicy(policy PrunePolicy) {
+	m.prune = policy
+}
+
+// Start begins watching all providers
+func (m *Manager) Start() {
+	for _, p := range m.providers {
+		log.Printf("[Discovery] Starting provider %s", p.Name())
+		m.wg.Add(1)
+		go m.run(p)
+	}
+	if m.prune.After > 0 && m.prune.UnhealthySince != nil {
+		m.wg.Add(1)
+		go m.runPrune()
+	}
+}
+
+// Stop stops watching; discovered backends stay in the pool
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// run watches a provider, restarting it with backoff when it fails
+func (m *Manager) run(p Provider) {
+	defer m.wg.Done()
+
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := p.Watch(m.ctx, func(endpoints []Endpoint) {
+			m.Apply(p, endpoints)
+		})
+		if m.ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > maxRetryBackoff {
+			backoff = time.Second
+		}
+		log.Printf("[Discovery] Provider %s stopped, restarting in %s: %v", p.Name(), backoff, err)
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// Apply reconciles the pool with the complete set of endpoints announced by a provider
+// New endpoints are added, missing ones removed, and the weight of existing ones
+// updated. An endpoint whose address or other settings changed is replaced.
+func (m *Manager) Apply(p Provider, endpoints []Endpoint) {
+	m.mu.Lock()
+	owned := m.owned[p]
+	m.last[p] = endpoints
+
+	// Keep the backends of the configuration, other providers and the admin API, and
+	// swap this provider's backends for the announced endpoints in one step under the
+	// pool lock, so backends added or removed meanwhile are not lost
+	nextOwned := make(map[string]bool, len(endpoints))
+	seen := make(map[string]bool, len(endpoints))
+	added, removed, err := m.pool.Update(func(current []*backend.Backend) []*backend.Backend {
+		next := make([]*backend.Backend, 0, len(current)+len(endpoints))
+		others := make(map[string]bool)
+		addresses := make(map[string]string)
+		for _, b := range current {
+			if !owned[b.Name()] {
+				next = append(next, b)
+				others[b.Name()] = true
+				addresses[b.Address()] = b.Name()
+			}
+		}
+
+		for _, ep := range endpoints {
+			if ep.Name == "" || seen[ep.Name] {
+				continue
+			}
+			seen[ep.Name] = true
+			if m.quarantined(p, ep) {
+				continue
+			}
+			if err := backend.ValidateAddress(ep.Address); err != nil {
+				log.Printf("[Discovery] Provider %s: ignoring endpoint %s: %v", p.Name(), ep.Name, err)
+				continue
+			}
+			if others[ep.Name] {
+				log.Printf("[Discovery] Provider %s: ignoring endpoint %s, a backend with that name already exists", p.Name(), ep.Name)
+				continue
+			}
+			if other, ok := addresses[ep.Address]; ok {
+				log.Printf("[Discovery] Provider %s: ignoring endpoint %s, backend %s already uses address %s", p.Name(), ep.Name, other, ep.Address)
+				continue
+			}
+			addresses[ep.Address] = ep.Name
+			next = append(next, newBackend(ep))
+			nextOwned[ep.Name] = true
+		}
+		return next
+	})
+	if err != nil {
+		log.Printf("[Discovery] Provider %s: %v", p.Name(), err)
+	}
+	m.owned[p] = nextOwned
+
+	// Withdrawn backends leave quarantine, so announcing them again re-admits them
+	for name, entry := range m.quarantine {
+		if entry.provider == p && !seen[name] {
+			delete(m.quarantine, name)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, b := range removed {
+		log.Printf("[Discovery] Provider %s: removed backend %s (%s)", p.Name(), b.Name(), b.Address())
+	}
+	for _, b := range added {
+		log.Printf("[Discovery] Provider %s: added backend %s (%s)", p.Name(), b.Name(), b.Address())
+	}
+	if m.onChange != nil && (len(added) > 0 || len(removed) > 0) {
+		m.onChange(added, removed)
+	}
+}
+
+// quarantined reports whether an announced endpoint is kept out of the pool
+// The quarantine ends if it expired or the endpoint moved to another address.
+// Callers must hold the lock.
+func (m *Manager) quarantined(p Provider, ep Endpoint) bool {
+	entry, ok := m.quarantine[ep.Name]
+	if !ok || entry.provider != p {
+		return false
+	}
+	expired := m.prune.Quarantine > 0 && time.Since(entry.Since) >= m.prune.Quarantine
+	if expired || entry.Address != ep.Address {
+		delete(m.quarantine, ep.Name)
+		log.Printf("[Discovery] Provider %s: re-admitting quarantined backend %s (%s)", p.Name(), ep.Name, ep.Address)
+		return false
+	}
+	return true
+}
+
+// runPrune periodically prunes backends that have been unhealthy for too long
+func (m *Manager) runPrune() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(min(max(m.prune.After/2, time.Millisecond), 10*time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.Prune()
+		}
+	}
+}
+
+// Prune removes discovered backends that have been unhealthy for longer than the
+// prune policy allows and quarantines them; it also re-admits backends whose
+// quarantine has expired
+func (m *Manager) Prune() {
+	m.mu.Lock()
+	var removed []*backend.Backend
+	var providers []Provider
+	for p, owned := range m.owned {
+		for name := range owned {
+			since, unhealthy := m.prune.UnhealthySince(name)
+			if !unhealthy || time.Since(since) < m.prune.After {
+				continue
+			}
+			b := m.pool.Get(name)
+			delete(owned, name)
+			if b == nil {
+				continue
+			}
+			m.pool.Remove(name)
+			removed = append(removed, b)
+			m.quarantine[name] = quarantineEntry{
+				QuarantinedBackend: QuarantinedBackend{
+					Name:     name,
+					Address:  b.Address(),
+					Provider: p.Name(),
+					Since:    time.Now(),
+				},
+				provider: p,
+			}
+			log.Printf("[Discovery] Provider %s: pruned backend %s (%s), unhealthy since %s",
+				p.Name(), name, b.Address(), since.Format(time.RFC3339))
+		}
+	}
+
+	// Providers with expired quarantines apply their latest endpoints again
+	if m.prune.Quarantine > 0 {
+		for _, entry := range m.quarantine {
+			if time.Since(entry.Since) >= m.prune.Quarantine && !slices.Contains(providers, entry.provider) {
+				providers = append(providers, entry.provider)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	if m.onChange != nil && len(removed) > 0 {
+		m.onChange(nil, removed)
+	}
+	for _, p := range providers {
+		m.mu.Lock()
+		endpoints := m.last[p]
+		m.mu.Unlock()
+		m.Apply(p, endpoints)
+	}
+}
+
+// Quarantined returns the pruned backends currently kept out of the pool
+func (m *Manager) Quarantined() []QuarantinedBackend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]QuarantinedBackend, 0, len(m.quarantine))
+	for _, entry := range m.quarantine {
+		result = append(result, entry.QuarantinedBackend)
+	}
+	slices.SortFunc(result, func(a, b QuarantinedBackend) int { return strings.Compare(a.Name, b.Name) })
+	return result
+}
+
+// newBackend creates a pool backend for an endpoint
+func newBackend(ep Endpoint) *backend.Backend {
+	b := backend.NewBackend(ep.Name, ep.Address, ep.Weight)
+	b.SetPriority(ep.Priority)
+	b.SetZone(ep.Zone)
+	b.SetLabels(ep.Labels)
+	return b
+}
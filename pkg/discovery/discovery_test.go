@@ -0,0 +1,231 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+// staticProvider announces a fixed endpoint set once
+type staticProvider struct {
+	endpoints []Endpoint
+}
+
+func (p *staticProvider) Name() string { return "static" }
+
+func (p *staticProvider) Watch(ctx context.Context, update func([]Endpoint)) error {
+	update(p.endpoints)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestManagerApply(t *testing.T) {
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("static", "10.0.0.1:80", 1))
+
+	provider := &staticProvider{}
+	m := NewManager(pool, provider)
+	var added, removed []string
+	m.OnChange(func(a, r []*backend.Backend) {
+		for _, b := range a {
+			added = append(added, b.Name())
+		}
+		for _, b := range r {
+			removed = append(removed, b.Name())
+		}
+	})
+
+	m.Apply(provider, []Endpoint{
+		{Name: "a", Address: "10.0.1.1:80", Weight: 1},
+		{Name: "b", Address: "10.0.1.2:80", Weight: 2},
+		{Name: "static", Address: "10.0.9.9:80", Weight: 1},
+		{Name: "copy", Address: "10.0.0.1:80", Weight: 1},
+	})
+	if pool.Size() != 3 {
+		t.Fatalf("Expected 3 backends, got %d", pool.Size())
+	}
+	if got := pool.Get("static").Address(); got != "10.0.0.1:80" {
+		t.Errorf("Expected the configured backend to be left alone, got address %s", got)
+	}
+	if pool.Get("copy") != nil {
+		t.Error("Expected the endpoint at the configured backend's address to be ignored")
+	}
+	if len(added) != 2 || len(removed) != 0 {
+		t.Errorf("Expected 2 added and 0 removed, got %v and %v", added, removed)
+	}
+
+	// Update a weight, move b to another address, and drop a
+	added, removed = nil, nil
+	m.Apply(provider, []Endpoint{
+		{Name: "b", Address: "10.0.1.3:80", Weight: 5, Priority: 1},
+		{Name: "c", Address: "10.0.1.4:80", Weight: 3},
+	})
+	if pool.Get("a") != nil {
+		t.Error("Expected a to be removed")
+	}
+	b := pool.Get("b")
+	if b == nil || b.Address() != "10.0.1.3:80" || b.Weight() != 5 || b.Priority() != 1 {
+		t.Errorf("Expected b at its new address with weight 5 and priority 1, got %+v", b)
+	}
+	if pool.Get("static") == nil {
+		t.Error("Expected the configured backend to stay")
+	}
+	if len(added) != 2 || len(removed) != 2 {
+		t.Errorf("Expected 2 added and 2 removed, got %v and %v", added, removed)
+	}
+
+	// Weight changes alone do not replace the backend
+	added, removed = nil, nil
+	m.Apply(provider, []Endpoint{
+		{Name: "b", Address: "10.0.1.3:80", Weight: 7, Priority: 1},
+		{Name: "c", Address: "10.0.1.4:80", Weight: 3},
+	})
+	if pool.Get("b") != b || b.Weight() != 7 {
+		t.Errorf("Expected b to be updated in place with weight 7, got %d", pool.Get("b").Weight())
+	}
+	if added != nil || removed != nil {
+		t.Errorf("Expected no changes, got %v and %v", added, removed)
+	}
+
+	m.Apply(provider, nil)
+	if pool.Size() != 1 || pool.Get("static") == nil {
+		t.Errorf("Expected only the configured backend to remain, got %d backends", pool.Size())
+	}
+}
+
+func TestManagerApplyConcurrentAdd(t *testing.T) {
+	pool := backend.NewPool()
+	provider := &staticProvider{}
+	m := NewManager(pool, provider)
+
+	// Backends added through the pool while the provider updates are kept
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			m.Apply(provider, []Endpoint{{Name: "a", Address: fmt.Sprintf("10.0.1.1:%d", 1000+i), Weight: 1}})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		pool.Add(backend.NewBackend(fmt.Sprintf("admin-%d", i), fmt.Sprintf("10.0.2.1:%d", 1000+i), 1))
+	}
+	<-done
+
+	if size := pool.Size(); size != 101 {
+		t.Errorf("Expected 101 backends, got %d", size)
+	}
+}
+
+func TestManagerStartStop(t *testing.T) {
+	pool := backend.NewPool()
+	m := NewManager(pool, &staticProvider{endpoints: []Endpoint{{Name: "a", Address: "10.0.1.1:80", Weight: 1}}})
+	m.Start()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Get("a") == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Stop()
+
+	if pool.Get("a") == nil {
+		t.Error("Expected the discovered backend to be added and kept after Stop")
+	}
+}
+
+func TestManagerPrune(t *testing.T) {
+	pool := backend.NewPool()
+	provider := &staticProvider{}
+	m := NewManager(pool, provider)
+
+	unhealthy := map[string]time.Time{"bad": time.Now().Add(-time.Hour), "flaky": time.Now()}
+	m.SetPrunePolicy(PrunePolicy{
+		After: time.Minute,
+		UnhealthySince: func(name string) (time.Time, bool) {
+			since, ok := unhealthy[name]
+			return since, ok
+		},
+	})
+	var removed []string
+	m.OnChange(func(_, r []*backend.Backend) {
+		for _, b := range r {
+			removed = append(removed, b.Name())
+		}
+	})
+
+	endpoints := []Endpoint{
+		{Name: "good", Address: "10.0.0.1:80", Weight: 1},
+		{Name: "bad", Address: "10.0.0.2:80", Weight: 1},
+		{Name: "flaky", Address: "10.0.0.3:80", Weight: 1},
+	}
+	m.Apply(provider, endpoints)
+	m.Prune()
+
+	// Only the backend unhealthy for longer than After is pruned
+	if pool.Get("bad") != nil || pool.Get("good") == nil || pool.Get("flaky") == nil {
+		t.Fatalf("Expected only bad to be pruned, pool has %d backends", pool.Size())
+	}
+	if len(removed) != 1 || removed[0] != "bad" {
+		t.Errorf("Expected the change function to see bad removed, got %v", removed)
+	}
+	if q := m.Quarantined(); len(q) != 1 || q[0].Name != "bad" || q[0].Address != "10.0.0.2:80" || q[0].Provider != "static" {
+		t.Errorf("Expected bad to be quarantined, got %+v", q)
+	}
+
+	// Announcing it again unchanged keeps it out
+	delete(unhealthy, "bad")
+	m.Apply(provider, endpoints)
+	if pool.Get("bad") != nil {
+		t.Error("Expected bad to stay quarantined while announced at the same address")
+	}
+
+	// Withdrawing and re-announcing it re-admits it
+	m.Apply(provider, endpoints[:1])
+	if len(m.Quarantined()) != 0 {
+		t.Error("Expected the quarantine to end when the endpoint is withdrawn")
+	}
+	m.Apply(provider, endpoints)
+	if pool.Get("bad") == nil {
+		t.Error("Expected bad to be re-admitted when re-announced")
+	}
+
+	// A new address also re-admits a quarantined backend
+	unhealthy["bad"] = time.Now().Add(-time.Hour)
+	m.Prune()
+	delete(unhealthy, "bad")
+	m.Apply(provider, []Endpoint{endpoints[0], {Name: "bad", Address: "10.0.0.9:80", Weight: 1}})
+	if b := pool.Get("bad"); b == nil || b.Address() != "10.0.0.9:80" {
+		t.Error("Expected bad to be re-admitted at its new address")
+	}
+}
+
+func TestManagerQuarantineExpiry(t *testing.T) {
+	pool := backend.NewPool()
+	provider := &staticProvider{}
+	m := NewManager(pool, provider)
+
+	pruned := false
+	m.SetPrunePolicy(PrunePolicy{
+		After:      time.Minute,
+		Quarantine: 20 * time.Millisecond,
+		UnhealthySince: func(string) (time.Time, bool) {
+			return time.Now().Add(-time.Hour), !pruned
+		},
+	})
+
+	m.Apply(provider, []Endpoint{{Name: "bad", Address: "10.0.0.2:80", Weight: 1}})
+	m.Prune()
+	pruned = true
+	if pool.Get("bad") != nil {
+		t.Fatal("Expected bad to be pruned")
+	}
+
+	// Once the quarantine expires, the provider's latest endpoints are applied again
+	time.Sleep(30 * time.Millisecond)
+	m.Prune()
+	if pool.Get("bad") == nil {
+		t.Error("Expected bad to be re-admitted after the quarantine expired")
+	}
+}
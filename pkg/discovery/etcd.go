@@ -0,0 +1,296 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// EtcdConfig configures an etcd provider
+type EtcdConfig struct {
+	// Endpoints are the etcd client URLs, tried in order (e.g., "http://127.0.0.1:2379")
+	Endpoints []string
+
+	// Prefix is the key prefix backends are registered under
+	Prefix string
+
+	// Username and Password authenticate to etcd if set
+	Username string
+	Password string
+
+	// TLS configures HTTPS endpoints
+	TLS TLSConfig
+}
+
+// EtcdProvider discovers backends registered as keys under a prefix in etcd
+// Each key holds a JSON registration ({"address": "10.0.0.1:8080", "weight": 2});
+// the part of the key after the prefix is the backend name. The provider lists the
+// prefix, then watches it and announces every change. It uses the etcd v3 JSON
+// gateway, so no etcd client library is needed.
+type EtcdProvider struct {
+	endpoints []string
+	prefix    string
+	username  string
+	password  string
+	client    *http.Client
+}
+
+// etcdRegistration is the value of a backend key
+type etcdRegistration struct {
+	Address  string            `json:"address"`
+	Weight   int               `json:"weight"`
+	Priority int               `json:"priority"`
+	Zone     string            `json:"zone"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// etcdKV is a key-value pair in gateway responses; bytes are base64 encoded
+type etcdKV struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// etcdHeader is the response header carrying the store revision
+type etcdHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+// NewEtcdProvider creates an etcd provider
+func NewEtcdProvider(cfg EtcdConfig) (*EtcdProvider, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, errors.New("at least one etcd endpoint is required")
+	}
+	client, err := newHTTPClient(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]string, len(cfg.Endpoints))
+	for i, endpoint := range cfg.Endpoints {
+		endpoints[i] = strings.TrimSuffix(endpoint, "/")
+	}
+	return &EtcdProvider{
+		endpoints: endpoints,
+		prefix:    cfg.Prefix,
+		username:  cfg.Username,
+		password:  cfg.Password,
+		client:    client,
+	}, nil
+}
+
+// Name identifies the provider in logs
+func (p *EtcdProvider) Name() string {
+	return "etcd:" + p.prefix
+}
+
+// Watch announces the registered backends, then every change to them
+// Endpoints are tried in order; it returns once the watch on every endpoint has ended.
+func (p *EtcdProvider) Watch(ctx context.Context, update func([]Endpoint)) error {
+	var errs []error
+	for _, endpoint := range p.endpoints {
+		err := p.watchEndpoint(ctx, endpoint, update)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+	}
+	return errors.Join(errs...)
+}
+
+// watchEndpoint lists and then watches the prefix on one etcd endpoint
+func (p *EtcdProvider) watchEndpoint(ctx context.Context, endpoint string, update func([]Endpoint)) error {
+	token, err := p.authenticate(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+
+	var listed struct {
+		Header etcdHeader `json:"header"`
+		KVs    []etcdKV   `json:"kvs"`
+	}
+	listCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	resp, err := p.post(listCtx, endpoint, "/v3/kv/range", token, p.keyRange())
+	if err == nil {
+		err = decodeEtcdResponse(resp, &listed)
+	}
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", p.prefix, err)
+	}
+
+	registered := make(map[string]Endpoint, len(listed.KVs))
+	for _, kv := range listed.KVs {
+		p.put(registered, kv)
+	}
+	update(sortedEndpoints(registered))
+
+	// Watch for changes after the listed revision
+	watch := p.keyRange()
+	watch["start_revision"] = listed.Header.Revision + 1
+	resp, err = p.post(ctx, endpoint, "/v3/watch", token, map[string]any{"create_request": watch})
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", p.prefix, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decodeEtcdResponse(resp, nil)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Events []struct {
+					Type string `json:"type"`
+					KV   etcdKV `json:"kv"`
+				} `json:"events"`
+				Canceled        bool   `json:"canceled"`
+				CancelReason    string `json:"cancel_reason"`
+				CompactRevision string `json:"compact_revision"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := decoder.Decode(&msg); err != nil {
+			return fmt.Errorf("watch stream ended: %w", err)
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("watch failed: %s", msg.Error.Message)
+		}
+		if msg.Result.Canceled {
+			// Also returned when the start revision was compacted; listing again recovers
+			return fmt.Errorf("watch canceled: %s (compact revision %s)", msg.Result.CancelReason, msg.Result.CompactRevision)
+		}
+		if len(msg.Result.Events) == 0 {
+			continue
+		}
+
+		for _, event := range msg.Result.Events {
+			if event.Type == "DELETE" {
+				delete(registered, p.backendName(event.KV.Key))
+			} else {
+				p.put(registered, event.KV)
+			}
+		}
+		update(sortedEndpoints(registered))
+	}
+}
+
+// authenticate returns an auth token for endpoint ("" if no username is configured)
+func (p *EtcdProvider) authenticate(ctx context.Context, endpoint string) (string, error) {
+	if p.username == "" {
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	resp, err := p.post(ctx, endpoint, "/v3/auth/authenticate", "", map[string]any{
+		"name":     p.username,
+		"password": p.password,
+	})
+	var auth struct {
+		Token string `json:"token"`
+	}
+	if err == nil {
+		err = decodeEtcdResponse(resp, &auth)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to authenticate: %w", err)
+	}
+	return auth.Token, nil
+}
+
+// put adds or replaces the endpoint registered in kv
+// Values that are not valid registrations are logged and leave the key unregistered.
+func (p *EtcdProvider) put(registered map[string]Endpoint, kv etcdKV) {
+	name := p.backendName(kv.Key)
+	var reg etcdRegistration
+	if err := json.Unmarshal(kv.Value, &reg); err != nil || reg.Address == "" {
+		log.Printf("[Discovery] etcd key %s is not a valid backend registration", kv.Key)
+		delete(registered, name)
+		return
+	}
+	registered[name] = Endpoint{
+		Name:     name,
+		Address:  reg.Address,
+		Weight:   max(reg.Weight, 1),
+		Priority: reg.Priority,
+		Zone:     reg.Zone,
+		Labels:   reg.Labels,
+	}
+}
+
+// backendName returns the part of key after the prefix
+func (p *EtcdProvider) backendName(key []byte) string {
+	return strings.TrimPrefix(string(key), p.prefix)
+}
+
+// keyRange returns the request fields selecting every key under the prefix
+func (p *EtcdProvider) keyRange() map[string]any {
+	return map[string]any{
+		"key":       []byte(p.prefix),
+		"range_end": prefixEnd([]byte(p.prefix)),
+	}
+}
+
+// post sends a JSON request to the etcd gateway
+func (p *EtcdProvider) post(ctx context.Context, endpoint, path, token string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	return p.client.Do(req)
+}
+
+// decodeEtcdResponse decodes a successful response into v and closes its body
+func decodeEtcdResponse(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("etcd returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// prefixEnd returns the end of the key range covering every key with prefix
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// The prefix is empty or all 0xff: the range ends at the last key
+	return []byte{0}
+}
+
+// sortedEndpoints returns the endpoints of a registration map sorted by name
+func sortedEndpoints(registered map[string]Endpoint) []Endpoint {
+	names := slices.Sorted(maps.Keys(registered))
+	endpoints := make([]Endpoint, len(names))
+	for i, name := range names {
+		endpoints[i] = registered[name]
+	}
+	return endpoints
+}
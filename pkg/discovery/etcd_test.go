@@ -0,0 +1,121 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEtcdProvider(t *testing.T) {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	events := make(chan string, 4)
+
+	etcd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch r.URL.Path {
+		case "/v3/auth/authenticate":
+			if body["name"] != "balance" || body["password"] != "secret" {
+				http.Error(w, `{"error":"authentication failed"}`, http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"tok"}`)
+		case "/v3/kv/range":
+			if r.Header.Get("Authorization") != "tok" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if body["key"] != b64("/svc/") || body["range_end"] != b64("/svc0") {
+				t.Errorf("Unexpected range %v", body)
+			}
+			fmt.Fprintf(w, `{"header":{"revision":"7"},"kvs":[{"key":%q,"value":%q},{"key":%q,"value":%q}]}`,
+				b64("/svc/api-1"), b64(`{"address":"10.0.0.1:8080","weight":3,"zone":"a"}`),
+				b64("/svc/broken"), b64(`not json`))
+		case "/v3/watch":
+			create, _ := body["create_request"].(map[string]any)
+			if create["start_revision"] != float64(8) {
+				t.Errorf("Expected the watch to start at revision 8, got %v", create["start_revision"])
+			}
+			fmt.Fprint(w, `{"result":{"header":{"revision":"7"},"created":true}}`)
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case event := <-events:
+					fmt.Fprint(w, event)
+					w.(http.Flusher).Flush()
+				case <-r.Context().Done():
+					return
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer etcd.Close()
+
+	provider, err := NewEtcdProvider(EtcdConfig{
+		Endpoints: []string{"http://127.0.0.1:1", etcd.URL},
+		Prefix:    "/svc/",
+		Username:  "balance",
+		Password:  "secret",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []Endpoint, 4)
+	go provider.Watch(ctx, func(endpoints []Endpoint) { updates <- endpoints })
+
+	next := func() []Endpoint {
+		select {
+		case endpoints := <-updates:
+			return endpoints
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for an update")
+			return nil
+		}
+	}
+
+	// The first endpoint is unreachable, so the listing comes from the second
+	endpoints := next()
+	if len(endpoints) != 1 || endpoints[0].Name != "api-1" || endpoints[0].Address != "10.0.0.1:8080" ||
+		endpoints[0].Weight != 3 || endpoints[0].Zone != "a" {
+		t.Fatalf("Expected api-1 only, got %+v", endpoints)
+	}
+
+	events <- fmt.Sprintf(`{"result":{"events":[{"kv":{"key":%q,"value":%q}}]}}`, b64("/svc/api-2"), b64(`{"address":"10.0.0.2:8080"}`))
+	endpoints = next()
+	if len(endpoints) != 2 || endpoints[1].Name != "api-2" || endpoints[1].Weight != 1 {
+		t.Fatalf("Expected api-1 and api-2 with default weight, got %+v", endpoints)
+	}
+
+	events <- fmt.Sprintf(`{"result":{"events":[{"type":"DELETE","kv":{"key":%q}}]}}`, b64("/svc/api-1"))
+	endpoints = next()
+	if len(endpoints) != 1 || endpoints[0].Name != "api-2" {
+		t.Fatalf("Expected api-2 only, got %+v", endpoints)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"/svc/", "/svc0"},
+		{"a\xff", "b"},
+		{"", "\x00"},
+	}
+	for _, tt := range tests {
+		if got := string(prefixEnd([]byte(tt.prefix))); got != tt.want {
+			t.Errorf("prefixEnd(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
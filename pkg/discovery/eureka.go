@@ -0,0 +1,296 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EurekaConfig configures a Eureka provider
+type EurekaConfig struct {
+	// ServiceURLs are the Eureka server URLs, tried in order (e.g., "http://eureka:8761/eureka")
+	// Basic auth credentials can be included in the URL.
+	ServiceURLs []string
+
+	// Applications are the names of the applications whose instances become backends
+	Applications []string
+
+	// RefreshInterval is how often the registry is polled
+	RefreshInterval time.Duration
+
+	// TLS configures HTTPS service URLs
+	TLS TLSConfig
+}
+
+// EurekaProvider discovers backends from the instances of applications registered
+// in a Netflix Eureka server, as used by Spring Cloud
+// Like Eureka clients, it fetches the full registry once and then polls for deltas,
+// falling back to a full fetch when the registry's reconcile hash code disagrees
+// with the local copy. Instances with status UP become backends named by their
+// instance ID and addressed by IP and port; the "weight" and "zone" metadata keys
+// set the backend weight and zone, and all metadata becomes backend labels.
+type EurekaProvider struct {
+	serviceURLs  []string
+	applications map[string]bool
+	interval     time.Duration
+	client       *http.Client
+
+	// registry holds every instance of every application by app and instance ID,
+	// since the reconcile hash code covers the whole registry
+	registry map[string]map[string]eurekaInstance
+}
+
+// eurekaApplications is the registry, or a delta of it, as returned by the REST API
+type eurekaApplications struct {
+	HashCode     string                `json:"apps__hashcode"`
+	Applications eurekaList[eurekaApp] `json:"application"`
+}
+
+// eurekaApp is a registered application
+type eurekaApp struct {
+	Name      string                     `json:"name"`
+	Instances eurekaList[eurekaInstance] `json:"instance"`
+}
+
+// eurekaInstance is a registered application instance
+type eurekaInstance struct {
+	InstanceID string `json:"instanceId"`
+	HostName   string `json:"hostName"`
+	IPAddr     string `json:"ipAddr"`
+	Status     string `json:"status"`
+	Port       struct {
+		Port int `json:"$"`
+	} `json:"port"`
+	Metadata   map[string]string `json:"metadata"`
+	ActionType string            `json:"actionType"`
+}
+
+// eurekaList is a JSON list that Eureka encodes as a single object when it has one element
+type eurekaList[T any] []T
+
+// UnmarshalJSON accepts an array or a single object
+func (l *eurekaList[T]) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		var item T
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+		*l = eurekaList[T]{item}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]T)(l))
+}
+
+// NewEurekaProvider creates a Eureka provider
+func NewEurekaProvider(cfg EurekaConfig) (*EurekaProvider, error) {
+	if len(cfg.ServiceURLs) == 0 {
+		return nil, errors.New("at least one Eureka service URL is required")
+	}
+	client, err := newHTTPClient(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceURLs := make([]string, len(cfg.ServiceURLs))
+	for i, u := range cfg.ServiceURLs {
+		serviceURLs[i] = strings.TrimSuffix(u, "/")
+	}
+	applications := make(map[string]bool, len(cfg.Applications))
+	for _, app := range cfg.Applications {
+		applications[strings.ToUpper(app)] = true
+	}
+	return &EurekaProvider{
+		serviceURLs:  serviceURLs,
+		applications: applications,
+		interval:     cfg.RefreshInterval,
+		client:       client,
+	}, nil
+}
+
+// Name identifies the provider in logs
+func (p *EurekaProvider) Name() string {
+	return "eureka:" + strings.Join(slices.Sorted(maps.Keys(p.applications)), ",")
+}
+
+// Watch polls the registry every refresh interval and announces the instances of the
+// configured applications
+// Failed polls keep the previous instances and are retried on the next interval.
+func (p *EurekaProvider) Watch(ctx context.Context, update func([]Endpoint)) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.refresh(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("[Discovery] Eureka registry fetch failed: %v", err)
+		} else {
+			update(p.endpoints())
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh brings the local registry up to date, applying a delta when possible
+func (p *EurekaProvider) refresh(ctx context.Context) error {
+	if p.registry != nil {
+		delta, err := p.fetch(ctx, "/apps/delta")
+		if err == nil {
+			p.applyDelta(delta)
+			hash := p.hashCode()
+			if hash == delta.HashCode {
+				return nil
+			}
+			log.Printf("[Discovery] Eureka registry hash code %s does not match %s, fetching the full registry", hash, delta.HashCode)
+		} else {
+			log.Printf("[Discovery] Eureka delta fetch failed, fetching the full registry: %v", err)
+		}
+	}
+
+	full, err := p.fetch(ctx, "/apps")
+	if err != nil {
+		return err
+	}
+	p.registry = make(map[string]map[string]eurekaInstance, len(full.Applications))
+	for _, app := range full.Applications {
+		for _, instance := range app.Instances {
+			p.put(app.Name, instance)
+		}
+	}
+	return nil
+}
+
+// applyDelta applies the added, modified and deleted instances of a delta
+func (p *EurekaProvider) applyDelta(delta *eurekaApplications) {
+	for _, app := range delta.Applications {
+		for _, instance := range app.Instances {
+			switch instance.ActionType {
+			case "ADDED", "MODIFIED":
+				p.put(app.Name, instance)
+			case "DELETED":
+				name := strings.ToUpper(app.Name)
+				delete(p.registry[name], instance.id())
+				if len(p.registry[name]) == 0 {
+					delete(p.registry, name)
+				}
+			}
+		}
+	}
+}
+
+// put adds or replaces an instance in the local registry
+func (p *EurekaProvider) put(app string, instance eurekaInstance) {
+	name := strings.ToUpper(app)
+	if p.registry[name] == nil {
+		p.registry[name] = make(map[string]eurekaInstance)
+	}
+	p.registry[name][instance.id()] = instance
+}
+
+// hashCode returns the reconcile hash code of the local registry: the count of
+// instances in each status, ordered by status (e.g., "DOWN_1_UP_3_")
+func (p *EurekaProvider) hashCode() string {
+	counts := make(map[string]int)
+	for _, instances := range p.registry {
+		for _, instance := range instances {
+			counts[instance.Status]++
+		}
+	}
+
+	var b strings.Builder
+	for _, status := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Fprintf(&b, "%s_%d_", status, counts[status])
+	}
+	return b.String()
+}
+
+// endpoints returns the UP instances of the configured applications
+func (p *EurekaProvider) endpoints() []Endpoint {
+	registered := make(map[string]Endpoint)
+	for app := range p.applications {
+		for _, instance := range p.registry[app] {
+			if instance.Status != "UP" || instance.Port.Port == 0 {
+				continue
+			}
+			host := instance.IPAddr
+			if host == "" {
+				host = instance.HostName
+			}
+			weight, _ := strconv.Atoi(instance.Metadata["weight"])
+			registered[instance.id()] = Endpoint{
+				Name:    instance.id(),
+				Address: net.JoinHostPort(host, strconv.Itoa(instance.Port.Port)),
+				Weight:  max(weight, 1),
+				Zone:    instance.Metadata["zone"],
+				Labels:  instance.Metadata,
+			}
+		}
+	}
+	return sortedEndpoints(registered)
+}
+
+// fetch gets the registry or a delta from the first service URL that responds
+func (p *EurekaProvider) fetch(ctx context.Context, path string) (*eurekaApplications, error) {
+	var errs []error
+	for _, serviceURL := range p.serviceURLs {
+		apps, err := p.get(ctx, serviceURL+path)
+		if err == nil {
+			return apps, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", serviceURL, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// get fetches and decodes one registry document
+func (p *EurekaProvider) get(ctx context.Context, url string) (*eurekaApplications, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("eureka returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var doc struct {
+		Applications eurekaApplications `json:"applications"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid registry response: %w", err)
+	}
+	return &doc.Applications, nil
+}
+
+// id returns the instance ID, falling back to the host name for old registrations
+func (i eurekaInstance) id() string {
+	if i.InstanceID != "" {
+		return i.InstanceID
+	}
+	return i.HostName
+}
@@ -0,0 +1,117 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEurekaProvider(t *testing.T) {
+	var mu sync.Mutex
+	fullFetches := 0
+	full := `{"applications":{"apps__hashcode":"DOWN_1_UP_2_","application":[
+		{"name":"ORDERS","instance":[
+			{"instanceId":"orders-1","hostName":"orders-1.local","ipAddr":"10.0.0.1","status":"UP","port":{"$":8080,"@enabled":"true"},"metadata":{"weight":"3","zone":"a"}},
+			{"instanceId":"orders-2","hostName":"orders-2.local","ipAddr":"10.0.0.2","status":"DOWN","port":{"$":8080,"@enabled":"true"}}
+		]},
+		{"name":"BILLING","instance":{"instanceId":"billing-1","ipAddr":"10.0.1.1","status":"UP","port":{"$":9090}}}
+	]}}`
+	delta := `{"applications":{"apps__hashcode":"UP_3_","application":{"name":"ORDERS","instance":[
+		{"instanceId":"orders-2","ipAddr":"10.0.0.2","status":"UP","port":{"$":8080},"actionType":"MODIFIED"},
+		{"instanceId":"orders-3","ipAddr":"10.0.0.3","status":"UP","port":{"$":8080},"actionType":"ADDED"},
+		{"instanceId":"orders-1","actionType":"DELETED"}
+	]}}}`
+
+	eureka := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" {
+			t.Errorf("Expected a JSON request, got Accept %q", r.Header.Get("Accept"))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/eureka/apps":
+			fullFetches++
+			fmt.Fprint(w, full)
+		case "/eureka/apps/delta":
+			fmt.Fprint(w, delta)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer eureka.Close()
+
+	provider, err := NewEurekaProvider(EurekaConfig{
+		ServiceURLs:     []string{eureka.URL + "/eureka/"},
+		Applications:    []string{"orders"},
+		RefreshInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []Endpoint, 16)
+	go provider.Watch(ctx, func(endpoints []Endpoint) { updates <- endpoints })
+
+	next := func() []Endpoint {
+		select {
+		case endpoints := <-updates:
+			return endpoints
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for an update")
+			return nil
+		}
+	}
+
+	// Only UP instances of the configured application
+	endpoints := next()
+	if len(endpoints) != 1 || endpoints[0].Name != "orders-1" || endpoints[0].Address != "10.0.0.1:8080" ||
+		endpoints[0].Weight != 3 || endpoints[0].Zone != "a" {
+		t.Fatalf("Expected orders-1 only, got %+v", endpoints)
+	}
+
+	// The delta brings orders-2 up, adds orders-3 and removes orders-1; its hash code
+	// matches, so no full fetch is needed
+	endpoints = next()
+	if len(endpoints) != 2 || endpoints[0].Name != "orders-2" || endpoints[1].Name != "orders-3" {
+		t.Fatalf("Expected orders-2 and orders-3, got %+v", endpoints)
+	}
+	mu.Lock()
+	if fullFetches != 1 {
+		t.Errorf("Expected 1 full fetch, got %d", fullFetches)
+	}
+	// A hash code mismatch on the next delta triggers a full fetch
+	delta = `{"applications":{"apps__hashcode":"UP_9_","application":[]}}`
+	mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		next()
+		mu.Lock()
+		fetches := fullFetches
+		mu.Unlock()
+		if fetches >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a full fetch after a hash code mismatch")
+		}
+	}
+}
+
+func TestEurekaHashCode(t *testing.T) {
+	p := &EurekaProvider{registry: make(map[string]map[string]eurekaInstance)}
+	p.put("a", eurekaInstance{InstanceID: "1", Status: "UP"})
+	p.put("a", eurekaInstance{InstanceID: "2", Status: "UP"})
+	p.put("b", eurekaInstance{InstanceID: "3", Status: "OUT_OF_SERVICE"})
+	p.put("b", eurekaInstance{InstanceID: "4", Status: "DOWN"})
+
+	if got := p.hashCode(); got != "DOWN_1_OUT_OF_SERVICE_1_UP_2_" {
+		t.Errorf("Unexpected hash code %s", got)
+	}
+}
@@ -0,0 +1,151 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileCheckInterval is how often a backends file is checked for changes
+const fileCheckInterval = 2 * time.Second
+
+// FileProvider reads backends from a YAML file and reloads it when it changes,
+// so tooling can add and remove backends by rewriting one small file
+// The file has a backends list with the name, address, weight, priority, zone and
+// labels fields of the main configuration's backends. An invalid or empty file is
+// logged and the previous backends are kept.
+type FileProvider struct {
+	path     string
+	interval time.Duration
+
+	endpoints []Endpoint
+	modTime   time.Time
+	size      int64
+}
+
+// backendsFile is the format of a backends file
+type backendsFile struct {
+	Backends []struct {
+		Name     string            `yaml:"name"`
+		Address  string            `yaml:"address"`
+		Weight   int               `yaml:"weight"`
+		Priority int               `yaml:"priority"`
+		Zone     string            `yaml:"zone"`
+		Labels   map[string]string `yaml:"labels"`
+	} `yaml:"backends"`
+}
+
+// NewFileProvider creates a provider for a backends file, which must be valid
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path, interval: fileCheckInterval}
+	if _, err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Name identifies the provider in logs
+func (p *FileProvider) Name() string {
+	return "file:" + p.path
+}
+
+// Watch announces the backends in the file, then again every time it changes
+func (p *FileProvider) Watch(ctx context.Context, update func([]Endpoint)) error {
+	update(p.endpoints)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		changed, err := p.reload()
+		if err != nil {
+			log.Printf("[Discovery] Keeping the previous backends: %v", err)
+			continue
+		}
+		if changed {
+			log.Printf("[Discovery] Reloaded %s with %d backends", p.path, len(p.endpoints))
+			update(p.endpoints)
+		}
+	}
+}
+
+// reload reads the file if it changed since it was last checked
+func (p *FileProvider) reload() (bool, error) {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read backends file: %w", err)
+	}
+	if p.endpoints != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return false, nil
+	}
+
+	// An invalid file may still be being written, so it is read again on the next check
+	endpoints, err := loadBackendsFile(p.path)
+	if err != nil {
+		return false, err
+	}
+	p.endpoints = endpoints
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	return true, nil
+}
+
+// loadBackendsFile parses and validates a backends file
+func loadBackendsFile(path string) ([]Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backends file: %w", err)
+	}
+	var file backendsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse backends file %s: %w", path, err)
+	}
+
+	// An empty file is usually one caught mid-write; removing every backend at once is
+	// never what an update means
+	if len(file.Backends) == 0 {
+		return nil, fmt.Errorf("backends file %s has no backends", path)
+	}
+
+	endpoints := make([]Endpoint, 0, len(file.Backends))
+	names := make(map[string]bool, len(file.Backends))
+	for i, b := range file.Backends {
+		if b.Address == "" {
+			return nil, fmt.Errorf("backends file %s: backend %d: address is required", path, i)
+		}
+		if b.Weight < 0 || b.Priority < 0 {
+			return nil, fmt.Errorf("backends file %s: backend %d: weight and priority must be non-negative", path, i)
+		}
+		name := b.Name
+		if name == "" {
+			name = b.Address
+		}
+		if names[name] {
+			return nil, fmt.Errorf("backends file %s: duplicate backend name %s", path, name)
+		}
+		names[name] = true
+
+		weight := b.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		endpoints = append(endpoints, Endpoint{
+			Name:     name,
+			Address:  b.Address,
+			Weight:   weight,
+			Priority: b.Priority,
+			Zone:     b.Zone,
+			Labels:   b.Labels,
+		})
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,116 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	write := func(content string) {
+		t.Helper()
+		// Replace the file atomically, as the docs ask tooling to
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write backends file: %v", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatalf("Failed to replace backends file: %v", err)
+		}
+	}
+
+	write("backends:\n  - name: api-1\n    address: 10.0.0.1:8080\n    weight: 2\n  - address: 10.0.0.2:8080\n")
+	provider, err := NewFileProvider(path)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	provider.interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []Endpoint, 4)
+	go provider.Watch(ctx, func(endpoints []Endpoint) { updates <- endpoints })
+
+	next := func() []Endpoint {
+		select {
+		case endpoints := <-updates:
+			return endpoints
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for an update")
+			return nil
+		}
+	}
+
+	endpoints := next()
+	if len(endpoints) != 2 || endpoints[0].Name != "api-1" || endpoints[0].Weight != 2 ||
+		endpoints[1].Name != "10.0.0.2:8080" || endpoints[1].Weight != 1 {
+		t.Fatalf("Unexpected backends %+v", endpoints)
+	}
+
+	// Invalid and empty files keep the previous backends
+	for _, content := range []string{"backends:\n  - name: api-1\n", "", "backends: []\n"} {
+		write(content)
+		select {
+		case endpoints := <-updates:
+			t.Fatalf("Expected no update for %q, got %+v", content, endpoints)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	write("backends:\n  - name: api-3\n    address: 10.0.0.3:8080\n    zone: b\n")
+	endpoints = next()
+	if len(endpoints) != 1 || endpoints[0].Name != "api-3" || endpoints[0].Zone != "b" {
+		t.Fatalf("Expected api-3 only, got %+v", endpoints)
+	}
+}
+
+func TestFileProviderRetriesInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	os.WriteFile(path, []byte("backends:\n  - address: 10.0.0.1:8080\n"), 0o644)
+	provider, err := NewFileProvider(path)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	// A file caught mid-write is read again even if the finished file has the same
+	// size and modification time
+	mtime := time.Now().Add(time.Minute)
+	os.WriteFile(path, []byte("backends:\n  - name: a\n    address: x:1\n  - name: a\n    address: x:2\n"), 0o644)
+	os.Chtimes(path, mtime, mtime)
+	if _, err := provider.reload(); err == nil {
+		t.Fatal("Expected an error for duplicate names")
+	}
+
+	os.WriteFile(path, []byte("backends:\n  - name: a\n    address: x:1\n  - name: b\n    address: x:2\n"), 0o644)
+	os.Chtimes(path, mtime, mtime)
+	changed, err := provider.reload()
+	if err != nil || !changed {
+		t.Fatalf("Expected the finished file to be loaded, got changed %v, error %v", changed, err)
+	}
+	if len(provider.endpoints) != 2 {
+		t.Errorf("Expected 2 backends, got %+v", provider.endpoints)
+	}
+}
+
+func TestLoadBackendsFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing address": "backends:\n  - name: a\n",
+		"duplicate name":  "backends:\n  - name: a\n    address: x:1\n  - name: a\n    address: x:2\n",
+		"negative weight": "backends:\n  - address: x:1\n    weight: -1\n",
+		"invalid YAML":    "backends: [",
+		"empty file":      "",
+		"no backends":     "backends: []\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "backends.yaml")
+			os.WriteFile(path, []byte(content), 0o644)
+			if _, err := NewFileProvider(path); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
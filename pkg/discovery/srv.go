@@ -0,0 +1,255 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// srvQueryTimeout bounds a single DNS query, including the TCP retry of truncated answers
+const srvQueryTimeout = 5 * time.Second
+
+// SRVConfig configures an SRV record provider
+type SRVConfig struct {
+	// Name is the SRV record to query
+	Name string
+
+	// Server is the DNS server address (default: the first nameserver in /etc/resolv.conf)
+	Server string
+
+	// MinRefresh and MaxRefresh bound the time between queries, which otherwise follows
+	// the lowest TTL of the answer
+	MinRefresh time.Duration
+	MaxRefresh time.Duration
+}
+
+// SRVProvider discovers backends from DNS SRV records, as published by Consul DNS
+// and other service registries
+// Each record becomes a backend named target:port; the SRV priority and weight become
+// the backend's priority and weight. Targets are addressed by the IPs in the answer's
+// additional section when present, and by hostname otherwise.
+type SRVProvider struct {
+	name       dnsmessage.Name
+	server     string
+	minRefresh time.Duration
+	maxRefresh time.Duration
+}
+
+// NewSRVProvider creates an SRV record provider
+func NewSRVProvider(cfg SRVConfig) (*SRVProvider, error) {
+	name, err := dnsmessage.NewName(fqdn(cfg.Name))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRV name %q: %w", cfg.Name, err)
+	}
+
+	server := cfg.Server
+	if server == "" {
+		if server, err = systemNameserver("/etc/resolv.conf"); err != nil {
+			return nil, err
+		}
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	return &SRVProvider{
+		name:       name,
+		server:     server,
+		minRefresh: cfg.MinRefresh,
+		maxRefresh: max(cfg.MaxRefresh, cfg.MinRefresh),
+	}, nil
+}
+
+// Name identifies the provider in logs
+func (p *SRVProvider) Name() string {
+	return "srv:" + strings.TrimSuffix(p.name.String(), ".")
+}
+
+// Watch queries the SRV record and calls update with its endpoints, again after every TTL
+// Failed queries keep the previous endpoints and are retried after the minimum refresh interval.
+func (p *SRVProvider) Watch(ctx context.Context, update func([]Endpoint)) error {
+	for {
+		endpoints, ttl, err := p.Lookup(ctx)
+		refresh := p.minRefresh
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("[Discovery] SRV lookup of %s via %s failed: %v", p.name, p.server, err)
+		} else {
+			update(endpoints)
+			refresh = min(max(ttl, p.minRefresh), p.maxRefresh)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(refresh):
+		}
+	}
+}
+
+// Lookup queries the SRV record once, returning its endpoints and the lowest TTL of the answer
+// A name that does not exist or has no records yields no endpoints and a TTL of 0.
+func (p *SRVProvider) Lookup(ctx context.Context) ([]Endpoint, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, srvQueryTimeout)
+	defer cancel()
+
+	msg, err := p.exchange(ctx, "udp")
+	if err == nil && msg.Truncated {
+		msg, err = p.exchange(ctx, "tcp")
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch msg.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, nil
+	default:
+		return nil, 0, fmt.Errorf("DNS server returned %s", msg.RCode)
+	}
+
+	// Addresses of the targets from the additional section
+	addrs := make(map[string][]string)
+	for _, rr := range msg.Additionals {
+		target := strings.ToLower(rr.Header.Name.String())
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs[target] = append(addrs[target], net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			addrs[target] = append(addrs[target], net.IP(body.AAAA[:]).String())
+		}
+	}
+
+	var ttl time.Duration
+	var endpoints []Endpoint
+	for _, rr := range msg.Answers {
+		srv, ok := rr.Body.(*dnsmessage.SRVResource)
+		if !ok {
+			continue
+		}
+		if recordTTL := time.Duration(rr.Header.TTL) * time.Second; len(endpoints) == 0 || recordTTL < ttl {
+			ttl = recordTTL
+		}
+
+		target := strings.ToLower(srv.Target.String())
+		port := strconv.Itoa(int(srv.Port))
+		host := strings.TrimSuffix(target, ".")
+		if ips := addrs[target]; len(ips) > 0 {
+			host = ips[0]
+		}
+		endpoints = append(endpoints, Endpoint{
+			Name:     net.JoinHostPort(strings.TrimSuffix(target, "."), port),
+			Address:  net.JoinHostPort(host, port),
+			Weight:   max(int(srv.Weight), 1),
+			Priority: int(srv.Priority),
+		})
+	}
+
+	slices.SortFunc(endpoints, func(a, b Endpoint) int { return strings.Compare(a.Name, b.Name) })
+	return endpoints, ttl, nil
+}
+
+// exchange sends the SRV query over network ("udp" or "tcp") and parses the response
+func (p *SRVProvider) exchange(ctx context.Context, network string) (*dnsmessage.Message, error) {
+	id := uint16(rand.Uint32())
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  p.name,
+			Type:  dnsmessage.TypeSRV,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, p.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var resp []byte
+	if network == "tcp" {
+		// DNS over TCP prefixes messages with their length
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+		if _, err := conn.Write(append(framed, packed...)); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(packed); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, 65535)
+		n, err := conn.Read(resp)
+		if err != nil {
+			return nil, err
+		}
+		resp = resp[:n]
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		return nil, fmt.Errorf("invalid DNS response: %w", err)
+	}
+	if msg.ID != id || !msg.Response {
+		return nil, errors.New("DNS response does not match the query")
+	}
+	return &msg, nil
+}
+
+// systemNameserver returns the first nameserver listed in a resolv.conf file
+func systemNameserver(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("no DNS server configured and %s is unreadable: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	return "", fmt.Errorf("no DNS server configured and none found in %s", path)
+}
+
+// fqdn returns name with a trailing dot
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
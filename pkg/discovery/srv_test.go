@@ -0,0 +1,188 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS answers SRV queries over UDP and TCP on the same port
+// With truncate set, UDP answers are empty and marked truncated. Fields are
+// changed under mu once the server is running.
+type fakeDNS struct {
+	addr     string
+	mu       sync.Mutex
+	rcode    dnsmessage.RCode
+	answers  []dnsmessage.Resource
+	extra    []dnsmessage.Resource
+	truncate bool
+}
+
+func newFakeDNS(t *testing.T) *fakeDNS {
+	t.Helper()
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() {
+		udp.Close()
+		tcp.Close()
+	})
+
+	f := &fakeDNS{addr: udp.LocalAddr().String()}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udp.WriteTo(f.respond(buf[:n], true), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err == nil {
+					resp := f.respond(query, false)
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return f
+}
+
+// respond answers a query; UDP answers are truncated if f.truncate is set
+func (f *fakeDNS) respond(query []byte, udp bool) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	truncate := udp && f.truncate
+
+	var q dnsmessage.Message
+	if err := q.Unpack(query); err != nil {
+		return nil
+	}
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.ID, Response: true, RCode: f.rcode, Truncated: truncate},
+		Questions: q.Questions,
+	}
+	if !truncate {
+		resp.Answers = f.answers
+		resp.Additionals = f.extra
+	}
+	packed, _ := resp.Pack()
+	return packed
+}
+
+func srvRecord(name, target string, priority, weight, port uint16, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.SRVResource{Priority: priority, Weight: weight, Port: port, Target: dnsmessage.MustNewName(target)},
+	}
+}
+
+func aRecord(name string, ip [4]byte) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: 30},
+		Body:   &dnsmessage.AResource{A: ip},
+	}
+}
+
+func TestSRVLookup(t *testing.T) {
+	dns := newFakeDNS(t)
+	name := "_http._tcp.api.service.consul."
+	dns.answers = []dnsmessage.Resource{
+		srvRecord(name, "node1.node.dc1.consul.", 0, 10, 8080, 30),
+		srvRecord(name, "node2.node.dc1.consul.", 1, 0, 8081, 15),
+	}
+	dns.extra = []dnsmessage.Resource{aRecord("node1.node.dc1.consul.", [4]byte{10, 0, 0, 1})}
+
+	provider, err := NewSRVProvider(SRVConfig{Name: "_http._tcp.api.service.consul", Server: dns.addr, MinRefresh: time.Second, MaxRefresh: time.Minute})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	for _, truncate := range []bool{false, true} {
+		dns.mu.Lock()
+		dns.truncate = truncate
+		dns.mu.Unlock()
+		endpoints, ttl, err := provider.Lookup(context.Background())
+		if err != nil {
+			t.Fatalf("Lookup failed (truncate=%v): %v", truncate, err)
+		}
+		want := []Endpoint{
+			{Name: "node1.node.dc1.consul:8080", Address: "10.0.0.1:8080", Weight: 10, Priority: 0},
+			{Name: "node2.node.dc1.consul:8081", Address: "node2.node.dc1.consul:8081", Weight: 1, Priority: 1},
+		}
+		if len(endpoints) != len(want) {
+			t.Fatalf("Expected %d endpoints, got %+v", len(want), endpoints)
+		}
+		for i := range want {
+			got := endpoints[i]
+			if got.Name != want[i].Name || got.Address != want[i].Address || got.Weight != want[i].Weight || got.Priority != want[i].Priority {
+				t.Errorf("Endpoint %d: expected %+v, got %+v", i, want[i], got)
+			}
+		}
+		if ttl != 15*time.Second {
+			t.Errorf("Expected the lowest TTL of 15s, got %s", ttl)
+		}
+	}
+}
+
+func TestSRVLookupErrors(t *testing.T) {
+	dns := newFakeDNS(t)
+	provider, err := NewSRVProvider(SRVConfig{Name: "_http._tcp.missing", Server: dns.addr, MinRefresh: time.Second, MaxRefresh: time.Minute})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	// A missing name means no endpoints
+	dns.mu.Lock()
+	dns.rcode = dnsmessage.RCodeNameError
+	dns.mu.Unlock()
+	endpoints, _, err := provider.Lookup(context.Background())
+	if err != nil || len(endpoints) != 0 {
+		t.Errorf("Expected no endpoints and no error, got %v, %v", endpoints, err)
+	}
+
+	// Server failures are errors, so the previous endpoints are kept
+	dns.mu.Lock()
+	dns.rcode = dnsmessage.RCodeServerFailure
+	dns.mu.Unlock()
+	if _, _, err := provider.Lookup(context.Background()); err == nil {
+		t.Error("Expected an error for SERVFAIL")
+	}
+}
+
+func TestSystemNameserver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	os.WriteFile(path, []byte("# comment\nsearch example.com\nnameserver 192.0.2.53\nnameserver 192.0.2.54\n"), 0o644)
+
+	server, err := systemNameserver(path)
+	if err != nil {
+		t.Fatalf("systemNameserver failed: %v", err)
+	}
+	if server != "192.0.2.53:53" {
+		t.Errorf("Expected 192.0.2.53:53, got %s", server)
+	}
+}
@@ -2,12 +2,17 @@ package health
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"sort"
 	"time"
 
 	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
 )
 
 // CheckType represents the type of health check
@@ -43,6 +48,29 @@ type CheckResult struct {
 
 	// StatusCode for HTTP checks
 	StatusCode int
+
+	// ResolvedAddresses are the IPs a hostname backend address resolved to (nil for IP addresses)
+	ResolvedAddresses []string
+}
+
+// ResolveError is returned when a backend's hostname cannot be resolved
+// It is reported separately from connection failures so DNS problems are easy to tell apart
+type ResolveError struct {
+	Host string
+	Err  error
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("DNS resolution of %s failed: %v", e.Host, e.Err)
+}
+
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+// Resolver looks up the addresses of a host
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
 }
 
 // ActiveChecker performs active health checks on backends
@@ -61,6 +89,28 @@ type ActiveChecker struct {
 
 	// HTTP client for HTTP health checks
 	httpClient *http.Client
+
+	// Resolver used to re-resolve hostname backend addresses on every check
+	resolver Resolver
+
+	// tlsErr is set if the TLS options could not be loaded; HTTPS checks fail with it
+	tlsErr error
+}
+
+// TLSOptions configures the TLS client used by HTTPS health checks
+type TLSOptions struct {
+	// CAFile is a PEM CA bundle used to verify backend certificates (default: system roots)
+	CAFile string
+
+	// ServerName overrides the SNI and verified hostname (default: backend host)
+	ServerName string
+
+	// ClientCertFile and ClientKeyFile are a PEM client certificate for mTLS backends
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// InsecureSkipVerify disables backend certificate verification
+	InsecureSkipVerify bool
 }
 
 // ActiveCheckerConfig configures an active health checker
@@ -76,6 +126,15 @@ type ActiveCheckerConfig struct {
 
 	// ExpectedStatusCodes are the HTTP status codes considered healthy
 	ExpectedStatusCodes []int
+
+	// Resolver for hostname backend addresses (default: net.DefaultResolver)
+	Resolver Resolver
+
+	// TLS options for HTTPS health checks
+	TLS TLSOptions
+
+	// Logger receives configuration problems (nil discards them)
+	Logger *logging.Logger
 }
 
 // NewActiveChecker creates a new active health checker
@@ -93,23 +152,68 @@ func NewActiveChecker(config ActiveCheckerConfig) *ActiveChecker {
 	if len(config.ExpectedStatusCodes) == 0 {
 		config.ExpectedStatusCodes = []int{http.StatusOK}
 	}
+	if config.Resolver == nil {
+		config.Resolver = net.DefaultResolver
+	}
+
+	tlsConfig, tlsErr := config.TLS.tlsConfig()
+	if tlsErr != nil {
+		config.Logger.Error("Invalid TLS options for HTTPS health checks", logging.Err(tlsErr))
+	}
 
 	return &ActiveChecker{
 		checkType:           config.CheckType,
 		timeout:             config.Timeout,
 		httpPath:            config.HTTPPath,
 		expectedStatusCodes: config.ExpectedStatusCodes,
+		resolver:            config.Resolver,
+		tlsErr:              tlsErr,
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
 			Transport: &http.Transport{
 				DisableKeepAlives:   true,
 				MaxIdleConnsPerHost: 1,
 				IdleConnTimeout:     config.Timeout,
+				TLSClientConfig:     tlsConfig,
 			},
 		},
 	}
 }
 
+// tlsConfig builds the TLS client configuration, loading the CA bundle and client certificate
+// It returns nil (Go defaults) when no options are set
+func (o TLSOptions) tlsConfig() (*tls.Config, error) {
+	if o == (TLSOptions{}) {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+	}
+
+	if o.ClientCertFile != "" || o.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
 // Check performs a health check on the given backend
 func (ac *ActiveChecker) Check(ctx context.Context, b *backend.Backend) CheckResult {
 	start := time.Now()
@@ -118,13 +222,25 @@ func (ac *ActiveChecker) Check(ctx context.Context, b *backend.Backend) CheckRes
 		Timestamp: start,
 	}
 
+	// Re-resolve hostnames on every check so backends moved via DNS are noticed
 	var err error
+	result.ResolvedAddresses, err = ac.resolve(ctx, b.Address())
+	if err != nil {
+		result.Duration = time.Since(start)
+		result.Error = err
+		return result
+	}
+
 	switch ac.checkType {
 	case CheckTypeTCP:
 		err = ac.checkTCP(ctx, b.Address())
 	case CheckTypeHTTP:
 		result.StatusCode, err = ac.checkHTTP(ctx, "http://"+b.Address()+ac.httpPath)
 	case CheckTypeHTTPS:
+		if ac.tlsErr != nil {
+			err = fmt.Errorf("invalid TLS options: %w", ac.tlsErr)
+			break
+		}
 		result.StatusCode, err = ac.checkHTTP(ctx, "https://"+b.Address()+ac.httpPath)
 	default:
 		err = fmt.Errorf("unsupported check type: %s", ac.checkType)
@@ -137,6 +253,26 @@ func (ac *ActiveChecker) Check(ctx context.Context, b *backend.Backend) CheckRes
 	return result
 }
 
+// resolve looks up the host of a backend address
+// It returns nil without error when the host is already an IP address
+func (ac *ActiveChecker) resolve(ctx context.Context, address string) ([]string, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return nil, nil
+	}
+
+	addrs, err := ac.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, &ResolveError{Host: host, Err: err}
+	}
+	if len(addrs) == 0 {
+		return nil, &ResolveError{Host: host, Err: fmt.Errorf("no addresses found")}
+	}
+
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
 // checkTCP performs a TCP connection check
 func (ac *ActiveChecker) checkTCP(ctx context.Context, address string) error {
 	var dialer net.Dialer
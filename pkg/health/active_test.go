@@ -2,9 +2,13 @@ package health
 
 import (
 	"context"
+	"encoding/pem"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -178,6 +182,50 @@ func TestActiveChecker_HTTPCheck_WrongStatusCode(t *testing.T) {
 	}
 }
 
+func TestActiveChecker_HTTPSCheck_TLSOptions(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Write the test server's certificate as a CA bundle
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+
+	b := backend.NewBackend("test", server.Listener.Addr().String(), 1)
+
+	tests := []struct {
+		name    string
+		tls     TLSOptions
+		success bool
+	}{
+		{"system roots", TLSOptions{}, false},
+		{"ca bundle", TLSOptions{CAFile: caFile}, true},
+		{"ca bundle with sni override", TLSOptions{CAFile: caFile, ServerName: "example.com"}, true},
+		{"ca bundle with wrong sni", TLSOptions{CAFile: caFile, ServerName: "other.test"}, false},
+		{"insecure skip verify", TLSOptions{InsecureSkipVerify: true}, true},
+		{"missing ca file", TLSOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewActiveChecker(ActiveCheckerConfig{
+				CheckType: CheckTypeHTTPS,
+				Timeout:   1 * time.Second,
+				TLS:       tt.tls,
+			})
+
+			result := checker.Check(context.Background(), b)
+			if result.Success != tt.success {
+				t.Errorf("Expected success=%v, got error: %v", tt.success, result.Error)
+			}
+		})
+	}
+}
+
 func TestActiveChecker_CheckMultiple(t *testing.T) {
 	// Start multiple test servers
 	server1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -264,6 +312,67 @@ func TestActiveChecker_ContextCancellation(t *testing.T) {
 	}
 }
 
+// fakeResolver resolves hosts from a fixed table
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := f[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func TestActiveChecker_ResolvesHostnames(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	resolver := fakeResolver{"backend.internal": {"127.0.0.1"}}
+	checker := NewActiveChecker(ActiveCheckerConfig{
+		CheckType: CheckTypeTCP,
+		Timeout:   1 * time.Second,
+		Resolver:  resolver,
+	})
+
+	// IP addresses are not resolved
+	result := checker.Check(context.Background(), backend.NewBackend("ip", listener.Addr().String(), 1))
+	if !result.Success || result.ResolvedAddresses != nil {
+		t.Errorf("Expected IP backend to be checked without resolution, got %+v", result)
+	}
+
+	b := backend.NewBackend("test", net.JoinHostPort("backend.internal", port), 1)
+	result = checker.Check(context.Background(), b)
+	if len(result.ResolvedAddresses) != 1 || result.ResolvedAddresses[0] != "127.0.0.1" {
+		t.Errorf("Expected resolved addresses [127.0.0.1], got %v", result.ResolvedAddresses)
+	}
+
+	// The hostname is re-resolved on the next check
+	delete(resolver, "backend.internal")
+	result = checker.Check(context.Background(), b)
+	if result.Success {
+		t.Error("Expected check to fail when the hostname does not resolve")
+	}
+
+	var resolveErr *ResolveError
+	if !errors.As(result.Error, &resolveErr) || resolveErr.Host != "backend.internal" {
+		t.Errorf("Expected ResolveError for backend.internal, got %v", result.Error)
+	}
+}
+
 func TestCheckType_String(t *testing.T) {
 	tests := []struct {
 		checkType CheckType
@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+// AdaptiveWeightsConfig configures the adaptive weight controller
+type AdaptiveWeightsConfig struct {
+	// Interval between weight adjustments
+	Interval time.Duration
+
+	// MinWeightFactor is the lowest fraction of its base weight a backend can drop to
+	MinWeightFactor float64
+
+	// Logger receives controller events (nil discards them)
+	Logger *logging.Logger
+}
+
+// AdaptiveWeights periodically adjusts backend weights so that faster and more
+// reliable backends receive more traffic
+//
+// Each backend's weight factor is set from how its EWMA latency compares to the fastest
+// backend and from its error rate over the last interval. The weight itself is left to
+// the configuration and the operator, and load balancers use the two multiplied.
+type AdaptiveWeights struct {
+	checker *Checker
+	pool    *backend.Pool
+	config  AdaptiveWeightsConfig
+
+	// Request counters per backend at the previous adjustment
+	last map[string]requestCount
+	mu   sync.Mutex
+
+	// Control
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// requestCount is a snapshot of a backend's request counters
+type requestCount struct {
+	total  int64
+	failed int64
+}
+
+// NewAdaptiveWeights creates an adaptive weight controller using the checker's request metrics
+func NewAdaptiveWeights(checker *Checker, pool *backend.Pool, config AdaptiveWeightsConfig) *AdaptiveWeights {
+	if config.Interval == 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.MinWeightFactor == 0 {
+		config.MinWeightFactor = 0.1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &AdaptiveWeights{
+		checker: checker,
+		pool:    pool,
+		config:  config,
+		last:    make(map[string]requestCount),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start begins adjusting weights
+func (a *AdaptiveWeights) Start() {
+	a.config.Logger.Info("Starting adaptive weights", logging.Duration("interval", a.config.Interval))
+
+	a.wg.Add(1)
+	go a.run()
+}
+
+// Stop stops adjusting weights and removes the weight factors
+func (a *AdaptiveWeights) Stop() {
+	a.cancel()
+	a.wg.Wait()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, b := range a.pool.All() {
+		b.SetWeightFactor(1)
+	}
+}
+
+// run adjusts weights on every tick
+func (a *AdaptiveWeights) run() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.adjust()
+		}
+	}
+}
+
+// backendSample is the data used to weigh one backend
+type backendSample struct {
+	backend   *backend.Backend
+	latency   time.Duration
+	errorRate float64
+}
+
+// adjust recomputes and applies the weight factor of every backend
+func (a *AdaptiveWeights) adjust() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var samples []backendSample
+	var fastest time.Duration
+
+	for _, b := range a.pool.All() {
+		sm, err := a.checker.GetStateMachine(b.Name())
+		if err != nil {
+			continue
+		}
+
+		// Error rate over the last interval only, so backends can recover
+		current := requestCount{total: sm.GetTotalRequests(), failed: sm.GetFailedRequests()}
+		previous := a.last[b.Name()]
+		a.last[b.Name()] = current
+
+		var errorRate float64
+		if total := current.total - previous.total; total > 0 {
+			errorRate = float64(current.failed-previous.failed) / float64(total)
+		}
+
+		latency := sm.GetEWMAResponseTime()
+		if latency > 0 && (fastest == 0 || latency < fastest) {
+			fastest = latency
+		}
+
+		samples = append(samples, backendSample{backend: b, latency: latency, errorRate: errorRate})
+	}
+
+	for _, s := range samples {
+		factor := 1.0
+		if s.latency > 0 {
+			factor = float64(fastest) / float64(s.latency)
+		}
+		factor *= 1 - s.errorRate
+		factor = math.Max(factor, a.config.MinWeightFactor)
+
+		s.backend.SetWeightFactor(factor)
+	}
+}
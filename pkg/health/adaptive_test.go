@@ -0,0 +1,113 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+func TestAdaptiveWeights_Latency(t *testing.T) {
+	pool := backend.NewPool()
+	fast := backend.NewBackend("fast", "localhost:9001", 1)
+	slow := backend.NewBackend("slow", "localhost:9002", 1)
+	pool.Add(fast)
+	pool.Add(slow)
+
+	checker := NewChecker(pool, CheckerConfig{})
+	aw := NewAdaptiveWeights(checker, pool, AdaptiveWeightsConfig{})
+
+	checker.RecordRequest(fast, true, 10*time.Millisecond)
+	checker.RecordRequest(slow, true, 40*time.Millisecond)
+
+	aw.adjust()
+
+	if f := fast.WeightFactor(); f != 1 {
+		t.Errorf("Expected fast backend factor 1, got %v", f)
+	}
+	if f := slow.WeightFactor(); f != 0.25 {
+		t.Errorf("Expected slow backend factor 0.25, got %v", f)
+	}
+	if fast.Weight() != 1 || slow.Weight() != 1 {
+		t.Errorf("Expected configured weights to be kept, got %d and %d", fast.Weight(), slow.Weight())
+	}
+
+	// Stopping removes the weight factors
+	aw.Stop()
+
+	if fast.WeightFactor() != 1 || slow.WeightFactor() != 1 {
+		t.Errorf("Expected weight factors to be reset, got %v and %v", fast.WeightFactor(), slow.WeightFactor())
+	}
+}
+
+func TestAdaptiveWeights_ErrorRate(t *testing.T) {
+	pool := backend.NewPool()
+	b1 := backend.NewBackend("backend-1", "localhost:9001", 1)
+	b2 := backend.NewBackend("backend-2", "localhost:9002", 1)
+	pool.Add(b1)
+	pool.Add(b2)
+
+	checker := NewChecker(pool, CheckerConfig{})
+	aw := NewAdaptiveWeights(checker, pool, AdaptiveWeightsConfig{MinWeightFactor: 0.2})
+
+	checker.RecordRequest(b1, true, 10*time.Millisecond)
+	checker.RecordRequest(b2, true, 10*time.Millisecond)
+	checker.RecordRequest(b2, false, 0)
+
+	aw.adjust()
+
+	if f := b2.WeightFactor(); f != 0.5 {
+		t.Errorf("Expected backend-2 factor 0.5 with 50%% errors, got %v", f)
+	}
+
+	// Only failures in the last interval count
+	for i := 0; i < 10; i++ {
+		checker.RecordRequest(b2, false, 0)
+	}
+	aw.adjust()
+
+	if f := b2.WeightFactor(); f != 0.2 {
+		t.Errorf("Expected backend-2 factor clamped to 0.2, got %v", f)
+	}
+
+	checker.RecordRequest(b2, true, 10*time.Millisecond)
+	aw.adjust()
+
+	if f := b2.WeightFactor(); f != 1 {
+		t.Errorf("Expected backend-2 to recover to factor 1, got %v", f)
+	}
+}
+
+func TestAdaptiveWeights_OperatorOverride(t *testing.T) {
+	pool := backend.NewPool()
+	fast := backend.NewBackend("fast", "localhost:9001", 1)
+	slow := backend.NewBackend("slow", "localhost:9002", 1)
+	pool.Add(fast)
+	pool.Add(slow)
+
+	checker := NewChecker(pool, CheckerConfig{})
+	aw := NewAdaptiveWeights(checker, pool, AdaptiveWeightsConfig{})
+
+	checker.RecordRequest(fast, true, 10*time.Millisecond)
+	checker.RecordRequest(slow, true, 20*time.Millisecond)
+	aw.adjust()
+
+	// A weight changed at runtime is kept across adjustments and scaled by the factor
+	slow.SetWeight(3)
+	aw.adjust()
+
+	if w := slow.Weight(); w != 3 {
+		t.Errorf("Expected weight 3 to be kept, got %d", w)
+	}
+	if w := slow.EffectiveWeight(); w != 1.5 {
+		t.Errorf("Expected effective weight 1.5, got %v", w)
+	}
+
+	// Draining with weight 0 is preserved
+	slow.SetWeight(0)
+	aw.adjust()
+
+	if w := slow.EffectiveWeight(); w != 0 {
+		t.Errorf("Expected effective weight 0, got %v", w)
+	}
+}
@@ -2,12 +2,16 @@ package health
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
 )
 
 // Checker orchestrates health checking for a pool of backends
@@ -25,20 +29,37 @@ type Checker struct {
 	stateMachines map[string]*backend.StateMachine
 	mu            sync.RWMutex
 
+	// Latest DNS resolution of hostname backends
+	resolutions map[string]Resolution
+
 	// Configuration
 	interval           time.Duration
+	unhealthyInterval  time.Duration
 	healthyThreshold   int
 	unhealthyThreshold int
 
+	logger *logging.Logger
+
 	// Control
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	unsubscribe func()
 
 	// Metrics
-	totalChecks   int64
-	successChecks int64
-	failedChecks  int64
+	totalChecks     int64
+	successChecks   int64
+	failedChecks    int64
+	resolveFailures int64
+}
+
+// Resolution is the latest DNS resolution of a backend hostname
+type Resolution struct {
+	// Addresses the hostname resolved to at the last successful lookup
+	Addresses []string
+
+	// Error from the last lookup (nil if it succeeded)
+	Error error
 }
 
 // CheckerConfig configures the health checker
@@ -46,6 +67,9 @@ type CheckerConfig struct {
 	// Interval between health checks
 	Interval time.Duration
 
+	// UnhealthyInterval between health checks of unhealthy backends (default: Interval)
+	UnhealthyInterval time.Duration
+
 	// Timeout for each health check
 	Timeout time.Duration
 
@@ -58,12 +82,16 @@ type CheckerConfig struct {
 	// ActiveCheck configuration
 	ActiveCheckType CheckType
 	HTTPPath        string
+	TLS             TLSOptions
 
 	// PassiveCheck configuration
 	EnablePassiveChecks  bool
 	ErrorRateThreshold   float64
 	ConsecutiveFailures  int
 	PassiveCheckWindow   time.Duration
+
+	// Logger receives health check events (nil discards them)
+	Logger *logging.Logger
 }
 
 // NewChecker creates a new health checker
@@ -72,6 +100,9 @@ func NewChecker(pool *backend.Pool, config CheckerConfig) *Checker {
 	if config.Interval == 0 {
 		config.Interval = 10 * time.Second
 	}
+	if config.UnhealthyInterval == 0 {
+		config.UnhealthyInterval = config.Interval
+	}
 	if config.Timeout == 0 {
 		config.Timeout = 3 * time.Second
 	}
@@ -90,9 +121,12 @@ func NewChecker(pool *backend.Pool, config CheckerConfig) *Checker {
 	checker := &Checker{
 		pool:               pool,
 		interval:           config.Interval,
+		unhealthyInterval:  config.UnhealthyInterval,
 		healthyThreshold:   config.HealthyThreshold,
 		unhealthyThreshold: config.UnhealthyThreshold,
 		stateMachines:      make(map[string]*backend.StateMachine),
+		resolutions:        make(map[string]Resolution),
+		logger:             config.Logger,
 		ctx:                ctx,
 		cancel:             cancel,
 	}
@@ -102,6 +136,8 @@ func NewChecker(pool *backend.Pool, config CheckerConfig) *Checker {
 		CheckType: config.ActiveCheckType,
 		Timeout:   config.Timeout,
 		HTTPPath:  config.HTTPPath,
+		TLS:       config.TLS,
+		Logger:    config.Logger,
 	})
 
 	// Create passive checker if enabled
@@ -113,19 +149,22 @@ func NewChecker(pool *backend.Pool, config CheckerConfig) *Checker {
 		})
 	}
 
-	// Initialize state machines for all backends
+	// Initialize state machines for all backends, and follow backends joining and
+	// leaving the pool
 	for _, b := range pool.All() {
 		sm := backend.NewStateMachine(b, config.HealthyThreshold, config.UnhealthyThreshold)
 		sm.AddListener(checker.onStateChange)
 		checker.stateMachines[b.Name()] = sm
 	}
+	checker.unsubscribe = pool.Subscribe(checker.onPoolEvent)
 
 	return checker
 }
 
 // Start begins health checking
 func (c *Checker) Start() error {
-	log.Printf("[Health] Starting health checker with interval %s", c.interval)
+	c.logger.Info("Starting health checker",
+		logging.Duration("interval", c.interval), logging.Duration("unhealthy_interval", c.unhealthyInterval))
 
 	c.wg.Add(1)
 	go c.runHealthChecks()
@@ -135,36 +174,83 @@ func (c *Checker) Start() error {
 
 // Stop stops health checking
 func (c *Checker) Stop() error {
-	log.Println("[Health] Stopping health checker")
+	c.logger.Info("Stopping health checker")
+	c.unsubscribe()
 	c.cancel()
 	c.wg.Wait()
-	log.Println("[Health] Health checker stopped")
+	c.logger.Info("Health checker stopped")
 	return nil
 }
 
 // runHealthChecks runs periodic health checks
+// Each backend is scheduled individually: healthy backends every interval and
+// unhealthy backends every unhealthy interval
 func (c *Checker) runHealthChecks() {
 	defer c.wg.Done()
 
-	ticker := time.NewTicker(c.interval)
-	defer ticker.Stop()
-
 	// Run initial health check
-	c.performHealthChecks()
+	next := make(map[string]time.Time)
+	timer := time.NewTimer(c.checkDue(next))
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-c.ctx.Done():
 			return
-		case <-ticker.C:
-			c.performHealthChecks()
+		case <-timer.C:
+			timer.Reset(c.checkDue(next))
 		}
 	}
 }
 
-// performHealthChecks performs health checks on all backends
-func (c *Checker) performHealthChecks() {
+// checkDue checks the backends whose next check time has passed, schedules their next
+// check, and returns how long to wait until the next backend is due
+func (c *Checker) checkDue(next map[string]time.Time) time.Duration {
+	now := time.Now()
 	backends := c.pool.All()
+
+	var due []*backend.Backend
+	for _, b := range backends {
+		if at, ok := next[b.Name()]; !ok || !now.Before(at) {
+			due = append(due, b)
+			delete(next, b.Name())
+		}
+	}
+	c.performHealthChecks(due)
+
+	now = time.Now()
+	earliest := now.Add(c.interval)
+	current := make(map[string]time.Time, len(backends))
+	for _, b := range backends {
+		// Backends marked unhealthy since their last check (e.g. by passive checks)
+		// are brought forward to the unhealthy interval
+		at, ok := next[b.Name()]
+		if !ok || now.Add(c.intervalFor(b)).Before(at) {
+			at = now.Add(c.intervalFor(b))
+		}
+		current[b.Name()] = at
+		if at.Before(earliest) {
+			earliest = at
+		}
+	}
+
+	// Drop schedules of backends removed from the pool
+	clear(next)
+	maps.Copy(next, current)
+
+	return max(earliest.Sub(now), 0)
+}
+
+// intervalFor returns how often a backend is checked in its current state
+func (c *Checker) intervalFor(b *backend.Backend) time.Duration {
+	if !b.IsHealthy() {
+		return c.unhealthyInterval
+	}
+	return c.interval
+}
+
+// performHealthChecks performs health checks on the given backends
+func (c *Checker) performHealthChecks(backends []*backend.Backend) {
 	if len(backends) == 0 {
 		return
 	}
@@ -197,25 +283,70 @@ func (c *Checker) processResult(result CheckResult) {
 		c.mu.Unlock()
 	}
 
+	c.recordResolution(result)
+
 	if result.Success {
 		sm.RecordSuccess()
 		c.successChecks++
 	} else {
 		sm.RecordFailure()
 		c.failedChecks++
-		log.Printf("[Health] Backend %s health check failed: %v (duration: %s)",
-			result.Backend.Name(), result.Error, result.Duration)
+		c.logger.Warn("Backend health check failed",
+			logging.String("backend", result.Backend.Name()), logging.Err(result.Error), logging.Duration("duration", result.Duration))
 	}
+	metrics.RecordHealthCheck(result.Backend.Name(), result.Success, result.Duration)
+	metrics.SetHealthCheckConsecutiveFailures(result.Backend.Name(), sm.GetConsecutiveFailures())
 
 	c.totalChecks++
 }
 
-// RecordRequest records a request result for passive health checking
-func (c *Checker) RecordRequest(b *backend.Backend, success bool, responseTime time.Duration) {
-	if c.passiveChecker == nil {
+// recordResolution tracks a hostname backend's resolved addresses, logging changes and failures
+func (c *Checker) recordResolution(result CheckResult) {
+	name := result.Backend.Name()
+
+	var resolveErr *ResolveError
+	failed := errors.As(result.Error, &resolveErr)
+	if !failed && result.ResolvedAddresses == nil {
 		return
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.resolutions[name]
+	if failed {
+		c.resolveFailures++
+		c.resolutions[name] = Resolution{Addresses: previous.Addresses, Error: resolveErr}
+		return
+	}
+
+	if previous.Addresses != nil && !slices.Equal(previous.Addresses, result.ResolvedAddresses) {
+		c.logger.Info("Backend address resolution changed",
+			logging.String("backend", name), logging.String("address", result.Backend.Address()),
+			logging.Any("resolved", result.ResolvedAddresses), logging.Any("previous", previous.Addresses))
+	}
+	c.resolutions[name] = Resolution{Addresses: result.ResolvedAddresses}
+}
+
+// GetResolution returns the latest DNS resolution of a backend
+// ok is false if the backend address is not a hostname or has not been checked yet
+func (c *Checker) GetResolution(backendName string) (res Resolution, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res, ok = c.resolutions[backendName]
+	return res, ok
+}
+
+// GetResolveFailures returns the number of health checks that failed on DNS resolution
+func (c *Checker) GetResolveFailures() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.resolveFailures
+}
+
+// RecordRequest records a request result for passive health checking
+func (c *Checker) RecordRequest(b *backend.Backend, success bool, responseTime time.Duration) {
 	c.mu.RLock()
 	sm, exists := c.stateMachines[b.Name()]
 	c.mu.RUnlock()
@@ -224,9 +355,13 @@ func (c *Checker) RecordRequest(b *backend.Backend, success bool, responseTime t
 		return
 	}
 
-	// Record in state machine metrics
+	// Record in state machine metrics (also used for adaptive weights)
 	sm.RecordRequest(success, responseTime)
 
+	if c.passiveChecker == nil {
+		return
+	}
+
 	// Record in passive checker
 	if success {
 		c.passiveChecker.RecordSuccess(b, responseTime)
@@ -235,14 +370,30 @@ func (c *Checker) RecordRequest(b *backend.Backend, success bool, responseTime t
 		if shouldMarkUnhealthy {
 			// Passive check indicates backend is unhealthy
 			sm.RecordFailure()
-			log.Printf("[Health] Passive check marked backend %s as potentially unhealthy", b.Name())
+			metrics.SetHealthCheckConsecutiveFailures(b.Name(), sm.GetConsecutiveFailures())
+			c.logger.Warn("Passive check marked backend as potentially unhealthy", logging.String("backend", b.Name()))
+		}
+	}
+}
+
+// onPoolEvent starts and stops health checking backends as they join and leave the pool
+func (c *Checker) onPoolEvent(event backend.PoolEvent) {
+	switch event.Type {
+	case backend.BackendAdded:
+		c.AddBackend(event.Backend)
+	case backend.BackendRemoved:
+		c.RemoveBackend(event.Backend.Name())
+		if c.passiveChecker != nil {
+			c.passiveChecker.Reset(event.Backend)
 		}
 	}
 }
 
 // onStateChange is called when a backend's state changes
 func (c *Checker) onStateChange(b *backend.Backend, oldState, newState backend.State) {
-	log.Printf("[Health] Backend %s state changed: %s -> %s", b.Name(), oldState, newState)
+	c.logger.Info("Backend state changed",
+		logging.String("backend", b.Name()), logging.String("from", oldState.String()), logging.String("to", newState.String()))
+	metrics.SetBackendLastTransition(b.Name(), time.Now())
 
 	// Reset passive check failures when transitioning to healthy
 	if newState == backend.StateHealthy && c.passiveChecker != nil {
@@ -295,7 +446,7 @@ func (c *Checker) AddBackend(b *backend.Backend) {
 	sm.AddListener(c.onStateChange)
 	c.stateMachines[b.Name()] = sm
 
-	log.Printf("[Health] Added backend %s to health checking", b.Name())
+	c.logger.Info("Added backend to health checking", logging.String("backend", b.Name()))
 }
 
 // RemoveBackend removes a backend from health checking
@@ -304,5 +455,5 @@ func (c *Checker) RemoveBackend(backendName string) {
 	defer c.mu.Unlock()
 
 	delete(c.stateMachines, backendName)
-	log.Printf("[Health] Removed backend %s from health checking", backendName)
+	c.logger.Info("Removed backend from health checking", logging.String("backend", backendName))
 }
@@ -0,0 +1,114 @@
+package health
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
+)
+
+func TestChecker_UnhealthyInterval(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("up", listener.Addr().String(), 1))
+	pool.Add(backend.NewBackend("down", "127.0.0.1:1", 1))
+
+	checker := NewChecker(pool, CheckerConfig{
+		Interval:           time.Hour,
+		UnhealthyInterval:  20 * time.Millisecond,
+		Timeout:            time.Second,
+		UnhealthyThreshold: 1,
+	})
+	if err := checker.Start(); err != nil {
+		t.Fatalf("Failed to start checker: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	checker.Stop()
+
+	// The healthy backend is checked once; the unhealthy one keeps being re-checked
+	total, success, failed := checker.GetStats()
+	if success != 1 {
+		t.Errorf("Expected the healthy backend to be checked once, got %d successful checks", success)
+	}
+	if failed < 3 {
+		t.Errorf("Expected the unhealthy backend to be re-checked, got %d failed checks (total %d)", failed, total)
+	}
+}
+
+func TestChecker_FollowsPool(t *testing.T) {
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("static", "127.0.0.1:9001", 1))
+	checker := NewChecker(pool, CheckerConfig{Interval: time.Hour})
+
+	pool.Add(backend.NewBackend("added", "127.0.0.1:9002", 1))
+	if _, err := checker.GetStateMachine("added"); err != nil {
+		t.Errorf("Expected a state machine for the added backend: %v", err)
+	}
+
+	pool.Remove("static")
+	if _, err := checker.GetStateMachine("static"); err == nil {
+		t.Error("Expected the removed backend to leave health checking")
+	}
+
+	// After stopping, pool changes are no longer followed
+	checker.Stop()
+	pool.Add(backend.NewBackend("late", "127.0.0.1:9003", 1))
+	if _, err := checker.GetStateMachine("late"); err == nil {
+		t.Error("Expected a stopped checker to ignore new backends")
+	}
+}
+
+func TestChecker_Metrics(t *testing.T) {
+	pool := backend.NewPool()
+	b := backend.NewBackend("health-metrics", "127.0.0.1:9001", 1)
+	pool.Add(b)
+	checker := NewChecker(pool, CheckerConfig{Interval: time.Hour, UnhealthyThreshold: 2})
+	defer checker.Stop()
+
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		metrics.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rec.Body.String()
+	}
+
+	failure := CheckResult{Backend: b, Error: errors.New("connection refused"), Duration: 10 * time.Millisecond}
+	checker.processResult(failure)
+	checker.processResult(failure)
+	body := scrape()
+	for _, line := range []string{
+		`balance_health_checks_total{backend="health-metrics",result="failure"} 2`,
+		`balance_health_check_duration_seconds_count{backend="health-metrics"} 2`,
+		`balance_health_check_consecutive_failures{backend="health-metrics"} 2`,
+		`balance_backend_last_transition_timestamp_seconds{backend="health-metrics"}`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %s in metrics", line)
+		}
+	}
+
+	checker.processResult(CheckResult{Backend: b, Success: true, Duration: time.Millisecond})
+	if body := scrape(); !strings.Contains(body, `balance_health_check_consecutive_failures{backend="health-metrics"} 0`) {
+		t.Error("Expected a successful check to reset consecutive failures")
+	}
+}
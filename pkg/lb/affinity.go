@@ -52,8 +52,8 @@ func (sa *SessionAffinity) SelectWithClientIP(clientIP string) *backend.Backend
 	// Check if we have an existing session
 	sa.mu.RLock()
 	if sess, exists := sa.sessions[clientIP]; exists {
-		// Check if session is still valid and backend is healthy
-		if time.Since(sess.lastAccess) < sa.timeout && sess.backend.IsHealthy() {
+		// Check if session is still valid and backend is healthy and not draining
+		if time.Since(sess.lastAccess) < sa.timeout && sess.backend.IsAvailable() {
 			sa.mu.RUnlock()
 
 			// Update last access time
@@ -12,6 +12,9 @@ import (
 const (
 	// DefaultVirtualNodes is the default number of virtual nodes per backend
 	DefaultVirtualNodes = 150
+
+	// DefaultLoadFactor is the default bounded-load cap, as a multiple of the average load
+	DefaultLoadFactor = 1.25
 )
 
 // ConsistentHash implements consistent hashing load balancing
@@ -150,7 +153,7 @@ type BoundedLoadConsistentHash struct {
 // NewBoundedLoadConsistentHash creates a new bounded load consistent hash load balancer
 func NewBoundedLoadConsistentHash(pool *backend.Pool, virtualNodes int, hashKey string, loadFactor float64) *BoundedLoadConsistentHash {
 	if loadFactor <= 0 {
-		loadFactor = 1.25 // Default to 125% of average load
+		loadFactor = DefaultLoadFactor
 	}
 
 	return &BoundedLoadConsistentHash{
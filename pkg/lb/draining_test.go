@@ -0,0 +1,93 @@
+package lb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+func TestAlgorithmsSkipDrainingBackends(t *testing.T) {
+	algorithms := map[string]func(*backend.Pool) LoadBalancer{
+		"round-robin":                func(p *backend.Pool) LoadBalancer { return NewRoundRobin(p) },
+		"least-connections":          func(p *backend.Pool) LoadBalancer { return NewLeastConnections(p) },
+		"weighted-round-robin":       func(p *backend.Pool) LoadBalancer { return NewWeightedRoundRobin(p) },
+		"weighted-least-connections": func(p *backend.Pool) LoadBalancer { return NewWeightedLeastConnections(p) },
+		"consistent-hash":            func(p *backend.Pool) LoadBalancer { return NewConsistentHash(p, 10, "") },
+		"bounded-consistent-hash":    func(p *backend.Pool) LoadBalancer { return NewBoundedLoadConsistentHash(p, 10, "", 1.25) },
+		"peak-ewma":                  func(p *backend.Pool) LoadBalancer { return NewPeakEWMA(p, time.Second) },
+	}
+
+	for name, newBalancer := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			pool := backend.NewPool()
+			draining := backend.NewBackend("draining", "localhost:9001", 10)
+			active := backend.NewBackend("active", "localhost:9002", 1)
+			pool.Add(draining)
+			pool.Add(active)
+
+			// A draining backend stays healthy but must not receive new connections
+			draining.SetDraining(true)
+			balancer := newBalancer(pool)
+			for i := 0; i < 20; i++ {
+				if b := balancer.Select(); b != active {
+					t.Fatalf("Expected the active backend, got %v", b)
+				}
+			}
+
+			// With only draining backends left, nothing is selected
+			active.SetDraining(true)
+			if b := balancer.Select(); b != nil {
+				t.Errorf("Expected no backend, got %s", b.Name())
+			}
+		})
+	}
+}
+
+func TestStateMachineDrainingSkipsBackend(t *testing.T) {
+	pool := backend.NewPool()
+	b1 := backend.NewBackend("backend-1", "localhost:9001", 1)
+	b2 := backend.NewBackend("backend-2", "localhost:9002", 1)
+	pool.Add(b1)
+	pool.Add(b2)
+	b1.IncrementConnections()
+
+	sm := backend.NewStateMachine(b1, 1, 1)
+	sm.StartDraining()
+
+	// Marking the backend healthy directly does not end draining
+	b1.MarkHealthy()
+	balancer := NewRoundRobin(pool)
+	for i := 0; i < 4; i++ {
+		if b := balancer.Select(); b != b2 {
+			t.Fatalf("Expected backend-2 while backend-1 drains, got %v", b)
+		}
+	}
+	if b1.ActiveConnections() != 1 {
+		t.Errorf("Expected existing connections to be kept, got %d", b1.ActiveConnections())
+	}
+
+	sm.ForceHealthy()
+	selected := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		selected[balancer.Select().Name()] = true
+	}
+	if !selected["backend-1"] {
+		t.Error("Expected backend-1 to receive traffic again after draining ends")
+	}
+}
+
+func TestSessionAffinityDrainingBackend(t *testing.T) {
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("backend-1", "localhost:9001", 1))
+	pool.Add(backend.NewBackend("backend-2", "localhost:9002", 1))
+
+	sa := NewSessionAffinity(NewRoundRobin(pool), 5*time.Second)
+	defer sa.Stop()
+
+	first := sa.SelectWithClientIP("192.168.1.100")
+	first.SetDraining(true)
+	if second := sa.SelectWithClientIP("192.168.1.100"); second == first {
+		t.Error("Expected the session to move off the draining backend")
+	}
+}
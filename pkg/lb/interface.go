@@ -1,6 +1,10 @@
 package lb
 
-import "github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+import (
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
 
 // LoadBalancer defines the interface for load balancing algorithms
 type LoadBalancer interface {
@@ -11,3 +15,9 @@ type LoadBalancer interface {
 	// Name returns the name of the load balancing algorithm
 	Name() string
 }
+
+// LatencyObserver is implemented by load balancers that learn from request latency
+type LatencyObserver interface {
+	// Observe records the latency of a successful request to a backend
+	Observe(b *backend.Backend, latency time.Duration)
+}
@@ -0,0 +1,125 @@
+package lb
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+const (
+	// DefaultPeakEWMADecay is the time over which old latency samples lose their influence
+	DefaultPeakEWMADecay = 10 * time.Second
+)
+
+// PeakEWMA implements peak-EWMA load balancing
+// Each backend's cost is an exponentially weighted moving average of its latency
+// that jumps straight to any higher sample (the "peak") and decays slowly afterwards.
+// The load score is cost * (in-flight requests + 1), and selection uses the
+// power of two choices: two random backends are compared and the cheaper one wins.
+type PeakEWMA struct {
+	pool  *backend.Pool
+	decay time.Duration
+
+	costs map[string]*ewmaCost
+	mu    sync.Mutex
+}
+
+// ewmaCost is the decayed latency of a single backend
+type ewmaCost struct {
+	cost  float64 // nanoseconds
+	stamp time.Time
+}
+
+// NewPeakEWMA creates a new peak-EWMA load balancer
+// decay controls how quickly latency spikes are forgotten
+func NewPeakEWMA(pool *backend.Pool, decay time.Duration) *PeakEWMA {
+	if decay <= 0 {
+		decay = DefaultPeakEWMADecay
+	}
+
+	return &PeakEWMA{
+		pool:  pool,
+		decay: decay,
+		costs: make(map[string]*ewmaCost),
+	}
+}
+
+// Select picks the cheaper of two randomly chosen healthy backends
+func (p *PeakEWMA) Select() *backend.Backend {
+	backends := p.pool.Healthy()
+	if len(backends) == 0 {
+		return nil
+	}
+
+	if len(backends) == 1 {
+		return backends[0]
+	}
+
+	i := rand.Intn(len(backends))
+	j := rand.Intn(len(backends) - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := backends[i], backends[j]
+	if p.score(b) < p.score(a) {
+		return b
+	}
+	return a
+}
+
+// Observe records the latency of a completed request to a backend
+func (p *PeakEWMA) Observe(b *backend.Backend, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	rtt := float64(latency)
+
+	c, ok := p.costs[b.Name()]
+	if !ok {
+		p.costs[b.Name()] = &ewmaCost{cost: rtt, stamp: now}
+		return
+	}
+
+	if rtt > c.cost {
+		// Latency spikes are taken at face value
+		c.cost = rtt
+	} else {
+		// Improvements are folded in gradually, weighted by how long since the last sample
+		w := math.Exp(-float64(now.Sub(c.stamp)) / float64(p.decay))
+		c.cost = c.cost*w + rtt*(1-w)
+	}
+	c.stamp = now
+}
+
+// Cost returns the current latency estimate for a backend (0 if unobserved)
+func (p *PeakEWMA) Cost(b *backend.Backend) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.costs[b.Name()]; ok {
+		return time.Duration(c.cost)
+	}
+	return 0
+}
+
+// score returns the load score for a backend (lower is better)
+func (p *PeakEWMA) score(b *backend.Backend) float64 {
+	inFlight := float64(b.ActiveConnections())
+	cost := float64(p.Cost(b))
+
+	if cost == 0 {
+		// Unobserved backends are tried first so they get a latency sample
+		return inFlight
+	}
+	return cost * (inFlight + 1)
+}
+
+// Name returns the algorithm name
+func (p *PeakEWMA) Name() string {
+	return "peak-ewma"
+}
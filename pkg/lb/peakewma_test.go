@@ -0,0 +1,92 @@
+package lb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+func TestPeakEWMA(t *testing.T) {
+	pool := backend.NewPool()
+
+	fast := backend.NewBackend("fast", "localhost:9001", 1)
+	slow := backend.NewBackend("slow", "localhost:9002", 1)
+
+	pool.Add(fast)
+	pool.Add(slow)
+
+	p := NewPeakEWMA(pool, DefaultPeakEWMADecay)
+
+	if p.Name() != "peak-ewma" {
+		t.Errorf("Expected name 'peak-ewma', got '%s'", p.Name())
+	}
+
+	p.Observe(fast, 10*time.Millisecond)
+	p.Observe(slow, 100*time.Millisecond)
+
+	// With two backends, both are always compared
+	for i := 0; i < 10; i++ {
+		if b := p.Select(); b != fast {
+			t.Errorf("Expected fast backend, got %v", b.Name())
+		}
+	}
+
+	// Enough in-flight requests outweigh the latency advantage
+	for i := 0; i < 10; i++ {
+		fast.IncrementConnections()
+	}
+
+	if b := p.Select(); b != slow {
+		t.Errorf("Expected slow backend when fast is loaded, got %v", b.Name())
+	}
+}
+
+func TestPeakEWMAPeak(t *testing.T) {
+	pool := backend.NewPool()
+	b := backend.NewBackend("backend-1", "localhost:9001", 1)
+	pool.Add(b)
+
+	p := NewPeakEWMA(pool, time.Hour)
+
+	p.Observe(b, 10*time.Millisecond)
+
+	// Spikes are adopted immediately
+	p.Observe(b, 200*time.Millisecond)
+	if cost := p.Cost(b); cost != 200*time.Millisecond {
+		t.Errorf("Expected cost 200ms after spike, got %s", cost)
+	}
+
+	// Recovery is gradual
+	p.Observe(b, 10*time.Millisecond)
+	if cost := p.Cost(b); cost <= 10*time.Millisecond || cost > 200*time.Millisecond {
+		t.Errorf("Expected cost to decay slowly, got %s", cost)
+	}
+}
+
+func TestPeakEWMAUnobserved(t *testing.T) {
+	pool := backend.NewPool()
+
+	observed := backend.NewBackend("observed", "localhost:9001", 1)
+	fresh := backend.NewBackend("fresh", "localhost:9002", 1)
+
+	pool.Add(observed)
+	pool.Add(fresh)
+
+	p := NewPeakEWMA(pool, DefaultPeakEWMADecay)
+	p.Observe(observed, time.Millisecond)
+
+	// New backends are tried so they get a latency sample
+	if b := p.Select(); b != fresh {
+		t.Errorf("Expected unobserved backend, got %v", b.Name())
+	}
+}
+
+func TestPeakEWMANoBackends(t *testing.T) {
+	pool := backend.NewPool()
+	p := NewPeakEWMA(pool, 0)
+
+	if b := p.Select(); b != nil {
+		t.Errorf("Expected nil, got %v", b)
+	}
+}
@@ -0,0 +1,99 @@
+package lb
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+// Options are the load balancer settings from the configuration
+type Options struct {
+	// HashKey selects the request attribute hashed by consistent hashing
+	HashKey string
+
+	// VirtualNodes is the number of ring positions per unit of backend weight
+	VirtualNodes int
+
+	// LoadFactor caps each backend at this multiple of the average load
+	LoadFactor float64
+
+	// Params are free-form settings for custom algorithms
+	Params map[string]string
+}
+
+// Factory creates a load balancer over a pool
+type Factory func(pool *backend.Pool, opts Options) (LoadBalancer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+func init() {
+	Register("round-robin", func(pool *backend.Pool, _ Options) (LoadBalancer, error) {
+		return NewRoundRobin(pool), nil
+	})
+	Register("least-connections", func(pool *backend.Pool, _ Options) (LoadBalancer, error) {
+		return NewLeastConnections(pool), nil
+	})
+	Register("weighted-round-robin", func(pool *backend.Pool, _ Options) (LoadBalancer, error) {
+		return NewWeightedRoundRobin(pool), nil
+	})
+	Register("weighted-least-connections", func(pool *backend.Pool, _ Options) (LoadBalancer, error) {
+		return NewWeightedLeastConnections(pool), nil
+	})
+	Register("consistent-hash", func(pool *backend.Pool, opts Options) (LoadBalancer, error) {
+		return NewConsistentHash(pool, opts.VirtualNodes, opts.HashKey), nil
+	})
+	Register("bounded-consistent-hash", func(pool *backend.Pool, opts Options) (LoadBalancer, error) {
+		return NewBoundedLoadConsistentHash(pool, opts.VirtualNodes, opts.HashKey, opts.LoadFactor), nil
+	})
+	Register("peak-ewma", func(pool *backend.Pool, _ Options) (LoadBalancer, error) {
+		return NewPeakEWMA(pool, DefaultPeakEWMADecay), nil
+	})
+}
+
+// Register makes a load balancing algorithm selectable by name in the configuration
+// It is meant to be called from an init function, so the algorithm is known before
+// the configuration is loaded, and panics if the name is empty or already registered.
+func Register(name string, factory Factory) {
+	if name == "" || factory == nil {
+		panic("lb: Register requires a name and a factory")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("lb: algorithm %s is already registered", name))
+	}
+	registry[name] = factory
+}
+
+// IsRegistered reports whether an algorithm is registered under name
+func IsRegistered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[name]
+	return ok
+}
+
+// Registered returns the names of all registered algorithms, sorted
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// New creates a load balancer using the algorithm registered under name
+func New(name string, pool *backend.Pool, opts Options) (LoadBalancer, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported load balancer algorithm: %s", name)
+	}
+	return factory(pool, opts)
+}
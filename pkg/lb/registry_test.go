@@ -0,0 +1,72 @@
+package lb
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+// firstBackend always selects the first healthy backend
+type firstBackend struct {
+	pool *backend.Pool
+	name string
+}
+
+func (f *firstBackend) Select() *backend.Backend {
+	if backends := f.pool.Healthy(); len(backends) > 0 {
+		return backends[0]
+	}
+	return nil
+}
+
+func (f *firstBackend) Name() string {
+	return f.name
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-first", func(pool *backend.Pool, opts Options) (LoadBalancer, error) {
+		return &firstBackend{pool: pool, name: opts.Params["name"]}, nil
+	})
+
+	if !IsRegistered("test-first") || !slices.Contains(Registered(), "test-first") {
+		t.Fatal("Expected test-first to be registered")
+	}
+	for _, name := range []string{"round-robin", "consistent-hash", "peak-ewma"} {
+		if !IsRegistered(name) {
+			t.Errorf("Expected built-in algorithm %s to be registered", name)
+		}
+	}
+
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("backend-1", "localhost:9001", 1))
+	pool.Add(backend.NewBackend("backend-2", "localhost:9002", 1))
+
+	balancer, err := New("test-first", pool, Options{Params: map[string]string{"name": "first"}})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	if balancer.Name() != "first" {
+		t.Errorf("Expected params to reach the factory, got name %q", balancer.Name())
+	}
+	for i := 0; i < 3; i++ {
+		if b := balancer.Select(); b == nil || b.Name() != "backend-1" {
+			t.Errorf("Expected backend-1, got %v", b)
+		}
+	}
+
+	if _, err := New("missing", pool, Options{}); err == nil {
+		t.Error("Expected an error for an unregistered algorithm")
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a built-in name to panic")
+		}
+	}()
+	Register("round-robin", func(pool *backend.Pool, _ Options) (LoadBalancer, error) {
+		return NewRoundRobin(pool), nil
+	})
+}
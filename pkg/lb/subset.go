@@ -0,0 +1,60 @@
+package lb
+
+import (
+	"hash/fnv"
+	"sort"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+// Subset deterministically picks size backends for the given instance
+// It uses rendezvous (highest random weight) hashing: every backend is scored by
+// hash(instanceID, backend name) and the highest scores win. The same instance always
+// gets the same subset, different instances get different subsets, and adding or
+// removing a backend only changes the subsets that contained it.
+// The returned backends keep their original order. If size is not smaller than the
+// number of backends, all backends are returned.
+func Subset(backends []*backend.Backend, instanceID string, size int) []*backend.Backend {
+	if size <= 0 || size >= len(backends) {
+		return backends
+	}
+
+	type scored struct {
+		index int
+		score uint64
+	}
+
+	scores := make([]scored, len(backends))
+	for i, b := range backends {
+		h := fnv.New64a()
+		h.Write([]byte(instanceID))
+		h.Write([]byte{0})
+		h.Write([]byte(b.Name()))
+		scores[i] = scored{index: i, score: mix64(h.Sum64())}
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].score > scores[j].score
+	})
+
+	chosen := scores[:size]
+	sort.Slice(chosen, func(i, j int) bool {
+		return chosen[i].index < chosen[j].index
+	})
+
+	result := make([]*backend.Backend, 0, size)
+	for _, s := range chosen {
+		result = append(result, backends[s.index])
+	}
+	return result
+}
+
+// mix64 spreads the bits of an FNV hash so similar inputs get unrelated scores
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
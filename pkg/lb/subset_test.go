@@ -0,0 +1,102 @@
+package lb
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+func newBackends(n int) []*backend.Backend {
+	backends := make([]*backend.Backend, n)
+	for i := range backends {
+		backends[i] = backend.NewBackend(fmt.Sprintf("backend-%d", i), fmt.Sprintf("10.0.0.%d:8080", i), 1)
+	}
+	return backends
+}
+
+func TestSubset(t *testing.T) {
+	backends := newBackends(100)
+
+	subset := Subset(backends, "instance-1", 10)
+	if len(subset) != 10 {
+		t.Fatalf("Expected 10 backends, got %d", len(subset))
+	}
+
+	// Same instance, same subset
+	again := Subset(backends, "instance-1", 10)
+	for i := range subset {
+		if subset[i] != again[i] {
+			t.Fatalf("Expected deterministic subset, got %s and %s at %d", subset[i].Name(), again[i].Name(), i)
+		}
+	}
+
+	// Different instances get different subsets
+	other := Subset(backends, "instance-2", 10)
+	same := 0
+	for i := range subset {
+		if subset[i] == other[i] {
+			same++
+		}
+	}
+	if same == len(subset) {
+		t.Error("Expected different instances to get different subsets")
+	}
+}
+
+func TestSubsetDistribution(t *testing.T) {
+	backends := newBackends(20)
+
+	// 100 instances with subsets of 5 hold 500 connections, 25 per backend on average
+	counts := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		for _, b := range Subset(backends, fmt.Sprintf("instance-%d", i), 5) {
+			counts[b.Name()]++
+		}
+	}
+
+	for _, b := range backends {
+		if c := counts[b.Name()]; c < 10 || c > 40 {
+			t.Errorf("Expected roughly even spread, %s is in %d subsets", b.Name(), c)
+		}
+	}
+}
+
+func TestSubsetStability(t *testing.T) {
+	backends := newBackends(50)
+	before := Subset(backends, "instance-1", 10)
+
+	// Removing a backend outside the subset leaves the subset unchanged
+	inSubset := make(map[*backend.Backend]bool)
+	for _, b := range before {
+		inSubset[b] = true
+	}
+
+	var reduced []*backend.Backend
+	removed := false
+	for _, b := range backends {
+		if !removed && !inSubset[b] {
+			removed = true
+			continue
+		}
+		reduced = append(reduced, b)
+	}
+
+	after := Subset(reduced, "instance-1", 10)
+	for i := range before {
+		if before[i] != after[i] {
+			t.Fatalf("Expected subset to be unchanged, got %s instead of %s", after[i].Name(), before[i].Name())
+		}
+	}
+}
+
+func TestSubsetSmallPool(t *testing.T) {
+	backends := newBackends(3)
+
+	if subset := Subset(backends, "instance-1", 5); len(subset) != 3 {
+		t.Errorf("Expected all 3 backends, got %d", len(subset))
+	}
+	if subset := Subset(backends, "instance-1", 0); len(subset) != 3 {
+		t.Errorf("Expected all 3 backends when disabled, got %d", len(subset))
+	}
+}
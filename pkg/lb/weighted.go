@@ -1,7 +1,7 @@
 package lb
 
 import (
-	"sync/atomic"
+	"sync"
 
 	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
 )
@@ -9,20 +9,26 @@ import (
 // WeightedRoundRobin implements weighted round-robin load balancing
 // Backends with higher weights receive proportionally more requests
 type WeightedRoundRobin struct {
-	pool    *backend.Pool
-	current atomic.Int64
+	pool *backend.Pool
+
+	mu sync.Mutex
+	// current is each backend's current weight in the smooth weighted round-robin
+	current map[*backend.Backend]float64
+	// next is the round-robin position used when no backend has a weight
+	next int
 }
 
 // NewWeightedRoundRobin creates a new weighted round-robin load balancer
 func NewWeightedRoundRobin(pool *backend.Pool) *WeightedRoundRobin {
 	return &WeightedRoundRobin{
-		pool: pool,
+		pool:    pool,
+		current: make(map[*backend.Backend]float64),
 	}
 }
 
 // Select selects a backend using weighted round-robin algorithm
-// This uses the smooth weighted round-robin algorithm (SWRR) by Nginx
-// which provides better distribution than simple weighted round-robin
+// This uses the smooth weighted round-robin algorithm (SWRR) by Nginx, which
+// interleaves the picks instead of sending a backend its whole share in a row
 func (wrr *WeightedRoundRobin) Select() *backend.Backend {
 	backends := wrr.pool.Healthy()
 	if len(backends) == 0 {
@@ -34,17 +40,22 @@ func (wrr *WeightedRoundRobin) Select() *backend.Backend {
 		return backends[0]
 	}
 
-	// Calculate total weight
-	totalWeight := 0
-	for _, b := range backends {
-		totalWeight += b.Weight()
+	// Snapshot the weights, which may change concurrently, so the total and the pick
+	// below agree; weight factors scale them directly
+	weights := make([]float64, len(backends))
+	totalWeight := 0.0
+	for i, b := range backends {
+		weights[i] = max(b.EffectiveWeight(), 0)
+		totalWeight += weights[i]
 	}
 
+	wrr.mu.Lock()
+	defer wrr.mu.Unlock()
+
 	if totalWeight == 0 {
 		// Fallback to simple round-robin if all weights are 0
-		next := wrr.current.Add(1)
-		index := (next - 1) % int64(len(backends))
-		return backends[index]
+		wrr.next++
+		return backends[(wrr.next-1)%len(backends)]
 	}
 
 	// Smooth weighted round-robin algorithm
@@ -53,23 +64,34 @@ func (wrr *WeightedRoundRobin) Select() *backend.Backend {
 	//   1. Add effective_weight to current_weight for all backends
 	//   2. Select the backend with highest current_weight
 	//   3. Subtract total_weight from selected backend's current_weight
+	var selected *backend.Backend
+	var best float64
+	for i, b := range backends {
+		if weights[i] == 0 {
+			continue
+		}
+		current := wrr.current[b] + weights[i]
+		wrr.current[b] = current
+		if selected == nil || current > best {
+			selected, best = b, current
+		}
+	}
+	wrr.current[selected] = best - totalWeight
 
-	// For simplicity in this stateless implementation, we use the atomic counter
-	// to determine which backend to select based on weights
-	next := wrr.current.Add(1)
-	offset := (next - 1) % int64(totalWeight)
-
-	// Find the backend that corresponds to this offset
-	currentOffset := int64(0)
-	for _, b := range backends {
-		currentOffset += int64(b.Weight())
-		if offset < currentOffset {
-			return b
+	// Forget backends that left the healthy set, so they start afresh if they return
+	if len(wrr.current) > len(backends) {
+		healthy := make(map[*backend.Backend]bool, len(backends))
+		for _, b := range backends {
+			healthy[b] = true
+		}
+		for b := range wrr.current {
+			if !healthy[b] {
+				delete(wrr.current, b)
+			}
 		}
 	}
 
-	// Fallback (should not reach here)
-	return backends[0]
+	return selected
 }
 
 // Name returns the algorithm name
@@ -106,13 +128,13 @@ func (wlc *WeightedLeastConnections) Select() *backend.Backend {
 	minRatio := float64(-1)
 
 	for _, b := range backends {
-		weight := b.Weight()
+		weight := b.EffectiveWeight()
 		if weight <= 0 {
 			weight = 1 // Treat 0 or negative weight as 1
 		}
 
 		connections := float64(b.ActiveConnections())
-		ratio := connections / float64(weight)
+		ratio := connections / weight
 
 		if minRatio == -1 || ratio < minRatio {
 			selected = b
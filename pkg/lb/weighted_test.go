@@ -103,6 +103,140 @@ func TestWeightedRoundRobinUnhealthyBackend(t *testing.T) {
 	}
 }
 
+func TestWeightedRoundRobinWeightChange(t *testing.T) {
+	pool := backend.NewPool()
+
+	b1 := backend.NewBackend("backend-1", "localhost:9001", 1)
+	b2 := backend.NewBackend("backend-2", "localhost:9002", 1)
+
+	pool.Add(b1)
+	pool.Add(b2)
+
+	wrr := NewWeightedRoundRobin(pool)
+
+	// Shift all traffic away from backend-2
+	b2.SetWeight(0)
+	for i := 0; i < 10; i++ {
+		b := wrr.Select()
+		if b == nil || b.Name() != "backend-1" {
+			t.Errorf("Expected backend-1, got %v", b)
+		}
+	}
+
+	// Shift most traffic to backend-2
+	b2.SetWeight(3)
+	distribution := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		distribution[wrr.Select().Name()]++
+	}
+
+	if distribution["backend-1"] != 100 || distribution["backend-2"] != 300 {
+		t.Errorf("Expected 100/300 distribution, got %v", distribution)
+	}
+}
+
+func TestWeightedRoundRobinWeightFactor(t *testing.T) {
+	pool := backend.NewPool()
+
+	b1 := backend.NewBackend("backend-1", "localhost:9001", 1)
+	b2 := backend.NewBackend("backend-2", "localhost:9002", 2)
+
+	pool.Add(b1)
+	pool.Add(b2)
+
+	wrr := NewWeightedRoundRobin(pool)
+
+	// Halving backend-2 evens out the traffic without changing its weight
+	b2.SetWeightFactor(0.5)
+	distribution := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		distribution[wrr.Select().Name()]++
+	}
+
+	if distribution["backend-1"] != 200 || distribution["backend-2"] != 200 {
+		t.Errorf("Expected 200/200 distribution, got %v", distribution)
+	}
+	if w := b2.Weight(); w != 2 {
+		t.Errorf("Expected weight 2 to be kept, got %d", w)
+	}
+}
+
+func TestWeightedRoundRobinInterleaves(t *testing.T) {
+	pool := backend.NewPool()
+
+	b1 := backend.NewBackend("backend-1", "localhost:9001", 1)
+	b2 := backend.NewBackend("backend-2", "localhost:9002", 3)
+
+	pool.Add(b1)
+	pool.Add(b2)
+
+	wrr := NewWeightedRoundRobin(pool)
+
+	// Picks are spread out, with or without a weight factor, rather than handed out
+	// as one run per backend
+	for _, factor := range []float64{1, 0.9} {
+		b2.SetWeightFactor(factor)
+
+		run, longest := 0, 0
+		var last *backend.Backend
+		for i := 0; i < 100; i++ {
+			b := wrr.Select()
+			if b == last {
+				run++
+			} else {
+				run = 1
+			}
+			last = b
+			longest = max(longest, run)
+		}
+		if longest > 3 {
+			t.Errorf("Expected runs of at most 3 picks with factor %v, got %d", factor, longest)
+		}
+	}
+}
+
+func TestWeightedRoundRobinConcurrentWeightChange(t *testing.T) {
+	pool := backend.NewPool()
+
+	// backend-1 has no weight, so it must never be picked, even when a weight changes
+	// between summing and picking
+	b1 := backend.NewBackend("backend-1", "localhost:9001", 0)
+	b2 := backend.NewBackend("backend-2", "localhost:9002", 1000)
+	b3 := backend.NewBackend("backend-3", "localhost:9003", 1)
+
+	pool.Add(b1)
+	pool.Add(b2)
+	pool.Add(b3)
+
+	wrr := NewWeightedRoundRobin(pool)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				b2.SetWeight(1)
+			} else {
+				b2.SetWeight(1000)
+			}
+		}
+	}()
+
+	for i := 0; i < 100000; i++ {
+		if b := wrr.Select(); b == nil || b.Name() == "backend-1" {
+			t.Fatalf("Expected a weighted backend, got %v", b)
+		}
+	}
+	close(done)
+	<-stopped
+}
+
 func TestWeightedLeastConnections(t *testing.T) {
 	pool := backend.NewPool()
 
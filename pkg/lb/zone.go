@@ -0,0 +1,79 @@
+package lb
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+// ZoneAware implements locality-aware load balancing
+// It wraps one load balancer over same-zone backends and one over backends in other zones.
+// Requests stay in the local zone except for a configurable spillover percentage, and fall
+// back to the other side whenever one side has no healthy backend.
+type ZoneAware struct {
+	local     LoadBalancer
+	remote    LoadBalancer
+	spillover int // percentage of requests sent to other zones
+}
+
+// NewZoneAware creates a zone-aware load balancer
+// spilloverPercent (0-100) is the share of requests routed to other zones while the local zone is healthy
+func NewZoneAware(local, remote LoadBalancer, spilloverPercent int) *ZoneAware {
+	if spilloverPercent < 0 {
+		spilloverPercent = 0
+	}
+	if spilloverPercent > 100 {
+		spilloverPercent = 100
+	}
+
+	return &ZoneAware{
+		local:     local,
+		remote:    remote,
+		spillover: spilloverPercent,
+	}
+}
+
+// Select selects a backend, preferring the local zone
+func (z *ZoneAware) Select() *backend.Backend {
+	return z.selectWith(func(balancer LoadBalancer) *backend.Backend {
+		return balancer.Select()
+	})
+}
+
+// SelectWithKey selects a backend by key when the underlying algorithm supports it
+func (z *ZoneAware) SelectWithKey(key string) *backend.Backend {
+	return z.selectWith(func(balancer LoadBalancer) *backend.Backend {
+		if keyed, ok := balancer.(interface{ SelectWithKey(string) *backend.Backend }); ok {
+			return keyed.SelectWithKey(key)
+		}
+		return balancer.Select()
+	})
+}
+
+// selectWith picks the zone for this request and falls back to the other one if it is empty
+func (z *ZoneAware) selectWith(selectFn func(LoadBalancer) *backend.Backend) *backend.Backend {
+	first, second := z.local, z.remote
+	if z.spillover > 0 && rand.Intn(100) < z.spillover {
+		first, second = z.remote, z.local
+	}
+
+	if b := selectFn(first); b != nil {
+		return b
+	}
+	return selectFn(second)
+}
+
+// Observe forwards latency samples to the underlying load balancers
+func (z *ZoneAware) Observe(b *backend.Backend, latency time.Duration) {
+	for _, balancer := range []LoadBalancer{z.local, z.remote} {
+		if observer, ok := balancer.(LatencyObserver); ok {
+			observer.Observe(b, latency)
+		}
+	}
+}
+
+// Name returns the algorithm name
+func (z *ZoneAware) Name() string {
+	return "zone-aware(" + z.local.Name() + ")"
+}
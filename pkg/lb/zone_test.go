@@ -0,0 +1,79 @@
+package lb
+
+import (
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+)
+
+func newZonePools() (*backend.Pool, *backend.Pool, *backend.Backend, *backend.Backend) {
+	local := backend.NewPool()
+	remote := backend.NewPool()
+
+	near := backend.NewBackend("near", "localhost:9001", 1)
+	far := backend.NewBackend("far", "localhost:9002", 1)
+
+	local.Add(near)
+	remote.Add(far)
+
+	return local, remote, near, far
+}
+
+func TestZoneAware(t *testing.T) {
+	local, remote, near, far := newZonePools()
+	z := NewZoneAware(NewRoundRobin(local), NewRoundRobin(remote), 0)
+
+	if z.Name() != "zone-aware(round-robin)" {
+		t.Errorf("Expected name 'zone-aware(round-robin)', got '%s'", z.Name())
+	}
+
+	// Without spillover, traffic stays in the local zone
+	for i := 0; i < 10; i++ {
+		if b := z.Select(); b != near {
+			t.Errorf("Expected near backend, got %v", b)
+		}
+	}
+
+	// Traffic leaves the zone when it has no healthy backend
+	near.MarkUnhealthy()
+	if b := z.Select(); b != far {
+		t.Errorf("Expected far backend, got %v", b)
+	}
+
+	far.MarkUnhealthy()
+	if b := z.Select(); b != nil {
+		t.Errorf("Expected nil, got %v", b)
+	}
+}
+
+func TestZoneAwareSpillover(t *testing.T) {
+	local, remote, near, far := newZonePools()
+
+	// Full spillover sends everything to other zones
+	z := NewZoneAware(NewRoundRobin(local), NewRoundRobin(remote), 100)
+	for i := 0; i < 10; i++ {
+		if b := z.Select(); b != far {
+			t.Errorf("Expected far backend, got %v", b)
+		}
+	}
+
+	// Partial spillover sends roughly that share of requests away
+	z = NewZoneAware(NewRoundRobin(local), NewRoundRobin(remote), 20)
+	distribution := make(map[*backend.Backend]int)
+	for i := 0; i < 10000; i++ {
+		distribution[z.Select()]++
+	}
+
+	if distribution[far] < 1500 || distribution[far] > 2500 {
+		t.Errorf("Expected ~2000 spillover requests, got %d (near: %d)", distribution[far], distribution[near])
+	}
+}
+
+func TestZoneAwareSelectWithKey(t *testing.T) {
+	local, remote, near, _ := newZonePools()
+	z := NewZoneAware(NewConsistentHash(local, DefaultVirtualNodes, "source-ip"), NewRoundRobin(remote), 0)
+
+	if b := z.SelectWithKey("10.0.0.1"); b != near {
+		t.Errorf("Expected near backend, got %v", b)
+	}
+}
@@ -2,9 +2,12 @@ package logging
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -23,12 +26,65 @@ type AccessLog struct {
 	Referer        string
 	Backend        string
 	TraceID        string
+	JA3            string
+	Upstream       *UpstreamTiming
 	RequestHeaders map[string]string
 }
 
+// UpstreamTiming breaks down the time a request to a backend took by phase
+// DNS, Connect and TLSHandshake are zero when the request reused a connection.
+type UpstreamTiming struct {
+	// DNS is the time spent resolving the backend address
+	DNS time.Duration
+
+	// Connect is the time spent establishing the TCP connection
+	Connect time.Duration
+
+	// TLSHandshake is the time spent on the TLS handshake with the backend
+	TLSHandshake time.Duration
+
+	// FirstByte is the time from sending the request to the first response byte,
+	// including the phases above
+	FirstByte time.Duration
+
+	// Total is the time until the response was fully copied to the client
+	Total time.Duration
+
+	// Reused is true if the request was sent on an idle connection
+	Reused bool
+}
+
+// accessLogKey is the context key of a request's upstream details
+type accessLogKey struct{}
+
+// accessLogUpstream holds the upstream details the proxy records for a request's
+// access log entry
+type accessLogUpstream struct {
+	mu      sync.Mutex
+	backend string
+	timing  *UpstreamTiming
+}
+
+// SetUpstream records the backend that served a request, and how long its phases took
+// if timing is not nil, in the request's access log entry
+// Later calls, such as from retries, replace earlier ones. It does nothing for
+// requests that are not access logged.
+func SetUpstream(ctx context.Context, backend string, timing *UpstreamTiming) {
+	u, ok := ctx.Value(accessLogKey{}).(*accessLogUpstream)
+	if !ok {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.backend = backend
+	u.timing = timing
+}
+
 // AccessLogger logs HTTP access
 type AccessLogger struct {
-	logger *Logger
+	logger  *Logger
+	sampler *AccessLogSampler
+	closer  io.Closer
 }
 
 // NewAccessLogger creates a new access logger
@@ -38,8 +94,46 @@ func NewAccessLogger(logger *Logger) *AccessLogger {
 	}
 }
 
-// Log logs an access entry
+// OpenAccessLog creates an access logger writing to an output opened by OpenOutput
+// ("" is stdout)
+func OpenAccessLog(output string) (*AccessLogger, error) {
+	if output == "" {
+		output = "stdout"
+	}
+	w, err := OpenOutput(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access log: %w", err)
+	}
+	return &AccessLogger{
+		logger: NewLogger(Config{Level: InfoLevel, Output: w}),
+		closer: w,
+	}, nil
+}
+
+// Close closes the access log output, if the logger opened one
+func (al *AccessLogger) Close() error {
+	if al == nil || al.closer == nil {
+		return nil
+	}
+	return al.closer.Close()
+}
+
+// SetSampler sets the sampler deciding which entries are logged (nil logs all of them)
+// It must be called before the logger is used.
+func (al *AccessLogger) SetSampler(sampler *AccessLogSampler) {
+	al.sampler = sampler
+}
+
+// Log logs an access entry, unless it is sampled out
 func (al *AccessLogger) Log(entry AccessLog) {
+	rate := 1.0
+	if al.sampler != nil {
+		var logged bool
+		if logged, rate = al.sampler.Sample(entry.StatusCode, entry.Duration); !logged {
+			return
+		}
+	}
+
 	fields := []Field{
 		String("client_ip", entry.ClientIP),
 		String("method", entry.Method),
@@ -63,17 +157,45 @@ func (al *AccessLogger) Log(entry AccessLog) {
 		fields = append(fields, String("backend", entry.Backend))
 	}
 
+	if t := entry.Upstream; t != nil {
+		if t.DNS > 0 {
+			fields = append(fields, Duration("upstream_dns", t.DNS))
+		}
+		if t.Connect > 0 {
+			fields = append(fields, Duration("upstream_connect", t.Connect))
+		}
+		if t.TLSHandshake > 0 {
+			fields = append(fields, Duration("upstream_tls", t.TLSHandshake))
+		}
+		fields = append(fields,
+			Duration("upstream_ttfb", t.FirstByte),
+			Duration("upstream_total", t.Total),
+			Bool("upstream_reused", t.Reused))
+	}
+
 	if entry.TraceID != "" {
 		fields = append(fields, String("trace_id", entry.TraceID))
 	}
 
+	if entry.JA3 != "" {
+		fields = append(fields, String("ja3", entry.JA3))
+	}
+
+	// Sampled entries carry their rate so counts can be extrapolated
+	if rate < 1 {
+		fields = append(fields, Float64("sample_rate", rate))
+	}
+
 	al.logger.Info("access", fields...)
 }
 
 // AccessLogMiddleware creates middleware for access logging
 func AccessLogMiddleware(logger *Logger) func(http.Handler) http.Handler {
-	accessLogger := NewAccessLogger(logger)
+	return NewAccessLogger(logger).Middleware()
+}
 
+// Middleware creates middleware logging requests with the access logger
+func (al *AccessLogger) Middleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
@@ -85,8 +207,9 @@ func AccessLogMiddleware(logger *Logger) func(http.Handler) http.Handler {
 				bytesWritten:   0,
 			}
 
-			// Handle request
-			next.ServeHTTP(lrw, r)
+			// Handle request, letting the proxy record the upstream details
+			upstream := &accessLogUpstream{}
+			next.ServeHTTP(lrw, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, upstream)))
 
 			// Extract client IP
 			clientIP := r.RemoteAddr
@@ -108,8 +231,11 @@ func AccessLogMiddleware(logger *Logger) func(http.Handler) http.Handler {
 				UserAgent:    r.UserAgent(),
 				Referer:      r.Referer(),
 			}
+			upstream.mu.Lock()
+			entry.Backend, entry.Upstream = upstream.backend, upstream.timing
+			upstream.mu.Unlock()
 
-			accessLogger.Log(entry)
+			al.Log(entry)
 		})
 	}
 }
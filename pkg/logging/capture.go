@@ -0,0 +1,308 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxCaptureRequests is the most requests a single capture records
+const MaxCaptureRequests = 100
+
+// redactedHeaders are headers whose values are never captured, as they carry credentials
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// CaptureFilter selects the requests a capture records; empty fields match any request
+type CaptureFilter struct {
+	// Method matches the request method exactly
+	Method string `json:"method,omitempty"`
+
+	// Host matches the request host, without port
+	Host string `json:"host,omitempty"`
+
+	// PathPrefix matches requests whose path starts with it
+	PathPrefix string `json:"path_prefix,omitempty"`
+
+	// ClientIP matches the client address
+	ClientIP string `json:"client_ip,omitempty"`
+
+	// Headers match requests carrying each header with the given value
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// matches reports whether a request passes the filter
+func (f *CaptureFilter) matches(r *http.Request, clientIP string) bool {
+	if f.Method != "" && f.Method != r.Method {
+		return false
+	}
+	if f.Host != "" {
+		host := r.Host
+		if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+			host = host[:i]
+		}
+		if !strings.EqualFold(f.Host, host) {
+			return false
+		}
+	}
+	if f.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, f.PathPrefix) {
+		return false
+	}
+	if f.ClientIP != "" && f.ClientIP != clientIP {
+		return false
+	}
+	for name, value := range f.Headers {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// CapturedRequest is the debug record of one request: what the client sent, which
+// backends were tried and how long they took, why attempts were retried and what the
+// client got back
+type CapturedRequest struct {
+	Time           time.Time        `json:"time"`
+	ClientIP       string           `json:"client_ip"`
+	Method         string           `json:"method"`
+	Host           string           `json:"host"`
+	URL            string           `json:"url"`
+	Protocol       string           `json:"protocol"`
+	RequestHeaders http.Header      `json:"request_headers"`
+	Attempts       []CaptureAttempt `json:"attempts,omitempty"`
+	Retries        []CaptureRetry   `json:"retries,omitempty"`
+	Error          string           `json:"error,omitempty"`
+	Status         int              `json:"status"`
+	ResponseHeader http.Header      `json:"response_headers"`
+	Duration       time.Duration    `json:"duration_ns"`
+
+	// redacted are the headers hidden in addition to redactedHeaders
+	redacted map[string]bool
+
+	mu sync.Mutex
+}
+
+// CaptureAttempt is one attempt to serve a captured request from a backend
+type CaptureAttempt struct {
+	Backend  string          `json:"backend"`
+	Address  string          `json:"address"`
+	Status   int             `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Duration time.Duration   `json:"duration_ns"`
+	Upstream *UpstreamTiming `json:"upstream,omitempty"`
+}
+
+// CaptureRetry records the decision to retry a captured request
+type CaptureRetry struct {
+	// Attempt is the attempt that failed
+	Attempt int `json:"attempt"`
+
+	// Reason is the error that was retried
+	Reason string `json:"reason"`
+
+	// Backoff is how long the proxy waited before the next attempt
+	Backoff time.Duration `json:"backoff_ns"`
+}
+
+// AddAttempt records an attempt to serve the request from a backend
+func (cr *CapturedRequest) AddAttempt(attempt CaptureAttempt) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.Attempts = append(cr.Attempts, attempt)
+}
+
+// AddRetry records the decision to retry the request after a failed attempt
+func (cr *CapturedRequest) AddRetry(attempt int, err error, backoff time.Duration) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.Retries = append(cr.Retries, CaptureRetry{Attempt: attempt, Reason: err.Error(), Backoff: backoff})
+}
+
+// SetError records why the request failed
+func (cr *CapturedRequest) SetError(err error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.Error = err.Error()
+}
+
+// Finish records the response to the request
+func (cr *CapturedRequest) Finish(status int, header http.Header, duration time.Duration) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.Status = status
+	cr.ResponseHeader = redactHeaders(header, cr.redacted)
+	cr.Duration = duration
+}
+
+// captureKey is the context key of a request's capture record
+type captureKey struct{}
+
+// NewCaptureContext returns a context carrying a request's capture record
+func NewCaptureContext(ctx context.Context, cr *CapturedRequest) context.Context {
+	return context.WithValue(ctx, captureKey{}, cr)
+}
+
+// CaptureFromContext returns the capture record of a request (nil if it is not captured)
+func CaptureFromContext(ctx context.Context) *CapturedRequest {
+	cr, _ := ctx.Value(captureKey{}).(*CapturedRequest)
+	return cr
+}
+
+// RequestCapture records the next requests matching a filter in full, so a single
+// client or URL can be troubleshot live without raising the log level
+// It records nothing until started; requests are only matched against the filter
+// while a capture is active.
+type RequestCapture struct {
+	active atomic.Bool
+
+	// redacted are the headers hidden in addition to redactedHeaders
+	redacted map[string]bool
+
+	mu        sync.Mutex
+	filter    CaptureFilter
+	remaining int
+	started   time.Time
+	requests  []*CapturedRequest
+}
+
+// CaptureStatus describes a capture and the requests it recorded
+type CaptureStatus struct {
+	Active    bool               `json:"active"`
+	Filter    CaptureFilter      `json:"filter"`
+	Remaining int                `json:"remaining"`
+	Started   time.Time          `json:"started,omitempty"`
+	Requests  []*CapturedRequest `json:"requests"`
+}
+
+// NewRequestCapture creates an inactive request capture
+// The values of the given headers are hidden along with those of the headers that
+// always carry credentials, such as Authorization and Cookie.
+func NewRequestCapture(redact ...string) *RequestCapture {
+	c := &RequestCapture{}
+	if len(redact) > 0 {
+		c.redacted = make(map[string]bool, len(redact))
+		for _, name := range redact {
+			c.redacted[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return c
+}
+
+// Start captures the next count requests matching filter, discarding the requests
+// recorded by any previous capture
+func (c *RequestCapture) Start(filter CaptureFilter, count int) error {
+	if count < 1 || count > MaxCaptureRequests {
+		return fmt.Errorf("capture count must be between 1 and %d", MaxCaptureRequests)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filter = filter
+	c.remaining = count
+	c.started = time.Now()
+	c.requests = nil
+	c.active.Store(true)
+	return nil
+}
+
+// Stop stops capturing and discards the recorded requests
+func (c *RequestCapture) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active.Store(false)
+	c.filter = CaptureFilter{}
+	c.remaining = 0
+	c.requests = nil
+}
+
+// Begin starts recording a request if a capture is active and the request matches
+// its filter (nil otherwise)
+func (c *RequestCapture) Begin(r *http.Request, clientIP string) *CapturedRequest {
+	if c == nil || !c.active.Load() {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.remaining == 0 || !c.filter.matches(r, clientIP) {
+		return nil
+	}
+	c.remaining--
+	if c.remaining == 0 {
+		c.active.Store(false)
+	}
+
+	cr := &CapturedRequest{
+		Time:           time.Now(),
+		ClientIP:       clientIP,
+		Method:         r.Method,
+		Host:           r.Host,
+		URL:            r.URL.RequestURI(),
+		Protocol:       r.Proto,
+		RequestHeaders: redactHeaders(r.Header, c.redacted),
+		redacted:       c.redacted,
+	}
+	c.requests = append(c.requests, cr)
+	return cr
+}
+
+// Status returns the capture state and the requests recorded so far
+// Requests still in flight are included with a zero status.
+func (c *RequestCapture) Status() CaptureStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := CaptureStatus{
+		Active:    c.active.Load(),
+		Filter:    c.filter,
+		Remaining: c.remaining,
+		Started:   c.started,
+		Requests:  make([]*CapturedRequest, 0, len(c.requests)),
+	}
+	for _, cr := range c.requests {
+		status.Requests = append(status.Requests, cr.snapshot())
+	}
+	return status
+}
+
+// snapshot returns a copy of the record that later updates do not touch
+func (cr *CapturedRequest) snapshot() *CapturedRequest {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return &CapturedRequest{
+		Time:           cr.Time,
+		ClientIP:       cr.ClientIP,
+		Method:         cr.Method,
+		Host:           cr.Host,
+		URL:            cr.URL,
+		Protocol:       cr.Protocol,
+		RequestHeaders: cr.RequestHeaders,
+		Attempts:       append([]CaptureAttempt(nil), cr.Attempts...),
+		Retries:        append([]CaptureRetry(nil), cr.Retries...),
+		Error:          cr.Error,
+		Status:         cr.Status,
+		ResponseHeader: cr.ResponseHeader,
+		Duration:       cr.Duration,
+	}
+}
+
+// redactHeaders copies headers, hiding the values of those carrying credentials and
+// of the extra ones
+func redactHeaders(header http.Header, extra map[string]bool) http.Header {
+	out := header.Clone()
+	for name := range out {
+		if canonical := http.CanonicalHeaderKey(name); redactedHeaders[canonical] || extra[canonical] {
+			out[name] = []string{"[redacted]"}
+		}
+	}
+	return out
+}
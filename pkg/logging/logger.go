@@ -1,7 +1,9 @@
 package logging
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -41,6 +43,45 @@ func (l Level) String() string {
 	}
 }
 
+// ParseLevel parses a level name: "debug", "info", "warn", "error" or "fatal"
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return DebugLevel, nil
+	case "info", "":
+		return InfoLevel, nil
+	case "warn":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	case "fatal":
+		return FatalLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("invalid log level: %s (must be debug, info, warn, error or fatal)", name)
+}
+
+// Format is how log entries are encoded
+type Format int
+
+const (
+	// TextFormat writes entries as "time LEVEL message key=value ..."
+	TextFormat Format = iota
+
+	// JSONFormat writes entries as JSON objects, one per line
+	JSONFormat
+)
+
+// ParseFormat parses a format name: "text" or "json"
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "text", "":
+		return TextFormat, nil
+	case "json":
+		return JSONFormat, nil
+	}
+	return TextFormat, fmt.Errorf("invalid log format: %s (must be text or json)", name)
+}
+
 // Field represents a log field
 type Field struct {
 	Key   string
@@ -91,10 +132,20 @@ func Any(key string, value interface{}) Field {
 }
 
 // Logger provides structured logging
+// A nil Logger discards entries, so components can be created without one in tests.
 type Logger struct {
+	sink *sink
+
+	// fields are added to every entry (see With)
+	fields []Field
+}
+
+// sink is the destination a logger shares with the loggers derived from it by With
+type sink struct {
+	mu         sync.Mutex
 	level      Level
+	format     Format
 	output     io.Writer
-	mu         sync.Mutex
 	timeFormat string
 	addCaller  bool
 }
@@ -102,6 +153,7 @@ type Logger struct {
 // Config configures the logger
 type Config struct {
 	Level      Level
+	Format     Format
 	Output     io.Writer
 	TimeFormat string
 	AddCaller  bool
@@ -115,12 +167,13 @@ func NewLogger(config Config) *Logger {
 	if config.TimeFormat == "" {
 		config.TimeFormat = time.RFC3339
 	}
-	return &Logger{
+	return &Logger{sink: &sink{
 		level:      config.Level,
+		format:     config.Format,
 		output:     config.Output,
 		timeFormat: config.TimeFormat,
 		addCaller:  config.AddCaller,
-	}
+	}}
 }
 
 // NewDefaultLogger creates a logger with default settings
@@ -134,10 +187,25 @@ func NewDefaultLogger() *Logger {
 }
 
 // SetLevel sets the logging level
+// It applies to the loggers derived from l by With as well.
 func (l *Logger) SetLevel(level Level) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.level = level
+	if l == nil {
+		return
+	}
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+	l.sink.level = level
+}
+
+// Enabled reports whether entries of the level are written, so callers can skip
+// building fields that would be discarded
+func (l *Logger) Enabled(level Level) bool {
+	if l == nil {
+		return false
+	}
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+	return level >= l.sink.level
 }
 
 // Debug logs a debug message
@@ -191,18 +259,56 @@ func (l *Logger) log(level Level, msg string, fields ...Field) {
 }
 
 func (l *Logger) logContext(ctx context.Context, level Level, msg string, fields ...Field) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	if l == nil {
+		return
+	}
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+
+	if level < l.sink.level {
+		return
+	}
+
+	// Context fields: trace correlation and caller info
+	var ctxFields []Field
+	if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
+		ctxFields = append(ctxFields,
+			String("trace_id", span.SpanContext().TraceID().String()),
+			String("span_id", span.SpanContext().SpanID().String()))
+	}
+	var caller string
+	if l.sink.addCaller {
+		if _, file, line, ok := runtime.Caller(3); ok {
+			// Get just the filename, not full path
+			parts := strings.Split(file, "/")
+			caller = fmt.Sprintf("%s:%d", parts[len(parts)-1], line)
+		}
+	}
 
-	if level < l.level {
+	all := make([]Field, 0, len(l.fields)+len(fields))
+	all = append(append(all, l.fields...), fields...)
+
+	var entry []byte
+	if l.sink.format == JSONFormat {
+		entry = l.jsonEntry(level, caller, msg, ctxFields, all)
+	} else {
+		entry = l.textEntry(level, caller, msg, ctxFields, all)
+	}
+
+	// Write to output
+	if lw, ok := l.sink.output.(LevelWriter); ok {
+		lw.WriteLevel(level, entry)
 		return
 	}
+	l.sink.output.Write(entry)
+}
 
-	// Build log entry
+// textEntry encodes an entry as "time LEVEL [context] [caller] message key=value ..."
+func (l *Logger) textEntry(level Level, caller, msg string, ctxFields, fields []Field) []byte {
 	var b strings.Builder
 
 	// Timestamp
-	b.WriteString(time.Now().Format(l.timeFormat))
+	b.WriteString(time.Now().Format(l.sink.timeFormat))
 	b.WriteString(" ")
 
 	// Level
@@ -210,52 +316,102 @@ func (l *Logger) logContext(ctx context.Context, level Level, msg string, fields
 	b.WriteString(" ")
 
 	// Trace ID (if available)
-	if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
-		b.WriteString("trace_id=")
-		b.WriteString(span.SpanContext().TraceID().String())
-		b.WriteString(" ")
-		b.WriteString("span_id=")
-		b.WriteString(span.SpanContext().SpanID().String())
+	for _, field := range ctxFields {
+		b.WriteString(field.Key)
+		b.WriteString("=")
+		b.WriteString(fmt.Sprintf("%v", field.Value))
 		b.WriteString(" ")
 	}
 
 	// Caller info
-	if l.addCaller {
-		if _, file, line, ok := runtime.Caller(3); ok {
-			// Get just the filename, not full path
-			parts := strings.Split(file, "/")
-			filename := parts[len(parts)-1]
-			b.WriteString(fmt.Sprintf("%s:%d ", filename, line))
-		}
+	if caller != "" {
+		b.WriteString(caller)
+		b.WriteString(" ")
 	}
 
 	// Message
 	b.WriteString(msg)
 
 	// Fields
-	if len(fields) > 0 {
+	for _, field := range fields {
 		b.WriteString(" ")
-		for i, field := range fields {
-			if i > 0 {
-				b.WriteString(" ")
-			}
-			b.WriteString(field.Key)
-			b.WriteString("=")
-			b.WriteString(fmt.Sprintf("%v", field.Value))
-		}
+		b.WriteString(field.Key)
+		b.WriteString("=")
+		b.WriteString(fmt.Sprintf("%v", field.Value))
 	}
 
 	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// jsonEntry encodes an entry as a JSON object with the time, level, context, caller
+// and message first, followed by the fields in order
+func (l *Logger) jsonEntry(level Level, caller, msg string, ctxFields, fields []Field) []byte {
+	var b bytes.Buffer
+	b.WriteString("{")
+	writeJSONField(&b, "time", time.Now().Format(l.sink.timeFormat))
+	b.WriteString(",")
+	writeJSONField(&b, "level", strings.ToLower(level.String()))
+	for _, field := range ctxFields {
+		b.WriteString(",")
+		writeJSONField(&b, field.Key, field.Value)
+	}
+	if caller != "" {
+		b.WriteString(",")
+		writeJSONField(&b, "caller", caller)
+	}
+	b.WriteString(",")
+	writeJSONField(&b, "msg", msg)
+	for _, field := range fields {
+		b.WriteString(",")
+		writeJSONField(&b, field.Key, field.Value)
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
 
-	// Write to output
-	l.output.Write([]byte(b.String()))
+// writeJSONField writes "key":value, falling back to the value's string form when it
+// cannot be encoded
+func writeJSONField(b *bytes.Buffer, key string, value interface{}) {
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+	b.Write(k)
+	b.WriteString(":")
+	b.Write(v)
 }
 
-// With creates a child logger with additional fields
+// With creates a child logger adding fields to every entry
+// The child shares the output and level of l.
 func (l *Logger) With(fields ...Field) *Logger {
-	// For simplicity, return the same logger
-	// In a production system, this would create a child logger with bound fields
-	return l
+	if l == nil {
+		return nil
+	}
+	child := &Logger{sink: l.sink, fields: make([]Field, 0, len(l.fields)+len(fields))}
+	child.fields = append(append(child.fields, l.fields...), fields...)
+	return child
+}
+
+// Writer returns a writer logging each line written to it as a message of the level,
+// for code that logs through the standard log package
+func (l *Logger) Writer(level Level) io.Writer {
+	return lineWriter{logger: l, level: level}
+}
+
+// lineWriter logs the lines written to it
+type lineWriter struct {
+	logger *Logger
+	level  Level
+}
+
+// Write logs each non-empty line of p
+func (w lineWriter) Write(p []byte) (int, error) {
+	for _, line := range logLines(p) {
+		w.logger.log(w.level, strings.TrimSpace(line))
+	}
+	return len(p), nil
 }
 
 // Global logger instance
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// LevelWriter is an output that wants to know the level of each log entry, such as
+// syslog, whose messages carry a severity
+// Logger calls WriteLevel instead of Write for outputs implementing it.
+type LevelWriter interface {
+	WriteLevel(level Level, p []byte) (int, error)
+}
+
+// OpenOutput opens a log output:
+//   - "stdout" or "stderr"
+//   - "syslog://host[:port]" or "syslog+udp://", "syslog+tcp://", "syslog+tls://" for
+//     RFC 5424 syslog over UDP (default), TCP or TLS
+//   - "tcp-json://host:port" or "tcp-json+tls://host:port" for newline-delimited JSON
+//   - anything else is the path of a file to append to
+//
+// Remote outputs take the query parameters "app" (the syslog APP-NAME and JSON "app",
+// default "balance"), "facility" (syslog facility, default "local0") and "ca_file"
+// (CA certificates trusted for TLS instead of the system roots).
+func OpenOutput(output string) (io.WriteCloser, error) {
+	switch output {
+	case "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "stderr":
+		return nopCloser{os.Stderr}, nil
+	}
+
+	scheme, _, remote := strings.Cut(output, "://")
+	if !remote {
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		return f, nil
+	}
+
+	u, err := url.Parse(output)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log output %s: %w", output, err)
+	}
+	query := u.Query()
+	app := query.Get("app")
+	if app == "" {
+		app = "balance"
+	}
+
+	var tlsConfig *tls.Config
+	if strings.HasSuffix(scheme, "+tls") {
+		tlsConfig = &tls.Config{ServerName: u.Hostname()}
+		if caFile := query.Get("ca_file"); caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read log output CA file: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in log output CA file %s", caFile)
+			}
+		}
+	}
+
+	switch scheme {
+	case "syslog", "syslog+udp", "syslog+tcp", "syslog+tls":
+		network, defaultPort := "udp", "514"
+		switch scheme {
+		case "syslog+tcp":
+			network = "tcp"
+		case "syslog+tls":
+			network, defaultPort = "tcp", "6514"
+		}
+		facility, err := syslogFacility(query.Get("facility"))
+		if err != nil {
+			return nil, err
+		}
+		address := u.Host
+		if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), defaultPort)
+		}
+		return newSyslogWriter(newRemoteConn(network, address, tlsConfig), facility, app), nil
+	case "tcp-json", "tcp-json+tls":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("log output %s requires a port", output)
+		}
+		return newJSONWriter(newRemoteConn("tcp", u.Host, tlsConfig), app), nil
+	}
+	return nil, fmt.Errorf("unsupported log output scheme: %s (must be syslog, syslog+udp, syslog+tcp, syslog+tls, tcp-json or tcp-json+tls)", scheme)
+}
+
+// nopCloser is an output that is not closed, such as stdout
+type nopCloser struct {
+	io.Writer
+}
+
+// Close does nothing
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,275 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// remoteQueueSize is how many messages wait for a remote collector before new
+	// ones are dropped, so a slow collector never blocks the proxy
+	remoteQueueSize = 4096
+
+	// remoteDialTimeout bounds connecting to a remote collector
+	remoteDialTimeout = 5 * time.Second
+
+	// remoteWriteTimeout bounds sending a message to a remote collector
+	remoteWriteTimeout = 5 * time.Second
+
+	// syslogTimeFormat is the RFC 5424 timestamp format, with microseconds
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// syslogFacilities maps facility names to their RFC 5424 codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogFacility returns the code of a facility name ("" is local0)
+func syslogFacility(name string) (int, error) {
+	if name == "" {
+		return syslogFacilities["local0"], nil
+	}
+	facility, ok := syslogFacilities[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid syslog facility: %s", name)
+	}
+	return facility, nil
+}
+
+// syslogSeverity returns the RFC 5424 severity of a log level
+func syslogSeverity(level Level) int {
+	switch level {
+	case DebugLevel:
+		return 7
+	case WarnLevel:
+		return 4
+	case ErrorLevel:
+		return 3
+	case FatalLevel:
+		return 2
+	default:
+		return 6
+	}
+}
+
+// remoteConn sends messages to a remote collector from a queue, connecting on first
+// use and reconnecting after a failed write
+// Messages are dropped rather than blocking the writer while the queue is full.
+type remoteConn struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+
+	// stream is true for TCP, where messages need framing
+	stream bool
+
+	queue  chan []byte
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+	conn   net.Conn
+
+	// Statistics
+	dropped atomic.Int64
+}
+
+// newRemoteConn creates a connection to a collector and starts its sender
+func newRemoteConn(network, address string, tlsConfig *tls.Config) *remoteConn {
+	c := &remoteConn{
+		network:   network,
+		address:   address,
+		tlsConfig: tlsConfig,
+		stream:    network != "udp",
+		queue:     make(chan []byte, remoteQueueSize),
+		done:      make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// enqueue queues a message, dropping it if the queue is full or the connection closed
+func (c *remoteConn) enqueue(msg []byte) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		c.dropped.Add(1)
+		return
+	}
+	select {
+	case c.queue <- msg:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// run sends queued messages until the connection is closed
+func (c *remoteConn) run() {
+	defer close(c.done)
+	for msg := range c.queue {
+		if err := c.send(msg); err != nil {
+			c.dropped.Add(1)
+		}
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// send writes a message, retrying once on a new connection as the collector may have
+// closed an idle one
+func (c *remoteConn) send(msg []byte) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.conn == nil {
+			if c.conn, err = c.dial(); err != nil {
+				return err
+			}
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(remoteWriteTimeout))
+		if _, err = c.conn.Write(msg); err == nil {
+			return nil
+		}
+		c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+// dial connects to the collector
+func (c *remoteConn) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: remoteDialTimeout}
+	if c.tlsConfig != nil {
+		return tls.DialWithDialer(dialer, c.network, c.address, c.tlsConfig)
+	}
+	return dialer.Dial(c.network, c.address)
+}
+
+// Close sends the queued messages and closes the connection
+func (c *remoteConn) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mu.Unlock()
+	<-c.done
+	return nil
+}
+
+// syslogWriter writes each line as an RFC 5424 syslog message
+// Over TCP and TLS, messages are framed by octet counting (RFC 6587).
+type syslogWriter struct {
+	*remoteConn
+	facility int
+	app      string
+	hostname string
+	pid      int
+}
+
+// newSyslogWriter creates a syslog writer sending to conn
+func newSyslogWriter(conn *remoteConn, facility int, app string) *syslogWriter {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &syslogWriter{
+		remoteConn: conn,
+		facility:   facility,
+		app:        app,
+		hostname:   hostname,
+		pid:        os.Getpid(),
+	}
+}
+
+// Write writes log lines at info severity
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(InfoLevel, p)
+}
+
+// WriteLevel writes log lines at the severity of the level
+func (w *syslogWriter) WriteLevel(level Level, p []byte) (int, error) {
+	priority := w.facility*8 + syslogSeverity(level)
+	for _, line := range logLines(p) {
+		msg := fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+			priority, time.Now().Format(syslogTimeFormat), w.hostname, w.app, w.pid, line)
+		if w.stream {
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		w.enqueue([]byte(msg))
+	}
+	return len(p), nil
+}
+
+// jsonWriter writes each line as a JSON object followed by a newline
+// Lines that already are JSON objects, such as audit events, are sent as they are;
+// others are wrapped with their time, level and origin.
+type jsonWriter struct {
+	*remoteConn
+	app      string
+	hostname string
+}
+
+// jsonLine is a wrapped log line
+type jsonLine struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Host    string    `json:"host"`
+	App     string    `json:"app"`
+	Message string    `json:"message"`
+}
+
+// newJSONWriter creates a JSON writer sending to conn
+func newJSONWriter(conn *remoteConn, app string) *jsonWriter {
+	hostname, _ := os.Hostname()
+	return &jsonWriter{remoteConn: conn, app: app, hostname: hostname}
+}
+
+// Write writes log lines at info level
+func (w *jsonWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(InfoLevel, p)
+}
+
+// WriteLevel writes log lines of the level
+func (w *jsonWriter) WriteLevel(level Level, p []byte) (int, error) {
+	for _, line := range logLines(p) {
+		msg := []byte(line)
+		if !strings.HasPrefix(line, "{") || !json.Valid(msg) {
+			var err error
+			msg, err = json.Marshal(jsonLine{
+				Time:    time.Now(),
+				Level:   strings.ToLower(level.String()),
+				Host:    w.hostname,
+				App:     w.app,
+				Message: line,
+			})
+			if err != nil {
+				continue
+			}
+		}
+		w.enqueue(append(msg, '\n'))
+	}
+	return len(p), nil
+}
+
+// logLines splits written bytes into non-empty lines
+func logLines(p []byte) []string {
+	var lines []string
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, string(line))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// AccessLogSampler decides which requests are access logged, so access logging stays
+// affordable at high request rates while errors and slow requests are never lost
+// Each status class has its own sample rate; requests at least as slow as the slow
+// threshold are always logged.
+type AccessLogSampler struct {
+	// rates holds the fraction of requests logged per status class (index 1-5)
+	rates [6]float64
+
+	// slowThreshold is the duration from which requests are always logged (0 = none)
+	slowThreshold time.Duration
+
+	// Statistics
+	logged  atomic.Int64
+	dropped atomic.Int64
+}
+
+// NewAccessLogSampler creates a sampler from sample rates per status class, keyed
+// "1xx" to "5xx"; classes without a rate are always logged
+func NewAccessLogSampler(rates map[string]float64, slowThreshold time.Duration) (*AccessLogSampler, error) {
+	s := &AccessLogSampler{slowThreshold: slowThreshold}
+	for class := range s.rates {
+		s.rates[class] = 1
+	}
+	for key, rate := range rates {
+		class, err := statusClass(key)
+		if err != nil {
+			return nil, err
+		}
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("sample rate of %s must be between 0 and 1", key)
+		}
+		s.rates[class] = rate
+	}
+	return s, nil
+}
+
+// statusClass parses a status class key such as "2xx"
+func statusClass(key string) (int, error) {
+	if len(key) != 3 || key[0] < '1' || key[0] > '5' || key[1:] != "xx" {
+		return 0, fmt.Errorf("invalid status class: %s (must be 1xx, 2xx, 3xx, 4xx or 5xx)", key)
+	}
+	return int(key[0] - '0'), nil
+}
+
+// Sample reports whether a request with the given status and duration is logged and
+// the rate it was sampled at
+func (s *AccessLogSampler) Sample(status int, duration time.Duration) (bool, float64) {
+	rate := 1.0
+	if class := status / 100; class >= 1 && class <= 5 {
+		rate = s.rates[class]
+	}
+	if s.slowThreshold > 0 && duration >= s.slowThreshold {
+		rate = 1
+	}
+
+	if rate < 1 && rand.Float64() >= rate {
+		s.dropped.Add(1)
+		return false, rate
+	}
+	s.logged.Add(1)
+	return true, rate
+}
+
+// Stats returns sampling statistics
+func (s *AccessLogSampler) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"logged":  s.logged.Load(),
+		"dropped": s.dropped.Load(),
+	}
+}
@@ -1,14 +1,21 @@
 package metrics
 
 import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -30,6 +37,15 @@ var (
 		[]string{"backend", "method"},
 	)
 
+	upstreamPhaseDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "balance_upstream_phase_duration_seconds",
+			Help:    "Duration of the phases of requests to backends in seconds (dns, connect, tls, ttfb, total)",
+			Buckets: []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"backend", "phase"},
+	)
+
 	requestErrors = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "balance_request_errors_total",
@@ -63,6 +79,40 @@ var (
 		[]string{"backend"},
 	)
 
+	// Health check metrics
+	healthChecksTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_health_checks_total",
+			Help: "Total number of active health checks by result (success, failure)",
+		},
+		[]string{"backend", "result"},
+	)
+
+	healthCheckDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "balance_health_check_duration_seconds",
+			Help:    "Active health check duration in seconds",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"backend"},
+	)
+
+	healthCheckConsecutiveFailures = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "balance_health_check_consecutive_failures",
+			Help: "Number of consecutive failed health checks of backend",
+		},
+		[]string{"backend"},
+	)
+
+	backendLastTransition = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "balance_backend_last_transition_timestamp_seconds",
+			Help: "Unix time of the backend's last health state change",
+		},
+		[]string{"backend"},
+	)
+
 	// Connection pool metrics
 	poolConnectionsActive = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -96,6 +146,14 @@ var (
 		[]string{"backend"},
 	)
 
+	poolExhausted = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_pool_exhausted_total",
+			Help: "Total number of times a connection was requested from a pool at its maximum size",
+		},
+		[]string{"backend"},
+	)
+
 	// Circuit breaker metrics
 	circuitBreakerState = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -130,6 +188,82 @@ var (
 		[]string{"backend"},
 	)
 
+	// TCP proxy metrics
+	tcpConnectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_tcp_connections_total",
+			Help: "Total number of TCP connections proxied to backend",
+		},
+		[]string{"backend"},
+	)
+
+	tcpConnectionsActive = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "balance_tcp_connections_active",
+			Help: "Number of TCP connections currently proxied to backend",
+		},
+		[]string{"backend"},
+	)
+
+	tcpConnectDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "balance_tcp_connect_duration_seconds",
+			Help:    "Time to connect to backend in seconds, including the backend TLS handshake",
+			Buckets: []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+		[]string{"backend"},
+	)
+
+	tcpBytesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_tcp_bytes_total",
+			Help: "Total bytes proxied over TCP connections by direction (received from clients, sent to clients)",
+		},
+		[]string{"backend", "direction"},
+	)
+
+	tcpConnectionErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_tcp_connection_errors_total",
+			Help: "Total number of TCP connections that failed by reason (no_backend, connection_limit, circuit_open, connect, stream)",
+		},
+		[]string{"backend", "reason"},
+	)
+
+	// WebSocket metrics
+	websocketConnectionsActive = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "balance_websocket_connections_active",
+			Help: "Number of WebSocket connections currently proxied to backend",
+		},
+		[]string{"backend"},
+	)
+
+	websocketUpgradeFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_websocket_upgrade_failures_total",
+			Help: "Total number of WebSocket upgrades that failed by reason (no_backend, connect, hijack, handshake, rejected)",
+		},
+		[]string{"backend", "reason"},
+	)
+
+	websocketBytesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_websocket_bytes_total",
+			Help: "Total WebSocket bytes proxied by direction (received from clients, sent to clients)",
+		},
+		[]string{"backend", "direction"},
+	)
+
+	websocketConnectionDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "balance_websocket_connection_duration_seconds",
+			Help:    "Duration of WebSocket connections in seconds",
+			Buckets: []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 14400, 43200, 86400},
+		},
+		[]string{"backend"},
+	)
+
 	// TLS metrics
 	tlsHandshakesTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -147,6 +281,22 @@ var (
 		},
 	)
 
+	tlsCertExpirySeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "balance_tls_cert_expiry_seconds",
+			Help: "Seconds until the TLS certificate expires (negative once expired)",
+		},
+		[]string{"certificate"},
+	)
+
+	tlsBlockedHandshakes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_tls_blocked_handshakes_total",
+			Help: "Total number of TLS handshakes rejected by JA3 fingerprint",
+		},
+		[]string{"ja3"},
+	)
+
 	// Rate limiting metrics
 	rateLimitedRequests = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -155,8 +305,110 @@ var (
 		},
 		[]string{"client_ip"},
 	)
+
+	rateLimitDecisions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_rate_limit_decisions_total",
+			Help: "Total number of rate limiter decisions by limiter and result (allowed, blocked)",
+		},
+		[]string{"limiter", "result"},
+	)
+
+	rateLimitActiveKeys = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "balance_rate_limit_active_keys",
+			Help: "Number of keys (buckets or windows) tracked by a rate limiter",
+		},
+		[]string{"limiter"},
+	)
+
+	// Connection guard metrics
+	connectionGuardDecisions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_connection_guard_decisions_total",
+			Help: "Total number of client connections checked by the connection guard by result (allowed, rate_limited, too_many_connections)",
+		},
+		[]string{"result"},
+	)
+
+	connectionGuardActive = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "balance_connection_guard_active_connections",
+			Help: "Number of client connections admitted by the connection guard that are still open",
+		},
+	)
+
+	connectionGuardTrackedIPs = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "balance_connection_guard_tracked_ips",
+			Help: "Number of client IPs tracked by the connection guard",
+		},
+	)
+)
+
+// ClientLabelMode controls how client addresses are reported in metric labels
+type ClientLabelMode string
+
+const (
+	// ClientLabelIP uses the full client IP address (highest cardinality)
+	ClientLabelIP ClientLabelMode = "ip"
+
+	// ClientLabelPrefix aggregates clients by network prefix (/24 for IPv4, /48 for IPv6)
+	ClientLabelPrefix ClientLabelMode = "prefix"
+
+	// ClientLabelHash maps clients into a fixed number of hash buckets
+	ClientLabelHash ClientLabelMode = "hash"
+
+	// ClientLabelDrop drops the client label entirely
+	ClientLabelDrop ClientLabelMode = "drop"
 )
 
+// clientHashBuckets is the number of buckets used by ClientLabelHash
+const clientHashBuckets = 256
+
+var clientLabelMode atomic.Value // ClientLabelMode
+
+func init() {
+	clientLabelMode.Store(ClientLabelIP)
+}
+
+// SetClientLabelMode sets the policy applied to all per-client metric labels
+func SetClientLabelMode(mode string) error {
+	switch m := ClientLabelMode(mode); m {
+	case "":
+		clientLabelMode.Store(ClientLabelIP)
+	case ClientLabelIP, ClientLabelPrefix, ClientLabelHash, ClientLabelDrop:
+		clientLabelMode.Store(m)
+	default:
+		return fmt.Errorf("invalid client label mode: %s (must be 'ip', 'prefix', 'hash', or 'drop')", mode)
+	}
+	return nil
+}
+
+// ClientLabel returns the label value for a client IP under the current policy
+func ClientLabel(clientIP string) string {
+	switch clientLabelMode.Load().(ClientLabelMode) {
+	case ClientLabelPrefix:
+		ip := net.ParseIP(clientIP)
+		if ip == nil {
+			return "invalid"
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+		}
+		return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+	case ClientLabelHash:
+		h := fnv.New32a()
+		h.Write([]byte(clientIP))
+		return fmt.Sprintf("bucket-%03d", h.Sum32()%clientHashBuckets)
+	case ClientLabelDrop:
+		// An empty label value is equivalent to the label being absent
+		return ""
+	default:
+		return clientIP
+	}
+}
+
 // Collector manages metrics collection
 type Collector struct {
 	registry *prometheus.Registry
@@ -165,17 +417,67 @@ type Collector struct {
 
 // NewCollector creates a new metrics collector
 func NewCollector() *Collector {
+	registry := prometheus.NewRegistry()
+	registerRuntimeCollectors(registry)
+
 	return &Collector{
-		registry: prometheus.NewRegistry(),
+		registry: registry,
 	}
 }
 
+var runtimeOnce sync.Once
+
+// RegisterRuntimeCollectors exports Go runtime and process metrics on the default registry
+// This includes GC pause and scheduler latency histograms, goroutine count, open fds and RSS
+func RegisterRuntimeCollectors() {
+	runtimeOnce.Do(func() {
+		// Replace the default Go collector with one that also exposes runtime/metrics
+		prometheus.Unregister(collectors.NewGoCollector())
+		prometheus.Unregister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+		registerRuntimeCollectors(prometheus.DefaultRegisterer)
+	})
+}
+
+// registerRuntimeCollectors registers the Go and process collectors on a registerer
+func registerRuntimeCollectors(reg prometheus.Registerer) {
+	reg.MustRegister(
+		collectors.NewGoCollector(
+			collectors.WithGoCollectorRuntimeMetrics(
+				collectors.MetricsGC,
+				collectors.MetricsMemory,
+				collectors.MetricsScheduler,
+			),
+		),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
 // RecordRequest records a request metric
 func RecordRequest(backend, method, status string, duration time.Duration) {
 	requestsTotal.WithLabelValues(backend, method, status).Inc()
 	requestDuration.WithLabelValues(backend, method).Observe(duration.Seconds())
 }
 
+// RecordRequestContext records a request metric, attaching the trace ID from ctx
+// as an exemplar on the duration histogram when the request is being traced
+func RecordRequestContext(ctx context.Context, backend, method, status string, duration time.Duration) {
+	requestsTotal.WithLabelValues(backend, method, status).Inc()
+
+	observer := requestDuration.WithLabelValues(backend, method)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		if eo, ok := observer.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(duration.Seconds(), prometheus.Labels{"trace_id": sc.TraceID().String()})
+			return
+		}
+	}
+	observer.Observe(duration.Seconds())
+}
+
+// RecordUpstreamPhase records how long a phase of a request to a backend took
+func RecordUpstreamPhase(backend, phase string, duration time.Duration) {
+	upstreamPhaseDuration.WithLabelValues(backend, phase).Observe(duration.Seconds())
+}
+
 // RecordRequestError records a request error
 func RecordRequestError(backend, errorType string) {
 	requestErrors.WithLabelValues(backend, errorType).Inc()
@@ -195,6 +497,41 @@ func SetBackendHealthStatus(backend string, healthy bool) {
 	backendHealthStatus.WithLabelValues(backend).Set(status)
 }
 
+// DeleteBackend removes every series of a backend that left the pool, so removed
+// backends stop being reported
+func DeleteBackend(backend string) {
+	labels := prometheus.Labels{"backend": backend}
+	requestsTotal.DeletePartialMatch(labels)
+	requestDuration.DeletePartialMatch(labels)
+	upstreamPhaseDuration.DeletePartialMatch(labels)
+	requestErrors.DeletePartialMatch(labels)
+	backendConnectionsActive.DeletePartialMatch(labels)
+	backendHealthStatus.DeletePartialMatch(labels)
+	backendRequestsInFlight.DeletePartialMatch(labels)
+	healthChecksTotal.DeletePartialMatch(labels)
+	healthCheckDuration.DeletePartialMatch(labels)
+	healthCheckConsecutiveFailures.DeletePartialMatch(labels)
+	backendLastTransition.DeletePartialMatch(labels)
+	poolConnectionsActive.DeletePartialMatch(labels)
+	poolConnectionsIdle.DeletePartialMatch(labels)
+	poolConnectionsCreated.DeletePartialMatch(labels)
+	poolConnectionsReused.DeletePartialMatch(labels)
+	poolExhausted.DeletePartialMatch(labels)
+	circuitBreakerState.DeletePartialMatch(labels)
+	circuitBreakerOpenTotal.DeletePartialMatch(labels)
+	retriesTotal.DeletePartialMatch(labels)
+	retriesExhausted.DeletePartialMatch(labels)
+	tcpConnectionsTotal.DeletePartialMatch(labels)
+	tcpConnectionsActive.DeletePartialMatch(labels)
+	tcpConnectDuration.DeletePartialMatch(labels)
+	tcpBytesTotal.DeletePartialMatch(labels)
+	tcpConnectionErrors.DeletePartialMatch(labels)
+	websocketConnectionsActive.DeletePartialMatch(labels)
+	websocketUpgradeFailures.DeletePartialMatch(labels)
+	websocketBytesTotal.DeletePartialMatch(labels)
+	websocketConnectionDuration.DeletePartialMatch(labels)
+}
+
 // IncBackendRequestsInFlight increments in-flight requests
 func IncBackendRequestsInFlight(backend string) {
 	backendRequestsInFlight.WithLabelValues(backend).Inc()
@@ -205,6 +542,26 @@ func DecBackendRequestsInFlight(backend string) {
 	backendRequestsInFlight.WithLabelValues(backend).Dec()
 }
 
+// RecordHealthCheck records the result and duration of an active health check
+func RecordHealthCheck(backend string, success bool, duration time.Duration) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	healthChecksTotal.WithLabelValues(backend, result).Inc()
+	healthCheckDuration.WithLabelValues(backend).Observe(duration.Seconds())
+}
+
+// SetHealthCheckConsecutiveFailures sets the consecutive health check failures gauge
+func SetHealthCheckConsecutiveFailures(backend string, count int64) {
+	healthCheckConsecutiveFailures.WithLabelValues(backend).Set(float64(count))
+}
+
+// SetBackendLastTransition records when a backend's health state last changed
+func SetBackendLastTransition(backend string, at time.Time) {
+	backendLastTransition.WithLabelValues(backend).Set(float64(at.UnixNano()) / 1e9)
+}
+
 // SetPoolConnectionsActive sets pool active connections
 func SetPoolConnectionsActive(backend string, count int) {
 	poolConnectionsActive.WithLabelValues(backend).Set(float64(count))
@@ -225,6 +582,11 @@ func IncPoolConnectionsReused(backend string) {
 	poolConnectionsReused.WithLabelValues(backend).Inc()
 }
 
+// IncPoolExhausted increments the pool exhaustion counter
+func IncPoolExhausted(backend string) {
+	poolExhausted.WithLabelValues(backend).Inc()
+}
+
 // SetCircuitBreakerState sets circuit breaker state
 // 0=closed, 1=open, 2=half-open
 func SetCircuitBreakerState(backend string, state int) {
@@ -246,20 +608,117 @@ func IncRetriesExhausted(backend string) {
 	retriesExhausted.WithLabelValues(backend).Inc()
 }
 
+// IncTCPConnections counts a TCP connection proxied to a backend as opened
+func IncTCPConnections(backend string) {
+	tcpConnectionsTotal.WithLabelValues(backend).Inc()
+	tcpConnectionsActive.WithLabelValues(backend).Inc()
+}
+
+// DecTCPConnectionsActive counts a TCP connection proxied to a backend as closed
+func DecTCPConnectionsActive(backend string) {
+	tcpConnectionsActive.WithLabelValues(backend).Dec()
+}
+
+// RecordTCPConnect records how long connecting to a backend took
+func RecordTCPConnect(backend string, duration time.Duration) {
+	tcpConnectDuration.WithLabelValues(backend).Observe(duration.Seconds())
+}
+
+// AddTCPBytes adds bytes proxied over a TCP connection ("received" from the client or
+// "sent" to it)
+func AddTCPBytes(backend, direction string, n int64) {
+	tcpBytesTotal.WithLabelValues(backend, direction).Add(float64(n))
+}
+
+// IncTCPConnectionErrors records a TCP connection that failed (backend is empty when
+// none could be selected)
+func IncTCPConnectionErrors(backend, reason string) {
+	tcpConnectionErrors.WithLabelValues(backend, reason).Inc()
+}
+
+// IncWebSocketConnectionsActive counts an upgraded WebSocket connection as open
+func IncWebSocketConnectionsActive(backend string) {
+	websocketConnectionsActive.WithLabelValues(backend).Inc()
+}
+
+// RecordWebSocketConnection records a closed WebSocket connection: its duration and the
+// bytes received from and sent to the client
+func RecordWebSocketConnection(backend string, duration time.Duration, received, sent int64) {
+	websocketConnectionsActive.WithLabelValues(backend).Dec()
+	websocketConnectionDuration.WithLabelValues(backend).Observe(duration.Seconds())
+	websocketBytesTotal.WithLabelValues(backend, "received").Add(float64(received))
+	websocketBytesTotal.WithLabelValues(backend, "sent").Add(float64(sent))
+}
+
+// IncWebSocketUpgradeFailures records a WebSocket upgrade that failed (backend is empty
+// when none could be selected)
+func IncWebSocketUpgradeFailures(backend, reason string) {
+	websocketUpgradeFailures.WithLabelValues(backend, reason).Inc()
+}
+
 // RecordTLSHandshake records a TLS handshake
 func RecordTLSHandshake(status string, duration time.Duration) {
 	tlsHandshakesTotal.WithLabelValues(status).Inc()
 	tlsHandshakeDuration.Observe(duration.Seconds())
 }
 
+// SetTLSCertExpiry sets the remaining lifetime of a TLS certificate
+func SetTLSCertExpiry(certificate string, remaining time.Duration) {
+	tlsCertExpirySeconds.WithLabelValues(certificate).Set(remaining.Seconds())
+}
+
+// IncTLSBlockedHandshakes increments TLS handshakes rejected by JA3 fingerprint
+// Only blocklisted fingerprints are counted, so the label set stays bounded
+func IncTLSBlockedHandshakes(ja3 string) {
+	tlsBlockedHandshakes.WithLabelValues(ja3).Inc()
+}
+
 // IncRateLimitedRequests increments rate limited requests
+// The client label is derived according to the configured client label mode
 func IncRateLimitedRequests(clientIP string) {
-	rateLimitedRequests.WithLabelValues(clientIP).Inc()
+	rateLimitedRequests.WithLabelValues(ClientLabel(clientIP)).Inc()
+}
+
+// RecordRateLimitDecision records whether a rate limiter allowed a request
+// The limiter label is the limiter's name, never a client key, so it stays bounded.
+func RecordRateLimitDecision(limiter string, allowed bool) {
+	result := "blocked"
+	if allowed {
+		result = "allowed"
+	}
+	rateLimitDecisions.WithLabelValues(limiter, result).Inc()
+}
+
+// SetRateLimitActiveKeys sets the number of keys tracked by a rate limiter
+func SetRateLimitActiveKeys(limiter string, count int) {
+	rateLimitActiveKeys.WithLabelValues(limiter).Set(float64(count))
+}
+
+// RecordConnectionGuardDecision records a connection guard decision
+// result is "allowed", "rate_limited" or "too_many_connections"
+func RecordConnectionGuardDecision(result string) {
+	connectionGuardDecisions.WithLabelValues(result).Inc()
+}
+
+// SetConnectionGuardActive sets the number of connections admitted by the connection guard
+func SetConnectionGuardActive(count int64) {
+	connectionGuardActive.Set(float64(count))
+}
+
+// SetConnectionGuardTrackedIPs sets the number of client IPs tracked by the connection guard
+func SetConnectionGuardTrackedIPs(count int) {
+	connectionGuardTrackedIPs.Set(float64(count))
 }
 
 // MetricsHandler returns an HTTP handler for Prometheus metrics
+// OpenMetrics negotiation is enabled so that exemplars are exposed
 func MetricsHandler() http.Handler {
-	return promhttp.Handler()
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		}),
+	)
 }
 
 // RequestMetricsMiddleware wraps an HTTP handler with metrics collection
@@ -281,7 +740,7 @@ func RequestMetricsMiddleware(backend string) func(http.Handler) http.Handler {
 			// Record metrics
 			duration := time.Since(start)
 			status := strconv.Itoa(rw.statusCode)
-			RecordRequest(backend, r.Method, status, duration)
+			RecordRequestContext(r.Context(), backend, r.Method, status, duration)
 
 			// Record error if status >= 500
 			if rw.statusCode >= 500 {
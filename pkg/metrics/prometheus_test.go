@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestClientLabel(t *testing.T) {
+	defer SetClientLabelMode("")
+
+	tests := []struct {
+		mode     string
+		clientIP string
+		expected string
+	}{
+		{"ip", "203.0.113.7", "203.0.113.7"},
+		{"ip", "2001:db8::1", "2001:db8::1"},
+		{"", "203.0.113.7", "203.0.113.7"},
+		{"prefix", "203.0.113.7", "203.0.113.0/24"},
+		{"prefix", "2001:db8:1:2::1", "2001:db8:1::/48"},
+		{"prefix", "not-an-ip", "invalid"},
+		{"drop", "203.0.113.7", ""},
+	}
+	for _, tt := range tests {
+		if err := SetClientLabelMode(tt.mode); err != nil {
+			t.Fatalf("SetClientLabelMode(%q) failed: %v", tt.mode, err)
+		}
+		if label := ClientLabel(tt.clientIP); label != tt.expected {
+			t.Errorf("mode %q: expected %q for %s, got %q", tt.mode, tt.expected, tt.clientIP, label)
+		}
+	}
+}
+
+func TestClientLabelHash(t *testing.T) {
+	defer SetClientLabelMode("")
+	if err := SetClientLabelMode("hash"); err != nil {
+		t.Fatalf("SetClientLabelMode failed: %v", err)
+	}
+
+	label := ClientLabel("203.0.113.7")
+	if !regexp.MustCompile(`^bucket-\d{3}$`).MatchString(label) {
+		t.Errorf("Expected a bucket label, got %q", label)
+	}
+	if again := ClientLabel("203.0.113.7"); again != label {
+		t.Errorf("Expected the same client in the same bucket, got %q and %q", label, again)
+	}
+
+	// Clients are spread over at most clientHashBuckets values
+	buckets := make(map[string]bool)
+	for i := 0; i < 4096; i++ {
+		buckets[ClientLabel(fmt.Sprintf("10.0.%d.%d", i/256, i%256))] = true
+	}
+	if len(buckets) < 2 || len(buckets) > clientHashBuckets {
+		t.Errorf("Expected clients spread over up to %d buckets, got %d", clientHashBuckets, len(buckets))
+	}
+}
+
+func TestSetClientLabelModeInvalid(t *testing.T) {
+	defer SetClientLabelMode("")
+	SetClientLabelMode("prefix")
+
+	if err := SetClientLabelMode("subnet"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+	// The previous mode is kept
+	if label := ClientLabel("203.0.113.7"); label != "203.0.113.0/24" {
+		t.Errorf("Expected the prefix mode to be kept, got %q", label)
+	}
+}
+
+func TestRegisterRuntimeCollectors(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Expected registering the runtime collectors twice not to panic, got %v", r)
+		}
+	}()
+	RegisterRuntimeCollectors()
+	RegisterRuntimeCollectors()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, mf := range families {
+		names[mf.GetName()] = true
+	}
+	for _, name := range []string{"go_goroutines", "go_sched_latencies_seconds", "process_open_fds"} {
+		if !names[name] {
+			t.Errorf("Expected %s to be exported", name)
+		}
+	}
+}
+
+func TestNewCollectorRegistries(t *testing.T) {
+	// Each collector has its own registry, so creating several does not conflict
+	for i := 0; i < 2; i++ {
+		c := NewCollector()
+		if _, err := c.registry.Gather(); err != nil {
+			t.Errorf("Failed to gather collector %d: %v", i, err)
+		}
+	}
+}
+
+func TestMetricsHandlerExemplars(t *testing.T) {
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{0, 0, 0, 0, 0, 0, 0, 1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	RecordRequestContext(ctx, "exemplar-backend", "GET", "200", 25*time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Fatalf("Expected OpenMetrics output, got %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+
+	// The exemplar follows the bucket the observation fell into
+	pattern := regexp.MustCompile(`balance_request_duration_seconds_bucket\{backend="exemplar-backend",method="GET",le="[^"]+"\} \d+ # \{trace_id="` + traceID.String() + `"\} 0\.025`)
+	if !pattern.Match(body) {
+		t.Errorf("Expected an exemplar with the trace ID in the OpenMetrics output")
+	}
+}
+
+func TestRecordRequestContextUnsampled(t *testing.T) {
+	// Requests that are not traced are recorded without an exemplar
+	RecordRequestContext(context.Background(), "untraced-backend", "GET", "200", 25*time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, req)
+
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if strings.Contains(line, `backend="untraced-backend"`) && strings.Contains(line, "trace_id") {
+			t.Errorf("Expected no exemplar for an untraced request, got %s", line)
+		}
+	}
+}
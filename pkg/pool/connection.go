@@ -6,6 +6,8 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
 )
 
 var (
@@ -26,7 +28,7 @@ type PooledConnection struct {
 	lastUsed     time.Time
 	inUse        bool
 	mu           sync.Mutex
-	closeOnce    sync.Once
+	released     bool
 }
 
 // Read implements net.Conn
@@ -51,16 +53,23 @@ func (pc *PooledConnection) Write(b []byte) (n int, err error) {
 
 // Close returns the connection to the pool or closes it if the pool is full
 func (pc *PooledConnection) Close() error {
-	var err error
-	pc.closeOnce.Do(func() {
-		if pc.pool != nil && !pc.pool.closed {
-			err = pc.pool.put(pc)
-		} else if pc.conn != nil {
-			err = pc.conn.Close()
-			pc.conn = nil
-		}
-	})
-	return err
+	pc.mu.Lock()
+	if pc.released {
+		pc.mu.Unlock()
+		return nil
+	}
+	pc.released = true
+	pc.mu.Unlock()
+
+	if pc.pool != nil && !pc.pool.closed {
+		return pc.pool.put(pc)
+	}
+	if pc.conn != nil {
+		err := pc.conn.Close()
+		pc.conn = nil
+		return err
+	}
+	return nil
 }
 
 // LocalAddr implements net.Conn
@@ -110,10 +119,12 @@ func (pc *PooledConnection) updateLastUsed() {
 }
 
 // MarkInUse marks the connection as in use
+// Closing it then returns it to the pool again.
 func (pc *PooledConnection) MarkInUse() {
 	pc.mu.Lock()
 	pc.inUse = true
 	pc.lastUsed = time.Now()
+	pc.released = false
 	pc.mu.Unlock()
 }
 
@@ -124,8 +135,8 @@ func (pc *PooledConnection) IsHealthy() bool {
 	}
 
 	// Try to set a read deadline and check for errors
-	// This is a lightweight check
-	one := []byte{}
+	// This is a lightweight check; an empty buffer would return without reaching the socket
+	one := make([]byte, 1)
 	pc.conn.SetReadDeadline(time.Now())
 	_, err := pc.conn.Read(one)
 	pc.conn.SetReadDeadline(time.Time{})
@@ -141,6 +152,7 @@ func (pc *PooledConnection) IsHealthy() bool {
 
 // ConnectionPool manages a pool of connections to a backend
 type ConnectionPool struct {
+	name            string
 	address         string
 	maxSize         int
 	maxIdleTime     time.Duration
@@ -158,6 +170,7 @@ type ConnectionPool struct {
 
 // PoolConfig configures a connection pool
 type PoolConfig struct {
+	Name           string // Backend name labeling the pool's metrics (default: Address)
 	Address        string
 	MaxSize        int
 	MaxIdleTime    time.Duration
@@ -175,8 +188,12 @@ func NewConnectionPool(config PoolConfig) *ConnectionPool {
 	if config.ConnectTimeout <= 0 {
 		config.ConnectTimeout = 5 * time.Second
 	}
+	if config.Name == "" {
+		config.Name = config.Address
+	}
 
 	pool := &ConnectionPool{
+		name:           config.Name,
 		address:        config.Address,
 		maxSize:        config.MaxSize,
 		maxIdleTime:    config.MaxIdleTime,
@@ -206,6 +223,7 @@ func (p *ConnectionPool) Get(ctx context.Context) (*PooledConnection, error) {
 		return nil, ErrPoolClosed
 	}
 	p.mu.RUnlock()
+	defer p.recordGauges()
 
 	// Try to get an existing connection from the pool
 	select {
@@ -216,12 +234,16 @@ func (p *ConnectionPool) Get(ctx context.Context) (*PooledConnection, error) {
 			p.mu.Lock()
 			p.totalReused++
 			p.mu.Unlock()
+			metrics.IncPoolConnectionsReused(p.name)
 			return pc, nil
 		}
 		// Connection is unhealthy, close it and create a new one
 		if pc.conn != nil {
 			pc.conn.Close()
 		}
+		p.mu.Lock()
+		p.activeCount--
+		p.mu.Unlock()
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
@@ -232,6 +254,7 @@ func (p *ConnectionPool) Get(ctx context.Context) (*PooledConnection, error) {
 	p.mu.Lock()
 	if p.activeCount >= p.maxSize {
 		p.mu.Unlock()
+		metrics.IncPoolExhausted(p.name)
 
 		// Wait for a connection to become available or context to cancel
 		select {
@@ -241,6 +264,7 @@ func (p *ConnectionPool) Get(ctx context.Context) (*PooledConnection, error) {
 				p.mu.Lock()
 				p.totalReused++
 				p.mu.Unlock()
+				metrics.IncPoolConnectionsReused(p.name)
 				return pc, nil
 			}
 			// Unhealthy connection, close it
@@ -268,6 +292,7 @@ func (p *ConnectionPool) Get(ctx context.Context) (*PooledConnection, error) {
 		p.mu.Unlock()
 		return nil, err
 	}
+	metrics.IncPoolConnectionsCreated(p.name)
 
 	pc := &PooledConnection{
 		conn:     conn,
@@ -290,6 +315,7 @@ func (p *ConnectionPool) put(pc *PooledConnection) error {
 		return nil
 	}
 	p.mu.RUnlock()
+	defer p.recordGauges()
 
 	// Mark as not in use
 	pc.mu.Lock()
@@ -328,6 +354,7 @@ func (p *ConnectionPool) cleanupIdleConnections() {
 
 func (p *ConnectionPool) cleanup() {
 	now := time.Now()
+	defer p.recordGauges()
 
 	// Check connections in the pool
 	for {
@@ -387,10 +414,21 @@ func (p *ConnectionPool) Close() error {
 			pc.conn.Close()
 		}
 	}
+	metrics.SetPoolConnectionsActive(p.name, 0)
+	metrics.SetPoolConnectionsIdle(p.name, 0)
 
 	return nil
 }
 
+// recordGauges reports the pool's open and idle connections
+func (p *ConnectionPool) recordGauges() {
+	p.mu.RLock()
+	active := p.activeCount
+	p.mu.RUnlock()
+	metrics.SetPoolConnectionsActive(p.name, active)
+	metrics.SetPoolConnectionsIdle(p.name, len(p.connections))
+}
+
 // Stats returns pool statistics
 func (p *ConnectionPool) Stats() PoolStats {
 	p.mu.RLock()
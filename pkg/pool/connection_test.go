@@ -3,9 +3,14 @@ package pool
 import (
 	"context"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
 )
 
 // mockListener creates a test TCP listener
@@ -368,6 +373,62 @@ func TestConnectionPool_Stats(t *testing.T) {
 	}
 }
 
+func TestConnectionPool_Metrics(t *testing.T) {
+	addr, cleanup := setupTestListener(t)
+	defer cleanup()
+
+	pool := NewConnectionPool(PoolConfig{
+		Name:        "pool-metrics",
+		Address:     addr,
+		MaxSize:     1,
+		MaxIdleTime: 1 * time.Minute,
+	})
+	defer pool.Close()
+
+	ctx := context.Background()
+	conn1, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+
+	// The pool is at its maximum size until the connection is returned
+	ctx2, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx2); err != context.DeadlineExceeded {
+		t.Errorf("Expected timeout error when pool exhausted, got: %v", err)
+	}
+
+	conn1.Close()
+	assertMetrics(t,
+		`balance_pool_connections_active{backend="pool-metrics"} 1`,
+		`balance_pool_connections_idle{backend="pool-metrics"} 1`,
+		`balance_pool_connections_created_total{backend="pool-metrics"} 1`,
+		`balance_pool_exhausted_total{backend="pool-metrics"} 1`,
+	)
+
+	conn2, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn2.Close()
+	assertMetrics(t,
+		`balance_pool_connections_idle{backend="pool-metrics"} 0`,
+		`balance_pool_connections_reused_total{backend="pool-metrics"} 1`,
+	)
+}
+
+// assertMetrics checks that the exported metrics contain each of the lines
+func assertMetrics(t *testing.T, lines ...string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range lines {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Errorf("Expected %s in metrics", line)
+		}
+	}
+}
+
 func BenchmarkConnectionPool_GetPut(b *testing.B) {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
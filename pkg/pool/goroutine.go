@@ -54,7 +54,8 @@ type GoroutinePoolConfig struct {
 	// MaxIdleTime is how long idle workers wait before terminating
 	MaxIdleTime time.Duration
 
-	// QueueSize is the size of the task queue (0 for unbounded)
+	// QueueSize is how many tasks can wait for a busy worker (0 = none: tasks only go to
+	// new or idle workers)
 	QueueSize int
 
 	// NonBlocking determines if Submit should return immediately if queue is full
@@ -106,6 +107,11 @@ func (p *GoroutinePool) Submit(task Task) error {
 
 	atomic.AddUint64(&p.submitted, 1)
 
+	// Run the task on a new worker if the pool has room for one
+	if p.startWorker(task) {
+		return nil
+	}
+
 	// Try to send task to queue
 	if p.nonBlocking {
 		select {
@@ -138,6 +144,10 @@ func (p *GoroutinePool) SubmitWithTimeout(task Task, timeout time.Duration) erro
 
 	atomic.AddUint64(&p.submitted, 1)
 
+	if p.startWorker(task) {
+		return nil
+	}
+
 	timer := time.NewTimer(timeout)
 	defer timer.Stop()
 
@@ -163,6 +173,10 @@ func (p *GoroutinePool) SubmitWithContext(ctx context.Context, task Task) error
 
 	atomic.AddUint64(&p.submitted, 1)
 
+	if p.startWorker(task) {
+		return nil
+	}
+
 	select {
 	case p.taskQueue <- task:
 		p.ensureWorker()
@@ -182,18 +196,41 @@ func (p *GoroutinePool) ensureWorker() {
 	if currentWorkers < p.maxWorkers {
 		if atomic.CompareAndSwapInt32(&p.workers, currentWorkers, currentWorkers+1) {
 			p.wg.Add(1)
-			go p.worker()
+			go p.worker(nil)
 		}
 	}
 }
 
-// worker is the main worker goroutine
-func (p *GoroutinePool) worker() {
+// startWorker starts a new worker that runs task first, reporting false if the pool
+// already has its maximum number of workers
+// Tasks then only wait in the queue while every worker is busy, and an unbuffered queue
+// (QueueSize 0) hands tasks to idle workers directly.
+func (p *GoroutinePool) startWorker(task Task) bool {
+	for {
+		currentWorkers := atomic.LoadInt32(&p.workers)
+		if currentWorkers >= p.maxWorkers {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&p.workers, currentWorkers, currentWorkers+1) {
+			p.wg.Add(1)
+			go p.worker(task)
+			return true
+		}
+	}
+}
+
+// worker is the main worker goroutine, running first (if set) before taking tasks
+// from the queue
+func (p *GoroutinePool) worker(first Task) {
 	defer func() {
 		atomic.AddInt32(&p.workers, -1)
 		p.wg.Done()
 	}()
 
+	if first != nil {
+		p.executeTask(first)
+	}
+
 	timer := time.NewTimer(p.maxIdleTime)
 	defer timer.Stop()
 
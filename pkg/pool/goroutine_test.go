@@ -273,3 +273,38 @@ func BenchmarkRawGoroutine(b *testing.B) {
 		}()
 	}
 }
+
+func TestGoroutinePoolWithoutQueue(t *testing.T) {
+	pool := NewGoroutinePool(GoroutinePoolConfig{
+		MaxWorkers:  2,
+		MaxIdleTime: time.Second,
+		NonBlocking: true,
+	})
+	defer pool.Close()
+
+	// Tasks run on new workers up to the limit
+	release := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		if err := pool.Submit(func() { <-release }); err != nil {
+			t.Fatalf("Failed to submit task %d: %v", i, err)
+		}
+	}
+	if err := pool.Submit(func() {}); err != ErrGoroutinePoolTimeout {
+		t.Errorf("Expected ErrGoroutinePoolTimeout with every worker busy, got %v", err)
+	}
+
+	// Idle workers take tasks directly
+	close(release)
+	var counter int32
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&counter) == 0 && time.Now().Before(deadline) {
+		pool.Submit(func() { atomic.AddInt32(&counter, 1) })
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&counter) == 0 {
+		t.Error("Expected an idle worker to run the task")
+	}
+	if stats := pool.Stats(); stats.Workers > 2 {
+		t.Errorf("Expected at most 2 workers, got %d", stats.Workers)
+	}
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+// acmeChallengePrefix is the path of ACME HTTP-01 challenge responses (RFC 8555 section 8.3)
+const acmeChallengePrefix = "/.well-known/acme-challenge/"
+
+// acmeChallengeProxy forwards ACME HTTP-01 challenge requests to the backend that
+// answers them, so backends doing their own certificate issuance can be validated
+// through the proxy
+type acmeChallengeProxy struct {
+	pool      *backend.Pool
+	name      string
+	transport http.RoundTripper
+	logger    *logging.Logger
+}
+
+// newACMEChallengeProxy creates the challenge proxy if http.acme_challenge_backend is set
+func newACMEChallengeProxy(cfg *config.Config, pool *backend.Pool, logger *logging.Logger) *acmeChallengeProxy {
+	if cfg.HTTP == nil || cfg.HTTP.ACMEChallengeBackend == "" {
+		return nil
+	}
+	return &acmeChallengeProxy{
+		pool: pool,
+		name: cfg.HTTP.ACMEChallengeBackend,
+		transport: &http.Transport{
+			DialContext:     newDialer(cfg).DialContext,
+			MaxIdleConns:    1,
+			IdleConnTimeout: cfg.Timeouts.Idle,
+		},
+		logger: logger,
+	}
+}
+
+// isACMEChallenge reports whether a request fetches an ACME HTTP-01 challenge response
+func isACMEChallenge(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, acmeChallengePrefix)
+}
+
+// ServeHTTP forwards the request to the challenge backend over plain HTTP
+// The request is passed on untouched: the Host header is kept, the path is not
+// rewritten and no X-Forwarded headers are added.
+func (a *acmeChallengeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b := a.pool.Get(a.name)
+	if b == nil {
+		http.Error(w, "ACME challenge backend unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	b.IncrementConnections()
+	defer b.DecrementConnections()
+
+	target := &url.URL{Scheme: "http", Host: b.Address()}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.Host = pr.In.Host
+		},
+		Transport: a.transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			a.logger.Error("ACME challenge backend error", logging.String("backend", b.Address()), logging.Err(err))
+			http.Error(w, "Backend error", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// withACMEChallenges serves ACME challenge requests with acme and everything else with next
+func withACMEChallenges(acme *acmeChallengeProxy, next http.Handler) http.Handler {
+	if acme == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isACMEChallenge(r) {
+			acme.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
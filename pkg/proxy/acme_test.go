@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestACMEChallengePassthrough(t *testing.T) {
+	acmeBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Forwarded-For") != "" {
+			t.Error("Expected the challenge request without X-Forwarded headers")
+		}
+		io.WriteString(w, "acme:"+r.Host+r.URL.Path)
+	}))
+	defer acmeBackend.Close()
+	appBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "app")
+	}))
+	defer appBackend.Close()
+
+	certFile, keyFile := writeTestCertificate(t, "example.com")
+	cfg := &config.Config{
+		Mode:   "http",
+		Listen: "127.0.0.1:0",
+		Backends: []config.Backend{
+			{Name: "app", Address: appBackend.Listener.Addr().String(), Weight: 1},
+			{Name: "acme", Address: acmeBackend.Listener.Addr().String(), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP: &config.HTTPConfig{
+			Routes: []config.Route{
+				{Name: "all", PathPrefix: "/", Backends: []string{"app"}},
+			},
+			ACMEChallengeBackend: "acme",
+		},
+		TLS: &config.TLSConfig{
+			Enabled:      true,
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			RedirectHTTP: &config.HTTPRedirectConfig{Enabled: true, Listen: "127.0.0.1:0"},
+		},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+
+	// get fetches a path from handler with the example.com Host header
+	get := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = "example.com"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("routes are bypassed", func(t *testing.T) {
+		h := server.httpServer
+		if body := get(http.HandlerFunc(h.handleRequest), "/.well-known/acme-challenge/token").Body.String(); body != "acme:example.com/.well-known/acme-challenge/token" {
+			t.Errorf("Expected the challenge backend, got %q", body)
+		}
+		if body := get(http.HandlerFunc(h.handleRequest), "/index.html").Body.String(); body != "app" {
+			t.Errorf("Expected the routed backend, got %q", body)
+		}
+	})
+
+	t.Run("redirect listener passes challenges through", func(t *testing.T) {
+		handler := server.redirect.server.Handler
+		rec := get(handler, "/.well-known/acme-challenge/token")
+		if rec.Code != http.StatusOK || rec.Body.String() != "acme:example.com/.well-known/acme-challenge/token" {
+			t.Errorf("Expected the challenge to be proxied, got %d %q", rec.Code, rec.Body.String())
+		}
+		if rec := get(handler, "/index.html"); rec.Code != http.StatusMovedPermanently {
+			t.Errorf("Expected other requests to be redirected, got %d", rec.Code)
+		}
+	})
+}
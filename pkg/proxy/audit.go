@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	balancetls "github.com/therealutkarshpriyadarshi/balance/pkg/tls"
+)
+
+// auditRequest records a rejected HTTP request in the audit log
+// The actor is the peer the request arrived from, which the client cannot forge; the
+// X-Forwarded-For chain is recorded as it was sent.
+func (h *HTTPServer) auditRequest(r *http.Request, eventType logging.AuditEventType, reason string, details map[string]string) {
+	if h.audit == nil {
+		return
+	}
+	h.audit.Log(logging.AuditEvent{
+		Type:         eventType,
+		Actor:        peerIP(r),
+		ForwardedFor: strings.Join(r.Header.Values("X-Forwarded-For"), ", "),
+		Action:       r.Method + " " + r.URL.RequestURI(),
+		Outcome:      "rejected",
+		Reason:       reason,
+		Details:      details,
+	})
+}
+
+// auditConnection records a rejected connection in the audit log
+func auditConnection(audit *logging.AuditLogger, eventType logging.AuditEventType, clientIP, reason string, details map[string]string) {
+	audit.Log(logging.AuditEvent{
+		Type:    eventType,
+		Actor:   clientIP,
+		Action:  "connect",
+		Outcome: "rejected",
+		Reason:  reason,
+		Details: details,
+	})
+}
+
+// auditHandshakeFailure records a TLS handshake that failed because the client
+// certificate did not verify or was revoked; other handshake failures are not audited
+func auditHandshakeFailure(audit *logging.AuditLogger, clientIP string, err error) {
+	var verifyErr *tls.CertificateVerificationError
+	if !errors.As(err, &verifyErr) && !errors.Is(err, balancetls.ErrCertificateRevoked) {
+		return
+	}
+	auditConnection(audit, logging.AuditAuthFailure, clientIP, err.Error(), nil)
+}
@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	balancetls "github.com/therealutkarshpriyadarshi/balance/pkg/tls"
+)
+
+// auditEvents decodes the JSON lines written to an audit log
+func auditEvents(t *testing.T, out *bytes.Buffer) []logging.AuditEvent {
+	t.Helper()
+	var events []logging.AuditEvent
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var event logging.AuditEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Failed to decode audit event %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestHTTPAuditLog(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Security: &config.SecurityConfig{
+			RateLimit: &config.RateLimitConfig{Enabled: true, Type: "token-bucket", RequestsPerSecond: 0.001, BurstSize: 1},
+			URLFilter: &config.URLFilterConfig{Enabled: true, DenyPatterns: []string{`^/internal/`}},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+	var out bytes.Buffer
+	h.audit = logging.NewAuditLogger(&out)
+
+	// The first request is served, the second rate limited, the third denied by the
+	// URL filter and the fourth rejected once the client is blocked
+	for i, target := range []string{"/", "/", "/internal/admin", "/"} {
+		if i == 3 {
+			h.security.BlockIP("192.0.2.1", time.Hour)
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Forwarded-For", "198.51.100.9")
+		h.handleRequest(httptest.NewRecorder(), req)
+	}
+
+	events := auditEvents(t, &out)
+	want := []logging.AuditEventType{logging.AuditRateLimited, logging.AuditWAF, logging.AuditBlocked}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d audit events, got %d: %q", len(want), len(events), out.String())
+	}
+	for i, event := range events {
+		if event.Type != want[i] || event.Actor != "192.0.2.1" || event.ForwardedFor != "198.51.100.9" || event.Outcome != "rejected" {
+			t.Errorf("Unexpected audit event %d: %+v", i, event)
+		}
+	}
+	if events[0].Action != "GET /" || events[0].Details["key"] != "192.0.2.1" {
+		t.Errorf("Unexpected rate limit audit event: %+v", events[0])
+	}
+	if events[1].Action != "GET /internal/admin" {
+		t.Errorf("Unexpected URL filter audit event: %+v", events[1])
+	}
+}
+
+func TestAuditHandshakeFailure(t *testing.T) {
+	var out bytes.Buffer
+	audit := logging.NewAuditLogger(&out)
+
+	auditHandshakeFailure(audit, "192.0.2.1", &tls.CertificateVerificationError{Err: errors.New("unknown authority")})
+	auditHandshakeFailure(audit, "192.0.2.2", fmt.Errorf("client certificate test: %w", balancetls.ErrCertificateRevoked))
+	auditHandshakeFailure(audit, "192.0.2.3", errors.New("tls: first record does not look like a TLS handshake"))
+
+	// Only failed client authentication is audited
+	events := auditEvents(t, &out)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 audit events, got %d: %q", len(events), out.String())
+	}
+	for i, event := range events {
+		if event.Type != logging.AuditAuthFailure || event.Actor != fmt.Sprintf("192.0.2.%d", i+1) {
+			t.Errorf("Unexpected audit event %d: %+v", i, event)
+		}
+	}
+
+	// A nil audit log discards events
+	auditHandshakeFailure(nil, "192.0.2.1", &tls.CertificateVerificationError{})
+}
+
+func TestAuditLogTCPJSON(t *testing.T) {
+	collector, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer collector.Close()
+
+	audit, err := newAuditLog(&config.Config{Logging: &config.LoggingConfig{
+		AuditLog: &config.AuditLogConfig{Enabled: true, Output: "tcp-json://" + collector.Addr().String()},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	audit.Log(logging.AuditEvent{Type: logging.AuditBlocked, Actor: "192.0.2.1", Outcome: "rejected"})
+	audit.Log(logging.AuditEvent{Type: logging.AuditWAF, Actor: "192.0.2.2", Outcome: "rejected"})
+	audit.Close()
+
+	conn, err := collector.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Failed to read audit events: %v", err)
+	}
+
+	// Audit events are already JSON and are shipped unwrapped, one per line
+	events := auditEvents(t, bytes.NewBuffer(received))
+	if len(events) != 2 || events[0].Actor != "192.0.2.1" || events[1].Type != logging.AuditWAF {
+		t.Errorf("Unexpected audit events: %q", received)
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+// captureRequest starts recording a request that matches the active debug capture
+// It returns the writer and request to serve the request with, and a function to call
+// once it has been served; requests that are not captured are returned unchanged.
+func (h *HTTPServer) captureRequest(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, func()) {
+	cr := h.capture.Begin(r, getClientIP(r))
+	if cr == nil {
+		return w, r, func() {}
+	}
+
+	start := time.Now()
+	cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+	return cw, r.WithContext(logging.NewCaptureContext(r.Context(), cr)), func() {
+		cr.Finish(cw.status, w.Header(), time.Since(start))
+	}
+}
+
+// captureAttempt records an attempt to serve a captured request from a backend
+func captureAttempt(r *http.Request, attempt logging.CaptureAttempt) {
+	if cr := logging.CaptureFromContext(r.Context()); cr != nil {
+		cr.AddAttempt(attempt)
+	}
+}
+
+// captureWriter records the status of the response to a captured request
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	cw.status = code
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher for streaming responses
+func (cw *captureWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for WebSocket upgrades
+func (cw *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		cw.status = http.StatusSwitchingProtocols
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("ResponseWriter does not implement http.Hijacker")
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
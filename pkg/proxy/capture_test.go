@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+func TestRequestCapture(t *testing.T) {
+	h := newRetryTestServer(t, &config.RetryConfig{
+		Enabled:      true,
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1,
+	}, nil)
+
+	if err := h.capture.Start(logging.CaptureFilter{PathPrefix: "/api"}, 1); err != nil {
+		t.Fatalf("Failed to start capture: %v", err)
+	}
+
+	// Only the first request matching the filter is captured
+	for _, path := range []string{"/api/users", "/other", "/api/orders"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d", path, rec.Code)
+		}
+	}
+
+	status := h.capture.Status()
+	if status.Active || len(status.Requests) != 1 {
+		t.Fatalf("Expected a finished capture of 1 request, got %+v", status)
+	}
+	cr := status.Requests[0]
+	if cr.URL != "/api/users" || cr.Status != http.StatusOK {
+		t.Errorf("Expected /api/users with status 200, got %s with %d", cr.URL, cr.Status)
+	}
+	if got := cr.RequestHeaders.Get("Authorization"); got != "[redacted]" {
+		t.Errorf("Expected Authorization to be redacted, got %q", got)
+	}
+
+	// The first attempt fails on the dead backend and is retried on the healthy one
+	if len(cr.Attempts) != 2 || len(cr.Retries) != 1 {
+		t.Fatalf("Expected 2 attempts and 1 retry, got %+v and %+v", cr.Attempts, cr.Retries)
+	}
+	if first := cr.Attempts[0]; first.Backend != "dead" || first.Error == "" {
+		t.Errorf("Expected failed attempt on dead backend, got %+v", first)
+	}
+	if second := cr.Attempts[1]; second.Backend != "healthy" || second.Status != http.StatusOK || second.Upstream == nil {
+		t.Errorf("Expected timed attempt on healthy backend, got %+v", second)
+	}
+}
+
+func TestRequestCaptureRedactsAPIKey(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Security: &config.SecurityConfig{
+			RateLimit: &config.RateLimitConfig{
+				Enabled:           true,
+				Type:              "token-bucket",
+				Key:               "api-key:X-API-Key",
+				RequestsPerSecond: 100,
+				BurstSize:         100,
+			},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	defer server.Shutdown()
+	h := server.httpServer
+
+	if err := h.capture.Start(logging.CaptureFilter{}, 1); err != nil {
+		t.Fatalf("Failed to start capture: %v", err)
+	}
+
+	// The header rate limits are keyed on carries a secret, so it is hidden like
+	// Authorization
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("X-Request-ID", "abc")
+	h.handleRequest(httptest.NewRecorder(), req)
+
+	status := h.capture.Status()
+	if len(status.Requests) != 1 {
+		t.Fatalf("Expected 1 captured request, got %d", len(status.Requests))
+	}
+	headers := status.Requests[0].RequestHeaders
+	if got := headers.Get("X-API-Key"); got != "[redacted]" {
+		t.Errorf("Expected the API key header to be redacted, got %q", got)
+	}
+	if got := headers.Get("X-Request-ID"); got != "abc" {
+		t.Errorf("Expected other headers to be kept, got %q", got)
+	}
+}
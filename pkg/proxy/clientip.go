@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+// trustedProxies are the peers whose forwarding headers identify the client
+type trustedProxies []*net.IPNet
+
+// newTrustedProxies parses security.trusted_proxies
+func newTrustedProxies(cfg *config.Config) (trustedProxies, error) {
+	if cfg.Security == nil {
+		return nil, nil
+	}
+
+	var proxies trustedProxies
+	for _, entry := range cfg.Security.TrustedProxies {
+		if ip := net.ParseIP(entry); ip != nil {
+			mask := net.CIDRMask(128, 128)
+			if v4 := ip.To4(); v4 != nil {
+				ip, mask = v4, net.CIDRMask(32, 32)
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: mask})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %s: %w", entry, err)
+		}
+		proxies = append(proxies, ipNet)
+	}
+	return proxies, nil
+}
+
+// contains reports whether ip is a trusted proxy
+func (t trustedProxies) contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range t {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIP returns the IP of the connection a request arrived on
+func peerIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// trustedClientIP returns the client IP that security decisions such as bans, blocks and
+// rate limits are keyed on
+// It is the connection's peer address unless the peer is a trusted proxy, in which case
+// it is the last X-Forwarded-For address not added by a trusted proxy, or X-Real-IP.
+// Clients cannot choose it by sending those headers themselves.
+func (h *HTTPServer) trustedClientIP(r *http.Request) string {
+	ip := peerIP(r)
+	if !h.trustedProxies.contains(ip) {
+		return ip
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			ip = hop
+			if !h.trustedProxies.contains(hop) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		return xri
+	}
+	return ip
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestTrustedClientIP(t *testing.T) {
+	proxies, err := newTrustedProxies(&config.Config{Security: &config.SecurityConfig{
+		TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+	h := &HTTPServer{trustedProxies: proxies}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		xRealIP    string
+		expected   string
+	}{
+		{"direct client", "203.0.113.7:4000", nil, "", "203.0.113.7"},
+		{"spoofed X-Forwarded-For", "203.0.113.7:4000", []string{"198.51.100.9"}, "", "203.0.113.7"},
+		{"spoofed X-Real-IP", "203.0.113.7:4000", nil, "198.51.100.9", "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:4000", []string{"198.51.100.9"}, "", "198.51.100.9"},
+		{"client prepends a hop", "10.1.2.3:4000", []string{"198.51.100.9, 203.0.113.7"}, "", "203.0.113.7"},
+		{"chain of trusted proxies", "10.1.2.3:4000", []string{"203.0.113.7, 192.0.2.1", "10.9.9.9"}, "", "203.0.113.7"},
+		{"trusted proxy with X-Real-IP", "192.0.2.1:4000", nil, "198.51.100.9", "198.51.100.9"},
+		{"trusted proxy without headers", "10.1.2.3:4000", nil, "", "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xff {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.xRealIP != "" {
+				r.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			if ip := h.trustedClientIP(r); ip != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, ip)
+			}
+		})
+	}
+}
@@ -0,0 +1,630 @@
+package proxy
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/discovery"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/health"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/lb"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/pool"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/router"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/security"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/tracing"
+)
+
+// newBackendPool creates the backend pool from the configured backends
+func newBackendPool(cfg *config.Config, logger *logging.Logger) (*backend.Pool, error) {
+	pool := backend.NewPool()
+	pool.SetFailoverThreshold(cfg.LoadBalancer.FailoverThreshold)
+
+	for _, b := range configuredBackends(cfg, logger) {
+		if err := pool.Add(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return pool, nil
+}
+
+// configuredBackends creates the configured backends
+// If subsetting is configured, only this instance's subset is returned
+func configuredBackends(cfg *config.Config, logger *logging.Logger) []*backend.Backend {
+	backends := make([]*backend.Backend, 0, len(cfg.Backends))
+	for _, backendCfg := range cfg.Backends {
+		b := backend.NewBackend(backendCfg.Name, backendCfg.Address, backendCfg.Weight)
+		b.SetPriority(backendCfg.Priority)
+		b.SetBackup(backendCfg.Backup)
+		b.SetZone(backendCfg.Zone)
+		b.SetLabels(backendCfg.Labels)
+		b.SetMaxConnections(backendCfg.MaxConnections)
+		backends = append(backends, b)
+	}
+
+	if subset := cfg.LoadBalancer.Subset; subset != nil && subset.Size > 0 {
+		instanceID := subsetInstanceID(subset)
+		backends = lb.Subset(backends, instanceID, subset.Size)
+		logger.Info("Using a subset of the backends", logging.Int("subset", len(backends)),
+			logging.Int("backends", len(cfg.Backends)), logging.String("instance", instanceID))
+	}
+	return backends
+}
+
+// syncPoolMetrics keeps the backend health gauge up to date and drops the metrics of
+// backends that leave the pool
+func syncPoolMetrics(pool *backend.Pool) {
+	for _, b := range pool.All() {
+		metrics.SetBackendHealthStatus(b.Name(), b.IsHealthy())
+	}
+	pool.Subscribe(func(event backend.PoolEvent) {
+		switch event.Type {
+		case backend.BackendAdded, backend.BackendHealthChanged:
+			metrics.SetBackendHealthStatus(event.Backend.Name(), event.Backend.IsHealthy())
+		case backend.BackendRemoved:
+			metrics.DeleteBackend(event.Backend.Name())
+		}
+	})
+}
+
+// subsetInstanceID returns the configured instance ID, falling back to the hostname
+func subsetInstanceID(subset *config.SubsetConfig) string {
+	if subset.InstanceID != "" {
+		return subset.InstanceID
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "balance"
+	}
+	return hostname
+}
+
+// newLoadBalancer creates the configured load balancer for the pool
+// When the proxy has a zone, same-zone backends are preferred (see lb.ZoneAware)
+func newLoadBalancer(cfg *config.Config, pool *backend.Pool) (lb.LoadBalancer, error) {
+	if cfg.Zone == "" {
+		return newAlgorithm(cfg, pool)
+	}
+
+	local := backend.NewPool()
+	remote := backend.NewPool()
+	local.SetFailoverThreshold(cfg.LoadBalancer.FailoverThreshold)
+	remote.SetFailoverThreshold(cfg.LoadBalancer.FailoverThreshold)
+
+	// Both pools share the backends, so health and connection counts stay in sync
+	for _, b := range pool.All() {
+		if b.Zone() == cfg.Zone {
+			local.Add(b)
+		} else {
+			remote.Add(b)
+		}
+	}
+
+	localBalancer, err := newAlgorithm(cfg, local)
+	if err != nil {
+		return nil, err
+	}
+	remoteBalancer, err := newAlgorithm(cfg, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	return lb.NewZoneAware(localBalancer, remoteBalancer, cfg.LoadBalancer.ZoneSpillover), nil
+}
+
+// newAlgorithm creates the load balancing algorithm named in the configuration
+func newAlgorithm(cfg *config.Config, pool *backend.Pool) (lb.LoadBalancer, error) {
+	return lb.New(cfg.LoadBalancer.Algorithm, pool, lb.Options{
+		HashKey:      cfg.LoadBalancer.HashKey,
+		VirtualNodes: cfg.LoadBalancer.VirtualNodes,
+		LoadFactor:   cfg.LoadBalancer.LoadFactor,
+		Params:       cfg.LoadBalancer.Params,
+	})
+}
+
+// newHealthChecker creates a health checker for the pool (nil if health checking is disabled)
+func newHealthChecker(cfg *config.Config, pool *backend.Pool, logger *logging.Logger) *health.Checker {
+	hc := cfg.HealthCheck
+	if hc == nil || !hc.Enabled {
+		return nil
+	}
+
+	checkerCfg := health.CheckerConfig{
+		Interval:           hc.Interval,
+		UnhealthyInterval:  hc.UnhealthyInterval,
+		Timeout:            hc.Timeout,
+		HealthyThreshold:   hc.HealthyThreshold,
+		UnhealthyThreshold: hc.UnhealthyThreshold,
+		ActiveCheckType:    health.CheckType(hc.Type),
+		HTTPPath:           hc.Path,
+		Logger:             logger.With(logging.String("component", "health")),
+	}
+
+	if hc.TLS != nil {
+		checkerCfg.TLS = health.TLSOptions{
+			CAFile:             hc.TLS.CAFile,
+			ServerName:         hc.TLS.ServerName,
+			ClientCertFile:     hc.TLS.ClientCertFile,
+			ClientKeyFile:      hc.TLS.ClientKeyFile,
+			InsecureSkipVerify: hc.TLS.InsecureSkipVerify,
+		}
+	}
+
+	if hc.PassiveChecks != nil && hc.PassiveChecks.Enabled {
+		checkerCfg.EnablePassiveChecks = true
+		checkerCfg.ErrorRateThreshold = hc.PassiveChecks.ErrorRateThreshold
+		checkerCfg.ConsecutiveFailures = hc.PassiveChecks.ConsecutiveFailures
+		checkerCfg.PassiveCheckWindow = hc.PassiveChecks.Window
+	}
+
+	return health.NewChecker(pool, checkerCfg)
+}
+
+// newDiscovery creates the discovery manager for the configured providers (nil if none)
+// The health checker, metrics and circuit breakers follow the pool, so discovered
+// backends need no extra wiring.
+func newDiscovery(cfg *config.Config, pool *backend.Pool, checker *health.Checker) (*discovery.Manager, error) {
+	if !cfg.DiscoveryEnabled() {
+		return nil, nil
+	}
+
+	var providers []discovery.Provider
+	if cfg.BackendsFile != "" {
+		provider, err := discovery.NewFileProvider(cfg.BackendsFile)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	discoveryCfg := cfg.Discovery
+	if discoveryCfg == nil {
+		discoveryCfg = &config.DiscoveryConfig{}
+	}
+	if srv := discoveryCfg.SRV; srv != nil {
+		provider, err := discovery.NewSRVProvider(discovery.SRVConfig{
+			Name:       srv.Name,
+			Server:     srv.Server,
+			MinRefresh: srv.MinRefreshInterval,
+			MaxRefresh: srv.MaxRefreshInterval,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SRV discovery: %w", err)
+		}
+		providers = append(providers, provider)
+	}
+	if etcd := discoveryCfg.Etcd; etcd != nil {
+		provider, err := discovery.NewEtcdProvider(discovery.EtcdConfig{
+			Endpoints: etcd.Endpoints,
+			Prefix:    etcd.Prefix,
+			Username:  etcd.Username,
+			Password:  etcd.Password,
+			TLS: discovery.TLSConfig{
+				CAFile:   etcd.CAFile,
+				CertFile: etcd.CertFile,
+				KeyFile:  etcd.KeyFile,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create etcd discovery: %w", err)
+		}
+		providers = append(providers, provider)
+	}
+	if eureka := discoveryCfg.Eureka; eureka != nil {
+		provider, err := discovery.NewEurekaProvider(discovery.EurekaConfig{
+			ServiceURLs:     eureka.ServiceURLs,
+			Applications:    eureka.Applications,
+			RefreshInterval: eureka.RefreshInterval,
+			TLS:             discovery.TLSConfig{CAFile: eureka.CAFile},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Eureka discovery: %w", err)
+		}
+		providers = append(providers, provider)
+	}
+
+	manager := discovery.NewManager(pool, providers...)
+	if prune := discoveryCfg.Prune; prune != nil && checker != nil {
+		manager.SetPrunePolicy(discovery.PrunePolicy{
+			After:      prune.After,
+			Quarantine: prune.Quarantine,
+			UnhealthySince: func(name string) (time.Time, bool) {
+				sm, err := checker.GetStateMachine(name)
+				if err != nil || sm.GetState() != backend.StateUnhealthy {
+					return time.Time{}, false
+				}
+				return sm.GetLastStateChangeTime(), true
+			},
+		})
+	}
+	return manager, nil
+}
+
+// newAdaptiveWeights creates the adaptive weight controller (nil if disabled or health checking is off)
+func newAdaptiveWeights(cfg *config.Config, checker *health.Checker, pool *backend.Pool, logger *logging.Logger) *health.AdaptiveWeights {
+	aw := cfg.LoadBalancer.AdaptiveWeights
+	if aw == nil || !aw.Enabled || checker == nil {
+		return nil
+	}
+
+	return health.NewAdaptiveWeights(checker, pool, health.AdaptiveWeightsConfig{
+		Interval:        aw.Interval,
+		MinWeightFactor: aw.MinWeightFactor,
+		Logger:          logger.With(logging.String("component", "health")),
+	})
+}
+
+// newSecurityManager creates a security manager (nil if security is not configured)
+func newSecurityManager(cfg *config.Config, logger *logging.Logger) (*security.SecurityManager, error) {
+	sc := cfg.Security
+	if sc == nil {
+		return nil, nil
+	}
+
+	protection := security.DefaultProtectionConfig()
+	protection.Logger = logger.With(logging.String("component", "security"))
+	if cp := sc.ConnectionProtection; cp != nil {
+		if cp.MaxConnectionsPerIP > 0 {
+			protection.MaxConnectionsPerIP = cp.MaxConnectionsPerIP
+		}
+		if cp.MaxConnectionRate > 0 {
+			protection.MaxConnectionRate = cp.MaxConnectionRate
+		}
+		protection.MaxConcurrentRequestsPerIP = cp.MaxConcurrentRequestsPerIP
+		if cp.ReadTimeout != "" {
+			readTimeout, err := time.ParseDuration(cp.ReadTimeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid connection_protection read_timeout: %w", err)
+			}
+			protection.ReadTimeout = readTimeout
+		}
+		if cp.MaxRequestSize > 0 {
+			protection.MaxRequestSize = cp.MaxRequestSize
+		}
+		if cp.MaxHeaderSize > 0 {
+			protection.MaxHeaderSize = cp.MaxHeaderSize
+		}
+	}
+
+	var rateLimiter security.RateLimiter
+	tiers := make(map[string]security.RateLimiter)
+	if rl := sc.RateLimit; rl != nil && rl.Enabled {
+		var err error
+		rateLimiter, err = newRateLimiter("requests", rl.Type, &config.RateLimitTierConfig{
+			RequestsPerSecond: rl.RequestsPerSecond,
+			BurstSize:         rl.BurstSize,
+			WindowSize:        rl.WindowSize,
+			MaxRequests:       rl.MaxRequests,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for name, tier := range rl.Tiers {
+			if tiers[name], err = newRateLimiter("tier:"+name, rl.Type, tier); err != nil {
+				return nil, fmt.Errorf("rate_limit tier %s: %w", name, err)
+			}
+		}
+	}
+
+	manager := security.NewSecurityManager(protection, rateLimiter)
+	for name, limiter := range tiers {
+		manager.SetRateLimitTier(name, limiter)
+	}
+
+	if bl := sc.IPBlocklist; bl != nil {
+		for _, ip := range bl.BlockedIPs {
+			manager.Blocklist().BlockPermanent(ip)
+		}
+		for _, cidr := range bl.BlockedCIDRs {
+			if err := manager.Blocklist().BlockCIDR(cidr); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if ab := sc.AutoBan; ab != nil && ab.Enabled {
+		watcher, err := security.NewBanWatcher(security.BanWatcherConfig{
+			Events:     ab.Events,
+			Threshold:  ab.Threshold,
+			FindTime:   ab.FindTime,
+			BanTime:    ab.BanTime,
+			MaxBanTime: ab.MaxBanTime,
+			Allowlist:  ab.Allowlist,
+		}, manager.Blocklist())
+		if err != nil {
+			return nil, fmt.Errorf("auto_ban: %w", err)
+		}
+		manager.SetBanWatcher(watcher)
+	}
+
+	return manager, nil
+}
+
+// newURLFilter creates the request URL filter (nil if URL filtering is disabled)
+func newURLFilter(cfg *config.Config) (*security.URLFilter, error) {
+	if cfg.Security == nil || cfg.Security.URLFilter == nil || !cfg.Security.URLFilter.Enabled {
+		return nil, nil
+	}
+	return security.NewURLFilter(cfg.Security.URLFilter.DenyPatterns)
+}
+
+// newAccessLogger opens the HTTP access log (nil if access logging is disabled)
+func newAccessLogger(cfg *config.Config) (*logging.AccessLogger, error) {
+	if cfg.Logging == nil || !cfg.Logging.AccessLog {
+		return nil, nil
+	}
+
+	var sampler *logging.AccessLogSampler
+	if s := cfg.Logging.AccessLogSampling; s != nil {
+		var err error
+		if sampler, err = logging.NewAccessLogSampler(s.Rates, s.SlowThreshold); err != nil {
+			return nil, fmt.Errorf("access_log_sampling: %w", err)
+		}
+	}
+	accessLogger, err := logging.OpenAccessLog(cfg.Logging.AccessLogOutput)
+	if err != nil {
+		return nil, err
+	}
+	accessLogger.SetSampler(sampler)
+	return accessLogger, nil
+}
+
+// newAuditLog opens the security event audit log (nil if audit logging is disabled)
+func newAuditLog(cfg *config.Config) (*logging.AuditLogger, error) {
+	if cfg.Logging == nil || cfg.Logging.AuditLog == nil || !cfg.Logging.AuditLog.Enabled {
+		return nil, nil
+	}
+	return logging.OpenAuditLog(cfg.Logging.AuditLog.Output)
+}
+
+// newTracer creates the tracer (nil if tracing is disabled)
+// Routes with a trace_sample_rate override tracing.sample_rate for their requests.
+func newTracer(cfg *config.Config, rt *router.Router) (*tracing.Tracer, error) {
+	if cfg.Tracing == nil || !cfg.Tracing.Enabled {
+		return nil, nil
+	}
+
+	tc := tracing.Config{
+		Enabled:     true,
+		ServiceName: cfg.Tracing.ServiceName,
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRate:  cfg.Tracing.SampleRate,
+		Attributes:  cfg.Tracing.SpanAttributes,
+	}
+	if rt != nil && slices.ContainsFunc(rt.Routes(), func(route *router.RouteEntry) bool {
+		return route.Config().TraceSampleRate != nil
+	}) {
+		tc.SampleRateFunc = func(r *http.Request) (float64, bool) {
+			route := rt.MatchRoute(r)
+			if route == nil || route.Config().TraceSampleRate == nil {
+				return 0, false
+			}
+			return *route.Config().TraceSampleRate, true
+		}
+	}
+
+	tracer, err := tracing.NewTracer(tc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracer: %w", err)
+	}
+	return tracer, nil
+}
+
+// newRateLimiter creates a rate limiter of the given type with the given limits, whose
+// metrics are labeled with name
+func newRateLimiter(name, limiterType string, limits *config.RateLimitTierConfig) (security.RateLimiter, error) {
+	switch limiterType {
+	case "token-bucket":
+		limiter := security.NewTokenBucket(limits.RequestsPerSecond, limits.BurstSize)
+		limiter.SetName(name)
+		return limiter, nil
+	case "sliding-window":
+		window, err := time.ParseDuration(limits.WindowSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate_limit window_size: %w", err)
+		}
+		limiter := security.NewSlidingWindow(limits.MaxRequests, window)
+		limiter.SetName(name)
+		return limiter, nil
+	default:
+		return nil, fmt.Errorf("unsupported rate limit type: %s", limiterType)
+	}
+}
+
+// newRetryBudget creates the retry budget shared by all requests (nil if retries are disabled)
+func newRetryBudget(cfg *config.Config) *resilience.RetryBudget {
+	if !cfg.RetriesEnabled() || cfg.Resilience.RetryBudget == nil {
+		return nil
+	}
+	budget := cfg.Resilience.RetryBudget
+	return resilience.NewRetryBudget(budget.Window, budget.MinRetriesPerSecond, budget.Percent/100)
+}
+
+// newLoadShedder creates the load shedder (nil if load shedding is disabled)
+func newLoadShedder(cfg *config.Config) *resilience.LoadShedder {
+	if cfg.Resilience == nil || cfg.Resilience.LoadShedding == nil || !cfg.Resilience.LoadShedding.Enabled {
+		return nil
+	}
+	ls := cfg.Resilience.LoadShedding
+	return resilience.NewLoadShedder(resilience.LoadShedderConfig{
+		Interval:    ls.Interval,
+		MaxLag:      ls.MaxLag,
+		MaxCPU:      ls.MaxCPU,
+		Step:        ls.Step,
+		MaxFraction: ls.MaxFraction,
+	})
+}
+
+// circuitBreakers holds a circuit breaker per backend, following backends as they
+// join and leave the pool
+type circuitBreakers struct {
+	mu       sync.RWMutex
+	breakers map[string]*resilience.CircuitBreaker
+
+	// newBreaker creates the breaker of a backend from its name
+	newBreaker func(name string) *resilience.CircuitBreaker
+}
+
+// newCircuitBreakers creates a circuit breaker per backend (nil if circuit breaking is disabled)
+func newCircuitBreakers(cfg *config.Config, pool *backend.Pool) *circuitBreakers {
+	if cfg.Resilience == nil || cfg.Resilience.CircuitBreaker == nil || !cfg.Resilience.CircuitBreaker.Enabled {
+		return nil
+	}
+
+	cbCfg := cfg.Resilience.CircuitBreaker
+
+	// Per-backend overrides by name
+	overrides := make(map[string]*config.BackendCircuitBreakerConfig)
+	for _, b := range cfg.Backends {
+		if b.CircuitBreaker != nil {
+			overrides[b.Name] = b.CircuitBreaker
+		}
+	}
+
+	cb := &circuitBreakers{
+		breakers: make(map[string]*resilience.CircuitBreaker),
+		newBreaker: func(name string) *resilience.CircuitBreaker {
+			breakerCfg := resilience.CircuitBreakerConfig{
+				Name:                  name,
+				MaxFailures:           uint32(cbCfg.MaxFailures),
+				Timeout:               cbCfg.Timeout,
+				MaxConcurrentRequests: uint32(cbCfg.MaxConcurrentRequests),
+			}
+
+			if override, ok := overrides[name]; ok {
+				if override.MaxFailures > 0 {
+					breakerCfg.MaxFailures = uint32(override.MaxFailures)
+				}
+				if override.Timeout > 0 {
+					breakerCfg.Timeout = override.Timeout
+				}
+				if override.MaxConcurrentRequests > 0 {
+					breakerCfg.MaxConcurrentRequests = uint32(override.MaxConcurrentRequests)
+				}
+			}
+
+			return resilience.NewCircuitBreaker(breakerCfg)
+		},
+	}
+	for _, b := range pool.All() {
+		cb.breakers[b.Name()] = cb.newBreaker(b.Name())
+	}
+	pool.Subscribe(cb.onPoolEvent)
+
+	return cb
+}
+
+// get returns the circuit breaker of a backend (nil if it has none)
+func (c *circuitBreakers) get(name string) *resilience.CircuitBreaker {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.breakers[name]
+}
+
+// all returns a copy of the circuit breakers by backend name
+func (c *circuitBreakers) all() map[string]*resilience.CircuitBreaker {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return maps.Clone(c.breakers)
+}
+
+// onPoolEvent gives backends joining the pool a fresh circuit breaker and drops the
+// breaker of backends leaving it
+func (c *circuitBreakers) onPoolEvent(event backend.PoolEvent) {
+	name := event.Backend.Name()
+	switch event.Type {
+	case backend.BackendAdded:
+		breaker := c.newBreaker(name)
+		c.mu.Lock()
+		c.breakers[name] = breaker
+		c.mu.Unlock()
+	case backend.BackendRemoved:
+		c.mu.Lock()
+		delete(c.breakers, name)
+		c.mu.Unlock()
+	}
+}
+
+// defaultCopyBufferSize is the copy buffer size used when none is configured
+const defaultCopyBufferSize = 32 * 1024
+
+// newCopyBufferPool returns the buffer pool used by the stream copy loops
+func newCopyBufferPool(cfg *config.Config) *pool.BufferPool {
+	size := defaultCopyBufferSize
+	if cfg.Buffers != nil && cfg.Buffers.CopyBufferSize > 0 {
+		size = cfg.Buffers.CopyBufferSize
+	}
+
+	// Share the global pools for the common sizes
+	switch size {
+	case pool.MediumBufferPool.Size():
+		return pool.MediumBufferPool
+	case pool.LargeBufferPool.Size():
+		return pool.LargeBufferPool
+	default:
+		return pool.NewBufferPool(size)
+	}
+}
+
+// defaultTransportBufferSize is the HTTP transport buffer size used when none is configured
+const defaultTransportBufferSize = 4096
+
+// transportBufferSizes returns the HTTP transport read and write buffer sizes
+func transportBufferSizes(cfg *config.Config) (readSize, writeSize int) {
+	readSize, writeSize = defaultTransportBufferSize, defaultTransportBufferSize
+	if cfg.Buffers != nil {
+		if cfg.Buffers.TransportReadBufferSize > 0 {
+			readSize = cfg.Buffers.TransportReadBufferSize
+		}
+		if cfg.Buffers.TransportWriteBufferSize > 0 {
+			writeSize = cfg.Buffers.TransportWriteBufferSize
+		}
+	}
+	return readSize, writeSize
+}
+
+// keepAliveSettings returns the keepalive period and probe configuration used by
+// net.Dialer and net.ListenConfig (zero values keep Go's defaults)
+func keepAliveSettings(cfg *config.Config) (time.Duration, net.KeepAliveConfig) {
+	ka := cfg.Timeouts.KeepAlive
+	if ka == nil {
+		return 0, net.KeepAliveConfig{}
+	}
+	if !ka.Enabled {
+		// A negative period disables keepalive probes
+		return -1, net.KeepAliveConfig{}
+	}
+
+	return 0, net.KeepAliveConfig{
+		Enable:   true,
+		Idle:     ka.Idle,
+		Interval: ka.Interval,
+		Count:    ka.Count,
+	}
+}
+
+// newDialer creates a backend dialer using the configured connect timeout and keepalive settings
+func newDialer(cfg *config.Config) *net.Dialer {
+	keepAlive, keepAliveConfig := keepAliveSettings(cfg)
+	return &net.Dialer{
+		Timeout:         cfg.Timeouts.Connect,
+		KeepAlive:       keepAlive,
+		KeepAliveConfig: keepAliveConfig,
+	}
+}
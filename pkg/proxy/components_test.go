@@ -0,0 +1,530 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/router"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestKeepAliveSettings(t *testing.T) {
+	tests := []struct {
+		name          string
+		keepAlive     *config.KeepAliveConfig
+		expectPeriod  time.Duration
+		expectEnabled bool
+	}{
+		{
+			name:          "Not configured uses defaults",
+			keepAlive:     nil,
+			expectPeriod:  0,
+			expectEnabled: false,
+		},
+		{
+			name:          "Disabled",
+			keepAlive:     &config.KeepAliveConfig{Enabled: false},
+			expectPeriod:  -1,
+			expectEnabled: false,
+		},
+		{
+			name: "Enabled with custom probes",
+			keepAlive: &config.KeepAliveConfig{
+				Enabled:  true,
+				Idle:     30 * time.Second,
+				Interval: 5 * time.Second,
+				Count:    3,
+			},
+			expectPeriod:  0,
+			expectEnabled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Timeouts: config.TimeoutConfig{
+					Connect:   time.Second,
+					KeepAlive: tt.keepAlive,
+				},
+			}
+
+			period, kac := keepAliveSettings(cfg)
+			if period != tt.expectPeriod {
+				t.Errorf("Expected keepalive period %v, got %v", tt.expectPeriod, period)
+			}
+			if kac.Enable != tt.expectEnabled {
+				t.Errorf("Expected keepalive enabled %v, got %v", tt.expectEnabled, kac.Enable)
+			}
+			if tt.expectEnabled {
+				if kac.Idle != tt.keepAlive.Idle || kac.Interval != tt.keepAlive.Interval || kac.Count != tt.keepAlive.Count {
+					t.Errorf("Keepalive probe settings not applied: %+v", kac)
+				}
+			}
+
+			dialer := newDialer(cfg)
+			if dialer.Timeout != time.Second {
+				t.Errorf("Expected dial timeout 1s, got %v", dialer.Timeout)
+			}
+			if dialer.KeepAlive != tt.expectPeriod {
+				t.Errorf("Expected dialer keepalive %v, got %v", tt.expectPeriod, dialer.KeepAlive)
+			}
+		})
+	}
+}
+
+func TestNewCopyBufferPool(t *testing.T) {
+	tests := []struct {
+		name     string
+		buffers  *config.BufferConfig
+		expected int
+	}{
+		{"Default", nil, defaultCopyBufferSize},
+		{"Shared 64KB pool", &config.BufferConfig{CopyBufferSize: 64 * 1024}, 64 * 1024},
+		{"Custom size", &config.BufferConfig{CopyBufferSize: 128 * 1024}, 128 * 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bp := newCopyBufferPool(&config.Config{Buffers: tt.buffers})
+			if bp.Size() != tt.expected {
+				t.Errorf("Expected buffer size %d, got %d", tt.expected, bp.Size())
+			}
+
+			buf := bp.Get()
+			if len(buf) != tt.expected {
+				t.Errorf("Expected buffer length %d, got %d", tt.expected, len(buf))
+			}
+			bp.Put(buf)
+		})
+	}
+}
+
+func TestTransportBufferSizes(t *testing.T) {
+	read, write := transportBufferSizes(&config.Config{})
+	if read != defaultTransportBufferSize || write != defaultTransportBufferSize {
+		t.Errorf("Expected default sizes %d/%d, got %d/%d", defaultTransportBufferSize, defaultTransportBufferSize, read, write)
+	}
+
+	read, write = transportBufferSizes(&config.Config{
+		Buffers: &config.BufferConfig{
+			TransportReadBufferSize:  64 * 1024,
+			TransportWriteBufferSize: 16 * 1024,
+		},
+	})
+	if read != 64*1024 || write != 16*1024 {
+		t.Errorf("Expected configured sizes 65536/16384, got %d/%d", read, write)
+	}
+}
+
+func TestNewLoadBalancerZoneAware(t *testing.T) {
+	cfg := &config.Config{
+		Zone: "us-east-1a",
+		Backends: []config.Backend{
+			{Name: "near", Address: "127.0.0.1:9001", Weight: 1, Zone: "us-east-1a"},
+			{Name: "far", Address: "127.0.0.1:9002", Weight: 1, Zone: "us-east-1b"},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "least-connections"},
+	}
+
+	pool, err := newBackendPool(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create backend pool: %v", err)
+	}
+	balancer, err := newLoadBalancer(cfg, pool)
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	if balancer.Name() != "zone-aware(least-connections)" {
+		t.Errorf("Expected zone-aware balancer, got %s", balancer.Name())
+	}
+
+	for i := 0; i < 5; i++ {
+		if b := balancer.Select(); b == nil || b.Name() != "near" {
+			t.Errorf("Expected same-zone backend, got %v", b)
+		}
+	}
+
+	// Without a proxy zone, the algorithm is used as-is
+	cfg.Zone = ""
+	balancer, err = newLoadBalancer(cfg, pool)
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	if balancer.Name() != "least-connections" {
+		t.Errorf("Expected least-connections, got %s", balancer.Name())
+	}
+}
+
+func TestNewBackendPoolSubset(t *testing.T) {
+	cfg := &config.Config{
+		LoadBalancer: config.LoadBalancerConfig{
+			Algorithm: "round-robin",
+			Subset:    &config.SubsetConfig{Size: 3, InstanceID: "instance-1"},
+		},
+	}
+	for i := 0; i < 10; i++ {
+		cfg.Backends = append(cfg.Backends, config.Backend{
+			Name:    fmt.Sprintf("backend-%d", i),
+			Address: fmt.Sprintf("127.0.0.1:%d", 9000+i),
+			Weight:  1,
+		})
+	}
+
+	pool, err := newBackendPool(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create backend pool: %v", err)
+	}
+	if pool.Size() != 3 {
+		t.Fatalf("Expected 3 backends in subset, got %d", pool.Size())
+	}
+
+	// The same instance always gets the same subset
+	again, _ := newBackendPool(cfg, nil)
+	for i, b := range pool.All() {
+		if again.All()[i].Name() != b.Name() {
+			t.Errorf("Expected deterministic subset, got %s and %s", b.Name(), again.All()[i].Name())
+		}
+	}
+}
+
+func TestNewCircuitBreakersOverrides(t *testing.T) {
+	cfg := &config.Config{
+		Backends: []config.Backend{
+			{Name: "fragile", Address: "127.0.0.1:9001", Weight: 1,
+				CircuitBreaker: &config.BackendCircuitBreakerConfig{MaxFailures: 1}},
+			{Name: "sturdy", Address: "127.0.0.1:9002", Weight: 1},
+		},
+		Resilience: &config.ResilienceConfig{
+			CircuitBreaker: &config.CircuitBreakerConfig{
+				Enabled:               true,
+				MaxFailures:           5,
+				Timeout:               time.Minute,
+				MaxConcurrentRequests: 1,
+			},
+		},
+	}
+
+	pool, err := newBackendPool(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create backend pool: %v", err)
+	}
+	breakers := newCircuitBreakers(cfg, pool)
+
+	fail := func() error { return errors.New("backend failure") }
+	breakers.get("fragile").Execute(fail)
+	breakers.get("sturdy").Execute(fail)
+
+	if state := breakers.get("fragile").GetState(); state != resilience.StateOpen {
+		t.Errorf("Expected fragile breaker to open after 1 failure, got %v", state)
+	}
+	if state := breakers.get("sturdy").GetState(); state != resilience.StateClosed {
+		t.Errorf("Expected sturdy breaker to stay closed, got %v", state)
+	}
+}
+
+func TestCircuitBreakersFollowPool(t *testing.T) {
+	cfg := &config.Config{
+		Backends: []config.Backend{
+			{Name: "static", Address: "127.0.0.1:9001", Weight: 1},
+		},
+		Resilience: &config.ResilienceConfig{
+			CircuitBreaker: &config.CircuitBreakerConfig{Enabled: true, MaxFailures: 1, Timeout: time.Minute},
+		},
+	}
+
+	pool, err := newBackendPool(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create backend pool: %v", err)
+	}
+	breakers := newCircuitBreakers(cfg, pool)
+
+	if err := pool.Add(backend.NewBackend("discovered", "127.0.0.1:9002", 1)); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+	if breakers.get("discovered") == nil {
+		t.Fatal("Expected a circuit breaker for the added backend")
+	}
+
+	pool.Remove("static")
+	if breakers.get("static") != nil {
+		t.Error("Expected the circuit breaker of the removed backend to be dropped")
+	}
+	if all := breakers.all(); len(all) != 1 {
+		t.Errorf("Expected 1 circuit breaker, got %d", len(all))
+	}
+}
+
+func TestNewAccessLoggerSampling(t *testing.T) {
+	if accessLog, err := newAccessLogger(&config.Config{}); err != nil || accessLog != nil {
+		t.Fatalf("Expected no access logger without logging.access_log, got %v, %v", accessLog, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	accessLog, err := newAccessLogger(&config.Config{Logging: &config.LoggingConfig{
+		AccessLog:       true,
+		AccessLogOutput: path,
+		AccessLogSampling: &config.AccessLogSamplingConfig{
+			Rates:         map[string]float64{"2xx": 0},
+			SlowThreshold: time.Second,
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create access logger: %v", err)
+	}
+
+	// Fast successful requests are sampled out; errors and slow requests are kept
+	accessLog.Log(logging.AccessLog{Path: "/fast", StatusCode: 200, Duration: time.Millisecond})
+	accessLog.Log(logging.AccessLog{Path: "/error", StatusCode: 502, Duration: time.Millisecond})
+	accessLog.Log(logging.AccessLog{Path: "/slow", StatusCode: 200, Duration: 2 * time.Second})
+	accessLog.Close()
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read access log: %v", err)
+	}
+	logged := string(out)
+	if strings.Contains(logged, "path=/fast") || !strings.Contains(logged, "path=/error") || !strings.Contains(logged, "path=/slow") {
+		t.Errorf("Unexpected access log:\n%s", logged)
+	}
+}
+
+func TestNewAccessLoggerSyslog(t *testing.T) {
+	collector, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer collector.Close()
+
+	accessLog, err := newAccessLogger(&config.Config{Logging: &config.LoggingConfig{
+		AccessLog:       true,
+		AccessLogOutput: "syslog://" + collector.LocalAddr().String() + "?app=edge&facility=local1",
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create access logger: %v", err)
+	}
+	accessLog.Log(logging.AccessLog{Path: "/orders", StatusCode: 200})
+	accessLog.Close()
+
+	// Access logs are sent at info severity (local1.info is priority 142)
+	collector.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := collector.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to receive syslog message: %v", err)
+	}
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<142>1 ") || !strings.Contains(msg, fmt.Sprintf(" edge %d - - ", os.Getpid())) || !strings.Contains(msg, "path=/orders") {
+		t.Errorf("Unexpected syslog message: %q", msg)
+	}
+}
+
+func TestNewTracerRouteSampleRate(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer collector.Close()
+
+	always := 1.0
+	routes := []config.Route{
+		{Name: "checkout", PathPrefix: "/checkout", TraceSampleRate: &always},
+		{Name: "api", PathPrefix: "/api"},
+	}
+	tracer, err := newTracer(&config.Config{
+		Tracing: &config.TracingConfig{
+			Enabled:    true,
+			Exporter:   "zipkin",
+			Endpoint:   collector.URL,
+			SampleRate: 0,
+		},
+	}, router.NewRouter(routes, backend.NewPool()))
+	if err != nil {
+		t.Fatalf("Failed to create tracer: %v", err)
+	}
+	defer tracer.Close(context.Background())
+
+	// Only the route overriding the global rate of 0 is traced
+	for path, want := range map[string]bool{"/checkout/pay": true, "/api/users": false, "/other": false} {
+		var sampled bool
+		handler := tracer.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sampled = trace.SpanContextFromContext(r.Context()).IsSampled()
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if sampled != want {
+			t.Errorf("Expected sampled=%v for %s, got %v", want, path, sampled)
+		}
+	}
+}
+
+func TestSecurityEnforcedOnRequests(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Security: &config.SecurityConfig{
+			IPBlocklist: &config.IPBlocklistConfig{BlockedCIDRs: []string{"192.0.2.0/24"}},
+			RateLimit: &config.RateLimitConfig{
+				Enabled:           true,
+				Type:              "token-bucket",
+				RequestsPerSecond: 0.001,
+				BurstSize:         1,
+			},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	// X-Forwarded-For from a client that is not a trusted proxy is ignored
+	tests := []struct {
+		remoteAddr string
+		xff        string
+		want       int
+	}{
+		{"192.0.2.10:1234", "", http.StatusForbidden},
+		{"192.0.2.10:1234", "198.51.100.50", http.StatusForbidden},
+		{"198.51.100.1:1234", "", http.StatusOK},
+		{"198.51.100.1:1234", "", http.StatusTooManyRequests},
+		{"198.51.100.1:1234", "203.0.113.9", http.StatusTooManyRequests},
+		{"198.51.100.2:1234", "192.0.2.10", http.StatusOK},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("Request %d from %s: expected %d, got %d", i+1, tt.remoteAddr, tt.want, rec.Code)
+		}
+	}
+}
+
+func TestSecurityEnforcedOnConnections(t *testing.T) {
+	server, err := NewTCPServer(&config.Config{
+		Mode:         "tcp",
+		Listen:       "127.0.0.1:0",
+		Backends:     []config.Backend{{Name: "echo", Address: startEchoBackend(t), Weight: 1}},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+		Security: &config.SecurityConfig{
+			IPBlocklist: &config.IPBlocklistConfig{BlockedIPs: []string{"127.0.0.1"}},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TCP server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Shutdown()
+
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if echo(conn, "hello") {
+		t.Error("Expected the connection from a blocked IP to be closed")
+	}
+}
+
+func TestCircuitBreakerEnforcedOnRequests(t *testing.T) {
+	// Nothing listens on the backend address, so every attempt fails
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode:         "http",
+		Backends:     []config.Backend{{Name: "b1", Address: address, Weight: 1}},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Resilience: &config.ResilienceConfig{
+			CircuitBreaker: &config.CircuitBreakerConfig{Enabled: true, MaxFailures: 1, Timeout: time.Minute},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected %d for the failed request, got %d", http.StatusBadGateway, rec.Code)
+	}
+	if state := h.breakers.get("b1").GetState(); state != resilience.StateOpen {
+		t.Errorf("Expected the circuit breaker to open after the failure, got %s", state)
+	}
+}
+
+func TestHealthCheckerStartedWithServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	server, err := NewTCPServer(&config.Config{
+		Mode:         "tcp",
+		Listen:       "127.0.0.1:0",
+		Backends:     []config.Backend{{Name: "down", Address: address, Weight: 1}},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+		HealthCheck: &config.HealthCheckConfig{
+			Enabled:            true,
+			Type:               "tcp",
+			Interval:           20 * time.Millisecond,
+			Timeout:            100 * time.Millisecond,
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TCP server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Shutdown()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.Pool().HealthySize() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := server.Pool().HealthySize(); n != 0 {
+		t.Errorf("Expected the active health check to mark the backend down, got %d healthy", n)
+	}
+}
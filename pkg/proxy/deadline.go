@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// grpcTimeoutUnits are the grpc-timeout units from smallest to largest
+var grpcTimeoutUnits = []struct {
+	unit   time.Duration
+	suffix byte
+}{
+	{time.Nanosecond, 'n'},
+	{time.Microsecond, 'u'},
+	{time.Millisecond, 'm'},
+	{time.Second, 'S'},
+	{time.Minute, 'M'},
+	{time.Hour, 'H'},
+}
+
+// propagateDeadline tells the backend how long the proxy will wait for this attempt
+// The remaining time is the request deadline minus elapsed time, capped at perTry (0 = no cap).
+// It is sent as X-Request-Timeout (milliseconds) and, for gRPC requests, as grpc-timeout
+// unless the client already asked for a shorter one.
+func propagateDeadline(req *http.Request, perTry time.Duration) {
+	remaining := perTry
+	if deadline, ok := req.Context().Deadline(); ok {
+		if left := time.Until(deadline); remaining == 0 || left < remaining {
+			remaining = left
+		}
+	}
+	if remaining <= 0 {
+		return
+	}
+
+	req.Header.Set("X-Request-Timeout", strconv.FormatInt(max(remaining.Milliseconds(), 1), 10))
+
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+		return
+	}
+	if current, ok := parseGRPCTimeout(req.Header.Get("Grpc-Timeout")); ok && current <= remaining {
+		return
+	}
+	req.Header.Set("Grpc-Timeout", formatGRPCTimeout(remaining))
+}
+
+// formatGRPCTimeout encodes a duration as a grpc-timeout value (at most 8 digits plus a unit)
+func formatGRPCTimeout(d time.Duration) string {
+	for _, u := range grpcTimeoutUnits {
+		if v := d / u.unit; v < 1e8 {
+			return strconv.FormatInt(int64(v), 10) + string(u.suffix)
+		}
+	}
+	return "99999999H"
+}
+
+// parseGRPCTimeout decodes a grpc-timeout value
+func parseGRPCTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+
+	v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+
+	for _, u := range grpcTimeoutUnits {
+		if s[len(s)-1] == u.suffix {
+			return time.Duration(v) * u.unit, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPropagateDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodPost, "/pkg.Service/Method", nil).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/grpc")
+	propagateDeadline(req, 0)
+
+	ms, err := strconv.Atoi(req.Header.Get("X-Request-Timeout"))
+	if err != nil || ms <= 1500 || ms > 2000 {
+		t.Errorf("Expected X-Request-Timeout close to 2000ms, got %q", req.Header.Get("X-Request-Timeout"))
+	}
+
+	timeout, ok := parseGRPCTimeout(req.Header.Get("Grpc-Timeout"))
+	if !ok || timeout <= 1500*time.Millisecond || timeout > 2*time.Second {
+		t.Errorf("Expected grpc-timeout close to 2s, got %q", req.Header.Get("Grpc-Timeout"))
+	}
+}
+
+func TestPropagateDeadlinePerTry(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	propagateDeadline(req, 250*time.Millisecond)
+
+	if got := req.Header.Get("X-Request-Timeout"); got != "250" {
+		t.Errorf("Expected X-Request-Timeout 250, got %q", got)
+	}
+	if got := req.Header.Get("Grpc-Timeout"); got != "" {
+		t.Errorf("Expected no grpc-timeout for non-gRPC requests, got %q", got)
+	}
+}
+
+func TestPropagateDeadlineKeepsShorterClientTimeout(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Grpc-Timeout", "100m")
+	propagateDeadline(req, time.Second)
+
+	if got := req.Header.Get("Grpc-Timeout"); got != "100m" {
+		t.Errorf("Expected client grpc-timeout to be kept, got %q", got)
+	}
+}
+
+func TestGRPCTimeoutFormat(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{500 * time.Nanosecond, "500n"},
+		{250 * time.Millisecond, "250000u"},
+		{2 * time.Second, "2000000u"},
+		{5 * time.Minute, "300000m"},
+		{48 * time.Hour, "172800S"},
+	}
+
+	for _, tt := range tests {
+		got := formatGRPCTimeout(tt.d)
+		if got != tt.want {
+			t.Errorf("formatGRPCTimeout(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+		if parsed, ok := parseGRPCTimeout(got); !ok || parsed != tt.d {
+			t.Errorf("parseGRPCTimeout(%q) = %s, want %s", got, parsed, tt.d)
+		}
+	}
+
+	if _, ok := parseGRPCTimeout("10x"); ok {
+		t.Error("Expected invalid unit to be rejected")
+	}
+}
@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+const (
+	// defaultDrainTimeout is used when shutdown.drain_timeout is not set
+	defaultDrainTimeout = 30 * time.Second
+
+	// drainIdleThreshold is how long a stream must be quiet to be closed early while draining
+	drainIdleThreshold = time.Second
+
+	// drainPollInterval is how often idle streams are closed while draining
+	drainPollInterval = 250 * time.Millisecond
+)
+
+// drainTimeout returns how long shutdown waits for active connections to finish
+func drainTimeout(cfg *config.Config) time.Duration {
+	if cfg.Shutdown != nil && cfg.Shutdown.DrainTimeout > 0 {
+		return cfg.Shutdown.DrainTimeout
+	}
+	return defaultDrainTimeout
+}
+
+// announceShutdown keeps serving for shutdown.announce_period after the server started
+// reporting itself as draining, so upstream load balancers can take it out of rotation
+// before its listeners close
+func (s *Server) announceShutdown() {
+	cfg := s.config.Shutdown
+	if cfg == nil || cfg.AnnouncePeriod <= 0 {
+		return
+	}
+
+	// Ask HTTP clients to reconnect elsewhere instead of reusing their connections
+	if s.httpServer != nil && cfg.CloseConnections {
+		s.httpServer.server.SetKeepAlivesEnabled(false)
+	}
+
+	s.logger.Info("Reporting not ready before closing listeners", logging.Duration("announce_period", cfg.AnnouncePeriod))
+	time.Sleep(cfg.AnnouncePeriod)
+}
+
+// connTracker keeps track of proxied streams so they can be closed during shutdown
+type connTracker struct {
+	streams map[*trackedStream]struct{}
+	mu      sync.Mutex
+}
+
+// trackedStream is an open proxied stream
+type trackedStream struct {
+	activity *streamActivity // nil if activity is not tracked
+	close    func()
+}
+
+// newConnTracker creates an empty connection tracker
+func newConnTracker() *connTracker {
+	return &connTracker{streams: make(map[*trackedStream]struct{})}
+}
+
+// add registers a stream and returns a function that unregisters it
+// A stream without an activity tracker is never considered idle
+func (t *connTracker) add(activity *streamActivity, close func()) (remove func()) {
+	stream := &trackedStream{activity: activity, close: close}
+
+	t.mu.Lock()
+	t.streams[stream] = struct{}{}
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		delete(t.streams, stream)
+		t.mu.Unlock()
+	}
+}
+
+// closeIdle closes streams that have been idle for at least minIdle and returns how many were closed
+func (t *connTracker) closeIdle(minIdle time.Duration) int {
+	return t.closeWhere(func(s *trackedStream) bool {
+		return s.activity != nil && s.activity.idleFor() >= minIdle
+	})
+}
+
+// closeAll closes every open stream and returns how many were closed
+func (t *connTracker) closeAll() int {
+	return t.closeWhere(func(*trackedStream) bool { return true })
+}
+
+// closeWhere closes and unregisters the streams matching fn
+func (t *connTracker) closeWhere(fn func(*trackedStream) bool) int {
+	t.mu.Lock()
+	var matched []*trackedStream
+	for s := range t.streams {
+		if fn(s) {
+			matched = append(matched, s)
+			delete(t.streams, s)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, s := range matched {
+		s.close()
+	}
+	return len(matched)
+}
+
+// drain waits for done while closing idle streams, and force-closes the remaining
+// streams once the timeout expires
+func (t *connTracker) drain(done <-chan struct{}, timeout time.Duration, logger *logging.Logger) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			logger.Info("All connections closed")
+			return
+		case <-ticker.C:
+			if n := t.closeIdle(drainIdleThreshold); n > 0 {
+				logger.Info("Closed idle connections while draining", logging.Int("connections", n))
+			}
+		case <-deadline.C:
+			logger.Warn("Drain timeout exceeded, closing remaining connections",
+				logging.Duration("timeout", timeout), logging.Int("connections", t.closeAll()))
+			return
+		}
+	}
+}
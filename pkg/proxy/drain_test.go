@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestConnTrackerCloseIdle(t *testing.T) {
+	tracker := newConnTracker()
+
+	idle := newStreamActivity()
+	idle.last.Store(time.Now().Add(-time.Minute).UnixNano())
+	busy := newStreamActivity()
+
+	var idleClosed, busyClosed, untrackedClosed bool
+	tracker.add(idle, func() { idleClosed = true })
+	tracker.add(busy, func() { busyClosed = true })
+	tracker.add(nil, func() { untrackedClosed = true })
+
+	if n := tracker.closeIdle(time.Second); n != 1 {
+		t.Errorf("Expected 1 idle stream to be closed, got %d", n)
+	}
+	if !idleClosed || busyClosed || untrackedClosed {
+		t.Errorf("Expected only the idle stream to be closed (idle=%v busy=%v untracked=%v)",
+			idleClosed, busyClosed, untrackedClosed)
+	}
+
+	if n := tracker.closeAll(); n != 2 {
+		t.Errorf("Expected 2 remaining streams to be closed, got %d", n)
+	}
+}
+
+func TestConnTrackerRemove(t *testing.T) {
+	tracker := newConnTracker()
+
+	closed := false
+	remove := tracker.add(nil, func() { closed = true })
+	remove()
+
+	if n := tracker.closeAll(); n != 0 || closed {
+		t.Errorf("Expected removed stream not to be closed, closed %d", n)
+	}
+}
+
+func TestConnTrackerDrainTimeout(t *testing.T) {
+	tracker := newConnTracker()
+
+	closed := make(chan struct{})
+	tracker.add(newStreamActivity(), func() { close(closed) })
+
+	start := time.Now()
+	tracker.drain(make(chan struct{}), 50*time.Millisecond, nil)
+
+	select {
+	case <-closed:
+	default:
+		t.Error("Expected active stream to be force-closed after the drain timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected drain to stop at the timeout, took %s", elapsed)
+	}
+}
+
+func TestShutdownAnnouncePeriod(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backendServer.Close()
+
+	cfg := &config.Config{
+		Mode:   "http",
+		Listen: "127.0.0.1:0",
+		Backends: []config.Backend{
+			{Name: "backend", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Shutdown: &config.ShutdownConfig{
+			DrainTimeout:     time.Second,
+			AnnouncePeriod:   300 * time.Millisecond,
+			CloseConnections: true,
+		},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	url := "http://" + server.httpServer.listener.Addr().String() + "/"
+
+	done := make(chan struct{})
+	go func() {
+		server.Shutdown()
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !server.Draining() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !server.Draining() {
+		t.Fatal("Expected server to report draining")
+	}
+
+	// Requests are still served during the announce period, but connections are not reused
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Expected request during announce period to succeed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !resp.Close {
+		t.Errorf("Expected 200 with Connection: close, got %d (close=%v)", resp.StatusCode, resp.Close)
+	}
+
+	<-done
+}
@@ -0,0 +1,31 @@
+package proxy
+
+import "net/http"
+
+// isEarlyData reports whether a request was sent in TLS 1.3 early data (0-RTT)
+// This is the case while the request's own handshake is not complete, or when a TLS
+// terminator in front of the proxy marked it with "Early-Data: 1" (RFC 8470).
+func isEarlyData(r *http.Request) bool {
+	if r.TLS != nil && !r.TLS.HandshakeComplete {
+		return true
+	}
+	return r.Header.Get("Early-Data") == "1"
+}
+
+// checkEarlyData rejects non-idempotent requests sent in early data with 425 Too Early,
+// since an attacker can replay early data, and marks the others for the backend
+// It returns false if the request was rejected.
+func checkEarlyData(w http.ResponseWriter, r *http.Request) bool {
+	if !isEarlyData(r) {
+		return true
+	}
+	if !isIdempotent(r.Method) {
+		http.Error(w, "Too Early", http.StatusTooEarly)
+		return false
+	}
+
+	// The backend may still answer 425 itself, which makes the client retry after
+	// the handshake
+	r.Header.Set("Early-Data", "1")
+	return true
+}
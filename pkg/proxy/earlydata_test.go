@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestEarlyData(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Early-Data", r.Header.Get("Early-Data"))
+		w.Write([]byte("ok"))
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	tests := []struct {
+		name      string
+		method    string
+		header    bool
+		handshake bool
+		wantCode  int
+		wantEarly string
+	}{
+		{"regular POST", http.MethodPost, false, false, http.StatusOK, ""},
+		{"POST marked by TLS terminator", http.MethodPost, true, false, http.StatusTooEarly, ""},
+		{"GET marked by TLS terminator", http.MethodGet, true, false, http.StatusOK, "1"},
+		{"POST before handshake completed", http.MethodPost, false, true, http.StatusTooEarly, ""},
+		{"PUT before handshake completed", http.MethodPut, false, true, http.StatusOK, "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			if tt.header {
+				r.Header.Set("Early-Data", "1")
+			}
+			if tt.handshake {
+				r.TLS = &tls.ConnectionState{Version: tls.VersionTLS13}
+			}
+
+			rec := httptest.NewRecorder()
+			h.handleRequest(rec, r)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d", tt.wantCode, rec.Code)
+			}
+			if got := rec.Header().Get("X-Early-Data"); got != tt.wantEarly {
+				t.Errorf("Expected backend to see Early-Data %q, got %q", tt.wantEarly, got)
+			}
+		})
+	}
+}
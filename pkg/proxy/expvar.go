@@ -0,0 +1,27 @@
+package proxy
+
+import (
+	"expvar"
+)
+
+// expvarStats is the top-level "balance" expvar map
+var expvarStats = expvar.NewMap("balance")
+
+// PublishExpvar publishes the server's statistics under the "balance" expvar
+// Publishing again replaces the previous server's entries
+func (s *Server) PublishExpvar() {
+	expvarStats.Set("proxy", expvar.Func(func() interface{} {
+		return s.Stats()
+	}))
+	expvarStats.Set("pool", expvar.Func(func() interface{} {
+		return s.pool.Stats()
+	}))
+
+	if s.security != nil {
+		expvarStats.Set("security", expvar.Func(func() interface{} {
+			return s.security.Stats()
+		}))
+	} else {
+		expvarStats.Delete("security")
+	}
+}
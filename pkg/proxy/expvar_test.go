@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	cfg := &config.Config{
+		Mode:   "tcp",
+		Listen: "127.0.0.1:0",
+		Backends: []config.Backend{
+			{Name: "backend1", Address: "127.0.0.1:9001", Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{
+			Algorithm: "round-robin",
+		},
+		Security: &config.SecurityConfig{},
+	}
+
+	server, err := NewTCPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	server.totalConnections.Add(3)
+	server.PublishExpvar()
+
+	balance, ok := expvar.Get("balance").(*expvar.Map)
+	if !ok {
+		t.Fatal("Expected balance expvar map")
+	}
+
+	var proxyStats map[string]interface{}
+	if err := json.Unmarshal([]byte(balance.Get("proxy").String()), &proxyStats); err != nil {
+		t.Fatalf("Failed to decode proxy stats: %v", err)
+	}
+	if proxyStats["total_connections"] != float64(3) {
+		t.Errorf("Expected total_connections 3, got %v", proxyStats["total_connections"])
+	}
+
+	for _, key := range []string{"pool", "security"} {
+		if balance.Get(key) == nil {
+			t.Errorf("Expected %q to be published", key)
+		}
+	}
+}
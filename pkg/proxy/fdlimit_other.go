@@ -0,0 +1,10 @@
+//go:build !unix
+// +build !unix
+
+package proxy
+
+// openFileLimit is not supported on this platform, so the connection queue is not
+// bounded by it
+func openFileLimit() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,15 @@
+//go:build unix
+// +build unix
+
+package proxy
+
+import "syscall"
+
+// openFileLimit returns the process's limit on open file descriptors
+func openFileLimit() (uint64, bool) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, false
+	}
+	return uint64(limit.Cur), true
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// fingerprintListener wraps accepted connections so the JA3 fingerprint of their
+// ClientHello can be recorded during the TLS handshake
+type fingerprintListener struct {
+	net.Listener
+}
+
+// Accept waits for and returns the next connection
+func (l fingerprintListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &fingerprintConn{Conn: conn}, nil
+}
+
+// fingerprintConn is a connection with the JA3 hash of its ClientHello
+type fingerprintConn struct {
+	net.Conn
+	ja3 atomic.Pointer[string]
+}
+
+// NetConn returns the wrapped connection
+func (c *fingerprintConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// connJA3 returns the JA3 hash recorded for a TLS connection ("" if there is none)
+func connJA3(conn net.Conn) string {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+	fc, ok := tlsConn.NetConn().(*fingerprintConn)
+	if !ok {
+		return ""
+	}
+	if ja3 := fc.ja3.Load(); ja3 != nil {
+		return *ja3
+	}
+	return ""
+}
+
+// connContextKey is the context key of the connection an HTTP request arrived on
+type connContextKey struct{}
+
+// withConn stores the connection in the context; it is used as http.Server.ConnContext
+func withConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, conn)
+}
+
+// requestJA3 returns the JA3 hash of the connection a request arrived on
+func requestJA3(r *http.Request) string {
+	conn, ok := r.Context().Value(connContextKey{}).(net.Conn)
+	if !ok {
+		return ""
+	}
+	return connJA3(conn)
+}
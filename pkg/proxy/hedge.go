@@ -0,0 +1,310 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+)
+
+const (
+	// hedgeWindow is the number of recent response times used to derive the hedge delay
+	hedgeWindow = 256
+
+	// hedgeMinSamples is how many response times are needed before the delay is derived
+	hedgeMinSamples = 20
+
+	// hedgeRecompute is how often (in samples) the derived delay is recomputed
+	hedgeRecompute = 32
+)
+
+// errHedgeLost is returned to an attempt whose response lost the race
+var errHedgeLost = errors.New("hedged attempt lost the race")
+
+// hedger decides when to send a second copy of a slow request
+// The hedge delay is either fixed or the p95 of recent response times, and the
+// number of hedged requests is capped by a budget so hedging cannot double the load
+type hedger struct {
+	delay    time.Duration
+	minDelay time.Duration
+	budget   *resilience.RetryBudget
+
+	// Recent response times
+	samples []time.Duration
+	next    int
+	count   int
+	p95     atomic.Int64
+	mu      sync.Mutex
+
+	// Statistics
+	hedged atomic.Int64
+	wins   atomic.Int64
+}
+
+// newHedger creates a hedger from the resilience.hedging settings (nil if hedging is disabled)
+func newHedger(cfg *config.Config) *hedger {
+	if cfg.Resilience == nil || cfg.Resilience.Hedging == nil || !cfg.Resilience.Hedging.Enabled {
+		return nil
+	}
+	hc := cfg.Resilience.Hedging
+
+	return &hedger{
+		delay:    hc.Delay,
+		minDelay: hc.MinDelay,
+		budget:   resilience.NewRetryBudget(10*time.Second, 0, hc.BudgetPercent/100),
+		samples:  make([]time.Duration, hedgeWindow),
+	}
+}
+
+// observe records the response time of a successful request
+func (hg *hedger) observe(d time.Duration) {
+	hg.mu.Lock()
+	defer hg.mu.Unlock()
+
+	hg.samples[hg.next] = d
+	hg.next = (hg.next + 1) % len(hg.samples)
+	hg.count++
+
+	if hg.count >= hedgeMinSamples && (hg.count == hedgeMinSamples || hg.count%hedgeRecompute == 0) {
+		n := min(hg.count, len(hg.samples))
+		sorted := make([]time.Duration, n)
+		copy(sorted, hg.samples[:n])
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		hg.p95.Store(int64(sorted[(n*95)/100]))
+	}
+}
+
+// hedgeDelay returns how long to wait before hedging, or false if there is no delay yet
+func (hg *hedger) hedgeDelay() (time.Duration, bool) {
+	if hg.delay > 0 {
+		return hg.delay, true
+	}
+
+	p95 := time.Duration(hg.p95.Load())
+	if p95 == 0 {
+		return 0, false
+	}
+	return max(p95, hg.minDelay), true
+}
+
+// Stats returns hedging statistics
+func (hg *hedger) Stats() map[string]interface{} {
+	delay, _ := hg.hedgeDelay()
+	return map[string]interface{}{
+		"hedge_delay_ms":  delay.Milliseconds(),
+		"hedged_requests": hg.hedged.Load(),
+		"hedge_wins":      hg.wins.Load(),
+	}
+}
+
+// isHedgeable reports whether a request may be sent to two backends at once
+// Only idempotent requests without a body are hedged
+func isHedgeable(r *http.Request) bool {
+	return isIdempotent(r.Method) && isReplayable(r)
+}
+
+// hedgeResult is the outcome of one attempt in a hedged request
+type hedgeResult struct {
+	writer *hedgeWriter
+	err    error
+	abort  bool // the attempt aborted after the response started
+}
+
+// hedgedAttempt sends the request to one backend and, if it has not responded within
+// the hedge delay, to a second one; the first response to arrive is sent to the client
+func (h *HTTPServer) hedgedAttempt(sw *statusWriter, r *http.Request, clientIP string, rules *retryRules) error {
+	h.hedger.budget.RecordRequest()
+
+	delay, ok := h.hedger.hedgeDelay()
+	if !ok {
+		return h.proxyAttempt(sw, r, clientIP, rules)
+	}
+
+	primary, err := h.acquireBackend(r, clientIP)
+	if err != nil {
+		return err
+	}
+
+	race := &hedgeRace{dst: sw}
+	results := make(chan hedgeResult, 2)
+	launch := func(b *backend.Backend, hedge bool) {
+		ctx, cancel := context.WithCancel(r.Context())
+		hw := race.add(cancel, hedge)
+		go func() {
+			defer cancel()
+			defer func() {
+				// ReverseProxy aborts with a panic when the response body cannot be copied
+				if p := recover(); p != nil {
+					if p != http.ErrAbortHandler {
+						panic(p)
+					}
+					results <- hedgeResult{writer: hw, err: errHedgeLost, abort: true}
+				}
+			}()
+			err := h.proxyTo(&statusWriter{ResponseWriter: hw, status: http.StatusOK}, r.WithContext(ctx), clientIP, b, rules)
+			results <- hedgeResult{writer: hw, err: err}
+		}()
+	}
+
+	launch(primary, false)
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if race.isWinner(res.writer) {
+				if res.writer.hedge {
+					h.hedger.wins.Add(1)
+				}
+				if res.abort {
+					panic(http.ErrAbortHandler)
+				}
+				return res.err
+			}
+			if res.err != nil {
+				lastErr = res.err
+			}
+
+		case <-timer.C:
+			if race.decided() || !h.hedger.budget.CanRetry() {
+				continue
+			}
+			if b := h.hedgeBackend(r, clientIP, primary); b != nil {
+				h.hedger.hedged.Add(1)
+				launch(b, true)
+				pending++
+			}
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = errNoBackend
+	}
+	return lastErr
+}
+
+// hedgeBackend picks a backend for the hedged copy of a request, avoiding the primary
+// one, and takes one of its connections
+// Hedges are never queued: nil is returned if no other backend has a free connection.
+func (h *HTTPServer) hedgeBackend(r *http.Request, clientIP string, primary *backend.Backend) *backend.Backend {
+	if b := h.selectBackend(r, clientIP); b != nil && b != primary && b.TryIncrementConnections() {
+		return b
+	}
+
+	// Key-based algorithms keep returning the primary backend, so fall back to any other
+	for _, b := range h.pool.Healthy() {
+		if b != primary && b.TryIncrementConnections() {
+			return b
+		}
+	}
+	return nil
+}
+
+// hedgeRace hands the client response to whichever attempt starts responding first
+type hedgeRace struct {
+	dst     http.ResponseWriter
+	writers []*hedgeWriter
+	winner  *hedgeWriter
+	mu      sync.Mutex
+}
+
+// add registers a new attempt and returns its response writer
+func (hr *hedgeRace) add(cancel context.CancelFunc, hedge bool) *hedgeWriter {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	hw := &hedgeWriter{race: hr, header: make(http.Header), cancel: cancel, hedge: hedge}
+	hr.writers = append(hr.writers, hw)
+	return hw
+}
+
+// claim makes hw the winner if no attempt has responded yet, cancelling the others
+func (hr *hedgeRace) claim(hw *hedgeWriter) bool {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	if hr.winner != nil {
+		return hr.winner == hw
+	}
+
+	hr.winner = hw
+	for _, other := range hr.writers {
+		if other != hw {
+			other.cancel()
+		}
+	}
+
+	// Headers were buffered per attempt until now
+	dst := hr.dst.Header()
+	for k, v := range hw.header {
+		dst[k] = v
+	}
+	return true
+}
+
+// isWinner reports whether hw won the race
+func (hr *hedgeRace) isWinner(hw *hedgeWriter) bool {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	return hr.winner == hw
+}
+
+// decided reports whether an attempt has already started responding
+func (hr *hedgeRace) decided() bool {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	return hr.winner != nil
+}
+
+// hedgeWriter buffers an attempt's headers and only writes to the client if the attempt wins
+type hedgeWriter struct {
+	race   *hedgeRace
+	header http.Header
+	cancel context.CancelFunc
+	hedge  bool
+}
+
+// Header returns the attempt's headers, or the client's once the attempt has won
+func (hw *hedgeWriter) Header() http.Header {
+	if hw.race.isWinner(hw) {
+		return hw.race.dst.Header()
+	}
+	return hw.header
+}
+
+// WriteHeader claims the client response for this attempt
+func (hw *hedgeWriter) WriteHeader(code int) {
+	if hw.race.claim(hw) {
+		hw.race.dst.WriteHeader(code)
+	}
+}
+
+// Write writes to the client if this attempt won, and fails otherwise
+func (hw *hedgeWriter) Write(p []byte) (int, error) {
+	if !hw.race.claim(hw) {
+		return 0, errHedgeLost
+	}
+	return hw.race.dst.Write(p)
+}
+
+// Flush implements http.Flusher for streaming responses
+func (hw *hedgeWriter) Flush() {
+	if !hw.race.isWinner(hw) {
+		return
+	}
+	if flusher, ok := hw.race.dst.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestHedgedRequest(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", "fast")
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	cfg := &config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "slow", Address: strings.TrimPrefix(slow.URL, "http://"), Weight: 1},
+			{Name: "fast", Address: strings.TrimPrefix(fast.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Resilience: &config.ResilienceConfig{
+			Hedging: &config.HedgingConfig{Enabled: true, Delay: 20 * time.Millisecond, BudgetPercent: 100},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	// Round-robin sends the first request to the slow backend, so the hedge wins
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "fast" {
+		t.Errorf("Expected hedged response from fast backend, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Backend") != "fast" {
+		t.Errorf("Expected headers from the winning attempt, got %v", rec.Header())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected hedged request to finish early, took %s", elapsed)
+	}
+	if hedged := h.hedger.hedged.Load(); hedged != 1 {
+		t.Errorf("Expected 1 hedged request, got %d", hedged)
+	}
+	if wins := h.hedger.wins.Load(); wins != 1 {
+		t.Errorf("Expected 1 hedge win, got %d", wins)
+	}
+}
+
+func TestHedgerDelay(t *testing.T) {
+	hg := newHedger(&config.Config{
+		Resilience: &config.ResilienceConfig{
+			Hedging: &config.HedgingConfig{Enabled: true, MinDelay: 5 * time.Millisecond, BudgetPercent: 10},
+		},
+	})
+
+	if _, ok := hg.hedgeDelay(); ok {
+		t.Error("Expected no hedge delay before enough samples")
+	}
+
+	for i := 1; i <= 100; i++ {
+		hg.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	delay, ok := hg.hedgeDelay()
+	if !ok {
+		t.Fatal("Expected a hedge delay after 100 samples")
+	}
+	if delay < 90*time.Millisecond || delay > 100*time.Millisecond {
+		t.Errorf("Expected p95 delay around 95ms, got %s", delay)
+	}
+}
+
+func TestIsHedgeable(t *testing.T) {
+	if !isHedgeable(httptest.NewRequest(http.MethodGet, "/", nil)) {
+		t.Error("Expected GET to be hedgeable")
+	}
+	if isHedgeable(httptest.NewRequest(http.MethodPost, "/", nil)) {
+		t.Error("Expected POST not to be hedgeable")
+	}
+}
@@ -1,7 +1,11 @@
 package proxy
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,6 +13,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,8 +21,16 @@ import (
 
 	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
 	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/health"
 	"github.com/therealutkarshpriyadarshi/balance/pkg/lb"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/pool"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
 	"github.com/therealutkarshpriyadarshi/balance/pkg/router"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/security"
+	balancetls "github.com/therealutkarshpriyadarshi/balance/pkg/tls"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/tracing"
 	"golang.org/x/net/http2"
 )
 
@@ -25,15 +38,59 @@ import (
 type HTTPServer struct {
 	config    *config.Config
 	server    *http.Server
+	listener  net.Listener
+	limiter   *limitListener
 	pool      *backend.Pool
 	balancer  lb.LoadBalancer
 	router    *router.Router
 	transport *http.Transport
 
+	// Load balancers for routes with their own backends
+	routeBalancers map[*router.RouteEntry]lb.LoadBalancer
+
+	// Load balancers for the overflow backends of routes that have them
+	overflowBalancers map[*router.RouteEntry]lb.LoadBalancer
+
+	// TLS termination (nil when disabled)
+	termination *tlsTermination
+	tlsRoutes   *tlsRoutes
+
+	// Backend TLS (nil when disabled)
+	spiffe     *balancetls.SPIFFESource
+	backendTLS *tls.Config
+
+	// ACME HTTP-01 challenge passthrough (nil when disabled)
+	acme *acmeChallengeProxy
+
+	// Proxies whose forwarding headers identify the client (see trustedClientIP)
+	trustedProxies trustedProxies
+
+	// Optional components (nil when disabled)
+	checker   *health.Checker
+	security  *security.SecurityManager
+	urlFilter *security.URLFilter
+	audit     *logging.AuditLogger
+	accessLog *logging.AccessLogger
+	breakers  *circuitBreakers
+	retries   *resilience.RetryBudget
+	hedger    *hedger
+	timer     *upstreamTimer
+	capture   *logging.RequestCapture
+	queue     *requestQueue
+	shedder   *resilience.LoadShedder
+	bandwidth *bandwidthManager
+	tracer    *tracing.Tracer
+
+	logger *logging.Logger
+
+	// Reusable buffers for WebSocket copy loops
+	buffers *pool.BufferPool
+
 	// Graceful shutdown
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 	wg         sync.WaitGroup
+	websockets *connTracker
 
 	// Statistics
 	totalRequests      atomic.Int64
@@ -41,93 +98,198 @@ type HTTPServer struct {
 	totalBytesReceived atomic.Int64
 	totalBytesSent     atomic.Int64
 	totalErrors        atomic.Int64
+	overflowRequests   atomic.Int64
 }
 
-// NewHTTPServer creates a new HTTP reverse proxy server
-func NewHTTPServer(cfg *config.Config) (*Server, error) {
+// NewHTTPServer creates a new HTTP reverse proxy server logging to logger
+func NewHTTPServer(cfg *config.Config, logger *logging.Logger) (*Server, error) {
 	// Create backend pool
-	pool := backend.NewPool()
-	for _, backendCfg := range cfg.Backends {
-		b := backend.NewBackend(backendCfg.Name, backendCfg.Address, backendCfg.Weight)
-		pool.Add(b)
+	pool, err := newBackendPool(cfg, logger)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create load balancer
-	var balancer lb.LoadBalancer
-
-	switch cfg.LoadBalancer.Algorithm {
-	case "round-robin":
-		balancer = lb.NewRoundRobin(pool)
-	case "least-connections":
-		balancer = lb.NewLeastConnections(pool)
-	case "weighted-round-robin":
-		balancer = lb.NewWeightedRoundRobin(pool)
-	case "weighted-least-connections":
-		balancer = lb.NewWeightedLeastConnections(pool)
-	case "consistent-hash":
-		balancer = lb.NewConsistentHash(pool, lb.DefaultVirtualNodes, cfg.LoadBalancer.HashKey)
-	case "bounded-consistent-hash":
-		balancer = lb.NewBoundedLoadConsistentHash(pool, lb.DefaultVirtualNodes, cfg.LoadBalancer.HashKey, 1.25)
-	default:
-		return nil, fmt.Errorf("unsupported load balancer algorithm: %s", cfg.LoadBalancer.Algorithm)
+	balancer, err := newLoadBalancer(cfg, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	secManager, err := newSecurityManager(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := newTrustedProxies(cfg)
+	if err != nil {
+		return nil, err
+	}
+	urlFilter, err := newURLFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := newAuditLog(cfg)
+	if err != nil {
+		return nil, err
+	}
+	accessLog, err := newAccessLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	syncPoolMetrics(pool)
+	checker := newHealthChecker(cfg, pool, logger)
+	disc, err := newDiscovery(cfg, pool, checker)
+	if err != nil {
+		return nil, err
+	}
+	breakers := newCircuitBreakers(cfg, pool)
+
+	termination, err := newTLSTermination(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if termination != nil {
+		termination.audit = audit
+	}
+	tlsRoutes, err := newTLSRoutes(cfg, pool, logger)
+	if err != nil {
+		return nil, err
+	}
+	spiffe := newSPIFFESource(cfg, termination, logger)
+	backendTLS, err := newBackendTLSConfig(cfg, spiffe)
+	if err != nil {
+		return nil, err
+	}
+
+	acme := newACMEChallengeProxy(cfg, pool, logger)
+
+	// Create router if routes are configured
+	var rt *router.Router
+	routeBalancers := make(map[*router.RouteEntry]lb.LoadBalancer)
+	overflowBalancers := make(map[*router.RouteEntry]lb.LoadBalancer)
+	if cfg.HTTP != nil && len(cfg.HTTP.Routes) > 0 {
+		rt = router.NewRouter(cfg.HTTP.Routes, pool)
+		for _, route := range rt.Routes() {
+			if overflow := route.Overflow(); overflow != nil {
+				overflowBalancers[route], err = newLoadBalancer(cfg, overflow)
+				if err != nil {
+					return nil, fmt.Errorf("route %s overflow: %w", route.Name(), err)
+				}
+			}
+			if route.Pool().Size() == 0 {
+				// Routes without backends only override settings and use the global load balancer
+				continue
+			}
+			routeBalancers[route], err = newLoadBalancer(cfg, route.Pool())
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %w", route.Name(), err)
+			}
+		}
+	}
+
+	// Create tracer if tracing is enabled
+	tracer, err := newTracer(cfg, rt)
+	if err != nil {
+		return nil, err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Create HTTP transport
+	readBufferSize, writeBufferSize := transportBufferSizes(cfg)
 	transport := &http.Transport{
 		MaxIdleConnsPerHost: cfg.HTTP.MaxIdleConnsPerHost,
 		IdleConnTimeout:     cfg.HTTP.IdleConnTimeout,
 		DisableKeepAlives:   false,
 		DisableCompression:  false,
-		DialContext: (&net.Dialer{
-			Timeout:   cfg.Timeouts.Connect,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
+		DialContext:         newDialer(cfg).DialContext,
+		TLSClientConfig:     backendTLS,
 		ForceAttemptHTTP2:     cfg.HTTP.EnableHTTP2,
 		MaxIdleConns:          100,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 		ResponseHeaderTimeout: cfg.Timeouts.Read,
-		WriteBufferSize:       4096,
-		ReadBufferSize:        4096,
+		WriteBufferSize:       writeBufferSize,
+		ReadBufferSize:        readBufferSize,
 	}
 
 	// Enable HTTP/2 if configured
 	if cfg.HTTP.EnableHTTP2 {
 		if err := http2.ConfigureTransport(transport); err != nil {
-			log.Printf("Warning: Failed to configure HTTP/2: %v", err)
+			logger.Warn("Failed to configure HTTP/2", logging.Err(err))
 		}
 	}
 
-	// Create router if routes are configured
-	var rt *router.Router
-	if cfg.HTTP != nil && len(cfg.HTTP.Routes) > 0 {
-		rt = router.NewRouter(cfg.HTTP.Routes, pool)
-	}
+	// Idle connections to a backend that left the pool would otherwise linger until
+	// they time out
+	pool.Subscribe(func(event backend.PoolEvent) {
+		if event.Type == backend.BackendRemoved {
+			transport.CloseIdleConnections()
+		}
+	})
 
 	httpServer := &HTTPServer{
-		config:     cfg,
-		pool:       pool,
-		balancer:   balancer,
-		router:     rt,
-		transport:  transport,
-		ctx:        ctx,
-		cancelFunc: cancel,
+		config:            cfg,
+		pool:              pool,
+		balancer:          balancer,
+		router:            rt,
+		transport:         transport,
+		routeBalancers:    routeBalancers,
+		overflowBalancers: overflowBalancers,
+		termination:       termination,
+		tlsRoutes:         tlsRoutes,
+		spiffe:            spiffe,
+		backendTLS:        backendTLS,
+		acme:              acme,
+		checker:           checker,
+		security:          secManager,
+		trustedProxies:    proxies,
+		urlFilter:         urlFilter,
+		audit:             audit,
+		accessLog:         accessLog,
+		breakers:          breakers,
+		retries:           newRetryBudget(cfg),
+		hedger:            newHedger(cfg),
+		timer:             newUpstreamTimer(cfg),
+		capture:           logging.NewRequestCapture(apiKeyHeaders(cfg)...),
+		queue:             newRequestQueue(cfg),
+		shedder:           newLoadShedder(cfg),
+		bandwidth:         newBandwidthManager(cfg),
+		tracer:            tracer,
+		logger:            logger,
+		buffers:           newCopyBufferPool(cfg),
+		ctx:               ctx,
+		cancelFunc:        cancel,
+		websockets:        newConnTracker(),
 	}
 
 	// Create HTTP server with handlers
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", httpServer.handleRequest)
 
+	var handler http.Handler = mux
+	if tracer != nil {
+		handler = tracer.HTTPMiddleware(mux)
+	}
+	if accessLog != nil {
+		handler = accessLog.Middleware()(handler)
+	}
+
 	httpServer.server = &http.Server{
 		Addr:           cfg.Listen,
-		Handler:        mux,
+		Handler:        handler,
 		ReadTimeout:    cfg.Timeouts.Read,
 		WriteTimeout:   cfg.Timeouts.Write,
 		IdleTimeout:    cfg.Timeouts.Idle,
 		MaxHeaderBytes: 1 << 20, // 1MB
+		ConnContext:    withConn,
+	}
+
+	// Failed TLS handshakes are only reported to the error log
+	var errorLog io.Writer = logger.Writer(logging.WarnLevel)
+	if termination != nil && secManager != nil {
+		errorLog = handshakeErrorLog{security: secManager, next: errorLog}
 	}
+	httpServer.server.ErrorLog = log.New(errorLog, "", 0)
 
 	// Enable HTTP/2 on the server if configured
 	if cfg.HTTP.EnableHTTP2 {
@@ -139,6 +301,14 @@ func NewHTTPServer(cfg *config.Config) (*Server, error) {
 		config:          cfg,
 		pool:            pool,
 		balancer:        balancer,
+		checker:         checker,
+		discovery:       disc,
+		adaptive:        newAdaptiveWeights(cfg, checker, pool, logger),
+		security:        secManager,
+		audit:           audit,
+		breakers:        breakers,
+		redirect:        newHTTPSRedirect(cfg, acme, logger),
+		logger:          logger,
 		ctx:             ctx,
 		cancelFunc:      cancel,
 		httpServer:      httpServer,
@@ -152,65 +322,234 @@ func (h *HTTPServer) handleRequest(w http.ResponseWriter, r *http.Request) {
 	h.activeRequests.Add(1)
 	defer h.activeRequests.Add(-1)
 
+	// Record the request in full if a debug capture asks for it
+	w, r, finishCapture := h.captureRequest(w, r)
+	defer finishCapture()
+
+	// Reject traversal attempts and denied URLs before they can match a route
+	if h.urlFilter != nil {
+		switch h.urlFilter.Check(r.URL) {
+		case security.URLMalformed:
+			h.totalErrors.Add(1)
+			h.auditRequest(r, logging.AuditWAF, "Malformed URL", nil)
+			h.recordSecurityEvent(r, security.BanEventWAF)
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		case security.URLDenied:
+			h.totalErrors.Add(1)
+			h.auditRequest(r, logging.AuditWAF, "URL matches a deny pattern", nil)
+			h.recordSecurityEvent(r, security.BanEventWAF)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Requests in TLS early data can be replayed, so only idempotent ones are proxied
+	if !checkEarlyData(w, r) {
+		h.totalErrors.Add(1)
+		return
+	}
+
+	// Match the route; its backends are selected by the route's own load balancer
+	var route *router.RouteEntry
+	if h.router != nil {
+		route = h.router.MatchRoute(r)
+	}
+
+	// Classify the request so rate limiting, load shedding and queueing favor
+	// interactive over batch traffic
+	priority := h.requestPriority(r, route)
+	r = r.WithContext(withPriority(r.Context(), priority))
+
+	// Apply blocklist and rate limit; each priority class has its own rate limit
+	if h.security != nil {
+		ip := h.trustedClientIP(r)
+		key, tier := h.rateLimitKey(r), h.rateLimitTier(r)
+		if allowed, reason := h.security.AllowRequestInTier(ip, key, priority.String(), tier); !allowed {
+			h.totalErrors.Add(1)
+			status := http.StatusTooManyRequests
+			if h.security.Blocklist().IsBlocked(ip) {
+				status = http.StatusForbidden
+				h.auditRequest(r, logging.AuditBlocked, reason, nil)
+			} else {
+				metrics.IncRateLimitedRequests(ip)
+				h.auditRequest(r, logging.AuditRateLimited, reason, map[string]string{
+					"key":   key,
+					"class": priority.String(),
+					"tier":  tier,
+				})
+			}
+			http.Error(w, reason, status)
+			return
+		}
+
+		// Limit the requests a client has in flight, however many connections carry them
+		if !h.security.AcquireRequest(ip) {
+			h.totalErrors.Add(1)
+			metrics.IncRateLimitedRequests(ip)
+			h.auditRequest(r, logging.AuditRateLimited, "Too many concurrent requests", nil)
+			http.Error(w, "Too many concurrent requests", http.StatusTooManyRequests)
+			return
+		}
+		defer h.security.ReleaseRequest(ip)
+	}
+
+	// ACME challenges go to their backend regardless of routes
+	if h.acme != nil && isACMEChallenge(r) {
+		h.acme.ServeHTTP(w, r)
+		return
+	}
+
+	// Requests no route matched are handled as no_route_match says
+	if h.router != nil && route == nil {
+		switch h.config.HTTP.NoRouteMatch {
+		case "404":
+			http.NotFound(w, r)
+			return
+		case "503":
+			http.Error(w, "No route available", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if route != nil {
+		r = r.WithContext(router.NewContext(r.Context(), route))
+	}
+
+	// Shed load while the proxy itself is saturated, low-priority requests first
+	if h.shedder != nil && !h.shedder.Allow(priority) {
+		h.totalErrors.Add(1)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Service overloaded", http.StatusServiceUnavailable)
+		return
+	}
+
 	// Check if this is a WebSocket upgrade request
 	if h.config.HTTP.EnableWebSocket && isWebSocketRequest(r) {
 		h.handleWebSocket(w, r)
 		return
 	}
 
-	// Select backend pool (use router if configured, otherwise default pool)
-	// Note: For now, we use the global load balancer.
-	// TODO: In future, create per-route load balancers for better isolation
-	if h.router != nil {
-		_ = h.router.Match(r) // Route matching for future enhancement
+	// Bound the request, including all retries, by the route or global request timeout
+	if timeout := h.requestTimeout(route); timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
 	}
 
-	// Select a backend using load balancer
-	var selectedBackend *backend.Backend
-
-	// Check if the balancer supports key-based selection
 	clientIP := getClientIP(r)
-	switch balancer := h.balancer.(type) {
-	case interface{ SelectWithKey(string) *backend.Backend }:
-		// Use consistent hash with client IP or custom key
-		selectedBackend = balancer.SelectWithKey(clientIP)
-	case interface{ SelectWithClientIP(string) *backend.Backend }:
-		// Use session affinity with client IP
-		selectedBackend = balancer.SelectWithClientIP(clientIP)
-	default:
-		// Use standard selection
-		selectedBackend = h.balancer.Select()
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+	if h.retries != nil {
+		h.retries.RecordRequest()
 	}
 
-	if selectedBackend == nil {
+	// Each attempt goes through the load balancer again, so retries can land on another backend
+	policy, rules := h.retryPolicy(r, route)
+	hedge := h.hedger != nil && isHedgeable(r)
+	err := resilience.RetryWithContext(r.Context(), func(ctx context.Context) error {
+		if hedge {
+			return h.hedgedAttempt(sw, r, clientIP, rules)
+		}
+		return h.proxyAttempt(sw, r, clientIP, rules)
+	}, policy)
+
+	if err != nil {
+		// Attempts only fail before anything is written to the client
 		h.totalErrors.Add(1)
-		http.Error(w, "No healthy backend available", http.StatusServiceUnavailable)
-		log.Printf("No healthy backend available for request: %s %s", r.Method, r.URL.Path)
-		return
+		if cr := logging.CaptureFromContext(r.Context()); cr != nil {
+			cr.SetError(err)
+		}
+		var respErr *responseError
+		switch {
+		case errors.As(err, &respErr):
+			// The last attempt's response matched retry_on but was not retried
+			respErr.write(sw)
+		case errors.Is(err, errNoBackend):
+			http.Error(sw, "No healthy backend available", http.StatusServiceUnavailable)
+			h.logger.ErrorContext(r.Context(), "No healthy backend available",
+				logging.String("method", r.Method), logging.String("path", r.URL.Path))
+		case errors.Is(err, errBackendsFull), errors.Is(err, errQueueFull):
+			h.writeBusy(sw)
+		case errors.Is(err, resilience.ErrCircuitOpen), errors.Is(err, resilience.ErrTooManyRequests):
+			http.Error(sw, "Service unavailable", http.StatusServiceUnavailable)
+		case errors.Is(err, errPerTryTimeout), errors.Is(err, context.DeadlineExceeded):
+			http.Error(sw, "Backend timeout", http.StatusGatewayTimeout)
+		default:
+			http.Error(sw, "Backend error", http.StatusBadGateway)
+		}
 	}
 
-	// Track connection for this backend
-	selectedBackend.IncrementConnections()
-	defer selectedBackend.DecrementConnections()
+	// Clients that keep failing authentication are banned like those probing for URLs
+	if sw.status == http.StatusUnauthorized {
+		h.recordSecurityEvent(r, security.BanEventUnauthorized)
+	}
+}
 
-	// Build target URL
+// recordSecurityEvent counts a suspicious event towards banning the client
+func (h *HTTPServer) recordSecurityEvent(r *http.Request, event string) {
+	if h.security != nil {
+		h.security.RecordEvent(h.trustedClientIP(r), event)
+	}
+}
+
+// proxyAttempt forwards the request to one backend chosen by the load balancer
+// It returns an error without writing to the client if the backend could not be reached
+// or responded with a status the retry rules hold back
+func (h *HTTPServer) proxyAttempt(sw *statusWriter, r *http.Request, clientIP string, rules *retryRules) error {
+	selectedBackend, err := h.acquireBackend(r, clientIP)
+	if err != nil {
+		return err
+	}
+	return h.proxyTo(sw, r, clientIP, selectedBackend, rules)
+}
+
+// proxyTo forwards the request to a backend whose connection was taken by acquireBackend
+func (h *HTTPServer) proxyTo(sw *statusWriter, r *http.Request, clientIP string, selectedBackend *backend.Backend, rules *retryRules) error {
+	defer h.releaseBackend(selectedBackend)
+
+	// Build target URL (the request path and query are appended by the director)
 	targetURL := &url.URL{
-		Scheme:   "http",
-		Host:     selectedBackend.Address(),
-		Path:     r.URL.Path,
-		RawQuery: r.URL.RawQuery,
+		Scheme: "http",
+		Host:   selectedBackend.Address(),
+	}
+	if h.backendTLS != nil {
+		targetURL.Scheme = "https"
 	}
 
-	log.Printf("Proxying %s %s from %s to backend: %s", r.Method, r.URL.Path, clientIP, selectedBackend.Address())
+	if h.logger.Enabled(logging.DebugLevel) {
+		fields := []logging.Field{
+			logging.String("method", r.Method), logging.String("path", r.URL.Path),
+			logging.String("client_ip", clientIP), logging.String("backend", selectedBackend.Address()),
+		}
+		if ja3 := requestJA3(r); ja3 != "" {
+			fields = append(fields, logging.String("ja3", ja3))
+		}
+		h.logger.DebugContext(r.Context(), "Proxying request", fields...)
+	}
+
+	// Add the route, backend and client to the request's span
+	if h.tracer != nil {
+		var routeName string
+		if route := router.FromContext(r.Context()); route != nil {
+			routeName = route.Name()
+		}
+		h.tracer.SetRequestAttributes(r.Context(), routeName, selectedBackend.Name(), clientIP)
+	}
 
 	// Create reverse proxy
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
 	proxy.Transport = h.transport
+	var proxyErr error
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		h.totalErrors.Add(1)
-		log.Printf("Backend error for %s: %v", selectedBackend.Address(), err)
+		// The response is written by the caller once no retries are left
+		proxyErr = err
+		var respErr *responseError
+		if r.Context().Err() != nil || errors.As(err, &respErr) {
+			// Cancelled by the client or a faster hedged attempt, or a response held back for retry
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "Backend error", logging.String("backend", selectedBackend.Address()), logging.Err(err))
 		selectedBackend.MarkUnhealthy()
-		http.Error(w, "Backend error", http.StatusBadGateway)
 	}
 
 	// Modify request headers
@@ -222,44 +561,209 @@ func (h *HTTPServer) handleRequest(w http.ResponseWriter, r *http.Request) {
 		req.Header.Set("X-Forwarded-Host", r.Host)
 		req.Header.Set("X-Forwarded-Proto", getScheme(r))
 		req.Header.Set("X-Real-IP", clientIP)
+
+		// Apply the route's Host header and path rewrites
+		if route := router.FromContext(req.Context()); route != nil {
+			rewriteHost(req, route.Config())
+			rewritePath(req, route.Config())
+		}
+
+		// Propagate trace context and baggage to the backend
+		if h.tracer != nil {
+			tracing.InjectTraceContext(req.Context(), req.Header)
+		}
+
+		// Tell the backend how long the proxy will wait
+		if h.config.Timeouts.Propagate {
+			var perTry time.Duration
+			if rules != nil {
+				perTry = rules.perTryTimeout
+			}
+			propagateDeadline(req, perTry)
+		}
+	}
+
+	// Bound this attempt by the per-try timeout until its response headers arrive
+	attemptReq := r
+	var perTry *time.Timer
+	if rules != nil && rules.perTryTimeout > 0 {
+		ctx, cancel := context.WithCancelCause(r.Context())
+		defer cancel(nil)
+		perTry = time.AfterFunc(rules.perTryTimeout, func() { cancel(errPerTryTimeout) })
+		defer perTry.Stop()
+		attemptReq = r.WithContext(ctx)
+	}
+
+	// Hold back responses that retry_on says to retry
+	if rules != nil {
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			err := holdRetryableResponse(resp, rules)
+			if perTry != nil {
+				perTry.Stop()
+			}
+			return err
+		}
+	}
+
+	// Time the phases of the request to the backend
+	captured := logging.CaptureFromContext(r.Context()) != nil
+	var trace *upstreamTrace
+	if h.timer != nil || captured {
+		attemptReq, trace = traceUpstream(attemptReq)
 	}
+	var timing *logging.UpstreamTiming
 
-	// Serve the request
-	proxy.ServeHTTP(w, r)
+	// Serve the request through the backend's circuit breaker
+	start := time.Now()
+	var err error
+	defer func() {
+		status := sw.status
+		var respErr *responseError
+		switch {
+		case errors.As(err, &respErr):
+			status = respErr.status
+		case errors.Is(err, resilience.ErrCircuitOpen), errors.Is(err, resilience.ErrTooManyRequests):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, errPerTryTimeout):
+			status = http.StatusGatewayTimeout
+		case err != nil:
+			status = http.StatusBadGateway
+		}
+		metrics.RecordRequestContext(r.Context(), selectedBackend.Name(), r.Method, strconv.Itoa(status), time.Since(start))
+
+		if captured {
+			attempt := logging.CaptureAttempt{
+				Backend:  selectedBackend.Name(),
+				Address:  selectedBackend.Address(),
+				Status:   status,
+				Duration: time.Since(start),
+				Upstream: timing,
+			}
+			if err != nil {
+				attempt.Error = err.Error()
+			}
+			captureAttempt(r, attempt)
+		}
+	}()
+
+	serve := func() error {
+		proxy.ServeHTTP(sw, attemptReq)
+		if trace != nil {
+			t := trace.finish()
+			timing = &t
+		}
+		if proxyErr != nil && errors.Is(context.Cause(attemptReq.Context()), errPerTryTimeout) {
+			return errPerTryTimeout
+		}
+		return proxyErr
+	}
+
+	if breaker := h.breakers.get(selectedBackend.Name()); breaker != nil {
+		err = breaker.Execute(serve)
+		if err == resilience.ErrCircuitOpen || err == resilience.ErrTooManyRequests {
+			return err
+		}
+	} else {
+		err = serve()
+	}
+
+	if r.Context().Err() != nil {
+		return err
+	}
+
+	var logged *logging.UpstreamTiming
+	if h.timer != nil && timing != nil {
+		logged = h.timer.record(selectedBackend.Name(), *timing)
+	}
+	logging.SetUpstream(r.Context(), selectedBackend.Name(), logged)
+
+	if h.checker != nil {
+		h.checker.RecordRequest(selectedBackend, err == nil, time.Since(start))
+	}
+	if h.hedger != nil && err == nil {
+		h.hedger.observe(time.Since(start))
+	}
+	if observer, ok := h.balancerFor(r).(lb.LatencyObserver); ok && err == nil {
+		observer.Observe(selectedBackend, time.Since(start))
+	}
+
+	return err
 }
 
-// handleWebSocket handles WebSocket upgrade and proxying
-func (h *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// Select backend
-	var selectedBackend *backend.Backend
-	clientIP := getClientIP(r)
+// selectBackend selects a backend for the request using the load balancer
+func (h *HTTPServer) selectBackend(r *http.Request, clientIP string) *backend.Backend {
+	return h.selectFrom(h.balancerFor(r), r, clientIP)
+}
 
-	switch balancer := h.balancer.(type) {
+// selectFrom selects a backend for the request using the given load balancer
+func (h *HTTPServer) selectFrom(balancer lb.LoadBalancer, r *http.Request, clientIP string) *backend.Backend {
+	// Check if the balancer supports key-based selection
+	switch balancer := balancer.(type) {
 	case interface{ SelectWithKey(string) *backend.Backend }:
-		selectedBackend = balancer.SelectWithKey(clientIP)
+		// Use consistent hash with client IP or custom key
+		return balancer.SelectWithKey(h.hashKey(r, clientIP))
 	case interface{ SelectWithClientIP(string) *backend.Backend }:
-		selectedBackend = balancer.SelectWithClientIP(clientIP)
+		// Use session affinity with client IP
+		return balancer.SelectWithClientIP(clientIP)
 	default:
-		selectedBackend = h.balancer.Select()
+		// Use standard selection
+		return balancer.Select()
 	}
+}
 
-	if selectedBackend == nil {
+// balancerFor returns the load balancer for the request's route. Routes without backends
+// of their own use the TLS route's backends for TLS requests, or the global load balancer
+func (h *HTTPServer) balancerFor(r *http.Request) lb.LoadBalancer {
+	if route := router.FromContext(r.Context()); route != nil {
+		if balancer, ok := h.routeBalancers[route]; ok {
+			return balancer
+		}
+	}
+	if r.TLS != nil {
+		if balancer := h.tlsRoutes.balancerFor(r.TLS); balancer != nil {
+			return balancer
+		}
+	}
+	return h.balancer
+}
+
+// handleWebSocket handles WebSocket upgrade and proxying
+func (h *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Select backend
+	clientIP := getClientIP(r)
+	selectedBackend, err := h.acquireBackend(r, clientIP)
+	switch {
+	case errors.Is(err, errBackendsFull), errors.Is(err, errQueueFull):
 		h.totalErrors.Add(1)
+		h.writeBusy(w)
+		return
+	case err != nil:
+		h.totalErrors.Add(1)
+		metrics.IncWebSocketUpgradeFailures("", "no_backend")
 		http.Error(w, "No healthy backend available", http.StatusServiceUnavailable)
 		return
 	}
+	defer h.releaseBackend(selectedBackend)
 
-	selectedBackend.IncrementConnections()
-	defer selectedBackend.DecrementConnections()
-
-	log.Printf("WebSocket upgrade: %s -> %s", clientIP, selectedBackend.Address())
+	h.logger.DebugContext(r.Context(), "WebSocket upgrade",
+		logging.String("client_ip", clientIP), logging.String("backend", selectedBackend.Address()))
 
 	// Dial backend
-	backendConn, err := net.DialTimeout("tcp", selectedBackend.Address(), h.config.Timeouts.Connect)
+	backendConn, err := newDialer(h.config).DialContext(r.Context(), "tcp", selectedBackend.Address())
+	if err == nil && h.backendTLS != nil {
+		var tlsConn net.Conn
+		tlsConn, err = backendTLSClient(r.Context(), backendConn, selectedBackend.Address(), h.backendTLS)
+		if err != nil {
+			backendConn.Close()
+		}
+		backendConn = tlsConn
+	}
 	if err != nil {
 		h.totalErrors.Add(1)
-		log.Printf("Failed to connect to backend for WebSocket: %v", err)
+		h.logger.ErrorContext(r.Context(), "Failed to connect to backend for WebSocket",
+			logging.String("backend", selectedBackend.Address()), logging.Err(err))
 		selectedBackend.MarkUnhealthy()
+		metrics.IncWebSocketUpgradeFailures(selectedBackend.Name(), "connect")
 		http.Error(w, "Failed to connect to backend", http.StatusBadGateway)
 		return
 	}
@@ -269,6 +773,7 @@ func (h *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	hijacker, ok := w.(http.Hijacker)
 	if !ok {
 		h.totalErrors.Add(1)
+		metrics.IncWebSocketUpgradeFailures(selectedBackend.Name(), "hijack")
 		http.Error(w, "WebSocket hijacking not supported", http.StatusInternalServerError)
 		return
 	}
@@ -276,7 +781,8 @@ func (h *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	clientConn, _, err := hijacker.Hijack()
 	if err != nil {
 		h.totalErrors.Add(1)
-		log.Printf("Failed to hijack connection: %v", err)
+		h.logger.ErrorContext(r.Context(), "Failed to hijack connection", logging.Err(err))
+		metrics.IncWebSocketUpgradeFailures(selectedBackend.Name(), "hijack")
 		http.Error(w, "Failed to hijack connection", http.StatusInternalServerError)
 		return
 	}
@@ -285,66 +791,147 @@ func (h *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Forward the upgrade request to backend
 	if err := r.Write(backendConn); err != nil {
 		h.totalErrors.Add(1)
-		log.Printf("Failed to write upgrade request: %v", err)
+		h.logger.ErrorContext(r.Context(), "Failed to write upgrade request", logging.Err(err))
+		metrics.IncWebSocketUpgradeFailures(selectedBackend.Name(), "handshake")
+		return
+	}
+
+	// Check that the backend accepted the upgrade; a refusal is still relayed to the client
+	status, backendConn, err := websocketUpgradeStatus(backendConn, h.config.Timeouts.Read)
+	if err != nil {
+		h.totalErrors.Add(1)
+		h.logger.ErrorContext(r.Context(), "Failed to read upgrade response",
+			logging.String("backend", selectedBackend.Address()), logging.Err(err))
+		metrics.IncWebSocketUpgradeFailures(selectedBackend.Name(), "handshake")
 		return
 	}
+	upgraded := status == http.StatusSwitchingProtocols
+	if !upgraded {
+		metrics.IncWebSocketUpgradeFailures(selectedBackend.Name(), "rejected")
+	}
+
+	// Hijacked connections are not closed by http.Server.Shutdown, so track them for draining
+	untrack := h.websockets.add(nil, func() {
+		clientConn.Close()
+		backendConn.Close()
+	})
+	defer untrack()
+
+	// Apply bandwidth limits
+	var upload, download []*byteRateLimiter
+	if h.bandwidth != nil {
+		upload, download = h.bandwidth.acquire(selectedBackend.Name(), clientIP)
+		defer h.bandwidth.release(clientIP)
+	}
 
 	// Proxy WebSocket data bidirectionally
-	h.proxyWebSocket(clientConn, backendConn)
+	if !upgraded {
+		h.proxyWebSocket(clientConn, backendConn, upload, download)
+		return
+	}
+	start := time.Now()
+	metrics.IncWebSocketConnectionsActive(selectedBackend.Name())
+	received, sent := h.proxyWebSocket(clientConn, backendConn, upload, download)
+	metrics.RecordWebSocketConnection(selectedBackend.Name(), time.Since(start), received, sent)
 }
 
-// proxyWebSocket proxies WebSocket data between client and backend
-func (h *HTTPServer) proxyWebSocket(clientConn, backendConn net.Conn) {
+// proxyWebSocket proxies WebSocket data between client and backend, returning the bytes
+// received from and sent to the client
+func (h *HTTPServer) proxyWebSocket(clientConn, backendConn net.Conn, upload, download []*byteRateLimiter) (received, sent int64) {
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	// Client -> Backend
 	go func() {
 		defer wg.Done()
-		n, err := io.Copy(backendConn, clientConn)
+		buf := h.buffers.Get()
+		defer h.buffers.Put(buf)
+		n, err := io.CopyBuffer(newThrottledWriter(h.ctx, backendConn, upload), clientConn, buf)
 		if err != nil && err != io.EOF {
-			log.Printf("Error copying WebSocket client -> backend: %v", err)
+			h.logger.Warn("Error copying WebSocket client -> backend", logging.Err(err))
 		}
 		h.totalBytesSent.Add(n)
+		received = n
 	}()
 
 	// Backend -> Client
 	go func() {
 		defer wg.Done()
-		n, err := io.Copy(clientConn, backendConn)
+		buf := h.buffers.Get()
+		defer h.buffers.Put(buf)
+		n, err := io.CopyBuffer(newThrottledWriter(h.ctx, clientConn, download), backendConn, buf)
 		if err != nil && err != io.EOF {
-			log.Printf("Error copying WebSocket backend -> client: %v", err)
+			h.logger.Warn("Error copying WebSocket backend -> client", logging.Err(err))
 		}
 		h.totalBytesReceived.Add(n)
+		sent = n
 	}()
 
 	wg.Wait()
+	return received, sent
 }
 
 // Start starts the HTTP server
 func (h *HTTPServer) Start() error {
+	listener, limiter, err := listen(h.config)
+	if err != nil {
+		return err
+	}
+	if h.termination != nil {
+		listener = h.termination.listener(listener)
+		h.termination.start(h.ctx, &h.wg)
+	}
+	if h.spiffe != nil {
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.spiffe.Run(h.ctx)
+		}()
+	}
+	if h.shedder != nil {
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.shedder.Run(h.ctx)
+		}()
+	}
+	h.listener = listener
+	h.limiter = limiter
+
+	h.serve(listener)
+	return nil
+}
+
+// serve serves HTTP on the listener until the server is shut down
+func (h *HTTPServer) serve(listener net.Listener) {
 	h.wg.Add(1)
 	go func() {
 		defer h.wg.Done()
-		if err := h.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("HTTP server error: %v", err)
+		if err := h.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			h.logger.Error("HTTP server error", logging.Err(err))
 		}
 	}()
-	return nil
 }
 
 // Shutdown gracefully shuts down the HTTP server
 func (h *HTTPServer) Shutdown() error {
-	log.Println("Shutting down HTTP proxy server...")
+	h.logger.Info("Shutting down HTTP proxy server")
 
 	h.cancelFunc()
 
-	// Shutdown HTTP server with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Stop accepting, close idle keep-alive connections and wait for active requests
+	timeout := drainTimeout(h.config)
+	h.logger.Info("Draining connections", logging.Duration("timeout", timeout))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	if err := h.server.Shutdown(ctx); err != nil {
-		log.Printf("Error during HTTP server shutdown: %v", err)
+		h.logger.Warn("Drain timeout exceeded, closing remaining connections", logging.Duration("timeout", timeout), logging.Err(err))
+		h.server.Close()
+	}
+	// WebSocket streams have no request boundary to wait for, so they are closed once HTTP traffic has drained
+	if n := h.websockets.closeAll(); n > 0 {
+		h.logger.Info("Closed WebSocket connections", logging.Int("connections", n))
 	}
 
 	// Close transport
@@ -353,35 +940,134 @@ func (h *HTTPServer) Shutdown() error {
 	// Wait for all goroutines
 	h.wg.Wait()
 
+	// Flush pending spans (the drain context may already have expired)
+	if h.tracer != nil {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFlush()
+		if err := h.tracer.Close(flushCtx); err != nil {
+			h.logger.Error("Error shutting down tracer", logging.Err(err))
+		}
+	}
+
+	// Flush the access log, which may be shipped to a remote collector
+	if err := h.accessLog.Close(); err != nil {
+		h.logger.Error("Error closing access log", logging.Err(err))
+	}
+
 	// Print final statistics
-	log.Printf("Final statistics:")
-	log.Printf("  Total requests: %d", h.totalRequests.Load())
-	log.Printf("  Total errors: %d", h.totalErrors.Load())
-	log.Printf("  Bytes received: %d", h.totalBytesReceived.Load())
-	log.Printf("  Bytes sent: %d", h.totalBytesSent.Load())
+	h.logger.Info("Final statistics",
+		logging.Int64("total_requests", h.totalRequests.Load()),
+		logging.Int64("total_errors", h.totalErrors.Load()),
+		logging.Int64("bytes_received", h.totalBytesReceived.Load()),
+		logging.Int64("bytes_sent", h.totalBytesSent.Load()))
 
 	return nil
 }
 
 // Stats returns current HTTP server statistics
 func (h *HTTPServer) Stats() map[string]interface{} {
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"total_requests":       h.totalRequests.Load(),
 		"active_requests":      h.activeRequests.Load(),
 		"total_errors":         h.totalErrors.Load(),
 		"total_bytes_received": h.totalBytesReceived.Load(),
 		"total_bytes_sent":     h.totalBytesSent.Load(),
 	}
+	if h.limiter != nil {
+		stats["connection_limit"] = h.limiter.Stats()
+	}
+	if h.retries != nil {
+		requests, retries, ratio := h.retries.GetStats()
+		stats["retry_budget"] = map[string]interface{}{
+			"requests": requests,
+			"retries":  retries,
+			"ratio":    ratio,
+		}
+	}
+	if h.hedger != nil {
+		stats["hedging"] = h.hedger.Stats()
+	}
+	if h.queue != nil {
+		stats["request_queue"] = h.queue.Stats()
+	}
+	if h.shedder != nil {
+		stats["load_shedding"] = h.shedder.Stats()
+	}
+	if len(h.overflowBalancers) > 0 {
+		stats["overflow_requests"] = h.overflowRequests.Load()
+	}
+	if h.urlFilter != nil {
+		stats["url_filter"] = h.urlFilter.Stats()
+	}
+
+	return stats
+}
+
+// statusWriter wraps http.ResponseWriter to capture the status code
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	sw.status = code
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher for streaming responses
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
 // Helper functions
 
+// websocketUpgradeStatus reads ahead the status code of a backend's response to an
+// upgrade request, waiting at most timeout (0 waits indefinitely)
+// The returned connection still yields the whole response.
+func websocketUpgradeStatus(backendConn net.Conn, timeout time.Duration) (int, net.Conn, error) {
+	peeked := &peekedConn{Conn: backendConn, reader: bufio.NewReader(backendConn)}
+	line, err := peekWithTimeout(peeked, timeout, len("HTTP/1.1 101"))
+	if err != nil {
+		return 0, peeked, err
+	}
+	if !bytes.HasPrefix(line, []byte("HTTP/")) || line[8] != ' ' {
+		return 0, peeked, fmt.Errorf("malformed upgrade response: %q", line)
+	}
+	status, err := strconv.Atoi(string(line[9:]))
+	if err != nil {
+		return 0, peeked, fmt.Errorf("malformed upgrade response: %q", line)
+	}
+	return status, peeked, nil
+}
+
 // isWebSocketRequest checks if the request is a WebSocket upgrade
 func isWebSocketRequest(r *http.Request) bool {
 	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket" &&
 		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
 }
 
+// hashKey extracts the consistent hashing key for a request according to load_balancer.hash_key
+// Supported keys are "source-ip", "header:<name>" and "query:<name>"; the client IP is used
+// when the header or query parameter is missing
+func (h *HTTPServer) hashKey(r *http.Request, clientIP string) string {
+	key := h.config.LoadBalancer.HashKey
+
+	var value string
+	switch {
+	case strings.HasPrefix(key, "header:"):
+		value = r.Header.Get(strings.TrimPrefix(key, "header:"))
+	case strings.HasPrefix(key, "query:"):
+		value = r.URL.Query().Get(strings.TrimPrefix(key, "query:"))
+	}
+
+	if value == "" {
+		return clientIP
+	}
+	return value
+}
+
 // getClientIP extracts the client IP from the request
 func getClientIP(r *http.Request) string {
 	// Check X-Forwarded-For header
@@ -1,16 +1,24 @@
 package proxy
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
 )
 
 // TestHTTPProxyBasic tests basic HTTP proxying
@@ -54,7 +62,7 @@ func TestHTTPProxyBasic(t *testing.T) {
 	}
 
 	// Create and start proxy server
-	server, err := NewHTTPServer(cfg)
+	server, err := NewHTTPServer(cfg, nil)
 	if err != nil {
 		t.Fatalf("Failed to create HTTP server: %v", err)
 	}
@@ -165,7 +173,7 @@ func TestHTTPProxyLoadBalancing(t *testing.T) {
 	}
 
 	// Create and start proxy server
-	server, err := NewHTTPServer(cfg)
+	server, err := NewHTTPServer(cfg, nil)
 	if err != nil {
 		t.Fatalf("Failed to create HTTP server: %v", err)
 	}
@@ -259,7 +267,7 @@ func TestHTTPProxyHeaders(t *testing.T) {
 	}
 
 	// Create and start proxy server
-	server, err := NewHTTPServer(cfg)
+	server, err := NewHTTPServer(cfg, nil)
 	if err != nil {
 		t.Fatalf("Failed to create HTTP server: %v", err)
 	}
@@ -324,7 +332,7 @@ func TestHTTPProxyShutdown(t *testing.T) {
 	}
 
 	// Create and start proxy server
-	server, err := NewHTTPServer(cfg)
+	server, err := NewHTTPServer(cfg, nil)
 	if err != nil {
 		t.Fatalf("Failed to create HTTP server: %v", err)
 	}
@@ -371,6 +379,607 @@ func TestHTTPProxyShutdown(t *testing.T) {
 }
 
 // TestIsWebSocketRequest tests WebSocket detection
+func TestHTTPRouteBackends(t *testing.T) {
+	newNamedServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	api := newNamedServer("api")
+	defer api.Close()
+	web := newNamedServer("web")
+	defer web.Close()
+
+	newServer := func(noRouteMatch string, routes ...config.Route) *HTTPServer {
+		server, err := NewHTTPServer(&config.Config{
+			Mode: "http",
+			Backends: []config.Backend{
+				{Name: "api", Address: strings.TrimPrefix(api.URL, "http://"), Weight: 1},
+				{Name: "web", Address: strings.TrimPrefix(web.URL, "http://"), Weight: 1},
+			},
+			LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+			HTTP:         &config.HTTPConfig{Routes: routes, NoRouteMatch: noRouteMatch},
+			Timeouts:     config.TimeoutConfig{Connect: time.Second},
+		}, nil)
+		if err != nil {
+			t.Fatalf("Failed to create HTTP server: %v", err)
+		}
+		return server.httpServer
+	}
+	get := func(h *HTTPServer, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// Matched routes only use their own backends
+	h := newServer("", config.Route{Name: "api", PathPrefix: "/api/", Backends: []string{"api"}})
+	for i := 0; i < 4; i++ {
+		if rec := get(h, "/api/users"); rec.Body.String() != "api" {
+			t.Fatalf("Expected api route to be served by api backend, got %q", rec.Body.String())
+		}
+	}
+
+	// The default route serves unmatched requests
+	h = newServer("404",
+		config.Route{Name: "api", PathPrefix: "/api/", Backends: []string{"api"}},
+		config.Route{Name: "web", Default: true, Backends: []string{"web"}},
+	)
+	if rec := get(h, "/index.html"); rec.Body.String() != "web" {
+		t.Errorf("Expected default route to be served by web backend, got %q", rec.Body.String())
+	}
+
+	// Without a default route, no_route_match decides
+	tests := []struct {
+		noRouteMatch string
+		want         int
+	}{
+		{"", http.StatusOK},
+		{"pool", http.StatusOK},
+		{"404", http.StatusNotFound},
+		{"503", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		h := newServer(tt.noRouteMatch, config.Route{Name: "api", PathPrefix: "/api/", Backends: []string{"api"}})
+		if rec := get(h, "/index.html"); rec.Code != tt.want {
+			t.Errorf("no_route_match %q: expected %d, got %d", tt.noRouteMatch, tt.want, rec.Code)
+		}
+	}
+}
+
+func TestHTTPLoadSheddingByRoutePriority(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP: &config.HTTPConfig{Routes: []config.Route{
+			{Name: "batch", PathPrefix: "/batch/", Backends: []string{"b1"}, PriorityClass: "low"},
+			{Name: "api", PathPrefix: "/api/", Backends: []string{"b1"}},
+		}},
+		Resilience: &config.ResilienceConfig{
+			// Any scheduling delay counts as saturation, so the fraction settles at its maximum
+			LoadShedding: &config.LoadSheddingConfig{
+				Enabled: true, Interval: time.Millisecond, MaxLag: time.Nanosecond, Step: 0.5, MaxFraction: 0.5,
+			},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.shedder.Run(ctx)
+	waitFor(t, func() bool { return h.shedder.Fraction() == 0.5 })
+
+	// At half, low-priority routes are shed entirely and normal ones not at all
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/batch/job", nil))
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+			t.Fatalf("Expected low-priority request to be shed with 503 and Retry-After, got %d", rec.Code)
+		}
+
+		rec = httptest.NewRecorder()
+		h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected normal-priority request to be served, got %d", rec.Code)
+		}
+	}
+}
+
+func TestHTTPURLFilter(t *testing.T) {
+	var served atomic.Int32
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		w.Write([]byte("ok"))
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Security: &config.SecurityConfig{
+			URLFilter: &config.URLFilterConfig{Enabled: true, DenyPatterns: []string{`^/internal/`}},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/static/app.js", http.StatusOK},
+		{"/static/%2e%2e/%2e%2e/etc/passwd", http.StatusBadRequest},
+		{"/internal/metrics", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("Expected %d for %s, got %d", tt.want, tt.target, rec.Code)
+		}
+	}
+
+	if n := served.Load(); n != 1 {
+		t.Errorf("Expected only the allowed request to reach the backend, got %d", n)
+	}
+}
+
+func TestHTTPAutoBanUnauthorized(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Security: &config.SecurityConfig{
+			AutoBan: &config.AutoBanConfig{
+				Enabled:    true,
+				Events:     []string{"unauthorized"},
+				Threshold:  2,
+				FindTime:   time.Hour,
+				BanTime:    time.Minute,
+				MaxBanTime: time.Hour,
+			},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	// Two failed logins get the client banned
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusForbidden} {
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, httptest.NewRequest(http.MethodPost, "/login", nil))
+		if rec.Code != want {
+			t.Errorf("Expected %d for request %d, got %d", want, i+1, rec.Code)
+		}
+	}
+}
+
+func TestHTTPServerLogger(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backendServer.Close()
+
+	backendAddr := strings.TrimPrefix(backendServer.URL, "http://")
+
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.Config{
+		Level:  logging.DebugLevel,
+		Format: logging.JSONFormat,
+		Output: &buf,
+	})
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: backendAddr, Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Security:     &config.SecurityConfig{},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+	}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	h.handleRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	h.security.BlockIP("203.0.113.7", time.Minute)
+
+	// Entries from the proxy and the security manager both go to the injected logger
+	messages := make(map[string]map[string]interface{})
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %q", line)
+		}
+		messages[entry["msg"].(string)] = entry
+	}
+
+	if entry, ok := messages["Proxying request"]; !ok || entry["backend"] != backendAddr {
+		t.Errorf("Expected debug entry for proxied request, got %v", messages)
+	}
+	if entry, ok := messages["Blocked IP"]; !ok || entry["component"] != "security" || entry["ip"] != "203.0.113.7" {
+		t.Errorf("Expected security entry for blocked IP, got %v", messages)
+	}
+}
+
+func TestHTTPTracingZipkin(t *testing.T) {
+	spans := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		spans <- string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer collector.Close()
+
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Tracing: &config.TracingConfig{
+			Enabled:     true,
+			ServiceName: "edge",
+			Exporter:    "zipkin",
+			Endpoint:    collector.URL + "/api/v2/spans",
+			SampleRate:  1,
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	h.server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if err := h.tracer.Close(context.Background()); err != nil {
+		t.Fatalf("Failed to flush spans: %v", err)
+	}
+
+	select {
+	case body := <-spans:
+		if !strings.Contains(body, `"serviceName":"edge"`) || !strings.Contains(body, `"name":"get /orders"`) {
+			t.Errorf("Unexpected Zipkin spans: %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected spans to be sent to the Zipkin collector")
+	}
+}
+
+func TestHTTPTracingSpanAttributes(t *testing.T) {
+	spans := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		spans <- string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer collector.Close()
+
+	var backendBaggage string
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendBaggage = r.Header.Get("Baggage")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Tracing: &config.TracingConfig{
+			Enabled:        true,
+			ServiceName:    "edge",
+			Exporter:       "zipkin",
+			Endpoint:       collector.URL + "/api/v2/spans",
+			SampleRate:     1,
+			SpanAttributes: []string{"backend", "client_ip", "header:X-Tenant-ID", "baggage:plan"},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.RemoteAddr = "203.0.113.42:5000"
+	req.Header.Set("X-Tenant-ID", "acme")
+	req.Header.Set("Baggage", "plan=gold")
+	h.server.Handler.ServeHTTP(httptest.NewRecorder(), req)
+	if err := h.tracer.Close(context.Background()); err != nil {
+		t.Fatalf("Failed to flush spans: %v", err)
+	}
+
+	if backendBaggage != "plan=gold" {
+		t.Errorf("Expected baggage to be propagated to the backend, got %q", backendBaggage)
+	}
+
+	select {
+	case body := <-spans:
+		for _, attr := range []string{
+			`"balance.backend":"b1"`,
+			`"balance.client_ip":"203.0.113.0/24"`,
+			`"http.request.header.x-tenant-id":"acme"`,
+			`"baggage.plan":"gold"`,
+		} {
+			if !strings.Contains(body, attr) {
+				t.Errorf("Expected %s in spans: %s", attr, body)
+			}
+		}
+		if strings.Contains(body, "203.0.113.42") {
+			t.Errorf("Expected the full client address to be left out: %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected spans to be sent to the Zipkin collector")
+	}
+}
+
+func TestWebSocketMetrics(t *testing.T) {
+	// newBackend starts a backend answering upgrade requests with response, then echoing
+	// a four byte message
+	newBackend := func(response string) net.Listener {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					br := bufio.NewReader(conn)
+					if _, err := http.ReadRequest(br); err != nil {
+						return
+					}
+					conn.Write([]byte(response))
+					io.CopyN(conn, br, 4)
+				}()
+			}
+		}()
+		return ln
+	}
+	echo := newBackend("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	defer echo.Close()
+	refusing := newBackend("HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n")
+	defer refusing.Close()
+
+	// upgrade sends an upgrade request through a proxy to a backend, then a message
+	upgrade := func(name string, backendLn net.Listener) string {
+		server, err := NewHTTPServer(&config.Config{
+			Mode:         "http",
+			Backends:     []config.Backend{{Name: name, Address: backendLn.Addr().String(), Weight: 1}},
+			LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+			HTTP:         &config.HTTPConfig{EnableWebSocket: true},
+			Timeouts:     config.TimeoutConfig{Connect: time.Second, Read: time.Second},
+		}, nil)
+		if err != nil {
+			t.Fatalf("Failed to create HTTP server: %v", err)
+		}
+		proxy := httptest.NewServer(server.httpServer.server.Handler)
+		defer proxy.Close()
+
+		conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		br := bufio.NewReader(conn)
+		status, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read upgrade response: %v", err)
+		}
+		for line := ""; line != "\r\n"; {
+			if line, err = br.ReadString('\n'); err != nil {
+				t.Fatalf("Failed to read upgrade response: %v", err)
+			}
+		}
+		conn.Write([]byte("ping"))
+		echoed := make([]byte, 4)
+		if _, err := io.ReadFull(br, echoed); err != nil || string(echoed) != "ping" {
+			t.Errorf("Expected the message to be echoed, got %q (%v)", echoed, err)
+		}
+		return strings.TrimSpace(status)
+	}
+
+	if status := upgrade("ws-echo", echo); status != "HTTP/1.1 101 Switching Protocols" {
+		t.Errorf("Expected the upgrade to be accepted, got %q", status)
+	}
+	if status := upgrade("ws-refused", refusing); status != "HTTP/1.1 403 Forbidden" {
+		t.Errorf("Expected the backend's refusal to be relayed, got %q", status)
+	}
+
+	want := []string{
+		`balance_websocket_connections_active{backend="ws-echo"} 0`,
+		`balance_websocket_connection_duration_seconds_count{backend="ws-echo"} 1`,
+		`balance_websocket_bytes_total{backend="ws-echo",direction="received"} 4`,
+		`balance_websocket_upgrade_failures_total{backend="ws-refused",reason="rejected"} 1`,
+	}
+	var body string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rec := httptest.NewRecorder()
+		metrics.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body = rec.Body.String()
+		missing := false
+		for _, line := range want {
+			missing = missing || !strings.Contains(body, line)
+		}
+		if !missing {
+			break
+		}
+	}
+	for _, line := range want {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %s in metrics", line)
+		}
+	}
+	if strings.Contains(body, `balance_websocket_connections_active{backend="ws-refused"}`) {
+		t.Error("Expected a refused upgrade not to count as a WebSocket connection")
+	}
+}
+
+func TestHTTPAutoBanIgnoresForwardedFor(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Security: &config.SecurityConfig{
+			AutoBan: &config.AutoBanConfig{
+				Enabled:    true,
+				Events:     []string{"unauthorized"},
+				Threshold:  2,
+				FindTime:   time.Hour,
+				BanTime:    time.Minute,
+				MaxBanTime: time.Hour,
+			},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	defer server.Shutdown()
+	h := server.httpServer
+
+	// Failed logins naming a victim in X-Forwarded-For get the sender banned, not the victim
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		req.Header.Set("X-Forwarded-For", "198.51.100.9")
+		h.handleRequest(httptest.NewRecorder(), req)
+	}
+
+	victim := httptest.NewRequest(http.MethodGet, "/", nil)
+	victim.RemoteAddr = "198.51.100.9:4000"
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, victim)
+	if rec.Code == http.StatusForbidden {
+		t.Error("Expected the client named in X-Forwarded-For not to be banned")
+	}
+
+	if !h.security.Blocklist().IsBlocked("203.0.113.7") {
+		t.Error("Expected the sender to be banned")
+	}
+}
+
+func TestHTTPConcurrentRequestsPerIPIgnoresForwardedFor(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backendServer.Close()
+
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Security: &config.SecurityConfig{
+			ConnectionProtection: &config.ConnectionProtectionConfig{MaxConcurrentRequestsPerIP: 1},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	// The client's only request slot is taken
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		h.handleRequest(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	tests := []struct {
+		remoteAddr string
+		xff        string
+		want       int
+	}{
+		{"203.0.113.7:4001", "", http.StatusTooManyRequests},
+		{"203.0.113.7:4002", "198.51.100.1", http.StatusTooManyRequests},
+		{"203.0.113.7:4003", "198.51.100.2", http.StatusTooManyRequests},
+		{"203.0.113.8:4000", "", http.StatusOK},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("Request %d from %s: expected %d, got %d", i+1, tt.remoteAddr, tt.want, rec.Code)
+		}
+	}
+
+	close(release)
+	<-done
+}
+
 func TestIsWebSocketRequest(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -505,6 +1114,60 @@ func TestGetClientIP(t *testing.T) {
 	}
 }
 
+// TestHashKey tests consistent hashing key extraction
+func TestHashKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		hashKey  string
+		target   string
+		headers  map[string]string
+		expected string
+	}{
+		{
+			name:     "Source IP",
+			hashKey:  "source-ip",
+			target:   "/?session_id=abc",
+			expected: "192.0.2.1",
+		},
+		{
+			name:     "Header",
+			hashKey:  "header:X-User-ID",
+			target:   "/",
+			headers:  map[string]string{"X-User-ID": "user-42"},
+			expected: "user-42",
+		},
+		{
+			name:     "Query parameter",
+			hashKey:  "query:session_id",
+			target:   "/app?session_id=abc123&page=2",
+			expected: "abc123",
+		},
+		{
+			name:     "Missing query parameter falls back to client IP",
+			hashKey:  "query:session_id",
+			target:   "/app?page=2",
+			expected: "192.0.2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HTTPServer{config: &config.Config{
+				LoadBalancer: config.LoadBalancerConfig{HashKey: tt.hashKey},
+			}}
+
+			req := httptest.NewRequest("GET", tt.target, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			if result := h.hashKey(req, "192.0.2.1"); result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
 // TestGetScheme tests scheme detection
 func TestGetScheme(t *testing.T) {
 	tests := []struct {
@@ -582,7 +1245,7 @@ func BenchmarkHTTPProxy(b *testing.B) {
 	}
 
 	// Create and start proxy server
-	server, err := NewHTTPServer(cfg)
+	server, err := NewHTTPServer(cfg, nil)
 	if err != nil {
 		b.Fatalf("Failed to create HTTP server: %v", err)
 	}
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// streamActivity tracks the last time data moved in either direction of a proxied stream
+type streamActivity struct {
+	last atomic.Int64 // unix nanoseconds
+}
+
+// newStreamActivity creates an activity tracker starting now
+func newStreamActivity() *streamActivity {
+	a := &streamActivity{}
+	a.touch()
+	return a
+}
+
+// touch records activity
+func (a *streamActivity) touch() {
+	a.last.Store(time.Now().UnixNano())
+}
+
+// idleFor returns how long the stream has been idle
+func (a *streamActivity) idleFor() time.Duration {
+	return time.Since(time.Unix(0, a.last.Load()))
+}
+
+// watchIdle closes the stream once it has been idle for the given timeout
+// The returned function stops the watcher
+func (a *streamActivity) watchIdle(timeout time.Duration, onIdle func()) (stop func()) {
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		if remaining := timeout - a.idleFor(); remaining > 0 {
+			timer.Reset(remaining)
+			return
+		}
+		onIdle()
+	})
+
+	return func() { timer.Stop() }
+}
+
+// deadlineConn refreshes write deadlines before every operation so that long-lived
+// streams are only cut off when a peer stops accepting data
+// Reads never time out: a quiet stream stays open until tcp_idle, shutdown or either
+// peer closes it.
+type deadlineConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	activity     *streamActivity
+}
+
+// newDeadlineConn wraps conn with sliding deadlines sharing the given activity tracker
+// On the zero-copy path readTimeout paces how often the write deadline is refreshed.
+func newDeadlineConn(conn net.Conn, readTimeout, writeTimeout time.Duration, activity *streamActivity) *deadlineConn {
+	return &deadlineConn{
+		Conn:         conn,
+		readTimeout:  readTimeout,
+		writeTimeout: writeTimeout,
+		activity:     activity,
+	}
+}
+
+// Read reads without a deadline and records activity
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.activity.touch()
+	}
+	return n, err
+}
+
+// Write writes with a fresh write deadline
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	if c.writeTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.activity.touch()
+	}
+	return n, err
+}
+
+// spliceChunkSize is the amount transferred between deadline refreshes on the zero-copy path
+const spliceChunkSize = 1 << 20
+
+// ReadFrom enables zero-copy forwarding when both sides are TCP connections
+// On Linux the runtime uses splice(2), so data never passes through user space.
+// The runtime writes as soon as data arrives, so a read deadline wakes the loop to
+// refresh the write deadline while the source is quiet. Only a write that stalls for
+// the full write timeout ends the stream.
+func (c *deadlineConn) ReadFrom(r io.Reader) (int64, error) {
+	src, ok := r.(*deadlineConn)
+	if !ok {
+		return io.Copy(struct{ io.Writer }{c}, r)
+	}
+
+	dstTCP, dstOK := unwrapTCPConn(c.Conn)
+	srcTCP, srcOK := unwrapTCPConn(src.Conn)
+	if !dstOK || !srcOK {
+		return io.Copy(struct{ io.Writer }{c}, struct{ io.Reader }{src})
+	}
+
+	refresh := src.readTimeout
+	if refresh <= 0 {
+		refresh = c.writeTimeout
+	}
+
+	var written int64
+	for {
+		start := time.Now()
+		if refresh > 0 {
+			srcTCP.SetReadDeadline(start.Add(refresh))
+		}
+		if c.writeTimeout > 0 {
+			// Data read just before the refresh still gets the full write timeout
+			dstTCP.SetWriteDeadline(start.Add(refresh + c.writeTimeout))
+		}
+
+		n, err := dstTCP.ReadFrom(&io.LimitedReader{R: srcTCP, N: spliceChunkSize})
+		written += n
+		if n > 0 {
+			c.activity.touch()
+		}
+
+		if err != nil {
+			// Keep going while data flows and while the source is merely quiet
+			writeStalled := c.writeTimeout > 0 && time.Since(start) >= refresh+c.writeTimeout
+			if isTimeout(err) && (n > 0 || !writeStalled) {
+				continue
+			}
+			return written, err
+		}
+
+		if n < spliceChunkSize {
+			// Source reached EOF
+			return written, nil
+		}
+	}
+}
+
+// NetConn returns the wrapped connection
+func (c *deadlineConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// CloseWrite half-closes the underlying connection if supported
+func (c *deadlineConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// isTimeout reports whether err is a network timeout
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestDeadlineConnSlidingRead(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	conn := newDeadlineConn(server, 100*time.Millisecond, 100*time.Millisecond, newStreamActivity())
+
+	// Send data every 50ms for longer than the read timeout
+	go func() {
+		for i := 0; i < 6; i++ {
+			time.Sleep(50 * time.Millisecond)
+			client.Write([]byte("x"))
+		}
+	}()
+
+	buf := make([]byte, 1)
+	for i := 0; i < 6; i++ {
+		if _, err := conn.Read(buf); err != nil {
+			t.Fatalf("Read %d failed: %v", i, err)
+		}
+	}
+}
+
+func TestDeadlineConnQuietRead(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	conn := newDeadlineConn(server, 100*time.Millisecond, 100*time.Millisecond, newStreamActivity())
+
+	// Both directions stay quiet for longer than the read timeout
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		client.Write([]byte("x"))
+	}()
+
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Expected the quiet stream to stay open, got %v", err)
+	}
+}
+
+func TestWatchIdle(t *testing.T) {
+	activity := newStreamActivity()
+
+	var fired atomic.Bool
+	stop := activity.watchIdle(100*time.Millisecond, func() { fired.Store(true) })
+	defer stop()
+
+	// Keep the stream active past the first timer expiry
+	for i := 0; i < 4; i++ {
+		time.Sleep(40 * time.Millisecond)
+		activity.touch()
+	}
+	if fired.Load() {
+		t.Fatal("Idle callback fired while stream was active")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if !fired.Load() {
+		t.Error("Expected idle callback to fire after the stream went idle")
+	}
+}
+
+func TestTCPIdleTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts config.TimeoutConfig
+		closed   bool
+	}{
+		// The HTTP idle timeout does not apply to TCP streams
+		{"idle only", config.TimeoutConfig{Connect: time.Second, Idle: 100 * time.Millisecond}, false},
+		{"tcp_idle", config.TimeoutConfig{Connect: time.Second, TCPIdle: 100 * time.Millisecond}, true},
+		// Neither do read and write timeouts; only tcp_idle closes a quiet stream
+		{"read timeout", config.TimeoutConfig{Connect: time.Second, Read: 100 * time.Millisecond, Write: 100 * time.Millisecond}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := NewTCPServer(&config.Config{
+				Mode:         "tcp",
+				Listen:       "127.0.0.1:0",
+				Backends:     []config.Backend{{Name: "echo", Address: startEchoBackend(t), Weight: 1}},
+				LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+				Timeouts:     tt.timeouts,
+			}, nil)
+			if err != nil {
+				t.Fatalf("Failed to create TCP server: %v", err)
+			}
+			if err := server.Start(); err != nil {
+				t.Fatalf("Failed to start server: %v", err)
+			}
+			defer server.Shutdown()
+
+			conn, err := net.Dial("tcp", server.listener.Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer conn.Close()
+			if !echo(conn, "hello") {
+				t.Fatal("Expected the stream to be proxied")
+			}
+
+			time.Sleep(300 * time.Millisecond)
+			if open := echo(conn, "still there?"); open == tt.closed {
+				t.Errorf("Expected the idle stream to be closed: %v, got open: %v", tt.closed, open)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/security"
+)
+
+// listen opens the proxy listener, applying the global connection limits if configured
+func listen(cfg *config.Config) (net.Listener, *limitListener, error) {
+	keepAlive, keepAliveConfig := keepAliveSettings(cfg)
+	lc := net.ListenConfig{
+		KeepAlive:       keepAlive,
+		KeepAliveConfig: keepAliveConfig,
+	}
+
+	listener, err := lc.Listen(context.Background(), "tcp", cfg.Listen)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start listener: %w", err)
+	}
+
+	if b := cfg.Buffers; b != nil && (b.ListenerReadBuffer > 0 || b.ListenerWriteBuffer > 0) {
+		listener = &bufferListener{
+			Listener:    listener,
+			readBuffer:  b.ListenerReadBuffer,
+			writeBuffer: b.ListenerWriteBuffer,
+		}
+	}
+
+	if cfg.MaxConnections <= 0 && cfg.MaxConnectionRate <= 0 {
+		return listener, nil, nil
+	}
+
+	limited := newLimitListener(listener, cfg.MaxConnections, cfg.ConnectionQueueTimeout)
+	if cfg.ConnectionQueueSize > 0 {
+		limited.setQueueSize(cfg.ConnectionQueueSize)
+	}
+	if fds, ok := openFileLimit(); ok {
+		limited.boundQueue(fds)
+	}
+	if cfg.MaxConnectionRate > 0 {
+		burst := cfg.ConnectionRateBurst
+		if burst <= 0 {
+			burst = max(int(math.Ceil(cfg.MaxConnectionRate)), 1)
+		}
+		limited.setRate(cfg.MaxConnectionRate, burst)
+	}
+	return limited, limited, nil
+}
+
+// bufferListener sets socket buffer sizes on accepted TCP connections
+type bufferListener struct {
+	net.Listener
+	readBuffer  int
+	writeBuffer int
+}
+
+// Accept accepts a connection and applies the configured socket buffer sizes
+func (l *bufferListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if l.readBuffer > 0 {
+			tcpConn.SetReadBuffer(l.readBuffer)
+		}
+		if l.writeBuffer > 0 {
+			tcpConn.SetWriteBuffer(l.writeBuffer)
+		}
+	}
+
+	return conn, nil
+}
+
+// limitListener caps the number of concurrently open connections accepted from a
+// listener and, optionally, the rate at which they are accepted
+type limitListener struct {
+	net.Listener
+
+	// slots holds one token per open connection (nil = no limit)
+	slots chan struct{}
+
+	// queue holds one token per connection waiting for a slot (nil = no queueing)
+	queue chan struct{}
+
+	// queueTimeout is how long a queued connection waits for a free slot before being
+	// rejected (0 = reject immediately)
+	queueTimeout time.Duration
+
+	// rate limits accepted connections per second across all clients (nil = no limit)
+	rate *security.TokenBucket
+
+	// Statistics
+	rejected    atomic.Int64
+	rateLimited atomic.Int64
+}
+
+// errQueueTimeout is returned by queued connections that got no slot in time
+var errQueueTimeout = errors.New("connection limit reached")
+
+// newLimitListener wraps a listener so at most max connections are open at once (0 = no limit)
+// With a queueTimeout, up to max further connections may wait for a slot.
+func newLimitListener(l net.Listener, max int, queueTimeout time.Duration) *limitListener {
+	limited := &limitListener{
+		Listener:     l,
+		queueTimeout: queueTimeout,
+	}
+	if max > 0 {
+		limited.slots = make(chan struct{}, max)
+		if queueTimeout > 0 {
+			limited.queue = make(chan struct{}, max)
+		}
+	}
+	return limited
+}
+
+// setQueueSize sets how many connections may wait for a slot at once
+func (l *limitListener) setQueueSize(size int) {
+	if l.queue != nil {
+		l.queue = make(chan struct{}, size)
+	}
+}
+
+// fdReserve is the number of file descriptors kept free for listeners, health checks,
+// log files and the admin API when bounding the connection queue
+const fdReserve = 256
+
+// boundQueue shrinks the queue so that open and queued connections fit within fds
+// file descriptors
+// An open connection uses two descriptors, one for the client and one for the backend,
+// and a queued connection uses one. Without room for any, connections over the limit
+// are rejected immediately.
+func (l *limitListener) boundQueue(fds uint64) {
+	if l.queue == nil || fds > math.MaxInt32 {
+		return
+	}
+	room := int(fds) - 2*cap(l.slots) - fdReserve
+	switch {
+	case room <= 0:
+		l.queue = nil
+	case room < cap(l.queue):
+		l.queue = make(chan struct{}, room)
+	}
+}
+
+// setRate limits accepted connections to perSecond, allowing bursts of burst connections
+func (l *limitListener) setRate(perSecond float64, burst int) {
+	l.rate = security.NewTokenBucket(perSecond, int64(burst))
+	l.rate.SetName("listener")
+}
+
+// Accept waits for the next connection, closing it immediately if it exceeds the
+// accept rate or the connection limit with the queue full
+// A queued connection is returned right away and waits for its slot on first use (see
+// waitForSlot), so it does not hold up the connections accepted after it.
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		// The whole listener shares a single bucket, so floods from many source
+		// addresses are capped as well
+		if l.rate != nil && !l.rate.Allow("") {
+			l.rateLimited.Add(1)
+			conn.Close()
+			continue
+		}
+
+		if l.slots == nil {
+			return conn, nil
+		}
+		select {
+		case l.slots <- struct{}{}:
+			c := &limitConn{Conn: conn, listener: l, closed: make(chan struct{})}
+			c.held.Store(true)
+			return c, nil
+		default:
+		}
+
+		select {
+		case l.queue <- struct{}{}:
+			return &limitConn{Conn: conn, listener: l, queued: true, closed: make(chan struct{})}, nil
+		default:
+		}
+
+		// Over the limit: reject without reading anything from the client
+		l.rejected.Add(1)
+		conn.Close()
+	}
+}
+
+// release returns a connection slot
+func (l *limitListener) release() {
+	<-l.slots
+}
+
+// Stats returns connection limit statistics
+func (l *limitListener) Stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"rejected_connections": l.rejected.Load(),
+	}
+	if l.slots != nil {
+		stats["max_connections"] = cap(l.slots)
+		stats["open_connections"] = len(l.slots)
+	}
+	if l.queue != nil {
+		stats["queued_connections"] = len(l.queue)
+		stats["connection_queue_size"] = cap(l.queue)
+	}
+	if l.rate != nil {
+		stats["max_connection_rate"] = l.rate.Stats()["rate"]
+		stats["rate_limited_connections"] = l.rateLimited.Load()
+	}
+	return stats
+}
+
+// limitConn is a connection holding, or queued for, a slot of a limitListener
+// Its slot is released exactly once when it is closed.
+type limitConn struct {
+	net.Conn
+	listener *limitListener
+
+	// queued is set if the connection was accepted without a slot
+	queued bool
+
+	// ready is done once the connection holds a slot or gave up waiting for one
+	ready sync.Once
+	err   error
+
+	// Deadlines set while queued are held back until the connection gets its slot,
+	// so timeouts such as net/http's ReadTimeout do not run while it waits
+	mu            sync.Mutex
+	started       bool
+	readDeadline  pendingDeadline
+	writeDeadline pendingDeadline
+
+	held      atomic.Bool
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// wait waits up to the queue timeout for a slot if the connection was queued
+func (c *limitConn) wait() error {
+	c.ready.Do(func() {
+		if !c.queued {
+			return
+		}
+		l := c.listener
+		defer func() { <-l.queue }()
+
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+
+		select {
+		case l.slots <- struct{}{}:
+			c.held.Store(true)
+			c.start()
+		case <-timer.C:
+			l.rejected.Add(1)
+			c.err = errQueueTimeout
+			c.Conn.Close()
+		case <-c.closed:
+			c.err = net.ErrClosed
+		}
+	})
+	return c.err
+}
+
+// pendingDeadline is a deadline set while a connection was queued
+type pendingDeadline struct {
+	deadline time.Time
+	setAt    time.Time
+	set      bool
+}
+
+// start applies the deadlines set while the connection was queued, moved later by the
+// time it spent queued
+func (c *limitConn) start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.started = true
+	now := time.Now()
+	if d := c.readDeadline; d.set {
+		c.Conn.SetReadDeadline(d.shifted(now))
+	}
+	if d := c.writeDeadline; d.set {
+		c.Conn.SetWriteDeadline(d.shifted(now))
+	}
+}
+
+// shifted returns the deadline as if it had been set at now
+func (d pendingDeadline) shifted(now time.Time) time.Time {
+	if d.deadline.IsZero() {
+		return d.deadline
+	}
+	return d.deadline.Add(now.Sub(d.setAt))
+}
+
+// SetDeadline sets the read and write deadlines
+func (c *limitConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline, or holds it back while the connection is queued
+func (c *limitConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.queued && !c.started {
+		c.readDeadline = pendingDeadline{deadline: t, setAt: time.Now(), set: true}
+		return nil
+	}
+	return c.Conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline, or holds it back while the connection is queued
+func (c *limitConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.queued && !c.started {
+		c.writeDeadline = pendingDeadline{deadline: t, setAt: time.Now(), set: true}
+		return nil
+	}
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// Read waits for a slot before reading
+func (c *limitConn) Read(b []byte) (int, error) {
+	if err := c.wait(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+// Write waits for a slot before writing
+func (c *limitConn) Write(b []byte) (int, error) {
+	if err := c.wait(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// Close closes the connection and frees its slot, or its place in the queue
+func (c *limitConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	err := c.Conn.Close()
+
+	// Wakes a pending wait, or leaves the queue if the connection never waited
+	c.wait()
+	if c.held.CompareAndSwap(true, false) {
+		c.listener.release()
+	}
+	return err
+}
+
+// NetConn returns the wrapped connection
+func (c *limitConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// CloseWrite half-closes the underlying TCP connection
+func (c *limitConn) CloseWrite() error {
+	if tcpConn, ok := c.Conn.(*net.TCPConn); ok {
+		return tcpConn.CloseWrite()
+	}
+	return nil
+}
+
+// waitForSlot waits for the slot of a connection queued by a limitListener, unwrapping
+// conn as needed; connections that were not queued return immediately
+// Handlers call it before anything else, so the time spent queued does not count
+// against their own timeouts.
+func waitForSlot(conn net.Conn) error {
+	for {
+		switch c := conn.(type) {
+		case *limitConn:
+			return c.wait()
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package proxy
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimitListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	limited := newLimitListener(ln, 1, 0)
+	defer limited.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	// First connection takes the only slot
+	client1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client1.Close()
+
+	var server1 net.Conn
+	select {
+	case server1 = <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("First connection was not accepted")
+	}
+
+	// Second connection is rejected and closed by the proxy
+	client2, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client2.Close()
+
+	client2.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client2.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected rejected connection to be closed")
+	}
+
+	if rejected := limited.Stats()["rejected_connections"].(int64); rejected != 1 {
+		t.Errorf("Expected 1 rejected connection, got %d", rejected)
+	}
+
+	// Closing the first connection frees the slot
+	server1.Close()
+
+	client3, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client3.Close()
+
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(time.Second):
+		t.Fatal("Connection was not accepted after slot was released")
+	}
+}
+
+func TestLimitListenerQueueTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	limited := newLimitListener(ln, 1, 500*time.Millisecond)
+	defer limited.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	client1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client1.Close()
+	server1 := <-accepted
+
+	client2, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client2.Close()
+
+	// The second connection is accepted at once and waits for its slot
+	var server2 net.Conn
+	select {
+	case server2 = <-accepted:
+		defer server2.Close()
+	case <-time.After(time.Second):
+		t.Fatal("Queued connection was not accepted")
+	}
+	waited := make(chan error, 1)
+	go func() { waited <- waitForSlot(server2) }()
+
+	// Release the slot while the second connection is queued
+	time.Sleep(100 * time.Millisecond)
+	server1.Close()
+
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Fatalf("Expected the queued connection to get a slot, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Queued connection did not get a slot")
+	}
+
+	if rejected := limited.Stats()["rejected_connections"].(int64); rejected != 0 {
+		t.Errorf("Expected no rejected connections, got %d", rejected)
+	}
+	if open := limited.Stats()["open_connections"].(int); open != 1 {
+		t.Errorf("Expected 1 open connection, got %d", open)
+	}
+}
+
+func TestLimitListenerQueueExpired(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	limited := newLimitListener(ln, 1, 100*time.Millisecond)
+	defer limited.Close()
+
+	for i := 0; i < 2; i++ {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer client.Close()
+	}
+	server1, err := limited.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer server1.Close()
+	server2, err := limited.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+
+	// No slot frees up, so the queued connection is rejected once the timeout passes
+	if err := waitForSlot(server2); err != errQueueTimeout {
+		t.Errorf("Expected %v, got %v", errQueueTimeout, err)
+	}
+	if _, err := server2.Read(make([]byte, 1)); err != errQueueTimeout {
+		t.Errorf("Expected reads to fail with %v, got %v", errQueueTimeout, err)
+	}
+	server2.Close()
+
+	stats := limited.Stats()
+	if stats["rejected_connections"] != int64(1) || stats["queued_connections"] != 0 || stats["open_connections"] != 1 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestLimitListenerQueuedDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	limited := newLimitListener(ln, 1, 2*time.Second)
+	defer limited.Close()
+
+	for i := 0; i < 2; i++ {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer client.Close()
+		client.Write([]byte("x"))
+	}
+	server1, err := limited.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	server2, err := limited.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer server2.Close()
+
+	// A read timeout set by the server while queued, as net/http does, only starts
+	// once the connection gets its slot
+	server2.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	time.AfterFunc(300*time.Millisecond, func() { server1.Close() })
+
+	if _, err := server2.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Expected the read to succeed after the slot was acquired, got %v", err)
+	}
+}
+
+func TestLimitListenerBoundQueue(t *testing.T) {
+	tests := []struct {
+		fds   uint64
+		queue int
+	}{
+		{math.MaxUint64, 100},
+		{10000, 100},
+		// 500 descriptors leave room for 44 queued connections next to 100 open ones
+		{500, 44},
+		{300, 0},
+	}
+
+	for _, tt := range tests {
+		limited := newLimitListener(nil, 100, time.Second)
+		limited.boundQueue(tt.fds)
+		if size := cap(limited.queue); size != tt.queue {
+			t.Errorf("Expected a queue of %d with %d descriptors, got %d", tt.queue, tt.fds, size)
+		}
+	}
+}
+
+func TestLimitListenerQueueDoesNotBlockAccept(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueSize int
+		accepted  bool
+	}{
+		{"queue full", 1, false},
+		{"queue space", 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			limited := newLimitListener(ln, 1, 2*time.Second)
+			limited.setQueueSize(tt.queueSize)
+			defer limited.Close()
+
+			accepted := make(chan net.Conn, 3)
+			go func() {
+				for {
+					conn, err := limited.Accept()
+					if err != nil {
+						return
+					}
+					accepted <- conn
+					// Handlers wait for their slot on their own goroutine
+					go waitForSlot(conn)
+				}
+			}()
+
+			dial := func() net.Conn {
+				client, err := net.Dial("tcp", ln.Addr().String())
+				if err != nil {
+					t.Fatalf("Failed to dial: %v", err)
+				}
+				t.Cleanup(func() { client.Close() })
+				return client
+			}
+
+			// The first connection holds the slot and the second one is queued
+			for i := 0; i < 2; i++ {
+				dial()
+				select {
+				case conn := <-accepted:
+					defer conn.Close()
+				case <-time.After(time.Second):
+					t.Fatalf("Connection %d was not accepted", i+1)
+				}
+			}
+
+			// The third connection is accepted or rejected right away instead of
+			// waiting behind the queued one
+			client := dial()
+			select {
+			case conn := <-accepted:
+				defer conn.Close()
+				if !tt.accepted {
+					t.Error("Expected the connection to be rejected with the queue full")
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.accepted {
+					t.Fatal("Expected the connection to be queued while another one waits")
+				}
+			}
+
+			if !tt.accepted {
+				client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+				if _, err := client.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+					t.Errorf("Expected the rejected connection to be closed, got %v", err)
+				}
+				if rejected := limited.Stats()["rejected_connections"].(int64); rejected != 1 {
+					t.Errorf("Expected 1 rejected connection, got %d", rejected)
+				}
+			}
+		})
+	}
+}
+
+func TestLimitListenerRate(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	limited := newLimitListener(ln, 0, 0)
+	limited.setRate(0.001, 2)
+	defer limited.Close()
+
+	accepted := make(chan net.Conn, 3)
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	// The burst is accepted
+	for i := 0; i < 2; i++ {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer client.Close()
+
+		select {
+		case conn := <-accepted:
+			defer conn.Close()
+		case <-time.After(time.Second):
+			t.Fatalf("Connection %d was not accepted", i)
+		}
+	}
+
+	// The next connection exceeds the rate and is closed, even though no
+	// connection limit is set
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected rate limited connection to be closed")
+	}
+
+	stats := limited.Stats()
+	if stats["rate_limited_connections"] != int64(1) {
+		t.Errorf("Expected 1 rate limited connection, got %v", stats["rate_limited_connections"])
+	}
+	if _, ok := stats["max_connections"]; ok {
+		t.Error("Expected no connection limit in stats")
+	}
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/router"
+)
+
+// priorityContextKey is the context key of a request's priority class
+type priorityContextKey struct{}
+
+// requestPriority classifies a request by the priority header if it has a valid one,
+// and otherwise by the priority class of its route (normal without a route)
+func (h *HTTPServer) requestPriority(r *http.Request, route *router.RouteEntry) resilience.Priority {
+	if header := h.config.HTTP.PriorityHeader; header != "" {
+		if value := r.Header.Get(header); value != "" {
+			if priority, err := resilience.ParsePriority(strings.ToLower(strings.TrimSpace(value))); err == nil {
+				return priority
+			}
+		}
+	}
+	if route == nil {
+		return resilience.PriorityNormal
+	}
+	priority, _ := resilience.ParsePriority(route.Config().PriorityClass)
+	return priority
+}
+
+// withPriority stores the priority class of a request in its context
+func withPriority(ctx context.Context, priority resilience.Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, priority)
+}
+
+// priorityFrom returns the priority class stored in the context (normal if none)
+func priorityFrom(ctx context.Context) resilience.Priority {
+	priority, _ := ctx.Value(priorityContextKey{}).(resilience.Priority)
+	return priority
+}
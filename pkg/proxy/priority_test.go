@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/router"
+)
+
+func TestRequestPriority(t *testing.T) {
+	h := &HTTPServer{config: &config.Config{HTTP: &config.HTTPConfig{PriorityHeader: "X-Priority"}}}
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("b1", "localhost:9001", 1))
+	routes := router.NewRouter([]config.Route{
+		{Name: "batch", PathPrefix: "/", Backends: []string{"b1"}, PriorityClass: "low"},
+	}, pool)
+	batch := routes.MatchRoute(httptest.NewRequest(http.MethodGet, "/", nil))
+
+	tests := []struct {
+		name   string
+		header string
+		route  *router.RouteEntry
+		want   resilience.Priority
+	}{
+		{"no header or route", "", nil, resilience.PriorityNormal},
+		{"route class", "", batch, resilience.PriorityLow},
+		{"header overrides route", "High", batch, resilience.PriorityHigh},
+		{"invalid header falls back to route", "urgent", batch, resilience.PriorityLow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Priority", tt.header)
+			}
+			if got := h.requestPriority(r, tt.route); got != tt.want {
+				t.Errorf("Expected priority %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRequestQueuePriority(t *testing.T) {
+	q := newRequestQueue(&config.Config{
+		RequestQueue: &config.RequestQueueConfig{MaxDepth: 2, Timeout: 2 * time.Second, RetryAfter: time.Second},
+	})
+
+	// acquire hands out the free slots; none are free to begin with
+	var free atomic.Int32
+	acquire := func() (*backend.Backend, error) {
+		if free.Add(-1) < 0 {
+			free.Add(1)
+			return nil, errBackendsFull
+		}
+		return &backend.Backend{}, nil
+	}
+
+	// Low-priority requests may only fill half of the queue
+	normal := make(chan error, 1)
+	go func() {
+		_, err := q.wait(context.Background(), resilience.PriorityNormal, acquire)
+		normal <- err
+	}()
+	waitFor(t, func() bool { return q.Stats()["waiting"] == 1 })
+
+	if _, err := q.wait(context.Background(), resilience.PriorityLow, acquire); err != errQueueFull {
+		t.Errorf("Expected low-priority request to overflow, got %v", err)
+	}
+
+	// A high-priority request is served before the normal one that waited longer
+	high := make(chan error, 1)
+	go func() {
+		_, err := q.wait(context.Background(), resilience.PriorityHigh, acquire)
+		high <- err
+	}()
+	waitFor(t, func() bool { return q.Stats()["waiting"] == 2 })
+
+	free.Add(1)
+	q.release()
+
+	select {
+	case err := <-high:
+		if err != nil {
+			t.Errorf("Expected high-priority request to be served, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("High-priority request was not served")
+	}
+	select {
+	case err := <-normal:
+		t.Fatalf("Expected normal request to keep waiting, got %v", err)
+	default:
+	}
+
+	// The next free slot goes to the normal request
+	free.Add(1)
+	q.release()
+
+	select {
+	case err := <-normal:
+		if err != nil {
+			t.Errorf("Expected normal request to be served, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Normal request was not served")
+	}
+}
@@ -0,0 +1,242 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/lb"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/router"
+)
+
+var (
+	// errBackendsFull is returned when every backend tried is at its max_connections
+	errBackendsFull = errors.New("all backends are at their connection limit")
+
+	// errQueueFull is returned when the request queue is full or a request waited too long
+	errQueueFull = errors.New("request queue is full")
+)
+
+// requestQueue holds requests while every backend is at its connection limit
+// Waiting requests are woken whenever a backend connection is released and try to
+// acquire a backend again, until the queue timeout expires. Requests only try once no
+// request of a higher priority class is waiting, and low-priority requests may only
+// fill half of the queue, so batch traffic cannot crowd out interactive requests.
+type requestQueue struct {
+	maxDepth   int
+	timeout    time.Duration
+	retryAfter time.Duration
+
+	mu      sync.Mutex
+	waiting int
+
+	// byPriority counts the waiting requests of each priority class
+	byPriority map[resilience.Priority]int
+
+	// wake is closed and replaced when a backend connection is released
+	wake chan struct{}
+
+	// hasWaiters lets release skip the lock while nothing is queued
+	hasWaiters atomic.Bool
+
+	// Statistics
+	queued     atomic.Int64
+	overflowed atomic.Int64
+	timedOut   atomic.Int64
+}
+
+// newRequestQueue creates the request queue from the request_queue settings (nil if disabled)
+func newRequestQueue(cfg *config.Config) *requestQueue {
+	q := cfg.RequestQueue
+	if q == nil || q.MaxDepth <= 0 {
+		return nil
+	}
+	return &requestQueue{
+		maxDepth:   q.MaxDepth,
+		timeout:    q.Timeout,
+		retryAfter: q.RetryAfter,
+		byPriority: make(map[resilience.Priority]int),
+		wake:       make(chan struct{}),
+	}
+}
+
+// wait queues a request of the given priority until acquire returns something other
+// than errBackendsFull
+// It returns errQueueFull if the queue is full or the timeout expires first.
+func (q *requestQueue) wait(ctx context.Context, priority resilience.Priority, acquire func() (*backend.Backend, error)) (*backend.Backend, error) {
+	depth := q.maxDepth
+	if priority < resilience.PriorityNormal {
+		depth = max(q.maxDepth/2, 1)
+	}
+
+	q.mu.Lock()
+	if q.waiting >= depth {
+		q.mu.Unlock()
+		q.overflowed.Add(1)
+		return nil, errQueueFull
+	}
+	q.waiting++
+	q.byPriority[priority]++
+	q.hasWaiters.Store(true)
+	q.mu.Unlock()
+	q.queued.Add(1)
+
+	defer func() {
+		q.mu.Lock()
+		q.waiting--
+		q.byPriority[priority]--
+		q.hasWaiters.Store(q.waiting > 0)
+		q.mu.Unlock()
+
+		// Requests that held back for this one try again
+		q.release()
+	}()
+
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+
+	for {
+		// Take the wake channel before trying, so a release in between is not missed
+		q.mu.Lock()
+		wake := q.wake
+		preceded := q.waitingAbove(priority)
+		q.mu.Unlock()
+
+		if !preceded {
+			b, err := acquire()
+			if !errors.Is(err, errBackendsFull) {
+				return b, err
+			}
+		}
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			q.timedOut.Add(1)
+			return nil, errQueueFull
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// waitingAbove reports whether requests of a higher priority class are waiting
+// Callers must hold the lock.
+func (q *requestQueue) waitingAbove(priority resilience.Priority) bool {
+	for p, n := range q.byPriority {
+		if p > priority && n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// release wakes the queued requests after a backend connection was released
+func (q *requestQueue) release() {
+	if !q.hasWaiters.Load() {
+		return
+	}
+	q.mu.Lock()
+	close(q.wake)
+	q.wake = make(chan struct{})
+	q.mu.Unlock()
+}
+
+// reject responds to a request the queue could not take or hold long enough
+func (q *requestQueue) reject(w http.ResponseWriter) {
+	seconds := int(math.Ceil(q.retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+}
+
+// Stats returns request queue statistics
+func (q *requestQueue) Stats() map[string]interface{} {
+	q.mu.Lock()
+	waiting := q.waiting
+	q.mu.Unlock()
+
+	return map[string]interface{}{
+		"max_depth":    q.maxDepth,
+		"waiting":      waiting,
+		"total_queued": q.queued.Load(),
+		"overflowed":   q.overflowed.Load(),
+		"timed_out":    q.timedOut.Load(),
+	}
+}
+
+// acquireBackend selects a backend for the request and takes one of its connections
+// Backends at their connection limit are passed over; if every backend tried is full,
+// the request waits in the request queue when one is configured. The connection must
+// be returned with releaseBackend.
+func (h *HTTPServer) acquireBackend(r *http.Request, clientIP string) (*backend.Backend, error) {
+	b, err := h.tryAcquireBackend(r, clientIP)
+	if errors.Is(err, errBackendsFull) && h.queue != nil {
+		return h.queue.wait(r.Context(), priorityFrom(r.Context()), func() (*backend.Backend, error) {
+			return h.tryAcquireBackend(r, clientIP)
+		})
+	}
+	return b, err
+}
+
+// tryAcquireBackend takes a connection of the first selected backend with spare
+// capacity, spilling over to the route's overflow backends if none has any
+func (h *HTTPServer) tryAcquireBackend(r *http.Request, clientIP string) (*backend.Backend, error) {
+	b, err := h.tryAcquireFrom(h.balancerFor(r), r, clientIP)
+	if !errors.Is(err, errBackendsFull) {
+		return b, err
+	}
+
+	if route := router.FromContext(r.Context()); route != nil {
+		if overflow, ok := h.overflowBalancers[route]; ok {
+			if b, err := h.tryAcquireFrom(overflow, r, clientIP); err == nil {
+				h.overflowRequests.Add(1)
+				return b, nil
+			}
+		}
+	}
+	return nil, errBackendsFull
+}
+
+// tryAcquireFrom takes a connection of the first backend selected by the load balancer
+// that is below its connection limit and whose circuit breaker is not open
+func (h *HTTPServer) tryAcquireFrom(balancer lb.LoadBalancer, r *http.Request, clientIP string) (*backend.Backend, error) {
+	// Each selection can land on another backend, so try as many times as there are backends
+	for range max(h.pool.Size(), 1) {
+		b := h.selectFrom(balancer, r, clientIP)
+		if b == nil {
+			return nil, errNoBackend
+		}
+		if breaker := h.breakers.get(b.Name()); breaker != nil && breaker.IsOpen() {
+			continue
+		}
+		if b.TryIncrementConnections() {
+			return b, nil
+		}
+	}
+	return nil, errBackendsFull
+}
+
+// releaseBackend returns a connection taken by acquireBackend and wakes queued requests
+func (h *HTTPServer) releaseBackend(b *backend.Backend) {
+	b.DecrementConnections()
+	if h.queue != nil {
+		h.queue.release()
+	}
+}
+
+// writeBusy responds to a request no backend had a free connection for
+func (h *HTTPServer) writeBusy(w http.ResponseWriter) {
+	if h.queue != nil {
+		h.queue.reject(w)
+		return
+	}
+	http.Error(w, "All backends are busy", http.StatusServiceUnavailable)
+}
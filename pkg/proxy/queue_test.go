@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestRequestQueue(t *testing.T) {
+	release := make(chan struct{})
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			<-release
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer backendServer.Close()
+
+	cfg := &config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1, MaxConnections: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		RequestQueue: &config.RequestQueueConfig{MaxDepth: 1, Timeout: 2 * time.Second, RetryAfter: 3 * time.Second},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+	b := h.pool.Get("b1")
+
+	// The first request takes the backend's only connection
+	first := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/block", nil))
+		first <- rec
+	}()
+	waitFor(t, func() bool { return b.ActiveConnections() == 1 })
+
+	// The second request waits in the queue
+	queued := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		queued <- rec
+	}()
+	waitFor(t, func() bool { return h.queue.Stats()["waiting"] == 1 })
+
+	// The third request overflows the queue
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 on queue overflow, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("Expected Retry-After 3, got %q", got)
+	}
+
+	// Finishing the first request lets the queued one through
+	close(release)
+	for _, ch := range []chan *httptest.ResponseRecorder{first, queued} {
+		select {
+		case rec := <-ch:
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected 200, got %d", rec.Code)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Request did not complete")
+		}
+	}
+
+	stats := h.queue.Stats()
+	if stats["total_queued"] != int64(1) || stats["overflowed"] != int64(1) {
+		t.Errorf("Expected 1 queued and 1 overflowed request, got %v", stats)
+	}
+	if b.ActiveConnections() != 0 {
+		t.Errorf("Expected no active connections, got %d", b.ActiveConnections())
+	}
+}
+
+func TestRequestQueueTimeout(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backendServer.Close()
+
+	cfg := &config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1, MaxConnections: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		RequestQueue: &config.RequestQueueConfig{MaxDepth: 10, Timeout: 50 * time.Millisecond, RetryAfter: time.Second},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	// Hold the backend's only connection
+	b := h.pool.Get("b1")
+	if !b.TryIncrementConnections() {
+		t.Fatal("Expected to take the backend's connection")
+	}
+	defer b.DecrementConnections()
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected 503 with Retry-After after the queue timeout, got %d %v", rec.Code, rec.Header())
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the request to wait for the queue timeout, took %s", elapsed)
+	}
+	if timedOut := h.queue.Stats()["timed_out"]; timedOut != int64(1) {
+		t.Errorf("Expected 1 timed out request, got %v", timedOut)
+	}
+}
+
+func TestOverflowBackends(t *testing.T) {
+	newBackend := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+	}
+	primary := newBackend("primary")
+	defer primary.Close()
+	overflow := newBackend("overflow")
+	defer overflow.Close()
+
+	cfg := &config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(primary.URL, "http://"), Weight: 1, MaxConnections: 1},
+			{Name: "static", Address: strings.TrimPrefix(overflow.URL, "http://"), Weight: 1, Backup: true},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP: &config.HTTPConfig{
+			Routes: []config.Route{
+				{Name: "app", PathPrefix: "/", Backends: []string{"b1"}, Overflow: []string{"static"}},
+			},
+		},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	// The overflow backend is not used while the route has capacity
+	if body := get(); body != "primary" {
+		t.Errorf("Expected the primary backend, got %q", body)
+	}
+
+	// Requests spill over while the route's backends are at capacity
+	b := h.pool.Get("b1")
+	if !b.TryIncrementConnections() {
+		t.Fatal("Expected to take the backend's connection")
+	}
+	if body := get(); body != "overflow" {
+		t.Errorf("Expected the overflow backend, got %q", body)
+	}
+	b.DecrementConnections()
+
+	if body := get(); body != "primary" {
+		t.Errorf("Expected the primary backend after it freed up, got %q", body)
+	}
+	if n := h.Stats()["overflow_requests"]; n != int64(1) {
+		t.Errorf("Expected 1 overflow request, got %v", n)
+	}
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
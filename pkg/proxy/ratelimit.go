@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+// rateLimitKey extracts the key a request is rate limited by according to
+// security.rate_limit.key
+// Supported keys are "client-ip", "header:<name>", "cookie:<name>", "jwt:<claim>" and
+// "api-key:<header>"; the client IP is used when the request does not carry the key.
+// The client IP comes from trustedClientIP, so rotating X-Forwarded-For does not give
+// a client a fresh limit.
+func (h *HTTPServer) rateLimitKey(r *http.Request) string {
+	var key string
+	if sc := h.config.Security; sc != nil && sc.RateLimit != nil {
+		key = sc.RateLimit.Key
+	}
+
+	if value := requestKeyValue(r, key); value != "" {
+		return value
+	}
+	return h.trustedClientIP(r)
+}
+
+// rateLimitTier returns the rate limit tier of a request according to
+// security.rate_limit.tier_key ("" if it has none)
+func (h *HTTPServer) rateLimitTier(r *http.Request) string {
+	if sc := h.config.Security; sc != nil && sc.RateLimit != nil && len(sc.RateLimit.Tiers) > 0 {
+		return requestKeyValue(r, sc.RateLimit.TierKey)
+	}
+	return ""
+}
+
+// requestKeyValue returns the value of a request for a key of the form "<source>:<name>"
+// ("" if the request does not carry it)
+func requestKeyValue(r *http.Request, key string) string {
+	var value string
+	source, name, _ := strings.Cut(key, ":")
+	switch source {
+	case "header":
+		value = r.Header.Get(name)
+	case "cookie":
+		if cookie, err := r.Cookie(name); err == nil {
+			value = cookie.Value
+		}
+	case "jwt":
+		value = jwtClaim(r, name)
+	case "api-key":
+		// API keys are secrets, so only a digest is kept in the rate limiter
+		if apiKey := r.Header.Get(name); apiKey != "" {
+			sum := sha256.Sum256([]byte(apiKey))
+			value = "api-key:" + hex.EncodeToString(sum[:8])
+		}
+	}
+	return value
+}
+
+// apiKeyHeaders returns the headers security.rate_limit reads API keys from, so request
+// captures can hide them
+func apiKeyHeaders(cfg *config.Config) []string {
+	sc := cfg.Security
+	if sc == nil || sc.RateLimit == nil {
+		return nil
+	}
+
+	var headers []string
+	for _, key := range []string{sc.RateLimit.Key, sc.RateLimit.TierKey} {
+		if name, ok := strings.CutPrefix(key, "api-key:"); ok && name != "" {
+			headers = append(headers, name)
+		}
+	}
+	return headers
+}
+
+// jwtClaim returns a claim of the bearer token in the Authorization header ("" if
+// there is none)
+// The token's signature is not verified, so a client can choose its own key by forging
+// a token; the key is only trustworthy where tokens are verified before the proxy.
+func jwtClaim(r *http.Request, claim string) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	var claims map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	raw, ok := claims[claim]
+	if !ok {
+		return ""
+	}
+
+	// String claims are used as is; numbers and other values by their JSON text
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
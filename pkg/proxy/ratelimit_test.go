@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	token := func(payload string) string {
+		return "Bearer e30." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".sig"
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		header string
+		value  string
+		cookie *http.Cookie
+		want   string
+	}{
+		{"default", "", "", "", nil, "10.0.0.1"},
+		{"client ip", "client-ip", "X-Tenant", "acme", nil, "10.0.0.1"},
+		{"header", "header:X-Tenant", "X-Tenant", "acme", nil, "acme"},
+		{"missing header", "header:X-Tenant", "", "", nil, "10.0.0.1"},
+		{"cookie", "cookie:tenant", "", "", &http.Cookie{Name: "tenant", Value: "acme"}, "acme"},
+		{"jwt string claim", "jwt:sub", "Authorization", token(`{"sub":"user-1"}`), nil, "user-1"},
+		{"jwt number claim", "jwt:tenant_id", "Authorization", token(`{"tenant_id":1234567}`), nil, "1234567"},
+		{"jwt missing claim", "jwt:sub", "Authorization", token(`{"iss":"idp"}`), nil, "10.0.0.1"},
+		{"malformed jwt", "jwt:sub", "Authorization", "Bearer not-a-token", nil, "10.0.0.1"},
+		{"api key", "api-key:X-API-Key", "X-API-Key", "secret", nil, "api-key:2bb80d537b1da3e3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HTTPServer{config: &config.Config{
+				Security: &config.SecurityConfig{RateLimit: &config.RateLimitConfig{Key: tt.key}},
+			}}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.1:4000"
+			r.Header.Set("X-Forwarded-For", "198.51.100.9")
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+
+			if got := h.rateLimitKey(r); got != tt.want {
+				t.Errorf("Expected key %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRateLimitTier(t *testing.T) {
+	h := &HTTPServer{config: &config.Config{
+		Security: &config.SecurityConfig{RateLimit: &config.RateLimitConfig{
+			TierKey: "header:X-Plan",
+			Tiers:   map[string]*config.RateLimitTierConfig{"pro": {RequestsPerSecond: 100, BurstSize: 200}},
+		}},
+	}}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if tier := h.rateLimitTier(r); tier != "" {
+		t.Errorf("Expected no tier, got %q", tier)
+	}
+
+	r.Header.Set("X-Plan", "pro")
+	if tier := h.rateLimitTier(r); tier != "pro" {
+		t.Errorf("Expected tier pro, got %q", tier)
+	}
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+// httpsRedirect is a plaintext HTTP listener that redirects requests to HTTPS
+type httpsRedirect struct {
+	server   *http.Server
+	listener net.Listener
+	logger   *logging.Logger
+}
+
+// newHTTPSRedirect creates the redirect listener if tls.redirect_http is enabled
+// ACME challenge requests are forwarded by acme (if set) instead of redirected.
+func newHTTPSRedirect(cfg *config.Config, acme *acmeChallengeProxy, logger *logging.Logger) *httpsRedirect {
+	if cfg.TLS == nil || !cfg.TLS.Enabled || cfg.TLS.RedirectHTTP == nil || !cfg.TLS.RedirectHTTP.Enabled {
+		return nil
+	}
+
+	port := ""
+	if cfg.TLS.RedirectHTTP.HTTPSPort > 0 {
+		port = strconv.Itoa(cfg.TLS.RedirectHTTP.HTTPSPort)
+	} else if _, listenPort, err := net.SplitHostPort(cfg.Listen); err == nil {
+		port = listenPort
+	}
+
+	return &httpsRedirect{
+		server: &http.Server{
+			Addr:              cfg.TLS.RedirectHTTP.Listen,
+			Handler:           withACMEChallenges(acme, httpsRedirectHandler(port)),
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       30 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+// httpsRedirectHandler redirects requests to the same host, path and query over HTTPS
+// on httpsPort (omitted from the URL when it is the default 443)
+func httpsRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if host == "" {
+			http.Error(w, "Host header required", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		target := url.URL{
+			Scheme:   "https",
+			Host:     host,
+			Path:     r.URL.Path,
+			RawPath:  r.URL.RawPath,
+			RawQuery: r.URL.RawQuery,
+		}
+
+		// 308 keeps the method and body of non-GET requests, which 301 lets clients drop
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, target.String(), status)
+	})
+}
+
+// start listens and serves redirects until shutdown
+func (r *httpsRedirect) start(wg *sync.WaitGroup) error {
+	listener, err := net.Listen("tcp", r.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start HTTPS redirect listener: %w", err)
+	}
+	r.listener = listener
+	r.logger.Info("Redirecting HTTP to HTTPS", logging.String("address", listener.Addr().String()))
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := r.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			r.logger.Error("HTTPS redirect listener error", logging.Err(err))
+		}
+	}()
+	return nil
+}
+
+// shutdown stops the listener, waiting briefly for in-flight redirects
+func (r *httpsRedirect) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.server.Shutdown(ctx); err != nil {
+		r.server.Close()
+	}
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		port       string
+		method     string
+		target     string
+		host       string
+		wantStatus int
+		wantURL    string
+	}{
+		{"default port", "443", http.MethodGet, "/path?q=1", "example.com", http.StatusMovedPermanently, "https://example.com/path?q=1"},
+		{"request port is replaced", "443", http.MethodGet, "/", "example.com:80", http.StatusMovedPermanently, "https://example.com/"},
+		{"custom port", "8443", http.MethodGet, "/a%2Fb", "example.com:8080", http.StatusMovedPermanently, "https://example.com:8443/a%2Fb"},
+		{"IPv6 host", "443", http.MethodGet, "/", "[::1]:80", http.StatusMovedPermanently, "https://[::1]/"},
+		{"POST keeps its method", "443", http.MethodPost, "/form", "example.com", http.StatusPermanentRedirect, "https://example.com/form"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			httpsRedirectHandler(tt.port).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantURL {
+				t.Errorf("Expected Location %s, got %s", tt.wantURL, got)
+			}
+		})
+	}
+}
+
+func TestHTTPSRedirectListener(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, "example.com")
+	cfg := &config.Config{
+		Mode:   "http",
+		Listen: "127.0.0.1:0",
+		Backends: []config.Backend{
+			{Name: "backend", Address: "127.0.0.1:1", Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		TLS: &config.TLSConfig{
+			Enabled:      true,
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			RedirectHTTP: &config.HTTPRedirectConfig{Enabled: true, Listen: "127.0.0.1:0", HTTPSPort: 8443},
+		},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Shutdown()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	req, _ := http.NewRequest(http.MethodGet, "http://"+server.redirect.listener.Addr().String()+"/login?next=/", nil)
+	req.Host = "example.com"
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("Expected status 301, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Location"); got != "https://example.com:8443/login?next=/" {
+		t.Errorf("Unexpected Location: %s", got)
+	}
+}
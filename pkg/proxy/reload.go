@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"fmt"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+// Config returns the running configuration, including changes applied by ApplyConfig
+func (s *Server) Config() *config.Config {
+	if cfg := s.running.Load(); cfg != nil {
+		return cfg
+	}
+	return s.config
+}
+
+// ApplyConfig applies a validated configuration to the running proxy
+// Only changes to the static backends can be applied, as config.Change.Reloadable
+// describes; any other change fails the whole configuration, leaving the proxy as it
+// was. Backends are replaced in one step, so requests never see them partly updated,
+// and backends added through the admin API are dropped.
+func (s *Server) ApplyConfig(cfg *config.Config) error {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+
+	changes, err := config.Diff(s.Config(), cfg)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if !change.Reloadable() {
+			return fmt.Errorf("%s cannot be changed without a restart", change.Path)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if cfg.DiscoveryEnabled() {
+		return fmt.Errorf("backends cannot be changed at runtime while service discovery manages the pool")
+	}
+
+	added, removed, err := s.pool.ReplaceAll(configuredBackends(cfg, s.logger))
+	if err != nil {
+		s.logger.Warn("Skipped invalid backends", logging.Err(err))
+	}
+	// Backends that were kept take on their new connection limit
+	for _, backendCfg := range cfg.Backends {
+		if b := s.pool.Get(backendCfg.Name); b != nil {
+			b.SetMaxConnections(backendCfg.MaxConnections)
+		}
+	}
+	s.running.Store(cfg)
+
+	s.logger.Info("Applied configuration", logging.Int("changes", len(changes)),
+		logging.Int("backends_added", len(added)), logging.Int("backends_removed", len(removed)))
+	return nil
+}
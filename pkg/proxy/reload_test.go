@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+const reloadTestConfig = `
+mode: tcp
+listen: "127.0.0.1:0"
+backends:
+  - name: web-1
+    address: "127.0.0.1:9001"
+    weight: 1
+  - name: web-2
+    address: "127.0.0.1:9002"
+    weight: 1
+`
+
+// parseReloadConfig parses and validates a configuration for the reload tests
+func parseReloadConfig(t *testing.T, data string) *config.Config {
+	t.Helper()
+	cfg, err := config.Parse([]byte(data), "config.yaml")
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	return cfg
+}
+
+func TestApplyConfig(t *testing.T) {
+	server, err := NewTCPServer(parseReloadConfig(t, reloadTestConfig), nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	original := server.Pool().Get("web-1")
+
+	// The configuration the server started with is unchanged
+	if changes, err := config.Diff(server.Config(), parseReloadConfig(t, reloadTestConfig)); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes, got %v (%v)", changes, err)
+	}
+
+	restart := parseReloadConfig(t, strings.Replace(reloadTestConfig, "127.0.0.1:0", "127.0.0.1:1", 1))
+	if err := server.ApplyConfig(restart); err == nil || !strings.Contains(err.Error(), "listen") {
+		t.Fatalf("expected a listen change to be rejected, got %v", err)
+	}
+
+	next := strings.Replace(reloadTestConfig, "weight: 1\n  - name: web-2", "weight: 4\n    max_connections: 10\n  - name: web-2", 1)
+	next = strings.Replace(next, "web-2", "web-3", 1)
+	cfg := parseReloadConfig(t, next)
+	if err := server.ApplyConfig(cfg); err != nil {
+		t.Fatalf("failed to apply config: %v", err)
+	}
+
+	pool := server.Pool()
+	if pool.Size() != 2 || pool.Get("web-2") != nil || pool.Get("web-3") == nil {
+		t.Fatalf("expected web-2 to be replaced by web-3, got %d backends", pool.Size())
+	}
+	b := pool.Get("web-1")
+	if b != original {
+		t.Error("expected the unchanged backend to be kept")
+	}
+	if b.Weight() != 4 || b.MaxConnections() != 10 {
+		t.Errorf("expected weight 4 and 10 max connections, got %d and %d", b.Weight(), b.MaxConnections())
+	}
+	if server.Config() != cfg {
+		t.Error("expected the applied configuration to be running")
+	}
+}
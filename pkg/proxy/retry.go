@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/router"
+)
+
+// maxRetryableBody is the largest response body held back so a failed response can be retried
+const maxRetryableBody = 64 * 1024
+
+var (
+	// errNoBackend is returned by an attempt when no healthy backend is available
+	errNoBackend = errors.New("no healthy backend available")
+
+	// errPerTryTimeout is returned by an attempt that exceeded retry.per_try_timeout
+	errPerTryTimeout = errors.New("per-try timeout exceeded")
+)
+
+// responseError carries a backend response that matched retry_on
+// It is written to the client as-is if no further attempt is made
+type responseError struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("backend responded with status %d", e.status)
+}
+
+// write sends the held-back response to the client
+func (e *responseError) write(w http.ResponseWriter) {
+	for k, v := range e.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// retryConfig returns the retry settings for a request: the matched route's retry block
+// if it has one, otherwise the global resilience.retry (nil if retries are not configured)
+func (h *HTTPServer) retryConfig(route *router.RouteEntry) *config.RetryConfig {
+	if route != nil {
+		if retry := route.Config().Retry; retry != nil {
+			return retry
+		}
+	}
+	if h.config.Resilience != nil {
+		return h.config.Resilience.Retry
+	}
+	return nil
+}
+
+// requestTimeout returns the total time allowed for a request: the matched route's
+// timeout if it has one, otherwise timeouts.request (0 = no limit)
+func (h *HTTPServer) requestTimeout(route *router.RouteEntry) time.Duration {
+	if route != nil && route.Config().Timeout > 0 {
+		return route.Config().Timeout
+	}
+	return h.config.Timeouts.Request
+}
+
+// retryRules decides which failures of a single request are retried (per retry_on)
+type retryRules struct {
+	conditions    map[string]bool
+	nonIdempotent bool
+	idempotent    bool // whether the request method is idempotent
+	perTryTimeout time.Duration
+}
+
+// newRetryRules builds the retry rules for a request from its retry settings
+func newRetryRules(r *http.Request, retry *config.RetryConfig) *retryRules {
+	rules := &retryRules{
+		conditions:    make(map[string]bool, len(retry.RetryOn)),
+		nonIdempotent: retry.RetryNonIdempotent,
+		idempotent:    isIdempotent(r.Method),
+		perTryTimeout: retry.PerTryTimeout,
+	}
+	for _, cond := range retry.RetryOn {
+		rules.conditions[cond] = true
+	}
+	return rules
+}
+
+// retryableStatus reports whether a response with this status should be retried
+func (rr *retryRules) retryableStatus(status int) bool {
+	if rr == nil || !rr.methodAllowed(true) {
+		return false
+	}
+	return (rr.conditions["5xx"] && status >= 500 && status <= 599) || rr.conditions[strconv.Itoa(status)]
+}
+
+// retryableError reports whether a failed attempt should be retried
+func (rr *retryRules) retryableError(err error) bool {
+	var respErr *responseError
+	if errors.As(err, &respErr) {
+		return rr.retryableStatus(respErr.status)
+	}
+
+	// A slow backend is treated like one that answered 504 Gateway Timeout
+	if errors.Is(err, errPerTryTimeout) && len(rr.conditions) > 0 {
+		return rr.retryableStatus(http.StatusGatewayTimeout)
+	}
+
+	notSent := isNotSent(err)
+	if !rr.methodAllowed(!notSent) {
+		return false
+	}
+
+	// Without retry_on, any connection error is retried
+	if len(rr.conditions) == 0 || (len(rr.conditions) == 1 && rr.conditions["idempotent-only"]) {
+		return true
+	}
+
+	switch {
+	case rr.conditions["connect-failure"] && notSent:
+		return true
+	case rr.conditions["refused"] && errors.Is(err, syscall.ECONNREFUSED):
+		return true
+	case rr.conditions["reset"] && isReset(err):
+		return true
+	}
+	return false
+}
+
+// methodAllowed reports whether the request method may be retried
+// sent is whether the failed attempt reached a backend
+func (rr *retryRules) methodAllowed(sent bool) bool {
+	if rr.idempotent {
+		return true
+	}
+	if rr.conditions["idempotent-only"] {
+		return false
+	}
+	// Non-idempotent requests that never reached a backend are safe to retry
+	return !sent || rr.nonIdempotent
+}
+
+// retryPolicy builds the retry policy for a request along with the rules attempts use to
+// hold back retryable responses (nil rules if the request is not retried)
+// Requests are only retried if retries are enabled and the request can be replayed
+func (h *HTTPServer) retryPolicy(r *http.Request, route *router.RouteEntry) (resilience.RetryPolicy, *retryRules) {
+	policy := resilience.RetryPolicy{
+		MaxAttempts: 1,
+		RetryableErrors: func(err error) bool {
+			return !errors.Is(err, errNoBackend) && !errors.Is(err, errBackendsFull) && !errors.Is(err, errQueueFull) &&
+				!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+		},
+	}
+
+	retry := h.retryConfig(route)
+	if retry == nil || !retry.Enabled || retry.MaxAttempts <= 1 || !isReplayable(r) {
+		return policy, nil
+	}
+
+	rules := newRetryRules(r, retry)
+	retryable := policy.RetryableErrors
+	policy.RetryableErrors = func(err error) bool {
+		return retryable(err) && rules.retryableError(err)
+	}
+
+	policy.MaxAttempts = retry.MaxAttempts
+	policy.InitialDelay = retry.InitialDelay
+	policy.MaxDelay = retry.MaxDelay
+	policy.Multiplier = retry.Multiplier
+	policy.Jitter = retry.Jitter
+	policy.Budget = h.retries
+	policy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		h.logger.InfoContext(r.Context(), "Retrying request", logging.String("method", r.Method), logging.String("path", r.URL.Path),
+			logging.Int("attempt", attempt), logging.Int("max_attempts", retry.MaxAttempts), logging.Err(err), logging.Duration("backoff", delay))
+		if cr := logging.CaptureFromContext(r.Context()); cr != nil {
+			cr.AddRetry(attempt, err, delay)
+		}
+	}
+
+	return policy, rules
+}
+
+// holdRetryableResponse returns a responseError for a response that matches retry_on,
+// consuming its body; other responses (and oversized bodies) are passed through
+func holdRetryableResponse(resp *http.Response, rules *retryRules) error {
+	if !rules.retryableStatus(resp.StatusCode) || resp.ContentLength > maxRetryableBody {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRetryableBody+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxRetryableBody {
+		// Too large to hold back; stream what was read followed by the rest
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil
+	}
+
+	return &responseError{status: resp.StatusCode, header: resp.Header.Clone(), body: body}
+}
+
+// isReplayable reports whether a request can be sent again after a failed attempt
+// Request bodies are streamed to the backend, so only requests without a body are replayable
+func isReplayable(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0
+}
+
+// isIdempotent reports whether a request method is idempotent (RFC 9110)
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isNotSent reports whether an attempt failed before the request reached a backend
+func isNotSent(err error) bool {
+	if errors.Is(err, resilience.ErrCircuitOpen) || errors.Is(err, resilience.ErrTooManyRequests) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isReset reports whether the backend connection was reset or closed mid-request
+func isReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,330 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+)
+
+// closedAddress returns an address that refuses connections
+func closedAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func newRetryTestServer(t *testing.T, retry *config.RetryConfig, routes []config.Route) *HTTPServer {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(healthy.Close)
+
+	cfg := &config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "dead", Address: closedAddress(t), Weight: 1},
+			{Name: "healthy", Address: strings.TrimPrefix(healthy.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{Routes: routes},
+		Resilience:   &config.ResilienceConfig{Retry: retry},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	return server.httpServer
+}
+
+func TestHTTPRetry(t *testing.T) {
+	h := newRetryTestServer(t, &config.RetryConfig{
+		Enabled:      true,
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1,
+	}, nil)
+
+	// The first attempt goes to the dead backend and is retried on the healthy one
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("Expected retried request to succeed, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHTTPRetryBudget(t *testing.T) {
+	h := newRetryTestServer(t, &config.RetryConfig{
+		Enabled:      true,
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1,
+	}, nil)
+
+	// A budget with no room for retries
+	h.retries = resilience.NewRetryBudget(time.Minute, 0, 0)
+
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected %d once the retry budget is exhausted, got %d", http.StatusBadGateway, rec.Code)
+	}
+	if requests, retries, _ := h.retries.GetStats(); requests != 1 || retries != 0 {
+		t.Errorf("Expected 1 request and 0 retries, got %d and %d", requests, retries)
+	}
+}
+
+func TestHTTPRetryDisabledForRoute(t *testing.T) {
+	h := newRetryTestServer(t, &config.RetryConfig{
+		Enabled:      true,
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1,
+	}, []config.Route{
+		{Name: "payments", PathPrefix: "/payments", Retry: &config.RetryConfig{Enabled: false}},
+	})
+
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/payments/charge", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected %d without retries, got %d", http.StatusBadGateway, rec.Code)
+	}
+}
+
+func TestHTTPRetryEnabledForRoute(t *testing.T) {
+	h := newRetryTestServer(t, nil, []config.Route{
+		{Name: "search", PathPrefix: "/search", Retry: &config.RetryConfig{
+			Enabled:      true,
+			MaxAttempts:  2,
+			InitialDelay: time.Millisecond,
+			MaxDelay:     time.Millisecond,
+			Multiplier:   1,
+		}},
+	})
+
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/search", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected route retry to succeed, got %d", rec.Code)
+	}
+}
+
+func TestIsReplayable(t *testing.T) {
+	if !isReplayable(httptest.NewRequest(http.MethodGet, "/", nil)) {
+		t.Error("Expected request without body to be replayable")
+	}
+	if isReplayable(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("data"))) {
+		t.Error("Expected request with body not to be replayable")
+	}
+}
+
+// newStatusTestServer creates an HTTP server whose first backend always responds with status
+func newStatusTestServer(t *testing.T, status int, retry *config.RetryConfig) *HTTPServer {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", "failing")
+		w.WriteHeader(status)
+		w.Write([]byte("unavailable"))
+	}))
+	t.Cleanup(failing.Close)
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(healthy.Close)
+
+	cfg := &config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "failing", Address: strings.TrimPrefix(failing.URL, "http://"), Weight: 1},
+			{Name: "healthy", Address: strings.TrimPrefix(healthy.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		Resilience:   &config.ResilienceConfig{Retry: retry},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	return server.httpServer
+}
+
+func TestHTTPRetryOnStatus(t *testing.T) {
+	retry := &config.RetryConfig{
+		Enabled:      true,
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1,
+		RetryOn:      []string{"503"},
+	}
+
+	// The 503 from the first backend is held back and retried on the healthy one
+	h := newStatusTestServer(t, http.StatusServiceUnavailable, retry)
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("Expected 503 to be retried, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Statuses not listed in retry_on are passed through
+	h = newStatusTestServer(t, http.StatusInternalServerError, retry)
+	rec = httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 to be passed through, got %d", rec.Code)
+	}
+}
+
+func TestHTTPRetryOnLastAttemptPassesResponse(t *testing.T) {
+	h := newStatusTestServer(t, http.StatusServiceUnavailable, &config.RetryConfig{
+		Enabled:      true,
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1,
+		RetryOn:      []string{"5xx"},
+	})
+	h.retries = resilience.NewRetryBudget(time.Minute, 0, 0)
+
+	// No retry is allowed, so the backend's own response reaches the client
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "unavailable" {
+		t.Errorf("Expected backend 503 response, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Backend") != "failing" {
+		t.Errorf("Expected backend headers to be preserved, got %v", rec.Header())
+	}
+}
+
+func TestHTTPRetryNonIdempotent(t *testing.T) {
+	retry := &config.RetryConfig{
+		Enabled:      true,
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1,
+		RetryOn:      []string{"5xx", "connect-failure"},
+	}
+
+	// A POST that reached a backend is not retried
+	h := newStatusTestServer(t, http.StatusServiceUnavailable, retry)
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected POST not to be retried after reaching a backend, got %d", rec.Code)
+	}
+
+	// A POST that never reached a backend is retried
+	h = newRetryTestServer(t, retry, nil)
+	rec = httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected POST to be retried after a connect failure, got %d", rec.Code)
+	}
+
+	// Unless retries are restricted to idempotent requests
+	retry.RetryOn = append(retry.RetryOn, "idempotent-only")
+	h = newRetryTestServer(t, retry, nil)
+	rec = httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected idempotent-only to prevent POST retries, got %d", rec.Code)
+	}
+}
+
+// newSlowTestServer creates an HTTP server whose first backend takes a second to respond
+func newSlowTestServer(t *testing.T, cfg *config.Config) *HTTPServer {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("slow"))
+	}))
+	t.Cleanup(slow.Close)
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(healthy.Close)
+
+	cfg.Mode = "http"
+	cfg.Backends = []config.Backend{
+		{Name: "slow", Address: strings.TrimPrefix(slow.URL, "http://"), Weight: 1},
+		{Name: "healthy", Address: strings.TrimPrefix(healthy.URL, "http://"), Weight: 1},
+	}
+	cfg.LoadBalancer = config.LoadBalancerConfig{Algorithm: "round-robin"}
+	cfg.Timeouts.Connect = time.Second
+	if cfg.HTTP == nil {
+		cfg.HTTP = &config.HTTPConfig{}
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	return server.httpServer
+}
+
+func TestHTTPPerTryTimeout(t *testing.T) {
+	h := newSlowTestServer(t, &config.Config{
+		Resilience: &config.ResilienceConfig{Retry: &config.RetryConfig{
+			Enabled:       true,
+			MaxAttempts:   2,
+			InitialDelay:  time.Millisecond,
+			MaxDelay:      time.Millisecond,
+			Multiplier:    1,
+			PerTryTimeout: 50 * time.Millisecond,
+		}},
+	})
+
+	// The slow attempt is abandoned after 50ms and retried on the healthy backend
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("Expected retry after per-try timeout, got %d %q", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected per-try timeout to cut the slow attempt short, took %s", elapsed)
+	}
+}
+
+func TestHTTPRequestTimeout(t *testing.T) {
+	h := newSlowTestServer(t, &config.Config{
+		HTTP: &config.HTTPConfig{Routes: []config.Route{
+			{Name: "reports", PathPrefix: "/reports", Timeout: 50 * time.Millisecond},
+		}},
+	})
+
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/reports", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected %d when the route timeout expires, got %d", http.StatusGatewayTimeout, rec.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+// rewriteHost sets the Host header sent to the backend according to the route:
+// host_rewrite replaces it, preserve_host: false uses the backend address, and
+// otherwise the client's Host header is kept
+func rewriteHost(req *http.Request, route config.Route) {
+	switch {
+	case route.HostRewrite != "":
+		req.Host = route.HostRewrite
+	case route.PreserveHost != nil && !*route.PreserveHost:
+		req.Host = req.URL.Host
+	}
+}
+
+// rewritePath applies the route's strip_prefix and add_prefix to the request path
+// The prefixes are applied to the escaped path, so encoded characters such as %2F are kept
+func rewritePath(req *http.Request, route config.Route) {
+	if route.StripPrefix == "" && route.AddPrefix == "" {
+		return
+	}
+
+	path := req.URL.EscapedPath()
+	if route.StripPrefix != "" {
+		path = strings.TrimPrefix(path, route.StripPrefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	if route.AddPrefix != "" && !strings.HasPrefix(path, route.AddPrefix) {
+		path = route.AddPrefix + path
+	}
+
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return
+	}
+	req.URL.Path = unescaped
+	req.URL.RawPath = path
+}
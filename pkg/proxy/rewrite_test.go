@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestRouteHostRewrite(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer backendServer.Close()
+
+	address := strings.TrimPrefix(backendServer.URL, "http://")
+	preserve := false
+	cfg := &config.Config{
+		Mode:         "http",
+		Backends:     []config.Backend{{Name: "backend", Address: address, Weight: 1}},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP: &config.HTTPConfig{Routes: []config.Route{
+			{Name: "rewrite", PathPrefix: "/rewrite", HostRewrite: "api.internal", Backends: []string{"backend"}},
+			{Name: "backend-host", PathPrefix: "/backend-host", PreserveHost: &preserve, Backends: []string{"backend"}},
+			{Name: "default", PathPrefix: "/", Backends: []string{"backend"}},
+		}},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/rewrite", "api.internal"},
+		{"/backend-host", address},
+		{"/other", "public.example.com"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Host = "public.example.com"
+		rec := httptest.NewRecorder()
+		server.httpServer.handleRequest(rec, req)
+
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("%s: expected backend to see Host %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestRoutePathPrefix(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	defer backendServer.Close()
+
+	cfg := &config.Config{
+		Mode:         "http",
+		Backends:     []config.Backend{{Name: "backend", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1}},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP: &config.HTTPConfig{Routes: []config.Route{
+			{Name: "api", PathPrefix: "/api/", StripPrefix: "/api", Backends: []string{"backend"}},
+			{Name: "legacy", PathPrefix: "/legacy/", Backends: []string{"backend"}},
+			{Name: "v2", PathPrefix: "/v2/", StripPrefix: "/v2", AddPrefix: "/internal", Backends: []string{"backend"}},
+		}},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/api/users?page=2", "/users?page=2"},
+		{"/legacy/users", "/legacy/users"},
+		{"/v2/orders/a%2Fb", "/internal/orders/a%2Fb"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.httpServer.handleRequest(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("%s: expected backend to see %q, got %q", tt.target, tt.want, got)
+		}
+	}
+}
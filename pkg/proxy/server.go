@@ -2,9 +2,9 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -12,23 +12,69 @@ import (
 
 	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
 	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/discovery"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/health"
 	"github.com/therealutkarshpriyadarshi/balance/pkg/lb"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/pool"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/resilience"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/security"
+	balancetls "github.com/therealutkarshpriyadarshi/balance/pkg/tls"
 )
 
 // Server represents a proxy server
 type Server struct {
 	config   *config.Config
 	listener net.Listener
+	limiter  *limitListener
 	pool     *backend.Pool
 	balancer lb.LoadBalancer
 
-	// HTTP server (for HTTP mode)
+	// Configuration applied by ApplyConfig (nil until one is)
+	running atomic.Pointer[config.Config]
+	applyMu sync.Mutex
+
+	// Optional components (nil when disabled)
+	checker   *health.Checker
+	discovery *discovery.Manager
+	adaptive  *health.AdaptiveWeights
+	security  *security.SecurityManager
+	audit     *logging.AuditLogger
+	breakers  *circuitBreakers
+	bandwidth *bandwidthManager
+	workers   *pool.GoroutinePool
+
+	logger *logging.Logger
+
+	// Reusable buffers for the copy loops
+	buffers *pool.BufferPool
+
+	// TLS termination (nil when disabled)
+	termination *tlsTermination
+	tlsRoutes   *tlsRoutes
+
+	// Backend TLS (nil when disabled)
+	spiffe     *balancetls.SPIFFESource
+	backendTLS *tls.Config
+
+	// HTTP server (for HTTP and auto mode)
 	httpServer *HTTPServer
 
+	// Plaintext listener redirecting to HTTPS (nil when disabled)
+	redirect *httpsRedirect
+
+	// Protocol detection (auto mode): HTTP connections are handed to httpServer
+	// through httpListener, others are proxied as raw TCP
+	sniff        bool
+	httpListener *connListener
+
 	// Graceful shutdown
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 	wg         sync.WaitGroup
+	conns      *connTracker
+	draining   atomic.Bool
 
 	// Statistics
 	totalConnections    atomic.Int64
@@ -37,43 +83,77 @@ type Server struct {
 	totalBytesSent      atomic.Int64
 }
 
-// NewTCPServer creates a new TCP proxy server
-func NewTCPServer(cfg *config.Config) (*Server, error) {
+// NewTCPServer creates a new TCP proxy server logging to logger
+func NewTCPServer(cfg *config.Config, logger *logging.Logger) (*Server, error) {
 	// Create backend pool
-	pool := backend.NewPool()
-	for _, backendCfg := range cfg.Backends {
-		b := backend.NewBackend(backendCfg.Name, backendCfg.Address, backendCfg.Weight)
-		pool.Add(b)
+	pool, err := newBackendPool(cfg, logger)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create load balancer
-	var balancer lb.LoadBalancer
-
-	switch cfg.LoadBalancer.Algorithm {
-	case "round-robin":
-		balancer = lb.NewRoundRobin(pool)
-	case "least-connections":
-		balancer = lb.NewLeastConnections(pool)
-	case "weighted-round-robin":
-		balancer = lb.NewWeightedRoundRobin(pool)
-	case "weighted-least-connections":
-		balancer = lb.NewWeightedLeastConnections(pool)
-	case "consistent-hash":
-		balancer = lb.NewConsistentHash(pool, lb.DefaultVirtualNodes, cfg.LoadBalancer.HashKey)
-	case "bounded-consistent-hash":
-		balancer = lb.NewBoundedLoadConsistentHash(pool, lb.DefaultVirtualNodes, cfg.LoadBalancer.HashKey, 1.25)
-	default:
-		return nil, fmt.Errorf("unsupported load balancer algorithm: %s", cfg.LoadBalancer.Algorithm)
+	balancer, err := newLoadBalancer(cfg, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	secManager, err := newSecurityManager(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := newAuditLog(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	syncPoolMetrics(pool)
+	checker := newHealthChecker(cfg, pool, logger)
+	disc, err := newDiscovery(cfg, pool, checker)
+	if err != nil {
+		return nil, err
+	}
+
+	termination, err := newTLSTermination(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if termination != nil {
+		termination.audit = audit
+	}
+	tlsRoutes, err := newTLSRoutes(cfg, pool, logger)
+	if err != nil {
+		return nil, err
+	}
+	spiffe := newSPIFFESource(cfg, termination, logger)
+	backendTLS, err := newBackendTLSConfig(cfg, spiffe)
+	if err != nil {
+		return nil, err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &Server{
-		config:     cfg,
-		pool:       pool,
-		balancer:   balancer,
-		ctx:        ctx,
-		cancelFunc: cancel,
+		config:      cfg,
+		pool:        pool,
+		balancer:    balancer,
+		checker:     checker,
+		discovery:   disc,
+		adaptive:    newAdaptiveWeights(cfg, checker, pool, logger),
+		security:    secManager,
+		audit:       audit,
+		breakers:    newCircuitBreakers(cfg, pool),
+		bandwidth:   newBandwidthManager(cfg),
+		workers:     newConnectionWorkers(cfg),
+		buffers:     newCopyBufferPool(cfg),
+		termination: termination,
+		tlsRoutes:   tlsRoutes,
+		spiffe:      spiffe,
+		backendTLS:  backendTLS,
+		redirect:    newHTTPSRedirect(cfg, newACMEChallengeProxy(cfg, pool, logger), logger),
+		logger:      logger,
+		ctx:         ctx,
+		cancelFunc:  cancel,
+		conns:       newConnTracker(),
 	}, nil
 }
 
@@ -81,18 +161,57 @@ func NewTCPServer(cfg *config.Config) (*Server, error) {
 
 // Start starts the proxy server
 func (s *Server) Start() error {
+	// Start health checking if enabled
+	if s.checker != nil {
+		if err := s.checker.Start(); err != nil {
+			return fmt.Errorf("failed to start health checker: %w", err)
+		}
+	}
+	if s.adaptive != nil {
+		s.adaptive.Start()
+	}
+	if s.discovery != nil {
+		s.discovery.Start()
+	}
+
+	if s.redirect != nil {
+		if err := s.redirect.start(&s.wg); err != nil {
+			return err
+		}
+	}
+
 	// If HTTP server is configured, start it
-	if s.httpServer != nil {
+	if s.httpServer != nil && !s.sniff {
 		return s.httpServer.Start()
 	}
 
 	// Otherwise, start TCP server
-	listener, err := net.Listen("tcp", s.config.Listen)
+	listener, limiter, err := listen(s.config)
 	if err != nil {
-		return fmt.Errorf("failed to start listener: %w", err)
+		return err
+	}
+	if s.termination != nil {
+		// In auto mode TLS is terminated after protocol detection
+		if !s.sniff {
+			listener = s.termination.listener(listener)
+		}
+		s.termination.start(s.ctx, &s.wg)
+	}
+	if s.spiffe != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.spiffe.Run(s.ctx)
+		}()
 	}
 
 	s.listener = listener
+	s.limiter = limiter
+
+	if s.sniff {
+		s.httpListener = newConnListener(listener.Addr())
+		s.httpServer.serve(s.httpListener)
+	}
 
 	// Start accepting connections
 	s.wg.Add(1)
@@ -113,14 +232,12 @@ func (s *Server) acceptLoop() {
 				// Server is shutting down
 				return
 			default:
-				log.Printf("Failed to accept connection: %v", err)
+				s.logger.Error("Failed to accept connection", logging.Err(err))
 				continue
 			}
 		}
 
-		// Handle connection in a goroutine
-		s.wg.Add(1)
-		go s.handleConnection(conn)
+		s.dispatch(conn)
 	}
 }
 
@@ -129,6 +246,11 @@ func (s *Server) handleConnection(clientConn net.Conn) {
 	defer s.wg.Done()
 	defer clientConn.Close()
 
+	// Connections over max_connections may be queued for a slot
+	if err := waitForSlot(clientConn); err != nil {
+		return
+	}
+
 	// Update statistics
 	s.totalConnections.Add(1)
 	s.activeConnections.Add(1)
@@ -140,11 +262,45 @@ func (s *Server) handleConnection(clientConn net.Conn) {
 		clientIP = tcpAddr.IP.String()
 	}
 
+	// Apply blocklist, rate limit and per-IP connection limits
+	if s.security != nil {
+		if allowed, reason := s.security.AllowConnection(clientIP); !allowed {
+			s.logger.Warn("Rejected connection", logging.String("client_ip", clientIP), logging.String("reason", reason))
+			eventType := logging.AuditBlocked
+			if reason == "Rate limit exceeded" {
+				eventType = logging.AuditRateLimited
+			}
+			auditConnection(s.audit, eventType, clientIP, reason, nil)
+			return
+		}
+		defer s.security.ReleaseConnection(clientIP)
+	}
+
+	// Terminate TLS first so the SNI hostname or ALPN protocol can select the backends
+	balancer := s.balancer
+	if tlsConn, ok := clientConn.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(s.ctx, tlsHandshakeTimeout)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			s.logger.Warn("TLS handshake failed", logging.String("client_ip", clientIP), logging.Err(err))
+			auditHandshakeFailure(s.audit, clientIP, err)
+			if s.security != nil {
+				s.security.RecordEvent(clientIP, security.BanEventTLSHandshakeFailure)
+			}
+			return
+		}
+		state := tlsConn.ConnectionState()
+		if tlsBalancer := s.tlsRoutes.balancerFor(&state); tlsBalancer != nil {
+			balancer = tlsBalancer
+		}
+	}
+
 	// Select a backend using load balancer
 	var selectedBackend *backend.Backend
 
 	// Check if the balancer supports key-based selection
-	switch balancer := s.balancer.(type) {
+	switch balancer := balancer.(type) {
 	case interface{ SelectWithKey(string) *backend.Backend }:
 		// Use consistent hash with client IP
 		selectedBackend = balancer.SelectWithKey(clientIP)
@@ -153,62 +309,135 @@ func (s *Server) handleConnection(clientConn net.Conn) {
 		selectedBackend = balancer.SelectWithClientIP(clientIP)
 	default:
 		// Use standard selection
-		selectedBackend = s.balancer.Select()
+		selectedBackend = balancer.Select()
 	}
 
 	if selectedBackend == nil {
-		log.Printf("No healthy backend available")
+		s.logger.Error("No healthy backend available", logging.String("client_ip", clientIP))
+		metrics.IncTCPConnectionErrors("", "no_backend")
 		return
 	}
 
 	// Track connection for this backend
-	selectedBackend.IncrementConnections()
+	if !selectedBackend.TryIncrementConnections() {
+		s.logger.Warn("Backend is at its connection limit, closing connection",
+			logging.String("backend", selectedBackend.Name()), logging.String("client_ip", clientIP))
+		metrics.IncTCPConnectionErrors(selectedBackend.Name(), "connection_limit")
+		return
+	}
 	defer selectedBackend.DecrementConnections()
 
-	log.Printf("Routing connection from %s to backend: %s", clientIP, selectedBackend.Address())
+	if s.logger.Enabled(logging.DebugLevel) {
+		fields := []logging.Field{logging.String("client_ip", clientIP), logging.String("backend", selectedBackend.Address())}
+		if ja3 := connJA3(clientConn); ja3 != "" {
+			fields = append(fields, logging.String("ja3", ja3))
+		}
+		s.logger.Debug("Routing connection", fields...)
+	}
 
 	// Connect to backend with timeout
-	dialer := net.Dialer{
-		Timeout: s.config.Timeouts.Connect,
+	dialer := newDialer(s.config)
+
+	var backendConn net.Conn
+	dial := func() error {
+		var err error
+		backendConn, err = dialer.DialContext(s.ctx, "tcp", selectedBackend.Address())
+		if err != nil || s.backendTLS == nil {
+			return err
+		}
+		tlsConn, err := backendTLSClient(s.ctx, backendConn, selectedBackend.Address(), s.backendTLS)
+		if err != nil {
+			backendConn.Close()
+			return err
+		}
+		backendConn = tlsConn
+		return nil
 	}
 
-	backendConn, err := dialer.DialContext(s.ctx, "tcp", selectedBackend.Address())
+	start := time.Now()
+	var err error
+	if breaker := s.breakers.get(selectedBackend.Name()); breaker != nil {
+		err = breaker.Execute(dial)
+	} else {
+		err = dial()
+	}
+	if s.checker != nil {
+		s.checker.RecordRequest(selectedBackend, err == nil, time.Since(start))
+	}
+	if observer, ok := balancer.(lb.LatencyObserver); ok && err == nil {
+		observer.Observe(selectedBackend, time.Since(start))
+	}
 	if err != nil {
-		log.Printf("Failed to connect to backend %s: %v", selectedBackend.Address(), err)
-		selectedBackend.MarkUnhealthy()
+		s.logger.Error("Failed to connect to backend", logging.String("backend", selectedBackend.Address()), logging.Err(err))
+		if err != resilience.ErrCircuitOpen && err != resilience.ErrTooManyRequests {
+			selectedBackend.MarkUnhealthy()
+			metrics.IncTCPConnectionErrors(selectedBackend.Name(), "connect")
+		} else {
+			metrics.IncTCPConnectionErrors(selectedBackend.Name(), "circuit_open")
+		}
 		return
 	}
 	defer backendConn.Close()
-
-	// Set timeouts
-	if s.config.Timeouts.Read > 0 {
-		clientConn.SetReadDeadline(time.Now().Add(s.config.Timeouts.Read))
-		backendConn.SetReadDeadline(time.Now().Add(s.config.Timeouts.Read))
+	metrics.RecordTCPConnect(selectedBackend.Name(), time.Since(start))
+	metrics.IncTCPConnections(selectedBackend.Name())
+	defer metrics.DecTCPConnectionsActive(selectedBackend.Name())
+
+	// Refresh write deadlines on every operation and close the stream once it is idle
+	activity := newStreamActivity()
+	timeouts := s.config.Timeouts
+	if timeouts.TCPIdle > 0 {
+		client, backend := clientConn, backendConn
+		stop := activity.watchIdle(timeouts.TCPIdle, func() {
+			s.logger.Debug("Closing idle connection",
+				logging.String("client_ip", clientIP), logging.String("backend", selectedBackend.Address()))
+			client.Close()
+			backend.Close()
+		})
+		defer stop()
 	}
-	if s.config.Timeouts.Write > 0 {
-		clientConn.SetWriteDeadline(time.Now().Add(s.config.Timeouts.Write))
-		backendConn.SetWriteDeadline(time.Now().Add(s.config.Timeouts.Write))
+	clientConn = newDeadlineConn(clientConn, timeouts.Read, timeouts.Write, activity)
+	backendConn = newDeadlineConn(backendConn, timeouts.Read, timeouts.Write, activity)
+
+	// Let shutdown close the stream early once it goes quiet
+	untrack := s.conns.add(activity, func() {
+		clientConn.Close()
+		backendConn.Close()
+	})
+	defer untrack()
+
+	// Apply bandwidth limits
+	var upload, download []*byteRateLimiter
+	if s.bandwidth != nil {
+		upload, download = s.bandwidth.acquire(selectedBackend.Name(), clientIP)
+		defer s.bandwidth.release(clientIP)
 	}
 
 	// Proxy data bidirectionally
-	s.proxyData(clientConn, backendConn)
+	s.proxyData(clientConn, backendConn, selectedBackend.Name(), upload, download)
 }
 
-// proxyData proxies data between client and backend connections
-func (s *Server) proxyData(clientConn, backendConn net.Conn) {
+// proxyData proxies data between client and backend connections, recording the bytes
+// transferred under the backend's name
+// Each direction is throttled by its own limiters; a direction without limiters keeps
+// the zero-copy path.
+func (s *Server) proxyData(clientConn, backendConn net.Conn, backendName string, upload, download []*byteRateLimiter) {
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	// Client -> Backend
 	go func() {
 		defer wg.Done()
-		n, err := io.Copy(backendConn, clientConn)
+		buf := s.buffers.Get()
+		defer s.buffers.Put(buf)
+		n, err := io.CopyBuffer(newThrottledWriter(s.ctx, backendConn, upload), clientConn, buf)
 		if err != nil && err != io.EOF {
-			log.Printf("Error copying client -> backend: %v", err)
+			s.logger.Warn("Error copying client -> backend", logging.Err(err))
+			metrics.IncTCPConnectionErrors(backendName, "stream")
 		}
 		s.totalBytesReceived.Add(n)
+		metrics.AddTCPBytes(backendName, "received", n)
 		// Close write side to signal EOF
-		if conn, ok := backendConn.(*net.TCPConn); ok {
+		if conn, ok := backendConn.(interface{ CloseWrite() error }); ok {
 			conn.CloseWrite()
 		}
 	}()
@@ -216,13 +445,17 @@ func (s *Server) proxyData(clientConn, backendConn net.Conn) {
 	// Backend -> Client
 	go func() {
 		defer wg.Done()
-		n, err := io.Copy(clientConn, backendConn)
+		buf := s.buffers.Get()
+		defer s.buffers.Put(buf)
+		n, err := io.CopyBuffer(newThrottledWriter(s.ctx, clientConn, download), backendConn, buf)
 		if err != nil && err != io.EOF {
-			log.Printf("Error copying backend -> client: %v", err)
+			s.logger.Warn("Error copying backend -> client", logging.Err(err))
+			metrics.IncTCPConnectionErrors(backendName, "stream")
 		}
 		s.totalBytesSent.Add(n)
+		metrics.AddTCPBytes(backendName, "sent", n)
 		// Close write side to signal EOF
-		if conn, ok := clientConn.(*net.TCPConn); ok {
+		if conn, ok := clientConn.(interface{ CloseWrite() error }); ok {
 			conn.CloseWrite()
 		}
 	}()
@@ -230,15 +463,56 @@ func (s *Server) proxyData(clientConn, backendConn net.Conn) {
 	wg.Wait()
 }
 
+// StateMachine returns a backend's health state machine (nil if health checking is disabled)
+func (s *Server) StateMachine(name string) *backend.StateMachine {
+	if s.checker == nil {
+		return nil
+	}
+	sm, err := s.checker.GetStateMachine(name)
+	if err != nil {
+		return nil
+	}
+	return sm
+}
+
+// Draining reports whether the server is shutting down
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
 // Shutdown gracefully shuts down the server
+// New connections are refused, idle connections are closed first, and connections still
+// active after shutdown.drain_timeout are force-closed
 func (s *Server) Shutdown() error {
+	s.draining.Store(true)
+	defer s.audit.Close()
+	s.announceShutdown()
+
+	// Stop discovery, weight adjustment and health checking
+	if s.discovery != nil {
+		s.discovery.Stop()
+	}
+	if s.adaptive != nil {
+		s.adaptive.Stop()
+	}
+	if s.checker != nil {
+		s.checker.Stop()
+	}
+	if s.security != nil {
+		s.security.Stop()
+	}
+
+	if s.redirect != nil {
+		s.redirect.shutdown()
+	}
+
 	// If HTTP server is configured, shut it down
-	if s.httpServer != nil {
+	if s.httpServer != nil && !s.sniff {
 		return s.httpServer.Shutdown()
 	}
 
 	// Otherwise, shutdown TCP server
-	log.Println("Shutting down proxy server...")
+	s.logger.Info("Shutting down proxy server")
 
 	// Stop accepting new connections
 	s.cancelFunc()
@@ -246,30 +520,43 @@ func (s *Server) Shutdown() error {
 	// Close listener
 	if s.listener != nil {
 		if err := s.listener.Close(); err != nil {
-			log.Printf("Error closing listener: %v", err)
+			s.logger.Error("Error closing listener", logging.Err(err))
 		}
 	}
 
-	// Wait for all active connections to finish
+	// In auto mode HTTP connections drain alongside raw TCP streams
+	httpDone := make(chan struct{})
+	if s.sniff {
+		go func() {
+			s.httpServer.Shutdown()
+			close(httpDone)
+		}()
+	} else {
+		close(httpDone)
+	}
+
+	// Wait for active connections to finish, closing idle ones early
 	done := make(chan struct{})
 	go func() {
 		s.wg.Wait()
 		close(done)
 	}()
 
-	// Wait with timeout
-	select {
-	case <-done:
-		log.Println("All connections closed")
-	case <-time.After(30 * time.Second):
-		log.Println("Shutdown timeout exceeded, forcing shutdown")
+	timeout := drainTimeout(s.config)
+	s.logger.Info("Draining connections", logging.Duration("timeout", timeout))
+	s.conns.drain(done, timeout, s.logger)
+	<-httpDone
+
+	// Stop the connection workers; queued connections were closed with the others
+	if s.workers != nil {
+		s.workers.CloseWithTimeout(drainPollInterval)
 	}
 
 	// Print final statistics
-	log.Printf("Final statistics:")
-	log.Printf("  Total connections: %d", s.totalConnections.Load())
-	log.Printf("  Bytes received: %d", s.totalBytesReceived.Load())
-	log.Printf("  Bytes sent: %d", s.totalBytesSent.Load())
+	s.logger.Info("Final statistics",
+		logging.Int64("total_connections", s.totalConnections.Load()),
+		logging.Int64("bytes_received", s.totalBytesReceived.Load()),
+		logging.Int64("bytes_sent", s.totalBytesSent.Load()))
 
 	return nil
 }
@@ -277,15 +564,159 @@ func (s *Server) Shutdown() error {
 // Stats returns current server statistics
 func (s *Server) Stats() map[string]interface{} {
 	// If HTTP server is configured, return its stats
-	if s.httpServer != nil {
+	if s.httpServer != nil && !s.sniff {
 		return s.httpServer.Stats()
 	}
 
 	// Otherwise, return TCP stats
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"total_connections":    s.totalConnections.Load(),
 		"active_connections":   s.activeConnections.Load(),
 		"total_bytes_received": s.totalBytesReceived.Load(),
 		"total_bytes_sent":     s.totalBytesSent.Load(),
 	}
+	if s.limiter != nil {
+		stats["connection_limit"] = s.limiter.Stats()
+	}
+	if s.workers != nil {
+		stats["connection_workers"] = s.workerStats()
+	}
+	if s.sniff {
+		stats["http"] = s.httpServer.Stats()
+	}
+
+	return stats
+}
+
+// Pool returns the backend pool
+func (s *Server) Pool() *backend.Pool {
+	return s.pool
+}
+
+// AuditLog returns the security event audit log (nil if audit logging is disabled)
+func (s *Server) AuditLog() *logging.AuditLogger {
+	return s.audit
+}
+
+// Capture returns the debug capture of HTTP requests (nil in TCP mode)
+func (s *Server) Capture() *logging.RequestCapture {
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.capture
+}
+
+// CircuitBreakers returns the circuit breakers by backend name (nil if circuit breaking is disabled)
+func (s *Server) CircuitBreakers() map[string]*resilience.CircuitBreaker {
+	return s.breakers.all()
+}
+
+// Certificates returns the certificates served by the TLS listener (nil if TLS
+// termination is disabled)
+func (s *Server) Certificates() *balancetls.CertificateManager {
+	if s.termination == nil {
+		return nil
+	}
+	return s.termination.certs
+}
+
+// ReloadCertificates reads the TLS certificate and CRL files again, keeping the current
+// certificate of any file that fails to load
+func (s *Server) ReloadCertificates() error {
+	if s.termination == nil {
+		return fmt.Errorf("TLS termination is not enabled")
+	}
+	return s.termination.reloadCertificates()
+}
+
+// Blocklist returns the IP blocklist (nil if security is disabled)
+func (s *Server) Blocklist() *security.IPBlocklist {
+	if s.security == nil {
+		return nil
+	}
+	return s.security.Blocklist()
+}
+
+// ResetRateLimit clears the rate limit state of a key (a client IP, or the tenant or
+// API key requests are rate limited by) for every priority class, returning false if
+// rate limiting is disabled
+func (s *Server) ResetRateLimit(key string) bool {
+	if s.security == nil {
+		return false
+	}
+	return s.security.ResetRateLimit(key, resilience.PriorityLow.String(), resilience.PriorityHigh.String())
+}
+
+// AdminStats returns proxy, pool, backend, security and circuit breaker statistics
+func (s *Server) AdminStats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"mode":  s.config.Mode,
+		"proxy": s.Stats(),
+		"pool":  s.pool.Stats(),
+	}
+
+	backends := make([]map[string]interface{}, 0, s.pool.Size())
+	for _, b := range s.pool.All() {
+		entry := map[string]interface{}{
+			"name":               b.Name(),
+			"address":            b.Address(),
+			"weight":             b.Weight(),
+			"weight_factor":      b.WeightFactor(),
+			"priority":           b.Priority(),
+			"backup":             b.IsBackup(),
+			"healthy":            b.IsHealthy(),
+			"draining":           b.IsDraining(),
+			"active_connections": b.ActiveConnections(),
+		}
+
+		if s.checker != nil {
+			if sm, err := s.checker.GetStateMachine(b.Name()); err == nil {
+				entry["state"] = sm.GetState().String()
+				entry["consecutive_successes"] = sm.GetConsecutiveSuccesses()
+				entry["consecutive_failures"] = sm.GetConsecutiveFailures()
+				entry["total_requests"] = sm.GetTotalRequests()
+				entry["failed_requests"] = sm.GetFailedRequests()
+				entry["error_rate"] = sm.GetErrorRate()
+				entry["avg_response_time_ms"] = sm.GetAverageResponseTime().Milliseconds()
+				entry["last_check"] = sm.GetLastCheckTime()
+				entry["last_state_change"] = sm.GetLastStateChangeTime()
+			}
+			if res, ok := s.checker.GetResolution(b.Name()); ok {
+				entry["resolved_addresses"] = res.Addresses
+				if res.Error != nil {
+					entry["resolve_error"] = res.Error.Error()
+				}
+			}
+		}
+
+		backends = append(backends, entry)
+	}
+	stats["backends"] = backends
+
+	if s.discovery != nil && s.config.Discovery != nil && s.config.Discovery.Prune != nil {
+		stats["quarantined_backends"] = s.discovery.Quarantined()
+	}
+
+	if s.security != nil {
+		stats["security"] = s.security.Stats()
+	}
+
+	if all := s.breakers.all(); len(all) > 0 {
+		breakers := make(map[string]interface{}, len(all))
+		for name, cb := range all {
+			m := cb.GetMetrics()
+			breakers[name] = map[string]interface{}{
+				"state":                m.State.String(),
+				"total_requests":       m.TotalRequests,
+				"total_successes":      m.TotalSuccesses,
+				"total_failures":       m.TotalFailures,
+				"total_rejected":       m.TotalRejected,
+				"consecutive_failures": m.ConsecutiveFailures,
+				"state_changed_at":     m.StateChangedAt,
+			}
+		}
+		stats["circuit_breakers"] = breakers
+	}
+
+	return stats
 }
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
+)
+
+func TestTCPMetrics(t *testing.T) {
+	// Echo backend
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	// A backend that refuses connections
+	refused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	refusedAddr := refused.Addr().String()
+	refused.Close()
+
+	newServer := func(name, address string) *Server {
+		server, err := NewTCPServer(&config.Config{
+			Mode:         "tcp",
+			Listen:       "127.0.0.1:0",
+			Backends:     []config.Backend{{Name: name, Address: address, Weight: 1}},
+			LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+			Timeouts:     config.TimeoutConfig{Connect: time.Second},
+		}, nil)
+		if err != nil {
+			t.Fatalf("Failed to create TCP server: %v", err)
+		}
+		if err := server.Start(); err != nil {
+			t.Fatalf("Failed to start server: %v", err)
+		}
+		return server
+	}
+
+	echo := newServer("tcp-echo", ln.Addr().String())
+	defer echo.Shutdown()
+	conn, err := net.Dial("tcp", echo.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn.Write([]byte("hello"))
+	conn.(*net.TCPConn).CloseWrite()
+	if body, _ := io.ReadAll(conn); string(body) != "hello" {
+		t.Errorf("Expected echo, got %q", body)
+	}
+	conn.Close()
+
+	dead := newServer("tcp-refused", refusedAddr)
+	defer dead.Shutdown()
+	conn, err = net.Dial("tcp", dead.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	io.ReadAll(conn)
+	conn.Close()
+
+	want := []string{
+		`balance_tcp_connections_total{backend="tcp-echo"} 1`,
+		`balance_tcp_connections_active{backend="tcp-echo"} 0`,
+		`balance_tcp_connect_duration_seconds_count{backend="tcp-echo"} 1`,
+		`balance_tcp_bytes_total{backend="tcp-echo",direction="received"} 5`,
+		`balance_tcp_bytes_total{backend="tcp-echo",direction="sent"} 5`,
+		`balance_tcp_connection_errors_total{backend="tcp-refused",reason="connect"} 1`,
+	}
+
+	// The proxy finishes recording a connection after the client sees it closed
+	var body string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rec := httptest.NewRecorder()
+		metrics.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body = rec.Body.String()
+		missing := false
+		for _, line := range want {
+			missing = missing || !strings.Contains(body, line)
+		}
+		if !missing {
+			return
+		}
+	}
+	for _, line := range want {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %s in metrics", line)
+		}
+	}
+}
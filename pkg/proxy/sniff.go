@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/security"
+)
+
+// tlsRecordHandshake is the first byte of a TLS ClientHello record
+const tlsRecordHandshake = 0x16
+
+// httpPrefixes are the starts of HTTP/1.x requests and the HTTP/2 connection preface
+var httpPrefixes = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("HEAD "), []byte("DELETE "),
+	[]byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "), []byte("TRACE "), []byte("PRI * "),
+}
+
+// maxHTTPPrefix is the length of the longest entry in httpPrefixes
+const maxHTTPPrefix = 8
+
+// NewAutoServer creates a proxy server that serves TLS, plaintext HTTP and raw TCP
+// on one port, detecting the protocol of each connection from its first bytes
+// TLS connections are terminated first; they are served as HTTP if they negotiate
+// an HTTP ALPN protocol and proxied as raw TCP otherwise.
+func NewAutoServer(cfg *config.Config, logger *logging.Logger) (*Server, error) {
+	server, err := NewHTTPServer(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Raw TCP connections share the HTTP server's backends and TLS state
+	h := server.httpServer
+	server.sniff = true
+	server.bandwidth = h.bandwidth
+	server.buffers = h.buffers
+	server.termination = h.termination
+	server.tlsRoutes = h.tlsRoutes
+	server.spiffe = h.spiffe
+	server.backendTLS = h.backendTLS
+	server.conns = newConnTracker()
+	server.workers = newConnectionWorkers(cfg)
+	return server, nil
+}
+
+// handleSniffedConnection detects the protocol of a connection and hands it to the
+// HTTP server or proxies it as raw TCP
+func (s *Server) handleSniffedConnection(conn net.Conn) {
+	defer s.wg.Done()
+
+	// Wait for a queued connection's slot first, so it does not eat into sniff_timeout
+	if err := waitForSlot(conn); err != nil {
+		conn.Close()
+		return
+	}
+
+	var tlsState *tls.ConnectionState
+	first, conn := sniffConn(conn, s.config.SniffTimeout, 1)
+	if len(first) == 0 {
+		// The client is waiting for the server to speak first
+		s.wg.Add(1)
+		s.handleConnection(conn)
+		return
+	}
+	if first[0] == tlsRecordHandshake && s.termination != nil {
+		tlsConn := tls.Server(&fingerprintConn{Conn: conn}, s.termination.config)
+		ctx, cancel := context.WithTimeout(s.ctx, tlsHandshakeTimeout)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			clientIP, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+			s.logger.Warn("TLS handshake failed", logging.String("client_ip", clientIP), logging.Err(err))
+			auditHandshakeFailure(s.audit, clientIP, err)
+			if s.security != nil {
+				s.security.RecordEvent(clientIP, security.BanEventTLSHandshakeFailure)
+			}
+			tlsConn.Close()
+			return
+		}
+		state := tlsConn.ConnectionState()
+		tlsState = &state
+		conn = tlsConn
+	}
+
+	if isHTTPConn(conn, tlsState, s.config.SniffTimeout) {
+		if !s.httpListener.push(conn) {
+			conn.Close()
+		}
+		return
+	}
+
+	s.wg.Add(1)
+	s.handleConnection(conn)
+}
+
+// isHTTPConn reports whether a connection carries HTTP
+// TLS connections are HTTP if they negotiated an HTTP ALPN protocol, since peeking at
+// their decrypted bytes would hide the TLS state from the HTTP server.
+func isHTTPConn(conn net.Conn, tlsState *tls.ConnectionState, timeout time.Duration) bool {
+	if tlsState != nil {
+		return tlsState.NegotiatedProtocol == "h2" || tlsState.NegotiatedProtocol == "http/1.1"
+	}
+
+	peeked, ok := conn.(*peekedConn)
+	if !ok {
+		return false
+	}
+	prefix, _ := peekWithTimeout(peeked, timeout, maxHTTPPrefix)
+	for _, p := range httpPrefixes {
+		if bytes.HasPrefix(prefix, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffConn peeks at up to n bytes of a connection without consuming them
+// Fewer bytes are returned if the client sends nothing more within timeout.
+func sniffConn(conn net.Conn, timeout time.Duration, n int) ([]byte, net.Conn) {
+	peeked := &peekedConn{Conn: conn, reader: bufio.NewReader(conn)}
+	prefix, _ := peekWithTimeout(peeked, timeout, n)
+	return prefix, peeked
+}
+
+// peekWithTimeout peeks at up to n buffered or newly received bytes
+func peekWithTimeout(conn *peekedConn, timeout time.Duration, n int) ([]byte, error) {
+	if timeout > 0 {
+		conn.Conn.SetReadDeadline(time.Now().Add(timeout))
+		defer conn.Conn.SetReadDeadline(time.Time{})
+	}
+
+	prefix, err := conn.reader.Peek(n)
+	if err != nil {
+		// Return what arrived before the timeout
+		prefix, _ = conn.reader.Peek(conn.reader.Buffered())
+	}
+	return prefix, err
+}
+
+// peekedConn is a connection whose first bytes were read ahead for protocol detection
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read reads the peeked bytes first, then from the connection
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// NetConn returns the wrapped connection
+func (c *peekedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// CloseWrite half-closes the underlying connection if supported
+func (c *peekedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// connListener is a net.Listener for connections accepted and detected elsewhere
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+// newConnListener creates a listener reporting addr as its address
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{
+		addr:  addr,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// push hands a connection to Accept; it returns false once the listener is closed
+func (l *connListener) push(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// Accept waits for and returns the next pushed connection
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops Accept and push
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+// Addr returns the address of the real listener
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestAutoServer(t *testing.T) {
+	// The backend answers HTTP requests forwarded by the reverse proxy and echoes
+	// raw TCP lines, so each response shows which path the proxy took
+	backendLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer backendLn.Close()
+	go func() {
+		for {
+			conn, err := backendLn.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				if peek, _ := reader.Peek(4); string(peek) == "GET " {
+					req, err := http.ReadRequest(reader)
+					if err != nil {
+						return
+					}
+					body := "http"
+					if req.Header.Get("X-Forwarded-For") != "" {
+						body = "proxied-http"
+					}
+					resp := &http.Response{
+						StatusCode:    http.StatusOK,
+						ProtoMajor:    1,
+						ProtoMinor:    1,
+						ContentLength: int64(len(body)),
+						Body:          io.NopCloser(strings.NewReader(body)),
+						Close:         true,
+					}
+					resp.Write(conn)
+					return
+				}
+				line, _ := reader.ReadString('\n')
+				io.WriteString(conn, "tcp:"+line)
+			}()
+		}
+	}()
+
+	certFile, keyFile := writeTestCertificate(t, "example.com")
+	cfg := &config.Config{
+		Mode:         "auto",
+		Listen:       "127.0.0.1:0",
+		SniffTimeout: 200 * time.Millisecond,
+		Backends: []config.Backend{
+			{Name: "backend", Address: backendLn.Addr().String(), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		TLS:          &config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile},
+	}
+
+	server, err := NewAutoServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create auto server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Shutdown()
+	addr := server.listener.Addr().String()
+
+	// rawExchange writes a line on conn and returns the reply
+	rawExchange := func(conn net.Conn) string {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		io.WriteString(conn, "hello\n")
+		reply, _ := bufio.NewReader(conn).ReadString('\n')
+		return reply
+	}
+
+	t.Run("plaintext HTTP", func(t *testing.T) {
+		resp, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if body, _ := io.ReadAll(resp.Body); string(body) != "proxied-http" {
+			t.Errorf("Expected the request to go through the HTTP proxy, got %q", body)
+		}
+	})
+
+	t.Run("raw TCP", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+		if reply := rawExchange(conn); reply != "tcp:hello\n" {
+			t.Errorf("Expected the raw stream to be proxied, got %q", reply)
+		}
+	})
+
+	t.Run("HTTPS", func(t *testing.T) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true, ServerName: "example.com", NextProtos: []string{"http/1.1"}},
+		}}
+		resp, err := client.Get("https://" + addr + "/")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.TLS == nil {
+			t.Error("Expected a TLS response")
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != "proxied-http" {
+			t.Errorf("Expected the request to go through the HTTP proxy, got %q", body)
+		}
+	})
+
+	t.Run("TLS without ALPN", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"})
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+		if reply := rawExchange(conn); reply != "tcp:hello\n" {
+			t.Errorf("Expected the TLS stream to be proxied as raw TCP, got %q", reply)
+		}
+	})
+}
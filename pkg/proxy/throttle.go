@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+// byteRateLimiter is a token bucket measured in bytes that delays callers instead of rejecting them
+type byteRateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newByteRateLimiter creates a limiter allowing bytesPerSecond with a one second burst
+func newByteRateLimiter(bytesPerSecond int64) *byteRateLimiter {
+	return &byteRateLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until n bytes may be sent or ctx is cancelled
+func (l *byteRateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// Reserve the bytes up front; a negative balance is the time still owed
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// clientLimiter is a per-IP limiter shared by all connections from that IP
+type clientLimiter struct {
+	limiter *byteRateLimiter
+	refs    int
+}
+
+// bandwidthManager applies global, per-backend and per-client-IP bandwidth limits
+// Per-backend limiters are created on first use, so backends added later by service
+// discovery or the admin API are limited too.
+type bandwidthManager struct {
+	global *byteRateLimiter
+
+	// Directions the limits apply to
+	upload   bool
+	download bool
+
+	// perBackendRate applies to backends without a max_bandwidth in backendRates
+	perBackendRate int64
+	backendRates   map[string]int64
+
+	perClientRate int64
+	mu            sync.Mutex
+	perBackend    map[string]*byteRateLimiter
+	perClient     map[string]*clientLimiter
+}
+
+// newBandwidthManager creates a bandwidth manager (nil if no limits are configured)
+func newBandwidthManager(cfg *config.Config) *bandwidthManager {
+	m := &bandwidthManager{
+		backendRates: make(map[string]int64),
+		perBackend:   make(map[string]*byteRateLimiter),
+		perClient:    make(map[string]*clientLimiter),
+		upload:       true,
+		download:     true,
+	}
+
+	bw := cfg.Bandwidth
+	if bw != nil {
+		m.upload = bw.Direction != "download"
+		m.download = bw.Direction != "upload"
+		if bw.Global > 0 {
+			m.global = newByteRateLimiter(bw.Global)
+		}
+		m.perClientRate = bw.PerClientIP
+		m.perBackendRate = bw.PerBackend
+	}
+
+	for _, b := range cfg.Backends {
+		if b.MaxBandwidth > 0 {
+			m.backendRates[b.Name] = b.MaxBandwidth
+		}
+	}
+
+	if m.global == nil && m.perClientRate <= 0 && m.perBackendRate <= 0 && len(m.backendRates) == 0 {
+		return nil
+	}
+
+	return m
+}
+
+// acquire returns the limiters that apply to a connection's upload (client to backend)
+// and download (backend to client) traffic
+// Callers must call release with the same client IP when the connection ends.
+func (m *bandwidthManager) acquire(backendName, clientIP string) (upload, download []*byteRateLimiter) {
+	var limiters []*byteRateLimiter
+
+	if m.global != nil {
+		limiters = append(limiters, m.global)
+	}
+
+	m.mu.Lock()
+	if l := m.backendLimiter(backendName); l != nil {
+		limiters = append(limiters, l)
+	}
+	if m.perClientRate > 0 {
+		cl, ok := m.perClient[clientIP]
+		if !ok {
+			cl = &clientLimiter{limiter: newByteRateLimiter(m.perClientRate)}
+			m.perClient[clientIP] = cl
+		}
+		cl.refs++
+		limiters = append(limiters, cl.limiter)
+	}
+	m.mu.Unlock()
+
+	if m.upload {
+		upload = limiters
+	}
+	if m.download {
+		download = limiters
+	}
+	return upload, download
+}
+
+// backendLimiter returns the limiter shared by all connections to a backend, creating
+// it on first use (nil if the backend has no limit)
+// Callers must hold the lock.
+func (m *bandwidthManager) backendLimiter(name string) *byteRateLimiter {
+	if l, ok := m.perBackend[name]; ok {
+		return l
+	}
+
+	rate, ok := m.backendRates[name]
+	if !ok {
+		rate = m.perBackendRate
+	}
+	if rate <= 0 {
+		return nil
+	}
+	l := newByteRateLimiter(rate)
+	m.perBackend[name] = l
+	return l
+}
+
+// release drops the per-client limiter once the client has no open connections
+func (m *bandwidthManager) release(clientIP string) {
+	if m.perClientRate <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cl, ok := m.perClient[clientIP]; ok {
+		cl.refs--
+		if cl.refs <= 0 {
+			delete(m.perClient, clientIP)
+		}
+	}
+}
+
+// throttledWriter delays writes so they stay within every limiter's rate
+type throttledWriter struct {
+	ctx      context.Context
+	w        io.Writer
+	limiters []*byteRateLimiter
+}
+
+// newThrottledWriter wraps w with the given limiters (returns w unchanged if there are none)
+// A throttled writer has no ReadFrom, so streams copied into it never use splice(2);
+// unlimited directions must be passed no limiters to keep the zero-copy path.
+func newThrottledWriter(ctx context.Context, w io.Writer, limiters []*byteRateLimiter) io.Writer {
+	if len(limiters) == 0 {
+		return w
+	}
+	return &throttledWriter{ctx: ctx, w: w, limiters: limiters}
+}
+
+// Write waits on each limiter before writing p
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	for _, l := range t.limiters {
+		if err := l.wait(t.ctx, len(p)); err != nil {
+			return 0, err
+		}
+	}
+	return t.w.Write(p)
+}
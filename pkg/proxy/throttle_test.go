@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestThrottledWriter(t *testing.T) {
+	// 10KB/s with a 10KB burst: writing 20KB should take about one second
+	limiter := newByteRateLimiter(10 * 1024)
+
+	var buf bytes.Buffer
+	w := newThrottledWriter(context.Background(), &buf, []*byteRateLimiter{limiter})
+
+	start := time.Now()
+	chunk := make([]byte, 5*1024)
+	for i := 0; i < 4; i++ {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	if buf.Len() != 20*1024 {
+		t.Errorf("Expected 20480 bytes written, got %d", buf.Len())
+	}
+	if elapsed < 800*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Expected throttled write to take ~1s, took %v", elapsed)
+	}
+}
+
+func TestThrottledWriterCancel(t *testing.T) {
+	limiter := newByteRateLimiter(1024)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newThrottledWriter(ctx, &bytes.Buffer{}, []*byteRateLimiter{limiter})
+
+	// Drain the burst, then cancel while waiting
+	w.Write(make([]byte, 1024))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	if _, err := w.Write(make([]byte, 10*1024)); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBandwidthManager(t *testing.T) {
+	if m := newBandwidthManager(&config.Config{}); m != nil {
+		t.Error("Expected nil manager when no limits are configured")
+	}
+
+	cfg := &config.Config{
+		Backends: []config.Backend{
+			{Name: "b1", Address: "127.0.0.1:9001"},
+			{Name: "b2", Address: "127.0.0.1:9002", MaxBandwidth: 2048},
+		},
+		Bandwidth: &config.BandwidthConfig{
+			Global:      1 << 20,
+			PerBackend:  4096,
+			PerClientIP: 1024,
+		},
+	}
+	m := newBandwidthManager(cfg)
+	if m == nil {
+		t.Fatal("Expected bandwidth manager")
+	}
+
+	limiters, download := m.acquire("b1", "10.0.0.1")
+	if len(limiters) != 3 || len(download) != 3 {
+		t.Errorf("Expected global, backend and client limiters both ways, got %d and %d", len(limiters), len(download))
+	}
+
+	// Connections from the same client share a limiter
+	other, _ := m.acquire("b2", "10.0.0.1")
+	if other[2] != limiters[2] {
+		t.Error("Expected connections from the same IP to share a limiter")
+	}
+	if rate := other[1].rate; rate != 2048 {
+		t.Errorf("Expected backend override rate 2048, got %v", rate)
+	}
+
+	// Backends that are not in the configuration, such as discovered ones, get the
+	// per-backend limit, shared by their connections
+	discovered, _ := m.acquire("discovered", "10.0.0.2")
+	again, _ := m.acquire("discovered", "10.0.0.3")
+	if len(discovered) != 3 || discovered[1].rate != 4096 || again[1] != discovered[1] {
+		t.Errorf("Expected a shared per-backend limiter for a discovered backend, got %d limiters", len(discovered))
+	}
+
+	m.release("10.0.0.1")
+	m.release("10.0.0.1")
+	m.release("10.0.0.2")
+	m.release("10.0.0.3")
+	if len(m.perClient) != 0 {
+		t.Errorf("Expected client limiter to be released, got %d", len(m.perClient))
+	}
+}
+
+func TestBandwidthManagerDirection(t *testing.T) {
+	tests := []struct {
+		direction string
+		upload    bool
+		download  bool
+	}{
+		{"", true, true},
+		{"both", true, true},
+		{"upload", true, false},
+		{"download", false, true},
+	}
+
+	for _, tt := range tests {
+		m := newBandwidthManager(&config.Config{
+			Bandwidth: &config.BandwidthConfig{Global: 1024, Direction: tt.direction},
+		})
+		upload, download := m.acquire("b1", "10.0.0.1")
+		if (len(upload) > 0) != tt.upload || (len(download) > 0) != tt.download {
+			t.Errorf("direction %q: expected upload %v and download %v, got %d and %d limiters",
+				tt.direction, tt.upload, tt.download, len(upload), len(download))
+		}
+	}
+}
+
+func TestThrottledWriterKeepsReadFrom(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := &deadlineConn{Conn: server}
+
+	// A direction without limits writes to the connection itself, so io.Copy can splice
+	if _, ok := newThrottledWriter(context.Background(), conn, nil).(io.ReaderFrom); !ok {
+		t.Error("Expected an unthrottled writer to keep ReadFrom")
+	}
+	limited := []*byteRateLimiter{newByteRateLimiter(1024)}
+	if _, ok := newThrottledWriter(context.Background(), conn, limited).(io.ReaderFrom); ok {
+		t.Error("Expected a throttled writer to copy through its own buffer")
+	}
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
+)
+
+// upstreamTimer breaks requests to backends down into DNS, connect, TLS handshake,
+// time-to-first-byte and total time with httptrace
+type upstreamTimer struct {
+	// log adds the phases to access log entries
+	log bool
+
+	// metrics records the phases as histograms
+	metrics bool
+}
+
+// newUpstreamTimer creates an upstream timer from the logging and metrics
+// upstream_timing settings (nil if both are disabled)
+func newUpstreamTimer(cfg *config.Config) *upstreamTimer {
+	t := &upstreamTimer{
+		log:     cfg.Logging != nil && cfg.Logging.AccessLog && cfg.Logging.UpstreamTiming,
+		metrics: cfg.Metrics.UpstreamTiming,
+	}
+	if !t.log && !t.metrics {
+		return nil
+	}
+	return t
+}
+
+// traceUpstream returns the request with a client trace timing its phases
+func traceUpstream(r *http.Request) (*http.Request, *upstreamTrace) {
+	ut := &upstreamTrace{start: time.Now()}
+	return r.WithContext(httptrace.WithClientTrace(r.Context(), ut.clientTrace())), ut
+}
+
+// record reports the phases of a finished request to a backend, returning them if
+// they go to the access log
+func (t *upstreamTimer) record(backendName string, timing logging.UpstreamTiming) *logging.UpstreamTiming {
+	if t.metrics {
+		recordUpstreamPhases(backendName, timing)
+	}
+	if !t.log {
+		return nil
+	}
+	return &timing
+}
+
+// recordUpstreamPhases records the phase timings of a request as histograms
+// Phases that did not happen, such as connecting on a reused connection, are skipped.
+func recordUpstreamPhases(backendName string, timing logging.UpstreamTiming) {
+	if timing.DNS > 0 {
+		metrics.RecordUpstreamPhase(backendName, "dns", timing.DNS)
+	}
+	if timing.Connect > 0 {
+		metrics.RecordUpstreamPhase(backendName, "connect", timing.Connect)
+	}
+	if timing.TLSHandshake > 0 {
+		metrics.RecordUpstreamPhase(backendName, "tls", timing.TLSHandshake)
+	}
+	if timing.FirstByte > 0 {
+		metrics.RecordUpstreamPhase(backendName, "ttfb", timing.FirstByte)
+	}
+	metrics.RecordUpstreamPhase(backendName, "total", timing.Total)
+}
+
+// upstreamTrace collects the phase timings of one request to a backend
+// Its callbacks may run on the transport's dialing goroutines.
+type upstreamTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       logging.UpstreamTiming
+}
+
+// clientTrace returns the httptrace hooks recording the phases
+func (ut *upstreamTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			ut.mu.Lock()
+			defer ut.mu.Unlock()
+			ut.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			ut.mu.Lock()
+			defer ut.mu.Unlock()
+			ut.timing.DNS = time.Since(ut.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			ut.mu.Lock()
+			defer ut.mu.Unlock()
+			ut.connectStart = time.Now()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			ut.mu.Lock()
+			defer ut.mu.Unlock()
+			if err == nil {
+				ut.timing.Connect = time.Since(ut.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			ut.mu.Lock()
+			defer ut.mu.Unlock()
+			ut.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			ut.mu.Lock()
+			defer ut.mu.Unlock()
+			if err == nil {
+				ut.timing.TLSHandshake = time.Since(ut.tlsStart)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			ut.mu.Lock()
+			defer ut.mu.Unlock()
+			ut.timing.Reused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			ut.mu.Lock()
+			defer ut.mu.Unlock()
+			ut.timing.FirstByte = time.Since(ut.start)
+		},
+	}
+}
+
+// finish ends the request and returns its phase timings
+func (ut *upstreamTrace) finish() logging.UpstreamTiming {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	ut.timing.Total = time.Since(ut.start)
+	return ut.timing
+}
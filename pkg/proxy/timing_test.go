@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+func TestNewUpstreamTimer(t *testing.T) {
+	if timer := newUpstreamTimer(&config.Config{}); timer != nil {
+		t.Errorf("Expected no upstream timer without upstream_timing, got %+v", timer)
+	}
+
+	// Logging upstream timing needs an access log to write to
+	timer := newUpstreamTimer(&config.Config{
+		Logging: &config.LoggingConfig{UpstreamTiming: true},
+		Metrics: config.MetricsConfig{UpstreamTiming: true},
+	})
+	if timer == nil || timer.log || !timer.metrics {
+		t.Errorf("Expected upstream timer recording metrics only, got %+v", timer)
+	}
+}
+
+func TestUpstreamTimingAccessLog(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+	defer backendServer.Close()
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	server, err := NewHTTPServer(&config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "b1", Address: strings.TrimPrefix(backendServer.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{MaxIdleConnsPerHost: 1},
+		Logging: &config.LoggingConfig{
+			AccessLog:       true,
+			AccessLogOutput: path,
+			UpstreamTiming:  true,
+		},
+		Metrics:  config.MetricsConfig{UpstreamTiming: true},
+		Timeouts: config.TimeoutConfig{Connect: time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+	defer h.accessLog.Close()
+
+	// The first request connects to the backend, the second reuses the connection
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+	}
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read access log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 access log entries, got %d:\n%s", len(lines), out)
+	}
+
+	first, second := lines[0], lines[1]
+	for _, field := range []string{"backend=b1", "upstream_connect=", "upstream_ttfb=", "upstream_total=", "upstream_reused=false"} {
+		if !strings.Contains(first, field) {
+			t.Errorf("Expected %s in first entry: %s", field, first)
+		}
+	}
+	if strings.Contains(second, "upstream_connect=") || !strings.Contains(second, "upstream_reused=true") {
+		t.Errorf("Expected second entry on a reused connection: %s", second)
+	}
+}
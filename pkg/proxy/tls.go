@@ -0,0 +1,675 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/backend"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/lb"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/security"
+	balancetls "github.com/therealutkarshpriyadarshi/balance/pkg/tls"
+)
+
+const (
+	// tlsHandshakeTimeout bounds the TLS handshake of proxied TCP connections
+	tlsHandshakeTimeout = 10 * time.Second
+
+	// certExpiryCheckInterval is how often certificate expiry is reported
+	certExpiryCheckInterval = time.Hour
+
+	// certSourceFetchTimeout bounds fetching certificates from Vault or Kubernetes at startup
+	certSourceFetchTimeout = 30 * time.Second
+)
+
+// clientAuthTypes maps tls.client_auth values to crypto/tls client auth policies
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify":             tls.VerifyClientCertIfGiven,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+// tlsTermination is the TLS state of a proxy listener
+type tlsTermination struct {
+	config *tls.Config
+	certs  *balancetls.CertificateManager
+
+	// Client certificate revocation (nil without tls.crl_file)
+	crl        *balancetls.RevocationList
+	crlRefresh time.Duration
+
+	// Certificates fetched from Vault or Kubernetes, refreshed while running
+	sourced []loadedCertificate
+
+	// Certificates loaded from files, re-read by reloadCertificates
+	filesMu sync.Mutex
+	files   []loadedCertificate
+
+	// Configs of certificates with their own client auth policy
+	perCertMu sync.RWMutex
+	perCert   map[*balancetls.Certificate]*tls.Config
+
+	// JA3 hashes whose handshakes are rejected (nil without security.blocked_ja3)
+	blockedJA3 map[string]bool
+
+	// Audit log of blocked handshakes (nil when disabled)
+	audit *logging.AuditLogger
+
+	logger *logging.Logger
+}
+
+// loadedCertificate is a certificate with the configuration it was loaded from
+type loadedCertificate struct {
+	cert   *balancetls.Certificate
+	config config.CertificateConfig
+	source balancetls.CertificateSource // nil for certificate files
+}
+
+// newTLSTermination loads the certificates and builds the TLS config for the proxy listener
+// It returns nil if TLS is not enabled
+func newTLSTermination(cfg *config.Config, logger *logging.Logger) (*tlsTermination, error) {
+	if cfg.TLS == nil || !cfg.TLS.Enabled {
+		return nil, nil
+	}
+
+	logger = logger.With(logging.String("component", "tls"))
+	certMgr, loaded, err := newCertificateManager(cfg.TLS, logger)
+	if err != nil {
+		return nil, err
+	}
+	t := &tlsTermination{
+		certs:      certMgr,
+		crlRefresh: cfg.TLS.CRLRefreshInterval,
+		perCert:    make(map[*balancetls.Certificate]*tls.Config),
+		logger:     logger,
+	}
+
+	tlsCfg := balancetls.DefaultConfig()
+	if cfg.TLS.MinVersion != "" {
+		if tlsCfg.MinVersion, err = balancetls.ParseTLSVersion(cfg.TLS.MinVersion); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.TLS.MaxVersion != "" {
+		if tlsCfg.MaxVersion, err = balancetls.ParseTLSVersion(cfg.TLS.MaxVersion); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.TLS.CipherSuites) > 0 {
+		if tlsCfg.CipherSuites, err = parseCipherSuites(cfg.TLS.CipherSuites); err != nil {
+			return nil, err
+		}
+	}
+	tlsCfg.PreferServerCipherSuites = cfg.TLS.PreferServerCipherSuites
+	tlsCfg.SessionTicketsDisabled = cfg.TLS.SessionTicketsDisabled
+	if cfg.TLS.ClientAuth != "" {
+		tlsCfg.ClientAuth = clientAuthTypes[cfg.TLS.ClientAuth]
+	}
+	tlsCfg.NextProtos = nextProtos(cfg)
+	if err := tlsCfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid TLS config: %w", err)
+	}
+
+	stdCfg := tlsCfg.ToStdConfig()
+	stdCfg.GetCertificate = certMgr.GetCertificate
+
+	if cfg.TLS.ClientCAFile != "" {
+		if stdCfg.ClientCAs, err = loadCertPool(cfg.TLS.ClientCAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.TLS.CRLFile != "" {
+		if t.crl, err = balancetls.NewRevocationList(cfg.TLS.CRLFile, logger); err != nil {
+			return nil, err
+		}
+		stdCfg.VerifyConnection = t.crl.VerifyConnection
+	}
+
+	// Certificates with their own client auth policy are served with their own config
+	for _, lc := range loaded {
+		if lc.source != nil {
+			t.sourced = append(t.sourced, lc)
+		} else {
+			t.files = append(t.files, lc)
+		}
+
+		certCfg := lc.config
+		if certCfg.ClientAuth == "" && certCfg.ClientCAFile == "" {
+			continue
+		}
+		certTLS := stdCfg.Clone()
+		if certCfg.ClientAuth != "" {
+			certTLS.ClientAuth = clientAuthTypes[certCfg.ClientAuth]
+		}
+		if certCfg.ClientCAFile != "" {
+			if certTLS.ClientCAs, err = loadCertPool(certCfg.ClientCAFile); err != nil {
+				return nil, fmt.Errorf("TLS certificate %s: %w", certificateName(certCfg), err)
+			}
+		}
+		t.perCert[lc.cert] = certTLS
+	}
+	if cfg.Security != nil && len(cfg.Security.BlockedJA3) > 0 {
+		t.blockedJA3 = make(map[string]bool)
+		for _, hash := range cfg.Security.BlockedJA3 {
+			t.blockedJA3[hash] = true
+		}
+	}
+	stdCfg.GetConfigForClient = t.configForClient
+
+	t.config = stdCfg
+	return t, nil
+}
+
+// listener wraps a listener to terminate TLS on accepted connections
+func (t *tlsTermination) listener(ln net.Listener) net.Listener {
+	return tls.NewListener(fingerprintListener{ln}, t.config)
+}
+
+// handshakeErrorPrefix starts the message net/http logs for a failed TLS handshake
+const handshakeErrorPrefix = "http: TLS handshake error from "
+
+// handshakeErrorLog is the error log of an HTTP server terminating TLS
+// Messages go to next; failed handshakes, which net/http only reports there, also
+// count towards banning the client.
+type handshakeErrorLog struct {
+	security *security.SecurityManager
+	next     io.Writer
+}
+
+// Write logs a message of the HTTP server
+func (l handshakeErrorLog) Write(p []byte) (int, error) {
+	msg := string(p)
+	if rest, ok := strings.CutPrefix(msg, handshakeErrorPrefix); ok {
+		addr, _, _ := strings.Cut(rest, ": ")
+		if clientIP, _, err := net.SplitHostPort(addr); err == nil {
+			l.security.RecordEvent(clientIP, security.BanEventTLSHandshakeFailure)
+		}
+	}
+	return l.next.Write(p)
+}
+
+// configForClient records the JA3 fingerprint of a ClientHello, rejects blocklisted
+// fingerprints and selects the config of certificates with their own client auth policy
+func (t *tlsTermination) configForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	_, ja3 := balancetls.JA3(hello)
+	if fc, ok := hello.Conn.(*fingerprintConn); ok {
+		fc.ja3.Store(&ja3)
+	}
+	if t.blockedJA3[ja3] {
+		metrics.IncTLSBlockedHandshakes(ja3)
+		if t.audit != nil {
+			clientIP, _, _ := net.SplitHostPort(hello.Conn.RemoteAddr().String())
+			auditConnection(t.audit, logging.AuditBlocked, clientIP, "JA3 fingerprint is blocked", map[string]string{"ja3": ja3})
+		}
+		return nil, fmt.Errorf("JA3 fingerprint %s is blocked", ja3)
+	}
+
+	t.perCertMu.RLock()
+	defer t.perCertMu.RUnlock()
+	if len(t.perCert) == 0 {
+		return nil, nil
+	}
+	// nil keeps the listener's config
+	return t.perCert[t.certs.Lookup(hello.ServerName)], nil
+}
+
+// start runs certificate expiry reporting and CRL refreshes until ctx is done
+func (t *tlsTermination) start(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t.certs.MonitorExpiry(ctx, certExpiryCheckInterval)
+	}()
+
+	if t.crl != nil && t.crlRefresh > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.crl.Refresh(ctx, t.crlRefresh)
+		}()
+	}
+
+	for _, lc := range t.sourced {
+		if lc.config.RefreshInterval <= 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.certs.RefreshCertificate(ctx, lc.source, lc.cert, lc.config.RefreshInterval, t.certificateReplaced)
+		}()
+	}
+}
+
+// certificateReplaced moves the client auth policy of a refreshed certificate to its replacement
+func (t *tlsTermination) certificateReplaced(old, cert *balancetls.Certificate) {
+	t.perCertMu.Lock()
+	defer t.perCertMu.Unlock()
+
+	if certTLS, ok := t.perCert[old]; ok {
+		delete(t.perCert, old)
+		t.perCert[cert] = certTLS
+	}
+}
+
+// reloadCertificates reads the certificate files and the CRL file again, e.g. after they
+// were rotated out of band
+// A certificate that fails to load is reported and the current one is kept serving.
+// Certificates from Vault or Kubernetes refresh on their own and are not reloaded.
+func (t *tlsTermination) reloadCertificates() error {
+	t.filesMu.Lock()
+	defer t.filesMu.Unlock()
+
+	var errs []error
+	for i, lc := range t.files {
+		cert, err := t.certs.LoadCertificate(lc.config.CertFile, lc.config.KeyFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("TLS certificate %s: %w", certificateName(lc.config), err))
+			continue
+		}
+		if cert.Cert.Equal(lc.cert.Cert) {
+			continue
+		}
+		if len(lc.config.Domains) > 0 {
+			cert.Domains = lc.config.Domains
+		}
+		if err := t.certs.ReplaceCertificate(lc.cert, cert); err != nil {
+			errs = append(errs, fmt.Errorf("TLS certificate %s: %w", certificateName(lc.config), err))
+			continue
+		}
+		t.certificateReplaced(lc.cert, cert)
+		t.files[i].cert = cert
+		t.logger.Info("Reloaded TLS certificate",
+			logging.String("certificate", cert.Name()), logging.String("file", lc.config.CertFile),
+			logging.String("not_after", cert.NotAfter.Format(time.RFC3339)))
+	}
+
+	if t.crl != nil {
+		if err := t.crl.Reload(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	t.certs.ReportExpiry()
+	return errors.Join(errs...)
+}
+
+// loadCertPool reads a PEM file of CA certificates
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", file)
+	}
+	return pool, nil
+}
+
+// newSPIFFESource creates the SPIFFE Workload API source if tls.spiffe is enabled
+// When the listener terminates TLS, the SVID is served as its default certificate
+func newSPIFFESource(cfg *config.Config, termination *tlsTermination, logger *logging.Logger) *balancetls.SPIFFESource {
+	if cfg.TLS == nil || cfg.TLS.SPIFFE == nil || !cfg.TLS.SPIFFE.Enabled {
+		return nil
+	}
+
+	socketPath := cfg.TLS.SPIFFE.SocketPath
+	if socketPath == "" {
+		socketPath = os.Getenv("SPIFFE_ENDPOINT_SOCKET")
+	}
+
+	var certMgr *balancetls.CertificateManager
+	if termination != nil {
+		certMgr = termination.certs
+	}
+	return balancetls.NewSPIFFESource(socketPath, certMgr, logger.With(logging.String("component", "tls")))
+}
+
+// newBackendTLSConfig builds the TLS config for connections to backends
+// It returns nil if tls.backend is not enabled
+func newBackendTLSConfig(cfg *config.Config, spiffe *balancetls.SPIFFESource) (*tls.Config, error) {
+	if cfg.TLS == nil || cfg.TLS.Backend == nil || !cfg.TLS.Backend.Enabled {
+		return nil, nil
+	}
+	backendCfg := cfg.TLS.Backend
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: backendCfg.InsecureSkipVerify,
+	}
+
+	if backendCfg.CAFile != "" {
+		pool, err := loadCertPool(backendCfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLS backend: %w", err)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if backendCfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(backendCfg.ClientCertFile, backendCfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLS backend: failed to load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if spiffe != nil {
+		if len(tlsCfg.Certificates) == 0 {
+			tlsCfg.GetClientCertificate = spiffe.GetClientCertificate
+		}
+		if backendCfg.CAFile == "" && !backendCfg.InsecureSkipVerify {
+			// SVIDs have no DNS names, so backends are verified against the SPIFFE bundle
+			// and their SPIFFE ID instead of their hostname
+			tlsCfg.InsecureSkipVerify = true
+			tlsCfg.VerifyPeerCertificate = spiffe.VerifyPeer(cfg.TLS.SPIFFE.BackendIDs)
+		}
+	}
+
+	return tlsCfg, nil
+}
+
+// backendTLSClient performs the TLS handshake on a connection to a backend
+func backendTLSClient(ctx context.Context, conn net.Conn, address string, cfg *tls.Config) (net.Conn, error) {
+	tlsCfg := cfg
+	if tlsCfg.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			tlsCfg = cfg.Clone()
+			tlsCfg.ServerName = host
+		}
+	}
+
+	tlsConn := tls.Client(conn, tlsCfg)
+	ctx, cancel := context.WithTimeout(ctx, tlsHandshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// newCertificateManager loads the configured certificates from files, Vault or Kubernetes
+// It also returns the configuration each certificate was loaded from
+func newCertificateManager(cfg *config.TLSConfig, logger *logging.Logger) (*balancetls.CertificateManager, []loadedCertificate, error) {
+	certMgr := balancetls.NewCertificateManager(logger)
+	var loaded []loadedCertificate
+
+	certs := cfg.Certificates
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		certs = append([]config.CertificateConfig{{CertFile: cfg.CertFile, KeyFile: cfg.KeyFile}}, certs...)
+	}
+
+	for _, certCfg := range certs {
+		name := certificateName(certCfg)
+		source, err := newCertificateSource(certCfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("TLS certificate %s: %w", name, err)
+		}
+
+		var cert *balancetls.Certificate
+		if source != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), certSourceFetchTimeout)
+			cert, err = source.Fetch(ctx)
+			cancel()
+		} else {
+			cert, err = certMgr.LoadCertificate(certCfg.CertFile, certCfg.KeyFile)
+			if err == nil && len(certCfg.Domains) > 0 {
+				cert.Domains = certCfg.Domains
+			}
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("TLS certificate %s: %w", name, err)
+		}
+
+		if err := certMgr.AddCertificate(cert); err != nil {
+			return nil, nil, fmt.Errorf("TLS certificate %s: %w", name, err)
+		}
+		loaded = append(loaded, loadedCertificate{cert: cert, config: certCfg, source: source})
+		if certCfg.Default {
+			if err := certMgr.SetDefaultCertificate(cert); err != nil {
+				return nil, nil, fmt.Errorf("TLS certificate %s: %w", name, err)
+			}
+		}
+	}
+
+	if len(certs) == 0 && cfg.AutoSelfSigned {
+		domains := []string{"localhost"}
+		if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+			domains = append(domains, hostname)
+		}
+		cert, err := balancetls.GenerateSelfSignedCertificate(domains)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate self-signed certificate: %w", err)
+		}
+		if err := certMgr.AddCertificate(cert); err != nil {
+			return nil, nil, err
+		}
+		logger.Warn("No TLS certificates configured, serving a self-signed certificate", logging.Any("domains", domains))
+	}
+
+	return certMgr, loaded, nil
+}
+
+// newCertificateSource creates the Vault or Kubernetes source of a certificate
+// It returns nil for certificates loaded from files
+func newCertificateSource(certCfg config.CertificateConfig) (balancetls.CertificateSource, error) {
+	var source balancetls.CertificateSource
+	switch {
+	case certCfg.Vault != nil:
+		vault := certCfg.Vault
+		address, token := vault.Address, vault.Token
+		if address == "" {
+			address = os.Getenv("VAULT_ADDR")
+		}
+		if token == "" {
+			token = os.Getenv("VAULT_TOKEN")
+		}
+		vaultSource, err := balancetls.NewVaultPKISource(balancetls.VaultPKIConfig{
+			Address:    address,
+			Token:      token,
+			TokenFile:  vault.TokenFile,
+			Mount:      vault.Mount,
+			Role:       vault.Role,
+			CommonName: vault.CommonName,
+			AltNames:   vault.AltNames,
+			TTL:        vault.TTL,
+			CAFile:     vault.CAFile,
+		})
+		if err != nil {
+			return nil, err
+		}
+		source = vaultSource
+	case certCfg.KubernetesSecret != nil:
+		secretSource, err := balancetls.NewKubernetesSecretSource(certCfg.KubernetesSecret.Namespace, certCfg.KubernetesSecret.Name)
+		if err != nil {
+			return nil, err
+		}
+		source = secretSource
+	default:
+		return nil, nil
+	}
+
+	if len(certCfg.Domains) > 0 {
+		source = domainsSource{CertificateSource: source, domains: certCfg.Domains}
+	}
+	return source, nil
+}
+
+// domainsSource serves the certificates of a source for configured domains
+type domainsSource struct {
+	balancetls.CertificateSource
+	domains []string
+}
+
+// Fetch returns the source's certificate with the configured domains
+func (d domainsSource) Fetch(ctx context.Context) (*balancetls.Certificate, error) {
+	cert, err := d.CertificateSource.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Copy, since a source may return the same certificate again
+	withDomains := *cert
+	withDomains.Domains = d.domains
+	return &withDomains, nil
+}
+
+// certificateName identifies a certificate configuration in errors
+func certificateName(certCfg config.CertificateConfig) string {
+	switch {
+	case certCfg.Vault != nil:
+		return fmt.Sprintf("vault %s/%s", certCfg.Vault.Mount, certCfg.Vault.Role)
+	case certCfg.KubernetesSecret != nil:
+		return "kubernetes secret " + certCfg.KubernetesSecret.Name
+	default:
+		return certCfg.CertFile
+	}
+}
+
+// parseCipherSuites converts cipher suite names (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256) to IDs
+func parseCipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS cipher suite: %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// nextProtos returns the ALPN protocols offered to clients: tls.alpn_protocols if set,
+// otherwise the HTTP versions the proxy serves (none in TCP mode) followed by the
+// protocols of any tls.alpn routes
+func nextProtos(cfg *config.Config) []string {
+	if len(cfg.TLS.ALPNProtocols) > 0 {
+		return cfg.TLS.ALPNProtocols
+	}
+
+	var protos []string
+	if cfg.Mode != "tcp" && cfg.HTTP != nil {
+		if cfg.HTTP.EnableHTTP2 {
+			protos = append(protos, "h2")
+		}
+		protos = append(protos, "http/1.1")
+	}
+
+	if cfg.TLS.ALPN != nil {
+		routed := slices.Sorted(maps.Keys(cfg.TLS.ALPN.Routes))
+		for _, proto := range routed {
+			if !slices.Contains(protos, proto) {
+				protos = append(protos, proto)
+			}
+		}
+	}
+	return protos
+}
+
+// tlsRoutes selects backends by the SNI hostname or negotiated ALPN protocol of
+// terminated TLS connections
+type tlsRoutes struct {
+	sni          *balancetls.SNIRouter
+	sniBalancers map[string]lb.LoadBalancer // by route hostname
+
+	alpnBalancers map[string]lb.LoadBalancer // by protocol
+}
+
+// newTLSRoutes creates a load balancer for each tls.sni and tls.alpn route over its backends
+// It returns nil if no TLS routes are configured
+func newTLSRoutes(cfg *config.Config, pool *backend.Pool, logger *logging.Logger) (*tlsRoutes, error) {
+	if cfg.TLS == nil || !cfg.TLS.Enabled {
+		return nil, nil
+	}
+
+	var sniRoutes, alpnRoutes map[string][]string
+	if cfg.TLS.SNI != nil {
+		sniRoutes = cfg.TLS.SNI.Routes
+	}
+	if cfg.TLS.ALPN != nil {
+		alpnRoutes = cfg.TLS.ALPN.Routes
+	}
+	if len(sniRoutes) == 0 && len(alpnRoutes) == 0 {
+		return nil, nil
+	}
+
+	routes := &tlsRoutes{
+		sni:           balancetls.NewSNIRouter(nil, logger),
+		sniBalancers:  make(map[string]lb.LoadBalancer, len(sniRoutes)),
+		alpnBalancers: make(map[string]lb.LoadBalancer, len(alpnRoutes)),
+	}
+	for hostname, names := range sniRoutes {
+		if err := routes.sni.AddRoute(hostname, names); err != nil {
+			return nil, fmt.Errorf("sni route %s: %w", hostname, err)
+		}
+		balancer, err := newBackendsBalancer(cfg, pool, names)
+		if err != nil {
+			return nil, fmt.Errorf("sni route %s: %w", hostname, err)
+		}
+		routes.sniBalancers[hostname] = balancer
+	}
+	for protocol, names := range alpnRoutes {
+		balancer, err := newBackendsBalancer(cfg, pool, names)
+		if err != nil {
+			return nil, fmt.Errorf("alpn route %s: %w", protocol, err)
+		}
+		routes.alpnBalancers[protocol] = balancer
+		logger.Info("Added ALPN route", logging.String("protocol", protocol), logging.Any("backends", names))
+	}
+
+	return routes, nil
+}
+
+// newBackendsBalancer creates a load balancer over the named backends of the pool
+func newBackendsBalancer(cfg *config.Config, pool *backend.Pool, names []string) (lb.LoadBalancer, error) {
+	// Route pools share the backends, so health and connection counts stay in sync
+	routePool := backend.NewPool()
+	routePool.SetFailoverThreshold(cfg.LoadBalancer.FailoverThreshold)
+	for _, name := range names {
+		if b := pool.GetByName(name); b != nil {
+			if err := routePool.Add(b); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return newLoadBalancer(cfg, routePool)
+}
+
+// balancerFor returns the load balancer for a TLS connection (nil if no route matches)
+// An ALPN route takes precedence over an SNI route
+func (r *tlsRoutes) balancerFor(state *tls.ConnectionState) lb.LoadBalancer {
+	if r == nil {
+		return nil
+	}
+	if balancer, ok := r.alpnBalancers[state.NegotiatedProtocol]; ok {
+		return balancer
+	}
+	if state.ServerName == "" {
+		return nil
+	}
+	if hostname, ok := r.sni.Match(state.ServerName); ok {
+		return r.sniBalancers[hostname]
+	}
+	return nil
+}
@@ -0,0 +1,608 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/security"
+	balancetls "github.com/therealutkarshpriyadarshi/balance/pkg/tls"
+)
+
+// writeTestCertificate writes a self-signed certificate for the domains and returns its files
+func writeTestCertificate(t *testing.T, domains ...string) (certFile, keyFile string) {
+	t.Helper()
+
+	cert, err := balancetls.GenerateSelfSignedCertificate(domains)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := balancetls.SaveCertificateToPEM(cert, certFile, keyFile); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestHTTPSNIRoutes(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	web := newBackend("web")
+	defer web.Close()
+	api := newBackend("api")
+	defer api.Close()
+	admin := newBackend("admin")
+	defer admin.Close()
+
+	certFile, keyFile := writeTestCertificate(t, "example.com", "*.example.com")
+	cfg := &config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "web", Address: strings.TrimPrefix(web.URL, "http://"), Weight: 1},
+			{Name: "api", Address: strings.TrimPrefix(api.URL, "http://"), Weight: 1},
+			{Name: "admin", Address: strings.TrimPrefix(admin.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP: &config.HTTPConfig{
+			Routes: []config.Route{
+				{Name: "admin", PathPrefix: "/admin", Backends: []string{"admin"}},
+			},
+		},
+		TLS: &config.TLSConfig{
+			Enabled:  true,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			SNI: &config.SNIConfig{
+				Routes: map[string][]string{
+					"api.example.com": {"api"},
+					"*.example.com":   {"web"},
+				},
+			},
+		},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"https://api.example.com/users", "api"},
+		{"https://www.example.com/", "web"},
+		// Route backends take precedence over the SNI route
+		{"https://api.example.com/admin", "admin"},
+	}
+
+	for _, tt := range tests {
+		// Requests to https:// targets carry a TLS connection state with the host as SNI
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Body.String() != tt.want {
+			t.Errorf("%s: expected backend %s, got %q", tt.target, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestTCPSNIRoutes(t *testing.T) {
+	newBackend := func(name string) net.Listener {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to start backend: %v", err)
+		}
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.Write([]byte(name))
+				conn.Close()
+			}
+		}()
+		return ln
+	}
+	web := newBackend("web")
+	defer web.Close()
+	api := newBackend("api")
+	defer api.Close()
+
+	certFile, keyFile := writeTestCertificate(t, "example.com", "*.example.com")
+	cfg := &config.Config{
+		Mode:   "tcp",
+		Listen: "127.0.0.1:0",
+		Backends: []config.Backend{
+			{Name: "web", Address: web.Addr().String(), Weight: 1},
+			{Name: "api", Address: api.Addr().String(), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+		TLS: &config.TLSConfig{
+			Enabled:  true,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			SNI: &config.SNIConfig{
+				Routes: map[string][]string{"api.example.com": {"api"}},
+			},
+			ALPN: &config.ALPNConfig{
+				Routes: map[string][]string{"x-custom": {"web"}},
+			},
+		},
+	}
+
+	server, err := NewTCPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TCP server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Shutdown()
+
+	tests := []struct {
+		protos []string
+		want   string
+	}{
+		{nil, "api"},
+		{nil, "api"},
+		// ALPN routes take precedence over SNI routes
+		{[]string{"x-custom"}, "web"},
+	}
+
+	for _, tt := range tests {
+		conn, err := tls.Dial("tcp", server.listener.Addr().String(), &tls.Config{
+			ServerName:         "api.example.com",
+			NextProtos:         tt.protos,
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		body, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		if string(body) != tt.want {
+			t.Errorf("ALPN %v: expected connection routed to %s, got %q", tt.protos, tt.want, body)
+		}
+	}
+}
+
+func TestHTTPALPNRoutes(t *testing.T) {
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("web"))
+	}))
+	defer web.Close()
+	grpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("grpc"))
+	}))
+	defer grpc.Close()
+
+	certFile, keyFile := writeTestCertificate(t, "example.com")
+	cfg := &config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "web", Address: strings.TrimPrefix(web.URL, "http://"), Weight: 1},
+			{Name: "grpc", Address: strings.TrimPrefix(grpc.URL, "http://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{EnableHTTP2: true},
+		TLS: &config.TLSConfig{
+			Enabled:  true,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			SNI: &config.SNIConfig{
+				Routes: map[string][]string{"example.com": {"web"}},
+			},
+			ALPN: &config.ALPNConfig{
+				Routes: map[string][]string{"h2": {"grpc"}},
+			},
+		},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+	h := server.httpServer
+
+	for _, proto := range []string{"http/1.1", "h2"} {
+		req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		req.TLS.NegotiatedProtocol = proto
+		rec := httptest.NewRecorder()
+		h.handleRequest(rec, req)
+
+		want := "web"
+		if proto == "h2" {
+			want = "grpc"
+		}
+		if rec.Body.String() != want {
+			t.Errorf("ALPN %s: expected backend %s, got %q", proto, want, rec.Body.String())
+		}
+	}
+}
+
+func TestNextProtos(t *testing.T) {
+	cfg := &config.Config{
+		Mode: "http",
+		HTTP: &config.HTTPConfig{EnableHTTP2: true},
+		TLS: &config.TLSConfig{
+			ALPN: &config.ALPNConfig{
+				Routes: map[string][]string{"h2": {"grpc"}, "x-custom": {"custom"}},
+			},
+		},
+	}
+
+	if got := strings.Join(nextProtos(cfg), ","); got != "h2,http/1.1,x-custom" {
+		t.Errorf("Expected routed protocols to be offered after the defaults, got %s", got)
+	}
+
+	cfg.TLS.ALPNProtocols = []string{"http/1.1"}
+	if got := strings.Join(nextProtos(cfg), ","); got != "http/1.1" {
+		t.Errorf("Expected alpn_protocols to be used as-is, got %s", got)
+	}
+}
+
+func TestAutoSelfSignedCertificate(t *testing.T) {
+	cfg := &config.Config{
+		Mode: "tcp",
+		TLS:  &config.TLSConfig{Enabled: true, AutoSelfSigned: true},
+	}
+
+	termination, err := newTLSTermination(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+	if n := len(termination.certs.ListCertificates()); n != 1 {
+		t.Fatalf("Expected a generated certificate, got %d", n)
+	}
+
+	// The generated certificate is the default, so it is served for any hostname
+	cert, err := termination.config.GetCertificate(&tls.ClientHelloInfo{ServerName: "app.example.com"})
+	if err != nil || cert == nil {
+		t.Errorf("Expected the self-signed certificate to be served, got %v", err)
+	}
+}
+
+func TestReloadCertificates(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, "www.example.com")
+	cfg := &config.Config{
+		Mode: "tcp",
+		TLS:  &config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile},
+	}
+
+	termination, err := newTLSTermination(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+	original := termination.certs.Lookup("www.example.com")
+
+	// Unchanged files keep the current certificate
+	if err := termination.reloadCertificates(); err != nil {
+		t.Fatalf("Failed to reload certificates: %v", err)
+	}
+	if termination.certs.Lookup("www.example.com") != original {
+		t.Error("Expected an unchanged certificate to be kept")
+	}
+
+	// The certificate is rotated out of band
+	rotated, err := balancetls.GenerateSelfSignedCertificate([]string{"www.example.com"})
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	if err := balancetls.SaveCertificateToPEM(rotated, certFile, keyFile); err != nil {
+		t.Fatalf("Failed to save certificate: %v", err)
+	}
+	if err := termination.reloadCertificates(); err != nil {
+		t.Fatalf("Failed to reload certificates: %v", err)
+	}
+	served, err := termination.config.GetCertificate(&tls.ClientHelloInfo{ServerName: "www.example.com"})
+	if err != nil || string(served.Certificate[0]) != string(rotated.Cert.Raw) {
+		t.Errorf("Expected the rotated certificate to be served, got %v", err)
+	}
+
+	// A broken file is reported and the current certificate keeps serving
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := termination.reloadCertificates(); err == nil || !strings.Contains(err.Error(), certFile) {
+		t.Errorf("Expected an error naming %s, got %v", certFile, err)
+	}
+	if cert := termination.certs.Lookup("www.example.com"); cert == nil || !cert.Cert.Equal(rotated.Cert) {
+		t.Error("Expected the rotated certificate to keep serving")
+	}
+}
+
+func TestPerCertificateClientAuth(t *testing.T) {
+	internalCert, internalKey := writeTestCertificate(t, "api.internal.example.com")
+	publicCert, publicKey := writeTestCertificate(t, "www.example.com")
+	cfg := &config.Config{
+		Mode: "tcp",
+		TLS: &config.TLSConfig{
+			Enabled: true,
+			Certificates: []config.CertificateConfig{
+				{CertFile: internalCert, KeyFile: internalKey, ClientAuth: "require"},
+				{CertFile: publicCert, KeyFile: publicKey, Default: true},
+			},
+		},
+	}
+
+	termination, err := newTLSTermination(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := tls.Server(conn, termination.config)
+				if tlsConn.Handshake() == nil {
+					tlsConn.Write([]byte("ok"))
+				}
+			}()
+		}
+	}()
+
+	clientCert, err := balancetls.GenerateSelfSignedCertificate([]string{"client"})
+	if err != nil {
+		t.Fatalf("Failed to generate client certificate: %v", err)
+	}
+
+	connect := func(serverName string, certs []tls.Certificate) error {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+			ServerName:         serverName,
+			Certificates:       certs,
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		// With TLS 1.3 a rejected client certificate surfaces on the first read
+		_, err = io.ReadAll(conn)
+		return err
+	}
+
+	if err := connect("www.example.com", nil); err != nil {
+		t.Errorf("Expected public host to accept clients without a certificate, got %v", err)
+	}
+	if err := connect("api.internal.example.com", nil); err == nil {
+		t.Error("Expected internal host to reject clients without a certificate")
+	}
+	if err := connect("api.internal.example.com", []tls.Certificate{clientCert.TLSCert}); err != nil {
+		t.Errorf("Expected internal host to accept a client certificate, got %v", err)
+	}
+}
+
+func TestHTTPBackendTLS(t *testing.T) {
+	backendServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "client certificate required", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(r.TLS.PeerCertificates[0].DNSNames[0]))
+	}))
+	backendServer.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	backendServer.StartTLS()
+	defer backendServer.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backendServer.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	clientCert, clientKey := writeTestCertificate(t, "balance.example.com")
+
+	cfg := &config.Config{
+		Mode: "http",
+		Backends: []config.Backend{
+			{Name: "secure", Address: strings.TrimPrefix(backendServer.URL, "https://"), Weight: 1},
+		},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		HTTP:         &config.HTTPConfig{},
+		TLS: &config.TLSConfig{
+			Backend: &config.BackendTLSConfig{
+				Enabled:        true,
+				CAFile:         caFile,
+				ClientCertFile: clientCert,
+				ClientKeyFile:  clientKey,
+			},
+		},
+	}
+
+	server, err := NewHTTPServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP server: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.httpServer.handleRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != "balance.example.com" {
+		t.Errorf("Expected the backend to see the client certificate, got %q", rec.Body.String())
+	}
+}
+
+func TestVaultCertificate(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, "vault.example.com")
+	certPEM, _ := os.ReadFile(certFile)
+	keyPEM, _ := os.ReadFile(keyFile)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/pki/issue/edge" || r.Header.Get("X-Vault-Token") != "s.test" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"certificate":%q,"private_key":%q}}`, certPEM, keyPEM)
+	}))
+	defer vault.Close()
+
+	cfg := &config.Config{
+		Mode: "tcp",
+		TLS: &config.TLSConfig{
+			Enabled: true,
+			Certificates: []config.CertificateConfig{{
+				Vault: &config.VaultCertificateConfig{
+					Address:    vault.URL,
+					Token:      "s.test",
+					Mount:      "pki",
+					Role:       "edge",
+					CommonName: "vault.example.com",
+				},
+				Domains:    []string{"edge.example.com"},
+				ClientAuth: "require",
+			}},
+		},
+	}
+
+	termination, err := newTLSTermination(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+	cert := termination.certs.Lookup("edge.example.com")
+	if cert == nil || cert.Cert.Subject.CommonName != "vault.example.com" {
+		t.Fatal("Expected the Vault certificate to be served for the configured domain")
+	}
+	if len(termination.sourced) != 1 {
+		t.Errorf("Expected 1 refreshed certificate, got %d", len(termination.sourced))
+	}
+
+	// The client auth policy follows the certificate when it is refreshed
+	renewed, err := balancetls.GenerateSelfSignedCertificate([]string{"edge.example.com"})
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	if err := termination.certs.ReplaceCertificate(cert, renewed); err != nil {
+		t.Fatalf("Failed to replace certificate: %v", err)
+	}
+	termination.certificateReplaced(cert, renewed)
+	certTLS, _ := termination.config.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "edge.example.com"})
+	if certTLS == nil || certTLS.ClientAuth != tls.RequireAnyClientCert {
+		t.Error("Expected the renewed certificate to keep its client auth policy")
+	}
+
+	cfg.TLS.Certificates[0].Vault.Token = "wrong"
+	if _, err := newTLSTermination(cfg, nil); err == nil {
+		t.Error("Expected an error when Vault rejects the token")
+	}
+}
+
+func TestJA3Blocklist(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, "example.com")
+	cfg := &config.Config{
+		Mode: "tcp",
+		TLS:  &config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile},
+	}
+
+	// handshake connects once and returns the JA3 hash the server recorded
+	handshake := func(termination *tlsTermination) (string, error) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		tlsLn := termination.listener(ln)
+		defer tlsLn.Close()
+
+		recorded := make(chan string, 1)
+		go func() {
+			conn, err := tlsLn.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			conn.(*tls.Conn).Handshake()
+			recorded <- connJA3(conn)
+		}()
+
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"})
+		if err == nil {
+			conn.Close()
+		}
+		return <-recorded, err
+	}
+
+	termination, err := newTLSTermination(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+	ja3, err := handshake(termination)
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	if len(ja3) != 32 {
+		t.Fatalf("Expected a JA3 hash to be recorded, got %q", ja3)
+	}
+
+	cfg.Security = &config.SecurityConfig{BlockedJA3: []string{ja3}}
+	termination, err = newTLSTermination(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+	if _, err := handshake(termination); err == nil {
+		t.Error("Expected the handshake of a blocked fingerprint to fail")
+	}
+}
+
+func TestHandshakeErrorLog(t *testing.T) {
+	sm := security.NewSecurityManager(security.DefaultProtectionConfig(), nil)
+	w, err := security.NewBanWatcher(security.BanWatcherConfig{
+		Events:     []string{security.BanEventTLSHandshakeFailure},
+		Threshold:  2,
+		FindTime:   time.Hour,
+		BanTime:    time.Minute,
+		MaxBanTime: time.Hour,
+	}, sm.Blocklist())
+	if err != nil {
+		t.Fatalf("Failed to create ban watcher: %v", err)
+	}
+	sm.SetBanWatcher(w)
+	errorLog := handshakeErrorLog{security: sm, next: io.Discard}
+
+	// Other messages of the HTTP server are only logged
+	fmt.Fprintf(errorLog, "http: panic serving 198.51.100.1:4242: boom\n")
+	fmt.Fprintf(errorLog, "http: panic serving 198.51.100.1:4242: boom\n")
+	if sm.Blocklist().IsBlocked("198.51.100.1") {
+		t.Error("Expected other errors not to ban the client")
+	}
+
+	for _, ip := range []string{"198.51.100.1", "2001:db8::1"} {
+		for i := 0; i < 2; i++ {
+			fmt.Fprintf(errorLog, "%s%s: remote error: tls: bad certificate\n", handshakeErrorPrefix, net.JoinHostPort(ip, "4242"))
+		}
+		if !sm.Blocklist().IsBlocked(ip) {
+			t.Errorf("Expected %s to be banned after failed handshakes", ip)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"net"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/pool"
+)
+
+// newConnectionWorkers returns the goroutine pool handling TCP connections (nil if
+// connection_workers is not configured)
+func newConnectionWorkers(cfg *config.Config) *pool.GoroutinePool {
+	w := cfg.ConnectionWorkers
+	if w == nil {
+		return nil
+	}
+	return pool.NewGoroutinePool(pool.GoroutinePoolConfig{
+		MaxWorkers:  w.MaxWorkers,
+		MaxIdleTime: w.IdleTimeout,
+		QueueSize:   w.QueueSize,
+		NonBlocking: w.Overflow != "block",
+	})
+}
+
+// dispatch hands an accepted connection to its handler, on a new goroutine or on a
+// connection worker
+// With the "reject" overflow policy a connection is closed if every worker is busy and
+// the queue is full; with "block" the accept loop waits for a free worker.
+func (s *Server) dispatch(conn net.Conn) {
+	handle := s.handleConnection
+	if s.sniff {
+		handle = s.handleSniffedConnection
+	}
+
+	s.wg.Add(1)
+	if s.workers == nil {
+		go handle(conn)
+		return
+	}
+
+	// Queued connections are closed with the others if the drain timeout expires
+	untrack := s.conns.add(nil, func() { conn.Close() })
+	task := func() {
+		untrack()
+		handle(conn)
+	}
+
+	var err error
+	if s.config.ConnectionWorkers.Overflow == "block" {
+		err = s.workers.SubmitWithContext(s.ctx, task)
+	} else {
+		err = s.workers.Submit(task)
+	}
+	if err != nil {
+		untrack()
+		conn.Close()
+		s.wg.Done()
+		s.logger.Debug("Rejected connection, no connection worker available",
+			logging.String("client", conn.RemoteAddr().String()), logging.Err(err))
+	}
+}
+
+// workerStats returns the connection worker statistics
+func (s *Server) workerStats() map[string]interface{} {
+	stats := s.workers.Stats()
+	return map[string]interface{}{
+		"max_workers":          s.config.ConnectionWorkers.MaxWorkers,
+		"workers":              stats.Workers,
+		"busy_workers":         stats.Running,
+		"queued_connections":   stats.QueueSize,
+		"rejected_connections": stats.Rejected,
+	}
+}
@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/config"
+)
+
+// startEchoBackend starts a TCP backend echoing what it reads
+func startEchoBackend(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// startWorkerServer starts a TCP proxy serving one connection at a time
+func startWorkerServer(t *testing.T, backend, overflow string) *Server {
+	t.Helper()
+	server, err := NewTCPServer(&config.Config{
+		Mode:         "tcp",
+		Listen:       "127.0.0.1:0",
+		Backends:     []config.Backend{{Name: "echo", Address: backend, Weight: 1}},
+		LoadBalancer: config.LoadBalancerConfig{Algorithm: "round-robin"},
+		Timeouts:     config.TimeoutConfig{Connect: time.Second},
+		Shutdown:     &config.ShutdownConfig{DrainTimeout: 100 * time.Millisecond},
+		ConnectionWorkers: &config.ConnectionWorkersConfig{
+			MaxWorkers:  1,
+			Overflow:    overflow,
+			IdleTimeout: time.Second,
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create TCP server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { server.Shutdown() })
+	return server
+}
+
+// echo sends a message over conn and reports whether it came back
+func echo(conn net.Conn, msg string) bool {
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return false
+	}
+	buf := make([]byte, len(msg))
+	_, err := io.ReadFull(conn, buf)
+	return err == nil && string(buf) == msg
+}
+
+func TestConnectionWorkersReject(t *testing.T) {
+	server := startWorkerServer(t, startEchoBackend(t), "reject")
+	addr := server.listener.Addr().String()
+
+	// The first connection takes the only worker
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer first.Close()
+	if !echo(first, "hello") {
+		t.Fatal("Expected the first connection to be proxied")
+	}
+
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer second.Close()
+	if echo(second, "hello") {
+		t.Error("Expected the connection over the worker limit to be closed")
+	}
+	stats := server.Stats()["connection_workers"].(map[string]interface{})
+	if stats["rejected_connections"] != uint64(1) || stats["busy_workers"] != int32(1) {
+		t.Errorf("Unexpected worker stats: %v", stats)
+	}
+
+	// The worker serves the next connection once the first one closes
+	first.Close()
+	for deadline := time.Now().Add(time.Second); server.workers.Stats().Running > 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	third, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer third.Close()
+	if !echo(third, "again") {
+		t.Error("Expected a connection to be proxied once the worker is free")
+	}
+}
+
+func TestConnectionWorkersBlock(t *testing.T) {
+	server := startWorkerServer(t, startEchoBackend(t), "block")
+	addr := server.listener.Addr().String()
+
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer first.Close()
+	if !echo(first, "hello") {
+		t.Fatal("Expected the first connection to be proxied")
+	}
+
+	// The second connection waits for the worker instead of being closed
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer second.Close()
+	served := make(chan bool, 1)
+	go func() {
+		second.SetDeadline(time.Now().Add(2 * time.Second))
+		second.Write([]byte("queued"))
+		buf := make([]byte, len("queued"))
+		_, err := io.ReadFull(second, buf)
+		served <- err == nil && string(buf) == "queued"
+	}()
+
+	select {
+	case <-served:
+		t.Fatal("Expected the second connection to wait while the worker is busy")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	first.Close()
+	if !<-served {
+		t.Error("Expected the waiting connection to be proxied once the worker is free")
+	}
+	if rejected := server.workers.Stats().Rejected; rejected != 0 {
+		t.Errorf("Expected no rejected connections, got %d", rejected)
+	}
+}
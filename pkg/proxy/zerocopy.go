@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"crypto/tls"
 	"io"
 	"net"
 	"syscall"
@@ -9,7 +10,7 @@ import (
 // ZeroCopier provides zero-copy data transfer capabilities
 type ZeroCopier interface {
 	// Copy copies data from src to dst using zero-copy techniques when possible
-	Copy(dst, src net.Conn) (written int64, err error)
+	Copy(dst io.Writer, src io.Reader) (written int64, err error)
 }
 
 // DefaultZeroCopier is the default zero-copy implementation
@@ -28,46 +29,53 @@ func NewDefaultZeroCopier(bufferSize int) *DefaultZeroCopier {
 }
 
 // Copy implements zero-copy transfer when possible
-func (z *DefaultZeroCopier) Copy(dst, src net.Conn) (written int64, err error) {
-	// Try to use splice/sendfile for TCP connections on Linux
-	if tcpSrc, ok := src.(*net.TCPConn); ok {
-		if tcpDst, ok := dst.(*net.TCPConn); ok {
-			return z.tcpSplice(tcpDst, tcpSrc)
+func (z *DefaultZeroCopier) Copy(dst io.Writer, src io.Reader) (written int64, err error) {
+	// Use splice for TCP-to-TCP transfers on Linux
+	if tcpSrc, ok := src.(net.Conn); ok {
+		if tcpDst, ok := dst.(net.Conn); ok {
+			if written, ok, err := z.tcpSplice(tcpDst, tcpSrc); ok {
+				return written, err
+			}
 		}
 	}
 
-	// Fallback to regular io.Copy
-	return io.Copy(dst, src)
+	// Fallback to a buffered copy
+	return io.CopyBuffer(dst, src, make([]byte, z.bufferSize))
 }
 
-// tcpSplice attempts to use splice for zero-copy TCP transfer
-func (z *DefaultZeroCopier) tcpSplice(dst, src *net.TCPConn) (written int64, err error) {
-	// Get raw file descriptors
-	srcFile, err := src.File()
-	if err != nil {
-		// Fallback to regular copy
-		return io.Copy(dst, src)
-	}
-	defer srcFile.Close()
-
-	dstFile, err := dst.File()
-	if err != nil {
-		// Fallback to regular copy
-		return io.Copy(dst, src)
+// tcpSplice transfers between two TCP connections using the runtime's ReadFrom fast path,
+// which uses splice(2) on Linux and stays integrated with the network poller and deadlines
+// ok is false if either side is not a TCP connection
+func (z *DefaultZeroCopier) tcpSplice(dst, src net.Conn) (written int64, ok bool, err error) {
+	tcpDst, dstOK := unwrapTCPConn(dst)
+	tcpSrc, srcOK := unwrapTCPConn(src)
+	if !dstOK || !srcOK {
+		return 0, false, nil
 	}
-	defer dstFile.Close()
 
-	srcFd := int(srcFile.Fd())
-	dstFd := int(dstFile.Fd())
+	written, err = tcpDst.ReadFrom(tcpSrc)
+	return written, true, err
+}
 
-	// Try splice on Linux
-	written, err = spliceCopy(dstFd, srcFd, z.bufferSize)
-	if err != nil {
-		// If splice fails, fallback to regular copy
-		return io.Copy(dst, src)
+// unwrapTCPConn returns the *net.TCPConn underneath any proxy connection wrappers
+// TLS and protocol-detected connections are never unwrapped, since their bytes must go
+// through the TLS layer or the read-ahead buffer
+func unwrapTCPConn(conn net.Conn) (*net.TCPConn, bool) {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c, true
+		case *tls.Conn:
+			return nil, false
+		case *peekedConn:
+			// Bytes read ahead during protocol detection must go through its reader
+			return nil, false
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil, false
+		}
 	}
-
-	return written, nil
 }
 
 // BidirectionalCopy copies data bidirectionally between two connections
@@ -11,21 +11,27 @@ import (
 func TestDefaultZeroCopier(t *testing.T) {
 	copier := NewDefaultZeroCopier(32 * 1024)
 
-	// Create test connections
-	client, server := net.Pipe()
+	// Create test connections: client -> (srcServer => dstClient) -> dstServer
+	client, srcServer := net.Pipe()
 	defer client.Close()
+	defer srcServer.Close()
+
+	dstClient, server := net.Pipe()
+	defer dstClient.Close()
 	defer server.Close()
 
 	testData := []byte("Hello, World!")
 
 	// Start copying in background
 	go func() {
-		copier.Copy(server, client)
+		copier.Copy(dstClient, srcServer)
 	}()
 
 	// Write data
-	client.Write(testData)
-	client.Close()
+	go func() {
+		client.Write(testData)
+		client.Close()
+	}()
 
 	// Read data
 	buf := make([]byte, len(testData))
@@ -90,6 +96,121 @@ func TestBidirectionalCopy(t *testing.T) {
 	}
 }
 
+func TestDefaultZeroCopierTCP(t *testing.T) {
+	copier := NewDefaultZeroCopier(32 * 1024)
+
+	// Two TCP pairs so Copy can take the splice path
+	dial := func() (net.Conn, net.Conn) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer ln.Close()
+
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		server, err := ln.Accept()
+		if err != nil {
+			t.Fatalf("Failed to accept: %v", err)
+		}
+		return client, server
+	}
+
+	client, srcServer := dial()
+	defer client.Close()
+	defer srcServer.Close()
+
+	dstClient, server := dial()
+	defer dstClient.Close()
+	defer server.Close()
+
+	// Wrap the source in the proxy's connection wrappers
+	activity := newStreamActivity()
+	src := newDeadlineConn(srcServer, time.Second, time.Second, activity)
+	dst := newDeadlineConn(dstClient, time.Second, time.Second, activity)
+
+	testData := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+
+	done := make(chan int64, 1)
+	go func() {
+		n, _ := copier.Copy(dst, src)
+		dst.CloseWrite()
+		done <- n
+	}()
+
+	go func() {
+		client.Write(testData)
+		client.(*net.TCPConn).CloseWrite()
+	}()
+
+	got, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Errorf("Data mismatch: expected %d bytes, got %d", len(testData), len(got))
+	}
+	if n := <-done; n != int64(len(testData)) {
+		t.Errorf("Expected %d bytes copied, got %d", len(testData), n)
+	}
+}
+
+func TestDeadlineConnReadFrom(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	pair := func() (net.Conn, net.Conn) {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		server, err := ln.Accept()
+		if err != nil {
+			t.Fatalf("Failed to accept: %v", err)
+		}
+		return client, server
+	}
+
+	client, srcServer := pair()
+	defer client.Close()
+	dstClient, server := pair()
+	defer server.Close()
+
+	activity := newStreamActivity()
+	src := newDeadlineConn(srcServer, time.Second, time.Second, activity)
+	dst := newDeadlineConn(dstClient, time.Second, time.Second, activity)
+
+	testData := bytes.Repeat([]byte("x"), 3*spliceChunkSize+123)
+	go func() {
+		client.Write(testData)
+		client.Close()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(dst, src)
+		dst.Close()
+		src.Close()
+		done <- err
+	}()
+
+	got, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if len(got) != len(testData) {
+		t.Errorf("Expected %d bytes, got %d", len(testData), len(got))
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Copy failed: %v", err)
+	}
+}
+
 func TestReadWriteOptimizer(t *testing.T) {
 	optimizer := NewReadWriteOptimizer(32 * 1024)
 
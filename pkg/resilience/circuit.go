@@ -246,6 +246,12 @@ func (cb *CircuitBreaker) onFailure(state CircuitState) {
 	}
 }
 
+// IsOpen reports whether the breaker rejects requests: it is open and its timeout
+// has not elapsed yet
+func (cb *CircuitBreaker) IsOpen() bool {
+	return cb.GetState() == StateOpen && time.Since(cb.getLastFailTime()) <= cb.timeout
+}
+
 // GetState returns the current state
 func (cb *CircuitBreaker) GetState() CircuitState {
 	return cb.state.Load().(CircuitState)
@@ -107,13 +107,18 @@ func TestCircuitBreaker_OpenToHalfOpen(t *testing.T) {
 		return errors.New("fail")
 	})
 
-	if cb.GetState() != StateOpen {
+	if cb.GetState() != StateOpen || !cb.IsOpen() {
 		t.Fatal("Expected circuit to be open")
 	}
 
 	// Wait for timeout
 	time.Sleep(150 * time.Millisecond)
 
+	// The breaker lets the next request through once the timeout elapsed
+	if cb.IsOpen() {
+		t.Error("Expected circuit to accept requests after the timeout")
+	}
+
 	// Next request should transition to half-open
 	err := cb.Execute(func() error {
 		return nil
@@ -0,0 +1,12 @@
+//go:build !unix
+// +build !unix
+
+package resilience
+
+import "time"
+
+// processCPUTime is not supported on this platform, so load shedding only uses the
+// control loop delay
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -13,6 +13,9 @@ import (
 var (
 	// ErrMaxRetriesExceeded is returned when max retries are exceeded
 	ErrMaxRetriesExceeded = errors.New("maximum retry attempts exceeded")
+
+	// ErrRetryBudgetExceeded is returned when the retry budget does not allow another attempt
+	ErrRetryBudgetExceeded = errors.New("retry budget exceeded")
 )
 
 // RetryPolicy defines the retry behavior
@@ -37,6 +40,9 @@ type RetryPolicy struct {
 
 	// OnRetry is called before each retry attempt
 	OnRetry func(attempt int, err error, delay time.Duration)
+
+	// Budget caps retries across all callers sharing it (nil = unlimited)
+	Budget *RetryBudget
 }
 
 // DefaultRetryPolicy returns a default retry policy
@@ -84,7 +90,12 @@ func RetryWithContext(ctx context.Context, fn func(context.Context) error, polic
 
 		// Check if we've exceeded max attempts
 		if policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
-			return fmt.Errorf("%w after %d attempts: %v", ErrMaxRetriesExceeded, attempts, lastErr)
+			return fmt.Errorf("%w after %d attempts: %w", ErrMaxRetriesExceeded, attempts, lastErr)
+		}
+
+		// Check the shared retry budget
+		if policy.Budget != nil && !policy.Budget.CanRetry() {
+			return fmt.Errorf("%w after %d attempts: %w", ErrRetryBudgetExceeded, attempts, lastErr)
 		}
 
 		// Check if context is done
@@ -187,8 +198,8 @@ func (rb *RetryBudget) CanRetry() bool {
 
 	rb.maybeReset()
 
-	// Calculate minimum retries allowed
-	elapsed := time.Since(rb.lastReset)
+	// Calculate minimum retries allowed (at least one second's worth, so a fresh window is usable)
+	elapsed := max(time.Since(rb.lastReset), time.Second)
 	minRetries := int64(float64(rb.MinRetriesPerSecond) * elapsed.Seconds())
 
 	// Calculate ratio-based retries allowed
@@ -419,3 +419,29 @@ func TestCalculateBackoff(t *testing.T) {
 		})
 	}
 }
+
+func TestRetry_Budget(t *testing.T) {
+	budget := NewRetryBudget(time.Minute, 1, 0)
+	policy := RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1,
+		Budget:       budget,
+	}
+
+	testErr := errors.New("test error")
+	attempts := 0
+	err := Retry(func() error {
+		attempts++
+		return testErr
+	}, policy)
+
+	// The budget allows a single retry
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+	if !errors.Is(err, ErrRetryBudgetExceeded) || !errors.Is(err, testErr) {
+		t.Errorf("Expected ErrRetryBudgetExceeded wrapping the last error, got %v", err)
+	}
+}
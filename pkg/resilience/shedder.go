@@ -0,0 +1,187 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Priority is how important a request is when load is shed
+type Priority int
+
+const (
+	// PriorityLow requests are shed first
+	PriorityLow Priority = -1
+
+	// PriorityNormal requests are shed once all low-priority requests are
+	PriorityNormal Priority = 0
+
+	// PriorityHigh requests are never shed
+	PriorityHigh Priority = 1
+)
+
+// ParsePriority parses "low", "normal" or "high" ("" is normal)
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	return PriorityNormal, fmt.Errorf("invalid priority: %s (must be low, normal or high)", s)
+}
+
+// String returns the name of the priority
+func (p Priority) String() string {
+	switch {
+	case p < PriorityNormal:
+		return "low"
+	case p > PriorityNormal:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// LoadShedderConfig configures a load shedder
+type LoadShedderConfig struct {
+	// Interval is how often the load is measured and the shed fraction adjusted
+	Interval time.Duration
+
+	// MaxLag is the scheduling delay of the control loop above which the process
+	// is considered saturated
+	MaxLag time.Duration
+
+	// MaxCPU is the process CPU utilization (0-1 of GOMAXPROCS) above which the process
+	// is considered saturated (0 = ignore CPU)
+	MaxCPU float64
+
+	// Step is how much the shed fraction changes per interval
+	Step float64
+
+	// MaxFraction caps the fraction of requests shed
+	MaxFraction float64
+}
+
+// LoadShedder rejects a growing fraction of requests while the process itself is
+// saturated, so the requests it keeps are served with low latency
+// Saturation is detected from the scheduling delay of its own control loop, which
+// grows when goroutines wait for a CPU, and from the process CPU utilization. The
+// shed fraction rises by Step every interval the process is saturated and falls by
+// Step every interval it is not. Low-priority requests are shed at twice the
+// fraction, normal ones only once the fraction exceeds one half, and high-priority
+// requests never.
+type LoadShedder struct {
+	config LoadShedderConfig
+
+	// fraction is the float64 bits of the current shed fraction
+	fraction atomic.Uint64
+
+	// Last measurements
+	lag atomic.Int64
+	cpu atomic.Uint64
+
+	// Statistics
+	shedLow    atomic.Int64
+	shedNormal atomic.Int64
+}
+
+// NewLoadShedder creates a load shedder; it adjusts the shed fraction once Run is called
+func NewLoadShedder(config LoadShedderConfig) *LoadShedder {
+	return &LoadShedder{config: config}
+}
+
+// Allow reports whether a request of the given priority should be served
+func (s *LoadShedder) Allow(p Priority) bool {
+	fraction := s.Fraction()
+	if fraction == 0 || p >= PriorityHigh {
+		return true
+	}
+
+	probability := min(2*fraction, 1)
+	if p == PriorityNormal {
+		probability = max(2*fraction-1, 0)
+	}
+	if probability == 0 || rand.Float64() >= probability {
+		return true
+	}
+
+	if p == PriorityNormal {
+		s.shedNormal.Add(1)
+	} else {
+		s.shedLow.Add(1)
+	}
+	return false
+}
+
+// Fraction returns the fraction of requests currently shed
+func (s *LoadShedder) Fraction() float64 {
+	return math.Float64frombits(s.fraction.Load())
+}
+
+// Run measures the load every interval and adjusts the shed fraction until ctx is done
+func (s *LoadShedder) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	lastWall := time.Now()
+	lastCPU, cpuOK := processCPUTime()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case due := <-ticker.C:
+			now := time.Now()
+
+			// The tick carries the time it was due; the delay until this loop runs
+			// is how long runnable goroutines wait for a CPU
+			lag := now.Sub(due)
+
+			var utilization float64
+			if cpu, ok := processCPUTime(); ok && cpuOK {
+				wall := now.Sub(lastWall) * time.Duration(runtime.GOMAXPROCS(0))
+				if wall > 0 {
+					utilization = float64(cpu-lastCPU) / float64(wall)
+				}
+				lastCPU = cpu
+			}
+			lastWall = now
+
+			s.update(lag, utilization)
+		}
+	}
+}
+
+// update adjusts the shed fraction from one measurement of the load
+func (s *LoadShedder) update(lag time.Duration, cpu float64) {
+	s.lag.Store(int64(lag))
+	s.cpu.Store(math.Float64bits(cpu))
+
+	saturated := lag > s.config.MaxLag || (s.config.MaxCPU > 0 && cpu > s.config.MaxCPU)
+	fraction := s.Fraction()
+	if saturated {
+		fraction = min(fraction+s.config.Step, s.config.MaxFraction)
+	} else {
+		fraction = max(fraction-s.config.Step, 0)
+	}
+	// Rounding keeps repeated steps from leaving a tiny fraction behind
+	fraction = math.Round(fraction*1e6) / 1e6
+	s.fraction.Store(math.Float64bits(fraction))
+}
+
+// Stats returns load shedding statistics
+func (s *LoadShedder) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"shed_fraction":   s.Fraction(),
+		"lag_ms":          float64(s.lag.Load()) / float64(time.Millisecond),
+		"cpu_utilization": math.Float64frombits(s.cpu.Load()),
+		"shed_low":        s.shedLow.Load(),
+		"shed_normal":     s.shedNormal.Load(),
+	}
+}
@@ -0,0 +1,122 @@
+package resilience
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestShedder() *LoadShedder {
+	return NewLoadShedder(LoadShedderConfig{
+		Interval:    10 * time.Millisecond,
+		MaxLag:      50 * time.Millisecond,
+		MaxCPU:      0.9,
+		Step:        0.25,
+		MaxFraction: 0.75,
+	})
+}
+
+func TestLoadShedder_Update(t *testing.T) {
+	s := newTestShedder()
+
+	// Saturation from either signal raises the fraction up to the maximum
+	s.update(100*time.Millisecond, 0)
+	if f := s.Fraction(); f != 0.25 {
+		t.Errorf("Expected fraction 0.25 after high lag, got %v", f)
+	}
+	s.update(0, 0.95)
+	s.update(0, 0.95)
+	s.update(0, 0.95)
+	if f := s.Fraction(); f != 0.75 {
+		t.Errorf("Expected fraction capped at 0.75, got %v", f)
+	}
+
+	// Recovery lowers it back to zero
+	for i := 0; i < 4; i++ {
+		s.update(time.Millisecond, 0.1)
+	}
+	if f := s.Fraction(); f != 0 {
+		t.Errorf("Expected fraction 0 after recovery, got %v", f)
+	}
+}
+
+func TestLoadShedder_AllowByPriority(t *testing.T) {
+	s := newTestShedder()
+
+	// Nothing is shed while the process is not saturated
+	if !s.Allow(PriorityLow) || !s.Allow(PriorityNormal) || !s.Allow(PriorityHigh) {
+		t.Error("Expected all requests to be allowed")
+	}
+
+	// At half, every low-priority request is shed and no normal one
+	s.update(time.Second, 0)
+	s.update(time.Second, 0)
+	for i := 0; i < 100; i++ {
+		if s.Allow(PriorityLow) {
+			t.Fatal("Expected low-priority requests to be shed")
+		}
+		if !s.Allow(PriorityNormal) || !s.Allow(PriorityHigh) {
+			t.Fatal("Expected normal and high-priority requests to be allowed")
+		}
+	}
+
+	// Beyond half, normal requests are shed too, but never high-priority ones
+	s.update(time.Second, 0)
+	shed := 0
+	for i := 0; i < 1000; i++ {
+		if !s.Allow(PriorityNormal) {
+			shed++
+		}
+		if !s.Allow(PriorityHigh) {
+			t.Fatal("Expected high-priority requests to be allowed")
+		}
+	}
+	if shed < 300 || shed > 700 {
+		t.Errorf("Expected about half of normal requests to be shed, got %d of 1000", shed)
+	}
+
+	stats := s.Stats()
+	if stats["shed_low"] != int64(100) || stats["shed_normal"] != int64(shed) {
+		t.Errorf("Unexpected shed counts: %v", stats)
+	}
+}
+
+func TestLoadShedder_Run(t *testing.T) {
+	s := newTestShedder()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after the context was canceled")
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Priority
+		wantErr bool
+	}{
+		{"", PriorityNormal, false},
+		{"low", PriorityLow, false},
+		{"normal", PriorityNormal, false},
+		{"high", PriorityHigh, false},
+		{"urgent", PriorityNormal, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePriority(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePriority(%q) = %v, %v; want %v, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
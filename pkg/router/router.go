@@ -1,7 +1,11 @@
 package router
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"net/http"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -12,6 +16,7 @@ import (
 // Router handles HTTP request routing
 type Router struct {
 	routes       []*RouteEntry
+	defaultRoute *RouteEntry
 	defaultPool  *backend.Pool
 }
 
@@ -19,6 +24,10 @@ type Router struct {
 type RouteEntry struct {
 	config  config.Route
 	pool    *backend.Pool
+	headers []*headerMatcher
+
+	// overflow receives requests while the pool is at capacity (nil if none)
+	overflow *backend.Pool
 }
 
 // NewRouter creates a new HTTP router
@@ -35,14 +44,60 @@ func NewRouter(routes []config.Route, allBackends *backend.Pool) *Router {
 		// Add specified backends to this route's pool
 		for _, backendName := range routeCfg.Backends {
 			if b := allBackends.GetByName(backendName); b != nil {
-				pool.Add(b)
+				if err := pool.Add(b); err != nil {
+					log.Printf("Route %s: ignoring %v", routeCfg.Name, err)
+				}
+			}
+		}
+
+		// Add backends matching the route's label selector
+		if routeCfg.Selector != "" {
+			selector, err := config.ParseLabelSelector(routeCfg.Selector)
+			if err != nil {
+				log.Printf("Route %s: ignoring %v", routeCfg.Name, err)
+			}
+			for _, b := range allBackends.All() {
+				if selector != nil && b.MatchLabels(selector) && pool.GetByName(b.Name()) == nil {
+					if err := pool.Add(b); err != nil {
+						log.Printf("Route %s: ignoring %v", routeCfg.Name, err)
+					}
+				}
 			}
 		}
 
-		r.routes = append(r.routes, &RouteEntry{
-			config: routeCfg,
-			pool:   pool,
-		})
+		var overflow *backend.Pool
+		for _, backendName := range routeCfg.Overflow {
+			if b := allBackends.GetByName(backendName); b != nil {
+				if overflow == nil {
+					overflow = backend.NewPool()
+				}
+				if err := overflow.Add(b); err != nil {
+					log.Printf("Route %s: ignoring overflow %v", routeCfg.Name, err)
+				}
+			}
+		}
+
+		headers := make([]*headerMatcher, 0, len(routeCfg.Headers))
+		for name, condition := range routeCfg.Headers {
+			m, err := newHeaderMatcher(name, condition)
+			if err != nil {
+				// An invalid condition never matches, so the route is effectively disabled
+				log.Printf("Route %s: %v", routeCfg.Name, err)
+				m = &headerMatcher{name: name, invalid: true}
+			}
+			headers = append(headers, m)
+		}
+
+		entry := &RouteEntry{
+			config:   routeCfg,
+			pool:     pool,
+			headers:  headers,
+			overflow: overflow,
+		}
+		if routeCfg.Default {
+			r.defaultRoute = entry
+		}
+		r.routes = append(r.routes, entry)
 	}
 
 	// Sort routes by priority (higher priority first)
@@ -53,21 +108,72 @@ func NewRouter(routes []config.Route, allBackends *backend.Pool) *Router {
 	return r
 }
 
+// routeContextKey is the context key for the matched route
+type routeContextKey struct{}
+
+// NewContext returns a copy of ctx carrying the matched route
+func NewContext(ctx context.Context, route *RouteEntry) context.Context {
+	return context.WithValue(ctx, routeContextKey{}, route)
+}
+
+// FromContext returns the route stored in ctx by NewContext (nil if none)
+func FromContext(ctx context.Context) *RouteEntry {
+	route, _ := ctx.Value(routeContextKey{}).(*RouteEntry)
+	return route
+}
+
+// Name returns the route name
+func (e *RouteEntry) Name() string {
+	return e.config.Name
+}
+
+// Config returns the route configuration
+func (e *RouteEntry) Config() config.Route {
+	return e.config
+}
+
+// Pool returns the route's backend pool
+func (e *RouteEntry) Pool() *backend.Pool {
+	return e.pool
+}
+
+// Overflow returns the pool of the route's overflow backends (nil if it has none)
+func (e *RouteEntry) Overflow() *backend.Pool {
+	return e.overflow
+}
+
 // Match finds the best matching route for the given request
 func (r *Router) Match(req *http.Request) *backend.Pool {
+	if route := r.MatchRoute(req); route != nil {
+		return route.pool
+	}
+
+	// No route matched, use default pool
+	return r.defaultPool
+}
+
+// MatchRoute returns the highest-priority route matching the request, or the default
+// route if none match (nil if there is no default route)
+func (r *Router) MatchRoute(req *http.Request) *RouteEntry {
 	// Try each route in priority order
 	for _, route := range r.routes {
-		if r.matchRoute(req, &route.config) {
-			return route.pool
+		if route != r.defaultRoute && r.matchRoute(req, route) {
+			return route
 		}
 	}
+	return r.defaultRoute
+}
 
-	// No route matched, use default pool
-	return r.defaultPool
+// Routes returns the routes in matching order
+func (r *Router) Routes() []*RouteEntry {
+	return r.routes
 }
 
 // matchRoute checks if a request matches a route
-func (r *Router) matchRoute(req *http.Request, route *config.Route) bool {
+func (r *Router) matchRoute(req *http.Request, entry *RouteEntry) bool {
+	route := &entry.config
+
+
 	// Check host matching
 	if route.Host != "" {
 		if !matchHost(req.Host, route.Host) {
@@ -83,9 +189,21 @@ func (r *Router) matchRoute(req *http.Request, route *config.Route) bool {
 	}
 
 	// Check header matching
-	if len(route.Headers) > 0 {
-		for key, value := range route.Headers {
-			if req.Header.Get(key) != value {
+	for _, m := range entry.headers {
+		if !m.match(req.Header) {
+			return false
+		}
+	}
+
+	// Check query parameter matching
+	if len(route.QueryParams) > 0 {
+		query := req.URL.Query()
+		for key, value := range route.QueryParams {
+			if value == "*" {
+				if !query.Has(key) {
+					return false
+				}
+			} else if query.Get(key) != value {
 				return false
 			}
 		}
@@ -94,6 +212,66 @@ func (r *Router) matchRoute(req *http.Request, route *config.Route) bool {
 	return true
 }
 
+// headerMatcher matches a request header against a route condition:
+// "value" (exact), "*" (present), "~regex", or any of these prefixed with "!" to negate.
+// A leading backslash makes the rest of the condition an exact value (e.g. \!important).
+type headerMatcher struct {
+	name    string
+	value   string
+	regex   *regexp.Regexp
+	present bool // only require the header to be present
+	negate  bool
+	invalid bool // never matches
+}
+
+// newHeaderMatcher compiles a header condition
+func newHeaderMatcher(name, condition string) (*headerMatcher, error) {
+	m := &headerMatcher{name: name}
+
+	if rest, ok := strings.CutPrefix(condition, `\`); ok {
+		m.value = rest
+		return m, nil
+	}
+	if rest, ok := strings.CutPrefix(condition, "!"); ok {
+		m.negate = true
+		condition = rest
+	}
+
+	switch {
+	case condition == "*":
+		m.present = true
+	case strings.HasPrefix(condition, "~"):
+		re, err := regexp.Compile(condition[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for header %s: %w", name, err)
+		}
+		m.regex = re
+	default:
+		m.value = strings.TrimPrefix(condition, `\`)
+	}
+	return m, nil
+}
+
+// match reports whether the request headers satisfy the condition
+// Negated conditions also match when the header is absent
+func (m *headerMatcher) match(h http.Header) bool {
+	if m.invalid {
+		return false
+	}
+
+	var matched bool
+	switch {
+	case m.present:
+		matched = len(h.Values(m.name)) > 0
+	case m.regex != nil:
+		values := h.Values(m.name)
+		matched = len(values) > 0 && m.regex.MatchString(values[0])
+	default:
+		matched = h.Get(m.name) == m.value
+	}
+	return matched != m.negate
+}
+
 // matchHost checks if the request host matches the route host pattern
 func matchHost(requestHost, routeHost string) bool {
 	// Remove port from request host
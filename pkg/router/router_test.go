@@ -212,6 +212,54 @@ func TestRouterHeaderMatching(t *testing.T) {
 	}
 }
 
+func TestRouterHeaderConditions(t *testing.T) {
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("backend", "localhost:9001", 1))
+
+	tests := []struct {
+		name      string
+		condition string
+		header    string // empty = header absent
+		matches   bool
+	}{
+		{"exact", "premium", "premium", true},
+		{"exact mismatch", "premium", "basic", false},
+		{"present", "*", "anything", true},
+		{"present but absent", "*", "", false},
+		{"regex", "~(?i)(android|iphone)", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0)", true},
+		{"regex mismatch", "~(?i)(android|iphone)", "Mozilla/5.0 (X11; Linux x86_64)", false},
+		{"regex absent", "~.*", "", false},
+		{"negated exact", "!internal", "external", true},
+		{"negated exact matching", "!internal", "internal", false},
+		{"negated exact absent", "!internal", "", true},
+		{"negated presence", "!*", "", true},
+		{"negated presence present", "!*", "x", false},
+		{"negated regex", "!~^curl/", "Mozilla/5.0", true},
+		{"negated regex matching", "!~^curl/", "curl/8.0", false},
+		{"escaped literal", `\!important`, "!important", true},
+		{"invalid regex never matches", "~(", "(", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter([]config.Route{{
+				Name:     "route",
+				Headers:  map[string]string{"X-Test": tt.condition},
+				Backends: []string{"backend"},
+			}}, pool)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Test", tt.header)
+			}
+
+			if matched := router.MatchRoute(req) != nil; matched != tt.matches {
+				t.Errorf("Condition %q with header %q: expected match=%v, got %v", tt.condition, tt.header, tt.matches, matched)
+			}
+		})
+	}
+}
+
 func TestRouterPriority(t *testing.T) {
 	// Create backend pool
 	pool := backend.NewPool()
@@ -354,6 +402,113 @@ func TestRouterCombinedMatching(t *testing.T) {
 	}
 }
 
+func TestRouterQueryParamMatching(t *testing.T) {
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("v2", "localhost:9001", 1))
+	pool.Add(backend.NewBackend("beta", "localhost:9002", 1))
+	pool.Add(backend.NewBackend("stable", "localhost:9003", 1))
+
+	routes := []config.Route{
+		{Name: "v2", QueryParams: map[string]string{"version": "2"}, Backends: []string{"v2"}, Priority: 10},
+		{Name: "beta", QueryParams: map[string]string{"beta": "*"}, Backends: []string{"beta"}, Priority: 5},
+	}
+
+	router := NewRouter(routes, pool)
+
+	tests := []struct {
+		name          string
+		target        string
+		expectedRoute string
+	}{
+		{"exact match", "/items?version=2", "v2"},
+		{"exact mismatch", "/items?version=1", ""},
+		{"presence with value", "/items?beta=true", "beta"},
+		{"presence without value", "/items?beta", "beta"},
+		{"priority", "/items?beta=1&version=2", "v2"},
+		{"no query", "/items", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := router.MatchRoute(httptest.NewRequest("GET", tt.target, nil))
+			name := ""
+			if route != nil {
+				name = route.Name()
+			}
+			if name != tt.expectedRoute {
+				t.Errorf("Expected route %q, got %q", tt.expectedRoute, name)
+			}
+		})
+	}
+}
+
+func TestRouterLabelSelector(t *testing.T) {
+	pool := backend.NewPool()
+	labels := map[string]map[string]string{
+		"premium-eu": {"tier": "premium", "region": "eu"},
+		"premium-us": {"tier": "premium", "region": "us"},
+		"basic-eu":   {"tier": "basic", "region": "eu"},
+		"unlabeled":  nil,
+	}
+	port := 9000
+	for name, l := range labels {
+		port++
+		b := backend.NewBackend(name, fmt.Sprintf("localhost:%d", port), 1)
+		b.SetLabels(l)
+		pool.Add(b)
+	}
+
+	routes := []config.Route{
+		{Name: "premium-eu", PathPrefix: "/eu/premium", Selector: "tier=premium, region=eu", Priority: 20},
+		{Name: "premium", PathPrefix: "/premium", Selector: "tier=premium", Backends: []string{"premium-us", "unlabeled"}, Priority: 10},
+	}
+
+	router := NewRouter(routes, pool)
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"/eu/premium", []string{"premium-eu"}},
+		{"/premium", []string{"premium-eu", "premium-us", "unlabeled"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			matchedPool := router.Match(httptest.NewRequest("GET", tt.path, nil))
+			if matchedPool.Size() != len(tt.expected) {
+				t.Fatalf("Expected pool size %d, got %d", len(tt.expected), matchedPool.Size())
+			}
+			for _, name := range tt.expected {
+				if matchedPool.GetByName(name) == nil {
+					t.Errorf("Expected backend %s in pool", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterDefaultRoute(t *testing.T) {
+	pool := backend.NewPool()
+	pool.Add(backend.NewBackend("api", "localhost:9001", 1))
+	pool.Add(backend.NewBackend("web", "localhost:9002", 1))
+
+	routes := []config.Route{
+		{Name: "web", Default: true, Backends: []string{"web"}, Priority: 100},
+		{Name: "api", PathPrefix: "/api/", Backends: []string{"api"}},
+	}
+
+	router := NewRouter(routes, pool)
+
+	// The default route is only used when nothing else matches, regardless of priority
+	if route := router.MatchRoute(httptest.NewRequest("GET", "/api/users", nil)); route == nil || route.Name() != "api" {
+		t.Errorf("Expected api route, got %v", route)
+	}
+	if route := router.MatchRoute(httptest.NewRequest("GET", "/index.html", nil)); route == nil || route.Name() != "web" {
+		t.Errorf("Expected default web route, got %v", route)
+	}
+}
+
 func TestMatchHost(t *testing.T) {
 	tests := []struct {
 		name        string
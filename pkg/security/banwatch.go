@@ -0,0 +1,243 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Events counted by a BanWatcher
+const (
+	// BanEventUnauthorized is a request a backend answered with 401 Unauthorized
+	BanEventUnauthorized = "unauthorized"
+
+	// BanEventWAF is a request rejected by the URL filter
+	BanEventWAF = "waf"
+
+	// BanEventTLSHandshakeFailure is a failed TLS handshake
+	BanEventTLSHandshakeFailure = "tls_handshake_failure"
+
+	// BanEventRateLimited is a request or connection rejected by a rate limit
+	BanEventRateLimited = "rate_limited"
+)
+
+// BanWatcherConfig configures a ban watcher
+type BanWatcherConfig struct {
+	// Events are the event names counted towards a ban; others are ignored
+	Events []string
+
+	// Threshold is the number of events within FindTime at which a client is banned
+	Threshold int
+
+	// FindTime is how long an event counts towards a ban
+	FindTime time.Duration
+
+	// BanTime is the duration of a client's first ban
+	BanTime time.Duration
+
+	// MaxBanTime caps the duration of repeated bans
+	MaxBanTime time.Duration
+
+	// Allowlist holds IPs and CIDR ranges that are never banned
+	Allowlist []string
+}
+
+// BanWatcher counts suspicious events per client and temporarily blocks clients that
+// keep causing them, in the manner of fail2ban
+// A client is banned once it causes Threshold events within FindTime; older events
+// no longer count. Bans escalate: each further ban of a client doubles the duration up to MaxBanTime,
+// and a client's ban count drops by one for every MaxBanTime it goes without a ban.
+type BanWatcher struct {
+	config    BanWatcherConfig
+	events    map[string]bool
+	allowlist []*net.IPNet
+	blocklist *IPBlocklist
+
+	mu        sync.Mutex
+	offenders map[string]*offender
+
+	// cleanupInterval is how often clients with nothing left to remember are forgotten
+	cleanupInterval time.Duration
+	stop            chan struct{}
+	stopOnce        sync.Once
+
+	// Statistics
+	totalEvents       atomic.Int64
+	allowlistedEvents atomic.Int64
+	totalBans         atomic.Int64
+}
+
+// offender is the recent events and ban history of a client
+type offender struct {
+	// events are the times of the events within the find time, oldest first
+	events []time.Time
+
+	// bans is the number of bans that still escalate the next one
+	bans        int
+	bannedUntil time.Time
+}
+
+// NewBanWatcher creates a ban watcher that bans clients on the given blocklist
+func NewBanWatcher(config BanWatcherConfig, blocklist *IPBlocklist) (*BanWatcher, error) {
+	w := &BanWatcher{
+		config:          config,
+		events:          make(map[string]bool, len(config.Events)),
+		blocklist:       blocklist,
+		offenders:       make(map[string]*offender),
+		cleanupInterval: 1 * time.Minute,
+		stop:            make(chan struct{}),
+	}
+	for _, event := range config.Events {
+		w.events[event] = true
+	}
+	for _, entry := range config.Allowlist {
+		if ip := net.ParseIP(entry); ip != nil {
+			mask := net.CIDRMask(128, 128)
+			if v4 := ip.To4(); v4 != nil {
+				ip, mask = v4, net.CIDRMask(32, 32)
+			}
+			w.allowlist = append(w.allowlist, &net.IPNet{IP: ip, Mask: mask})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry %s: %w", entry, err)
+		}
+		w.allowlist = append(w.allowlist, ipNet)
+	}
+
+	// Start cleanup goroutine
+	go w.cleanup()
+
+	return w, nil
+}
+
+// Record counts an event caused by a client and bans it if it reaches the threshold,
+// returning true if it was banned
+func (w *BanWatcher) Record(ip, event string) bool {
+	return w.recordAt(ip, event, time.Now())
+}
+
+// recordAt records an event that happened at now
+func (w *BanWatcher) recordAt(ip, event string, now time.Time) bool {
+	if !w.events[event] {
+		return false
+	}
+	w.totalEvents.Add(1)
+	if w.isAllowlisted(ip) {
+		w.allowlistedEvents.Add(1)
+		return false
+	}
+
+	w.mu.Lock()
+	o, exists := w.offenders[ip]
+	if !exists {
+		o = &offender{}
+		w.offenders[ip] = o
+	}
+	if now.Before(o.bannedUntil) {
+		// Events of a banned client, e.g. raised before the blocklist is checked, do
+		// not count towards its next ban
+		w.mu.Unlock()
+		return false
+	}
+	w.decay(o, now)
+	o.events = append(o.events, now)
+	if len(o.events) < w.config.Threshold {
+		w.mu.Unlock()
+		return false
+	}
+
+	duration := w.config.BanTime
+	for i := 0; i < o.bans && duration < w.config.MaxBanTime; i++ {
+		duration *= 2
+	}
+	duration = min(duration, w.config.MaxBanTime)
+	o.events = nil
+	o.bans++
+	o.bannedUntil = now.Add(duration)
+	w.mu.Unlock()
+
+	w.totalBans.Add(1)
+	w.blocklist.Block(ip, duration)
+	return true
+}
+
+// decay drops an offender's events older than the find time and lowers its ban count
+// for the time it has gone without a ban
+func (w *BanWatcher) decay(o *offender, now time.Time) {
+	cutoff := now.Add(-w.config.FindTime)
+	expired := 0
+	for expired < len(o.events) && !o.events[expired].After(cutoff) {
+		expired++
+	}
+	o.events = o.events[expired:]
+
+	if o.bans > 0 && now.After(o.bannedUntil) {
+		forgiven := int(now.Sub(o.bannedUntil) / w.config.MaxBanTime)
+		if forgiven >= o.bans {
+			o.bans = 0
+		} else if forgiven > 0 {
+			o.bans -= forgiven
+			o.bannedUntil = o.bannedUntil.Add(time.Duration(forgiven) * w.config.MaxBanTime)
+		}
+	}
+}
+
+// isAllowlisted reports whether an IP is never banned
+func (w *BanWatcher) isAllowlisted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range w.allowlist {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops forgetting clients in the background
+// Events are still recorded after Stop; it is safe to call more than once.
+func (w *BanWatcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// cleanup periodically forgets clients without recent events or bans to escalate
+func (w *BanWatcher) cleanup() {
+	ticker := time.NewTicker(w.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case now := <-ticker.C:
+			w.mu.Lock()
+			for ip, o := range w.offenders {
+				w.decay(o, now)
+				if len(o.events) == 0 && o.bans == 0 {
+					delete(w.offenders, ip)
+				}
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// Stats returns ban watcher statistics
+func (w *BanWatcher) Stats() map[string]interface{} {
+	w.mu.Lock()
+	tracked := len(w.offenders)
+	w.mu.Unlock()
+
+	return map[string]interface{}{
+		"total_events":       w.totalEvents.Load(),
+		"allowlisted_events": w.allowlistedEvents.Load(),
+		"total_bans":         w.totalBans.Load(),
+		"tracked_ips":        tracked,
+	}
+}
@@ -0,0 +1,124 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestBanWatcher(t *testing.T, bl *IPBlocklist) *BanWatcher {
+	t.Helper()
+	w, err := NewBanWatcher(BanWatcherConfig{
+		Events:     []string{BanEventUnauthorized, BanEventWAF},
+		Threshold:  3,
+		FindTime:   time.Minute,
+		BanTime:    time.Minute,
+		MaxBanTime: 4 * time.Minute,
+		Allowlist:  []string{"10.0.0.0/8", "2001:db8::1"},
+	}, bl)
+	if err != nil {
+		t.Fatalf("Failed to create ban watcher: %v", err)
+	}
+	return w
+}
+
+func TestBanWatcherThreshold(t *testing.T) {
+	bl := NewIPBlocklist()
+	w := newTestBanWatcher(t, bl)
+	now := time.Now()
+	ip := "203.0.113.1"
+
+	// Events that are not watched do not count
+	for i := 0; i < 5; i++ {
+		if w.recordAt(ip, BanEventRateLimited, now) {
+			t.Fatal("Expected unwatched events to be ignored")
+		}
+	}
+
+	// Events older than the find time no longer count
+	w.recordAt(ip, BanEventWAF, now)
+	w.recordAt(ip, BanEventWAF, now.Add(30*time.Second))
+	if w.recordAt(ip, BanEventUnauthorized, now.Add(time.Minute)) {
+		t.Error("Expected expired events not to count towards a ban")
+	}
+	if !w.recordAt(ip, BanEventUnauthorized, now.Add(time.Minute)) {
+		t.Error("Expected the client to be banned at the threshold")
+	}
+	if !bl.IsBlocked(ip) {
+		t.Error("Expected the banned client to be blocked")
+	}
+
+	// Allowlisted clients are never banned
+	for _, allowed := range []string{"10.1.2.3", "2001:db8::1"} {
+		for i := 0; i < 5; i++ {
+			if w.recordAt(allowed, BanEventWAF, now) {
+				t.Errorf("Expected allowlisted client %s not to be banned", allowed)
+			}
+		}
+	}
+
+	stats := w.Stats()
+	if stats["total_bans"] != int64(1) || stats["allowlisted_events"] != int64(10) {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestBanWatcherEscalation(t *testing.T) {
+	bl := NewIPBlocklist()
+	w := newTestBanWatcher(t, bl)
+	now := time.Now()
+	ip := "203.0.113.2"
+
+	ban := func(at time.Time) time.Duration {
+		t.Helper()
+		for i := 0; i < 3; i++ {
+			w.recordAt(ip, BanEventWAF, at)
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.offenders[ip].bannedUntil.Sub(at)
+	}
+
+	// Each ban doubles the duration up to the maximum
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+	for i, d := range want {
+		if got := ban(now); got != d {
+			t.Errorf("Expected ban %d to last %s, got %s", i+1, d, got)
+		}
+		// Events while banned do not count towards the next ban
+		if w.recordAt(ip, BanEventWAF, now.Add(d/2)) {
+			t.Error("Expected events of a banned client to be ignored")
+		}
+		now = now.Add(d)
+	}
+
+	// Staying clean for the maximum ban time forgives one ban
+	if got := ban(now.Add(4 * time.Minute)); got != 4*time.Minute {
+		t.Errorf("Expected a ban after one forgiven ban to last 4m, got %s", got)
+	}
+	now = now.Add(8 * time.Minute)
+	if got := ban(now.Add(time.Hour)); got != time.Minute {
+		t.Errorf("Expected a ban after a long clean period to last 1m, got %s", got)
+	}
+}
+
+func TestBanWatcherStop(t *testing.T) {
+	bl := NewIPBlocklist()
+	w := newTestBanWatcher(t, bl)
+
+	w.Stop()
+	w.Stop()
+
+	select {
+	case <-w.stop:
+	default:
+		t.Fatal("Expected the cleanup to be stopped")
+	}
+
+	// Events are still recorded
+	for i := 0; i < 3; i++ {
+		w.Record("203.0.113.1", BanEventWAF)
+	}
+	if !bl.IsBlocked("203.0.113.1") {
+		t.Error("Expected the client to be banned after Stop")
+	}
+}
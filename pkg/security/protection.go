@@ -2,11 +2,14 @@ package security
 
 import (
 	"fmt"
-	"log"
 	"net"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
 )
 
 // ProtectionConfig configures security protections
@@ -17,6 +20,9 @@ type ProtectionConfig struct {
 	// MaxConnectionRate limits new connections per second per IP
 	MaxConnectionRate float64
 
+	// MaxConcurrentRequestsPerIP limits in-flight requests per IP (0 = unlimited)
+	MaxConcurrentRequestsPerIP int
+
 	// ReadTimeout for reading request headers (Slowloris protection)
 	ReadTimeout time.Duration
 
@@ -31,6 +37,9 @@ type ProtectionConfig struct {
 
 	// ConnectionTimeout limits how long a connection can be open
 	ConnectionTimeout time.Duration
+
+	// Logger receives rejections and blocklist changes (nil discards them)
+	Logger *logging.Logger
 }
 
 // DefaultProtectionConfig returns a default protection configuration
@@ -96,7 +105,8 @@ func (cg *ConnectionGuard) AllowConnection(ip string) bool {
 	// Check connection rate limit
 	if !cg.connectionRateLimiter.Allow(ip) {
 		cg.rejectedConnections.Add(1)
-		log.Printf("Connection rate limit exceeded for IP: %s", ip)
+		metrics.RecordConnectionGuardDecision("rate_limited")
+		cg.config.Logger.Warn("Connection rate limit exceeded", logging.String("ip", ip))
 		return false
 	}
 
@@ -107,8 +117,9 @@ func (cg *ConnectionGuard) AllowConnection(ip string) bool {
 	if ipConns, exists := cg.connectionsPerIP[ip]; exists {
 		if ipConns.count >= cg.config.MaxConnectionsPerIP {
 			cg.rejectedConnections.Add(1)
-			log.Printf("Max connections exceeded for IP: %s (current: %d, max: %d)",
-				ip, ipConns.count, cg.config.MaxConnectionsPerIP)
+			metrics.RecordConnectionGuardDecision("too_many_connections")
+			cg.config.Logger.Warn("Max connections exceeded",
+				logging.String("ip", ip), logging.Int("current", ipConns.count), logging.Int("max", cg.config.MaxConnectionsPerIP))
 			return false
 		}
 		ipConns.count++
@@ -118,9 +129,11 @@ func (cg *ConnectionGuard) AllowConnection(ip string) bool {
 			count:        1,
 			lastActivity: time.Now(),
 		}
+		metrics.SetConnectionGuardTrackedIPs(len(cg.connectionsPerIP))
 	}
 
-	cg.activeConnections.Add(1)
+	metrics.RecordConnectionGuardDecision("allowed")
+	metrics.SetConnectionGuardActive(cg.activeConnections.Add(1))
 	return true
 }
 
@@ -134,10 +147,11 @@ func (cg *ConnectionGuard) ReleaseConnection(ip string) {
 		ipConns.lastActivity = time.Now()
 		if ipConns.count <= 0 {
 			delete(cg.connectionsPerIP, ip)
+			metrics.SetConnectionGuardTrackedIPs(len(cg.connectionsPerIP))
 		}
 	}
 
-	cg.activeConnections.Add(-1)
+	metrics.SetConnectionGuardActive(cg.activeConnections.Add(-1))
 }
 
 // cleanup periodically removes old IP tracking entries
@@ -154,6 +168,7 @@ func (cg *ConnectionGuard) cleanup() {
 				delete(cg.connectionsPerIP, ip)
 			}
 		}
+		metrics.SetConnectionGuardTrackedIPs(len(cg.connectionsPerIP))
 		cg.mu.Unlock()
 	}
 }
@@ -163,7 +178,7 @@ func (cg *ConnectionGuard) cleanup() {
 func (cg *ConnectionGuard) DetectSlowloris(conn net.Conn, readDeadline time.Time) bool {
 	// Set read deadline to detect slow readers
 	if err := conn.SetReadDeadline(readDeadline); err != nil {
-		log.Printf("Failed to set read deadline: %v", err)
+		cg.config.Logger.Error("Failed to set read deadline", logging.Err(err))
 		return false
 	}
 
@@ -196,6 +211,7 @@ func (cg *ConnectionGuard) Stats() map[string]interface{} {
 type RequestSizeGuard struct {
 	maxRequestSize int64
 	maxHeaderSize  int64
+	logger         *logging.Logger
 
 	totalRequests    atomic.Int64
 	rejectedRequests atomic.Int64
@@ -215,7 +231,7 @@ func (g *RequestSizeGuard) CheckRequestSize(size int64) bool {
 
 	if size > g.maxRequestSize {
 		g.rejectedRequests.Add(1)
-		log.Printf("Request size exceeded limit: %d > %d", size, g.maxRequestSize)
+		g.logger.Warn("Request size exceeded limit", logging.Int64("size", size), logging.Int64("max", g.maxRequestSize))
 		return false
 	}
 
@@ -225,7 +241,7 @@ func (g *RequestSizeGuard) CheckRequestSize(size int64) bool {
 // CheckHeaderSize checks if a header size is within limits
 func (g *RequestSizeGuard) CheckHeaderSize(size int64) bool {
 	if size > g.maxHeaderSize {
-		log.Printf("Header size exceeded limit: %d > %d", size, g.maxHeaderSize)
+		g.logger.Warn("Header size exceeded limit", logging.Int64("size", size), logging.Int64("max", g.maxHeaderSize))
 		return false
 	}
 
@@ -242,9 +258,78 @@ func (g *RequestSizeGuard) Stats() map[string]interface{} {
 	}
 }
 
+// RequestConcurrencyGuard limits the requests each IP has in flight at once
+// Unlike the connection limit, it also contains clients that multiplex many
+// requests over few HTTP/2 connections.
+type RequestConcurrencyGuard struct {
+	maxPerIP int
+
+	mu       sync.Mutex
+	inFlight map[string]int
+
+	// Statistics
+	rejectedRequests atomic.Int64
+}
+
+// NewRequestConcurrencyGuard creates a guard allowing maxPerIP in-flight requests per IP
+func NewRequestConcurrencyGuard(maxPerIP int) *RequestConcurrencyGuard {
+	return &RequestConcurrencyGuard{
+		maxPerIP: maxPerIP,
+		inFlight: make(map[string]int),
+	}
+}
+
+// Acquire reserves an in-flight request slot for the IP
+// It returns false if the IP is at its limit; otherwise Release must be called when
+// the request completes.
+func (g *RequestConcurrencyGuard) Acquire(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.inFlight[ip] >= g.maxPerIP {
+		g.rejectedRequests.Add(1)
+		return false
+	}
+	g.inFlight[ip]++
+	return true
+}
+
+// Release frees an in-flight request slot of the IP
+func (g *RequestConcurrencyGuard) Release(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.inFlight[ip] <= 1 {
+		delete(g.inFlight, ip)
+		return
+	}
+	g.inFlight[ip]--
+}
+
+// InFlight returns the number of in-flight requests of the IP
+func (g *RequestConcurrencyGuard) InFlight(ip string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.inFlight[ip]
+}
+
+// Stats returns request concurrency guard statistics
+func (g *RequestConcurrencyGuard) Stats() map[string]interface{} {
+	g.mu.Lock()
+	trackedIPs := len(g.inFlight)
+	g.mu.Unlock()
+
+	return map[string]interface{}{
+		"rejected_requests":              g.rejectedRequests.Load(),
+		"tracked_ips":                    trackedIPs,
+		"max_concurrent_requests_per_ip": g.maxPerIP,
+	}
+}
+
 // IPBlocklist manages a blocklist of IP addresses
 type IPBlocklist struct {
-	mu sync.RWMutex
+	mu     sync.RWMutex
+	logger *logging.Logger
 
 	// blocked maps IP addresses to block expiry time
 	blocked map[string]time.Time
@@ -252,6 +337,9 @@ type IPBlocklist struct {
 	// Permanent blocks (never expire)
 	permanent map[string]bool
 
+	// Permanently blocked CIDR ranges
+	cidrs []*net.IPNet
+
 	// Statistics
 	totalBlocks   atomic.Int64
 	activeBlocks  atomic.Int64
@@ -276,11 +364,13 @@ func (bl *IPBlocklist) Block(ip string, duration time.Duration) {
 	bl.mu.Lock()
 	defer bl.mu.Unlock()
 
+	if _, exists := bl.blocked[ip]; !exists {
+		bl.activeBlocks.Add(1)
+	}
 	bl.blocked[ip] = time.Now().Add(duration)
 	bl.totalBlocks.Add(1)
-	bl.activeBlocks.Add(1)
 
-	log.Printf("Blocked IP %s for %s", ip, duration)
+	bl.logger.Info("Blocked IP", logging.String("ip", ip), logging.Duration("duration", duration))
 }
 
 // BlockPermanent permanently blocks an IP address
@@ -288,29 +378,119 @@ func (bl *IPBlocklist) BlockPermanent(ip string) {
 	bl.mu.Lock()
 	defer bl.mu.Unlock()
 
+	if !bl.permanent[ip] {
+		bl.activeBlocks.Add(1)
+	}
 	bl.permanent[ip] = true
 	bl.totalBlocks.Add(1)
+
+	bl.logger.Info("Permanently blocked IP", logging.String("ip", ip))
+}
+
+// BlockCIDR permanently blocks a CIDR range
+func (bl *IPBlocklist) BlockCIDR(cidr string) error {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR %s: %w", cidr, err)
+	}
+
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	for _, blocked := range bl.cidrs {
+		if blocked.String() == ipNet.String() {
+			return nil
+		}
+	}
+	bl.cidrs = append(bl.cidrs, ipNet)
+	bl.totalBlocks.Add(1)
 	bl.activeBlocks.Add(1)
 
-	log.Printf("Permanently blocked IP %s", ip)
+	bl.logger.Info("Permanently blocked CIDR", logging.String("cidr", ipNet.String()))
+	return nil
 }
 
-// Unblock removes an IP from the blocklist
-func (bl *IPBlocklist) Unblock(ip string) {
+// Unblock removes an IP from the blocklist, reporting whether it was blocked
+func (bl *IPBlocklist) Unblock(ip string) bool {
 	bl.mu.Lock()
 	defer bl.mu.Unlock()
 
+	found := false
 	if _, exists := bl.blocked[ip]; exists {
 		delete(bl.blocked, ip)
 		bl.activeBlocks.Add(-1)
+		found = true
 	}
 
 	if _, exists := bl.permanent[ip]; exists {
 		delete(bl.permanent, ip)
 		bl.activeBlocks.Add(-1)
+		found = true
 	}
 
-	log.Printf("Unblocked IP %s", ip)
+	bl.logger.Info("Unblocked IP", logging.String("ip", ip))
+	return found
+}
+
+// UnblockCIDR removes a CIDR range from the blocklist, reporting whether it was blocked
+func (bl *IPBlocklist) UnblockCIDR(cidr string) (bool, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false, fmt.Errorf("invalid CIDR %s: %w", cidr, err)
+	}
+
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	for i, blocked := range bl.cidrs {
+		if blocked.String() == ipNet.String() {
+			bl.cidrs = append(bl.cidrs[:i], bl.cidrs[i+1:]...)
+			bl.activeBlocks.Add(-1)
+			bl.logger.Info("Unblocked CIDR", logging.String("cidr", ipNet.String()))
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// BlockEntry describes an IP address or CIDR range on the blocklist
+type BlockEntry struct {
+	// Target is the blocked IP address or CIDR range
+	Target string `json:"target"`
+
+	// Permanent is true for blocks that never expire
+	Permanent bool `json:"permanent"`
+
+	// ExpiresAt is when a temporary block ends (nil for permanent blocks)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// List returns the active blocks sorted by target; expired temporary blocks are omitted
+// An IP blocked both temporarily and permanently is listed once, as permanent.
+func (bl *IPBlocklist) List() []BlockEntry {
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
+
+	now := time.Now()
+	entries := make([]BlockEntry, 0, len(bl.permanent)+len(bl.blocked)+len(bl.cidrs))
+	for ip := range bl.permanent {
+		entries = append(entries, BlockEntry{Target: ip, Permanent: true})
+	}
+	for ip, expiry := range bl.blocked {
+		if bl.permanent[ip] || !now.Before(expiry) {
+			continue
+		}
+		expiresAt := expiry
+		entries = append(entries, BlockEntry{Target: ip, ExpiresAt: &expiresAt})
+	}
+	for _, ipNet := range bl.cidrs {
+		entries = append(entries, BlockEntry{Target: ipNet.String(), Permanent: true})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Target < entries[j].Target
+	})
+	return entries
 }
 
 // IsBlocked checks if an IP address is blocked
@@ -332,6 +512,18 @@ func (bl *IPBlocklist) IsBlocked(ip string) bool {
 		}
 	}
 
+	// Check blocked CIDR ranges
+	if len(bl.cidrs) > 0 {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			for _, ipNet := range bl.cidrs {
+				if ipNet.Contains(parsed) {
+					bl.blockedRequests.Add(1)
+					return true
+				}
+			}
+		}
+	}
+
 	return false
 }
 
@@ -358,6 +550,7 @@ func (bl *IPBlocklist) Stats() map[string]interface{} {
 	bl.mu.RLock()
 	permanentCount := len(bl.permanent)
 	temporaryCount := len(bl.blocked)
+	cidrCount := len(bl.cidrs)
 	bl.mu.RUnlock()
 
 	return map[string]interface{}{
@@ -366,6 +559,7 @@ func (bl *IPBlocklist) Stats() map[string]interface{} {
 		"blocked_requests":  bl.blockedRequests.Load(),
 		"permanent_blocks":  permanentCount,
 		"temporary_blocks":  temporaryCount,
+		"blocked_cidrs":     cidrCount,
 	}
 }
 
@@ -375,6 +569,15 @@ type SecurityManager struct {
 	requestSizeGuard  *RequestSizeGuard
 	rateLimiter       RateLimiter
 	blocklist         *IPBlocklist
+
+	// concurrencyGuard is nil without a per-IP in-flight request limit
+	concurrencyGuard *RequestConcurrencyGuard
+
+	// tiers are rate limiters replacing rateLimiter for requests of a named tier
+	tiers map[string]RateLimiter
+
+	// banWatcher is nil without reactive banning
+	banWatcher *BanWatcher
 }
 
 // NewSecurityManager creates a new security manager
@@ -383,12 +586,18 @@ func NewSecurityManager(config *ProtectionConfig, rateLimiter RateLimiter) *Secu
 		config = DefaultProtectionConfig()
 	}
 
-	return &SecurityManager{
+	sm := &SecurityManager{
 		connectionGuard:  NewConnectionGuard(config),
 		requestSizeGuard: NewRequestSizeGuard(config.MaxRequestSize, config.MaxHeaderSize),
 		rateLimiter:      rateLimiter,
 		blocklist:        NewIPBlocklist(),
 	}
+	sm.requestSizeGuard.logger = config.Logger
+	sm.blocklist.logger = config.Logger
+	if config.MaxConcurrentRequestsPerIP > 0 {
+		sm.concurrencyGuard = NewRequestConcurrencyGuard(config.MaxConcurrentRequestsPerIP)
+	}
+	return sm
 }
 
 // AllowConnection checks if a connection should be allowed
@@ -400,6 +609,7 @@ func (sm *SecurityManager) AllowConnection(ip string) (bool, string) {
 
 	// Check rate limit
 	if sm.rateLimiter != nil && !sm.rateLimiter.Allow(ip) {
+		sm.RecordEvent(ip, BanEventRateLimited)
 		return false, "Rate limit exceeded"
 	}
 
@@ -411,6 +621,129 @@ func (sm *SecurityManager) AllowConnection(ip string) (bool, string) {
 	return true, ""
 }
 
+// AllowRequest checks if a request should be allowed
+// Unlike AllowConnection, it does not count towards per-IP connection limits
+func (sm *SecurityManager) AllowRequest(ip string) (bool, string) {
+	return sm.AllowRequestInClass(ip, "")
+}
+
+// AllowRequestInClass checks if a request of a priority class should be allowed
+// Each class other than "" and "normal" is rate limited separately, so a client's batch
+// traffic cannot use up the rate limit of its interactive traffic.
+func (sm *SecurityManager) AllowRequestInClass(ip, class string) (bool, string) {
+	return sm.AllowRequestForKey(ip, ip, class)
+}
+
+// AllowRequestForKey checks if a request should be allowed, rate limiting it by key
+// (such as a tenant or API key) instead of by IP
+// The blocklist still applies to the IP.
+func (sm *SecurityManager) AllowRequestForKey(ip, key, class string) (bool, string) {
+	return sm.AllowRequestInTier(ip, key, class, "")
+}
+
+// AllowRequestInTier checks if a request should be allowed, rate limiting it with the
+// limiter of the named tier
+// Requests of an unknown tier ("" included) use the default rate limiter.
+func (sm *SecurityManager) AllowRequestInTier(ip, key, class, tier string) (bool, string) {
+	// Check blocklist first
+	if sm.blocklist.IsBlocked(ip) {
+		return false, "IP is blocked"
+	}
+
+	// Check rate limit
+	limiter := sm.rateLimiter
+	if tierLimiter, ok := sm.tiers[tier]; ok {
+		limiter = tierLimiter
+	}
+	if limiter != nil && !limiter.Allow(classKey(key, class)) {
+		sm.RecordEvent(ip, BanEventRateLimited)
+		return false, "Rate limit exceeded"
+	}
+
+	return true, ""
+}
+
+// classKey returns the rate limit key of a priority class of requests
+func classKey(key, class string) string {
+	if class != "" && class != "normal" {
+		return key + "/" + class
+	}
+	return key
+}
+
+// ResetRateLimit clears the rate limit state of a key (an IP, tenant or API key) in the
+// default and every tier's rate limiter, along with that of the given priority classes,
+// returning false if rate limiting is disabled
+func (sm *SecurityManager) ResetRateLimit(key string, classes ...string) bool {
+	limiters := make([]RateLimiter, 0, len(sm.tiers)+1)
+	if sm.rateLimiter != nil {
+		limiters = append(limiters, sm.rateLimiter)
+	}
+	for _, limiter := range sm.tiers {
+		limiters = append(limiters, limiter)
+	}
+	if len(limiters) == 0 {
+		return false
+	}
+
+	for _, limiter := range limiters {
+		limiter.Reset(key)
+		for _, class := range classes {
+			if k := classKey(key, class); k != key {
+				limiter.Reset(k)
+			}
+		}
+	}
+	return true
+}
+
+// SetRateLimitTier sets the rate limiter of a named tier
+// It must be called before the manager is used.
+func (sm *SecurityManager) SetRateLimitTier(name string, limiter RateLimiter) {
+	if sm.tiers == nil {
+		sm.tiers = make(map[string]RateLimiter)
+	}
+	sm.tiers[name] = limiter
+}
+
+// SetBanWatcher sets the watcher that bans clients causing too many suspicious events
+// It must be called before the manager is used.
+func (sm *SecurityManager) SetBanWatcher(w *BanWatcher) {
+	sm.banWatcher = w
+}
+
+// Stop stops the background work of the manager's ban watcher
+func (sm *SecurityManager) Stop() {
+	if sm.banWatcher != nil {
+		sm.banWatcher.Stop()
+	}
+}
+
+// RecordEvent counts a suspicious event caused by the IP towards banning it
+// Without reactive banning it does nothing.
+func (sm *SecurityManager) RecordEvent(ip, event string) {
+	if sm.banWatcher != nil {
+		sm.banWatcher.Record(ip, event)
+	}
+}
+
+// AcquireRequest reserves an in-flight request slot for the IP, returning false if it
+// already has the maximum number of requests in flight
+// When it returns true, ReleaseRequest must be called once the request completes.
+func (sm *SecurityManager) AcquireRequest(ip string) bool {
+	if sm.concurrencyGuard == nil {
+		return true
+	}
+	return sm.concurrencyGuard.Acquire(ip)
+}
+
+// ReleaseRequest frees an in-flight request slot reserved by AcquireRequest
+func (sm *SecurityManager) ReleaseRequest(ip string) {
+	if sm.concurrencyGuard != nil {
+		sm.concurrencyGuard.Release(ip)
+	}
+}
+
 // ReleaseConnection releases a connection
 func (sm *SecurityManager) ReleaseConnection(ip string) {
 	sm.connectionGuard.ReleaseConnection(ip)
@@ -426,6 +759,11 @@ func (sm *SecurityManager) BlockIP(ip string, duration time.Duration) {
 	sm.blocklist.Block(ip, duration)
 }
 
+// Blocklist returns the IP blocklist
+func (sm *SecurityManager) Blocklist() *IPBlocklist {
+	return sm.blocklist
+}
+
 // Stats returns combined security statistics
 func (sm *SecurityManager) Stats() map[string]interface{} {
 	stats := make(map[string]interface{})
@@ -438,6 +776,22 @@ func (sm *SecurityManager) Stats() map[string]interface{} {
 		stats["rate_limiter"] = sm.rateLimiter.Stats()
 	}
 
+	if len(sm.tiers) > 0 {
+		tiers := make(map[string]interface{}, len(sm.tiers))
+		for name, limiter := range sm.tiers {
+			tiers[name] = limiter.Stats()
+		}
+		stats["rate_limit_tiers"] = tiers
+	}
+
+	if sm.concurrencyGuard != nil {
+		stats["request_concurrency_guard"] = sm.concurrencyGuard.Stats()
+	}
+
+	if sm.banWatcher != nil {
+		stats["auto_ban"] = sm.banWatcher.Stats()
+	}
+
 	return stats
 }
 
@@ -101,6 +101,48 @@ func TestRequestSizeGuard(t *testing.T) {
 	}
 }
 
+func TestRequestConcurrencyGuard(t *testing.T) {
+	guard := NewRequestConcurrencyGuard(2)
+
+	// Should allow up to the limit
+	for i := 0; i < 2; i++ {
+		if !guard.Acquire("192.168.1.1") {
+			t.Errorf("Expected request %d to be allowed", i)
+		}
+	}
+
+	// Should block the next request in flight
+	if guard.Acquire("192.168.1.1") {
+		t.Error("Expected request to be blocked")
+	}
+
+	// Other IPs have their own limit
+	if !guard.Acquire("192.168.1.2") {
+		t.Error("Expected request from another IP to be allowed")
+	}
+
+	// Completing a request frees a slot
+	guard.Release("192.168.1.1")
+	if !guard.Acquire("192.168.1.1") {
+		t.Error("Expected request to be allowed after release")
+	}
+
+	guard.Release("192.168.1.1")
+	guard.Release("192.168.1.1")
+	guard.Release("192.168.1.2")
+	if n := guard.InFlight("192.168.1.1"); n != 0 {
+		t.Errorf("Expected no requests in flight, got %d", n)
+	}
+
+	stats := guard.Stats()
+	if stats["rejected_requests"] != int64(1) {
+		t.Errorf("Expected 1 rejected request, got %v", stats["rejected_requests"])
+	}
+	if stats["tracked_ips"] != 0 {
+		t.Errorf("Expected no tracked IPs, got %v", stats["tracked_ips"])
+	}
+}
+
 func TestIPBlocklist(t *testing.T) {
 	bl := NewIPBlocklist()
 
@@ -156,6 +198,52 @@ func TestIPBlocklistPermanent(t *testing.T) {
 	}
 }
 
+func TestIPBlocklistList(t *testing.T) {
+	bl := NewIPBlocklist()
+	bl.BlockPermanent("192.168.1.1")
+	bl.Block("192.168.1.2", time.Hour)
+	bl.Block("192.168.1.2", time.Hour)
+	bl.Block("192.168.1.3", -time.Second)
+	if err := bl.BlockCIDR("10.0.0.0/8"); err != nil {
+		t.Fatalf("Failed to block CIDR: %v", err)
+	}
+
+	entries := bl.List()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 active blocks, got %+v", entries)
+	}
+	if entries[0].Target != "10.0.0.0/8" || !entries[0].Permanent {
+		t.Errorf("Expected permanent CIDR block, got %+v", entries[0])
+	}
+	if entries[1].Target != "192.168.1.1" || !entries[1].Permanent || entries[1].ExpiresAt != nil {
+		t.Errorf("Expected permanent IP block, got %+v", entries[1])
+	}
+	if entries[2].Target != "192.168.1.2" || entries[2].Permanent || entries[2].ExpiresAt == nil || time.Until(*entries[2].ExpiresAt) < 59*time.Minute {
+		t.Errorf("Expected temporary IP block expiring in an hour, got %+v", entries[2])
+	}
+
+	// Blocking an IP again extends its block instead of adding another
+	if active := bl.Stats()["active_blocks"].(int64); active != 4 {
+		t.Errorf("Expected 4 active blocks counted, got %d", active)
+	}
+
+	if removed, err := bl.UnblockCIDR("10.0.0.0/8"); err != nil || !removed {
+		t.Errorf("Expected CIDR to be unblocked, got %v %v", removed, err)
+	}
+	if bl.IsBlocked("10.1.2.3") {
+		t.Error("Expected CIDR block to be removed")
+	}
+	if removed, _ := bl.UnblockCIDR("172.16.0.0/12"); removed {
+		t.Error("Expected unknown CIDR not to be unblocked")
+	}
+	if _, err := bl.UnblockCIDR("not-a-cidr"); err == nil {
+		t.Error("Expected error for invalid CIDR")
+	}
+	if !bl.Unblock("192.168.1.2") || bl.Unblock("192.168.1.4") {
+		t.Error("Expected Unblock to report whether the IP was blocked")
+	}
+}
+
 func TestSecurityManager(t *testing.T) {
 	cfg := DefaultProtectionConfig()
 	rateLimiter := NewTokenBucket(10.0, 20)
@@ -184,6 +272,145 @@ func TestSecurityManager(t *testing.T) {
 	}
 }
 
+func TestSecurityManagerRateLimitPerClass(t *testing.T) {
+	sm := NewSecurityManager(DefaultProtectionConfig(), NewTokenBucket(0.001, 2))
+
+	ip := "192.168.1.1"
+
+	// Batch traffic uses up its own rate limit
+	for i := 0; i < 2; i++ {
+		if allowed, _ := sm.AllowRequestInClass(ip, "low"); !allowed {
+			t.Errorf("Expected low-priority request %d to be allowed", i)
+		}
+	}
+	if allowed, reason := sm.AllowRequestInClass(ip, "low"); allowed || reason != "Rate limit exceeded" {
+		t.Errorf("Expected low-priority request to be rate limited, got %v %q", allowed, reason)
+	}
+
+	// Interactive traffic of the same client is limited separately
+	if allowed, _ := sm.AllowRequestInClass(ip, "normal"); !allowed {
+		t.Error("Expected normal-priority request to be allowed")
+	}
+	if allowed, _ := sm.AllowRequestInClass(ip, "high"); !allowed {
+		t.Error("Expected high-priority request to be allowed")
+	}
+
+	// Normal requests share the limit of requests without a class
+	if allowed, _ := sm.AllowRequest(ip); !allowed {
+		t.Error("Expected request to be allowed")
+	}
+	if allowed, _ := sm.AllowRequest(ip); allowed {
+		t.Error("Expected request to share the normal-priority rate limit")
+	}
+}
+
+func TestSecurityManagerRateLimitByKey(t *testing.T) {
+	sm := NewSecurityManager(DefaultProtectionConfig(), NewTokenBucket(0.001, 1))
+
+	// Tenants behind the same IP have their own rate limit
+	if allowed, _ := sm.AllowRequestForKey("203.0.113.1", "tenant-a", ""); !allowed {
+		t.Error("Expected request of tenant-a to be allowed")
+	}
+	if allowed, _ := sm.AllowRequestForKey("203.0.113.1", "tenant-b", ""); !allowed {
+		t.Error("Expected request of tenant-b to be allowed")
+	}
+
+	// A tenant's limit applies across IPs
+	if allowed, _ := sm.AllowRequestForKey("203.0.113.2", "tenant-a", ""); allowed {
+		t.Error("Expected tenant-a to be rate limited")
+	}
+
+	// The blocklist still applies to the IP
+	sm.BlockIP("203.0.113.3", time.Hour)
+	if allowed, reason := sm.AllowRequestForKey("203.0.113.3", "tenant-c", ""); allowed || reason != "IP is blocked" {
+		t.Errorf("Expected blocked IP to be rejected, got %v %q", allowed, reason)
+	}
+}
+
+func TestSecurityManagerRateLimitTiers(t *testing.T) {
+	sm := NewSecurityManager(DefaultProtectionConfig(), NewTokenBucket(0.001, 1))
+	sm.SetRateLimitTier("pro", NewTokenBucket(0.001, 3))
+
+	// A pro tenant gets the pro tier's burst
+	for i := 0; i < 3; i++ {
+		if allowed, _ := sm.AllowRequestInTier("203.0.113.1", "tenant-a", "", "pro"); !allowed {
+			t.Errorf("Expected pro request %d to be allowed", i)
+		}
+	}
+	if allowed, _ := sm.AllowRequestInTier("203.0.113.1", "tenant-a", "", "pro"); allowed {
+		t.Error("Expected pro tenant to be rate limited")
+	}
+
+	// Requests without a known tier get the default limit
+	if allowed, _ := sm.AllowRequestInTier("203.0.113.1", "tenant-b", "", "enterprise"); !allowed {
+		t.Error("Expected request of unknown tier to be allowed")
+	}
+	if allowed, _ := sm.AllowRequestInTier("203.0.113.1", "tenant-b", "", ""); allowed {
+		t.Error("Expected request of unknown tier to share the default limit")
+	}
+
+	if _, ok := sm.Stats()["rate_limit_tiers"].(map[string]interface{})["pro"]; !ok {
+		t.Error("Expected stats of the pro tier")
+	}
+}
+
+func TestSecurityManagerResetRateLimit(t *testing.T) {
+	if NewSecurityManager(DefaultProtectionConfig(), nil).ResetRateLimit("tenant-a") {
+		t.Error("Expected reset to report rate limiting disabled")
+	}
+
+	sm := NewSecurityManager(DefaultProtectionConfig(), NewTokenBucket(0.001, 1))
+	sm.SetRateLimitTier("pro", NewTokenBucket(0.001, 1))
+	for _, tc := range []struct{ class, tier string }{{"", ""}, {"low", ""}, {"", "pro"}} {
+		sm.AllowRequestInTier("203.0.113.1", "tenant-a", tc.class, tc.tier)
+		if allowed, _ := sm.AllowRequestInTier("203.0.113.1", "tenant-a", tc.class, tc.tier); allowed {
+			t.Fatalf("Expected tenant-a to be rate limited in class %q tier %q", tc.class, tc.tier)
+		}
+	}
+	sm.AllowRequestForKey("203.0.113.1", "tenant-b", "")
+
+	if !sm.ResetRateLimit("tenant-a", "low", "normal", "high") {
+		t.Fatal("Expected reset to succeed")
+	}
+	for _, tc := range []struct{ class, tier string }{{"", ""}, {"low", ""}, {"", "pro"}} {
+		if allowed, _ := sm.AllowRequestInTier("203.0.113.1", "tenant-a", tc.class, tc.tier); !allowed {
+			t.Errorf("Expected tenant-a to be allowed again in class %q tier %q", tc.class, tc.tier)
+		}
+	}
+
+	// Other keys keep their state
+	if allowed, _ := sm.AllowRequestForKey("203.0.113.1", "tenant-b", ""); allowed {
+		t.Error("Expected tenant-b to stay rate limited")
+	}
+}
+
+func TestSecurityManagerBanWatcher(t *testing.T) {
+	sm := NewSecurityManager(DefaultProtectionConfig(), NewTokenBucket(0.001, 1))
+	w, err := NewBanWatcher(BanWatcherConfig{
+		Events:     []string{BanEventRateLimited},
+		Threshold:  2,
+		FindTime:   time.Minute,
+		BanTime:    time.Minute,
+		MaxBanTime: time.Hour,
+	}, sm.Blocklist())
+	if err != nil {
+		t.Fatalf("Failed to create ban watcher: %v", err)
+	}
+	sm.SetBanWatcher(w)
+
+	// A client that keeps hitting the rate limit is banned
+	ip := "203.0.113.1"
+	for i := 0; i < 3; i++ {
+		sm.AllowRequest(ip)
+	}
+	if allowed, reason := sm.AllowRequest(ip); allowed || reason != "IP is blocked" {
+		t.Errorf("Expected rate limited client to be banned, got %v %q", allowed, reason)
+	}
+	if _, ok := sm.Stats()["auto_ban"]; !ok {
+		t.Error("Expected auto-ban stats")
+	}
+}
+
 func TestGetClientIP(t *testing.T) {
 	tests := []struct {
 		name string
@@ -5,6 +5,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
 )
 
 // RateLimiter defines the interface for rate limiting
@@ -38,6 +40,9 @@ type TokenBucket struct {
 	// bucketTTL is how long to keep inactive buckets
 	bucketTTL time.Duration
 
+	// name labels the limiter's Prometheus metrics ("" = not exported)
+	name string
+
 	// Statistics
 	totalRequests  atomic.Int64
 	allowedCount   atomic.Int64
@@ -69,8 +74,26 @@ func NewTokenBucket(rate float64, capacity int64) *TokenBucket {
 	return tb
 }
 
+// SetName names the limiter, exporting its decisions and active buckets as Prometheus
+// metrics labeled with the name
+// It must be called before the limiter is used.
+func (tb *TokenBucket) SetName(name string) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.name = name
+}
+
 // Allow checks if a request should be allowed for the given key
 func (tb *TokenBucket) Allow(key string) bool {
+	allowed := tb.allow(key)
+	if tb.name != "" {
+		metrics.RecordRateLimitDecision(tb.name, allowed)
+	}
+	return allowed
+}
+
+// allow takes a token from the key's bucket if it has one
+func (tb *TokenBucket) allow(key string) bool {
 	tb.totalRequests.Add(1)
 
 	tb.mu.Lock()
@@ -81,6 +104,7 @@ func (tb *TokenBucket) Allow(key string) bool {
 			lastRefill: time.Now(),
 		}
 		tb.buckets[key] = b
+		tb.reportActiveKeys()
 	}
 	tb.mu.Unlock()
 
@@ -113,6 +137,14 @@ func (tb *TokenBucket) Reset(key string) {
 	defer tb.mu.Unlock()
 
 	delete(tb.buckets, key)
+	tb.reportActiveKeys()
+}
+
+// reportActiveKeys exports the number of buckets of a named limiter; tb.mu must be held
+func (tb *TokenBucket) reportActiveKeys() {
+	if tb.name != "" {
+		metrics.SetRateLimitActiveKeys(tb.name, len(tb.buckets))
+	}
 }
 
 // cleanup periodically removes old buckets
@@ -130,6 +162,7 @@ func (tb *TokenBucket) cleanup() {
 			}
 			b.mu.Unlock()
 		}
+		tb.reportActiveKeys()
 		tb.mu.Unlock()
 	}
 }
@@ -150,7 +183,14 @@ func (tb *TokenBucket) Stats() map[string]interface{} {
 	}
 }
 
+// slidingWindowBuckets is the number of sub-windows a sliding window is divided into
+const slidingWindowBuckets = 10
+
 // SlidingWindow implements a sliding window rate limiter
+// It uses the sliding window counter algorithm: each key counts its requests in
+// fixed sub-windows, and the oldest sub-window, which is only partly inside the
+// window, is weighted by the part still inside. Memory per key and the work per
+// request are constant however many requests the window allows.
 type SlidingWindow struct {
 	mu sync.RWMutex
 
@@ -160,22 +200,34 @@ type SlidingWindow struct {
 	// window is the time window duration
 	window time.Duration
 
+	// bucketSize is the duration of each sub-window
+	bucketSize time.Duration
+
 	// windows maps keys to their request windows
 	windows map[string]*requestWindow
 
 	// cleanupInterval is how often to clean up old windows
 	cleanupInterval time.Duration
 
+	// name labels the limiter's Prometheus metrics ("" = not exported)
+	name string
+
 	// Statistics
 	totalRequests atomic.Int64
 	allowedCount  atomic.Int64
 	blockedCount  atomic.Int64
 }
 
-// requestWindow tracks requests in a sliding time window
+// requestWindow counts requests of a key per sub-window
 type requestWindow struct {
-	requests []time.Time
-	mu       sync.Mutex
+	// counts is a ring of request counts; sub-window n is at counts[n % len(counts)].
+	// It holds one sub-window more than the window, for the partly expired one.
+	counts [slidingWindowBuckets + 1]int64
+
+	// last is the number of the latest sub-window counted in
+	last int64
+
+	mu sync.Mutex
 }
 
 // NewSlidingWindow creates a new sliding window rate limiter
@@ -183,6 +235,7 @@ func NewSlidingWindow(limit int64, window time.Duration) *SlidingWindow {
 	sw := &SlidingWindow{
 		limit:           limit,
 		window:          window,
+		bucketSize:      max(window/slidingWindowBuckets, 1),
 		windows:         make(map[string]*requestWindow),
 		cleanupInterval: 1 * time.Minute,
 	}
@@ -193,38 +246,57 @@ func NewSlidingWindow(limit int64, window time.Duration) *SlidingWindow {
 	return sw
 }
 
+// SetName names the limiter, exporting its decisions and active windows as Prometheus
+// metrics labeled with the name
+// It must be called before the limiter is used.
+func (sw *SlidingWindow) SetName(name string) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.name = name
+}
+
 // Allow checks if a request should be allowed for the given key
 func (sw *SlidingWindow) Allow(key string) bool {
+	allowed := sw.allowAt(key, time.Now())
+	if sw.name != "" {
+		metrics.RecordRateLimitDecision(sw.name, allowed)
+	}
+	return allowed
+}
+
+// allowAt checks if a request at the given time should be allowed for the key
+func (sw *SlidingWindow) allowAt(key string, now time.Time) bool {
 	sw.totalRequests.Add(1)
 
+	bucket := now.UnixNano() / int64(sw.bucketSize)
+
 	sw.mu.Lock()
 	w, exists := sw.windows[key]
 	if !exists {
-		w = &requestWindow{
-			requests: make([]time.Time, 0),
-		}
+		w = &requestWindow{last: bucket}
 		sw.windows[key] = w
+		sw.reportActiveKeys()
 	}
 	sw.mu.Unlock()
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	now := time.Now()
-	cutoff := now.Add(-sw.window)
+	w.advance(bucket)
 
-	// Remove old requests outside the window
-	validRequests := make([]time.Time, 0)
-	for _, t := range w.requests {
-		if t.After(cutoff) {
-			validRequests = append(validRequests, t)
-		}
+	// Requests of the sub-windows inside the window, plus the share of the oldest
+	// sub-window that is still inside it
+	n := int64(len(w.counts))
+	var count float64
+	for i := bucket - n + 2; i <= bucket; i++ {
+		count += float64(w.counts[i%n])
 	}
-	w.requests = validRequests
+	elapsed := float64(now.UnixNano()%int64(sw.bucketSize)) / float64(sw.bucketSize)
+	count += float64(w.counts[(bucket+1)%n]) * (1 - elapsed)
 
 	// Check if we're under the limit
-	if int64(len(w.requests)) < sw.limit {
-		w.requests = append(w.requests, now)
+	if count < float64(sw.limit) {
+		w.counts[bucket%n]++
 		sw.allowedCount.Add(1)
 		return true
 	}
@@ -233,12 +305,38 @@ func (sw *SlidingWindow) Allow(key string) bool {
 	return false
 }
 
+// advance moves the window to the given sub-window, clearing the counts of the
+// sub-windows that expired since the last request
+func (w *requestWindow) advance(bucket int64) {
+	if bucket <= w.last {
+		return
+	}
+
+	n := int64(len(w.counts))
+	if bucket-w.last >= n {
+		w.counts = [slidingWindowBuckets + 1]int64{}
+	} else {
+		for i := w.last + 1; i <= bucket; i++ {
+			w.counts[i%n] = 0
+		}
+	}
+	w.last = bucket
+}
+
 // Reset resets the rate limiter for a specific key
 func (sw *SlidingWindow) Reset(key string) {
 	sw.mu.Lock()
 	defer sw.mu.Unlock()
 
 	delete(sw.windows, key)
+	sw.reportActiveKeys()
+}
+
+// reportActiveKeys exports the number of windows of a named limiter; sw.mu must be held
+func (sw *SlidingWindow) reportActiveKeys() {
+	if sw.name != "" {
+		metrics.SetRateLimitActiveKeys(sw.name, len(sw.windows))
+	}
 }
 
 // cleanup periodically removes old windows
@@ -248,16 +346,17 @@ func (sw *SlidingWindow) cleanup() {
 
 	for range ticker.C {
 		sw.mu.Lock()
-		now := time.Now()
-		cutoff := now.Add(-sw.window * 2) // Keep for 2x window duration
+		// Keep for 2x window duration
+		cutoff := time.Now().Add(-sw.window*2).UnixNano() / int64(sw.bucketSize)
 
 		for key, w := range sw.windows {
 			w.mu.Lock()
-			if len(w.requests) == 0 || (len(w.requests) > 0 && w.requests[len(w.requests)-1].Before(cutoff)) {
+			if w.last < cutoff {
 				delete(sw.windows, key)
 			}
 			w.mu.Unlock()
 		}
+		sw.reportActiveKeys()
 		sw.mu.Unlock()
 	}
 }
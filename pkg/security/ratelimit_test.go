@@ -3,6 +3,8 @@ package security
 import (
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func TestTokenBucket(t *testing.T) {
@@ -142,6 +144,87 @@ func TestSlidingWindowReset(t *testing.T) {
 	}
 }
 
+func TestSlidingWindowCounter(t *testing.T) {
+	// 10 requests per second, counted in 100ms sub-windows
+	sw := NewSlidingWindow(10, time.Second)
+	start := time.Unix(1000, 0)
+
+	// Fill the window at its start
+	for i := 0; i < 10; i++ {
+		if !sw.allowAt("test-key", start) {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+	if sw.allowAt("test-key", start.Add(900*time.Millisecond)) {
+		t.Error("Expected request to be blocked while the window is full")
+	}
+
+	// Halfway through the next sub-window, half of the first one has slid out
+	at := start.Add(1050 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		if !sw.allowAt("test-key", at) {
+			t.Errorf("Expected request %d to be allowed once the window slid", i)
+		}
+	}
+	if sw.allowAt("test-key", at) {
+		t.Error("Expected request to be blocked by the weighted count")
+	}
+
+	// Once the whole window has passed, every count has expired
+	if !sw.allowAt("test-key", start.Add(5*time.Second)) {
+		t.Error("Expected request to be allowed after the window passed")
+	}
+
+	// Memory per key does not grow with the number of requests
+	if n := len(sw.windows["test-key"].counts); n != slidingWindowBuckets+1 {
+		t.Errorf("Expected %d counters, got %d", slidingWindowBuckets+1, n)
+	}
+}
+
+func TestRateLimiterMetrics(t *testing.T) {
+	tb := NewTokenBucket(0.001, 1)
+	tb.SetName("metrics-test")
+
+	tb.Allow("10.0.0.1")
+	tb.Allow("10.0.0.1")
+	tb.Allow("10.0.0.2")
+
+	// Decisions and active buckets are labeled by limiter, never by client
+	want := map[string]float64{
+		"balance_rate_limit_decisions_total/allowed": 2,
+		"balance_rate_limit_decisions_total/blocked": 1,
+		"balance_rate_limit_active_keys/":            2,
+	}
+	got := make(map[string]float64)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["limiter"] != "metrics-test" {
+				continue
+			}
+			value := m.GetGauge().GetValue()
+			if m.GetCounter() != nil {
+				value = m.GetCounter().GetValue()
+			}
+			got[family.GetName()+"/"+labels["result"]] = value
+		}
+	}
+
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("Expected %s = %v, got %v", name, value, got[name])
+		}
+	}
+}
+
 func TestPerIPRateLimiter(t *testing.T) {
 	tb := NewTokenBucket(10.0, 20)
 	limiter := NewPerIPRateLimiter(tb)
@@ -0,0 +1,138 @@
+package security
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// maxDecodeRounds bounds how often a path is percent-decoded while normalizing, which
+// uncovers double and triple encoded sequences such as %252e%252e
+const maxDecodeRounds = 3
+
+// URLFilter rejects request URLs that try to escape the path hierarchy, carry null
+// bytes or match a deny-list of patterns
+// Checks run on the path as a backend or file system might interpret it: fully
+// percent-decoded, with backslashes as separators, so encoded variants such as
+// %2e%2e%2f or ..%5c are caught as well.
+type URLFilter struct {
+	denyPatterns []*regexp.Regexp
+
+	// Statistics
+	totalChecked   atomic.Int64
+	traversals     atomic.Int64
+	nullBytes      atomic.Int64
+	deniedPatterns atomic.Int64
+}
+
+// URLFilterResult is the outcome of checking a URL
+type URLFilterResult int
+
+const (
+	// URLAllowed URLs may be proxied
+	URLAllowed URLFilterResult = iota
+
+	// URLMalformed URLs contain traversal sequences or null bytes
+	URLMalformed
+
+	// URLDenied URLs match a deny-list pattern
+	URLDenied
+)
+
+// NewURLFilter creates a URL filter with the given deny-list of regular expressions
+func NewURLFilter(denyPatterns []string) (*URLFilter, error) {
+	f := &URLFilter{}
+	for _, pattern := range denyPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deny pattern %q: %w", pattern, err)
+		}
+		f.denyPatterns = append(f.denyPatterns, re)
+	}
+	return f, nil
+}
+
+// Check checks a request URL
+// Deny patterns are matched against the normalized path followed by the decoded
+// query string, if any.
+func (f *URLFilter) Check(u *url.URL) URLFilterResult {
+	f.totalChecked.Add(1)
+
+	decoded := decodePath(u.EscapedPath())
+	if strings.ContainsRune(decoded, 0) || strings.Contains(u.RawQuery, "%00") {
+		f.nullBytes.Add(1)
+		return URLMalformed
+	}
+	if hasDotSegment(decoded) {
+		f.traversals.Add(1)
+		return URLMalformed
+	}
+
+	if len(f.denyPatterns) == 0 {
+		return URLAllowed
+	}
+
+	target := normalizePath(u.EscapedPath())
+	if u.RawQuery != "" {
+		query, err := url.QueryUnescape(u.RawQuery)
+		if err != nil {
+			query = u.RawQuery
+		}
+		target += "?" + query
+	}
+	for _, re := range f.denyPatterns {
+		if re.MatchString(target) {
+			f.deniedPatterns.Add(1)
+			return URLDenied
+		}
+	}
+	return URLAllowed
+}
+
+// normalizePath returns an escaped URL path as it is interpreted: fully percent-decoded,
+// with backslashes turned into slashes and repeated slashes and dot segments removed
+func normalizePath(escapedPath string) string {
+	p := path.Clean("/" + decodePath(escapedPath))
+	if p == "/" || !strings.HasSuffix(escapedPath, "/") {
+		return p
+	}
+	return p + "/"
+}
+
+// decodePath percent-decodes an escaped path until it no longer changes (up to
+// maxDecodeRounds times) and turns backslashes into slashes
+func decodePath(escapedPath string) string {
+	p := escapedPath
+	for range maxDecodeRounds {
+		decoded, err := url.PathUnescape(p)
+		if err != nil || decoded == p {
+			break
+		}
+		p = decoded
+	}
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// hasDotSegment reports whether a decoded path has a "." or ".." segment
+func hasDotSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "." || segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns URL filter statistics
+func (f *URLFilter) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"total_checked":   f.totalChecked.Load(),
+		"traversals":      f.traversals.Load(),
+		"null_bytes":      f.nullBytes.Load(),
+		"denied_patterns": f.deniedPatterns.Load(),
+		"deny_patterns":   len(f.denyPatterns),
+	}
+}
@@ -0,0 +1,55 @@
+package security
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestURLFilter(t *testing.T) {
+	f, err := NewURLFilter([]string{`^/admin(/|$)`, `(?i)\.(git|env)(/|$)`, `[?&]debug=`})
+	if err != nil {
+		t.Fatalf("Failed to create URL filter: %v", err)
+	}
+
+	tests := []struct {
+		target string
+		want   URLFilterResult
+	}{
+		{"/", URLAllowed},
+		{"/api/users?id=1", URLAllowed},
+		{"/files/report..pdf", URLAllowed},
+		{"/static/../etc/passwd", URLMalformed},
+		{"/static/%2e%2e/etc/passwd", URLMalformed},
+		{"/static/%2E%2E%2Fetc/passwd", URLMalformed},
+		{"/static/%252e%252e/etc/passwd", URLMalformed},
+		{"/static/..%5cwindows", URLMalformed},
+		{"/static/./index.html", URLMalformed},
+		{"/file%00.jpg", URLMalformed},
+		{"/search?q=%00", URLMalformed},
+		{"/admin", URLDenied},
+		{"/admin/users", URLDenied},
+		{"//admin/users", URLDenied},
+		{"/%61dmin/users", URLDenied},
+		{"/administrators", URLAllowed},
+		{"/app/.ENV", URLDenied},
+		{"/api?debug=1", URLDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			u, err := url.ParseRequestURI(tt.target)
+			if err != nil {
+				t.Fatalf("Failed to parse %s: %v", tt.target, err)
+			}
+			if got := f.Check(u); got != tt.want {
+				t.Errorf("Check(%s) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLFilterInvalidPattern(t *testing.T) {
+	if _, err := NewURLFilter([]string{"("}); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,89 @@
+// Package systemd implements the parts of the sd_notify protocol used to run the proxy
+// as a Type=notify service: readiness, stopping and watchdog notifications
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Ready tells systemd the service has started and its listeners are bound
+	Ready = "READY=1"
+
+	// Reloading tells systemd the service is reloading its configuration; Ready is
+	// sent again once it is done
+	Reloading = "RELOADING=1"
+
+	// Stopping tells systemd the service is shutting down
+	Stopping = "STOPPING=1"
+
+	// Watchdog is the keepalive that resets the watchdog timer
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends a state such as Ready to systemd through $NOTIFY_SOCKET
+// It reports false without an error when the process is not run by systemd with
+// Type=notify (or NotifyAccess allowing it).
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// Names starting with @ are in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns how often keepalives should be sent: half of the
+// WatchdogSec systemd set for this process, or 0 if the watchdog is disabled
+func WatchdogInterval() (time.Duration, error) {
+	value := os.Getenv("WATCHDOG_USEC")
+	if value == "" {
+		return 0, nil
+	}
+	// The watchdog may be meant for another process, e.g. a wrapper script
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+
+	usec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC: %s", value)
+	}
+	return time.Duration(usec) * time.Microsecond / 2, nil
+}
+
+// RunWatchdog sends a keepalive every interval until ctx is done
+// Before each keepalive alive is called, if set; while it blocks or returns false no
+// keepalives are sent, so systemd restarts the service once WatchdogSec passes.
+func RunWatchdog(ctx context.Context, interval time.Duration, alive func() bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if alive == nil || alive() {
+			Notify(Watchdog)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listenNotify creates a notify socket and points $NOTIFY_SOCKET at it
+func listenNotify(t *testing.T) *net.UnixConn {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", socket)
+	return conn
+}
+
+// readNotify reads the next notification
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify(Ready); sent || err != nil {
+		t.Fatalf("expected nothing to be sent outside systemd, got %v (%v)", sent, err)
+	}
+
+	conn := listenNotify(t)
+	if sent, err := Notify(Ready); !sent || err != nil {
+		t.Fatalf("expected the notification to be sent, got %v (%v)", sent, err)
+	}
+	if state := readNotify(t, conn); state != Ready {
+		t.Errorf("expected %q, got %q", Ready, state)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", "/nonexistent/notify")
+	if _, err := Notify(Stopping); err == nil {
+		t.Error("expected an error for a missing socket")
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		usec      string
+		pid       string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{usec: "", expected: 0},
+		{usec: "10000000", expected: 5 * time.Second},
+		{usec: "10000000", pid: strconv.Itoa(os.Getpid()), expected: 5 * time.Second},
+		{usec: "10000000", pid: "1", expected: 0},
+		{usec: "soon", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("WATCHDOG_USEC", tt.usec)
+		t.Setenv("WATCHDOG_PID", tt.pid)
+		interval, err := WatchdogInterval()
+		if (err != nil) != tt.expectErr || interval != tt.expected {
+			t.Errorf("WATCHDOG_USEC=%q WATCHDOG_PID=%q: expected %v, got %v (%v)", tt.usec, tt.pid, tt.expected, interval, err)
+		}
+	}
+}
+
+func TestRunWatchdog(t *testing.T) {
+	conn := listenNotify(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	alive := make(chan bool, 3)
+	alive <- true
+	alive <- false
+	alive <- true
+	done := make(chan struct{})
+	go func() {
+		RunWatchdog(ctx, 20*time.Millisecond, func() bool { return <-alive })
+		close(done)
+	}()
+
+	// The keepalive skipped while not alive is not sent
+	for i := 0; i < 2; i++ {
+		if state := readNotify(t, conn); state != Watchdog {
+			t.Errorf("expected %q, got %q", Watchdog, state)
+		}
+	}
+	cancel()
+	close(alive)
+	<-done
+}
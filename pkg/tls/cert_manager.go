@@ -3,6 +3,7 @@ package tls
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -10,8 +11,11 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
 )
 
 // Certificate represents a TLS certificate with its private key
@@ -32,6 +36,20 @@ type Certificate struct {
 	NotAfter time.Time
 }
 
+// Fingerprint returns the SHA-256 fingerprint of the certificate as colon-separated hex
+// bytes, in the format printed by openssl x509 -fingerprint -sha256
+func (c *Certificate) Fingerprint() string {
+	if c.Cert == nil {
+		return ""
+	}
+	sum := sha256.Sum256(c.Cert.Raw)
+	hexBytes := make([]string, len(sum))
+	for i, b := range sum {
+		hexBytes[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hexBytes, ":")
+}
+
 // CertificateManager manages TLS certificates
 type CertificateManager struct {
 	mu sync.RWMutex
@@ -42,12 +60,21 @@ type CertificateManager struct {
 
 	// defaultCert is used when no matching certificate is found
 	defaultCert *Certificate
+
+	// expiryWarned holds the highest expiry warning level logged per certificate
+	expiryWarned map[*Certificate]int
+	expiryMu     sync.Mutex
+
+	// logger receives expiry warnings and certificate refreshes
+	logger *logging.Logger
 }
 
-// NewCertificateManager creates a new certificate manager
-func NewCertificateManager() *CertificateManager {
+// NewCertificateManager creates a new certificate manager logging to logger
+func NewCertificateManager(logger *logging.Logger) *CertificateManager {
 	return &CertificateManager{
 		certificates: make(map[string]*Certificate),
+		expiryWarned: make(map[*Certificate]int),
+		logger:       logger,
 	}
 }
 
@@ -59,6 +86,21 @@ func (cm *CertificateManager) LoadCertificate(certFile, keyFile string) (*Certif
 		return nil, fmt.Errorf("failed to load certificate: %w", err)
 	}
 
+	return newCertificate(tlsCert)
+}
+
+// ParseCertificatePEM parses a PEM encoded certificate chain and private key
+func ParseCertificatePEM(certPEM, keyPEM []byte) (*Certificate, error) {
+	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	return newCertificate(tlsCert)
+}
+
+// newCertificate wraps a tls.Certificate, extracting its domains and validity period
+func newCertificate(tlsCert tls.Certificate) (*Certificate, error) {
 	// Parse the certificate to extract information
 	cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
 	if err != nil {
@@ -132,34 +174,39 @@ func (cm *CertificateManager) SetDefaultCertificate(cert *Certificate) error {
 // GetCertificate returns the certificate for the given server name (SNI)
 // This method is suitable for use as tls.Config.GetCertificate
 func (cm *CertificateManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := cm.Lookup(hello.ServerName); cert != nil {
+		return &cert.TLSCert, nil
+	}
+
+	if hello.ServerName == "" {
+		return nil, fmt.Errorf("no default certificate configured")
+	}
+	return nil, fmt.Errorf("no certificate found for %s", hello.ServerName)
+}
+
+// Lookup returns the certificate served for the given server name: an exact match,
+// then a wildcard match, then the default certificate (nil if there is none)
+func (cm *CertificateManager) Lookup(serverName string) *Certificate {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	serverName := hello.ServerName
 	if serverName == "" {
 		// No SNI provided, use default certificate
-		if cm.defaultCert != nil {
-			return &cm.defaultCert.TLSCert, nil
-		}
-		return nil, fmt.Errorf("no default certificate configured")
+		return cm.defaultCert
 	}
 
 	// Try exact match first
 	if cert, ok := cm.certificates[serverName]; ok {
-		return &cert.TLSCert, nil
+		return cert
 	}
 
 	// Try wildcard match
 	if cert := cm.findWildcardCertificate(serverName); cert != nil {
-		return &cert.TLSCert, nil
+		return cert
 	}
 
 	// Fall back to default certificate
-	if cm.defaultCert != nil {
-		return &cm.defaultCert.TLSCert, nil
-	}
-
-	return nil, fmt.Errorf("no certificate found for %s", serverName)
+	return cm.defaultCert
 }
 
 // findWildcardCertificate finds a wildcard certificate matching the server name
@@ -182,6 +229,35 @@ func (cm *CertificateManager) findWildcardCertificate(serverName string) *Certif
 	return nil
 }
 
+// ReplaceCertificate swaps old for cert, e.g. after a renewal
+// Domains served by old are moved to cert, which also becomes the default if old was.
+func (cm *CertificateManager) ReplaceCertificate(old, cert *Certificate) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if err := cm.validateCertificate(cert); err != nil {
+		return err
+	}
+
+	for domain, c := range cm.certificates {
+		if c == old {
+			delete(cm.certificates, domain)
+		}
+	}
+	for _, domain := range cert.Domains {
+		cm.certificates[domain] = cert
+	}
+	if cm.defaultCert == old || cm.defaultCert == nil {
+		cm.defaultCert = cert
+	}
+
+	cm.expiryMu.Lock()
+	delete(cm.expiryWarned, old)
+	cm.expiryMu.Unlock()
+
+	return nil
+}
+
 // RemoveCertificate removes a certificate for the specified domain
 func (cm *CertificateManager) RemoveCertificate(domain string) {
 	cm.mu.Lock()
@@ -1,13 +1,16 @@
 package tls
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestNewCertificateManager(t *testing.T) {
-	cm := NewCertificateManager()
+	cm := NewCertificateManager(nil)
 
 	if cm == nil {
 		t.Fatal("Expected non-nil certificate manager")
@@ -49,7 +52,7 @@ func TestGenerateSelfSignedCertificate(t *testing.T) {
 }
 
 func TestCertificateManagerAddCertificate(t *testing.T) {
-	cm := NewCertificateManager()
+	cm := NewCertificateManager(nil)
 
 	// Generate a test certificate
 	cert, err := GenerateSelfSignedCertificate([]string{"example.com"})
@@ -70,7 +73,7 @@ func TestCertificateManagerAddCertificate(t *testing.T) {
 }
 
 func TestCertificateManagerGetCertificate(t *testing.T) {
-	cm := NewCertificateManager()
+	cm := NewCertificateManager(nil)
 
 	// Generate and add a test certificate
 	cert, err := GenerateSelfSignedCertificate([]string{"example.com", "www.example.com"})
@@ -130,7 +133,7 @@ func TestCertificateManagerGetCertificate(t *testing.T) {
 }
 
 func TestCertificateManagerWildcard(t *testing.T) {
-	cm := NewCertificateManager()
+	cm := NewCertificateManager(nil)
 
 	// Generate wildcard certificate
 	cert, err := GenerateSelfSignedCertificate([]string{"*.example.com"})
@@ -184,7 +187,7 @@ func TestCertificateManagerWildcard(t *testing.T) {
 }
 
 func TestCertificateManagerRemoveCertificate(t *testing.T) {
-	cm := NewCertificateManager()
+	cm := NewCertificateManager(nil)
 
 	cert, err := GenerateSelfSignedCertificate([]string{"example.com"})
 	if err != nil {
@@ -213,7 +216,7 @@ func TestCertificateManagerRemoveCertificate(t *testing.T) {
 }
 
 func TestCertificateManagerListCertificates(t *testing.T) {
-	cm := NewCertificateManager()
+	cm := NewCertificateManager(nil)
 
 	// Add multiple certificates
 	cert1, _ := GenerateSelfSignedCertificate([]string{"example.com"})
@@ -230,8 +233,22 @@ func TestCertificateManagerListCertificates(t *testing.T) {
 	}
 }
 
+func TestCertificateFingerprint(t *testing.T) {
+	cert, err := GenerateSelfSignedCertificate([]string{"example.com"})
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	sum := sha256.Sum256(cert.Cert.Raw)
+	want := strings.ToUpper(hex.EncodeToString(sum[:2]))
+	fingerprint := cert.Fingerprint()
+	if len(fingerprint) != 32*3-1 || !strings.HasPrefix(fingerprint, want[:2]+":"+want[2:]+":") {
+		t.Errorf("Unexpected fingerprint %s", fingerprint)
+	}
+}
+
 func TestCertificateManagerCheckExpiry(t *testing.T) {
-	cm := NewCertificateManager()
+	cm := NewCertificateManager(nil)
 
 	// Generate a certificate (valid for 1 year)
 	cert, err := GenerateSelfSignedCertificate([]string{"example.com"})
@@ -254,8 +271,46 @@ func TestCertificateManagerCheckExpiry(t *testing.T) {
 	}
 }
 
+func TestCertificateManagerReportExpiry(t *testing.T) {
+	cm := NewCertificateManager(nil)
+
+	cert, err := GenerateSelfSignedCertificate([]string{"example.com"})
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	cm.AddCertificate(cert)
+
+	// A certificate with a year left is not warned about
+	if warned := cm.ReportExpiry(); len(warned) != 0 {
+		t.Errorf("Expected no warnings, got %d", len(warned))
+	}
+
+	// Crossing a threshold warns once
+	cert.NotAfter = time.Now().Add(10 * 24 * time.Hour)
+	if warned := cm.ReportExpiry(); len(warned) != 1 {
+		t.Errorf("Expected a warning at 10 days left, got %d", len(warned))
+	}
+	if warned := cm.ReportExpiry(); len(warned) != 0 {
+		t.Errorf("Expected no repeated warning, got %d", len(warned))
+	}
+
+	// The warning escalates at the next threshold
+	cert.NotAfter = time.Now().Add(12 * time.Hour)
+	if warned := cm.ReportExpiry(); len(warned) != 1 {
+		t.Errorf("Expected a warning at 12 hours left, got %d", len(warned))
+	}
+
+	// Expired certificates are reported every time
+	cert.NotAfter = time.Now().Add(-time.Hour)
+	for i := 0; i < 2; i++ {
+		if warned := cm.ReportExpiry(); len(warned) != 1 {
+			t.Errorf("Expected expired certificate to be reported, got %d", len(warned))
+		}
+	}
+}
+
 func TestValidateCertificate(t *testing.T) {
-	cm := NewCertificateManager()
+	cm := NewCertificateManager(nil)
 
 	// Test with nil certificate
 	err := cm.validateCertificate(nil)
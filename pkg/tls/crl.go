@@ -0,0 +1,135 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+// ErrCertificateRevoked is returned by VerifyConnection for revoked client certificates
+var ErrCertificateRevoked = errors.New("certificate has been revoked")
+
+// RevocationList rejects client certificates listed in a certificate revocation list (CRL) file
+// The file may hold one or more PEM or DER encoded CRLs and is trusted as-is
+type RevocationList struct {
+	file   string
+	logger *logging.Logger
+
+	mu sync.RWMutex
+	// revoked maps a CRL issuer (raw subject) to its revoked serial numbers
+	revoked map[string]map[string]bool
+	// nextUpdate is the earliest NextUpdate of the loaded CRLs
+	nextUpdate time.Time
+}
+
+// NewRevocationList loads the CRLs in file; reload failures are reported to logger
+func NewRevocationList(file string, logger *logging.Logger) (*RevocationList, error) {
+	rl := &RevocationList{file: file, logger: logger}
+	if err := rl.Reload(); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// Reload reads the CRL file again; the previous list is kept if it cannot be loaded
+func (rl *RevocationList) Reload() error {
+	data, err := os.ReadFile(rl.file)
+	if err != nil {
+		return fmt.Errorf("failed to read CRL file: %w", err)
+	}
+
+	ders := [][]byte{data}
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		ders = nil
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type == "X509 CRL" {
+				ders = append(ders, block.Bytes)
+			}
+		}
+		if len(ders) == 0 {
+			return fmt.Errorf("no CRLs found in %s", rl.file)
+		}
+	}
+
+	revoked := make(map[string]map[string]bool)
+	var nextUpdate time.Time
+	for _, der := range ders {
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return fmt.Errorf("failed to parse CRL in %s: %w", rl.file, err)
+		}
+
+		issuer := string(crl.RawIssuer)
+		if revoked[issuer] == nil {
+			revoked[issuer] = make(map[string]bool)
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			revoked[issuer][entry.SerialNumber.String()] = true
+		}
+		if !crl.NextUpdate.IsZero() && (nextUpdate.IsZero() || crl.NextUpdate.Before(nextUpdate)) {
+			nextUpdate = crl.NextUpdate
+		}
+	}
+
+	rl.mu.Lock()
+	rl.revoked = revoked
+	rl.nextUpdate = nextUpdate
+	rl.mu.Unlock()
+
+	return nil
+}
+
+// IsRevoked reports whether a certificate is listed by a CRL from its issuer
+func (rl *RevocationList) IsRevoked(cert *x509.Certificate) bool {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	return rl.revoked[string(cert.RawIssuer)][cert.SerialNumber.String()]
+}
+
+// VerifyConnection rejects connections whose client certificate chain contains a
+// revoked certificate. It is suitable for use as tls.Config.VerifyConnection, which
+// also runs for resumed sessions.
+func (rl *RevocationList) VerifyConnection(state tls.ConnectionState) error {
+	for _, cert := range state.PeerCertificates {
+		if rl.IsRevoked(cert) {
+			return fmt.Errorf("client certificate %s (serial %s): %w",
+				cert.Subject.CommonName, cert.SerialNumber, ErrCertificateRevoked)
+		}
+	}
+	return nil
+}
+
+// Refresh reloads the CRL file every interval until ctx is done
+func (rl *RevocationList) Refresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := rl.Reload(); err != nil {
+				rl.logger.Error("Failed to reload CRL, keeping the previous list", logging.String("file", rl.file), logging.Err(err))
+				continue
+			}
+
+			rl.mu.RLock()
+			nextUpdate := rl.nextUpdate
+			rl.mu.RUnlock()
+			if !nextUpdate.IsZero() && time.Now().After(nextUpdate) {
+				rl.logger.Warn("CRL is stale", logging.String("file", rl.file), logging.String("next_update", nextUpdate.Format(time.RFC3339)))
+			}
+		}
+	}
+}
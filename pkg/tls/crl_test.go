@@ -0,0 +1,112 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRevocationList(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64) *x509.Certificate {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "client"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &caKey.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("Failed to issue certificate: %v", err)
+		}
+		cert, _ := x509.ParseCertificate(der)
+		return cert
+	}
+
+	file := filepath.Join(t.TempDir(), "ca.crl")
+	writeCRL := func(revoked ...int64) {
+		entries := make([]x509.RevocationListEntry, 0, len(revoked))
+		for _, serial := range revoked {
+			entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+		}
+		der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:                    big.NewInt(time.Now().UnixNano()),
+			RevokedCertificateEntries: entries,
+			ThisUpdate:                time.Now(),
+			NextUpdate:                time.Now().Add(time.Hour),
+		}, ca, caKey)
+		if err != nil {
+			t.Fatalf("Failed to create CRL: %v", err)
+		}
+		if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0o600); err != nil {
+			t.Fatalf("Failed to write CRL: %v", err)
+		}
+	}
+
+	writeCRL(42)
+	rl, err := NewRevocationList(file, nil)
+	if err != nil {
+		t.Fatalf("Failed to load CRL: %v", err)
+	}
+
+	revoked, valid := issue(42), issue(43)
+	if !rl.IsRevoked(revoked) {
+		t.Error("Expected certificate 42 to be revoked")
+	}
+	if rl.IsRevoked(valid) {
+		t.Error("Expected certificate 43 not to be revoked")
+	}
+	if err := rl.VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{revoked, ca}}); !errors.Is(err, ErrCertificateRevoked) {
+		t.Errorf("Expected connection with a revoked client certificate to be rejected, got %v", err)
+	}
+	if err := rl.VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{valid, ca}}); err != nil {
+		t.Errorf("Expected connection to be accepted, got %v", err)
+	}
+
+	// Reloading picks up newly revoked certificates
+	writeCRL(42, 43)
+	if err := rl.Reload(); err != nil {
+		t.Fatalf("Failed to reload CRL: %v", err)
+	}
+	if !rl.IsRevoked(valid) {
+		t.Error("Expected certificate 43 to be revoked after reload")
+	}
+
+	// A broken file keeps the previous list
+	os.WriteFile(file, []byte("not a crl"), 0o600)
+	if err := rl.Reload(); err == nil {
+		t.Error("Expected reload of an invalid CRL to fail")
+	}
+	if !rl.IsRevoked(revoked) {
+		t.Error("Expected previous list to be kept after a failed reload")
+	}
+}
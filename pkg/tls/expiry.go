@@ -0,0 +1,90 @@
+package tls
+
+import (
+	"context"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"github.com/therealutkarshpriyadarshi/balance/pkg/metrics"
+)
+
+// expiryWarnings are the remaining lifetimes at which expiry warnings escalate, longest first
+var expiryWarnings = []time.Duration{
+	30 * 24 * time.Hour,
+	14 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+	24 * time.Hour,
+}
+
+// Name returns the name a certificate is reported under: its common name, or its first domain
+func (c *Certificate) Name() string {
+	if c.Cert != nil && c.Cert.Subject.CommonName != "" {
+		return c.Cert.Subject.CommonName
+	}
+	if len(c.Domains) > 0 {
+		return c.Domains[0]
+	}
+	return "unknown"
+}
+
+// ReportExpiry exports the remaining lifetime of every certificate as
+// balance_tls_cert_expiry_seconds and logs a warning the first time a certificate
+// crosses each warning threshold (30, 14, 7 and 1 days). Expired certificates are
+// logged on every call. It returns the certificates that were logged.
+func (cm *CertificateManager) ReportExpiry() []*Certificate {
+	now := time.Now()
+	for _, cert := range cm.ListCertificates() {
+		metrics.SetTLSCertExpiry(cert.Name(), cert.NotAfter.Sub(now))
+	}
+
+	cm.expiryMu.Lock()
+	defer cm.expiryMu.Unlock()
+
+	var warned []*Certificate
+	for _, cert := range cm.CheckExpiry(expiryWarnings[0]) {
+		remaining := cert.NotAfter.Sub(now)
+		if remaining <= 0 {
+			cm.logger.Error("TLS certificate expired",
+				logging.String("certificate", cert.Name()), logging.String("not_after", cert.NotAfter.Format(time.RFC3339)))
+			warned = append(warned, cert)
+			continue
+		}
+
+		// Level is the number of thresholds the certificate has crossed
+		level := 0
+		for _, threshold := range expiryWarnings {
+			if remaining <= threshold {
+				level++
+			}
+		}
+		if level <= cm.expiryWarned[cert] {
+			continue
+		}
+		cm.expiryWarned[cert] = level
+
+		cm.logger.Warn("TLS certificate expires soon",
+			logging.String("certificate", cert.Name()), logging.Duration("remaining", remaining.Round(time.Hour)),
+			logging.String("not_after", cert.NotAfter.Format(time.RFC3339)))
+		warned = append(warned, cert)
+	}
+
+	return warned
+}
+
+// MonitorExpiry reports certificate expiry immediately and then every interval until
+// ctx is done
+func (cm *CertificateManager) MonitorExpiry(ctx context.Context, interval time.Duration) {
+	cm.ReportExpiry()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cm.ReportExpiry()
+		}
+	}
+}
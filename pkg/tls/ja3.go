@@ -0,0 +1,58 @@
+package tls
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// extensionSupportedVersions is the supported_versions ClientHello extension
+const extensionSupportedVersions = 43
+
+// JA3 returns the JA3 fingerprint of a ClientHello and its MD5 hash, which is the
+// form fingerprints are usually shared and blocklisted in
+//
+// crypto/tls does not expose the legacy version field, so it is derived: clients
+// sending supported_versions always set it to TLS 1.2, others to their highest
+// supported version.
+func JA3(hello *tls.ClientHelloInfo) (fingerprint, hash string) {
+	version := uint16(tls.VersionTLS12)
+	if !slices.Contains(hello.Extensions, extensionSupportedVersions) && len(hello.SupportedVersions) > 0 {
+		version = slices.Max(hello.SupportedVersions)
+	}
+
+	curves := make([]uint16, 0, len(hello.SupportedCurves))
+	for _, curve := range hello.SupportedCurves {
+		curves = append(curves, uint16(curve))
+	}
+	points := make([]uint16, 0, len(hello.SupportedPoints))
+	for _, point := range hello.SupportedPoints {
+		points = append(points, uint16(point))
+	}
+
+	fingerprint = strings.Join([]string{
+		strconv.Itoa(int(version)),
+		joinJA3(hello.CipherSuites),
+		joinJA3(hello.Extensions),
+		joinJA3(curves),
+		joinJA3(points),
+	}, ",")
+
+	sum := md5.Sum([]byte(fingerprint))
+	return fingerprint, hex.EncodeToString(sum[:])
+}
+
+// joinJA3 joins values with dashes, skipping GREASE values (RFC 8701)
+func joinJA3(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		if v&0x0f0f == 0x0a0a && v>>8 == v&0xff {
+			continue
+		}
+		parts = append(parts, strconv.Itoa(int(v)))
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,48 @@
+package tls
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestJA3(t *testing.T) {
+	tests := []struct {
+		name  string
+		hello *tls.ClientHelloInfo
+		want  string
+	}{
+		{
+			name: "TLS 1.3 client with GREASE",
+			hello: &tls.ClientHelloInfo{
+				CipherSuites:      []uint16{0x0a0a, 4865, 4866, 49195},
+				Extensions:        []uint16{0x1a1a, 0, 10, 11, 43, 51},
+				SupportedCurves:   []tls.CurveID{0x2a2a, tls.X25519, tls.CurveP256},
+				SupportedPoints:   []uint8{0},
+				SupportedVersions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+			},
+			want: "771,4865-4866-49195,0-10-11-43-51,29-23,0",
+		},
+		{
+			name: "TLS 1.2 client without supported_versions",
+			hello: &tls.ClientHelloInfo{
+				CipherSuites:      []uint16{49199, 49200},
+				Extensions:        []uint16{0, 10, 11},
+				SupportedCurves:   []tls.CurveID{tls.CurveP256},
+				SupportedVersions: []uint16{tls.VersionTLS12, tls.VersionTLS11},
+			},
+			want: "771,49199-49200,0-10-11,23,",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fingerprint, hash := JA3(tt.hello)
+			if fingerprint != tt.want {
+				t.Errorf("JA3() fingerprint = %q, want %q", fingerprint, tt.want)
+			}
+			if len(hash) != 32 {
+				t.Errorf("Expected an MD5 hex hash, got %q", hash)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package tls
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into every pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesSecretSource loads certificates from a kubernetes.io/tls Secret using the
+// pod's service account, which needs permission to get the Secret
+type KubernetesSecretSource struct {
+	namespace string
+	name      string
+
+	// apiServer is the base URL of the Kubernetes API and tokenFile the service account token
+	apiServer string
+	tokenFile string
+	client    *http.Client
+}
+
+// NewKubernetesSecretSource creates an in-cluster source for the Secret namespace/name
+// An empty namespace selects the pod's own namespace.
+func NewKubernetesSecretSource(namespace, name string) (*KubernetesSecretSource, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("kubernetes: not running in a cluster (KUBERNETES_SERVICE_HOST is not set)")
+	}
+
+	if namespace == "" {
+		b, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes: failed to read the pod namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(b))
+	}
+
+	client, err := newSourceHTTPClient(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: %w", err)
+	}
+
+	return &KubernetesSecretSource{
+		namespace: namespace,
+		name:      name,
+		apiServer: "https://" + net.JoinHostPort(host, port),
+		tokenFile: filepath.Join(serviceAccountDir, "token"),
+		client:    client,
+	}, nil
+}
+
+// Name identifies the source in logs
+func (k *KubernetesSecretSource) Name() string {
+	return fmt.Sprintf("kubernetes secret %s/%s", k.namespace, k.name)
+}
+
+// kubernetesSecret is the part of a Secret object holding its data
+// encoding/json decodes the base64 encoded values into the byte slices.
+type kubernetesSecret struct {
+	Type string            `json:"type"`
+	Data map[string][]byte `json:"data"`
+}
+
+// Fetch reads the Secret and parses its tls.crt and tls.key
+func (k *KubernetesSecretSource) Fetch(ctx context.Context) (*Certificate, error) {
+	// Projected service account tokens rotate, so the token is read for every request
+	token, err := os.ReadFile(k.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: failed to read service account token: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/namespaces/%s/secrets/%s", k.apiServer, k.namespace, k.name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("kubernetes: get secret %s/%s failed with status %d: %s",
+			k.namespace, k.name, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var secret kubernetesSecret
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("kubernetes: invalid secret %s/%s: %w", k.namespace, k.name, err)
+	}
+
+	certPEM, keyPEM := secret.Data["tls.crt"], secret.Data["tls.key"]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, fmt.Errorf("kubernetes: secret %s/%s has no tls.crt and tls.key", k.namespace, k.name)
+	}
+
+	cert, err := ParseCertificatePEM(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: secret %s/%s: %w", k.namespace, k.name, err)
+	}
+	return cert, nil
+}
@@ -3,10 +3,11 @@ package tls
 import (
 	"crypto/tls"
 	"fmt"
-	"log"
 	"strings"
 	"sync"
 	"sync/atomic"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
 )
 
 // SNIRouter routes connections based on Server Name Indication (SNI)
@@ -22,17 +23,20 @@ type SNIRouter struct {
 	// certManager manages certificates for different domains
 	certManager *CertificateManager
 
+	logger *logging.Logger
+
 	// Statistics
 	totalRequests atomic.Int64
 	routedByHost  map[string]*atomic.Int64 // Per-host routing stats
 	statsMu       sync.RWMutex
 }
 
-// NewSNIRouter creates a new SNI router
-func NewSNIRouter(certManager *CertificateManager) *SNIRouter {
+// NewSNIRouter creates a new SNI router logging route changes to logger
+func NewSNIRouter(certManager *CertificateManager, logger *logging.Logger) *SNIRouter {
 	return &SNIRouter{
 		routes:       make(map[string][]string),
 		certManager:  certManager,
+		logger:       logger,
 		routedByHost: make(map[string]*atomic.Int64),
 	}
 }
@@ -59,7 +63,7 @@ func (r *SNIRouter) AddRoute(hostname string, backends []string) error {
 	}
 	r.statsMu.Unlock()
 
-	log.Printf("Added SNI route: %s -> %v", hostname, backends)
+	r.logger.Info("Added SNI route", logging.String("hostname", hostname), logging.Any("backends", backends))
 	return nil
 }
 
@@ -69,7 +73,7 @@ func (r *SNIRouter) RemoveRoute(hostname string) {
 	defer r.mu.Unlock()
 
 	delete(r.routes, hostname)
-	log.Printf("Removed SNI route: %s", hostname)
+	r.logger.Info("Removed SNI route", logging.String("hostname", hostname))
 }
 
 // SetDefaultBackends sets the default backends when no SNI match is found
@@ -78,7 +82,7 @@ func (r *SNIRouter) SetDefaultBackends(backends []string) {
 	defer r.mu.Unlock()
 
 	r.defaultBackends = backends
-	log.Printf("Set default backends: %v", backends)
+	r.logger.Info("Set default SNI backends", logging.Any("backends", backends))
 }
 
 // Route returns the backend addresses for the given SNI hostname
@@ -88,30 +92,46 @@ func (r *SNIRouter) Route(hostname string) []string {
 
 	r.totalRequests.Add(1)
 
-	// Try exact match
-	if backends, ok := r.routes[hostname]; ok {
-		r.incrementHostStats(hostname)
-		return backends
-	}
-
-	// Try wildcard match
-	if backends := r.matchWildcard(hostname); backends != nil {
-		r.incrementHostStats(hostname)
-		return backends
+	if pattern, ok := r.match(hostname); ok {
+		r.incrementHostStats(pattern)
+		return r.routes[pattern]
 	}
 
 	// Return default backends
 	return r.defaultBackends
 }
 
+// Match returns the route hostname (an exact hostname or wildcard pattern) that
+// the given SNI hostname matches, and whether any route matched
+func (r *SNIRouter) Match(hostname string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	r.totalRequests.Add(1)
+
+	pattern, ok := r.match(hostname)
+	if ok {
+		r.incrementHostStats(pattern)
+	}
+	return pattern, ok
+}
+
+// match finds the route for a hostname, trying an exact match before wildcards
+func (r *SNIRouter) match(hostname string) (string, bool) {
+	if _, ok := r.routes[hostname]; ok {
+		return hostname, true
+	}
+	return r.matchWildcard(hostname)
+}
+
 // matchWildcard matches wildcard hostnames (e.g., *.example.com)
-func (r *SNIRouter) matchWildcard(hostname string) []string {
-	for pattern, backends := range r.routes {
+func (r *SNIRouter) matchWildcard(hostname string) (string, bool) {
+	for pattern := range r.routes {
 		if matchWildcardPattern(pattern, hostname) {
-			return backends
+			return pattern, true
 		}
 	}
-	return nil
+	return "", false
 }
 
 // matchWildcardPattern checks if hostname matches a wildcard pattern
@@ -166,13 +186,15 @@ func (r *SNIRouter) GetCertificateForSNI(hello *tls.ClientHelloInfo) (*tls.Certi
 type SNIHandler struct {
 	router      *SNIRouter
 	certManager *CertificateManager
+	logger      *logging.Logger
 }
 
 // NewSNIHandler creates a new SNI handler
-func NewSNIHandler(router *SNIRouter, certManager *CertificateManager) *SNIHandler {
+func NewSNIHandler(router *SNIRouter, certManager *CertificateManager, logger *logging.Logger) *SNIHandler {
 	return &SNIHandler{
 		router:      router,
 		certManager: certManager,
+		logger:      logger,
 	}
 }
 
@@ -180,19 +202,19 @@ func NewSNIHandler(router *SNIRouter, certManager *CertificateManager) *SNIHandl
 func (h *SNIHandler) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	serverName := hello.ServerName
 
-	log.Printf("SNI request for: %s", serverName)
+	h.logger.Debug("SNI request", logging.String("server_name", serverName))
 
 	// Get certificate from certificate manager
 	cert, err := h.certManager.GetCertificate(hello)
 	if err != nil {
-		log.Printf("Failed to get certificate for %s: %v", serverName, err)
+		h.logger.Warn("Failed to get certificate", logging.String("server_name", serverName), logging.Err(err))
 		return nil, err
 	}
 
 	// Route the request (for statistics/logging)
 	if h.router != nil {
 		backends := h.router.Route(serverName)
-		log.Printf("SNI routing %s to backends: %v", serverName, backends)
+		h.logger.Debug("SNI routing", logging.String("server_name", serverName), logging.Any("backends", backends))
 	}
 
 	return cert, nil
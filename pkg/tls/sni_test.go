@@ -0,0 +1,33 @@
+package tls
+
+import (
+	"testing"
+)
+
+func TestSNIRouterMatch(t *testing.T) {
+	router := NewSNIRouter(nil, nil)
+	router.AddRoute("api.example.com", []string{"api"})
+	router.AddRoute("*.example.com", []string{"web"})
+	router.SetDefaultBackends([]string{"default"})
+
+	tests := []struct {
+		hostname string
+		pattern  string
+		matched  bool
+		backends string
+	}{
+		{"api.example.com", "api.example.com", true, "api"},
+		{"www.example.com", "*.example.com", true, "web"},
+		{"example.org", "", false, "default"},
+	}
+
+	for _, tt := range tests {
+		pattern, ok := router.Match(tt.hostname)
+		if pattern != tt.pattern || ok != tt.matched {
+			t.Errorf("Match(%s) = %q, %v, want %q, %v", tt.hostname, pattern, ok, tt.pattern, tt.matched)
+		}
+		if backends := router.Route(tt.hostname); len(backends) != 1 || backends[0] != tt.backends {
+			t.Errorf("Route(%s) = %v, want [%s]", tt.hostname, backends, tt.backends)
+		}
+	}
+}
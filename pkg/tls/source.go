@@ -0,0 +1,97 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+)
+
+// sourceRequestTimeout bounds a single fetch from a certificate source
+const sourceRequestTimeout = 30 * time.Second
+
+// CertificateSource fetches a certificate from outside the filesystem, such as a
+// secrets store or a certificate authority
+type CertificateSource interface {
+	// Name identifies the source in logs
+	Name() string
+
+	// Fetch returns the current certificate
+	Fetch(ctx context.Context) (*Certificate, error)
+}
+
+// RefreshCertificate fetches the certificate from source every interval until ctx is
+// done, replacing current in the manager whenever a different certificate is returned.
+// A certificate is fetched early once two thirds of its lifetime have passed, so short
+// lived certificates are renewed before they expire. If a fetch fails, the current
+// certificate stays in use. onUpdate, if not nil, is called after each replacement.
+func (cm *CertificateManager) RefreshCertificate(ctx context.Context, source CertificateSource, current *Certificate,
+	interval time.Duration, onUpdate func(old, cert *Certificate)) {
+	for {
+		wait := interval
+		if renew := time.Until(renewalTime(current)); renew < wait {
+			wait = max(renew, time.Second)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, sourceRequestTimeout)
+		cert, err := source.Fetch(fetchCtx)
+		cancel()
+		if err != nil {
+			cm.logger.Error("Failed to refresh TLS certificate, keeping the current one",
+				logging.String("source", source.Name()), logging.Err(err))
+			continue
+		}
+		if cert.Cert.Equal(current.Cert) {
+			continue
+		}
+
+		if err := cm.ReplaceCertificate(current, cert); err != nil {
+			cm.logger.Error("Rejected TLS certificate", logging.String("source", source.Name()), logging.Err(err))
+			continue
+		}
+		cm.logger.Info("Refreshed TLS certificate",
+			logging.String("certificate", cert.Name()), logging.String("source", source.Name()),
+			logging.String("not_after", cert.NotAfter.Format(time.RFC3339)))
+		if onUpdate != nil {
+			onUpdate(current, cert)
+		}
+		current = cert
+	}
+}
+
+// renewalTime returns when two thirds of a certificate's lifetime have passed
+func renewalTime(cert *Certificate) time.Time {
+	return cert.NotBefore.Add(cert.NotAfter.Sub(cert.NotBefore) * 2 / 3)
+}
+
+// newSourceHTTPClient returns the HTTP client a source uses to reach its API,
+// trusting the CAs in caFile in addition to the system roots if it is set
+func newSourceHTTPClient(caFile string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &http.Client{Transport: transport, Timeout: sourceRequestTimeout}, nil
+}
@@ -0,0 +1,214 @@
+package tls
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// encodeCertificatePEM returns the PEM certificate and RSA key of a generated certificate
+func encodeCertificatePEM(t *testing.T, cert *Certificate) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, ok := cert.TLSCert.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		t.Fatal("Expected an RSA key")
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Cert.Raw})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return certPEM, keyPEM
+}
+
+func TestVaultPKISource(t *testing.T) {
+	var issued atomic.Int32
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/pki_int/issue/web" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != "s.test" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["common_name"] != "www.example.com" || req["alt_names"] != "example.com" || req["ttl"] != "86400s" {
+			t.Errorf("Unexpected issue request: %v", req)
+		}
+
+		cert, err := GenerateSelfSignedCertificate([]string{req["common_name"], req["alt_names"]})
+		if err != nil {
+			t.Errorf("Failed to generate certificate: %v", err)
+			return
+		}
+		certPEM, keyPEM := encodeCertificatePEM(t, cert)
+		issued.Add(1)
+
+		var resp vaultIssueResponse
+		resp.Data.Certificate = string(certPEM)
+		resp.Data.PrivateKey = string(keyPEM)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer vault.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s.test\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	source, err := NewVaultPKISource(VaultPKIConfig{
+		Address:    vault.URL + "/",
+		TokenFile:  tokenFile,
+		Mount:      "/pki_int",
+		Role:       "web",
+		CommonName: "www.example.com",
+		AltNames:   []string{"example.com"},
+		TTL:        24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	cert, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(cert.Domains) == 0 || cert.Domains[0] != "www.example.com" {
+		t.Errorf("Expected a certificate for www.example.com, got %v", cert.Domains)
+	}
+
+	// The issued certificate is reused until it is due for renewal
+	again, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if again != cert || issued.Load() != 1 {
+		t.Errorf("Expected the certificate to be reused, issued %d", issued.Load())
+	}
+
+	source.config.Token, source.config.TokenFile = "wrong", ""
+	source.last = nil
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Error("Expected an error for a rejected token")
+	}
+}
+
+func TestKubernetesSecretSource(t *testing.T) {
+	cert, err := GenerateSelfSignedCertificate([]string{"api.example.com"})
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	certPEM, keyPEM := encodeCertificatePEM(t, cert)
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sa-token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/namespaces/edge/secrets/api-tls":
+			json.NewEncoder(w).Encode(kubernetesSecret{
+				Type: "kubernetes.io/tls",
+				Data: map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM},
+			})
+		case "/api/v1/namespaces/edge/secrets/opaque":
+			json.NewEncoder(w).Encode(kubernetesSecret{Type: "Opaque", Data: map[string][]byte{"password": []byte("x")}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer apiServer.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("sa-token"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{name: "tls secret", secret: "api-tls", wantErr: false},
+		{name: "secret without tls keys", secret: "opaque", wantErr: true},
+		{name: "missing secret", secret: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &KubernetesSecretSource{
+				namespace: "edge",
+				name:      tt.secret,
+				apiServer: apiServer.URL,
+				tokenFile: tokenFile,
+				client:    apiServer.Client(),
+			}
+			got, err := source.Fetch(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Cert.Equal(cert.Cert) {
+				t.Error("Expected the certificate from the secret")
+			}
+		})
+	}
+}
+
+// staticSource returns its certificate until it is changed
+type staticSource struct {
+	cert atomic.Pointer[Certificate]
+}
+
+func (s *staticSource) Name() string { return "static" }
+
+func (s *staticSource) Fetch(context.Context) (*Certificate, error) {
+	return s.cert.Load(), nil
+}
+
+func TestCertificateManagerRefreshCertificate(t *testing.T) {
+	cm := NewCertificateManager(nil)
+
+	first, _ := GenerateSelfSignedCertificate([]string{"example.com"})
+	second, _ := GenerateSelfSignedCertificate([]string{"example.com", "www.example.com"})
+	cm.AddCertificate(first)
+
+	source := &staticSource{}
+	source.cert.Store(first)
+
+	updated := make(chan *Certificate, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cm.RefreshCertificate(ctx, source, first, 10*time.Millisecond, func(old, cert *Certificate) {
+		if old != first {
+			t.Error("Expected the first certificate to be replaced")
+		}
+		updated <- cert
+	})
+
+	source.cert.Store(second)
+	select {
+	case cert := <-updated:
+		if cert != second {
+			t.Error("Expected the second certificate")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the refresh")
+	}
+
+	if cm.Lookup("www.example.com") != second || cm.Lookup("") != second {
+		t.Error("Expected the second certificate to replace the first, including as default")
+	}
+	if certs := cm.ListCertificates(); len(certs) != 1 {
+		t.Errorf("Expected 1 certificate after the replacement, got %d", len(certs))
+	}
+}
@@ -0,0 +1,354 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// fetchX509SVIDMethod is the SPIFFE Workload API method streaming X.509 SVIDs
+	fetchX509SVIDMethod = "/SpiffeWorkloadAPI/FetchX509SVID"
+
+	// spiffeMaxBackoff caps the delay between Workload API reconnects
+	spiffeMaxBackoff = 30 * time.Second
+)
+
+// SPIFFESource fetches X.509 SVIDs from the SPIFFE Workload API and keeps them current
+// The SVID is served as the default certificate of an optional CertificateManager and
+// presented as the client certificate on backend connections; the trust bundle is
+// used to verify backends.
+type SPIFFESource struct {
+	socketPath string
+	certMgr    *CertificateManager // nil if the SVID is only used for backend connections
+
+	mu     sync.RWMutex
+	svid   *Certificate
+	id     string
+	bundle *x509.CertPool
+
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	logger *logging.Logger
+}
+
+// NewSPIFFESource creates a source for the Workload API at socketPath
+// (a filesystem path or unix:// URI)
+func NewSPIFFESource(socketPath string, certMgr *CertificateManager, logger *logging.Logger) *SPIFFESource {
+	return &SPIFFESource{
+		socketPath: socketPath,
+		certMgr:    certMgr,
+		ready:      make(chan struct{}),
+		logger:     logger,
+	}
+}
+
+// Run streams SVID updates until ctx is done, reconnecting with backoff when the
+// Workload API is unavailable
+func (s *SPIFFESource) Run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := s.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > spiffeMaxBackoff {
+			// The stream was healthy for a while, so reconnect quickly
+			backoff = time.Second
+		}
+		s.logger.Warn("SPIFFE Workload API stream ended, reconnecting", logging.Duration("backoff", backoff), logging.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, spiffeMaxBackoff)
+	}
+}
+
+// Ready is closed once the first SVID has been received
+func (s *SPIFFESource) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// ID returns the SPIFFE ID of the current SVID ("" before the first update)
+func (s *SPIFFESource) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// GetClientCertificate returns the current SVID
+// This method is suitable for use as tls.Config.GetClientCertificate
+func (s *SPIFFESource) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.svid == nil {
+		return nil, errors.New("no SVID received from the SPIFFE Workload API yet")
+	}
+	return &s.svid.TLSCert, nil
+}
+
+// VerifyPeer returns a tls.Config.VerifyPeerCertificate callback that verifies the peer
+// against the current trust bundle and, if allowedIDs is not empty, checks its SPIFFE ID
+// SVIDs carry no DNS names, so the config must also set InsecureSkipVerify to skip
+// hostname verification.
+func (s *SPIFFESource) VerifyPeer(allowedIDs []string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("peer presented no certificate")
+		}
+
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse peer certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+
+		s.mu.RLock()
+		bundle := s.bundle
+		s.mu.RUnlock()
+		if bundle == nil {
+			return errors.New("no SPIFFE trust bundle received yet")
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         bundle,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return fmt.Errorf("peer certificate not trusted by the SPIFFE bundle: %w", err)
+		}
+
+		id := spiffeID(certs[0])
+		if id == "" {
+			return errors.New("peer certificate has no SPIFFE ID")
+		}
+		if len(allowedIDs) > 0 && !slices.Contains(allowedIDs, id) {
+			return fmt.Errorf("peer SPIFFE ID %s is not allowed", id)
+		}
+		return nil
+	}
+}
+
+// watch opens one FetchX509SVID stream and applies its updates until it fails
+func (s *SPIFFESource) watch(ctx context.Context) error {
+	target := s.socketPath
+	if !strings.HasPrefix(target, "unix:") {
+		target = "unix://" + target
+	}
+
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The Workload API rejects requests without this header
+	ctx = metadata.AppendToOutgoingContext(ctx, "workload.spiffe.io", "true")
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fetchX509SVIDMethod,
+		grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return err
+	}
+
+	// X509SVIDRequest has no fields
+	if err := stream.SendMsg(&[]byte{}); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		var resp []byte
+		if err := stream.RecvMsg(&resp); err != nil {
+			return err
+		}
+		if err := s.update(resp); err != nil {
+			s.logger.Warn("Ignoring SPIFFE SVID update", logging.Err(err))
+		}
+	}
+}
+
+// update applies an X509SVIDResponse, using its first (default) SVID
+func (s *SPIFFESource) update(resp []byte) error {
+	svids, err := parseX509SVIDResponse(resp)
+	if err != nil {
+		return err
+	}
+	if len(svids) == 0 {
+		return errors.New("response contains no SVIDs")
+	}
+	svid := svids[0]
+
+	certs, err := x509.ParseCertificates(svid.certs)
+	if err != nil || len(certs) == 0 {
+		return fmt.Errorf("invalid SVID certificates for %s: %v", svid.id, err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(svid.key)
+	if err != nil {
+		return fmt.Errorf("invalid SVID key for %s: %w", svid.id, err)
+	}
+	bundleCerts, err := x509.ParseCertificates(svid.bundle)
+	if err != nil {
+		return fmt.Errorf("invalid trust bundle for %s: %w", svid.id, err)
+	}
+
+	tlsCert := tls.Certificate{PrivateKey: key, Leaf: certs[0]}
+	for _, cert := range certs {
+		tlsCert.Certificate = append(tlsCert.Certificate, cert.Raw)
+	}
+	cert := &Certificate{
+		Cert:      certs[0],
+		TLSCert:   tlsCert,
+		Domains:   certs[0].DNSNames,
+		NotBefore: certs[0].NotBefore,
+		NotAfter:  certs[0].NotAfter,
+	}
+
+	bundle := x509.NewCertPool()
+	for _, c := range bundleCerts {
+		bundle.AddCert(c)
+	}
+
+	if s.certMgr != nil {
+		if err := s.certMgr.AddCertificate(cert); err != nil {
+			return err
+		}
+		if err := s.certMgr.SetDefaultCertificate(cert); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.svid = cert
+	s.id = svid.id
+	s.bundle = bundle
+	s.mu.Unlock()
+
+	s.readyOnce.Do(func() { close(s.ready) })
+	s.logger.Info("Received SPIFFE SVID", logging.String("id", svid.id), logging.String("not_after", cert.NotAfter.Format(time.RFC3339)))
+	return nil
+}
+
+// x509SVID is an X509SVID message of the Workload API
+type x509SVID struct {
+	id     string
+	certs  []byte // concatenated DER certificates, leaf first
+	key    []byte // PKCS#8 DER private key
+	bundle []byte // concatenated DER trust bundle certificates
+}
+
+// parseX509SVIDResponse decodes the svids field of an X509SVIDResponse
+func parseX509SVIDResponse(b []byte) ([]x509SVID, error) {
+	var svids []x509SVID
+	err := walkFields(b, func(num protowire.Number, v []byte) error {
+		if num != 1 {
+			return nil
+		}
+		var svid x509SVID
+		err := walkFields(v, func(num protowire.Number, v []byte) error {
+			switch num {
+			case 1:
+				svid.id = string(v)
+			case 2:
+				svid.certs = v
+			case 3:
+				svid.key = v
+			case 4:
+				svid.bundle = v
+			}
+			return nil
+		})
+		svids = append(svids, svid)
+		return err
+	})
+	return svids, err
+}
+
+// walkFields calls fn for each length-delimited field of a protobuf message
+// Fields of other wire types are skipped
+func walkFields(b []byte, fn func(protowire.Number, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spiffeID returns the spiffe:// URI SAN of a certificate ("" if it has none)
+func spiffeID(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	return ""
+}
+
+// rawCodec passes pre-encoded protobuf messages through gRPC unchanged
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("rawCodec: unexpected message type %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("rawCodec: unexpected message type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
@@ -0,0 +1,174 @@
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestSPIFFESource(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Trust Domain"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	// issue returns a DER SVID and its PKCS#8 key for a SPIFFE ID
+	issue := func(id string, serial int64) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		uri, _ := url.Parse(id)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			URIs:         []*url.URL{uri},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("Failed to issue SVID: %v", err)
+		}
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("Failed to marshal key: %v", err)
+		}
+		return der, keyDER
+	}
+
+	const id = "spiffe://example.org/balance"
+	svidDER, keyDER := issue(id, 2)
+
+	var svid []byte
+	svid = protowire.AppendTag(svid, 1, protowire.BytesType)
+	svid = protowire.AppendString(svid, id)
+	svid = protowire.AppendTag(svid, 2, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, svidDER)
+	svid = protowire.AppendTag(svid, 3, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, keyDER)
+	svid = protowire.AppendTag(svid, 4, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, caDER)
+	var resp []byte
+	resp = protowire.AppendTag(resp, 1, protowire.BytesType)
+	resp = protowire.AppendBytes(resp, svid)
+
+	// Fake Workload API serving a single response and holding the stream open
+	server := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			if method != fetchX509SVIDMethod {
+				return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+			}
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			if len(md.Get("workload.spiffe.io")) == 0 {
+				return status.Error(codes.InvalidArgument, "missing security header")
+			}
+			var req []byte
+			if err := stream.RecvMsg(&req); err != nil {
+				return err
+			}
+			if err := stream.SendMsg(&resp); err != nil {
+				return err
+			}
+			<-stream.Context().Done()
+			return nil
+		}),
+	)
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(ln)
+	defer server.Stop()
+
+	cm := NewCertificateManager(nil)
+	source := NewSPIFFESource(socketPath, cm, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go source.Run(ctx)
+
+	select {
+	case <-source.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the SVID")
+	}
+
+	if got := source.ID(); got != id {
+		t.Errorf("Expected SPIFFE ID %s, got %s", id, got)
+	}
+
+	clientCert, err := source.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetClientCertificate() error = %v", err)
+	}
+	if len(clientCert.Certificate) != 1 || string(clientCert.Certificate[0]) != string(svidDER) {
+		t.Error("Expected the SVID as the client certificate")
+	}
+
+	if def := cm.Lookup(""); def == nil || def.TLSCert.Leaf.SerialNumber.Int64() != 2 {
+		t.Error("Expected the SVID as the default certificate")
+	}
+
+	tests := []struct {
+		name    string
+		peerID  string
+		allowed []string
+		wantErr bool
+	}{
+		{name: "any ID", peerID: "spiffe://example.org/backend", wantErr: false},
+		{name: "allowed ID", peerID: "spiffe://example.org/backend", allowed: []string{"spiffe://example.org/backend"}, wantErr: false},
+		{name: "other ID", peerID: "spiffe://example.org/other", allowed: []string{"spiffe://example.org/backend"}, wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peerDER, _ := issue(tt.peerID, int64(10+i))
+			err := source.VerifyPeer(tt.allowed)([][]byte{peerDER}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyPeer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Certificates from another CA are rejected
+	other, err := GenerateSelfSignedCertificate([]string{"example.com"})
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	if err := source.VerifyPeer(nil)([][]byte{other.Cert.Raw}, nil); err == nil {
+		t.Error("Expected a certificate outside the bundle to be rejected")
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"log"
 	"net"
 	"sync/atomic"
 	"time"
+
+	"github.com/therealutkarshpriyadarshi/balance/pkg/logging"
 )
 
 // Terminator handles TLS termination
@@ -20,6 +21,8 @@ type Terminator struct {
 	// Session cache for TLS session resumption
 	sessionCache tls.ClientSessionCache
 
+	logger *logging.Logger
+
 	// Statistics
 	totalConnections     atomic.Int64
 	activeConnections    atomic.Int64
@@ -29,8 +32,8 @@ type Terminator struct {
 	handshakeDuration    atomic.Int64 // Total handshake time in microseconds
 }
 
-// NewTerminator creates a new TLS terminator
-func NewTerminator(config *Config, certMgr *CertificateManager) (*Terminator, error) {
+// NewTerminator creates a new TLS terminator logging to logger
+func NewTerminator(config *Config, certMgr *CertificateManager, logger *logging.Logger) (*Terminator, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
@@ -47,6 +50,7 @@ func NewTerminator(config *Config, certMgr *CertificateManager) (*Terminator, er
 		config:       config,
 		certMgr:      certMgr,
 		sessionCache: tls.NewLRUClientSessionCache(1024), // Cache up to 1024 sessions
+		logger:       logger,
 	}
 
 	// Build tls.Config
@@ -88,9 +92,8 @@ func (t *Terminator) Listen(address string) error {
 	// Wrap with TLS
 	t.listener = tls.NewListener(listener, t.tlsConfig)
 
-	log.Printf("TLS listener started on %s", address)
-	log.Printf("TLS configuration: MinVersion=%s, CipherSuites=%d",
-		tlsVersionString(t.config.MinVersion), len(t.config.CipherSuites))
+	t.logger.Info("TLS listener started", logging.String("address", address),
+		logging.String("min_version", tlsVersionString(t.config.MinVersion)), logging.Int("cipher_suites", len(t.config.CipherSuites)))
 
 	return nil
 }
@@ -218,7 +221,7 @@ func (t *Terminator) UpdateConfig(config *Config) error {
 	t.config = config
 	t.tlsConfig = t.buildTLSConfig()
 
-	log.Printf("TLS configuration updated")
+	t.logger.Info("TLS configuration updated")
 	return nil
 }
 
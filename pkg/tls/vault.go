@@ -0,0 +1,152 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultPKIConfig configures a VaultPKISource
+type VaultPKIConfig struct {
+	// Address of the Vault server, e.g. https://vault.example.com:8200
+	Address string
+
+	// Token authenticates to Vault; TokenFile, if set, is read on every request instead
+	Token     string
+	TokenFile string
+
+	// Mount is the path of the PKI secrets engine and Role the role to issue from
+	Mount string
+	Role  string
+
+	// CommonName, AltNames and TTL of the issued certificate (TTL 0 uses the role's TTL)
+	CommonName string
+	AltNames   []string
+	TTL        time.Duration
+
+	// CAFile trusts an additional CA for the Vault server's certificate
+	CAFile string
+}
+
+// VaultPKISource issues certificates from a HashiCorp Vault PKI secrets engine
+// Every issue creates a new certificate, so Fetch returns the previously issued
+// certificate until it is due for renewal.
+type VaultPKISource struct {
+	config VaultPKIConfig
+	client *http.Client
+
+	mu   sync.Mutex
+	last *Certificate
+}
+
+// NewVaultPKISource creates a source issuing certificates from cfg.Role
+func NewVaultPKISource(cfg VaultPKIConfig) (*VaultPKISource, error) {
+	client, err := newSourceHTTPClient(cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	cfg.Address = strings.TrimSuffix(cfg.Address, "/")
+	cfg.Mount = strings.Trim(cfg.Mount, "/")
+	return &VaultPKISource{config: cfg, client: client}, nil
+}
+
+// Name identifies the source in logs
+func (v *VaultPKISource) Name() string {
+	return fmt.Sprintf("vault %s/%s", v.config.Mount, v.config.Role)
+}
+
+// Fetch returns the issued certificate, issuing a new one if none has been issued yet
+// or two thirds of its lifetime have passed
+func (v *VaultPKISource) Fetch(ctx context.Context) (*Certificate, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.last != nil && time.Now().Before(renewalTime(v.last)) {
+		return v.last, nil
+	}
+
+	cert, err := v.issue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.last = cert
+	return cert, nil
+}
+
+// vaultIssueResponse is the response of the PKI issue endpoint
+type vaultIssueResponse struct {
+	Data struct {
+		Certificate string   `json:"certificate"`
+		IssuingCA   string   `json:"issuing_ca"`
+		CAChain     []string `json:"ca_chain"`
+		PrivateKey  string   `json:"private_key"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// issue requests a new certificate from Vault
+func (v *VaultPKISource) issue(ctx context.Context) (*Certificate, error) {
+	token := v.config.Token
+	if v.config.TokenFile != "" {
+		b, err := os.ReadFile(v.config.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("vault: failed to read token file: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	req := map[string]string{"common_name": v.config.CommonName}
+	if len(v.config.AltNames) > 0 {
+		req["alt_names"] = strings.Join(v.config.AltNames, ",")
+	}
+	if v.config.TTL > 0 {
+		req["ttl"] = fmt.Sprintf("%ds", int64(v.config.TTL.Seconds()))
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/issue/%s", v.config.Address, v.config.Mount, v.config.Role)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	httpReq.Header.Set("X-Vault-Token", token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var issued vaultIssueResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&issued); err != nil {
+		return nil, fmt.Errorf("vault: invalid response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: issue failed with status %d: %s", resp.StatusCode, strings.Join(issued.Errors, "; "))
+	}
+
+	// Serve the leaf with its intermediates; ca_chain is absent on older Vault versions
+	chain := []string{issued.Data.Certificate}
+	if len(issued.Data.CAChain) > 0 {
+		chain = append(chain, issued.Data.CAChain...)
+	} else if issued.Data.IssuingCA != "" {
+		chain = append(chain, issued.Data.IssuingCA)
+	}
+
+	cert, err := ParseCertificatePEM([]byte(strings.Join(chain, "\n")), []byte(issued.Data.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	return cert, nil
+}
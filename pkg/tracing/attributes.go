@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes Balance can add to request spans
+const (
+	// AttributeRoute adds the name of the route a request matched as balance.route
+	AttributeRoute = "route"
+
+	// AttributeBackend adds the backend a request was proxied to as balance.backend
+	AttributeBackend = "backend"
+
+	// AttributeClientIP adds the client's network (/24 for IPv4, /48 for IPv6) as
+	// balance.client_ip, in place of the full client address
+	AttributeClientIP = "client_ip"
+
+	// AttributeHeaderPrefix adds a request header, e.g. "header:X-Tenant-ID" as
+	// http.request.header.x-tenant-id
+	AttributeHeaderPrefix = "header:"
+
+	// AttributeBaggagePrefix adds a W3C baggage member, e.g. "baggage:tenant" as baggage.tenant
+	AttributeBaggagePrefix = "baggage:"
+)
+
+// spanAttributes is the parsed set of attributes added to request spans
+type spanAttributes struct {
+	route    bool
+	backend  bool
+	clientIP bool
+	headers  []string
+	baggage  []string
+}
+
+// parseSpanAttributes parses the configured span attributes
+func parseSpanAttributes(names []string) (spanAttributes, error) {
+	var attrs spanAttributes
+	for _, name := range names {
+		switch {
+		case name == AttributeRoute:
+			attrs.route = true
+		case name == AttributeBackend:
+			attrs.backend = true
+		case name == AttributeClientIP:
+			attrs.clientIP = true
+		case strings.HasPrefix(name, AttributeHeaderPrefix) && len(name) > len(AttributeHeaderPrefix):
+			attrs.headers = append(attrs.headers, http.CanonicalHeaderKey(strings.TrimPrefix(name, AttributeHeaderPrefix)))
+		case strings.HasPrefix(name, AttributeBaggagePrefix) && len(name) > len(AttributeBaggagePrefix):
+			attrs.baggage = append(attrs.baggage, strings.TrimPrefix(name, AttributeBaggagePrefix))
+		default:
+			return spanAttributes{}, fmt.Errorf("invalid span attribute: %s (must be route, backend, client_ip, header:<name> or baggage:<key>)", name)
+		}
+	}
+	return attrs, nil
+}
+
+// requestAttributes returns the header and baggage attributes of an incoming request
+// whose baggage has been extracted into ctx
+func (a spanAttributes) requestAttributes(ctx context.Context, r *http.Request) []attribute.KeyValue {
+	var kvs []attribute.KeyValue
+	for _, name := range a.headers {
+		if value := r.Header.Get(name); value != "" {
+			kvs = append(kvs, attribute.String("http.request.header."+strings.ToLower(name), value))
+		}
+	}
+	if len(a.baggage) > 0 {
+		bag := baggage.FromContext(ctx)
+		for _, key := range a.baggage {
+			if member := bag.Member(key); member.Key() != "" {
+				kvs = append(kvs, attribute.String("baggage."+key, member.Value()))
+			}
+		}
+	}
+	return kvs
+}
+
+// SetRequestAttributes adds the configured route, backend and client attributes to
+// the span of a request being proxied
+func (t *Tracer) SetRequestAttributes(ctx context.Context, route, backend, clientIP string) {
+	var kvs []attribute.KeyValue
+	if t.attributes.route && route != "" {
+		kvs = append(kvs, attribute.String("balance.route", route))
+	}
+	if t.attributes.backend {
+		kvs = append(kvs, attribute.String("balance.backend", backend))
+	}
+	if t.attributes.clientIP {
+		kvs = append(kvs, attribute.String("balance.client_ip", anonymizeIP(clientIP)))
+	}
+	if len(kvs) > 0 {
+		trace.SpanFromContext(ctx).SetAttributes(kvs...)
+	}
+}
+
+// anonymizeIP returns the network of a client address: its /24 for IPv4 and its /48
+// for IPv6
+func anonymizeIP(clientIP string) string {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return "invalid"
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
@@ -1,14 +1,19 @@
 package tracing
 
 import (
+	"bufio"
 	"context"
+	"encoding/binary"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -20,18 +25,42 @@ const (
 	tracerName = "balance-proxy"
 )
 
+// Exporters spans can be sent with
+const (
+	// ExporterJaeger sends spans to a Jaeger collector over HTTP
+	ExporterJaeger = "jaeger"
+
+	// ExporterJaegerAgent sends spans to a Jaeger agent over UDP
+	ExporterJaegerAgent = "jaeger-agent"
+
+	// ExporterZipkin sends spans to a Zipkin collector over HTTP
+	ExporterZipkin = "zipkin"
+)
+
 // Config configures the tracing system
 type Config struct {
 	Enabled     bool
 	ServiceName string
-	Endpoint    string // Jaeger endpoint
+	Exporter    string // ExporterJaeger (default), ExporterJaegerAgent or ExporterZipkin
+	Endpoint    string // Collector URL, or host:port of the Jaeger agent
 	SampleRate  float64
+
+	// SampleRateFunc overrides SampleRate for some requests, such as those of a route
+	// (optional; ok is false to keep SampleRate)
+	SampleRateFunc func(r *http.Request) (rate float64, ok bool)
+
+	// Attributes are extra attributes added to request spans: AttributeRoute,
+	// AttributeBackend, AttributeClientIP, or AttributeHeaderPrefix and
+	// AttributeBaggagePrefix followed by a header name or baggage key
+	Attributes []string
 }
 
 // Tracer wraps OpenTelemetry tracer
 type Tracer struct {
 	tracer         trace.Tracer
 	tracerProvider *sdktrace.TracerProvider
+	sampleRate     func(r *http.Request) (float64, bool)
+	attributes     spanAttributes
 }
 
 // NewTracer creates a new tracer
@@ -42,10 +71,14 @@ func NewTracer(config Config) (*Tracer, error) {
 		}, nil
 	}
 
-	// Create Jaeger exporter
-	exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(config.Endpoint)))
+	attributes, err := parseSpanAttributes(config.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := newExporter(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Jaeger exporter: %w", err)
+		return nil, err
 	}
 
 	// Create resource
@@ -63,13 +96,13 @@ func NewTracer(config Config) (*Tracer, error) {
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exporter),
 		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(config.SampleRate)),
+		sdktrace.WithSampler(rateSampler{rate: config.SampleRate}),
 	)
 
 	// Set global tracer provider
 	otel.SetTracerProvider(tp)
 
-	// Set global propagator for trace context
+	// Set global propagator for trace context and W3C baggage
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
 		propagation.TraceContext{},
 		propagation.Baggage{},
@@ -78,9 +111,84 @@ func NewTracer(config Config) (*Tracer, error) {
 	return &Tracer{
 		tracer:         tp.Tracer(tracerName),
 		tracerProvider: tp,
+		sampleRate:     config.SampleRateFunc,
+		attributes:     attributes,
 	}, nil
 }
 
+// sampleRateKey is the context key of a sample rate overriding the configured one
+type sampleRateKey struct{}
+
+// WithSampleRate returns a context whose new traces are sampled at rate instead of
+// the configured sample rate
+func WithSampleRate(ctx context.Context, rate float64) context.Context {
+	return context.WithValue(ctx, sampleRateKey{}, rate)
+}
+
+// rateSampler samples a fraction of traces by trace ID like sdktrace.TraceIDRatioBased,
+// at the rate carried by the span's context if it has one
+type rateSampler struct {
+	rate float64
+}
+
+// ShouldSample decides whether a span is sampled
+func (s rateSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	rate := s.rate
+	if r, ok := p.ParentContext.Value(sampleRateKey{}).(float64); ok {
+		rate = r
+	}
+
+	decision := sdktrace.Drop
+	if rate >= 1 || (rate > 0 && binary.BigEndian.Uint64(p.TraceID[8:16])>>1 < uint64(rate*(1<<63))) {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+// Description describes the sampler
+func (s rateSampler) Description() string {
+	return fmt.Sprintf("RateSampler{%g}", s.rate)
+}
+
+// newExporter creates the span exporter selected by config.Exporter
+func newExporter(config Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case "", ExporterJaeger:
+		exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(config.Endpoint)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Jaeger exporter: %w", err)
+		}
+		return exporter, nil
+	case ExporterJaegerAgent:
+		var opts []jaeger.AgentEndpointOption
+		if config.Endpoint != "" {
+			host, port, err := net.SplitHostPort(config.Endpoint)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Jaeger agent endpoint %s: %w", config.Endpoint, err)
+			}
+			opts = append(opts, jaeger.WithAgentHost(host), jaeger.WithAgentPort(port))
+		}
+		exporter, err := jaeger.New(jaeger.WithAgentEndpoint(opts...))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Jaeger agent exporter: %w", err)
+		}
+		return exporter, nil
+	case ExporterZipkin:
+		if !strings.HasPrefix(config.Endpoint, "http://") && !strings.HasPrefix(config.Endpoint, "https://") {
+			return nil, fmt.Errorf("invalid Zipkin endpoint %q: must be an http or https URL", config.Endpoint)
+		}
+		exporter, err := zipkin.New(config.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Zipkin exporter: %w", err)
+		}
+		return exporter, nil
+	}
+	return nil, fmt.Errorf("unsupported trace exporter: %s (must be jaeger, jaeger-agent or zipkin)", config.Exporter)
+}
+
 // StartSpan starts a new span
 func (t *Tracer) StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
 	return t.tracer.Start(ctx, name, opts...)
@@ -99,19 +207,28 @@ func (t *Tracer) HTTPMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract trace context from headers
 		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		if t.sampleRate != nil {
+			if rate, ok := t.sampleRate(r); ok {
+				ctx = WithSampleRate(ctx, rate)
+			}
+		}
 
-		// Start span
+		// Start span; the full client address is left out when only its network is recorded
+		attrs := []attribute.KeyValue{
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPURLKey.String(r.URL.String()),
+			semconv.HTTPTargetKey.String(r.URL.Path),
+			semconv.HTTPSchemeKey.String(r.URL.Scheme),
+			attribute.String("http.host", r.Host),
+			attribute.String("http.user_agent", r.UserAgent()),
+		}
+		if !t.attributes.clientIP {
+			attrs = append(attrs, attribute.String("http.client_ip", r.RemoteAddr))
+		}
+		attrs = append(attrs, t.attributes.requestAttributes(ctx, r)...)
 		ctx, span := t.StartSpan(ctx, r.Method+" "+r.URL.Path,
 			trace.WithSpanKind(trace.SpanKindServer),
-			trace.WithAttributes(
-				semconv.HTTPMethodKey.String(r.Method),
-				semconv.HTTPURLKey.String(r.URL.String()),
-				semconv.HTTPTargetKey.String(r.URL.Path),
-				semconv.HTTPSchemeKey.String(r.URL.Scheme),
-				attribute.String("http.host", r.Host),
-				attribute.String("http.user_agent", r.UserAgent()),
-				attribute.String("http.client_ip", r.RemoteAddr),
-			),
+			trace.WithAttributes(attrs...),
 		)
 		defer span.End()
 
@@ -144,6 +261,15 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack implements http.Hijacker so traced handlers can still upgrade connections
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
 // StartProxySpan starts a span for proxying to a backend
 func (t *Tracer) StartProxySpan(ctx context.Context, backend, operation string) (context.Context, trace.Span) {
 	return t.StartSpan(ctx, "proxy: "+operation,
@@ -42,7 +42,7 @@ func TestTCPProxyBasic(t *testing.T) {
 	}
 
 	// Create and start proxy server
-	server, err := proxy.NewTCPServer(cfg)
+	server, err := proxy.NewTCPServer(cfg, nil)
 	if err != nil {
 		t.Fatalf("Failed to create proxy server: %v", err)
 	}
@@ -111,7 +111,7 @@ func TestHTTPProxyBasic(t *testing.T) {
 	}
 
 	// Create and start proxy server
-	server, err := proxy.NewHTTPServer(cfg)
+	server, err := proxy.NewHTTPServer(cfg, nil)
 	if err != nil {
 		t.Fatalf("Failed to create proxy server: %v", err)
 	}
@@ -177,7 +177,7 @@ func TestLoadBalancing(t *testing.T) {
 	}
 
 	// Create and start proxy server
-	server, err := proxy.NewHTTPServer(cfg)
+	server, err := proxy.NewHTTPServer(cfg, nil)
 	if err != nil {
 		t.Fatalf("Failed to create proxy server: %v", err)
 	}